@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/meb/bench"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditProject    string
+	auditUser       string
+	auditKind       string
+	auditContains   string
+	auditSince      time.Duration
+	auditMinLatency time.Duration
+	auditErrors     bool
+	auditLimit      int
+	auditFormat     string // Deprecated --format
+	auditOutput     string
+	auditInput      string
+	auditTop        int
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "List and replay the requests recorded in a server's audit log",
+	Long: `Inspect the audit log a server keeps of every Datalog, path and AI request
+it executes: who sent it, for which project, how long it took and how many
+rows it returned.
+
+The audit log is in the server's data folder. While the server runs, the log
+is locked; fetch it from GET /api/v1/audit?format=jsonl instead and pass the
+file to "gca audit replay --input".`,
+}
+
+var auditListCmd = &cobra.Command{
+	Use:   "list [data-folder]",
+	Short: "List audited requests, newest first",
+	Long: `List audited requests, newest first, one per line, or with --output json
+as JSON lines, one entry per line, for "gca audit replay --input".
+
+Arguments:
+  data-folder  Path to the data directory (default: ./data)`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed("format") && auditFormat == "jsonl" {
+			auditOutput = outputJSON
+		}
+		if err := checkOutput(auditOutput, outputText, outputJSON); err != nil {
+			return err
+		}
+		entries, err := auditEntries(auditDataPath(args))
+		if err != nil {
+			return err
+		}
+		if auditOutput == outputJSON {
+			enc := json.NewEncoder(os.Stdout)
+			for _, e := range entries {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, e := range entries {
+			status := fmt.Sprintf("%d rows", e.Rows)
+			if e.Error != "" {
+				status = "error: " + e.Error
+			}
+			fmt.Printf("%6d  %s  %-8s %-7s %-16s %10v  %s  %s\n", e.ID, e.Time.Local().Format(time.DateTime),
+				e.User, e.Kind, e.Project, auditLatency(e.LatencyUS), auditSummary(e), status)
+		}
+		return nil
+	},
+}
+
+var auditReplayCmd = &cobra.Command{
+	Use:   "replay [data-folder]",
+	Short: "Replay audited requests and compare their latency with the recorded one",
+	Long: `Replay audited Datalog and path requests, one at a time, through the same
+service calls the server made, against the stores in the data folder, and
+report how each one's latency compares with the recorded one. Use it to find
+the queries a store or code change made slower.
+
+AI requests are skipped: they depend on the model's answers, not the store.
+The requests come from the data folder's audit log, or from --input, a file
+saved from GET /api/v1/audit?format=jsonl or "gca audit list --output json".
+The filter flags select which requests are replayed.
+
+Arguments:
+  data-folder  Path to the data directory (default: ./data)`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataPath := auditDataPath(args)
+		var entries []gcamdb.AuditEntry
+		var err error
+		if auditInput != "" {
+			entries, err = readAuditFile(auditInput)
+		} else {
+			entries, err = auditEntries(dataPath)
+		}
+		if err != nil {
+			return err
+		}
+
+		newManager := manager.NewStoreManager
+		if sharedStore {
+			newManager = manager.NewSharedStoreManager
+		}
+		mgr := newManager(dataPath, getMemoryProfile(), true)
+		defer mgr.CloseAll()
+		svc := service.NewGraphService(mgr)
+
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return auditReplay(ctx, os.Stdout, svc, entries)
+	},
+}
+
+// auditDataPath returns the data folder named by args, or the --data default.
+func auditDataPath(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return dataDir
+}
+
+// auditFilter returns the filter set by the command's flags.
+func auditFilter() gcamdb.AuditFilter {
+	f := gcamdb.AuditFilter{
+		Project:    auditProject,
+		User:       auditUser,
+		Kind:       auditKind,
+		Contains:   auditContains,
+		MinLatency: auditMinLatency,
+		ErrorsOnly: auditErrors,
+		Limit:      auditLimit,
+	}
+	if auditSince > 0 {
+		f.Since = time.Now().Add(-auditSince)
+	}
+	return f
+}
+
+// auditEntries reads the entries selected by the flags from the audit log in
+// dataPath.
+func auditEntries(dataPath string) ([]gcamdb.AuditEntry, error) {
+	dir := filepath.Join(dataPath, config.AuditStoreDir)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("no audit log in %s: %w", dataPath, err)
+	}
+	log, err := gcamdb.OpenAuditLog(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	defer log.Close()
+	return log.Entries(auditFilter())
+}
+
+// readAuditFile reads entries saved one per line and keeps those selected by
+// the flags.
+func readAuditFile(path string) ([]gcamdb.AuditEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	filter := auditFilter()
+	var entries []gcamdb.AuditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var e gcamdb.AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if filter.Match(&e) {
+			entries = append(entries, e)
+			if filter.Limit > 0 && len(entries) >= filter.Limit {
+				break
+			}
+		}
+	}
+	return entries, sc.Err()
+}
+
+// auditReplayed is one replayed entry with its replay latency.
+type auditReplayed struct {
+	entry    gcamdb.AuditEntry
+	replayed time.Duration
+}
+
+// ratio is how many times slower the replay was than the recorded request.
+func (r auditReplayed) ratio() float64 {
+	recorded := max(auditLatency(r.entry.LatencyUS), time.Microsecond)
+	return float64(r.replayed) / float64(recorded)
+}
+
+// auditReplay replays entries oldest first and writes the latency comparison
+// to w.
+func auditReplay(ctx context.Context, w io.Writer, svc *service.GraphService, entries []gcamdb.AuditEntry) error {
+	recorded, replayed := bench.NewHistogram(), bench.NewHistogram()
+	var runs []auditReplayed
+	var skipped, failed int
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e := entries[i]
+		if e.Kind == gcamdb.AuditAI {
+			skipped++
+			continue
+		}
+		began := time.Now()
+		err := replayAuditEntry(ctx, svc, e)
+		took := time.Since(began)
+		// A request that failed when recorded is expected to fail again
+		if err != nil && e.Error == "" {
+			failed++
+			fmt.Fprintf(w, "entry %d (%s): %v\n", e.ID, e.Project, err)
+			continue
+		}
+		recorded.Record(auditLatency(e.LatencyUS))
+		replayed.Record(took)
+		runs = append(runs, auditReplayed{entry: e, replayed: took})
+	}
+
+	fmt.Fprintf(w, "Replayed %d requests (%d AI requests skipped, %d failed)\n", len(runs), skipped, failed)
+	if len(runs) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\n%-6s %12s %12s\n", "", "recorded", "replayed")
+	for _, p := range []struct {
+		name string
+		get  func(*bench.Histogram) time.Duration
+	}{
+		{"p50", func(h *bench.Histogram) time.Duration { return h.Percentile(50) }},
+		{"p95", func(h *bench.Histogram) time.Duration { return h.Percentile(95) }},
+		{"p99", func(h *bench.Histogram) time.Duration { return h.Percentile(99) }},
+		{"max", (*bench.Histogram).Max},
+	} {
+		fmt.Fprintf(w, "%-6s %12v %12v\n", p.name, p.get(recorded).Round(time.Microsecond), p.get(replayed).Round(time.Microsecond))
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].ratio() > runs[j].ratio() })
+	fmt.Fprintf(w, "\nSlowest against their recording:\n")
+	for _, r := range runs[:min(auditTop, len(runs))] {
+		fmt.Fprintf(w, "%6.1fx %10v -> %-10v %6d  %s  %s\n", r.ratio(), auditLatency(r.entry.LatencyUS),
+			r.replayed.Round(time.Microsecond), r.entry.ID, r.entry.Project, auditSummary(r.entry))
+	}
+	return nil
+}
+
+// replayAuditEntry issues an audited request through the service call that
+// served it.
+func replayAuditEntry(ctx context.Context, svc *service.GraphService, e gcamdb.AuditEntry) error {
+	var err error
+	switch {
+	case e.Kind == gcamdb.AuditDatalog && e.Endpoint == "/api/v1/query/federated":
+		_, err = svc.ExecuteFederatedQueryWithOptions(ctx, strings.Split(e.Project, ","), e.Query, gcamdb.QueryOptions{Limit: e.Limit})
+	case e.Kind == gcamdb.AuditPath && e.Endpoint == "/api/v1/search/flow":
+		_, err = svc.GetFlowPath(ctx, e.Project, e.Source, e.Target)
+	case e.Kind == gcamdb.AuditDatalog:
+		err = replayQuery(ctx, svc, bench.QueryRecord{Kind: bench.QueryDatalog, Project: e.Project, Query: e.Query, Raw: e.Raw, Limit: e.Limit})
+	case e.Kind == gcamdb.AuditPath:
+		err = replayQuery(ctx, svc, bench.QueryRecord{Kind: bench.QueryPath, Project: e.Project, Source: e.Source, Target: e.Target,
+			Predicates: e.Predicates, Direction: e.Direction, Depth: e.Depth})
+	default:
+		err = fmt.Errorf("unknown request kind %q", e.Kind)
+	}
+	return err
+}
+
+// auditLatency converts a recorded latency to a duration.
+func auditLatency(us int64) time.Duration {
+	return time.Duration(us) * time.Microsecond
+}
+
+// auditSummary is a one-line description of an audited request.
+func auditSummary(e gcamdb.AuditEntry) string {
+	if e.Kind == gcamdb.AuditPath {
+		return e.Source + " -> " + e.Target
+	}
+	q := strings.Join(strings.Fields(e.Query), " ")
+	if len(q) > 80 {
+		q = q[:77] + "..."
+	}
+	return q
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditListCmd, auditReplayCmd)
+	for _, c := range []*cobra.Command{auditListCmd, auditReplayCmd} {
+		c.Flags().StringVar(&auditProject, "project", "", "Only requests for this project")
+		c.Flags().StringVar(&auditUser, "user", "", "Only requests from this user")
+		c.Flags().StringVar(&auditKind, "kind", "", "Only requests of this kind (datalog, path, ai)")
+		c.Flags().StringVar(&auditContains, "contains", "", "Only queries containing this text")
+		c.Flags().DurationVar(&auditSince, "since", 0, "Only requests from this long ago or later, such as 24h")
+		c.Flags().DurationVar(&auditMinLatency, "min-latency", 0, "Only requests that took at least this long")
+		c.Flags().BoolVar(&auditErrors, "errors", false, "Only requests that failed")
+	}
+	auditListCmd.Flags().IntVar(&auditLimit, "limit", config.AuditDefaultLimit, "Maximum number of requests listed")
+	addOutputFlag(auditListCmd, &auditOutput, outputText, outputJSON)
+	auditListCmd.Flags().StringVar(&auditFormat, "format", outputText, "Output format: text or jsonl")
+	auditListCmd.Flags().MarkDeprecated("format", "use --output json")
+	auditReplayCmd.Flags().IntVar(&auditLimit, "limit", config.AuditMaxLimit, "Maximum number of requests replayed, newest first")
+	auditReplayCmd.Flags().StringVar(&auditInput, "input", "", "Replay the requests in this file instead of the data folder's audit log")
+	auditReplayCmd.Flags().IntVar(&auditTop, "top", 10, "Number of slowest requests shown")
+	auditReplayCmd.Flags().BoolVar(&sharedStore, "shared-store", false, "The data folder is one store holding several projects")
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/spf13/cobra"
+)
+
+const outputDOT = "dot"
+
+var (
+	exportQuery       string
+	exportLimit       int
+	exportFilterTests bool
+	exportOutput      string
+	exportFile        string
+	exportTitle       string
+	exportProject     string
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export [data-folder]",
+	Short: "Export the graph a Datalog query binds, as D3 JSON or Graphviz DOT",
+	Long: `Export the graph of the nodes and links a Datalog query binds, by default
+every call, as the D3 JSON the server's /api/v1/query returns or, with
+--output dot, as Graphviz DOT. The graph is written to stdout unless
+--out-file names a file.
+
+"gca export html" writes the graph as a single HTML page that draws it.
+
+Examples:
+  gca export ./data/my-project --out-file graph.json
+  gca export --project my-project --query 'triples(?A, "imports", ?B)' -o dot | dot -Tsvg > imports.svg
+
+Arguments:
+  data-folder  Path to the data directory (default: ./data)`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(exportOutput, outputJSON, outputDOT); err != nil {
+			return err
+		}
+		if exportFile == "" {
+			status = os.Stderr // The graph takes stdout
+		}
+		dataPath := dataDir
+		if len(args) > 0 {
+			dataPath = args[0]
+		}
+
+		graph, err := exportGraph(dataPath, exportProject, exportQuery)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if exportFile != "" {
+			f, err := os.Create(exportFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		if exportOutput == outputDOT {
+			err = export.WriteDOT(w, graph)
+		} else {
+			err = writeJSON(w, graph)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(status, "Exported %d nodes and %d links\n", len(graph.Nodes), len(graph.Links))
+		return nil
+	},
+}
+
+// exportHTMLCmd represents the export html command
+var exportHTMLCmd = &cobra.Command{
+	Use:   "html <data-folder> <datalog> <out.html>",
+	Short: "Export the graph a Datalog query binds as a self-contained HTML page",
+	Long: `Write the graph of the nodes and links a Datalog query binds as one HTML
+file holding the graph and a small renderer: pan and zoom, drag nodes, click
+a node for its details and neighbors, search by name. The page loads nothing
+else, so it can be mailed, attached to a ticket or opened offline.
+
+Nodes are laid out as the server lays out graphs; graphs spanning too many
+files for that are drawn on a circle.
+
+Examples:
+  gca export html ./data/my-project 'triples(?A, "imports", ?B)' imports.html
+
+Arguments:
+  data-folder  Path to the data directory
+  datalog      The query whose bindings are drawn
+  out.html     File the page is written to`,
+	Args:              cobra.ExactArgs(3),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs, cobra.ShellCompDirectiveNoFileComp, cobra.ShellCompDirectiveDefault),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataPath, query, outPath := args[0], args[1], args[2]
+		graph, err := exportGraph(dataPath, exportProject, query)
+		if err != nil {
+			return err
+		}
+		if positions, ok := export.ComputeLayout(graph, export.LayoutOptions{
+			Size:       config.LayoutSize,
+			Iterations: config.LayoutIterations,
+			MaxFiles:   config.LayoutMaxFiles,
+		}); ok {
+			graph.ApplyLayout(positions)
+		}
+
+		title := exportTitle
+		if title == "" {
+			project := exportProject
+			if project == "" {
+				project = getProjectName(dataPath)
+			}
+			title = project + ": " + query
+		}
+		f, err := os.Create(outPath)
+		if err != nil {
+			return err
+		}
+		if err := export.WriteHTML(f, graph, title); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(status, "Exported %d nodes and %d links to %s\n", len(graph.Nodes), len(graph.Links), outPath)
+		return nil
+	},
+}
+
+// exportGraph runs query against project in dataPath, opened as query opens
+// it, and returns the graph of its bindings, with the query's warnings.
+func exportGraph(dataPath, project, query string) (*export.D3Graph, error) {
+	ctx, cancel := createBaseContext()
+	defer cancel()
+
+	s, ctx, err := openProject(ctx, dataPath, project)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	res, err := gcamdb.QueryWithOptions(ctx, s, query, gcamdb.QueryOptions{Limit: exportLimit})
+	if err != nil {
+		return nil, err
+	}
+	transformer := export.NewD3Transformer(s)
+	transformer.ExcludeTestFiles = exportFilterTests
+	graph, err := transformer.Transform(ctx, query, res.Rows)
+	if err != nil {
+		return nil, fmt.Errorf("export: %w", err)
+	}
+	graph.Warnings = append(graph.Warnings, res.Warnings...)
+	return graph, nil
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportHTMLCmd)
+	exportCmd.Flags().StringVar(&exportQuery, "query", fmt.Sprintf(`triples(?S, "%s", ?O)`, config.PredicateCalls), "Datalog query whose bindings are exported")
+	exportCmd.PersistentFlags().IntVar(&exportLimit, "limit", config.QueryMaxResultLimit, "Maximum rows of the query exported")
+	exportCmd.PersistentFlags().StringVar(&exportProject, "project", "", "Project to export, in its own folder or a shared store under the data folder")
+	exportCmd.PersistentFlags().BoolVar(&exportFilterTests, "filter-tests", false, "Leave out test files")
+	exportHTMLCmd.Flags().StringVar(&exportTitle, "title", "", "Page title (default: the project and query)")
+	exportCmd.Flags().StringVarP(&exportFile, "out-file", "f", "", "Write the graph to this file instead of stdout")
+	addOutputFlag(exportCmd, &exportOutput, outputJSON, outputDOT)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/ingest"
+	"github.com/spf13/cobra"
+)
+
+var importFormat string
+var importProject string
+
+// importFactsCmd represents the import-facts command
+var importFactsCmd = &cobra.Command{
+	Use:   "import-facts <file|-> [data-folder]",
+	Short: "Import facts from external analyzers into the knowledge graph",
+	Long: `Import facts produced outside gca, such as linter findings or runtime
+call traces, into a project's graph as a new ingest version.
+
+The input is NDJSON, one {"subject", "predicate", "object", "graph", "weight",
+"source"} object per line, or CSV with a header row naming the same columns.
+An input with any invalid line is rejected as a whole.
+
+Arguments:
+  file         Path to the facts file, or - for standard input
+  data-folder  Path to the ingested data (default: ./data)`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveDefault, cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inputPath := args[0]
+		dataPath := dataDir
+		if len(args) > 1 {
+			dataPath = args[1]
+		}
+		sourceDir = inputPath
+		dataDir = dataPath
+
+		var in io.Reader = os.Stdin
+		if inputPath != "-" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		format := importFormat
+		if format == "" && strings.EqualFold(filepath.Ext(inputPath), ".csv") {
+			format = ingest.ImportFormatCSV
+		}
+
+		s, err := createStore(false, dataPath)
+		if err != nil {
+			return fmt.Errorf("failed to create MEB store: %w", err)
+		}
+		defer closeStore(s, dataPath)
+
+		projectName := getProjectName(dataPath)
+		if importProject != "" {
+			projectName = importProject
+		}
+		report, err := ingest.ImportFacts(s, projectName, in, format)
+		if err != nil {
+			var ierr *ingest.ImportError
+			if errors.As(err, &ierr) {
+				for _, e := range ierr.Errors {
+					fmt.Fprintf(os.Stderr, "line %d: %s\n", e.Line, e.Message)
+				}
+				if ierr.Truncated {
+					fmt.Fprintln(os.Stderr, "more invalid lines not shown")
+				}
+				return errors.New("import rejected, no facts written")
+			}
+			return err
+		}
+		fmt.Printf("Imported %d facts into %s (%d already present), version %d\n",
+			report.Imported, report.Project, report.Existing, report.Version)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importFactsCmd)
+	importFactsCmd.Flags().StringVar(&importFormat, "format", "", "Input format: ndjson or csv (default: csv for .csv files, else ndjson)")
+	importFactsCmd.Flags().StringVar(&importProject, "project", "", "Project name to import into (default: data folder name)")
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/duynguyendang/gca/pkg/ingest"
+	"github.com/spf13/cobra"
+)
+
+var traceFormat string
+var traceProject string
+
+// importTraceCmd represents the import-trace command
+var importTraceCmd = &cobra.Command{
+	Use:   "import-trace <file|-> [data-folder]",
+	Short: "Import observed calls from pprof profiles or OpenTelemetry traces",
+	Long: `Import calls observed at runtime as weighted actually_calls edges, to
+contrast static call edges with what the program really does.
+
+Accepts Go pprof profiles (e.g. from go test -cpuprofile or net/http/pprof)
+and OpenTelemetry trace exports in OTLP JSON. Spans are matched to symbols
+through their code.* attributes.
+
+Arguments:
+  file         Path to the profile or trace export, or - for standard input
+  data-folder  Path to the ingested data (default: ./data)`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveDefault, cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inputPath := args[0]
+		dataPath := dataDir
+		if len(args) > 1 {
+			dataPath = args[1]
+		}
+		sourceDir = inputPath
+		dataDir = dataPath
+
+		var in io.Reader = os.Stdin
+		if inputPath != "-" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+
+		s, err := createStore(false, dataPath)
+		if err != nil {
+			return fmt.Errorf("failed to create MEB store: %w", err)
+		}
+		defer closeStore(s, dataPath)
+
+		projectName := getProjectName(dataPath)
+		if traceProject != "" {
+			projectName = traceProject
+		}
+		report, err := ingest.ImportTrace(s, projectName, in, traceFormat)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d actually_calls edges from %s into %s (%d new), %d unmatched frames, version %d\n",
+			report.Edges, report.Format, report.Project, report.Imported-report.Existing, report.Unresolved, report.Version)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importTraceCmd)
+	importTraceCmd.Flags().StringVar(&traceFormat, "format", "", "Input format: pprof or otel (default: detected)")
+	importTraceCmd.Flags().StringVar(&traceProject, "project", "", "Project name to import into (default: data folder name)")
+}
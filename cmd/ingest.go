@@ -3,11 +3,19 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"text/tabwriter"
 	"time"
 
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/ingest"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/webhook"
+	"github.com/duynguyendang/meb"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +24,17 @@ var _ context.Context // Explicitly reference context package type
 var incremental bool
 var noEmbed bool
 var reEmbed bool
+var vulnScanner string
+var summarize bool
+var projectFlag string
+var ingestWorkers int
+var ingestJobBuffer int
+var embedWorkers int
+var embedQueue int
+var dryRun bool
+var ingestTenant string
+var ingestOutput string
+var ingestWebhooks string
 
 // ingestCmd represents the ingest command
 var ingestCmd = &cobra.Command{
@@ -24,16 +43,56 @@ var ingestCmd = &cobra.Command{
 	Long: `Parse and ingest source code into the semantic knowledge graph.
 Supports Go, Python, TypeScript, and JavaScript via tree-sitter.
 
+An interrupted ingest (Ctrl-C) saves its progress in the store; running the
+same command again resumes it, skipping files already stored and finishing
+their pending embeddings.
+
+With --dry-run, files are parsed but nothing is written: the command reports
+the files, symbols, facts and embeddings the ingest would produce per language
+and directory, an estimate of the store size, and the files it would skip.
+
+With --tenants and --tenant, the project is ingested into that tenant's data
+root (see serve --tenants), named by --project or the source folder, and the
+ingest is refused when it would take the tenant past its quota.
+
+With --webhooks, the ingest's completion or failure, and any change in the
+number of embeddings, is posted to the subscriptions of the given webhooks
+file (see serve --webhooks) and the file's rules are checked against the new
+graph.
+
+With --output json, the final report (or the dry-run report) is written to
+stdout as JSON and progress goes to stderr.
+
 Arguments:
   source-folder  Path to the source code directory to ingest
   data-folder    Path to store the ingested data (default: ./data)`,
-	Args: cobra.MinimumNArgs(1),
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs, cobra.ShellCompDirectiveFilterDirs),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(ingestOutput, outputText, outputJSON); err != nil {
+			return err
+		}
 		sourcePath := args[0]
 		dataPath := dataDir
 		if len(args) > 1 {
 			dataPath = args[1]
 		}
+		if ingestTenant != "" {
+			if len(args) > 1 {
+				return fmt.Errorf("data-folder cannot be given with --tenant")
+			}
+			if projectFlag == "" {
+				abs, err := filepath.Abs(sourcePath)
+				if err != nil {
+					return err
+				}
+				projectFlag = filepath.Base(abs)
+			}
+			var err error
+			if dataPath, err = tenantDataPath(ingestTenant, projectFlag, !dryRun); err != nil {
+				return err
+			}
+		}
 
 		// Update global for use in createStore
 		sourceDir = sourcePath
@@ -48,59 +107,260 @@ Arguments:
 		opts := &ingest.IngestOptions{
 			SkipEmbeddings: noEmbed,
 			ReEmbed:        reEmbed,
+			VulnScanner:    vulnScanner,
+			Summarize:      summarize,
+			Workers:        ingestWorkers,
+			JobBuffer:      ingestJobBuffer,
+			EmbedWorkers:   embedWorkers,
+			EmbedQueue:     embedQueue,
 		}
 
 		// Create context with signal handling
 		ctx, cancel := createBaseContext()
 		defer cancel()
 
+		projectName := getProjectName(dataPath)
+		if projectFlag != "" {
+			projectName = projectFlag
+		}
+
+		if dryRun {
+			report, err := ingest.DryRun(ctx, projectName, sourcePath, opts)
+			if err != nil {
+				return err
+			}
+			if ingestOutput == outputJSON {
+				return writeJSON(os.Stdout, report)
+			}
+			printDryRun(os.Stdout, report)
+			return nil
+		}
+
 		// Create store in write mode
 		s, err := createStore(false, dataPath)
 		if err != nil {
 			return fmt.Errorf("failed to create MEB store: %w", err)
 		}
-		defer s.Close()
+		defer closeStore(s, dataPath)
+
+		var notify *webhook.Dispatcher
+		if ingestWebhooks != "" {
+			if notify, err = openWebhooks(ingestWebhooks); err != nil {
+				return err
+			}
+			defer closeWebhooks(notify)
+		}
 
 		// Run ingestion
-		projectName := getProjectName(dataPath)
 		errChan := make(chan error, 1)
+		state := ingest.NewIngestState()
+		began, vectorsBefore := time.Now(), s.Vectors().Count()
 
 		go func() {
-			state := ingest.NewIngestState()
 			if incremental {
 				errChan <- ingest.RunIncrementalWithOptions(s, projectName, sourcePath, state, opts)
 			} else {
-				errChan <- ingest.RunWithOptions(s, projectName, sourcePath, state, opts)
+				errChan <- ingest.RunContext(ctx, s, projectName, sourcePath, state, opts)
 			}
 		}()
 
 		select {
 		case <-ctx.Done():
-			fmt.Println("Ingestion interrupted, closing store...")
+			if !incremental {
+				// Let the ingest save its journal so the next run resumes
+				fmt.Fprintln(status, "Ingestion interrupted, saving progress...")
+				<-errChan
+				fmt.Fprintln(status, "Progress saved; run the same command again to resume")
+			} else {
+				fmt.Fprintln(status, "Ingestion interrupted, closing store...")
+			}
 			return ctx.Err()
 		case err := <-errChan:
 			if err != nil {
 				log.Printf("Ingestion failed: %v", err)
+				if notify != nil {
+					notify.Publish(webhook.Event{Type: webhook.EventIngestFailed, Project: projectName, Data: webhook.IngestCompleted{
+						Trigger: "ingest", Facts: s.Count(), DurationMS: time.Since(began).Milliseconds(), Error: err.Error(),
+					}})
+				}
 				return err
 			}
 
-			// Recalculate stats
-			if _, err := s.RecalculateStats(); err != nil {
-				log.Printf("Stats recalc error: %v", err)
+			// The run recounted the facts as it finished
+			if notify != nil {
+				notifyIngest(ctx, notify, s, projectName, began, vectorsBefore)
 			}
 
 			// Allow background goroutines to settle
 			time.Sleep(1 * time.Second)
-			fmt.Println("Ingestion completed successfully")
+			fmt.Fprintln(status, "Ingestion completed successfully")
+			if ingestOutput == outputJSON {
+				return writeJSON(os.Stdout, ingestReport{
+					Project:    projectName,
+					Facts:      s.Count(),
+					Calls:      state.Calls,
+					Enrichment: state.Enrichment,
+				})
+			}
+			if state.Calls != nil {
+				printCallReport(os.Stdout, state.Calls)
+			}
+			if len(state.Enrichment) > 0 {
+				printEnrichReport(os.Stdout, state.Enrichment)
+			}
 		}
 
 		return nil
 	},
 }
 
+// notifyIngest publishes a completed ingest and checks the webhook rules
+// against the project's new graph.
+func notifyIngest(ctx context.Context, d *webhook.Dispatcher, s *meb.MEBStore, project string, began time.Time, vectorsBefore int) {
+	d.Publish(webhook.Event{Type: webhook.EventIngestCompleted, Project: project, Data: webhook.IngestCompleted{
+		Trigger: "ingest", Facts: s.Count(), DurationMS: time.Since(began).Milliseconds(),
+	}})
+	if after := s.Vectors().Count(); after != vectorsBefore {
+		d.Publish(webhook.Event{Type: webhook.EventVectorsRebuilt, Project: project,
+			Data: webhook.VectorsRebuilt{Before: vectorsBefore, After: after}})
+	}
+	ctx = gcamdb.WithScope(ctx, gcamdb.ProjectScope(project))
+	d.CheckRules(ctx, project, func(ctx context.Context, query string) ([]map[string]any, error) {
+		ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+		defer cancel()
+		return gcamdb.Query(ctx, s, query)
+	})
+}
+
+// ingestReport is what ingest --output json writes once the ingest completes.
+type ingestReport struct {
+	Project    string                `json:"project"`
+	Facts      uint64                `json:"facts"` // In the store, after the ingest
+	Calls      *ingest.CallReport    `json:"calls,omitempty"`
+	Enrichment []ingest.EnrichResult `json:"enrichment,omitempty"`
+}
+
+// printCallReport writes the outcome of call resolution and the callees most
+// often left unresolved.
+func printCallReport(w io.Writer, report *ingest.CallReport) {
+	fmt.Fprintf(w, "Calls resolved after parsing: %d of %d (%d ambiguous, %d external)\n",
+		report.Resolved, report.Pending, report.Ambiguous, report.External)
+	if len(report.Unresolved) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "unresolved callee\tcalls\treason\t")
+	for _, u := range report.Unresolved {
+		reason := "external"
+		if u.Ambiguous {
+			reason = "ambiguous"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t\n", u.Callee, u.Count, reason)
+	}
+	tw.Flush()
+}
+
+// printEnrichReport writes how long each enricher took, the facts it added
+// and whether it failed.
+func printEnrichReport(w io.Writer, results []ingest.EnrichResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "enricher\ttime\tfacts\tstatus\t")
+	for _, r := range results {
+		status := "ok"
+		if r.Error != "" {
+			status = "failed: " + r.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%+d\t%s\t\n", r.Name, r.Duration.Round(time.Millisecond), r.Facts, status)
+	}
+	tw.Flush()
+}
+
+// printDryRun writes a dry-run report as aligned tables.
+func printDryRun(w io.Writer, report *ingest.DryRunReport) {
+	table := func(title string, rows []ingest.DryRunCounts) {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "%s\tfiles\tsymbols\tfacts\tembeddings\tsource\test. size\t\n", title)
+		for _, r := range append(rows, report.Total) {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\t%s\t\n", r.Name, r.Files, r.Symbols, r.Facts, r.Embeddings,
+				formatBytes(r.Bytes), formatBytes(r.EstimatedBytes()))
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "Dry run for project %q (nothing was written)\n\n", report.Project)
+	table("language", report.Languages)
+	table("directory", report.Directories)
+
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(w, "Skipped %d files and directories:\n", len(report.Skipped))
+		for _, sk := range report.Skipped {
+			fmt.Fprintf(w, "  %s: %s\n", sk.Path, sk.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Estimated store size: %s\n", formatBytes(report.Total.EstimatedBytes()))
+}
+
+// tenantDataPath returns the store path of project in the data root of the
+// tenant id listed in --tenants, checking the tenant's quota first when check is set.
+func tenantDataPath(id, project string, check bool) (string, error) {
+	if tenantsFile == "" {
+		return "", fmt.Errorf("--tenant requires --tenants")
+	}
+	tenants, err := manager.LoadTenants(tenantsFile)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tenants {
+		if t.ID != id {
+			continue
+		}
+		if check {
+			sm := manager.NewTenantStoreManager(t, getMemoryProfile(), false)
+			err := sm.CheckNewProject(project)
+			sm.CloseAll()
+			if err != nil {
+				return "", fmt.Errorf("tenant %s: %w", id, err)
+			}
+		}
+		if t.SharedStore {
+			return t.DataDir, nil
+		}
+		return filepath.Join(t.DataDir, project), nil
+	}
+	return "", fmt.Errorf("tenant %s is not listed in %s", id, tenantsFile)
+}
+
+// formatBytes renders a byte count with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 func init() {
 	rootCmd.AddCommand(ingestCmd)
 	ingestCmd.Flags().BoolVarP(&incremental, "incremental", "i", false, "Enable incremental ingestion (only process changed files)")
 	ingestCmd.Flags().BoolVarP(&noEmbed, "no-embed", "e", false, "Skip embedding generation during ingestion")
 	ingestCmd.Flags().BoolVar(&reEmbed, "re-embed", false, "Regenerate embeddings for all symbols from source code")
+	ingestCmd.Flags().StringVar(&vulnScanner, "vuln", "", "Annotate vulnerabilities using a scanner (govulncheck, osv)")
+	ingestCmd.Flags().BoolVar(&summarize, "summarize", false, "Generate one-line LLM summaries of exported symbols")
+	ingestCmd.Flags().StringVar(&projectFlag, "project", "", "Project name to ingest under (default: data folder name); use with a shared store")
+	ingestCmd.Flags().IntVar(&ingestWorkers, "workers", 0, fmt.Sprintf("Files parsed concurrently (default: one per CPU, up to %d)", config.MaxWorkers))
+	ingestCmd.Flags().IntVar(&ingestJobBuffer, "job-buffer", 0, fmt.Sprintf("Files queued ahead of the parse workers (default %d)", config.IngestJobBuffer))
+	ingestCmd.Flags().IntVar(&embedWorkers, "embed-workers", 0, fmt.Sprintf("Concurrent embedding requests (default %d)", config.EmbeddingWorkers))
+	ingestCmd.Flags().StringVar(&tenantsFile, "tenants", "", "Tenants file (see serve --tenants) naming the data root of --tenant")
+	ingestCmd.Flags().StringVar(&ingestTenant, "tenant", "", "Ingest into this tenant's data root, within its quota")
+	ingestCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Parse and report what would be ingested, and the estimated store size, without writing")
+	ingestCmd.Flags().IntVar(&embedQueue, "embed-queue", 0, fmt.Sprintf("Symbols queued for embedding before parsing waits (default %d)", config.EmbeddingQueueSize))
+	ingestCmd.Flags().StringVar(&ingestWebhooks, "webhooks", "", "Post the ingest's completion to the webhooks in this YAML file (see serve --webhooks)")
+	addOutputFlag(ingestCmd, &ingestOutput, outputText, outputJSON)
 }
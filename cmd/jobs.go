@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobsServer  string
+	jobsAPIKey  string
+	jobsKind    string
+	jobsProject string
+	jobsState   string
+	jobsWait    bool
+	jobsOutput  string
+)
+
+// jobsCmd represents the jobs command
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "List, follow and cancel a server's background jobs",
+	Long: `Track the long-running work of a server: scheduled ingests, and the
+clustering, batch summaries and dictionary compactions started with
+?async=true. A server keeps its running jobs and its last finished ones.`,
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List jobs, newest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(jobsOutput, outputText, outputJSON); err != nil {
+			return err
+		}
+		list, err := jobsClient().Jobs(cmd.Context(), client.JobFilter{Kind: jobsKind, Project: jobsProject, State: jobsState})
+		if err != nil {
+			return err
+		}
+		if jobsOutput == outputJSON {
+			return writeJSON(os.Stdout, list)
+		}
+		for _, j := range list {
+			fmt.Printf("%s  %-8s %-10s %-12s %s\n", j.ID, j.Kind, j.Project, j.State, jobProgress(&j))
+		}
+		return nil
+	},
+}
+
+var jobsShowCmd = &cobra.Command{
+	Use:   "show <job-id>",
+	Short: "Show a job with its log, waiting for it to finish with --wait",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(jobsOutput, outputText, outputJSON); err != nil {
+			return err
+		}
+		c := jobsClient()
+		var job *client.Job
+		var err error
+		if jobsWait {
+			job, err = c.WaitJob(cmd.Context(), args[0], time.Second)
+		} else {
+			job, err = c.Job(cmd.Context(), args[0])
+		}
+		if err != nil {
+			return err
+		}
+		if jobsOutput == outputJSON {
+			return writeJSON(os.Stdout, job)
+		}
+		fmt.Printf("%s %s on %s: %s %s\n", job.Kind, job.ID, job.Project, job.State, jobProgress(job))
+		for _, line := range job.Logs {
+			fmt.Println("  " + line)
+		}
+		if job.Error != "" {
+			fmt.Println("Error: " + job.Error)
+		}
+		return nil
+	},
+}
+
+var jobsCancelCmd = &cobra.Command{
+	Use:   "cancel <job-id>",
+	Short: "Cancel a running job",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := jobsClient().CancelJob(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cancelling %s %s\n", job.Kind, job.ID)
+		return nil
+	},
+}
+
+// jobsClient returns a client of the --server.
+func jobsClient() *client.Client {
+	apiKey := jobsAPIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("GCA_API_KEY")
+	}
+	return client.New(jobsServer, client.WithAPIKey(apiKey))
+}
+
+// jobProgress describes how far a job has come.
+func jobProgress(j *client.Job) string {
+	p := j.Progress
+	switch {
+	case p.Total > 0:
+		return fmt.Sprintf("%s %d/%d", p.Stage, p.Done, p.Total)
+	case j.Running():
+		return p.Stage
+	}
+	return j.Finished.Sub(j.Started).Round(time.Millisecond).String()
+}
+
+func init() {
+	rootCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsListCmd, jobsShowCmd, jobsCancelCmd)
+	jobsCmd.PersistentFlags().StringVar(&jobsServer, "server", "http://localhost:8080", "URL of the server")
+	jobsCmd.PersistentFlags().StringVar(&jobsAPIKey, "api-key", "", "API key sent as X-API-Key (or set GCA_API_KEY)")
+	jobsListCmd.Flags().StringVar(&jobsKind, "kind", "", "Only jobs of this kind: ingest, cluster, summary or compact")
+	jobsListCmd.Flags().StringVar(&jobsProject, "project", "", "Only jobs on this project")
+	jobsListCmd.Flags().StringVar(&jobsState, "state", "", "Only jobs in this state: running, succeeded, failed or cancelled")
+	jobsShowCmd.Flags().BoolVar(&jobsWait, "wait", false, "Wait for the job to finish")
+	addOutputFlag(jobsListCmd, &jobsOutput, outputText, outputJSON)
+	addOutputFlag(jobsShowCmd, &jobsOutput, outputText, outputJSON)
+}
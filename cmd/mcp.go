@@ -33,7 +33,8 @@ Tools exposed:
 
 Arguments:
   data-folder  Path to the data directory (default: ./data)`,
-	Args: cobra.MaximumNArgs(1),
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		dataPath := dataDir
 		if len(args) > 0 {
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/spf13/cobra"
+)
+
+var migrateDryRun bool
+var migrateOutput string
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [data-folder]",
+	Short: "Upgrade a data folder written by an older gca to the current store format",
+	Long: `Run the format migrations a store still needs, in order, so that data
+folders survive changes to key layouts, index documents and registries.
+
+Every store records its format version. Commands and servers opening a store
+for writing migrate it on open; read-only ones only warn, and refuse stores
+written by a newer gca. Each migration is recorded as it completes, so an
+interrupted upgrade resumes where it stopped.
+
+Arguments:
+  data-folder  Path to the ingested data (default: ./data)`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(migrateOutput, outputText, outputJSON); err != nil {
+			return err
+		}
+		dataPath := dataDir
+		if len(args) > 0 {
+			dataPath = args[0]
+		}
+		dataDir = dataPath
+
+		s, err := createStore(migrateDryRun, dataPath)
+		if err != nil {
+			return fmt.Errorf("failed to open MEB store: %w", err)
+		}
+		if migrateDryRun {
+			defer s.Close()
+		} else {
+			defer closeStore(s, dataPath)
+		}
+
+		format, err := gcamdb.StoreFormat(s)
+		if err != nil {
+			return err
+		}
+		pending, err := gcamdb.PendingMigrations(s)
+		if err != nil {
+			return err
+		}
+		if migrateOutput == outputJSON {
+			type migration struct {
+				Version int    `json:"version"`
+				Name    string `json:"name"`
+			}
+			out := struct {
+				gcamdb.Format
+				Current int         `json:"current"`
+				Pending []migration `json:"pending"`
+			}{Format: format, Current: gcamdb.CurrentFormat, Pending: []migration{}}
+			for _, m := range pending {
+				out.Pending = append(out.Pending, migration{m.Version, m.Name})
+			}
+			return writeJSON(os.Stdout, out)
+		}
+
+		fmt.Printf("Store format %d (this gca writes %d)\n", format.Version, gcamdb.CurrentFormat)
+		for _, m := range pending {
+			fmt.Printf("  pending %d %s\n", m.Version, m.Name)
+		}
+		for _, m := range format.History {
+			fmt.Printf("  applied %d %s at %s in %s\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"), m.Took)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "List the pending migrations without running them")
+	addOutputFlag(migrateCmd, &migrateOutput, outputText, outputJSON)
+}
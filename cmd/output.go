@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Formats of --output, which commands printing results take for scripting.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// status is where commands print their progress. Commands writing JSON send it
+// to stderr, so that stdout holds only the JSON.
+var status io.Writer = os.Stdout
+
+// addOutputFlag adds --output (-o) to cmd, choosing one of formats, the first
+// the default, with shell completion of the formats.
+func addOutputFlag(cmd *cobra.Command, p *string, formats ...string) {
+	cmd.Flags().StringVarP(p, "output", "o", formats[0], "Output format: "+strings.Join(formats, ", "))
+	cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(formats, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// acceptFormatFlag makes cmd take --format as another name for --output, the
+// spelling of scripts written against other tools.
+func acceptFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "format" {
+			name = "output"
+		}
+		return pflag.NormalizedName(name)
+	})
+}
+
+// checkOutput returns an error unless format is one of formats, and sends
+// progress to stderr when it is JSON.
+func checkOutput(format string, formats ...string) error {
+	if !slices.Contains(formats, format) {
+		return fmt.Errorf("unknown output format %q (want %s)", format, strings.Join(formats, ", "))
+	}
+	if format == outputJSON {
+		status = os.Stderr
+	}
+	return nil
+}
+
+// writeJSON writes v to w as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// completeArgs completes the i-th positional argument as kinds[i] says:
+// cobra.ShellCompDirectiveFilterDirs for folders, cobra.ShellCompDirectiveDefault
+// for files and cobra.ShellCompDirectiveNoFileComp for anything else. Arguments
+// past the last kind are not completed.
+func completeArgs(kinds ...cobra.ShellCompDirective) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if len(args) < len(kinds) {
+			return nil, kinds[len(args)]
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/spf13/cobra"
+)
+
+// Values of --expect.
+const (
+	expectPath = "path"
+	expectNone = "none"
+)
+
+var (
+	pathFrom       string
+	pathTo         string
+	pathProject    string
+	pathPredicates []string
+	pathDirection  string
+	pathMaxDepth   int
+	pathExpect     string
+	pathOutput     string
+)
+
+// pathCmd represents the path command
+var pathCmd = &cobra.Command{
+	Use:   "path --from <symbol> --to <symbol> [data-folder]",
+	Short: "Find the shortest path between two symbols, as /api/v1/graph/path does",
+	Long: `Find the shortest weighted path between two symbols or files, the search the
+server's /api/v1/graph/path runs, and print it one edge per line, or with
+--output json as the graph the server returns, or with --output dot as
+Graphviz DOT. --format is accepted for --output.
+
+--predicates, --direction and --max-depth restrict the edges followed, as the
+route's query parameters do. With --expect the command exits non-zero unless
+a path is found (path) or none is (none), for architecture checks in CI.
+
+Examples:
+  gca path --from main.go:main --to db/store.go:Save ./data
+  gca path --project backend --from api/handler.go --to internal/db/conn.go \
+      --predicates imports --expect none
+
+Arguments:
+  data-folder  Path to the data directory (default: ./data)`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(pathOutput, outputText, outputJSON, outputDOT); err != nil {
+			return err
+		}
+		switch pathExpect {
+		case "", expectPath, expectNone:
+		default:
+			return fmt.Errorf("unknown --expect %q (want %s or %s)", pathExpect, expectPath, expectNone)
+		}
+		status = os.Stderr      // The path takes stdout
+		cmd.SilenceUsage = true // Failures past here are results, not misuse
+		dataPath := dataDir
+		if len(args) > 0 {
+			dataPath = args[0]
+		}
+
+		ctx, cancel := createBaseContext()
+		defer cancel()
+
+		root, project := dataPath, pathProject
+		newManager := manager.NewStoreManager
+		if sharedStore {
+			newManager = manager.NewSharedStoreManager
+		} else if isStore(dataPath) {
+			// A store folder is a project's own, searched from the data root
+			// holding it, or a shared store holding --project, as query reads it
+			abs, err := filepath.Abs(dataPath)
+			if err != nil {
+				return err
+			}
+			if project == "" || project == getProjectName(abs) {
+				root, project = filepath.Dir(abs), getProjectName(abs)
+			} else {
+				newManager = manager.NewSharedStoreManager
+			}
+		}
+		mgr := newManager(root, getMemoryProfile(), true)
+		defer mgr.CloseAll()
+		project, err := onlyProject(mgr, dataPath, project)
+		if err != nil {
+			return err
+		}
+
+		svc := service.NewGraphService(mgr)
+		graph, err := svc.FindShortestPathWithOptions(ctx, project, pathFrom, pathTo, service.PathOptions{
+			Predicates: pathPredicates,
+			Direction:  pathDirection,
+			MaxDepth:   pathMaxDepth,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch pathOutput {
+		case outputJSON:
+			err = writeJSON(os.Stdout, graph)
+		case outputDOT:
+			err = export.WriteDOT(os.Stdout, graph)
+		default:
+			printPath(os.Stdout, graph)
+		}
+		if err != nil {
+			return err
+		}
+
+		found := len(graph.Links) > 0
+		if !found {
+			fmt.Fprintf(os.Stderr, "No path from %s to %s\n", pathFrom, pathTo)
+		}
+		if pathExpect == expectPath && !found {
+			return fmt.Errorf("expected a path from %s to %s", pathFrom, pathTo)
+		}
+		if pathExpect == expectNone && found {
+			return fmt.Errorf("expected no path from %s to %s, found a %d-edge path", pathFrom, pathTo, len(graph.Links))
+		}
+		return nil
+	},
+}
+
+// onlyProject returns project, or when it is empty the one project in the
+// data folder.
+func onlyProject(mgr *manager.StoreManager, dataPath, project string) (string, error) {
+	if project != "" {
+		return project, nil
+	}
+	projects, err := mgr.ListProjects()
+	if err != nil {
+		return "", err
+	}
+	switch len(projects) {
+	case 0:
+		return "", fmt.Errorf("no projects in %s", dataPath)
+	case 1:
+		return projects[0].ID, nil
+	}
+	ids := make([]string, len(projects))
+	for i, p := range projects {
+		ids[i] = p.ID
+	}
+	return "", fmt.Errorf("%s holds several projects, pick one with --project: %s", dataPath, strings.Join(ids, ", "))
+}
+
+// printPath writes the path's links one per line, in path order, each in the
+// direction of its fact.
+func printPath(w io.Writer, graph *export.D3Graph) {
+	for _, l := range graph.Links {
+		fmt.Fprintf(w, "%s -[%s]-> %s\n", l.Source, l.Relation, l.Target)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(pathCmd)
+	pathCmd.Flags().StringVar(&pathFrom, "from", "", "Symbol or file the path starts at")
+	pathCmd.Flags().StringVar(&pathTo, "to", "", "Symbol or file the path ends at")
+	pathCmd.Flags().StringVar(&pathProject, "project", "", "Project searched (default: the data folder's only project)")
+	pathCmd.Flags().StringSliceVar(&pathPredicates, "predicates", nil, "Predicates followed, comma-separated (default: all)")
+	pathCmd.Flags().StringVar(&pathDirection, "direction", "", "Edges followed: forward, reverse or undirected (default: forward)")
+	pathCmd.Flags().IntVar(&pathMaxDepth, "max-depth", 0, "Maximum edges in the path (default: the server's limit)")
+	pathCmd.Flags().StringVar(&pathExpect, "expect", "", "Exit non-zero unless a path is found (path) or none is (none)")
+	pathCmd.Flags().BoolVar(&sharedStore, "shared-store", false, "The data folder is one store holding several projects")
+	pathCmd.MarkFlagRequired("from")
+	pathCmd.MarkFlagRequired("to")
+	pathCmd.RegisterFlagCompletionFunc("direction", cobra.FixedCompletions(
+		[]string{service.PathForward, service.PathReverse, service.PathUndirected}, cobra.ShellCompDirectiveNoFileComp))
+	pathCmd.RegisterFlagCompletionFunc("expect", cobra.FixedCompletions(
+		[]string{expectPath, expectNone}, cobra.ShellCompDirectiveNoFileComp))
+	addOutputFlag(pathCmd, &pathOutput, outputText, outputJSON, outputDOT)
+	acceptFormatFlag(pathCmd)
+}
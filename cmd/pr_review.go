@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/ingest"
+	"github.com/duynguyendang/gca/pkg/review"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/spf13/cobra"
+)
+
+// outputMarkdown is the PR comment format of pr-review.
+const outputMarkdown = "markdown"
+
+var (
+	reviewBase        string
+	reviewHead        string
+	reviewRepo        string
+	reviewProject     string
+	reviewIncremental bool
+	reviewFile        string
+	reviewOutput      string
+)
+
+// prReviewCmd represents the pr-review command
+var prReviewCmd = &cobra.Command{
+	Use:   "pr-review --base <sha> [--head <sha>]",
+	Short: "Summarize the architectural impact of a change as a PR comment",
+	Long: `Ingest two revisions of a git repository and summarize what the change does
+to its architecture: the package dependencies it adds or removes, the imports
+new to the project, the callers left calling symbols it removed, and the entry
+points (mains, HTTP handlers, exported API) that reach the code it changed.
+
+Each revision is read with git archive and ingested without embeddings into a
+temporary store. With --incremental the base is ingested once and head only
+re-ingests the files that differ, which is faster on large repositories but
+may miss calls into changed files from unchanged ones.
+
+The summary is Markdown meant to be posted as a PR comment, opening with a
+marker comment a bot can find to update its earlier comment; --output json
+writes the report as JSON instead. Progress goes to stderr.
+
+Examples:
+  gca pr-review --base origin/main --head HEAD > impact.md
+  gh pr comment "$PR" --body-file <(gca pr-review --base "$BASE_SHA" --head "$HEAD_SHA")`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(reviewOutput, outputMarkdown, outputJSON); err != nil {
+			return err
+		}
+		status = os.Stderr // The summary takes stdout
+		cmd.SilenceUsage = true
+
+		ctx, cancel := createBaseContext()
+		defer cancel()
+
+		repo, err := filepath.Abs(reviewRepo)
+		if err != nil {
+			return err
+		}
+		project := reviewProject
+		if project == "" {
+			project = filepath.Base(repo)
+		}
+		base, err := revParse(ctx, repo, reviewBase)
+		if err != nil {
+			return err
+		}
+		head, err := revParse(ctx, repo, reviewHead)
+		if err != nil {
+			return err
+		}
+
+		diff, err := git(ctx, repo, "diff", "-U0", "--no-color", "--no-ext-diff", "-M", base, head)
+		if err != nil {
+			return err
+		}
+		lines, err := review.ParseDiff(bytes.NewReader(diff))
+		if err != nil {
+			return fmt.Errorf("parse diff: %w", err)
+		}
+		changed := make(map[string][]review.LineRange, len(lines))
+		for file, ranges := range lines {
+			changed[project+"/"+file] = ranges
+		}
+
+		work, err := os.MkdirTemp("", "gca-pr-review-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(work)
+
+		baseGraph, headGraph, err := reviewGraphs(ctx, repo, work, project, base, head)
+		if err != nil {
+			return err
+		}
+
+		report := review.Compare(baseGraph, headGraph, changed)
+		report.Base, report.Head = base, head
+
+		out := io.Writer(os.Stdout)
+		if reviewFile != "" {
+			f, err := os.Create(reviewFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		if reviewOutput == outputJSON {
+			return writeJSON(out, report)
+		}
+		return report.WriteMarkdown(out)
+	},
+}
+
+// reviewGraphs ingests the base and head revisions and loads their graphs,
+// into one store with --incremental or into a store each.
+func reviewGraphs(ctx context.Context, repo, work, project, base, head string) (*review.Graph, *review.Graph, error) {
+	baseSrc, headSrc := filepath.Join(work, "base"), filepath.Join(work, "head")
+	for _, rev := range []struct{ sha, dir string }{{base, baseSrc}, {head, headSrc}} {
+		if err := checkout(ctx, repo, rev.sha, rev.dir); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(work, "base.db")))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer s.Close()
+	fmt.Fprintf(status, "Ingesting %s...\n", short(base))
+	if err := ingest.RunContext(ctx, s, project, baseSrc, ingest.NewIngestState(), &ingest.IngestOptions{SkipEmbeddings: true}); err != nil {
+		return nil, nil, fmt.Errorf("ingest %s: %w", short(base), err)
+	}
+	baseGraph, err := review.Load(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Fprintf(status, "Ingesting %s...\n", short(head))
+	if reviewIncremental {
+		// Paths are relative to the source folder, so head's files replace
+		// base's wherever their hashes differ
+		err = ingest.RunIncrementalWithOptions(s, project, headSrc, ingest.NewIngestState(), &ingest.IngestOptions{SkipEmbeddings: true})
+	} else {
+		var hs *meb.MEBStore
+		if hs, err = meb.NewMEBStore(store.DefaultConfig(filepath.Join(work, "head.db"))); err != nil {
+			return nil, nil, err
+		}
+		defer hs.Close()
+		s = hs
+		err = ingest.RunContext(ctx, s, project, headSrc, ingest.NewIngestState(), &ingest.IngestOptions{SkipEmbeddings: true})
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("ingest %s: %w", short(head), err)
+	}
+	headGraph, err := review.Load(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return baseGraph, headGraph, nil
+}
+
+// checkout writes the tree of rev in repo to dir, as git archive exports it.
+func checkout(ctx context.Context, repo, rev, dir string) error {
+	archive, err := git(ctx, repo, "archive", "--format=tar", rev)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive of %s: %w", short(rev), err)
+		}
+		name := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(name, dir+string(filepath.Separator)) {
+			continue // Outside dir, which git never writes
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(name, 0o755)
+		case tar.TypeReg:
+			err = writeArchived(name, tr, hdr.FileInfo().Mode())
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeArchived(name string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// revParse resolves a revision to its commit hash.
+func revParse(ctx context.Context, repo, rev string) (string, error) {
+	out, err := git(ctx, repo, "rev-parse", "--verify", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", rev, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// git runs a git command in repo and returns its stdout, or an error holding
+// its stderr.
+func git(ctx context.Context, repo string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repo}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// short abbreviates a commit hash for progress lines.
+func short(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+func init() {
+	rootCmd.AddCommand(prReviewCmd)
+	prReviewCmd.Flags().StringVar(&reviewBase, "base", "", "Revision the change starts from")
+	prReviewCmd.Flags().StringVar(&reviewHead, "head", "HEAD", "Revision the change ends at")
+	prReviewCmd.Flags().StringVar(&reviewRepo, "repo", ".", "Git repository reviewed")
+	prReviewCmd.Flags().StringVar(&reviewProject, "project", "", "Project name the symbols are ingested under (default: the repository folder's name)")
+	prReviewCmd.Flags().BoolVar(&reviewIncremental, "incremental", false, "Ingest head over base, re-ingesting only the files that differ")
+	prReviewCmd.Flags().StringVarP(&reviewFile, "out-file", "f", "", "Write the summary to this file instead of stdout")
+	prReviewCmd.MarkFlagRequired("base")
+	addOutputFlag(prReviewCmd, &reviewOutput, outputMarkdown, outputJSON)
+	acceptFormatFlag(prReviewCmd)
+}
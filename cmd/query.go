@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/datalog"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/spf13/cobra"
+)
+
+const outputCSV = "csv"
+
+var (
+	queryLimit   int
+	queryTimeout time.Duration
+	queryOutput  string
+	queryProject string
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query <datalog|-> [data-folder]",
+	Short: "Run a Datalog query and print the rows it binds",
+	Long: `Run one Datalog query against an ingested store, read-only, and print its
+rows, one per line with a column per variable, as CSV with --output csv, or
+with --output json as the rows the server's /api/v1/query returns. Pass - to
+read the query from stdin. --format is accepted for --output.
+
+--project picks a project under the data folder: its own store in
+<data-folder>/<project>, or its part of a shared store in the data folder.
+The command exits non-zero when the query fails, so scripts can rely on it.
+
+Examples:
+  gca query 'triples(?F, "defines", ?S)' ./data/my-project
+  gca query 'triples(?A, "calls", ?B)' -o json | jq '.results[].B'
+  gca query --project backend --data ./data --format csv 'triples(?S, "calls", ?O)'
+
+Arguments:
+  datalog      The query, or - to read it from stdin
+  data-folder  Path to the data directory (default: ./data)`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveNoFileComp, cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(queryOutput, outputText, outputJSON, outputCSV); err != nil {
+			return err
+		}
+		status = os.Stderr // The rows take stdout
+		query := args[0]
+		if query == "-" {
+			b, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("read query: %w", err)
+			}
+			query = string(b)
+		}
+		dataPath := dataDir
+		if len(args) > 1 {
+			dataPath = args[1]
+		}
+
+		ctx, cancel := createBaseContext()
+		defer cancel()
+
+		s, ctx, err := openProject(ctx, dataPath, queryProject)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		query = strings.TrimSpace(query)
+		res, err := gcamdb.QueryWithOptions(ctx, s, query, gcamdb.QueryOptions{Limit: queryLimit, Timeout: queryTimeout})
+		if err != nil {
+			return err
+		}
+		if res.Rows == nil {
+			res.Rows = []map[string]any{}
+		}
+		if queryOutput == outputJSON {
+			return writeJSON(os.Stdout, res)
+		}
+		for _, w := range res.Warnings {
+			fmt.Fprintln(os.Stderr, "Warning:", w)
+		}
+		columns := queryColumns(query, res.Rows)
+		if queryOutput == outputCSV {
+			if err := writeCSV(os.Stdout, columns, res.Rows); err != nil {
+				return err
+			}
+		} else {
+			printRows(os.Stdout, columns, res.Rows)
+		}
+		// Counts go to stderr, leaving stdout the table
+		fmt.Fprintf(os.Stderr, "%d rows\n", len(res.Rows))
+		return nil
+	},
+}
+
+// openProject opens the store holding project read-only. A project with its
+// own folder under dataPath is opened there; otherwise dataPath must be a
+// shared store the project was ingested into, and the returned context is
+// scoped to it. An empty project opens dataPath as the store of the project
+// named by its folder, as ingest names it, and fails when it holds no store.
+func openProject(ctx context.Context, dataPath, project string) (*meb.MEBStore, context.Context, error) {
+	if project == "" {
+		if !isStore(dataPath) {
+			return nil, nil, fmt.Errorf("%s holds no store: pass a project's folder, or pick a project with --project", dataPath)
+		}
+		s, err := openProjectStore(dataPath, getProjectName(dataPath))
+		return s, ctx, err
+	}
+	if info, err := os.Stat(filepath.Join(dataPath, project)); err == nil && info.IsDir() {
+		s, err := openProjectStore(filepath.Join(dataPath, project), project)
+		return s, ctx, err
+	}
+	// Opening a folder of per-project stores would create an empty store in it
+	if !isStore(dataPath) {
+		return nil, nil, fmt.Errorf("project not found: %s (no %s folder and %s is not a shared store)", project, filepath.Join(dataPath, project), dataPath)
+	}
+
+	s, err := createStore(true, dataPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create MEB store: %w", err)
+	}
+	projects, err := gcamdb.RegisteredProjects(s)
+	if err != nil {
+		s.Close()
+		return nil, nil, err
+	}
+	if i := sort.SearchStrings(projects, project); i == len(projects) || projects[i] != project {
+		s.Close()
+		return nil, nil, fmt.Errorf("project not found: %s", project)
+	}
+	return s, gcamdb.WithScope(ctx, gcamdb.ProjectScope(project)), nil
+}
+
+// isStore reports whether dir holds a store, rather than a folder of them.
+func isStore(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "badger"))
+	return err == nil && info.IsDir()
+}
+
+// openProjectStore opens the store of project in dir read-only, reading in
+// the project's topic as the server does.
+func openProjectStore(dir, project string) (*meb.MEBStore, error) {
+	s, err := createStore(true, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MEB store: %w", err)
+	}
+	s.SetTopicID(gcamdb.TopicForProject(project))
+	return s, nil
+}
+
+// queryColumns returns the columns of query's rows: its variables in the order
+// they first appear in its triples atoms, then any other keys rows bind, in
+// name order. A capitalised argument is only a variable when rows bind it,
+// since quoted constants are parsed without their quotes.
+func queryColumns(query string, rows []map[string]any) []string {
+	bound := make(map[string]bool)
+	for _, row := range rows {
+		for col := range row {
+			bound[col] = true
+		}
+	}
+	seen := make(map[string]bool)
+	var columns []string
+	atoms, _ := datalog.Parse(query)
+	for _, atom := range atoms {
+		if atom.Predicate != "triples" {
+			continue
+		}
+		for _, arg := range atom.Args {
+			if seen[arg] || !(strings.HasPrefix(arg, "?") || bound[arg]) {
+				continue
+			}
+			seen[arg] = true
+			columns = append(columns, arg)
+		}
+	}
+	var rest []string
+	for col := range bound {
+		if !seen[col] {
+			rest = append(rest, col)
+		}
+	}
+	sort.Strings(rest)
+	return append(columns, rest...)
+}
+
+// printRows writes rows as an aligned table under a header of columns.
+func printRows(w io.Writer, columns []string, rows []map[string]any) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t")+"\t")
+	for _, row := range rows {
+		for _, col := range columns {
+			if v, ok := row[col]; ok {
+				fmt.Fprintf(tw, "%v", v)
+			}
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// writeCSV writes rows as CSV under a header of columns. Variables a row
+// leaves unbound are empty.
+func writeCSV(w io.Writer, columns []string, rows []map[string]any) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, col := range columns {
+			record[i] = ""
+			if v, ok := row[col]; ok {
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.Flags().IntVar(&queryLimit, "limit", 0, "Maximum rows returned (default: the server's query limit)")
+	queryCmd.Flags().DurationVar(&queryTimeout, "timeout", 0, "Query deadline, such as 30s (default: the server's query timeout)")
+	queryCmd.Flags().StringVar(&queryProject, "project", "", "Project to query, in its own folder or a shared store under the data folder")
+	addOutputFlag(queryCmd, &queryOutput, outputText, outputJSON, outputCSV)
+	acceptFormatFlag(queryCmd)
+}
@@ -24,12 +24,14 @@ Commands available in REPL:
   - Datalog queries: triples(?A, "calls", ?B)
   - Natural language: Who calls the panic function?
   - Source view: show main.go:main
+  - Saved queries: \saved lists them, \saved callers-of symbol=main.go:main runs one
   - Schema: .schema
   - Exit: .exit
 
 Arguments:
   data-folder  Path to the data directory (default: ./data)`,
-	Args: cobra.MaximumNArgs(1),
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		dataPath := dataDir
 		if len(args) > 0 {
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/replica"
+	"github.com/duynguyendang/gca/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var replicaPrimary string
+var replicaAPIKey string
+var replicaProjects []string
+var replicaInterval time.Duration
+
+// replicaCmd represents the replica command
+var replicaCmd = &cobra.Command{
+	Use:   "replica --pull <primary-url>",
+	Short: "Serve a read replica of a primary server's projects",
+	Long: `Start a read-only REST API server whose projects are copied from a primary
+server. The replica downloads a snapshot bundle of each project into the data
+folder, serves it, and polls the primary for newer snapshots; unchanged
+projects cost one request per poll.
+
+Replicas keep no state of their own beyond the downloaded snapshots, so more of
+them can be started behind a load balancer to scale query traffic. For a
+multi-tenant primary, pull from its /t/<tenant> URL with one of the tenant's
+API keys.
+
+Each replica keeps an audit log of the requests it serves, as the server does;
+disable it with --audit=false.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if replicaPrimary == "" {
+			return fmt.Errorf("--pull is required")
+		}
+		if err := os.MkdirAll(dataDir, 0o755); err != nil {
+			return fmt.Errorf("create data folder: %w", err)
+		}
+		fmt.Printf("Starting read replica of %s. Data: %s\n", replicaPrimary, dataDir)
+
+		mgr := manager.NewStoreManager(dataDir, getMemoryProfile(), true)
+		defer mgr.CloseAll()
+		srv := server.NewServer(mgr, "")
+		if auditQueries {
+			if auditLog := openAuditLog(); auditLog != nil {
+				defer auditLog.Close()
+				srv.AuditTo(auditLog)
+			}
+		}
+
+		apiKey := replicaAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("GCA_REPLICA_API_KEY")
+		}
+		puller := replica.NewPuller(replicaPrimary, apiKey, replicaProjects, mgr)
+		puller.SetInterval(replicaInterval)
+
+		pullCtx, stopPull := context.WithCancel(context.Background())
+		defer stopPull()
+		pullDone := make(chan struct{})
+		go func() {
+			defer close(pullDone)
+			puller.Run(pullCtx)
+		}()
+
+		httpSrv := &http.Server{
+			Addr:    ":" + port,
+			Handler: srv.Handler(),
+		}
+		errChan := make(chan error, 1)
+		go func() {
+			if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errChan <- fmt.Errorf("listen error: %w", err)
+			}
+		}()
+
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		select {
+		case <-quit:
+			log.Println("Shutting down replica...")
+		case err := <-errChan:
+			log.Printf("Server error: %v", err)
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpSrv.Shutdown(ctx); err != nil {
+			log.Fatal("Server forced to shutdown: ", err)
+		}
+		// Let a running pull finish installing before the stores close
+		stopPull()
+		<-pullDone
+
+		log.Println("Replica exiting")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replicaCmd)
+	replicaCmd.Flags().StringVar(&replicaPrimary, "pull", "", "URL of the primary server to copy projects from")
+	replicaCmd.Flags().StringVar(&replicaAPIKey, "api-key", "", "API key sent to the primary as X-API-Key (or set GCA_REPLICA_API_KEY)")
+	replicaCmd.Flags().StringSliceVar(&replicaProjects, "project", nil, "Project to copy; repeat for several (default: every project of the primary)")
+	replicaCmd.Flags().BoolVar(&auditQueries, "audit", true, "Record every Datalog, path and AI request in the audit log")
+	replicaCmd.Flags().DurationVar(&replicaInterval, "interval", config.ReplicaPullInterval, "How often to poll the primary for newer snapshots")
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/spf13/cobra"
+)
+
+var retentionSet string
+var retentionSweep bool
+var retentionOutput string
+
+// retentionCmd represents the retention command
+var retentionCmd = &cobra.Command{
+	Use:   "retention [data-folder]",
+	Short: "Show or set how long transient graphs are kept, and delete expired facts",
+	Long: `Show the store's retention policy: the time to live of the facts of transient
+graphs, so that enrichment such as runtime traces or hand-drawn links does
+not pile up forever. Graphs:
+
+  runtime      actually_calls facts and weights from gca import-trace
+  annotations  Notes and labels added through /api/v1/annotations
+  virtual      Links added through /api/v1/annotations
+
+Facts written under a policy expire once its TTL has passed; writing them
+again, as a new trace of the same call does, renews it. Servers writing to
+their stores delete expired facts every few minutes, and --sweep does it now.
+Expired annotations are hidden at once and dropped on the next write.
+
+Arguments:
+  data-folder  Path to the ingested data (default: ./data)`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(retentionOutput, outputText, outputJSON); err != nil {
+			return err
+		}
+		dataPath := dataDir
+		if len(args) > 0 {
+			dataPath = args[0]
+		}
+		dataDir = dataPath
+		readOnly := retentionSet == "" && !retentionSweep
+
+		var policy gcamdb.RetentionPolicy
+		if retentionSet != "" {
+			var err error
+			if policy, err = gcamdb.ParseRetention(retentionSet); err != nil {
+				return err
+			}
+		}
+
+		s, err := createStore(readOnly, dataPath)
+		if err != nil {
+			return fmt.Errorf("failed to open MEB store: %w", err)
+		}
+		if readOnly {
+			defer s.Close()
+		} else {
+			defer closeStore(s, dataPath)
+		}
+
+		if retentionSet != "" {
+			if err := gcamdb.SaveRetention(s, policy); err != nil {
+				return err
+			}
+		} else if policy, err = gcamdb.Retention(s); err != nil {
+			return err
+		}
+		var swept *gcamdb.SweepReport
+		if retentionSweep {
+			report, err := gcamdb.SweepExpired(context.Background(), s, time.Now())
+			if err != nil {
+				return err
+			}
+			swept = &report
+		}
+		expiries, err := gcamdb.Expiries(s)
+		if err != nil {
+			return err
+		}
+		due := make(map[string]int)
+		var next time.Time
+		for _, e := range expiries {
+			due[e.Graph]++
+			if next.IsZero() || e.ExpiresAt.Before(next) {
+				next = e.ExpiresAt
+			}
+		}
+
+		if retentionOutput == outputJSON {
+			ttls := make(map[string]string, len(policy))
+			for graph, ttl := range policy {
+				ttls[graph] = ttl.String()
+			}
+			return writeJSON(os.Stdout, struct {
+				Policy map[string]string   `json:"policy"`
+				Due    map[string]int      `json:"due"` // Facts due to expire, by graph
+				Next   time.Time           `json:"next,omitzero"`
+				Sweep  *gcamdb.SweepReport `json:"sweep,omitempty"`
+			}{ttls, due, next, swept})
+		}
+
+		for _, graph := range gcamdb.RetentionGraphs {
+			ttl := "kept"
+			if d, ok := policy[graph]; ok {
+				ttl = d.String()
+			}
+			fmt.Printf("%-12s %-10s %d facts due to expire\n", graph, ttl, due[graph])
+		}
+		if !next.IsZero() {
+			fmt.Printf("Next expiry: %s\n", next.Local().Format("2006-01-02 15:04:05"))
+		}
+		if swept != nil {
+			fmt.Printf("Deleted %d expired facts and %d observed call weights\n", swept.Facts, swept.RuntimeEdges)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retentionCmd)
+	retentionCmd.Flags().StringVar(&retentionSet, "set", "", "Replace the policy, as graph=ttl pairs (e.g. runtime=168h,virtual=720h)")
+	retentionCmd.Flags().BoolVar(&retentionSweep, "sweep", false, "Delete the facts that have expired now")
+	addOutputFlag(retentionCmd, &retentionOutput, outputText, outputJSON)
+}
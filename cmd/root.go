@@ -11,6 +11,7 @@ import (
 
 	"github.com/duynguyendang/gca/internal/manager"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 	"github.com/duynguyendang/meb/store"
 	"github.com/joho/godotenv"
@@ -18,11 +19,12 @@ import (
 )
 
 var (
-	cfgFile   string
-	dataDir   string
-	sourceDir string
-	lowMem    bool
-	port      string
+	cfgFile     string
+	dataDir     string
+	sourceDir   string
+	lowMem      bool
+	port        string
+	blobBackend string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -31,7 +33,12 @@ var rootCmd = &cobra.Command{
 	Short: "GCA - Neuro-Symbolic Code Analysis Platform",
 	Long: `GCA (Gem Code Analysis) is a next-generation code analysis tool that ingests
 source code into a semantic knowledge graph, enabling powerful queries through
-Datalog, natural language, and semantic search.`,
+Datalog, natural language, and semantic search.
+
+Ingest a project with "gca ingest", serve it with "gca serve", and query it
+with "gca query", "gca repl" or "gca mcp"; "gca export" writes its graph.
+Commands that print results take --output json for scripting, and
+"gca completion" generates shell completions.`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// Load .env file if exists
 		_ = godotenv.Load()
@@ -52,6 +59,19 @@ Datalog, natural language, and semantic search.`,
 			lowMem = strings.ToLower(lowMemStr) == "true"
 		}
 
+		// Document contents go to object storage when a blob backend is set
+		if blobBackend == "" {
+			blobBackend = os.Getenv("GCA_BLOB_BACKEND")
+		}
+		backend, err := gcamdb.ParseBlobBackend(blobBackend)
+		if err != nil {
+			return err
+		}
+		if backend != nil {
+			gcamdb.SetBlobBackend(backend)
+			logger.Info("Storing document contents in blob backend", "backend", backend.Name())
+		}
+
 		return nil
 	},
 }
@@ -67,6 +87,9 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&sourceDir, "source", "s", "", "path to source code (for source view)")
 	rootCmd.PersistentFlags().BoolVarP(&lowMem, "low-mem", "l", false, "enable low memory mode")
 	rootCmd.PersistentFlags().StringVarP(&port, "port", "p", "8080", "port for the server (or set PORT env var)")
+	rootCmd.MarkPersistentFlagDirname("data")
+	rootCmd.MarkPersistentFlagDirname("source")
+	rootCmd.PersistentFlags().StringVar(&blobBackend, "blob-backend", "", "keep document contents in object storage: s3://bucket/prefix or gs://bucket/prefix (or set GCA_BLOB_BACKEND)")
 }
 
 // getMemoryProfile returns the appropriate memory profile based on flags
@@ -107,12 +130,70 @@ func createStore(readOnly bool, dataPath string) (*meb.MEBStore, error) {
 
 	if readOnly {
 		cfg.ReadOnly = true
-		fmt.Printf("Running in READ-ONLY mode. Data directory: %s\n", dataPath)
+		fmt.Fprintf(status, "Running in READ-ONLY mode. Data directory: %s\n", dataPath)
 	} else {
-		fmt.Printf("Running in INGESTION mode.\nSource: %s\nData: %s\n", sourceDir, dataDir)
+		fmt.Fprintf(status, "Running in INGESTION mode.\nSource: %s\nData: %s\n", sourceDir, dataDir)
+	}
+
+	s, err := meb.NewMEBStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reconcile the fact counter if the last writer crashed, then claim the
+	// store. Readers leave both to writers: the marker may be a live writer's,
+	// and a read-only store cannot save the count.
+	if !readOnly {
+		if _, err := gcamdb.Recover(s, dataPath); err != nil {
+			logger.Warn("Fact count recovery failed", "error", err)
+		}
+		if err := gcamdb.MarkDirty(dataPath); err != nil {
+			logger.Warn("Failed to write dirty marker", "error", err)
+		}
+	}
+	if err := migrateStore(s, readOnly, dataPath); err != nil {
+		s.Close()
+		return nil, err
 	}
+	return s, nil
+}
+
+// migrateStore brings a store written by an older gca up to the current format
+// before it is written to. Read-only commands only refuse stores of a newer
+// gca.
+func migrateStore(s *meb.MEBStore, readOnly bool, dataPath string) error {
+	pending, err := gcamdb.PendingMigrations(s)
+	if err != nil {
+		return err
+	}
+	if readOnly {
+		if len(pending) > 0 {
+			logger.Warn("Store needs format migrations; run gca migrate", "data", dataPath, "pending", len(pending))
+		}
+		return nil
+	}
+	report, err := gcamdb.Migrate(context.Background(), s, getProjectName(dataPath), func(p gcamdb.MigrationProgress) {
+		fmt.Fprintf(status, "Migrating store: %d %s (%d/%d)\n", p.Version, p.Name, p.Done, p.Total)
+	})
+	if err != nil {
+		return err
+	}
+	if len(report.Applied) > 0 {
+		fmt.Fprintf(status, "Migrated store from format %d to %d\n", report.From, report.To)
+	}
+	return nil
+}
 
-	return meb.NewMEBStore(cfg)
+// closeStore closes a store opened for writing and clears its dirty marker
+// once everything has been flushed.
+func closeStore(s *meb.MEBStore, dataPath string) {
+	if err := s.Close(); err != nil {
+		logger.Warn("Store close error", "error", err)
+		return
+	}
+	if err := gcamdb.MarkClean(dataPath); err != nil {
+		logger.Warn("Failed to clear dirty marker", "error", err)
+	}
 }
 
 // getProjectName extracts the project name from the data directory
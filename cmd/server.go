@@ -7,29 +7,176 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/meb/bench"
 	"github.com/duynguyendang/gca/pkg/server"
+	"github.com/duynguyendang/gca/pkg/webhook"
+	"github.com/duynguyendang/meb"
 	"github.com/spf13/cobra"
 )
 
+var sharedStore bool
+var watchSource string
+var watchProject string
+var recordQueries string
+var recordSample float64
+var tenantsFile string
+var webhooksFile string
+var scheduleFile string
+var auditQueries bool
+var warmupStores bool
+var maintainStores bool
+
 // serverCmd represents the server command
 var serverCmd = &cobra.Command{
-	Use:   "server",
-	Short: "Start the REST API server",
+	Use:     "serve",
+	Aliases: []string{"server"},
+	Short:   "Start the REST API server",
 	Long: `Start the GCA REST API server for code analysis and visualization.
 The server provides endpoints for querying the knowledge graph, semantic search,
-and AI-powered code analysis.`,
+and AI-powered code analysis. Its stores are opened read-only unless it is
+started with --watch, --schedule or --tenants; until then, routes that write
+to them (views, annotations, saved queries, fact imports and weights) answer
+503.
+
+With --watch, the server also re-ingests the given source tree incrementally
+whenever files change, so the graph follows the working tree.
+
+With --schedule, the server re-ingests the projects listed in the given YAML
+file on cron schedules, incrementally unless a job says full: true, pulling
+their git checkouts first with pull: true. A run due while the project's
+previous run is still going is skipped. GET /api/v1/admin/schedules lists the
+jobs and their last runs, and POST /api/v1/admin/schedules/<project>/run
+starts one at once.
+
+With --record-queries, a sample of the Datalog, search and path queries served
+is written to a query log that "gca stress --replay" plays back.
+
+With --tenants, the server serves every tenant listed in the given file, each
+from its own data root and held to its own API keys and quotas. Requests name
+their tenant with a /t/<tenant> path prefix or the X-Tenant-ID header.
+
+Every Datalog, path and AI request served is recorded in an audit log in the
+data folder (in each tenant's data root with --tenants), listed at
+GET /api/v1/audit and by "gca audit list", and replayed by "gca audit replay".
+Disable it with --audit=false.
+
+With --webhooks, every finished ingest or watch sync, fact import and change
+in the number of embeddings is posted as JSON to the subscriptions listed in
+the given YAML file, and the file's rules, Datalog queries that should return
+no rows, are checked after each; a rule returning rows is posted as
+rule.violated. Subscriptions are added and removed at /api/v1/webhooks, which
+writes them back to the file.
+
+Once listening, the server warms up the stores of its projects in the
+background, reading the facts, source and embeddings of their most central
+nodes (tagged at ingest) so that the first queries don't hit cold caches. The
+low memory profile warms fewer nodes and skips embeddings. Disable it with
+--warmup=false.
+
+While serving, stores left idle are reconciled in the background every few
+minutes: a rate-limited scan recounts their facts, in all and by predicate,
+correcting fact counters that drifted, for /api/v1/predicates and
+GET /api/v1/admin/catalogs. The low memory profile reconciles less often and
+more slowly. Servers writing to their stores (--watch, --schedule, --tenants)
+also delete the facts that expired under the stores' retention policies
+("gca retention") every few minutes. Disable both with --maintenance=false.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if tenantsFile != "" {
+			return runTenantServer()
+		}
 		fmt.Printf("Starting REST API Server. Project Root: %s\n", dataDir)
 
 		// Initialize StoreManager
-		mgr := manager.NewStoreManager(dataDir, getMemoryProfile(), true)
+		newManager := manager.NewStoreManager
+		if sharedStore {
+			newManager = manager.NewSharedStoreManager
+		}
+		mgr := newManager(dataDir, getMemoryProfile(), watchSource == "" && scheduleFile == "")
 		defer mgr.CloseAll()
 
+		if watchSource != "" && sourceDir == "" {
+			sourceDir = watchSource
+		}
 		srv := server.NewServer(mgr, sourceDir)
+		if recordQueries != "" {
+			f, err := os.OpenFile(recordQueries, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+			if err != nil {
+				return fmt.Errorf("open query log: %w", err)
+			}
+			defer f.Close()
+			srv.RecordQueries(bench.NewRecorder(f, recordSample))
+			fmt.Printf("Recording %.0f%% of queries to %s\n", recordSample*100, recordQueries)
+		}
+		if auditQueries {
+			if auditLog := openAuditLog(); auditLog != nil {
+				defer auditLog.Close()
+				srv.AuditTo(auditLog)
+			}
+		}
+		if webhooksFile != "" {
+			d, err := openWebhooks(webhooksFile)
+			if err != nil {
+				return err
+			}
+			defer closeWebhooks(d)
+			srv.NotifyTo(d)
+			fmt.Printf("Posting graph changes to the webhooks in %s\n", webhooksFile)
+		}
+
+		watchCtx, stopWatch := context.WithCancel(context.Background())
+		defer stopWatch()
+		var jobs []ingest.Job
+		if scheduleFile != "" {
+			var err error
+			if jobs, err = ingest.LoadJobs(scheduleFile); err != nil {
+				return err
+			}
+		}
+
+		watchDone := make(chan struct{})
+		if watchSource == "" {
+			close(watchDone)
+		} else {
+			project := watchProject
+			if project == "" {
+				abs, err := filepath.Abs(watchSource)
+				if err != nil {
+					return err
+				}
+				project = filepath.Base(abs)
+			}
+			for _, j := range jobs {
+				if j.Project == project {
+					return fmt.Errorf("project %s is both watched and scheduled; pick one", project)
+				}
+			}
+			opts := &ingest.IngestOptions{SkipEmbeddings: noEmbed || os.Getenv("SKIP_EMBEDDINGS") == "true"}
+			w := ingest.NewWatcher(func() (*meb.MEBStore, func(), error) { return mgr.HoldProject(project) }, project, watchSource, opts)
+			srv.Watch(w)
+			fmt.Printf("Watching %s for changes (project %s)\n", watchSource, project)
+			go func() {
+				defer close(watchDone)
+				if err := w.Run(watchCtx); err != nil {
+					log.Printf("Watch stopped: %v", err)
+				}
+			}()
+		}
+		if scheduleFile != "" {
+			opts := &ingest.IngestOptions{SkipEmbeddings: noEmbed || os.Getenv("SKIP_EMBEDDINGS") == "true"}
+			sch := ingest.NewScheduler(mgr.HoldProject, jobs, opts)
+			srv.Schedule(sch)
+			stopSchedule := startBackground("Scheduled ingests", true, sch.Run)
+			defer stopSchedule()
+			fmt.Printf("Scheduled re-ingestion of %d projects from %s\n", len(jobs), scheduleFile)
+		}
 		addr := ":" + port
 
 		httpSrv := &http.Server{
@@ -44,6 +191,12 @@ and AI-powered code analysis.`,
 				errChan <- fmt.Errorf("listen error: %w", err)
 			}
 		}()
+		stopWarmup := startBackground("Warmup", warmupStores, mgr.Warmup)
+		defer stopWarmup()
+		stopMaintenance := startBackground("Maintenance", maintainStores, mgr.Maintain)
+		defer stopMaintenance()
+		stopSweep := startBackground("Retention sweeps", maintainStores && (watchSource != "" || scheduleFile != ""), mgr.Sweep)
+		defer stopSweep()
 
 		// Wait for interrupt signal or server error
 		quit := make(chan os.Signal, 1)
@@ -64,12 +217,139 @@ and AI-powered code analysis.`,
 		if err := httpSrv.Shutdown(ctx); err != nil {
 			log.Fatal("Server forced to shutdown: ", err)
 		}
+		// Let a running sync finish before the stores close
+		stopWatch()
+		<-watchDone
 
 		log.Println("Server exiting")
 		return nil
 	},
 }
 
+// runTenantServer serves the tenants listed in tenantsFile until interrupted.
+// Tenant stores are opened for writing, so that saved views, annotations and
+// imported facts land in the tenant's own stores and count against its quota.
+func runTenantServer() error {
+	if watchSource != "" || sharedStore || recordQueries != "" || webhooksFile != "" || scheduleFile != "" {
+		return fmt.Errorf("--tenants cannot be combined with --watch, --shared-store, --record-queries, --webhooks or --schedule; set shared_store per tenant instead")
+	}
+	tenants, err := manager.LoadTenants(tenantsFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Starting multi-tenant REST API Server with %d tenants\n", len(tenants))
+	router := server.NewTenantRouter(tenants, getMemoryProfile(), false)
+	defer router.Close()
+	if auditQueries {
+		if err := router.EnableAudit(); err != nil {
+			return fmt.Errorf("open audit log: %w", err)
+		}
+	}
+
+	httpSrv := &http.Server{
+		Addr:    ":" + port,
+		Handler: router.Handler(),
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- fmt.Errorf("listen error: %w", err)
+		}
+	}()
+	stopWarmup := startBackground("Warmup", warmupStores, router.Warmup)
+	defer stopWarmup()
+	stopMaintenance := startBackground("Maintenance", maintainStores, router.Maintain)
+	defer stopMaintenance()
+	stopSweep := startBackground("Retention sweeps", maintainStores, router.Sweep)
+	defer stopSweep()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-quit:
+		log.Println("Shutting down server...")
+	case err := <-errChan:
+		log.Printf("Server error: %v", err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpSrv.Shutdown(ctx); err != nil {
+		log.Fatal("Server forced to shutdown: ", err)
+	}
+	log.Println("Server exiting")
+	return nil
+}
+
+// startBackground runs task in the background when enabled, such as the
+// warmup unless --warmup=false. The function it returns stops the task and
+// waits for it, and must be called before the stores close.
+func startBackground(name string, enabled bool, task func(context.Context) error) func() {
+	if !enabled {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		began := time.Now()
+		if err := task(ctx); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("%s failed: %v", name, err)
+			}
+			return
+		}
+		log.Printf("%s finished in %v", name, time.Since(began).Round(time.Millisecond))
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// openAuditLog opens the audit log in the data folder for writing. Serving goes
+// on without one when it can't be opened.
+func openAuditLog() *gcamdb.AuditLog {
+	auditLog, err := gcamdb.OpenAuditLog(filepath.Join(dataDir, config.AuditStoreDir), false)
+	if err != nil {
+		log.Printf("Warning: audit log disabled: %v", err)
+		return nil
+	}
+	return auditLog
+}
+
+// openWebhooks starts posting events to the subscriptions of the webhooks file
+// at path, which is created by the first subscription added when missing.
+func openWebhooks(path string) (*webhook.Dispatcher, error) {
+	reg, err := webhook.OpenRegistry(path)
+	if err != nil {
+		return nil, err
+	}
+	return webhook.NewDispatcher(reg), nil
+}
+
+// closeWebhooks gives queued deliveries a few seconds to be sent.
+func closeWebhooks(d *webhook.Dispatcher) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		log.Printf("Warning: webhook deliveries abandoned: %v", err)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(serverCmd)
+	serverCmd.Flags().BoolVar(&sharedStore, "shared-store", false, "Serve one store holding several projects (ingested with --project)")
+	serverCmd.Flags().StringVar(&watchSource, "watch", "", "Source folder to re-ingest incrementally as files change")
+	serverCmd.Flags().StringVar(&watchProject, "watch-project", "", "Project name for --watch (default: source folder name)")
+	serverCmd.Flags().StringVar(&scheduleFile, "schedule", "", "Re-ingest the projects listed in this YAML file on their cron schedules")
+	serverCmd.Flags().BoolVarP(&noEmbed, "no-embed", "e", false, "Skip embedding generation when re-ingesting with --watch or --schedule")
+	serverCmd.Flags().StringVar(&recordQueries, "record-queries", "", "Write a sample of served queries to this file for stress --replay")
+	serverCmd.Flags().StringVar(&tenantsFile, "tenants", "", "Serve the tenants listed in this YAML file, each with its own data root, API keys and quotas")
+	serverCmd.Flags().StringVar(&webhooksFile, "webhooks", "", "Post ingest, rule and vector index events to the webhooks in this YAML file, managed at /api/v1/webhooks")
+	serverCmd.Flags().BoolVar(&auditQueries, "audit", true, "Record every Datalog, path and AI request in the audit log")
+	serverCmd.Flags().BoolVar(&warmupStores, "warmup", true, "Warm up the stores' caches in the background after starting")
+	serverCmd.Flags().BoolVar(&maintainStores, "maintenance", true, "Reconcile idle stores' fact counts and catalogs, and delete expired facts, in the background")
+	serverCmd.Flags().Float64Var(&recordSample, "record-sample", 0.1, "Fraction of queries recorded with --record-queries (0-1)")
 }
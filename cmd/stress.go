@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/meb/bench"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/duynguyendang/meb"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stressWorkers   int
+	stressDuration  time.Duration
+	stressRequests  int64
+	stressWarmup    int64
+	stressWorkloads []string
+	stressFormat    string // Deprecated --format
+	stressOutput    string
+	stressFile      string
+	stressReplay    string
+	stressSpeed     float64
+)
+
+// stressSampleSize caps the symbols and files sampled as workload inputs.
+const stressSampleSize = 10000
+
+// stressCmd represents the stress command
+var stressCmd = &cobra.Command{
+	Use:   "stress [data-folder]",
+	Short: "Load-test the store's read paths and report latency percentiles",
+	Long: `Run concurrent read workloads against an ingested store and report
+throughput and latency percentiles per workload. Write the report as CSV or
+JSON to track store performance across releases.
+
+Workloads:
+  lookup     All facts of a random symbol (subject scan)
+  callers    Callers of a random symbol (object scan)
+  defines    Symbols defined by a random file
+  predicate  First 100 facts of the calls predicate
+
+With --replay, the queries recorded by "gca serve --record-queries" are played
+back instead, through the same service calls the server makes, against a
+snapshot of the server's data folder. The report has one row per query kind
+(datalog, keyword, path, vector) and a total. By default queries run back to
+back; --speed replays them at their recorded pace, sped up by the given factor.
+
+Arguments:
+  data-folder  Path to the data directory (default: ./data); with --replay,
+               the server's data folder`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeArgs(cobra.ShellCompDirectiveFilterDirs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataPath := dataDir
+		if len(args) > 0 {
+			dataPath = args[0]
+		}
+		// --output used to name the report file, and --format its format
+		format := stressOutput
+		if cmd.Flags().Changed("format") {
+			format = stressFormat
+		}
+		if _, isFormat := stressWriters[stressOutput]; !isFormat && stressFile == "" {
+			fmt.Fprintln(os.Stderr, "Flag --output takes the report format now; use --out-file to name the report file")
+			stressFile = stressOutput
+			if !cmd.Flags().Changed("format") {
+				format = outputText
+			}
+		}
+		write, ok := stressWriters[format]
+		if !ok {
+			return fmt.Errorf("unknown format %q (want text, csv or json)", format)
+		}
+
+		ctx, cancel := createBaseContext()
+		defer cancel()
+
+		if stressReplay != "" {
+			results, err := stressReplayLog(ctx, dataPath)
+			if err != nil {
+				return err
+			}
+			return writeStressReport(write, results)
+		}
+
+		s, err := createStore(true, dataPath)
+		if err != nil {
+			return fmt.Errorf("failed to create MEB store: %w", err)
+		}
+		defer s.Close()
+
+		symbols, files := stressSample(ctx, s)
+		if len(symbols) == 0 {
+			return fmt.Errorf("no symbols in %s; ingest a project first", dataPath)
+		}
+
+		cfg := bench.Config{
+			Workers:  stressWorkers,
+			Requests: stressRequests,
+			Duration: stressDuration,
+			Warmup:   stressWarmup,
+		}
+		var results []*bench.Result
+		for _, name := range stressWorkloads {
+			op, err := stressWorkload(s, name, symbols, files, max(cfg.Workers, 1))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Running %s with %d workers...\n", name, max(cfg.Workers, 1))
+			res, err := bench.Run(ctx, name, cfg, op)
+			if err != nil {
+				return fmt.Errorf("workload %s: %w", name, err)
+			}
+			if res.FirstError != nil {
+				fmt.Fprintf(os.Stderr, "%s: %d errors, first: %v\n", name, res.Errors, res.FirstError)
+			}
+			results = append(results, res)
+		}
+
+		return writeStressReport(write, results)
+	},
+}
+
+// stressWriters write stress reports, by --output format.
+var stressWriters = map[string]func(io.Writer, []*bench.Result) error{
+	outputText: bench.WriteText,
+	"csv":      bench.WriteCSV,
+	outputJSON: bench.WriteJSON,
+}
+
+// writeStressReport writes the results to --out-file, or stdout.
+func writeStressReport(write func(io.Writer, []*bench.Result) error, results []*bench.Result) error {
+	if stressFile == "" {
+		return write(os.Stdout, results)
+	}
+	f, err := os.Create(stressFile)
+	if err != nil {
+		return err
+	}
+	if err := write(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// stressReplayLog replays the --replay query log against the stores under dataPath.
+func stressReplayLog(ctx context.Context, dataPath string) ([]*bench.Result, error) {
+	f, err := os.Open(stressReplay)
+	if err != nil {
+		return nil, err
+	}
+	records, err := bench.ReadQueryLog(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read query log: %w", err)
+	}
+
+	newManager := manager.NewStoreManager
+	if sharedStore {
+		newManager = manager.NewSharedStoreManager
+	}
+	mgr := newManager(dataPath, getMemoryProfile(), true)
+	defer mgr.CloseAll()
+	svc := service.NewGraphService(mgr)
+
+	cfg := bench.ReplayConfig{
+		Config: bench.Config{
+			Workers:  stressWorkers,
+			Requests: stressRequests,
+			Duration: stressDuration,
+			Warmup:   stressWarmup,
+		},
+		Speed: stressSpeed,
+	}
+	fmt.Fprintf(os.Stderr, "Replaying %d queries with %d workers...\n", len(records), max(cfg.Workers, 1))
+	results, err := bench.Replay(ctx, cfg, records, func(ctx context.Context, rec bench.QueryRecord) error {
+		return replayQuery(ctx, svc, rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		if res.FirstError != nil && res.Name != "total" {
+			fmt.Fprintf(os.Stderr, "%s: %d errors, first: %v\n", res.Name, res.Errors, res.FirstError)
+		}
+	}
+	return results, nil
+}
+
+// replayQuery issues a recorded query through the service call that served it.
+func replayQuery(ctx context.Context, svc *service.GraphService, rec bench.QueryRecord) error {
+	var err error
+	switch rec.Kind {
+	case bench.QueryDatalog:
+		opts := gcamdb.QueryOptions{Limit: rec.Limit}
+		if rec.Raw {
+			_, err = svc.ExecuteQueryWithOptions(ctx, rec.Project, rec.Query, opts)
+		} else {
+			_, err = svc.ExportGraphWithOptions(ctx, rec.Project, rec.Query, true, false, opts)
+		}
+	case bench.QueryPath:
+		_, err = svc.FindShortestPathWithOptions(ctx, rec.Project, rec.Source, rec.Target, service.PathOptions{Predicates: rec.Predicates, Direction: rec.Direction, MaxDepth: rec.Depth})
+	case bench.QueryKeyword:
+		_, err = svc.KeywordSearch(ctx, rec.Project, strings.Join(rec.Tokens, " "), rec.K)
+	case bench.QueryVector:
+		_, err = svc.SemanticSearch(ctx, rec.Project, "", rec.K, recordedEmbedding(rec.Vector))
+	default:
+		err = fmt.Errorf("unknown query kind %q", rec.Kind)
+	}
+	return err
+}
+
+// recordedEmbedding answers every embedding request with a recorded query
+// vector, so vector searches replay without an embedding provider.
+type recordedEmbedding []float32
+
+func (e recordedEmbedding) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return e, nil
+}
+
+// stressSample collects the symbols and files the workloads pick from.
+func stressSample(ctx context.Context, s *meb.MEBStore) (symbols, files []string) {
+	seenFiles := make(map[string]bool)
+	for fact, err := range s.ScanContext(ctx, "", config.PredicateDefines, "") {
+		if err != nil || len(symbols) == stressSampleSize {
+			break
+		}
+		if id, ok := fact.Object.(string); ok {
+			symbols = append(symbols, id)
+		}
+		if !seenFiles[fact.Subject] {
+			seenFiles[fact.Subject] = true
+			files = append(files, fact.Subject)
+		}
+	}
+	return symbols, files
+}
+
+// stressWorkload returns the operation of a named workload. Each worker draws
+// its inputs from its own random source.
+func stressWorkload(s *meb.MEBStore, name string, symbols, files []string, workers int) (bench.Op, error) {
+	rngs := make([]*rand.Rand, workers)
+	for i := range rngs {
+		rngs[i] = rand.New(rand.NewPCG(uint64(i), uint64(time.Now().UnixNano())))
+	}
+	drain := func(ctx context.Context, subj, pred, obj string, limit int) error {
+		n := 0
+		for _, err := range s.ScanContext(ctx, subj, pred, obj) {
+			if err != nil {
+				return err
+			}
+			if n++; limit > 0 && n == limit {
+				break
+			}
+		}
+		return nil
+	}
+	switch name {
+	case "lookup":
+		return func(ctx context.Context, worker int) error {
+			return drain(ctx, symbols[rngs[worker].IntN(len(symbols))], "", "", 0)
+		}, nil
+	case "callers":
+		return func(ctx context.Context, worker int) error {
+			return drain(ctx, "", config.PredicateCalls, symbols[rngs[worker].IntN(len(symbols))], 0)
+		}, nil
+	case "defines":
+		return func(ctx context.Context, worker int) error {
+			return drain(ctx, files[rngs[worker].IntN(len(files))], config.PredicateDefines, "", 0)
+		}, nil
+	case "predicate":
+		return func(ctx context.Context, worker int) error {
+			return drain(ctx, "", config.PredicateCalls, "", 100)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown workload %q (want lookup, callers, defines or predicate)", name)
+}
+
+func init() {
+	rootCmd.AddCommand(stressCmd)
+	stressCmd.Flags().IntVar(&stressWorkers, "workers", runtime.NumCPU(), "Concurrent workers per workload")
+	stressCmd.Flags().DurationVar(&stressDuration, "duration", 10*time.Second, "Run time per workload (0 for no limit)")
+	stressCmd.Flags().Int64Var(&stressRequests, "requests", 0, "Operations per workload (0 for no limit)")
+	stressCmd.Flags().Int64Var(&stressWarmup, "warmup", 10, "Unmeasured operations per worker before each workload")
+	stressCmd.Flags().StringSliceVar(&stressWorkloads, "workload", []string{"lookup", "callers", "defines", "predicate"}, "Workloads to run, in order")
+	addOutputFlag(stressCmd, &stressOutput, outputText, "csv", outputJSON)
+	stressCmd.Flags().StringVarP(&stressFile, "out-file", "f", "", "Write the report to a file instead of stdout")
+	stressCmd.Flags().StringVar(&stressFormat, "format", outputText, "Report format: text, csv or json")
+	stressCmd.Flags().MarkDeprecated("format", "use --output")
+	stressCmd.Flags().StringVar(&stressReplay, "replay", "", "Replay a query log recorded with serve --record-queries")
+	stressCmd.Flags().Float64Var(&stressSpeed, "speed", 0, "With --replay, replay at the recorded pace times this factor (0: back to back)")
+	stressCmd.Flags().BoolVar(&sharedStore, "shared-store", false, "With --replay, the data folder is one store holding several projects")
+}
@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// MaintenanceSpec says how often and how hard Maintain reconciles stores.
+type MaintenanceSpec struct {
+	Interval time.Duration // Between rounds, moved by up to Jitter of it at random
+	Jitter   float64
+	Idle     time.Duration // Stores read more recently are left alone
+	MaxAge   time.Duration // Catalogs older than this are rebuilt even when nothing changed
+	Rate     int           // Facts scanned a second
+	Stores   int           // Most stores reconciled per round
+}
+
+// MaintenanceSpec returns how Maintain reconciles stores under the manager's
+// memory profile. The low memory profile runs fewer, slower rounds, whose
+// scans would otherwise churn its smaller caches.
+func (sm *StoreManager) MaintenanceSpec() MaintenanceSpec {
+	spec := MaintenanceSpec{
+		Interval: config.MaintenanceInterval,
+		Jitter:   config.MaintenanceJitter,
+		Idle:     config.MaintenanceIdle,
+		MaxAge:   config.MaintenanceMaxAge,
+		Rate:     config.MaintenanceRate,
+		Stores:   config.MaintenanceStoresPerRound,
+	}
+	if sm.profile == MemoryProfileLow {
+		spec.Interval = config.MaintenanceIntervalLow
+		spec.Rate = config.MaintenanceRateLow
+		spec.Stores = 1
+	}
+	return spec
+}
+
+// Maintain reconciles the open stores in the background, in place of running
+// RecalculateStats by hand: every round, spaced by the jittered interval of
+// the manager's MaintenanceSpec, MaintainOnce rebuilds the catalogs of idle
+// stores and corrects their fact counters. It returns ctx's error once ctx is
+// done.
+func (sm *StoreManager) Maintain(ctx context.Context) error {
+	spec := sm.MaintenanceSpec()
+	for {
+		jitter := time.Duration((rand.Float64()*2 - 1) * spec.Jitter * float64(spec.Interval))
+		timer := time.NewTimer(spec.Interval + jitter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		sm.MaintainOnce(ctx, spec)
+	}
+}
+
+// MaintainOnce rebuilds, with gcamdb.RefreshCatalog, the catalogs of up to
+// spec.Stores open stores that went unread for spec.Idle and whose catalog is
+// missing, older than spec.MaxAge or behind their fact count, least recently
+// refreshed first. It returns the cache keys of the stores it refreshed;
+// failures are logged and skipped.
+func (sm *StoreManager) MaintainOnce(ctx context.Context, spec MaintenanceSpec) []string {
+	type candidate struct {
+		key       string
+		store     *meb.MEBStore
+		refreshed time.Time
+	}
+	now := time.Now()
+	var due []candidate
+	sm.mu.Lock()
+	for _, key := range sm.projects.Keys() {
+		s, ok := sm.projects.Peek(key)
+		if !ok || now.Sub(sm.lastUsed[key]) < spec.Idle {
+			continue
+		}
+		cat, ok := gcamdb.CatalogOf(s)
+		if ok && cat.Facts == s.Count() && now.Sub(cat.Refreshed) < spec.MaxAge {
+			continue
+		}
+		due = append(due, candidate{key: key, store: s, refreshed: cat.Refreshed})
+	}
+	sm.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].refreshed.Before(due[j].refreshed) })
+	var refreshed []string
+	for _, c := range due[:min(len(due), spec.Stores)] {
+		// A store closed since it was picked, such as for a snapshot, is skipped
+		release := sm.Hold()
+		sm.mu.Lock()
+		s, ok := sm.projects.Peek(c.key)
+		sm.mu.Unlock()
+		if !ok || s != c.store {
+			release()
+			continue
+		}
+		cat, err := gcamdb.RefreshCatalog(ctx, c.store, spec.Rate)
+		release()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Maintenance failed for project %s: %v", c.key, err)
+			continue
+		}
+		if cat.Drift != 0 {
+			log.Printf("Corrected fact count of project %s by %d to %d", c.key, -cat.Drift, cat.Facts)
+		}
+		refreshed = append(refreshed, c.key)
+	}
+	return refreshed
+}
+
+// Catalogs returns the catalogs of the open stores that have one, by project.
+func (sm *StoreManager) Catalogs() map[string]gcamdb.Catalog {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	catalogs := make(map[string]gcamdb.Catalog, sm.projects.Len())
+	for _, key := range sm.projects.Keys() {
+		if s, ok := sm.projects.Peek(key); ok {
+			if cat, ok := gcamdb.CatalogOf(s); ok {
+				catalogs[key] = cat
+			}
+		}
+	}
+	return catalogs
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/meb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintainOnce(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, id := range []string{"p1", "p2"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, id), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sm := NewStoreManager(tmpDir, MemoryProfileDefault, false)
+	defer sm.CloseAll()
+
+	stores := make(map[string]*meb.MEBStore)
+	for _, id := range []string{"p1", "p2"} {
+		s, err := sm.GetStore(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddFact(meb.Fact{Subject: id + ".go:main", Predicate: "type", Object: "function"}); err != nil {
+			t.Fatal(err)
+		}
+		stores[id] = s
+	}
+	ctx := context.Background()
+	spec := sm.MaintenanceSpec()
+
+	// Stores just read are left alone
+	assert.Empty(t, sm.MaintainOnce(ctx, spec))
+
+	spec.Idle = 0
+	spec.Stores = 1
+	first := sm.MaintainOnce(ctx, spec)
+	assert.Len(t, first, 1)
+	second := sm.MaintainOnce(ctx, spec)
+	assert.Len(t, second, 1)
+	assert.NotEqual(t, first, second, "least recently refreshed first")
+
+	// Fresh catalogs of unchanged stores aren't rebuilt
+	spec.Stores = 2
+	assert.Empty(t, sm.MaintainOnce(ctx, spec))
+
+	// Writes make a store due again
+	if err := stores["p1"].AddFact(meb.Fact{Subject: "p1.go:run", Predicate: "type", Object: "function"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"p1"}, sm.MaintainOnce(ctx, spec))
+	catalogs := sm.Catalogs()
+	assert.Equal(t, uint64(2), catalogs["p1"].Facts)
+	assert.Equal(t, map[string]uint64{"type": 2}, catalogs["p1"].Predicates)
+	assert.Equal(t, uint64(1), catalogs["p2"].Facts)
+
+	// As do old catalogs
+	spec.MaxAge = time.Nanosecond
+	assert.ElementsMatch(t, []string{"p1", "p2"}, sm.MaintainOnce(ctx, spec))
+}
@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// ErrQuotaExceeded is returned when a write would take a manager past its Quota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota bounds what a StoreManager may hold. Zero fields are unlimited.
+type Quota struct {
+	MaxProjects   int   // Projects the manager may hold
+	MaxStoreBytes int64 // Bytes the stores under the data root may take on disk
+}
+
+// diskUsage caches the measured size of the data root, as walking it on every
+// write would cost more than the write.
+type diskUsage struct {
+	mu       sync.Mutex
+	bytes    int64
+	measured time.Time
+}
+
+// SetQuota sets the limits OpenProject and CheckStoreQuota enforce.
+func (sm *StoreManager) SetQuota(q Quota) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.quota = q
+}
+
+// CheckStoreQuota returns ErrQuotaExceeded when the stores under the data root
+// have grown to the manager's MaxStoreBytes. The size is measured at most once
+// per config.TenantUsageTTL, so a burst of writes may overshoot it slightly.
+func (sm *StoreManager) CheckStoreQuota() error {
+	sm.mu.Lock()
+	limit := sm.quota.MaxStoreBytes
+	sm.mu.Unlock()
+	if limit <= 0 {
+		return nil
+	}
+	used, err := sm.DiskUsage()
+	if err != nil {
+		return err
+	}
+	if used >= limit {
+		return fmt.Errorf("%w: stores use %d of %d bytes", ErrQuotaExceeded, used, limit)
+	}
+	return nil
+}
+
+// CheckNewProject returns ErrQuotaExceeded when projectID is not yet held and
+// adding it would take the manager past its quota. Writers outside the manager,
+// such as the ingest command, call it before creating a project's store.
+func (sm *StoreManager) CheckNewProject(projectID string) error {
+	if err := sm.CheckStoreQuota(); err != nil {
+		return err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if !sm.shared {
+		return sm.checkNewProjectDir(filepath.Join(sm.baseDir, projectID))
+	}
+	if sm.quota.MaxProjects <= 0 {
+		return nil // Don't open the store only to count its projects
+	}
+	s, err := sm.sharedStore()
+	if err != nil {
+		return err
+	}
+	return sm.checkNewSharedProject(s, projectID)
+}
+
+// DiskUsage returns the bytes the files under the data root take.
+func (sm *StoreManager) DiskUsage() (int64, error) {
+	sm.usage.mu.Lock()
+	defer sm.usage.mu.Unlock()
+	if !sm.usage.measured.IsZero() && time.Since(sm.usage.measured) < config.TenantUsageTTL {
+		return sm.usage.bytes, nil
+	}
+	var total int64
+	err := filepath.WalkDir(sm.baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Badger removes value logs and tables while it compacts
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s: %w", sm.baseDir, err)
+	}
+	sm.usage.bytes = total
+	sm.usage.measured = time.Now()
+	return total, nil
+}
+
+// checkNewProjectDir rejects creating projectDir when the manager already holds
+// MaxProjects projects. Callers hold sm.mu.
+func (sm *StoreManager) checkNewProjectDir(projectDir string) error {
+	if sm.quota.MaxProjects <= 0 {
+		return nil
+	}
+	if _, err := os.Stat(projectDir); err == nil {
+		return nil
+	}
+	entries, err := os.ReadDir(sm.baseDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ReadDir error on baseDir '%s': %v", sm.baseDir, err)
+	}
+	count := 0
+	for _, entry := range entries {
+		if entry.IsDir() && !isHiddenDir(entry.Name()) {
+			count++
+		}
+	}
+	if count >= sm.quota.MaxProjects {
+		return fmt.Errorf("%w: %d of %d projects", ErrQuotaExceeded, count, sm.quota.MaxProjects)
+	}
+	return nil
+}
+
+// checkNewSharedProject is checkNewProjectDir for a project of the shared store s.
+// Callers hold sm.mu.
+func (sm *StoreManager) checkNewSharedProject(s *meb.MEBStore, projectID string) error {
+	if sm.quota.MaxProjects <= 0 {
+		return nil
+	}
+	projects, err := gcamdb.RegisteredProjects(s)
+	if err != nil {
+		return err
+	}
+	for _, id := range projects {
+		if id == projectID {
+			return nil
+		}
+	}
+	if len(projects) >= sm.quota.MaxProjects {
+		return fmt.Errorf("%w: %d of %d projects", ErrQuotaExceeded, len(projects), sm.quota.MaxProjects)
+	}
+	return nil
+}
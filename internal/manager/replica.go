@@ -0,0 +1,227 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// ErrSharedSnapshot is returned when a snapshot is asked of a project that
+// shares its store with others.
+var ErrSharedSnapshot = errors.New("projects of a shared store are not snapshotted separately")
+
+// snapshotState is what a project's store held when its ETag was computed.
+type snapshotState struct {
+	facts    uint64
+	versions int
+	etag     string
+}
+
+// storeState reads the fact count and ingest version count of s.
+func storeState(s *meb.MEBStore) (snapshotState, error) {
+	versions, err := gcamdb.Versions(s)
+	if err != nil {
+		return snapshotState{}, err
+	}
+	return snapshotState{facts: s.Count(), versions: len(versions)}, nil
+}
+
+// SnapshotETag returns the ETag of projectID's store, the one a snapshot bundle
+// of it would carry. A writable store must be flushed, and so closed, for its
+// files to be hashed; that is only done when its fact count or ingest versions
+// changed since the last ETag, so replicas polling an unchanged store don't
+// disturb it, and waits for the stores' holders as Hold describes. The caller
+// must not hold the stores.
+func (sm *StoreManager) SnapshotETag(projectID string) (string, error) {
+	if sm.shared {
+		return "", fmt.Errorf("cannot snapshot project %s: %w", projectID, ErrSharedSnapshot)
+	}
+	s, err := sm.GetStore(projectID)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(sm.baseDir, projectID)
+	if sm.readOnly {
+		return gcamdb.SnapshotETag(dir)
+	}
+	// Read before the store is closed; a write landing after only makes the
+	// next poll hash the files again
+	state, err := storeState(s)
+	if err != nil {
+		return "", err
+	}
+	sm.mu.Lock()
+	last, ok := sm.snapshots[projectID]
+	sm.mu.Unlock()
+	if ok && last.facts == state.facts && last.versions == state.versions {
+		return last.etag, nil
+	}
+
+	sm.use.Lock()
+	defer sm.use.Unlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	// Eviction closes the store, flushing it to disk, and clears its dirty marker
+	sm.projects.Remove(projectID)
+	state.etag, err = gcamdb.SnapshotETag(dir)
+	if err != nil {
+		return "", err
+	}
+	sm.snapshots[projectID] = state
+	return state.etag, nil
+}
+
+// SnapshotProject runs fn on a copy of projectID's store, for making a snapshot
+// bundle of it, with the store's ETag and fact count. A writable store is
+// closed only while it is copied, once the stores' holders are done, so they
+// and other projects wait for the copy but not for fn; the copy is removed
+// when fn returns. The caller must not hold the stores. A read-only manager's stores are
+// not written to, so fn gets the store directory itself. Shared stores are not
+// snapshotted per project.
+func (sm *StoreManager) SnapshotProject(projectID string, fn func(dir, etag string, facts uint64) error) error {
+	if sm.shared {
+		return fmt.Errorf("cannot snapshot project %s: %w", projectID, ErrSharedSnapshot)
+	}
+	s, err := sm.GetStore(projectID)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(sm.baseDir, projectID)
+	if sm.readOnly {
+		etag, err := gcamdb.SnapshotETag(dir)
+		if err != nil {
+			return err
+		}
+		return fn(dir, etag, s.Count())
+	}
+	state, err := storeState(s)
+	if err != nil {
+		return err
+	}
+
+	// A hidden directory of the data root, so .sst files can be linked rather than copied
+	copyDir := filepath.Join(sm.baseDir, fmt.Sprintf(".%s.snapshot-%d", projectID, time.Now().UnixNano()))
+	defer os.RemoveAll(copyDir)
+	if err := sm.copyProject(projectID, dir, copyDir, &state); err != nil {
+		return err
+	}
+	return fn(copyDir, state.etag, state.facts)
+}
+
+// copyProject closes projectID's store and copies its directory to copyDir,
+// recording the ETag of the files copied in state and sm.snapshots.
+func (sm *StoreManager) copyProject(projectID, dir, copyDir string, state *snapshotState) error {
+	sm.use.Lock()
+	defer sm.use.Unlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.projects.Remove(projectID)
+	etag, err := gcamdb.SnapshotETag(dir)
+	if err != nil {
+		return err
+	}
+	if err := copyStoreDir(dir, copyDir); err != nil {
+		return fmt.Errorf("failed to copy store for project %s: %w", projectID, err)
+	}
+	state.etag = etag
+	sm.snapshots[projectID] = *state
+	return nil
+}
+
+// copyStoreDir copies the store directory src to dst, keeping modification
+// times. Badger never rewrites a table once written, so .sst files are
+// hard-linked; the value log, manifest and the rest are copied.
+func copyStoreDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if filepath.Ext(p) == ".sst" && os.Link(p, target) == nil {
+			return nil
+		}
+		return copyFile(p, target)
+	})
+}
+
+// copyFile copies the regular file src to dst with its modification time.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// ReplaceProject makes the store in dir, such as one extracted from a snapshot
+// bundle, the store of projectID. dir must be on the same filesystem as the
+// manager's data root. The project's open store is closed first, once the
+// stores' holders are done; requests that come in meanwhile wait, then open the
+// new store. The caller must not hold the stores.
+func (sm *StoreManager) ReplaceProject(projectID, dir string) error {
+	sm.use.Lock()
+	defer sm.use.Unlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.projects.Remove(projectID)
+	sm.cachedList = nil
+	delete(sm.snapshots, projectID)
+
+	target := filepath.Join(sm.baseDir, projectID)
+	old := filepath.Join(sm.baseDir, fmt.Sprintf(".%s.old-%d", projectID, time.Now().UnixNano()))
+	if err := os.Rename(target, old); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to move aside store for project %s: %w", projectID, err)
+	}
+	if err := os.Rename(dir, target); err != nil {
+		// Put the previous store back so the project stays servable
+		if rerr := os.Rename(old, target); rerr != nil && !os.IsNotExist(rerr) {
+			return fmt.Errorf("failed to install store for project %s: %w (and to restore the previous one: %v)", projectID, err, rerr)
+		}
+		return fmt.Errorf("failed to install store for project %s: %w", projectID, err)
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return fmt.Errorf("failed to remove previous store for project %s: %w", projectID, err)
+	}
+	return nil
+}
+
+// isHiddenDir reports whether name is a directory of the data root that holds
+// no project, such as a store being replaced.
+func isHiddenDir(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestStoreManager_Snapshot(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(tmpDir, "p1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	sm := NewStoreManager(tmpDir, MemoryProfileLow, false)
+	defer sm.CloseAll()
+	s1, err := sm.GetStore("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.AddFact(meb.Fact{Subject: "a.go:A", Predicate: config.PredicateCalls, Object: "b.go:B"}); err != nil {
+		t.Fatal(err)
+	}
+
+	etag, err := sm.SnapshotETag("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err := sm.GetStore("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An unchanged store keeps its ETag and stays open
+	if again, err := sm.SnapshotETag("p1"); err != nil || again != etag {
+		t.Errorf("SnapshotETag of an unchanged store = %s, %v; want %s", again, err, etag)
+	}
+	if s2, _ := sm.GetStore("p1"); s2 != open {
+		t.Error("SnapshotETag closed an unchanged store")
+	}
+
+	if err := open.AddFact(meb.Fact{Subject: "b.go:B", Predicate: config.PredicateCalls, Object: "c.go:C"}); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := sm.SnapshotETag("p1")
+	if err != nil || changed == etag {
+		t.Errorf("SnapshotETag after a write = %s, %v; want a new ETag", changed, err)
+	}
+
+	var copyDir string
+	err = sm.SnapshotProject("p1", func(dir, etag string, facts uint64) error {
+		copyDir = dir
+		if dir == filepath.Join(tmpDir, "p1") {
+			t.Error("SnapshotProject ran fn on the live store directory")
+		}
+		if facts != 2 {
+			t.Errorf("snapshot facts = %d, want 2", facts)
+		}
+		// The manager is not held while the bundle streams
+		if _, err := sm.GetStore("p1"); err != nil {
+			t.Errorf("GetStore during a snapshot: %v", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) == 0 {
+			t.Errorf("snapshot copy holds %d files, %v", len(entries), err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(copyDir); !os.IsNotExist(err) {
+		t.Errorf("snapshot copy %s left behind: %v", copyDir, err)
+	}
+	projects, err := sm.ListProjects()
+	if err != nil || len(projects) != 1 {
+		t.Errorf("ListProjects = %v, %v; want the one project", projects, err)
+	}
+}
+
+func TestStoreManager_SnapshotWaitsForHolders(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewStoreManager(tmpDir, MemoryProfileLow, false)
+	defer sm.CloseAll()
+	s, release, err := sm.HoldProject("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sm.SnapshotProject("p1", func(dir, etag string, facts uint64) error { return nil })
+	}()
+	// A holder keeps writing while the snapshot waits for it
+	time.Sleep(50 * time.Millisecond)
+	if err := s.AddFact(meb.Fact{Subject: "a.go:A", Predicate: config.PredicateCalls, Object: "b.go:B"}); err != nil {
+		t.Fatalf("AddFact while held: %v", err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("SnapshotProject returned while the store was held: %v", err)
+	default:
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if reopened, err := sm.GetStore("p1"); err != nil || reopened.Count() != 1 {
+		t.Errorf("store after the snapshot: %v; want the held write kept", err)
+	}
+}
@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// Sweep deletes the expired facts of the open stores every
+// config.RetentionSweepInterval, see gcamdb.SweepExpired. A read-only manager
+// has nothing to sweep. It returns ctx's error once ctx is done.
+func (sm *StoreManager) Sweep(ctx context.Context) error {
+	if sm.readOnly {
+		return nil
+	}
+	ticker := time.NewTicker(config.RetentionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			sm.SweepOnce(ctx, now)
+		}
+	}
+}
+
+// SweepOnce deletes the facts of the open stores that expired by now and
+// returns how many it deleted, by store cache key. Failures are logged and
+// skipped.
+func (sm *StoreManager) SweepOnce(ctx context.Context, now time.Time) map[string]int {
+	swept := make(map[string]int)
+	if sm.readOnly {
+		return swept
+	}
+	sm.mu.Lock()
+	keys := sm.projects.Keys()
+	sm.mu.Unlock()
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		release := sm.Hold()
+		sm.mu.Lock()
+		s, ok := sm.projects.Peek(key)
+		sm.mu.Unlock()
+		if !ok {
+			release()
+			continue
+		}
+		report, err := gcamdb.SweepExpired(ctx, s, now)
+		release()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Retention sweep failed for project %s: %v", key, err)
+			}
+			continue
+		}
+		if report.Facts > 0 {
+			log.Printf("Deleted %d expired facts of project %s (%d still due)", report.Facts, key, report.Remaining)
+			swept[key] = report.Facts
+		}
+	}
+	return swept
+}
@@ -7,10 +7,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/gca/pkg/telemetry"
 	"github.com/duynguyendang/meb"
 	"github.com/duynguyendang/meb/store"
@@ -25,6 +27,10 @@ type ProjectMetadata struct {
 	Version     string `json:"version,omitempty"`
 }
 
+// ServingProfile is the Badger profile stores are opened with, tuned for
+// long-running servers. Servers warm its stores up with Warmup.
+const ServingProfile = "Safe-Serving"
+
 // CurrentSchemaVersion is the current version of the knowledge schema.
 // Bump this when breaking changes require re-ingestion.
 const CurrentSchemaVersion = "2.0"
@@ -41,16 +47,26 @@ const (
 	WindowMaxFacts                     = 500_000   // 500K facts window limit
 )
 
+// sharedStoreKey is the cache key of the single store opened in shared mode; it
+// also makes the eviction callback clear the marker in baseDir itself.
+const sharedStoreKey = ""
+
 // StoreManager manages multiple MEBStore instances.
 type StoreManager struct {
 	baseDir       string
 	projects      *lru.Cache[string, *meb.MEBStore]
-	mu            sync.Mutex // Protects all access to projects cache
+	mu            sync.Mutex   // Protects all access to projects cache
+	use           sync.RWMutex // Held shared by the stores' users, exclusively to close a store under them; see Hold
 	profile       MemoryProfile
 	readOnly      bool
+	shared        bool // baseDir is one store holding every project
 	cachedList    []ProjectMetadata
 	lastListBuild time.Time
 	telemetrySink meb.TelemetrySink
+	quota         Quota
+	usage         diskUsage
+	lastUsed      map[string]time.Time     // When each store was last handed out, by cache key
+	snapshots     map[string]snapshotState // Each project's store when its snapshot ETag was last computed
 }
 
 // NewStoreManager creates a new StoreManager.
@@ -58,7 +74,18 @@ func NewStoreManager(baseDir string, profile MemoryProfile, readOnly bool) *Stor
 	// Create LRU cache with eviction callback to close stores
 	// Note: All access to this cache must be protected by StoreManager.mu
 	cache, _ := lru.NewWithEvict[string, *meb.MEBStore](MaxOpenStores, func(key string, value *meb.MEBStore) {
-		_ = value.Close()
+		gcamdb.DropHotCache(value)
+		gcamdb.DropCatalog(value)
+		if err := value.Close(); err != nil {
+			log.Printf("Failed to close store for project %s: %v", key, err)
+			return
+		}
+		// A clean close has persisted the fact count; the marker is only cleared then
+		if !readOnly {
+			if err := gcamdb.MarkClean(filepath.Join(baseDir, key)); err != nil {
+				log.Printf("Failed to clear dirty marker for project %s: %v", key, err)
+			}
+		}
 	})
 
 	return &StoreManager{
@@ -67,7 +94,53 @@ func NewStoreManager(baseDir string, profile MemoryProfile, readOnly bool) *Stor
 		profile:       profile,
 		readOnly:      readOnly,
 		telemetrySink: telemetry.NewLoggerSink(),
+		lastUsed:      make(map[string]time.Time),
+		snapshots:     make(map[string]snapshotState),
+	}
+}
+
+// NewSharedStoreManager creates a StoreManager for a single store at dir into
+// which several projects were ingested. Projects are listed from the store's
+// registry and queries against them must be scoped with ProjectTopic.
+func NewSharedStoreManager(dir string, profile MemoryProfile, readOnly bool) *StoreManager {
+	sm := NewStoreManager(dir, profile, readOnly)
+	sm.shared = true
+	return sm
+}
+
+// Profile returns the memory profile the manager opens stores with.
+func (sm *StoreManager) Profile() MemoryProfile {
+	return sm.profile
+}
+
+// ReadOnly reports whether the manager opens its stores read-only.
+func (sm *StoreManager) ReadOnly() bool {
+	return sm.readOnly
+}
+
+// HotCacheStats returns the hot cache stats of the open stores, by project.
+func (sm *StoreManager) HotCacheStats() map[string]gcamdb.HotCacheStats {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	stats := make(map[string]gcamdb.HotCacheStats, sm.projects.Len())
+	for _, id := range sm.projects.Keys() {
+		if s, ok := sm.projects.Peek(id); ok {
+			stats[id] = gcamdb.HotCacheOf(s).Stats()
+		}
 	}
+	return stats
+}
+
+// BaseDir returns the data root the manager's stores live under.
+func (sm *StoreManager) BaseDir() string {
+	return sm.baseDir
+}
+
+// ProjectTopic returns the topic projectID was ingested under and whether the
+// project shares its store with others, in which case its queries must be
+// scoped to that topic.
+func (sm *StoreManager) ProjectTopic(projectID string) (uint32, bool) {
+	return gcamdb.TopicForProject(projectID), sm.shared
 }
 
 // GetStore retrieves a store by project ID, opening it if necessary.
@@ -75,8 +148,25 @@ func (sm *StoreManager) GetStore(projectID string) (*meb.MEBStore, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	if sm.shared {
+		s, err := sm.sharedStore()
+		if err != nil {
+			return nil, err
+		}
+		projects, err := gcamdb.RegisteredProjects(s)
+		if err != nil {
+			return nil, err
+		}
+		if i := sort.SearchStrings(projects, projectID); i == len(projects) || projects[i] != projectID {
+			return nil, fmt.Errorf("project not found: %s", projectID)
+		}
+		sm.lastUsed[sharedStoreKey] = time.Now()
+		return s, nil
+	}
+
 	// Check if exists in LRU (under lock for thread safety)
 	if s, ok := sm.projects.Get(projectID); ok {
+		sm.lastUsed[projectID] = time.Now()
 		return s, nil
 	}
 
@@ -85,19 +175,118 @@ func (sm *StoreManager) GetStore(projectID string) (*meb.MEBStore, error) {
 		return nil, fmt.Errorf("project not found: %s", projectID)
 	}
 
+	s, err := sm.openStore(projectDir, projectID)
+	if err != nil {
+		return nil, err
+	}
+	sm.projects.Add(projectID, s)
+	sm.lastUsed[projectID] = time.Now()
+	return s, nil
+}
+
+// OpenProject is GetStore for a writer that may create the project, such as
+// a watch-mode ingest: the project's store is created when missing, and in a
+// shared store the project need not be registered yet.
+func (sm *StoreManager) OpenProject(projectID string) (*meb.MEBStore, error) {
+	if sm.readOnly {
+		return nil, fmt.Errorf("cannot open project %s for writing: store manager is read-only", projectID)
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.cachedList = nil // The project may be new
+
+	if sm.shared {
+		s, err := sm.sharedStore()
+		if err != nil {
+			return nil, err
+		}
+		if err := sm.checkNewSharedProject(s, projectID); err != nil {
+			return nil, err
+		}
+		sm.lastUsed[sharedStoreKey] = time.Now()
+		return s, nil
+	}
+	if s, ok := sm.projects.Get(projectID); ok {
+		sm.lastUsed[projectID] = time.Now()
+		return s, nil
+	}
+	projectDir := filepath.Join(sm.baseDir, projectID)
+	if err := sm.checkNewProjectDir(projectDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store for project %s: %w", projectID, err)
+	}
+	s, err := sm.openStore(projectDir, projectID)
+	if err != nil {
+		return nil, err
+	}
+	sm.projects.Add(projectID, s)
+	sm.lastUsed[projectID] = time.Now()
+	return s, nil
+}
+
+// Hold keeps the manager's stores from being closed under the caller, by a
+// snapshot or a replacement, until release is called; those wait for every
+// holder to be done, and hold back new holders while they run. Requests,
+// ingests, sweeps and maintenance hold the stores while they use them. Holds
+// are not reentrant: a holder must not Hold again, nor snapshot or replace a
+// project.
+func (sm *StoreManager) Hold() (release func()) {
+	sm.use.RLock()
+	return sm.use.RUnlock
+}
+
+// HoldExclusive waits for every holder of the stores to be done and holds back
+// new ones until release is called, for work no write may overlap, such as
+// compacting a dictionary. The caller must not hold the stores.
+func (sm *StoreManager) HoldExclusive() (release func()) {
+	sm.use.Lock()
+	return sm.use.Unlock
+}
+
+// HoldProject is OpenProject for a writer that holds the stores, as Hold does,
+// until it calls release.
+func (sm *StoreManager) HoldProject(projectID string) (s *meb.MEBStore, release func(), err error) {
+	release = sm.Hold()
+	s, err = sm.OpenProject(projectID)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return s, release, nil
+}
+
+// sharedStore returns the store at baseDir, opening it on first use. Callers hold sm.mu.
+func (sm *StoreManager) sharedStore() (*meb.MEBStore, error) {
+	if s, ok := sm.projects.Get(sharedStoreKey); ok {
+		return s, nil
+	}
+	s, err := sm.openStore(sm.baseDir, sharedStoreKey)
+	if err != nil {
+		return nil, err
+	}
+	sm.projects.Add(sharedStoreKey, s)
+	return s, nil
+}
+
+// openStore opens the store in projectDir with the manager's profile.
+func (sm *StoreManager) openStore(projectDir, projectID string) (*meb.MEBStore, error) {
 	// Open in ReadOnly mode if configured
 	cfg := store.DefaultConfig(projectDir)
 	cfg.ReadOnly = sm.readOnly
 
 	// Apply Memory Profile
+	hotCacheBudget := int64(config.HotCacheBudget)
 	if sm.profile == MemoryProfileLow {
 		cfg.BlockCacheSize = 64 << 20 // 64 MB
 		cfg.IndexCacheSize = 64 << 20 // 64 MB
-		cfg.Profile = "Safe-Serving"
+		cfg.Profile = ServingProfile
+		hotCacheBudget = config.HotCacheBudgetLow
 	} else {
 		cfg.BlockCacheSize = 128 << 20 // 128 MB (Still small)
 		cfg.IndexCacheSize = 128 << 20 // 128 MB
-		cfg.Profile = "Safe-Serving"
+		cfg.Profile = ServingProfile
 	}
 
 	// Enable auto-GC for long-running server mode
@@ -110,12 +299,34 @@ func (sm *StoreManager) GetStore(projectID string) (*meb.MEBStore, error) {
 		return nil, fmt.Errorf("failed to open store for project %s: %w", projectID, err)
 	}
 
+	// Reconcile the fact count if the previous writer crashed before closing.
+	// Readers leave that to writers: the marker may be a live writer's, and a
+	// read-only store cannot save the count.
+	if !sm.readOnly {
+		if recovered, err := gcamdb.Recover(s, projectDir); err != nil {
+			log.Printf("Fact count recovery failed for project %s: %v", projectID, err)
+		} else if recovered {
+			log.Printf("Recovered fact count for project %s after unclean shutdown (count=%d)", projectID, s.Count())
+		}
+		if err := gcamdb.MarkDirty(projectDir); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to mark store for project %s as open: %w", projectID, err)
+		}
+	}
+
 	// Set TopicID for project-scoped queries
 	// Uses a hash of the project name to generate a unique 24-bit topic ID
 	// This must be set before any query operations to ensure correct data filtering
-	topicID := hashToTopicID(projectID)
+	topicID := gcamdb.TopicForProject(projectID)
 	s.SetTopicID(topicID)
 
+	// Bring stores written by an older gca up to the current format; a reader
+	// can only warn, and must refuse stores of a newer one
+	if err := sm.migrateStore(s, projectID); err != nil {
+		s.Close()
+		return nil, err
+	}
+
 	// Register telemetry sink
 	s.RegisterTelemetrySink(sm.telemetrySink)
 	log.Printf("Registered telemetry sink for project %s (topicID=%d)", projectID, topicID)
@@ -125,10 +336,40 @@ func (sm *StoreManager) GetStore(projectID string) (*meb.MEBStore, error) {
 		return nil, fmt.Errorf("failed to set retention for project %s: %w", projectID, err)
 	}
 
-	sm.projects.Add(projectID, s)
+	// Keep the facts and documents of the most-read subjects decoded in memory
+	gcamdb.EnableHotCache(s, hotCacheBudget, sm.readOnly)
 	return s, nil
 }
 
+// migrateStore runs the pending format migrations of a store the manager
+// opened, or only checks for them when it is read-only.
+func (sm *StoreManager) migrateStore(s *meb.MEBStore, projectID string) error {
+	pending, err := gcamdb.PendingMigrations(s)
+	if err != nil {
+		return fmt.Errorf("failed to check store format for project %s: %w", projectID, err)
+	}
+	if sm.readOnly {
+		if len(pending) > 0 {
+			log.Printf("Store for project %s needs %d format migrations; open it for writing (gca migrate) to run them", projectID, len(pending))
+		}
+		return nil
+	}
+	project := projectID
+	if sm.shared {
+		project = ""
+	}
+	report, err := gcamdb.Migrate(context.Background(), s, project, func(p gcamdb.MigrationProgress) {
+		log.Printf("Migrating store for project %s: %d %s (%d/%d)", projectID, p.Version, p.Name, p.Done, p.Total)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate store for project %s: %w", projectID, err)
+	}
+	if len(report.Applied) > 0 {
+		log.Printf("Migrated store for project %s from format %d to %d", projectID, report.From, report.To)
+	}
+	return nil
+}
+
 // ListProjects returns a list of available projects.
 func (sm *StoreManager) ListProjects() ([]ProjectMetadata, error) {
 	sm.mu.Lock()
@@ -140,6 +381,44 @@ func (sm *StoreManager) ListProjects() ([]ProjectMetadata, error) {
 		return list, nil
 	}
 
+	var projects []ProjectMetadata
+	var err error
+	if sm.shared {
+		projects, err = sm.listSharedProjects()
+	} else {
+		projects, err = sm.listProjectDirs()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sm.cachedList = projects
+	sm.lastListBuild = time.Now()
+
+	list := make([]ProjectMetadata, len(projects))
+	copy(list, projects)
+	return list, nil
+}
+
+// listSharedProjects lists the projects registered in the shared store.
+func (sm *StoreManager) listSharedProjects() ([]ProjectMetadata, error) {
+	s, err := sm.sharedStore()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := gcamdb.RegisteredProjects(s)
+	if err != nil {
+		return nil, err
+	}
+	projects := make([]ProjectMetadata, 0, len(ids))
+	for _, id := range ids {
+		projects = append(projects, ProjectMetadata{ID: id, Name: id})
+	}
+	return projects, nil
+}
+
+// listProjectDirs lists one project per store directory under baseDir.
+func (sm *StoreManager) listProjectDirs() ([]ProjectMetadata, error) {
 	entries, err := os.ReadDir(sm.baseDir)
 	if err != nil {
 		return nil, fmt.Errorf("ReadDir error on baseDir '%s': %v", sm.baseDir, err)
@@ -147,7 +426,7 @@ func (sm *StoreManager) ListProjects() ([]ProjectMetadata, error) {
 
 	var projects []ProjectMetadata
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() && !isHiddenDir(entry.Name()) && !isStoreDir(sm.baseDir, entry.Name()) {
 			id := entry.Name()
 			meta := ProjectMetadata{
 				ID:   id,
@@ -168,13 +447,17 @@ func (sm *StoreManager) ListProjects() ([]ProjectMetadata, error) {
 			projects = append(projects, meta)
 		}
 	}
+	return projects, nil
+}
 
-	sm.cachedList = projects
-	sm.lastListBuild = time.Now()
-
-	list := make([]ProjectMetadata, len(projects))
-	copy(list, projects)
-	return list, nil
+// isStoreDir reports whether name is the Badger directory of a store kept in
+// dir itself, rather than the folder of a project named badger.
+func isStoreDir(dir, name string) bool {
+	if name != "badger" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, name, "MANIFEST"))
+	return err == nil
 }
 
 // CloseAll closes all open stores.
@@ -245,27 +528,14 @@ func (sm *StoreManager) SetProjectVersion(projectID, version string) error {
 	return os.WriteFile(metaPath, newData, 0644)
 }
 
-// hashToTopicID generates a deterministic 24-bit topic ID from a project name.
-func hashToTopicID(name string) uint32 {
-	if name == "" {
-		return 1
-	}
-	var h uint32 = 2166136261 // FNV-1a offset basis
-	for i := 0; i < len(name); i++ {
-		h ^= uint32(name[i])
-		h *= 16777619 // FNV-1a prime
-	}
-	return (h & 0xFFFFFF) | 1 // ensure non-zero (0 is reserved)
-}
-
 // GlobalTopicID returns the Attention Sink topic ID for a project (permanent storage).
 // Uses the base hash with high bit clear for Global partition.
 func GlobalTopicID(projectID string) uint32 {
-	return hashToTopicID(projectID) & 0x7FFFFF // clear high bit
+	return gcamdb.TopicForProject(projectID) & 0x7FFFFF // clear high bit
 }
 
 // WindowTopicID returns the Sliding Window topic ID for a project (temporary storage).
 // Uses the base hash with high bit set for Window partition.
 func WindowTopicID(projectID string) uint32 {
-	return hashToTopicID(projectID) | 0x800000 // set high bit
+	return gcamdb.TopicForProject(projectID) | 0x800000 // set high bit
 }
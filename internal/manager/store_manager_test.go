@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/meb"
 	"github.com/duynguyendang/meb/store"
 )
@@ -111,3 +112,82 @@ func TestStoreManager_ListProjects_Caching(t *testing.T) {
 		t.Errorf("Expected refreshed projects (2), got %d", len(projects))
 	}
 }
+
+func TestStoreManager_ListProjects_SkipsOwnStore(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A store's own Badger directory, and a project folder named badger
+	os.MkdirAll(filepath.Join(tmpDir, "badger"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "badger", "MANIFEST"), nil, 0644)
+	sm := NewStoreManager(tmpDir, MemoryProfileDefault, true)
+	projects, err := sm.ListProjects()
+	if err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	if len(projects) != 0 {
+		t.Errorf("Expected the store's badger directory skipped, got %v", projects)
+	}
+
+	other := t.TempDir()
+	os.MkdirAll(filepath.Join(other, "badger", "badger"), 0755)
+	projects, err = NewStoreManager(other, MemoryProfileDefault, true).ListProjects()
+	if err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	if len(projects) != 1 || projects[0].ID != "badger" {
+		t.Errorf("Expected project badger, got %v", projects)
+	}
+}
+
+func TestStoreManager_DirtyMarker(t *testing.T) {
+	tmpDir := t.TempDir()
+	pDir := filepath.Join(tmpDir, "p1")
+	s, err := meb.NewMEBStore(store.DefaultConfig(pDir))
+	if err != nil {
+		t.Fatalf("Failed to init store: %v", err)
+	}
+	facts := []meb.Fact{
+		{Subject: "a.go", Predicate: "defines", Object: "a.go:A"},
+		{Subject: "a.go", Predicate: "defines", Object: "a.go:B"},
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatalf("AddFactBatch failed: %v", err)
+	}
+	s.Close()
+
+	// Simulate a writer that never reached a clean close
+	marker := filepath.Join(pDir, config.DirtyMarkerFile)
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+
+	sm := NewStoreManager(tmpDir, MemoryProfileLow, false)
+	s1, err := sm.GetStore("p1")
+	if err != nil {
+		t.Fatalf("Failed to get p1: %v", err)
+	}
+	if got := s1.Count(); got != uint64(len(facts)) {
+		t.Errorf("Count() after recovery = %d, want %d", got, len(facts))
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("Expected dirty marker while the store is open: %v", err)
+	}
+
+	sm.CloseAll()
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("Expected dirty marker removed after clean close, stat err = %v", err)
+	}
+
+	// A reader leaves a writer's marker, and the recount, to writers
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+	reader := NewStoreManager(tmpDir, MemoryProfileLow, true)
+	if _, err := reader.GetStore("p1"); err != nil {
+		t.Fatalf("Failed to get p1 read-only: %v", err)
+	}
+	reader.CloseAll()
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("Expected a reader to keep the dirty marker: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tenantIDPattern keeps tenant IDs usable as a path segment and a header value.
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// Tenant is one team served by a multi-tenant server: its own data root, the
+// API keys that may read it and the quota its stores are held to.
+type Tenant struct {
+	ID            string   `yaml:"id"`
+	DataDir       string   `yaml:"data_dir"`
+	APIKeys       []string `yaml:"api_keys"`
+	SharedStore   bool     `yaml:"shared_store"`
+	MaxProjects   int      `yaml:"max_projects"`
+	MaxStoreBytes int64    `yaml:"max_store_bytes"`
+}
+
+// TenantsFile is the layout of the file given to server --tenants.
+type TenantsFile struct {
+	Tenants []Tenant `yaml:"tenants"`
+}
+
+// LoadTenants reads and validates a tenants file. Relative data directories
+// are resolved against the file's directory.
+func LoadTenants(path string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+	var file TenantsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file %s: %w", path, err)
+	}
+	if len(file.Tenants) == 0 {
+		return nil, fmt.Errorf("tenants file %s lists no tenants", path)
+	}
+
+	ids := make(map[string]bool, len(file.Tenants))
+	dirs := make(map[string]string, len(file.Tenants))
+	for i := range file.Tenants {
+		t := &file.Tenants[i]
+		if !tenantIDPattern.MatchString(t.ID) {
+			return nil, fmt.Errorf("tenant %d: invalid id %q", i, t.ID)
+		}
+		if ids[t.ID] {
+			return nil, fmt.Errorf("tenant %s is listed twice", t.ID)
+		}
+		ids[t.ID] = true
+		if t.DataDir == "" {
+			return nil, fmt.Errorf("tenant %s: data_dir is required", t.ID)
+		}
+		if !filepath.IsAbs(t.DataDir) {
+			t.DataDir = filepath.Join(filepath.Dir(path), t.DataDir)
+		}
+		t.DataDir = filepath.Clean(t.DataDir)
+		if other, ok := dirs[t.DataDir]; ok {
+			return nil, fmt.Errorf("tenants %s and %s share data_dir %s", other, t.ID, t.DataDir)
+		}
+		dirs[t.DataDir] = t.ID
+		if len(t.APIKeys) == 0 {
+			return nil, fmt.Errorf("tenant %s: at least one api key is required", t.ID)
+		}
+		for _, key := range t.APIKeys {
+			if key == "" {
+				return nil, fmt.Errorf("tenant %s: empty api key", t.ID)
+			}
+		}
+		if t.MaxProjects < 0 || t.MaxStoreBytes < 0 {
+			return nil, fmt.Errorf("tenant %s: quotas must not be negative", t.ID)
+		}
+	}
+	return file.Tenants, nil
+}
+
+// NewTenantStoreManager creates the StoreManager for t's data root, held to t's quota.
+func NewTenantStoreManager(t Tenant, profile MemoryProfile, readOnly bool) *StoreManager {
+	newManager := NewStoreManager
+	if t.SharedStore {
+		newManager = NewSharedStoreManager
+	}
+	sm := newManager(t.DataDir, profile, readOnly)
+	sm.SetQuota(Quota{MaxProjects: t.MaxProjects, MaxStoreBytes: t.MaxStoreBytes})
+	return sm
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTenants(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tenants.yaml")
+	yaml := `tenants:
+  - id: acme
+    data_dir: data/acme
+    api_keys: [k1, k2]
+    max_projects: 2
+  - id: globex
+    data_dir: /srv/globex
+    api_keys: [k3]
+    shared_store: true
+    max_store_bytes: 1048576
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := LoadTenants(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 2 {
+		t.Fatalf("LoadTenants = %d tenants, want 2", len(tenants))
+	}
+	if want := filepath.Join(dir, "data", "acme"); tenants[0].DataDir != want {
+		t.Errorf("acme data_dir = %q, want %q", tenants[0].DataDir, want)
+	}
+	if tenants[0].MaxProjects != 2 || len(tenants[0].APIKeys) != 2 {
+		t.Errorf("acme = %+v", tenants[0])
+	}
+	if !tenants[1].SharedStore || tenants[1].MaxStoreBytes != 1<<20 || tenants[1].DataDir != "/srv/globex" {
+		t.Errorf("globex = %+v", tenants[1])
+	}
+
+	for name, bad := range map[string]string{
+		"no keys":        "tenants:\n  - id: acme\n    data_dir: a\n",
+		"bad id":         "tenants:\n  - id: ../acme\n    data_dir: a\n    api_keys: [k]\n",
+		"duplicate id":   "tenants:\n  - id: acme\n    data_dir: a\n    api_keys: [k]\n  - id: acme\n    data_dir: b\n    api_keys: [k]\n",
+		"shared dir":     "tenants:\n  - id: acme\n    data_dir: a\n    api_keys: [k]\n  - id: globex\n    data_dir: a\n    api_keys: [k]\n",
+		"no tenants":     "tenants: []\n",
+		"negative quota": "tenants:\n  - id: acme\n    data_dir: a\n    api_keys: [k]\n    max_projects: -1\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTenants(path); err == nil {
+			t.Errorf("LoadTenants(%s) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestStoreManager_Quota(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewStoreManager(dir, MemoryProfileLow, false)
+	defer sm.CloseAll()
+	sm.SetQuota(Quota{MaxProjects: 1})
+
+	if _, err := sm.OpenProject("p1"); err != nil {
+		t.Fatalf("OpenProject(p1) = %v", err)
+	}
+	if _, err := sm.OpenProject("p1"); err != nil {
+		t.Errorf("reopening p1 = %v, want it allowed", err)
+	}
+	if _, err := sm.OpenProject("p2"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("OpenProject(p2) = %v, want ErrQuotaExceeded", err)
+	}
+	if err := sm.CheckNewProject("p2"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("CheckNewProject(p2) = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "p2")); !os.IsNotExist(err) {
+		t.Errorf("refused project p2 has a directory: %v", err)
+	}
+
+	if err := sm.CheckStoreQuota(); err != nil {
+		t.Errorf("CheckStoreQuota without a size limit = %v", err)
+	}
+	sm.SetQuota(Quota{MaxStoreBytes: 1})
+	if err := sm.CheckStoreQuota(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("CheckStoreQuota over the size limit = %v, want ErrQuotaExceeded", err)
+	}
+}
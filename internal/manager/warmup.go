@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"context"
+	"log"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// WarmupSpec returns how much of each store Warmup reads under the manager's
+// memory profile. The low memory profile skips embeddings, whose reads would
+// fill its smaller caches.
+func (sm *StoreManager) WarmupSpec() gcamdb.WarmupSpec {
+	if sm.profile == MemoryProfileLow {
+		return gcamdb.WarmupSpec{Nodes: config.WarmupNodesLow, Content: true}
+	}
+	return gcamdb.WarmupSpec{Nodes: config.WarmupNodes, Content: true, Vectors: true}
+}
+
+// Warmup opens the stores of the first MaxOpenStores projects, as many as stay
+// open together, and warms each up with gcamdb.Warmup, so that the first
+// queries after a server starts don't pay for cold caches. Projects that fail
+// to open or warm up are logged and skipped; only ctx's end stops it early.
+func (sm *StoreManager) Warmup(ctx context.Context) error {
+	projects, err := sm.ListProjects()
+	if err != nil {
+		return err
+	}
+	spec := sm.WarmupSpec()
+	for _, p := range projects[:min(len(projects), MaxOpenStores)] {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s, err := sm.GetStore(p.ID)
+		if err != nil {
+			log.Printf("Warmup skipped project %s: %v", p.ID, err)
+			continue
+		}
+		projectCtx := ctx
+		if topic, scoped := sm.ProjectTopic(p.ID); scoped {
+			projectCtx = gcamdb.WithScope(ctx, gcamdb.Scope{Project: p.ID, Topic: topic})
+		}
+		stats, err := gcamdb.Warmup(projectCtx, s, spec)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Warmup failed for project %s: %v", p.ID, err)
+			continue
+		}
+		log.Printf("Warmed up project %s: %d nodes, %d facts, %d documents, %d vectors in %v",
+			p.ID, stats.Nodes, stats.Facts, stats.Documents, stats.Vectors, stats.Took)
+	}
+	return nil
+}
@@ -141,7 +141,7 @@ func (e *Executor) hydrateResults(ctx context.Context, results []map[string]any,
 			node.Name = parts[len(parts)-1]
 		}
 
-		content, err := e.store.GetContentByKey(id)
+		content, err := gcamdb.GetDocument(e.store, id)
 
 		if err == nil && len(content) > 0 {
 			code := string(content)
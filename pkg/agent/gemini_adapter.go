@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"time"
 
+	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
 )
@@ -43,7 +43,7 @@ func NewGeminiAdapter(ctx context.Context) (*GeminiAdapter, error) {
 
 // GenerateContent sends a prompt to the LLM and returns the text response.
 func (a *GeminiAdapter) GenerateContent(ctx context.Context, prompt string) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, config.AIRequestTimeout)
 	defer cancel()
 
 	resp, err := genkit.Generate(ctx, a.g,
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/duynguyendang/gca/pkg/common/deadline"
 	"github.com/duynguyendang/gca/pkg/logger"
 	"github.com/duynguyendang/meb"
 	"github.com/google/uuid"
@@ -27,17 +28,19 @@ func NewOrchestrator(model ModelAdapter, store *meb.MEBStore) *Orchestrator {
 }
 
 // Run executes the full agent pipeline and returns the completed session.
-func (o *Orchestrator) Run(ctx context.Context, projectID, query string, predicates []string) (*ExecutionSession, error) {
+// entryPoints are the project's entry point IDs, given to the planner as context.
+func (o *Orchestrator) Run(ctx context.Context, projectID, query string, predicates, entryPoints []string) (*ExecutionSession, error) {
 	sessionID := uuid.New().String()
 	session := NewExecutionSession(sessionID, projectID, query)
 
 	logger.Info("Agent/Orchestrator Starting session", "sessionID", sessionID, "projectID", projectID)
 
 	// Phase 1: Plan
+	deadline.Enter(ctx, "agent planning")
 	planCtx, planCancel := context.WithTimeout(ctx, 30*time.Second)
 	defer planCancel()
 
-	steps, err := o.planner.Plan(planCtx, query, predicates)
+	steps, err := o.planner.Plan(planCtx, query, predicates, entryPoints)
 	if err != nil {
 		return nil, fmt.Errorf("planning failed: %w", err)
 	}
@@ -49,12 +52,14 @@ func (o *Orchestrator) Run(ctx context.Context, projectID, query string, predica
 	logger.Debug("Agent/Orchestrator Plan generated", "steps", len(steps))
 
 	// Phase 2: Execute all steps
+	deadline.Enter(ctx, "agent step execution")
 	if err := o.executor.ExecuteAllSteps(ctx, session); err != nil {
 		logger.Error("Agent/Orchestrator Execution completed with errors", "error", err)
 		// Continue to narrative synthesis even with partial failures
 	}
 
 	// Phase 3: Synthesize narrative
+	deadline.Enter(ctx, "agent narration")
 	narrCtx, narrCancel := context.WithTimeout(ctx, 30*time.Second)
 	defer narrCancel()
 
@@ -35,10 +35,14 @@ func NewPlanner(model ModelAdapter) *Planner {
 	return &Planner{model: model}
 }
 
+// maxPlannerEntryPoints caps the entry points listed in the planner prompt.
+const maxPlannerEntryPoints = 30
+
 // Plan asks the LLM to decompose the query and returns the PlanSteps.
+// entryPoints lists the project's entry point IDs, giving traces a place to start.
 // The context should already carry a timeout (e.g. 30s).
-func (p *Planner) Plan(ctx context.Context, query string, predicates []string) ([]PlanStep, error) {
-	prompt := buildPlannerPrompt(query, predicates)
+func (p *Planner) Plan(ctx context.Context, query string, predicates, entryPoints []string) ([]PlanStep, error) {
+	prompt := buildPlannerPrompt(query, predicates, entryPoints)
 
 	logger.Debug("Agent/Planner Sending plan request", "query", query, "predicates", len(predicates))
 
@@ -56,16 +60,28 @@ func (p *Planner) Plan(ctx context.Context, query string, predicates []string) (
 	return steps, nil
 }
 
-func buildPlannerPrompt(query string, predicates []string) string {
+func buildPlannerPrompt(query string, predicates, entryPoints []string) string {
 	var predList strings.Builder
 	for _, p := range predicates {
 		predList.WriteString(fmt.Sprintf("- `%s`\n", p))
 	}
 
+	var entryList strings.Builder
+	if len(entryPoints) > 0 {
+		entryList.WriteString("\nProject entry points (main functions, HTTP handlers, CLI commands, React roots):\n")
+		for i, id := range entryPoints {
+			if i == maxPlannerEntryPoints {
+				entryList.WriteString(fmt.Sprintf("- ... and %d more\n", len(entryPoints)-i))
+				break
+			}
+			entryList.WriteString(fmt.Sprintf("- `%s`\n", id))
+		}
+	}
+
 	return fmt.Sprintf(`You are a code analysis planner. Decompose the user's question into a sequence of Datalog queries.
 
 Available predicates:
-%s
+%s%s
 
 Rules:
 1. Each step MUST be a valid Datalog triple query like: triples(?s, "predicate", ?o)
@@ -82,7 +98,7 @@ Respond with ONLY a JSON object:
     {"task": "Find entry points", "query": "triples(?s, \"defines\", \"main\")"},
     {"task": "Trace calls", "query": "triples(\"{{step_0_result}}\", \"calls\", ?o)"}
   ]
-}`, predList.String(), query)
+}`, predList.String(), entryList.String(), query)
 }
 
 // parsePlanResponse extracts PlanSteps from the LLM JSON response.
@@ -45,7 +45,7 @@ Original Query: %s
 Error: %s
 User Intent: %s
 
-Available predicates: defines, calls, imports, has_doc, in_package, has_role, has_tag, kind
+Available predicates: defines, calls, imports, has_doc, in_package, has_role, has_tag, kind, is_entry_point
 
 Rules:
 1. Return ONLY the corrected Datalog query, nothing else.
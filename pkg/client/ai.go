@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// SemanticSearch returns the k symbols most similar to query (0 for the
+// server default). With keyword set, or on servers without embeddings, it
+// matches symbol names and doc comments instead.
+func (c *Client) SemanticSearch(ctx context.Context, project, query string, k int, keyword bool) (*SearchResults, error) {
+	params := url.Values{"project": {project}, "q": {query}}
+	if k > 0 {
+		params.Set("k", strconv.Itoa(k))
+	}
+	if keyword {
+		params.Set("mode", "keyword")
+	}
+	var res SearchResults
+	if err := c.get(ctx, "/api/v1/semantic-search", params, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// AskRequest is a natural language question about a project.
+type AskRequest struct {
+	Project  string `json:"project_id"`
+	Query    string `json:"query"`
+	SymbolID string `json:"symbol_id,omitempty"` // Symbol to focus on
+	Depth    int    `json:"depth,omitempty"`     // Traversal depth
+	Context  string `json:"context,omitempty"`   // Conversation history
+}
+
+// Ask answers a natural language question: the server classifies it,
+// translates it to Datalog, runs it and answers over the results.
+func (c *Client) Ask(ctx context.Context, req AskRequest) (*AskResponse, error) {
+	var resp AskResponse
+	if err := c.post(ctx, "/api/v1/ask", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SmartSearch answers a question in one request, falling back to semantic
+// search when its Datalog finds nothing, and reports each stage it ran.
+func (c *Client) SmartSearch(ctx context.Context, project, question string) (*SmartSearchResult, error) {
+	params := url.Values{"project": {project}}
+	var res SmartSearchResult
+	if err := c.post(ctx, "/api/v1/ai/smart-search", params, map[string]string{"query": question}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ModuleSummary summarizes the module at path, a package directory such as
+// "pkg/meb".
+func (c *Client) ModuleSummary(ctx context.Context, project, path string) (*ModuleSummary, error) {
+	params := url.Values{"project": {project}, "path": {path}}
+	var summary ModuleSummary
+	if err := c.get(ctx, "/api/v1/ai/module-summary", params, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
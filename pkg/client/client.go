@@ -0,0 +1,203 @@
+// Package client is a Go client of the gca REST API, for services such as
+// bots and CI tools that query a gca server rather than embed its stores.
+//
+//	c := client.New("http://localhost:8080", client.WithAPIKey(key))
+//	g, err := c.Query(ctx, "my-project", `triples(?s, "calls", ?o)`, nil)
+//
+// Every call takes a context that bounds it, retries included. Requests that
+// fail with a network error, 429 or a 502, 503 or 504 gateway status are
+// retried with exponential backoff, honoring Retry-After; every endpoint the
+// client covers is safe to repeat. Other failures are returned as *APIError.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+)
+
+// Client calls a gca server. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	retryDelay time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIKey sends key in X-API-Key, as multi-tenant servers require.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetries sets how many times a failed request is retried, 0 for none,
+// and the wait before the first retry (default: config.ClientRetryDelay).
+func WithRetries(n int, delay time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = max(n, 0)
+		if delay > 0 {
+			c.retryDelay = delay
+		}
+	}
+}
+
+// New creates a client of the server at baseURL, such as
+// "http://localhost:8080" or, for a multi-tenant server,
+// "http://gca:8080/t/<tenant>".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		maxRetries: config.ClientMaxRetries,
+		retryDelay: config.ClientRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is a response the server answered with an error status.
+type APIError struct {
+	StatusCode int
+	Message    string // The server's "error" message, or the status text
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gca: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is an APIError with status 404.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// get sends a GET for path with params and decodes the response into out.
+func (c *Client) get(ctx context.Context, path string, params url.Values, out any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, params, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, out)
+}
+
+// post sends body as JSON to path with params and decodes the response into
+// out.
+func (c *Client) post(ctx context.Context, path string, params url.Values, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, path, params, data)
+	if err != nil {
+		return err
+	}
+	return decode(resp, out)
+}
+
+// do sends a request, retrying it as the package documentation describes, and
+// returns the response of the first attempt that succeeds. Error statuses are
+// returned as *APIError, with the body closed.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body []byte) (*http.Response, error) {
+	target := c.baseURL + path
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.apiKey != "" {
+			req.Header.Set("X-API-Key", c.apiKey)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		case resp.StatusCode < 400:
+			return resp, nil
+		default:
+			apiErr := readError(resp)
+			if !retryable(resp.StatusCode) {
+				return nil, apiErr
+			}
+			err = apiErr
+			if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+				wait = time.Duration(secs) * time.Second
+			}
+		}
+		if attempt >= c.maxRetries {
+			return nil, err
+		}
+
+		wait = min(max(wait, delay), config.ClientRetryMaxDelay)
+		delay *= 2
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether a request answered with status may succeed when
+// sent again.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// readError turns an error response into an APIError and closes its body.
+func readError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body) == nil && body.Error != "" {
+		apiErr.Message = body.Error
+	}
+	return apiErr
+}
+
+// decode decodes a response body into out and closes it.
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("gca: decode %s response: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
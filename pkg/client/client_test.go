@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/server"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `{"nodes": [{"id": "a.go:F", "name": "F"}], "links": []}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithAPIKey("secret"), WithRetries(2, time.Millisecond))
+	graph, err := c.Backbone(context.Background(), "p", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+	if assert.Len(t, graph.Nodes, 1) {
+		assert.Equal(t, "a.go:F", graph.Nodes[0].ID)
+	}
+
+	calls.Store(0)
+	c = New(srv.URL, WithAPIKey("secret"), WithRetries(0, 0))
+	_, err = c.Backbone(context.Background(), "p", false, nil)
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	}
+	assert.Equal(t, int32(1), calls.Load(), "no retries")
+}
+
+func TestClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "limit: must be an integer"}`)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, WithRetries(3, time.Millisecond)).QueryRaw(context.Background(), "p", "q", nil)
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Equal(t, "limit: must be an integer", apiErr.Message)
+	}
+	assert.Equal(t, int32(1), calls.Load(), "client errors are not retried")
+}
+
+func TestBatchSummary(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "p", r.URL.Query().Get("project"))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"progress": {"done": 1, "total": 2, "written": ["a.go"]}}`)
+		fmt.Fprintln(w, `{"progress": {"done": 2, "total": 2}}`)
+		fmt.Fprintln(w, `{"report": {"project": "p", "files": 2, "written": 1, "skipped": 1}}`)
+	}))
+	defer srv.Close()
+
+	var events []SummaryEvent
+	for event, err := range New(srv.URL).BatchSummary(context.Background(), "p", "pkg", false) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if !assert.Len(t, events, 3) {
+		return
+	}
+	assert.Equal(t, []string{"a.go"}, events[0].Progress.Written)
+	assert.Equal(t, 2, events[1].Progress.Done)
+	if assert.NotNil(t, events[2].Report) {
+		assert.Equal(t, 1, events[2].Report.Skipped)
+	}
+}
+
+func TestClientServer(t *testing.T) {
+	tmpDir := t.TempDir()
+	pDir := filepath.Join(tmpDir, "projA")
+	if err := os.Mkdir(pDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := meb.NewMEBStore(store.DefaultConfig(pDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddFactBatch([]meb.Fact{
+		{Subject: "a.go", Predicate: "defines", Object: "a.go:F"},
+		{Subject: "a.go:F", Predicate: "calls", Object: "b.go:G"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	mgr := manager.NewStoreManager(tmpDir, manager.MemoryProfileDefault, false)
+	defer mgr.CloseAll()
+	srv := httptest.NewServer(server.NewServer(mgr, tmpDir).Handler())
+	defer srv.Close()
+	c := New(srv.URL)
+	ctx := context.Background()
+
+	res, err := c.QueryRaw(ctx, "projA", `triples(?s, "calls", ?o)`, &QueryOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, res.Rows, 1) {
+		assert.Equal(t, "b.go:G", res.Rows[0]["?o"])
+	}
+
+	graph, err := c.Query(ctx, "projA", `triples(?s, "calls", ?o)`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, graph.Links, 1) {
+		assert.Equal(t, "a.go:F", graph.Links[0].Source)
+	}
+
+	// Updates reach the watch as facts are imported
+	watchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	updates := make(chan GraphUpdate, 1)
+	go func() {
+		for u, err := range c.WatchGraph(watchCtx, "projA") {
+			if err == nil {
+				updates <- u
+			}
+			return
+		}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		// The import may land before the watch is established, so repeat it
+		// with new facts until an update arrives
+		resp, err := http.Post(srv.URL+"/api/v1/facts/import?project=projA", "application/json",
+			strings.NewReader(fmt.Sprintf(`{"subject": "c.go:H", "predicate": "calls", "object": "d.go:%d"}`, time.Now().UnixNano())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		select {
+		case u := <-updates:
+			assert.Equal(t, "projA", u.Project)
+			assert.NotEmpty(t, u.AddedLinks)
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no graph update received")
+		}
+	}
+}
+
+func TestClientJobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(tmpDir, "projA")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	mgr := manager.NewStoreManager(tmpDir, manager.MemoryProfileDefault, false)
+	defer mgr.CloseAll()
+	srv := httptest.NewServer(server.NewServer(mgr, tmpDir).Handler())
+	defer srv.Close()
+	c := New(srv.URL)
+	ctx := context.Background()
+
+	var started struct{ Job Job }
+	if err := c.post(ctx, "/api/v1/admin/compact-dictionary", url.Values{"project": {"projA"}, "async": {"true"}}, nil, &started); err != nil {
+		t.Fatal(err)
+	}
+	job, err := c.WaitJob(ctx, started.Job.ID, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "succeeded", job.State)
+	assert.NotEmpty(t, job.Result)
+
+	jobs, err := c.Jobs(ctx, JobFilter{Kind: "compact", Project: "projA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, job.ID, jobs[0].ID)
+	}
+
+	_, err = c.CancelJob(ctx, job.ID)
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	}
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GraphOptions are the parameters every graph endpoint shares. Zero values
+// leave the server defaults.
+type GraphOptions struct {
+	MaxNodes      int     // Most central nodes to keep
+	MaxLinks      int     // Heaviest links to keep
+	Layout        bool    // Attach precomputed x/y node positions
+	MinConfidence float64 // Drop inferred links scoring below it, 0 to 1
+}
+
+func (o *GraphOptions) encode(params url.Values) {
+	if o == nil {
+		return
+	}
+	setInt(params, "max_nodes", o.MaxNodes)
+	setInt(params, "max_links", o.MaxLinks)
+	if o.Layout {
+		params.Set("layout", "true")
+	}
+	if o.MinConfidence > 0 {
+		params.Set("min_confidence", strconv.FormatFloat(o.MinConfidence, 'f', -1, 64))
+	}
+}
+
+// QueryOptions configure a Datalog query. Zero values leave the server
+// defaults.
+type QueryOptions struct {
+	Limit     int           // Maximum result rows
+	Timeout   time.Duration // Execution deadline on the server
+	AsOf      int           // Read the project as of this ingest version
+	NoCluster bool          // Do not cluster large graphs
+	Seed      int64         // Seed of auto-clustering
+	Graph     GraphOptions
+}
+
+func (o *QueryOptions) encode(params url.Values) {
+	if o == nil {
+		return
+	}
+	setInt(params, "limit", o.Limit)
+	if o.Timeout > 0 {
+		params.Set("timeout", o.Timeout.String())
+	}
+	setInt(params, "as_of", o.AsOf)
+	if o.NoCluster {
+		params.Set("nocluster", "true")
+	}
+	if o.Seed != 0 {
+		params.Set("seed", strconv.FormatInt(o.Seed, 10))
+	}
+	o.Graph.encode(params)
+}
+
+// Query runs a Datalog query over project and returns its matches as a
+// graph. opts may be nil.
+func (c *Client) Query(ctx context.Context, project, query string, opts *QueryOptions) (*Graph, error) {
+	params := url.Values{"project": {project}}
+	opts.encode(params)
+	var graph Graph
+	if err := c.post(ctx, "/api/v1/query", params, map[string]string{"query": query}, &graph); err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
+
+// QueryRaw runs a Datalog query over project and returns its result rows.
+// opts may be nil; its clustering and graph options do not apply.
+func (c *Client) QueryRaw(ctx context.Context, project, query string, opts *QueryOptions) (*QueryResult, error) {
+	params := url.Values{"project": {project}, "raw": {"true"}}
+	opts.encode(params)
+	var res QueryResult
+	if err := c.post(ctx, "/api/v1/query", params, map[string]string{"query": query}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Backbone returns the calls between symbols of different files, or with
+// aggregate set, between the files themselves. opts may be nil.
+func (c *Client) Backbone(ctx context.Context, project string, aggregate bool, opts *GraphOptions) (*Graph, error) {
+	params := url.Values{"project": {project}}
+	if aggregate {
+		params.Set("aggregate", "true")
+	}
+	return c.graph(ctx, "/api/v1/graph/backbone", params, opts)
+}
+
+// PathOptions restrict the edges a path search follows. Zero values leave the
+// server defaults.
+type PathOptions struct {
+	Predicates []string // e.g. "calls"; all when empty
+	Direction  string   // "forward", "reverse" or "undirected"
+	MaxDepth   int      // Edges in the path
+	Graph      GraphOptions
+}
+
+// Path returns the shortest path from source to target. opts may be nil.
+func (c *Client) Path(ctx context.Context, project, source, target string, opts *PathOptions) (*Graph, error) {
+	params := url.Values{"project": {project}, "source": {source}, "target": {target}}
+	var graphOpts *GraphOptions
+	if opts != nil {
+		if len(opts.Predicates) > 0 {
+			params.Set("predicates", strings.Join(opts.Predicates, ","))
+		}
+		if opts.Direction != "" {
+			params.Set("direction", opts.Direction)
+		}
+		setInt(params, "max_depth", opts.MaxDepth)
+		graphOpts = &opts.Graph
+	}
+	return c.graph(ctx, "/api/v1/graph/path", params, graphOpts)
+}
+
+// Cluster returns the matches of a Datalog query clustered into communities;
+// the same seed always yields the same clusters, 0 the server default. opts
+// may be nil.
+func (c *Client) Cluster(ctx context.Context, project, query string, seed int64, opts *GraphOptions) (*Graph, error) {
+	params := url.Values{"project": {project}, "query": {query}}
+	if seed != 0 {
+		params.Set("seed", strconv.FormatInt(seed, 10))
+	}
+	return c.graph(ctx, "/api/v1/graph/cluster", params, opts)
+}
+
+// WhoCalls returns the callers of symbol, up to depth calls away (0 for 1).
+// opts may be nil.
+func (c *Client) WhoCalls(ctx context.Context, project, symbol string, depth int, opts *GraphOptions) (*Graph, error) {
+	params := url.Values{"project": {project}, "symbol": {symbol}}
+	setInt(params, "depth", depth)
+	return c.graph(ctx, "/api/v1/graph/who-calls", params, opts)
+}
+
+// WhatCalls returns the callees of symbol, up to depth calls away (0 for 1).
+// opts may be nil.
+func (c *Client) WhatCalls(ctx context.Context, project, symbol string, depth int, opts *GraphOptions) (*Graph, error) {
+	params := url.Values{"project": {project}, "symbol": {symbol}}
+	setInt(params, "depth", depth)
+	return c.graph(ctx, "/api/v1/graph/what-calls", params, opts)
+}
+
+// Subgraph returns the graph of the given node IDs and the nodes a Datalog
+// query binds, either of which may be empty, with their neighbors up to
+// expand hops away. opts may be nil.
+func (c *Client) Subgraph(ctx context.Context, project string, ids []string, query string, expand int, opts *GraphOptions) (*Graph, error) {
+	params := url.Values{"project": {project}}
+	opts.encode(params)
+	body := struct {
+		IDs    []string `json:"ids"`
+		Query  string   `json:"query,omitempty"`
+		Expand int      `json:"expand,omitempty"`
+	}{ids, query, expand}
+	var graph Graph
+	if err := c.post(ctx, "/api/v1/graph/subgraph", params, body, &graph); err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
+
+// graph fetches a graph endpoint.
+func (c *Client) graph(ctx context.Context, path string, params url.Values, opts *GraphOptions) (*Graph, error) {
+	opts.encode(params)
+	var graph Graph
+	if err := c.get(ctx, path, params, &graph); err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
+
+// HydrateOptions choose what hydrating a symbol fetches. Zero values leave
+// the server defaults.
+type HydrateOptions struct {
+	Fields     []string // "metadata", "content" and "children"
+	Depth      int      // Levels of children
+	ChildLimit int      // Children per symbol on each level
+	After      string   // A symbol's NextChildren, for its next page of children
+}
+
+// Hydrate returns the symbol with the given ID. opts may be nil. A symbol
+// the project does not hold fails with an error IsNotFound reports.
+func (c *Client) Hydrate(ctx context.Context, project, id string, opts *HydrateOptions) (*Symbol, error) {
+	params := url.Values{"project": {project}, "id": {id}}
+	if opts != nil {
+		if len(opts.Fields) > 0 {
+			params.Set("fields", strings.Join(opts.Fields, ","))
+		}
+		setInt(params, "depth", opts.Depth)
+		setInt(params, "child_limit", opts.ChildLimit)
+		if opts.After != "" {
+			params.Set("after", opts.After)
+		}
+	}
+	var symbol Symbol
+	if err := c.get(ctx, "/api/v1/hydrate", params, &symbol); err != nil {
+		return nil, err
+	}
+	return &symbol, nil
+}
+
+// setInt sets a positive integer parameter.
+func setInt(params url.Values, name string, v int) {
+	if v > 0 {
+		params.Set(name, strconv.Itoa(v))
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// JobProgress is how far a job has come: Done of Total units of Stage, Total
+// 0 while unknown.
+type JobProgress struct {
+	Stage string `json:"stage,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// Job is a background job of the server: an ingest, clustering, batch summary
+// or dictionary compaction.
+type Job struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Project    string          `json:"project,omitempty"`
+	State      string          `json:"state"` // "running", "succeeded", "failed" or "cancelled"
+	Cancelling bool            `json:"cancelling,omitempty"`
+	Progress   JobProgress     `json:"progress"`
+	Logs       []string        `json:"logs,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"` // What the job returned, once it succeeded
+	Started    time.Time       `json:"started"`
+	Finished   time.Time       `json:"finished,omitzero"`
+}
+
+// Running reports whether the job has yet to finish.
+func (j *Job) Running() bool {
+	return j.State == "running"
+}
+
+// JobFilter selects the jobs Jobs lists; empty fields match every job.
+type JobFilter struct {
+	Kind    string
+	Project string
+	State   string
+}
+
+// Jobs lists the server's running jobs and its last finished ones, newest
+// first, without their logs and results.
+func (c *Client) Jobs(ctx context.Context, filter JobFilter) ([]Job, error) {
+	params := url.Values{}
+	for name, v := range map[string]string{"kind": filter.Kind, "project": filter.Project, "state": filter.State} {
+		if v != "" {
+			params.Set(name, v)
+		}
+	}
+	var resp struct {
+		Jobs []Job `json:"jobs"`
+	}
+	if err := c.get(ctx, "/api/v1/jobs", params, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
+}
+
+// Job returns the job with id, with its logs and result.
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	if err := c.get(ctx, "/api/v1/jobs/"+url.PathEscape(id), nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelJob cancels the running job with id; it reports state "cancelled"
+// once it has stopped.
+func (c *Client) CancelJob(ctx context.Context, id string) (*Job, error) {
+	var resp struct {
+		Job Job `json:"job"`
+	}
+	if err := c.post(ctx, "/api/v1/jobs/"+url.PathEscape(id)+"/cancel", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Job, nil
+}
+
+// WaitJob polls the job with id every interval until it finishes or ctx is
+// done, and returns it as it finished.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil || !job.Running() {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
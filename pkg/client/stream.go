@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrFellBehind ends a WatchGraph stream the server closed because the client
+// did not keep up with the updates. Fetch the graph again, then watch anew.
+var ErrFellBehind = errors.New("gca: live graph client fell behind")
+
+// BatchSummary summarizes every file of the package at path, yielding a
+// SummaryEvent per batch as the server completes it and the final report
+// last. Files that already have a summary are skipped unless force is set.
+// The request is sent when the iteration starts; an error ends it.
+func (c *Client) BatchSummary(ctx context.Context, project, path string, force bool) iter.Seq2[SummaryEvent, error] {
+	return func(yield func(SummaryEvent, error) bool) {
+		body, err := json.Marshal(map[string]any{"path": path, "force": force})
+		if err != nil {
+			yield(SummaryEvent{}, err)
+			return
+		}
+		resp, err := c.do(ctx, http.MethodPost, "/api/v1/ai/batch-summary", url.Values{"project": {project}}, body)
+		if err != nil {
+			yield(SummaryEvent{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+		for scanner.Scan() {
+			var event SummaryEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				yield(SummaryEvent{}, fmt.Errorf("gca: decode batch summary line: %w", err))
+				return
+			}
+			if !yield(event, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(SummaryEvent{}, err)
+		}
+	}
+}
+
+// WatchGraph yields the project's graph updates as the server applies
+// ingests to it, until ctx is done, the connection fails or the loop stops.
+// An update with Reset set asks for the graph to be fetched again. Updates
+// are not replayed, so fetch the graph after the watch starts, and again
+// after reconnecting; a client the server drops for falling behind gets
+// ErrFellBehind.
+func (c *Client) WatchGraph(ctx context.Context, project string) iter.Seq2[GraphUpdate, error] {
+	return func(yield func(GraphUpdate, error) bool) {
+		target := c.baseURL + "/api/v1/ws/graph?" + url.Values{"project": {project}}.Encode()
+		if rest, ok := strings.CutPrefix(target, "http"); ok {
+			target = "ws" + rest // http: to ws:, https: to wss:
+		}
+		header := http.Header{}
+		if c.apiKey != "" {
+			header.Set("X-API-Key", c.apiKey)
+		}
+		conn, resp, err := websocket.DefaultDialer.DialContext(ctx, target, header)
+		if err != nil {
+			if resp != nil && resp.StatusCode >= 400 {
+				err = readError(resp)
+			}
+			yield(GraphUpdate{}, err)
+			return
+		}
+		defer conn.Close()
+
+		// Unblock the read below when ctx is done
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		defer stop()
+
+		for {
+			var u GraphUpdate
+			if err := conn.ReadJSON(&u); err != nil {
+				switch {
+				case ctx.Err() != nil:
+					err = ctx.Err()
+				case websocket.IsCloseError(err, websocket.CloseTryAgainLater):
+					err = ErrFellBehind
+				}
+				yield(GraphUpdate{}, err)
+				return
+			}
+			if !yield(u, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package client
+
+import "encoding/json"
+
+// The types below mirror the server's JSON responses, so that the client
+// builds without the stores and model SDKs the server links in.
+
+// Graph is a graph response, as export.D3Graph encodes it.
+type Graph struct {
+	Nodes      []Node   `json:"nodes"`
+	Links      []Link   `json:"links"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	HasMore    bool     `json:"has_more,omitempty"`
+	TotalNodes int      `json:"total_nodes,omitempty"`
+	TotalLinks int      `json:"total_links,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	Truncated  bool     `json:"truncated,omitempty"` // The graph was cut down to the node or link limit
+}
+
+// Node is a node of a Graph.
+type Node struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind,omitempty"`
+	Language   string            `json:"language,omitempty"`
+	Group      string            `json:"group,omitempty"`
+	Code       string            `json:"code,omitempty"`
+	Children   []Node            `json:"children,omitempty"`
+	ParentID   string            `json:"parentId,omitempty"`
+	IsInternal *bool             `json:"is_internal,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Collapsed  bool              `json:"collapsed,omitempty"`
+	ChildCount int               `json:"child_count,omitempty"`
+	X          *float64          `json:"x,omitempty"` // Set when GraphOptions.Layout is
+	Y          *float64          `json:"y,omitempty"`
+}
+
+// Link is a link of a Graph.
+type Link struct {
+	Source     string            `json:"source"`
+	Target     string            `json:"target"`
+	Relation   string            `json:"relation"`
+	Weight     float64           `json:"weight,omitempty"`
+	Confidence float64           `json:"confidence,omitempty"` // How likely an inferred link is right, 0 to 1
+	Type       string            `json:"type"`                 // "ast" or "virtual"
+	Provenance string            `json:"provenance,omitempty"`
+	Count      int               `json:"count,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// QueryResult holds the rows of a raw Datalog query, each binding the
+// query's variables, such as "?s", to their values.
+type QueryResult struct {
+	Rows      []map[string]any `json:"results"`
+	Truncated bool             `json:"truncated,omitempty"` // The row limit was reached
+	TimedOut  bool             `json:"timed_out,omitempty"` // The deadline expired before the scan finished
+	Warnings  []string         `json:"warnings,omitempty"`
+}
+
+// Symbol is a hydrated symbol.
+type Symbol struct {
+	ID           string         `json:"id"`
+	Kind         string         `json:"kind"`
+	Code         string         `json:"code"`
+	Metadata     map[string]any `json:"metadata"`
+	Children     []Symbol       `json:"children,omitempty"`
+	ChildCount   int            `json:"child_count,omitempty"`
+	NextChildren string         `json:"next_children,omitempty"` // Pass as HydrateOptions.After for the next page
+}
+
+// SearchResults is the response of a semantic search.
+type SearchResults struct {
+	Query   string         `json:"query"`
+	Count   int            `json:"count"`
+	Mode    string         `json:"mode"` // "embedding", "hybrid" or "keyword"
+	Results []SearchResult `json:"results"`
+}
+
+// SearchResult is a symbol a search matched.
+type SearchResult struct {
+	SymbolID string  `json:"symbol_id"`
+	Score    float32 `json:"score"`
+	Name     string  `json:"name,omitempty"`
+}
+
+// AskResponse answers a natural language question.
+type AskResponse struct {
+	Answer     string  `json:"answer"`
+	Query      string  `json:"query"`  // Datalog the question was translated to
+	Intent     string  `json:"intent"` // e.g. "who_calls", "explain"
+	Confidence float64 `json:"confidence"`
+	Results    any     `json:"results"`
+	Summary    string  `json:"summary"`
+	Error      string  `json:"error,omitempty"` // Why a stage failed
+}
+
+// SmartSearchResult holds the answer of a smart search and what each stage
+// produced.
+type SmartSearchResult struct {
+	Question   string            `json:"question"`
+	Intent     string            `json:"intent"`
+	Confidence float64           `json:"confidence"`
+	Datalog    string            `json:"datalog"`
+	Source     string            `json:"source"` // Stage the graph came from: "datalog", "semantic" or ""
+	Matches    []SearchResult    `json:"matches,omitempty"`
+	Graph      *Graph            `json:"graph"`
+	Answer     string            `json:"answer"`
+	Citations  []string          `json:"citations"`
+	Steps      []SmartSearchStep `json:"steps"`
+}
+
+// SmartSearchStep is one stage of a smart search.
+type SmartSearchStep struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"` // "ok", "empty", "failed" or "skipped"
+	Detail   string `json:"detail,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// ModuleSummary summarizes a module, citing the IDs it draws on.
+type ModuleSummary struct {
+	Path             string              `json:"path"`
+	Purpose          string              `json:"purpose"`
+	PurposeCitations []string            `json:"purpose_citations"`
+	KeyTypes         []ModuleSummaryItem `json:"key_types"`
+	Dependencies     []ModuleSummaryItem `json:"dependencies"`
+	Consumers        []ModuleSummaryItem `json:"consumers"`
+	Context          json.RawMessage     `json:"context"` // The facts the summary was drawn from
+}
+
+// ModuleSummaryItem is one entry of a module summary section.
+type ModuleSummaryItem struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Citations   []string `json:"citations"`
+}
+
+// SummaryEvent is one line of a batch summary stream: the progress of a
+// batch, or the final report, which a run that stopped early sends with
+// Error set.
+type SummaryEvent struct {
+	Progress *SummaryProgress `json:"progress,omitempty"`
+	Report   *SummaryReport   `json:"report,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// SummaryProgress reports a completed batch of a batch summary.
+type SummaryProgress struct {
+	Done    int      `json:"done"` // Files handled so far, including skipped and cached ones
+	Total   int      `json:"total"`
+	Written []string `json:"written,omitempty"`
+	Error   string   `json:"error,omitempty"` // Why the batch failed
+}
+
+// SummaryReport summarizes a batch summary run.
+type SummaryReport struct {
+	Project string `json:"project"`
+	Files   int    `json:"files"`
+	Written int    `json:"written"`
+	Cached  int    `json:"cached"`
+	Skipped int    `json:"skipped"`
+	Failed  int    `json:"failed"`
+}
+
+// GraphUpdate is how one ingest version changed a project's graph. With Reset
+// set, the version was a full ingest, and the graph has to be fetched again.
+type GraphUpdate struct {
+	Project      string   `json:"project"`
+	Version      int      `json:"version"`
+	Reset        bool     `json:"reset,omitempty"`
+	AddedNodes   []Node   `json:"added_nodes"`
+	RemovedNodes []string `json:"removed_nodes"`
+	AddedLinks   []Link   `json:"added_links"`
+	RemovedLinks []Link   `json:"removed_links"`
+}
@@ -0,0 +1,41 @@
+// Package deadline records which stage of a request is running, so that a
+// request that runs out of time can report where it was.
+package deadline
+
+import (
+	"context"
+	"sync"
+)
+
+type trackerKey struct{}
+
+type tracker struct {
+	mu    sync.Mutex
+	stage string
+}
+
+// Track returns a copy of ctx in which Enter records stages.
+func Track(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trackerKey{}, &tracker{})
+}
+
+// Enter records that the request ctx belongs to entered stage, such as
+// "datalog generation" or "hydration". It does nothing when ctx isn't tracked.
+func Enter(ctx context.Context, stage string) {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		t.mu.Lock()
+		t.stage = stage
+		t.mu.Unlock()
+	}
+}
+
+// Stage returns the stage the request ctx belongs to entered last, or "" when
+// none was entered.
+func Stage(ctx context.Context) string {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.stage
+	}
+	return ""
+}
@@ -17,9 +17,26 @@ const (
 )
 
 const (
-	QueryTimeout     = 30 * time.Second
-	AIRequestTimeout = 120 * time.Second
-	EmbeddingTimeout = 10 * time.Second
+	QueryTimeout        = 30 * time.Second
+	AIRequestTimeout    = 120 * time.Second
+	EmbeddingTimeout    = 10 * time.Second
+	VulnScanTimeout     = 5 * time.Minute
+	OpenAPIFetchTimeout = 10 * time.Second
+)
+
+// Per-route request deadlines (server.RouteTimeouts), overridden with
+// GCA_ROUTE_TIMEOUTS. Service calls inherit them through the request context.
+const (
+	RouteTimeoutDefault = 30 * time.Second // Graph, search and source endpoints
+	RouteTimeoutQuery   = QueryMaxTimeout  // Datalog queries, which pick shorter deadlines with ?timeout=
+	RouteTimeoutAI      = 3 * time.Minute  // AI endpoints: Datalog generation, LLM calls and synthesis
+	RouteTimeoutAdmin   = 10 * time.Minute // Maintenance and fact imports
+)
+
+// Vulnerability database endpoints
+const (
+	OSVQueryBatchURL = "https://api.osv.dev/v1/querybatch"
+	OSVVulnURL       = "https://api.osv.dev/v1/vulns/"
 )
 
 const (
@@ -35,20 +52,304 @@ const (
 	DisplayLimitMedium   = 15
 )
 
+// Store durability settings
+const (
+	WriteBatchMaxFacts = 5000         // Facts buffered by a write batch before it flushes
+	DirtyMarkerFile    = ".gca-dirty" // Present in a store directory while a writer has it open
+)
+
+// Watch mode settings (server --watch)
+const (
+	WatchPollInterval = 1 * time.Second        // How often the source tree is scanned for changes
+	WatchSettleTime   = 500 * time.Millisecond // Quiet time after the last change before re-ingesting
+)
+
+// Live graph updates (GET /v1/ws/graph), pushed as watch syncs and imports
+// change a project's graph
+const (
+	LiveGraphBuffer       = 16               // Updates queued per client; a client falling further behind is disconnected
+	LiveGraphPingInterval = 30 * time.Second // Keepalive pings on idle connections
+	LiveGraphWriteTimeout = 10 * time.Second // Deadline of one message write
+)
+
+// Subgraph settings (POST /v1/graph/subgraph)
+const (
+	SubgraphMaxNodes  = 500 // Nodes a query-seeded or expanded subgraph grows to
+	SubgraphMaxExpand = 2   // Hops of neighbors an expansion may add
+)
+
+// Blob backend settings (--blob-backend): document contents kept in object storage
+const (
+	BlobCacheEntries   = 512              // Blobs read from the backend kept in memory
+	BlobRequestTimeout = 30 * time.Second // Deadline of one blob upload or download
+)
+
+// Hot cache of decoded facts and documents, one per open store (meb.HotCache)
+const (
+	HotCacheBudget        = 32 << 20 // Bytes per store with the default memory profile
+	HotCacheBudgetLow     = 8 << 20  // Bytes per store with the low memory profile
+	HotCacheAdmitReads    = 2        // Reads of a subject or document before it is cached
+	HotCacheTrackedKeys   = 1 << 16  // Uncached keys whose reads are counted before the counts reset
+	HotCacheMaxEntryShare = 16       // No entry may take more than this fraction of the budget
+)
+
+// Memory admission control of heavy requests (server.Admission). Requests
+// reserve their estimated working memory; under the low memory profile they
+// wait for room when the budget is taken and are refused when they can't fit.
+const (
+	AdmissionBudgetLow     = 256 << 20        // Bytes of estimated request memory admitted at once with the low memory profile
+	AdmissionRowBytes      = 1 << 10          // Estimated bytes of a query result row or path search node
+	AdmissionNodeBytes     = 16 << 10         // Estimated bytes of a hydrated node, source included
+	AdmissionMaxQueued     = 64               // Requests waiting for room before new ones are turned away
+	AdmissionQueueTimeout  = 10 * time.Second // Longest a request waits for room
+	AdmissionRetryAfterSec = 2                // Retry-After sent with requests turned away
+)
+
+// Background maintenance of open stores (manager.StoreManager.Maintain). Every
+// interval, moved by up to the jitter at random, the stores left unread for a
+// while have their catalog rebuilt and fact counter reconciled by a scan held
+// to the rate.
+const (
+	MaintenanceInterval       = 5 * time.Minute  // Between rounds with the default memory profile
+	MaintenanceIntervalLow    = 15 * time.Minute // Between rounds with the low memory profile
+	MaintenanceJitter         = 0.25             // Fraction of the interval rounds move by at random
+	MaintenanceIdle           = 30 * time.Second // Stores read more recently are left alone
+	MaintenanceMaxAge         = time.Hour        // Catalogs older than this are rebuilt even when nothing changed
+	MaintenanceRate           = 200_000          // Facts scanned a second with the default memory profile
+	MaintenanceRateLow        = 50_000           // Facts scanned a second with the low memory profile
+	MaintenanceStoresPerRound = 2                // Stores reconciled per round; 1 with the low memory profile
+)
+
+// RetentionSweepInterval is how often writable servers delete the expired facts
+// of their open stores (manager.StoreManager.Sweep).
+const RetentionSweepInterval = 10 * time.Minute
+
+// Concurrency limits of expensive graph operations (server.OperationLimiter),
+// by class. Requests beyond a class's limit wait for a slot and are turned
+// away with 503 when too many already wait or none frees up in time.
+const (
+	OperationLimitBackbone = 4               // Backbone, package and map rollups scanning every call at once
+	OperationLimitCluster  = 2               // Cluster and community detections at once
+	OperationMaxQueued     = 16              // Requests of a class waiting for a slot before new ones are turned away
+	OperationQueueTimeout  = 5 * time.Second // Longest a request waits for a slot
+	OperationRetryAfterSec = 1               // Retry-After sent with requests turned away
+)
+
+// Pull request reviews (gca pr-review), which compare the graphs of two revisions
+const (
+	ReviewEntryPointDepth = 6  // Calls followed back from a changed symbol to the entry points reaching it
+	ReviewMaxListed       = 25 // Items listed per section of the Markdown summary; the rest are counted
+)
+
+// Webhooks (server --webhooks), posted when ingests complete, rules are violated
+// and vector indexes change
+const (
+	WebhookTimeout     = 10 * time.Second // Deadline of one delivery attempt
+	WebhookMaxAttempts = 4                // Attempts per delivery before it is dropped
+	WebhookRetryDelay  = 2 * time.Second  // Wait before the second attempt, doubled for each further one
+	WebhookQueueSize   = 256              // Deliveries waiting to be sent before new ones are dropped
+	WebhookRuleRows    = 10               // Rows of a violated rule sent in its event; the rest are counted
+)
+
+// Go client of the REST API (pkg/client)
+const (
+	ClientMaxRetries    = 3                      // Retries of a request failing with a network error, 429 or 5xx gateway status
+	ClientRetryDelay    = 500 * time.Millisecond // Wait before the first retry, doubled for each further one
+	ClientRetryMaxDelay = 30 * time.Second       // Longest wait, even when Retry-After asks for more
+)
+
+// Cold-start warmup of the most central nodes when a server starts (meb.Warmup)
+const (
+	CentralNodesTagged = 512 // Nodes tagged with has_centrality at ingest
+	WarmupNodes        = 256 // Nodes warmed per store with the default memory profile
+	WarmupNodesLow     = 64  // Nodes warmed per store with the low memory profile
+)
+
+// Near-duplicate function detection at ingest (similar_to facts, GET /v1/analysis/duplicates)
+const (
+	DuplicateMinTokens     = 40   // Fewest code tokens a function needs to be compared
+	DuplicateMinSimilarity = 0.95 // Lowest cosine similarity of duplicates' embeddings
+	DuplicateMinJaccard    = 0.7  // Lowest shingle overlap of duplicates without embeddings
+	DuplicateShingleSize   = 5    // Tokens per shingle
+	DuplicateDefaultLimit  = 50   // Groups returned when the caller sets no limit
+)
+
+// Background jobs (GET /v1/jobs): ingests, clustering, summaries and compactions
+const (
+	JobHistory  = 100 // Finished jobs kept for their status, oldest dropped first
+	JobLogLines = 200 // Log lines kept per job, oldest dropped first
+)
+
+// Children hydration (hydrate fields=children): depth and page size
+const (
+	HydrateDefaultDepth      = 1    // Levels of children when the caller sets no depth
+	HydrateMaxDepth          = 5    // Upper bound on a caller-requested depth
+	HydrateDefaultChildLimit = 100  // Children per symbol when the caller sets no limit
+	HydrateMaxChildLimit     = 1000 // Upper bound on a caller-requested child limit
+)
+
+// Document revision tracking (meb.PutDocumentRevision)
+const (
+	DocumentRevisionHistory = 20 // Revisions listed per document; older ones are forgotten
+)
+
+// Multi-tenant server settings (server --tenants)
+const (
+	TenantHeader     = "X-Tenant-ID"   // Header naming the tenant of a request
+	TenantPathPrefix = "/t/"           // Path prefix naming the tenant, as in /t/<tenant>/api/v1/...
+	TenantUsageTTL   = 1 * time.Minute // How long a measured store size is reused for quota checks
+)
+
+// Read replica settings (gca replica)
+const (
+	ReplicaPullInterval = 1 * time.Minute     // How often a replica asks the primary for newer snapshots
+	ReplicaETagFile     = ".gca-replica-etag" // Records, in a replica's store directory, the snapshot it came from
+)
+
+// Query audit log settings (server --audit, GET /v1/audit)
+const (
+	AuditStoreDir      = ".gca-audit"    // Audit store, under the data folder
+	AuditSegmentSize   = 500             // Entries written per segment document
+	AuditFlushInterval = 5 * time.Second // How often buffered entries are written
+	AuditDefaultLimit  = 100             // Entries returned when the caller sets no limit
+	AuditMaxLimit      = 1000            // Upper bound on a caller-requested limit
+)
+
+// Fact import settings (import-facts, POST /v1/facts/import)
+const (
+	FactImportMaxFacts    = 1000000 // Facts accepted in one import
+	FactImportMaxLineSize = 1 << 20 // Longest NDJSON line or CSV record, in bytes
+	FactImportMaxErrors   = 20      // Invalid lines reported before an import gives up
+)
+
+// Ingestion pipeline defaults (ingest --workers, --embed-workers, --embed-queue).
+// Parse workers default to one per CPU, up to MaxWorkers.
+const (
+	IngestJobBuffer    = 100 // Files queued ahead of the parse workers
+	EmbeddingWorkers   = 10  // Concurrent embedding requests
+	EmbeddingQueueSize = 256 // Symbols waiting for an embedding worker before parsing blocks
+
+	IngestJournalFlushFiles = 50 // File stage changes between saves of the resume journal
+	UnresolvedCallsReported = 20 // Most frequent unresolved callees listed after call resolution
+)
+
+// Ingestion guardrails against pathological files
+const (
+	MaxIngestFileSize   = 1 << 20 // Larger source files are skipped
+	MaxIngestLineLength = 2000    // A longer line marks a minified or bundled source file
+	MaxFactValueBytes   = 8 << 10 // Longer strings in facts (docs, literals) are truncated
+	BinarySniffBytes    = 8000    // Leading bytes checked for NUL, as git does
+)
+
+// Store size estimates for ingest --dry-run, calibrated against compacted
+// stores. Source content is counted at its size on disk.
+const (
+	DryRunFactBytes     = 24   // Per fact, across its index keys and dictionary entries
+	DryRunDocumentBytes = 64   // Per symbol document (file and line metadata)
+	DryRunVectorBytes   = 1728 // Per embedding: 1536 int8 dimensions plus block scales
+)
+
+// Symbol summary settings (ingest --summarize)
+const (
+	SummaryBatchSize  = 20               // Symbols summarized per LLM request
+	SummaryMaxSnippet = 2000             // Source bytes of a symbol sent to the model
+	SummaryMaxLength  = 200              // Longest summary kept, in bytes
+	SummaryTimeout    = 60 * time.Second // Deadline of one summary request
+)
+
+// Batch file summary settings (POST /v1/ai/batch-summary)
+const (
+	FileSummaryBatchSize       = 5                      // Files summarized per LLM request
+	FileSummaryMaxSnippet      = 8000                   // Source bytes of a file sent to the model
+	FileSummaryConcurrency     = 4                      // LLM requests in flight at once
+	FileSummaryRequestInterval = 250 * time.Millisecond // Least time between two requests starting
+	FileSummaryMaxFiles        = 500                    // Files of a package summarized by one request
+)
+
+// Path narrative settings (path_narrative AI task)
+const (
+	PathNarrativeMaxHops    = 20   // Hops of a path explained; longer paths are cut
+	PathNarrativeMaxSnippet = 1500 // Source bytes of each hop's code sent to the model and returned
+)
+
+// Module summary settings (GET /v1/ai/module-summary)
+const (
+	ModuleSummaryMaxFiles     = 100 // Files of a module listed to the model
+	ModuleSummaryMaxSymbols   = 80  // Exported symbols of a module listed to the model
+	ModuleSummaryMaxEdges     = 30  // Dependency and consumer packages listed, each
+	ModuleSummaryMaxCitations = 5   // Symbols cited per dependency or consumer
+)
+
+// Virtual link confidence settings
+const (
+	LinkConfidencePriorWeight = 4 // User verdicts a method's assumed precision counts for
+)
+
+// Smart search settings (POST /v1/ai/smart-search)
+const (
+	SmartSearchFallbackK = 10 // Symbols semantic search finds when the Datalog query finds none
+	SmartSearchMaxNodes  = 50 // Nodes, and links, of the found graph listed to the model
+)
+
+// Exported graph cache settings (GraphService)
+const (
+	GraphCacheEnabled = true
+	GraphCacheTTL     = 30 * time.Minute
+	GraphCacheMaxSize = 256
+)
+
+// Large graph export settings
+const (
+	GraphNodeBudget    = 300  // Default node budget when collapsing an exported graph by directory
+	GraphMaxNodeBudget = 5000 // Upper bound on a caller-requested node budget
+	LayoutSize         = 1000 // Side of the square server-side layouts are placed in
+	LayoutIterations   = 100  // Force-directed iterations per layout
+	LayoutMaxFiles     = 3000 // Larger graphs are left for the client to lay out
+	LayoutCacheMaxSize = 64   // Layouts kept, keyed by graph hash
+)
+
+// Graph response limits, applied to every graph endpoint
+const (
+	GraphDefaultMaxNodes = 5000   // Nodes returned when the caller sets no max_nodes
+	GraphDefaultMaxLinks = 20000  // Links returned when the caller sets no max_links
+	GraphMaxNodesLimit   = 20000  // Upper bound on a caller-requested max_nodes
+	GraphMaxLinksLimit   = 100000 // Upper bound on a caller-requested max_links
+)
+
+// GraphDefaultSeed seeds graph clustering when the caller sets no seed, so
+// repeated requests cluster the same graph the same way.
+const GraphDefaultSeed = 1
+
+// Backbone graph settings
+const (
+	BackboneMaxNodes   = 50000 // Nodes after which the backbone scan stops; max_nodes then ranks what it found
+	BackboneEnrichSize = 256   // Fewest nodes a backbone enrichment worker hydrates
+)
+
 // Query result cache settings
 const (
 	QueryCacheEnabled      = true
 	QueryCacheTTL          = 5 * time.Minute
 	QueryCacheMaxSize      = 1000
-	QueryResultLimit       = 1000 // Default limit for query results
-	QuerySymbolSearchLimit = 100  // Limit for symbol search
-	PathFindingMaxNodes    = 500  // Max nodes to visit in path finding
+	QueryResultLimit       = 1000            // Default limit for query results
+	QueryMaxResultLimit    = 10000           // Upper bound on a caller-requested result limit
+	QueryMaxTimeout        = 2 * time.Minute // Upper bound on a caller-requested query deadline
+	QuerySymbolSearchLimit = 100             // Limit for symbol search
+	PathFindingMaxNodes    = 500             // Max nodes to visit in path finding
+)
+
+// Documentation search: keyword and vector hits on doc nodes, merged by
+// reciprocal rank fusion
+const (
+	DocSearchVectorDepth = 4  // Vector hits fetched per requested result, as code vectors are skipped
+	DocSearchRankOffset  = 60 // RRF constant: larger values flatten the gap between ranks
 )
 
 const (
 	PathfinderEdgeWeightFile     = 1
 	PathfinderEdgeWeightDir      = 10
 	PathfinderEdgeWeightFunction = 5
+	PathfinderEdgeWeightDispatch = 3 // may_call: an implementation reached through an interface
 	PathfinderDepthLimit         = 3
 )
 
@@ -81,6 +382,9 @@ const (
 	MaxSearchQueryLength = 500
 	MaxPredicateLength   = 100
 	MaxPrefixLength      = 500
+	MaxViewNameLength    = 200
+	MaxQueryNameLength   = 100
+	MaxNoteLength        = 4000
 )
 
 // Supported source file extensions for validation
@@ -103,4 +407,7 @@ var PromptPaths = map[string]string{
 	"default_context": "prompts/default_context.prompt",
 	"explain":         "prompts/explain_results.prompt",
 	"planner":         "prompts/planner.prompt",
+	"summarize":       "prompts/summarize.prompt",
+	"summarize_files": "prompts/summarize_files.prompt",
+	"module_summary":  "prompts/module_summary.prompt",
 }
@@ -7,6 +7,7 @@ const (
 	PredicateDefines     = "defines"
 	PredicateCalls       = "calls"
 	PredicateImports     = "imports"
+	PredicateImportsFile = "imports_file"
 	PredicateType        = "type"
 	PredicateHasKind     = "has_kind"
 	PredicateHasLanguage = "has_language"
@@ -14,6 +15,7 @@ const (
 	PredicateEndLine     = "end_line"
 	PredicateInPackage   = "in_package"
 	PredicateHasDoc      = "has_doc"
+	PredicateDocuments   = "documents"
 	PredicateHasComment  = "has_comment"
 	PredicateHasRole     = "has_role"
 	PredicateHasTag      = "has_tag"
@@ -62,6 +64,11 @@ const (
 	SymbolKindCluster   = "cluster"
 	SymbolKindGateway   = "gateway"
 	SymbolKindSymbol    = "symbol"
+	SymbolKindDoc       = "doc"
+	SymbolKindPackage   = "package"  // An imported package with no parsed source
+	SymbolKindEndpoint  = "endpoint" // An HTTP route or API path
+
+	SymbolKindExternalPackage = "external_package"
 )
 
 // Relation types
@@ -121,8 +128,108 @@ const (
 	PredicateCalledBy      = "called_by"
 	PredicateHasName       = "has_name"
 	PredicateHasSecurityRisk = "has_security_risk"
+	PredicateHasOperation  = "has_operation"
+)
+
+// Ownership predicates
+const (
+	PredicateOwnedBy = "owned_by"
+)
+
+// Dependency manifest predicates
+const (
+	PredicateDependsOn  = "depends_on"
+	PredicateIsInternal = "is_internal"
+	PredicateProvidedBy = "provided_by"
+)
+
+// Vulnerability predicates
+const (
+	PredicateHasVulnerability = "has_vulnerability"
+	PredicateVulnSeverity     = "vuln_severity"
+	PredicateVulnSummary      = "vuln_summary"
+	PredicateVulnFixedIn      = "vuln_fixed_in"
+)
+
+// Metrics predicates
+const (
+	PredicateLOC        = "loc"
+	PredicateComplexity = "cyclomatic_complexity"
+	PredicateParamCount = "param_count"
+)
+
+// Runtime predicates, imported from profiles and traces
+const (
+	PredicateActuallyCalls = "actually_calls"
+)
+
+// Entry point predicate and kinds (the predicate's objects), tagged at ingest time
+const (
+	PredicateIsEntryPoint = "is_entry_point"
+
+	EntryPointMain        = "main"
+	EntryPointHTTPHandler = "http_handler"
+	EntryPointCLICommand  = "cli_command"
+	EntryPointReactRoot   = "react_root"
+)
+
+// Type composition predicates: struct and interface members and embedded types
+const (
+	PredicateHasField  = "has_field"
+	PredicateFieldType = "field_type"
+	PredicateEmbeds    = "embeds"
+)
+
+// Frontend component predicates: JSX usage and React hook calls
+const (
+	PredicateRenders  = "renders"
+	PredicateUsesHook = "uses_hook"
 )
 
+// Dynamic dispatch predicates: interface implementations and the methods a
+// call through an interface value may reach
+const (
+	PredicateImplements = "implements"
+	PredicateMayCall    = "may_call"
+)
+
+// Generated code predicate, tagged at ingest time on files with a generated-code header
+const (
+	PredicateIsGenerated = "is_generated"
+)
+
+// Summary predicates, generated at ingest time
+const (
+	PredicateHasSummary = "has_summary"
+)
+
+// Centrality predicate, tagged at ingest time on the most central nodes; the
+// object is the node's degree centrality, 1 for the most central
+const (
+	PredicateHasCentrality = "has_centrality"
+)
+
+// Stable identity predicate, tagged at ingest time on every symbol; the object
+// is the symbol's UID, which outlives moves of the symbol between lines and,
+// in Go, between the files of its package
+const (
+	PredicateHasUID = "has_uid"
+)
+
+// Duplicate code predicate, tagged at ingest time both ways between near
+// duplicate functions; the fact's weight is their similarity, 0 to 1
+const (
+	PredicateSimilarTo = "similar_to"
+)
+
+// Structural predicates, whose facts link symbols, files and packages to one
+// another; the adjacency snapshot saved at ingest time holds their edges
+var StructuralPredicates = []string{
+	PredicateCalls, PredicateCallsAPI, PredicateHandledBy, PredicateMayCall, PredicateActuallyCalls,
+	PredicateReferences, PredicateImports, PredicateImportsFile, PredicateDefines, PredicateInPackage,
+	PredicateExports, PredicateImplements, PredicateEmbeds, PredicateRenders, PredicateUsesHook,
+}
+
 // Centrality configuration
 const (
 	CentralityEnabled        = true
@@ -135,6 +242,19 @@ const (
 	CentralityBoostInterface = 1.3 // Boost for interface-like patterns
 )
 
+// Top symbols configuration
+const (
+	TopSymbolsMaxLimit = 1000 // Upper bound on the symbols /api/v1/symbols/top returns
+	PageRankIterations = 20
+	PageRankDamping    = 0.85
+)
+
+// Symbol overview configuration
+const (
+	OverviewNeighborLimit    = 20  // Callers and callees /api/v1/symbols/:id/overview lists by default
+	OverviewMaxNeighborLimit = 200 // Upper bound on the callers and callees it lists
+)
+
 // Virtual Attention Sink configuration
 const (
 	VirtualAttentionThreshold = 0.05 // Minimum centrality score (0-1) to include symbol
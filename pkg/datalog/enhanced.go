@@ -206,15 +206,7 @@ func (pq *ParsedQuery) BaseQuery() string {
 		if i > 0 {
 			sb.WriteString(", ")
 		}
-		sb.WriteString(atom.Predicate)
-		sb.WriteString("(")
-		for j, arg := range atom.Args {
-			if j > 0 {
-				sb.WriteString(", ")
-			}
-			sb.WriteString(arg)
-		}
-		sb.WriteString(")")
+		sb.WriteString(atom.String())
 	}
 	return sb.String()
 }
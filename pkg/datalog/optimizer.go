@@ -66,7 +66,9 @@ func (o *QueryOptimizer) calculateAtomPriority(atom Atom, atoms []Atom) int {
 
 	// Priority 2: Selective predicates get higher priority
 	switch atom.Predicate {
-	case "neq", "!=", "regex", "contains", "starts_with":
+	case "neq", "!=", "regex", "contains", "starts_with", "ends_with",
+		"gt", "lt", "gte", "lte", "between",
+		"weight_gt", "weight_gte", "weight_lt", "weight_lte":
 		score += 50 // Constraint predicates are very selective
 	case "eq", "=":
 		score += 40
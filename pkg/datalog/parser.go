@@ -11,152 +11,450 @@ type Atom struct {
 	Args      []string
 }
 
+// String literals
+//
+// Arguments may be bare (A, ?x, 42), double-quoted ("calls"), single-quoted ('calls')
+// or backtick raw strings (`\.go$`). Inside double and single quotes a backslash escapes
+// the next character: \" \' \\ \n \t and \r are decoded, any other escape is kept verbatim,
+// so "\.go$" and "\\.go$" both produce the regex \.go$. Raw strings are never unescaped.
+
+// ParseError describes a syntax error at a byte offset in the original query.
+type ParseError struct {
+	Query  string // The full query being parsed
+	Offset int    // Byte offset of the error
+	Line   int    // 1-based line number
+	Column int    // 1-based column number
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Context returns the offending query line with a caret under the error position.
+func (e *ParseError) Context() string {
+	start := strings.LastIndex(e.Query[:e.Offset], "\n") + 1
+	end := strings.Index(e.Query[e.Offset:], "\n")
+	if end == -1 {
+		end = len(e.Query)
+	} else {
+		end += e.Offset
+	}
+	return e.Query[start:end] + "\n" + strings.Repeat(" ", e.Offset-start) + "^"
+}
+
+func newParseError(query string, offset int, format string, args ...any) *ParseError {
+	if offset > len(query) {
+		offset = len(query)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	line := 1 + strings.Count(query[:offset], "\n")
+	col := offset - (strings.LastIndex(query[:offset], "\n") + 1) + 1
+	return &ParseError{Query: query, Offset: offset, Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
 // Parse parses a Datalog query string which may contain multiple atoms.
 // It supports standard predicates like 'triples', constraints like 'regex', and syntactic sugar like '!='.
+// Syntax errors are returned as *ParseError.
 func Parse(query string) ([]Atom, error) {
-	query = strings.TrimSpace(query)
-	// Handle "Head :- Body" syntax by taking Body (ignore Head as it's just the Goal)
-	if idx := strings.Index(query, ":-"); idx != -1 {
-		query = query[idx+2:]
+	atoms, errs := parse(query, false)
+	if len(errs) > 0 {
+		return nil, errs[0]
 	}
-	query = strings.TrimSpace(query)
-	// Remove trailing dot
-	query = strings.TrimSuffix(query, ".")
+	return atoms, nil
+}
 
-	// Remove leading ? if present (common in some Datalog dialects)
-	query = strings.TrimPrefix(query, "?")
+// ParseTolerant parses as much of the query as it can, skipping malformed atoms.
+// It returns the atoms that parsed cleanly together with every syntax error encountered,
+// which lets interactive clients run the valid part of a query while reporting the rest.
+func ParseTolerant(query string) ([]Atom, []*ParseError) {
+	return parse(query, true)
+}
 
-	// Split by ')' or similar to identify atoms? No, split by typical delimiters.
-	// We need a smart splitter that handles:
-	// atom1(...), atom2(...)
-	// atom1(...) , atom2(...)
+func parse(query string, tolerant bool) ([]Atom, []*ParseError) {
+	body, base := queryBody(query)
 
-	// Use SmartSplit to get top-level atoms considering commas and quotes.
-	rawAtoms := SmartSplit(query)
-	if len(rawAtoms) == 0 {
-		return nil, fmt.Errorf("empty query")
+	segments, errs := splitTopLevel(query, body, base, tolerant)
+	if len(errs) > 0 && !tolerant {
+		return nil, errs
 	}
 
 	var parsedAtoms []Atom
-	for _, raw := range rawAtoms {
-		raw = strings.TrimSpace(raw)
-		if raw == "" {
+	for _, seg := range segments {
+		if seg.text == "" {
 			continue
 		}
+		atom, err := parseSegment(query, seg)
+		if err != nil {
+			errs = append(errs, err)
+			if !tolerant {
+				return nil, errs
+			}
+			continue
+		}
+		parsedAtoms = append(parsedAtoms, atom)
+	}
+
+	if len(parsedAtoms) == 0 && len(errs) == 0 {
+		errs = append(errs, newParseError(query, base, "empty query"))
+	}
+	return parsedAtoms, errs
+}
+
+// queryBody strips the optional "Head :-" prefix, trailing dot and leading '?' dialect marker,
+// returning the remaining body and its byte offset within query.
+func queryBody(query string) (string, int) {
+	base := len(query) - len(strings.TrimLeft(query, " \t\r\n"))
+	body := strings.TrimSpace(query)
+
+	// Handle "Head :- Body" syntax by taking Body (ignore Head as it's just the Goal)
+	if idx := indexUnquoted(body, ":-"); idx != -1 {
+		rest := body[idx+2:]
+		base += idx + 2 + len(rest) - len(strings.TrimLeft(rest, " \t\r\n"))
+		body = strings.TrimSpace(rest)
+	}
+	// Remove trailing dot
+	body = strings.TrimSuffix(body, ".")
+
+	// Remove leading ? if present (common in some Datalog dialects), but not from "?x > 3"
+	if strings.HasPrefix(body, "?") {
+		if paren := strings.Index(body, "("); paren > 1 && isIdentifier(body[1:paren]) {
+			body = body[1:]
+			base++
+		}
+	}
+	return body, base
+}
 
-		// Handle syntactic sugar: A != B
-		if strings.Contains(raw, "!=") {
-			parts := strings.SplitN(raw, "!=", 2)
-			if len(parts) != 2 {
-				return nil, fmt.Errorf("invalid inequality format: %s", raw)
+// segment is a slice of the query with its byte offset in the original string.
+type segment struct {
+	text   string
+	offset int
+}
+
+// splitTopLevel splits body at commas outside quotes and parentheses, reporting
+// unbalanced parentheses and unterminated strings.
+func splitTopLevel(query, body string, base int, tolerant bool) ([]segment, []*ParseError) {
+	var segments []segment
+	var errs []*ParseError
+	var opens []int // offsets of unmatched '('
+	start := 0
+	var quote byte
+	quoteStart := 0
+
+	flush := func(end int) {
+		segments = append(segments, trimSegment(body[start:end], base+start))
+		start = end + 1
+	}
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
 			}
-			lhs := strings.TrimSpace(parts[0])
-			rhs := strings.TrimSpace(parts[1])
-			parsedAtoms = append(parsedAtoms, Atom{
-				Predicate: "neq",
-				Args:      []string{lhs, rhs},
-			})
 			continue
 		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+			quoteStart = i
+		case '(':
+			opens = append(opens, i)
+		case ')':
+			if len(opens) == 0 {
+				errs = append(errs, newParseError(query, base+i, "unexpected ')' without matching '('"))
+				continue
+			}
+			opens = opens[:len(opens)-1]
+		case ',':
+			if len(opens) == 0 {
+				flush(i)
+			}
+		}
+	}
 
-		// Standard atom: Predicate(Args...)
-		pred, args, err := parseAtomString(raw)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse atom '%s': %w", raw, err)
+	if quote != 0 {
+		errs = append(errs, newParseError(query, base+quoteStart, "unterminated string literal starting with %c", quote))
+	}
+	if len(opens) > 0 {
+		errs = append(errs, newParseError(query, base+opens[len(opens)-1], "missing ')' for this '('"))
+	}
+	if start <= len(body) {
+		segments = append(segments, trimSegment(body[start:], base+start))
+	}
+
+	// In tolerant mode drop only the atoms that contain a structural error.
+	if tolerant && len(errs) > 0 {
+		kept := segments[:0]
+		for _, seg := range segments {
+			broken := false
+			for _, e := range errs {
+				if e.Offset >= seg.offset && e.Offset < seg.offset+len(seg.text) {
+					broken = true
+					break
+				}
+			}
+			if !broken {
+				kept = append(kept, seg)
+			}
 		}
-		parsedAtoms = append(parsedAtoms, Atom{
-			Predicate: pred,
-			Args:      args,
-		})
+		segments = kept
 	}
+	return segments, errs
+}
 
-	return parsedAtoms, nil
+func trimSegment(text string, offset int) segment {
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	return segment{text: strings.TrimRight(trimmed, " \t\r\n"), offset: offset + len(text) - len(trimmed)}
 }
 
-// parseAtomString parses "predicate(arg1, arg2, ...)"
-func parseAtomString(s string) (string, []string, error) {
-	s = strings.TrimSpace(s)
-	start := strings.Index(s, "(")
-	end := strings.LastIndex(s, ")")
+// parseSegment parses one top-level atom or comparison.
+func parseSegment(query string, seg segment) (Atom, *ParseError) {
+	// Handle syntactic sugar: A != B, and numeric comparisons: A >= 10, A < B
+	if idx := indexUnquoted(seg.text, "!="); idx != -1 {
+		return infixAtom(query, seg, idx, "!=", "neq")
+	}
+	for _, c := range comparisonOperators {
+		if idx := indexUnquoted(seg.text, c.op); idx != -1 {
+			return infixAtom(query, seg, idx, c.op, c.predicate)
+		}
+	}
 
-	if start == -1 || end == -1 || start >= end {
-		return "", nil, fmt.Errorf("expected format 'predicate(args...)' but got '%s'", s)
+	paren := indexUnquoted(seg.text, "(")
+	if paren == -1 {
+		return Atom{}, newParseError(query, seg.offset, "expected 'predicate(args...)' or a comparison but got %q", seg.text)
 	}
 
-	predicate := strings.TrimSpace(s[:start])
-	argsBody := s[start+1 : end]
+	// Standard atom: Predicate(Args...)
+	predicate := strings.TrimSpace(seg.text[:paren])
+	if predicate == "" {
+		return Atom{}, newParseError(query, seg.offset, "missing predicate name before '('")
+	}
+	if strings.ContainsAny(predicate, " \t\"'`") {
+		return Atom{}, newParseError(query, seg.offset, "invalid predicate name %q", predicate)
+	}
+	closing := matchingParen(seg.text, paren)
+	if closing == -1 {
+		return Atom{}, newParseError(query, seg.offset+paren, "missing ')' for this '('")
+	}
+	if closing != len(seg.text)-1 {
+		return Atom{}, newParseError(query, seg.offset+closing+1, "unexpected %q after ')'; separate atoms with ','", seg.text[closing+1:])
+	}
 
-	args := SmartSplit(argsBody)
-	// Trim quotes from args for cleaner usage downstream, OR keep them?
-	// The original implementation trimmed them in `parseArg`.
-	// Ideally, the parser should keep structure, but for simplicity let's clean them here if they are purely string literals.
-	// Actually, let's keep them raw here and let the evaluator decide, OR standardizing on stripping quotes for ease.
-	// Given the previous helper `clean`, let's strip quotes to match previous behavior.
-	cleanedArgs := make([]string, len(args))
-	for i, arg := range args {
-		cleanedArgs[i] = strings.TrimSpace(strings.ReplaceAll(arg, "\"", "'")) // normalize to single quotes or just strip?
-		// Original 'clean' used ReplaceAll(s, "\"", "") -> stripped double quotes.
-		// Let's strip both single and double quotes for consistency.
-		cleanedArgs[i] = strings.Trim(cleanedArgs[i], "\"'")
+	argsBody := seg.text[paren+1 : closing]
+	if strings.TrimSpace(argsBody) == "" {
+		return Atom{Predicate: predicate, Args: []string{}}, nil
 	}
 
-	return predicate, cleanedArgs, nil
+	argSegs, errs := splitTopLevel(query, argsBody, seg.offset+paren+1, false)
+	if len(errs) > 0 {
+		return Atom{}, errs[0]
+	}
+	args := make([]string, 0, len(argSegs))
+	for _, a := range argSegs {
+		if a.text == "" {
+			return Atom{}, newParseError(query, a.offset, "empty argument in %s(...)", predicate)
+		}
+		val, err := parseArg(query, a)
+		if err != nil {
+			return Atom{}, err
+		}
+		args = append(args, val)
+	}
+	return Atom{Predicate: predicate, Args: args}, nil
 }
 
-// SmartSplit splits a string by comma, correctly handling quotes and parentheses.
-// e.g. "a, b, 'c,d'" -> ["a", "b", "'c,d'"]
-// Handles escaped quotes like "test\"string".
-func SmartSplit(s string) []string {
-	var results []string
-	var current strings.Builder
-	depth := 0
-	inQuote := false
-	var quoteChar rune
+func infixAtom(query string, seg segment, idx int, op, predicate string) (Atom, *ParseError) {
+	lhs := trimSegment(seg.text[:idx], seg.offset)
+	rhs := trimSegment(seg.text[idx+len(op):], seg.offset+idx+len(op))
+	if lhs.text == "" {
+		return Atom{}, newParseError(query, seg.offset+idx, "missing left operand for %q", op)
+	}
+	if rhs.text == "" {
+		return Atom{}, newParseError(query, seg.offset+idx, "missing right operand for %q", op)
+	}
+	l, err := parseArg(query, lhs)
+	if err != nil {
+		return Atom{}, err
+	}
+	r, err := parseArg(query, rhs)
+	if err != nil {
+		return Atom{}, err
+	}
+	return Atom{Predicate: predicate, Args: []string{l, r}}, nil
+}
 
-	for i, r := range s {
-		switch r {
-		case '\\':
-			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\'') {
-				current.WriteRune(r)
-			} else {
-				current.WriteRune(r)
+// comparisonOperators maps infix operators to constraint predicates.
+// Two-character operators are listed first so ">=" is not split on ">".
+var comparisonOperators = []struct {
+	op        string
+	predicate string
+}{
+	{">=", "gte"},
+	{"<=", "lte"},
+	{">", "gt"},
+	{"<", "lt"},
+}
+
+// parseArg decodes a single argument. Quoted literals are unquoted and unescaped,
+// raw strings are unquoted verbatim and anything else (variables, numbers,
+// nested calls like lowercase(?x)) is returned as written.
+func parseArg(query string, a segment) (string, *ParseError) {
+	s := a.text
+	q := s[0]
+	if q != '"' && q != '\'' && q != '`' {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == q {
+			if i != len(s)-1 {
+				return "", newParseError(query, a.offset+i+1, "unexpected %q after string literal", s[i+1:])
 			}
-		case '"', '\'':
-			if inQuote {
-				if r == quoteChar {
-					inQuote = false
-					current.WriteRune(r)
-				} else {
-					current.WriteRune(r)
-				}
-			} else {
-				inQuote = true
-				quoteChar = r
-				current.WriteRune(r)
+			return b.String(), nil
+		}
+		if c == '\\' && q != '`' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case '\\', '"', '\'':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				// Unknown escapes are kept so regexes like "\.go$" work as written
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
 			}
-		case '(':
-			if !inQuote {
-				depth++
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return "", newParseError(query, a.offset, "unterminated string literal starting with %c", q)
+}
+
+// FormatArg renders a parsed argument back into query syntax. Variables, numbers, plain
+// identifiers and nested calls such as lowercase(?x) are written bare; anything else is
+// double-quoted with backslashes and quotes escaped, so Parse(FormatArg(a)) yields a again.
+func FormatArg(arg string) string {
+	if arg != "" && !strings.Contains(arg, ":-") && !strings.ContainsAny(arg, " \t\r\n,\"'`\\") {
+		if paren := strings.Index(arg, "("); paren == -1 {
+			if !strings.ContainsAny(arg, ")!<>=") {
+				return arg
 			}
-			current.WriteRune(r)
+		} else if isIdentifier(arg[:paren]) && matchingParen(arg, paren) == len(arg)-1 {
+			return arg
+		}
+	}
+	r := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\t", "\\t", "\r", "\\r")
+	return "\"" + r.Replace(arg) + "\""
+}
+
+// String renders the atom in query syntax.
+func (a Atom) String() string {
+	args := make([]string, len(a.Args))
+	for i, arg := range a.Args {
+		args[i] = FormatArg(arg)
+	}
+	return a.Predicate + "(" + strings.Join(args, ", ") + ")"
+}
+
+// indexUnquoted returns the index of the first occurrence of sub in s outside string literals
+// and parentheses, or -1.
+func indexUnquoted(s, sub string) int {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if depth == 0 && strings.HasPrefix(s[i:], sub) {
+			return i
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(':
+			depth++
 		case ')':
-			if !inQuote {
-				depth--
+			depth--
+		}
+	}
+	return -1
+}
+
+// matchingParen returns the index of the ')' closing the '(' at open, or -1.
+func matchingParen(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
 			}
-			current.WriteRune(r)
-		case ',':
-			if !inQuote && depth == 0 {
-				results = append(results, strings.TrimSpace(current.String()))
-				current.Reset()
-				continue
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
 			}
-			current.WriteRune(r)
-		default:
-			current.WriteRune(r)
 		}
 	}
-	if current.Len() > 0 {
-		results = append(results, strings.TrimSpace(current.String()))
+	return -1
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// SmartSplit splits a string by comma, correctly handling quotes and parentheses.
+// e.g. "a, b, 'c,d'" -> ["a", "b", "'c,d'"]
+// Handles escaped quotes like "test\"string" and backtick raw strings.
+func SmartSplit(s string) []string {
+	segments, _ := splitTopLevel(s, s, 0, false)
+	var results []string
+	for i, seg := range segments {
+		// Preserve the previous behaviour of not emitting a trailing empty element
+		if seg.text == "" && i == len(segments)-1 {
+			continue
+		}
+		results = append(results, seg.text)
 	}
 	return results
 }
@@ -1,6 +1,7 @@
 package datalog
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -60,6 +61,62 @@ func TestParse(t *testing.T) {
 				{Predicate: "triples", Args: []string{"A", "calls", "B"}},
 			},
 		},
+		{
+			name:  "Numeric Comparison Sugar",
+			query: `triples(?s, "start_line", ?l), ?l >= 10, ?l < 100`,
+			want: []Atom{
+				{Predicate: "triples", Args: []string{"?s", "start_line", "?l"}},
+				{Predicate: "gte", Args: []string{"?l", "10"}},
+				{Predicate: "lt", Args: []string{"?l", "100"}},
+			},
+		},
+		{
+			name:  "Between Constraint",
+			query: `triples(?s, "loc", ?n), between(?n, 50, 200)`,
+			want: []Atom{
+				{Predicate: "triples", Args: []string{"?s", "loc", "?n"}},
+				{Predicate: "between", Args: []string{"?n", "50", "200"}},
+			},
+		},
+		{
+			name:  "Escaped Regex",
+			query: `regex(?f, "\\.go$"), regex(?g, "\.go$")`,
+			want: []Atom{
+				{Predicate: "regex", Args: []string{"?f", `\.go$`}},
+				{Predicate: "regex", Args: []string{"?g", `\.go$`}},
+			},
+		},
+		{
+			name:  "Escaped Quotes",
+			query: `triples(?s, "doc", "say \"hi\", then 'bye'")`,
+			want: []Atom{
+				{Predicate: "triples", Args: []string{"?s", "doc", `say "hi", then 'bye'`}},
+			},
+		},
+		{
+			name:  "Raw String",
+			query: "regex(?f, `^pkg\\\\(meb|ingest)/.*\\.go$`)",
+			want: []Atom{
+				{Predicate: "regex", Args: []string{"?f", `^pkg\\(meb|ingest)/.*\.go$`}},
+			},
+		},
+		{
+			name:  "Operator Inside Quotes",
+			query: `triples(?s, "name", "a!=b")`,
+			want: []Atom{
+				{Predicate: "triples", Args: []string{"?s", "name", "a!=b"}},
+			},
+		},
+		{
+			name:    "Unterminated String",
+			query:   `triples(?s, "calls, ?o)`,
+			wantErr: true,
+		},
+		{
+			name:    "Trailing Garbage",
+			query:   `triples(?s, "calls", ?o) triples(?o, "calls", ?x)`,
+			wantErr: true,
+		},
 		{
 			name:    "Invalid Syntax",
 			query:   `triples(A, B`,
@@ -86,6 +143,41 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseErrorPosition(t *testing.T) {
+	query := "triples(?s, \"calls\", ?o),\n  regex(?o, \"unterminated)"
+	_, err := Parse(query)
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Parse() error = %v, want *ParseError", err)
+	}
+	if perr.Line != 2 || perr.Column != 13 {
+		t.Errorf("position = %d:%d, want 2:13", perr.Line, perr.Column)
+	}
+	if want := "  regex(?o, \"unterminated)\n            ^"; perr.Context() != want {
+		t.Errorf("Context() = %q, want %q", perr.Context(), want)
+	}
+}
+
+func TestParseTolerant(t *testing.T) {
+	atoms, errs := ParseTolerant(`triples(?s, "calls", ?o), bogus, ?o != ?s, regex(?o, "(")`)
+	want := []Atom{
+		{Predicate: "triples", Args: []string{"?s", "calls", "?o"}},
+		{Predicate: "neq", Args: []string{"?o", "?s"}},
+		{Predicate: "regex", Args: []string{"?o", "("}},
+	}
+	if !reflect.DeepEqual(atoms, want) {
+		t.Errorf("ParseTolerant() atoms = %v, want %v", atoms, want)
+	}
+	if len(errs) != 1 || errs[0].Column != 27 {
+		t.Errorf("ParseTolerant() errs = %v, want one error at column 27", errs)
+	}
+
+	atoms, errs = ParseTolerant(`triples(?s, "calls", ?o), regex(?o, "x"`)
+	if len(atoms) != 1 || len(errs) != 1 {
+		t.Errorf("ParseTolerant() = %v, %v; want the first atom and one error", atoms, errs)
+	}
+}
+
 func TestSmartSplit(t *testing.T) {
 	tests := []struct {
 		input string
@@ -95,6 +187,8 @@ func TestSmartSplit(t *testing.T) {
 		{`a, "b,c", d`, []string{"a", "\"b,c\"", "d"}},
 		{`fn(a,b), c`, []string{"fn(a,b)", "c"}},
 		{`triples(A, "calls", B), A != B`, []string{`triples(A, "calls", B)`, `A != B`}},
+		{`a, "b\",c", d`, []string{"a", `"b\",c"`, "d"}},
+		{"a, `b\\`, c", []string{"a", "`b\\`", "c"}},
 	}
 
 	for _, tt := range tests {
@@ -106,3 +200,14 @@ func TestSmartSplit(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatArgRoundTrip(t *testing.T) {
+	atom := Atom{Predicate: "regex", Args: []string{"?f", `\.go$`, "a, b", `say "hi"`, "lowercase(?n)", "calls", "pkg/a.go:Foo"}}
+	got, err := Parse(atom.String())
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", atom.String(), err)
+	}
+	if !reflect.DeepEqual(got, []Atom{atom}) {
+		t.Errorf("Parse(%q) = %v, want %v", atom.String(), got, atom)
+	}
+}
@@ -0,0 +1,251 @@
+package export
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Collapse returns a copy of the graph with at most budget nodes, for graphs too
+// large for the frontend to render. Symbols are folded into their file and files
+// into their directory, deepest paths first and the groups that save the most
+// nodes first, until the budget is met. Collapsed nodes carry the number of
+// nodes they hide in ChildCount. Links are rewired to the collapsed nodes, links
+// that end up inside one node are dropped, and parallel links are bundled with
+// their multiplicity in Count. The receiver is not modified.
+func (g *D3Graph) Collapse(budget int) *D3Graph {
+	out := *g
+	if budget <= 0 || len(g.Nodes) <= budget {
+		out.Links = bundleLinks(g.Links)
+		return &out
+	}
+
+	groups := make(map[string]string, len(g.Nodes)) // node ID -> collapsed node ID
+	for _, n := range g.Nodes {
+		groups[n.ID] = n.ID
+	}
+	for countGroups(groups) > budget {
+		if !collapseDeepest(groups, budget) {
+			break
+		}
+	}
+
+	out.Nodes = collapsedNodes(g.Nodes, groups)
+	out.Links = bundleLinks(rewireLinks(g.Links, groups))
+	out.OriginalNodes = len(g.Nodes)
+	if g.OriginalNodes > 0 {
+		out.OriginalNodes = g.OriginalNodes
+	}
+	if len(out.Nodes) > budget {
+		out.Warnings = append(append([]string(nil), g.Warnings...),
+			fmt.Sprintf("graph has %d nodes after collapsing to top-level paths; node budget is %d", len(out.Nodes), budget))
+	}
+	return &out
+}
+
+// collapseDeepest folds the deepest collapsible groups into their parents, the
+// parents that absorb the most groups first, stopping once budget is met. It
+// reports false when nothing is left to collapse.
+func collapseDeepest(groups map[string]string, budget int) bool {
+	deepest := 0
+	for _, key := range groups {
+		if collapseParent(key) != "" && pathDepth(key) > deepest {
+			deepest = pathDepth(key)
+		}
+	}
+	if deepest == 0 {
+		return false
+	}
+
+	existing := make(map[string]bool)
+	children := make(map[string]map[string]bool) // parent -> groups at the deepest level
+	for _, key := range groups {
+		existing[key] = true
+		if pathDepth(key) != deepest {
+			continue
+		}
+		if parent := collapseParent(key); parent != "" {
+			if children[parent] == nil {
+				children[parent] = make(map[string]bool)
+			}
+			children[parent][key] = true
+		}
+	}
+
+	saving := func(parent string) int {
+		n := len(children[parent])
+		if !existing[parent] {
+			n--
+		}
+		return n
+	}
+	parents := make([]string, 0, len(children))
+	for parent := range children {
+		parents = append(parents, parent)
+	}
+	sort.Slice(parents, func(i, j int) bool {
+		if si, sj := saving(parents[i]), saving(parents[j]); si != sj {
+			return si > sj
+		}
+		return parents[i] < parents[j]
+	})
+
+	chosen := make(map[string]bool)
+	remaining := countGroups(groups)
+	for _, parent := range parents {
+		if remaining <= budget {
+			break
+		}
+		remaining -= saving(parent)
+		chosen[parent] = true
+	}
+	for id, key := range groups {
+		if pathDepth(key) == deepest && chosen[collapseParent(key)] {
+			groups[id] = collapseParent(key)
+		}
+	}
+	return true
+}
+
+// collapseParent returns the node a group folds into: a symbol's file or a
+// path's directory. Top-level paths and external names have no parent.
+func collapseParent(id string) string {
+	if i := strings.Index(id, ":"); i > 0 {
+		return id[:i]
+	}
+	if i := strings.LastIndex(id, "/"); i > 0 {
+		return id[:i]
+	}
+	return ""
+}
+
+// pathDepth counts the path segments of id, with a symbol one below its file.
+func pathDepth(id string) int {
+	depth := 1
+	if i := strings.Index(id, ":"); i > 0 {
+		id = id[:i]
+		depth++
+	}
+	return depth + strings.Count(strings.TrimPrefix(id, "/"), "/")
+}
+
+func countGroups(groups map[string]string) int {
+	distinct := make(map[string]bool, len(groups))
+	for _, key := range groups {
+		distinct[key] = true
+	}
+	return len(distinct)
+}
+
+// collapsedNodes builds one node per group, in order of first appearance. A group
+// holding only its own original node keeps that node unchanged.
+func collapsedNodes(nodes []D3Node, groups map[string]string) []D3Node {
+	members := make(map[string][]D3Node)
+	var order []string
+	for _, n := range nodes {
+		key := groups[n.ID]
+		if _, ok := members[key]; !ok {
+			order = append(order, key)
+		}
+		members[key] = append(members[key], n)
+	}
+
+	out := make([]D3Node, 0, len(order))
+	for _, key := range order {
+		group := members[key]
+		if len(group) == 1 && group[0].ID == key {
+			out = append(out, group[0])
+			continue
+		}
+		out = append(out, collapsedNode(key, group))
+	}
+	return out
+}
+
+func collapsedNode(key string, group []D3Node) D3Node {
+	node := D3Node{ID: key, Name: path.Base(key), Kind: "directory"}
+	hidden := len(group)
+	language := group[0].Language
+	internal := false
+	symbolsOnly := true
+	for _, n := range group {
+		if n.ID == key {
+			node = n
+			node.Code = ""
+			hidden--
+		} else if !strings.HasPrefix(n.ID, key+":") {
+			symbolsOnly = false
+		}
+		if n.Language != language {
+			language = "mixed"
+		}
+		if n.IsInternal != nil && *n.IsInternal {
+			internal = true
+		}
+	}
+
+	if node.Kind == "directory" && symbolsOnly {
+		node.Kind = "file"
+	}
+	if node.Language == "" {
+		node.Language = language
+	}
+	node.Group = node.Language
+	if node.Group == "" {
+		node.Group = "unknown"
+	}
+	node.Collapsed = true
+	node.ChildCount = hidden
+	node.IsInternal = &internal
+	return node
+}
+
+// rewireLinks points links at the collapsed nodes and drops those that now
+// start and end inside the same one.
+func rewireLinks(links []D3Link, groups map[string]string) []D3Link {
+	out := make([]D3Link, 0, len(links))
+	for _, l := range links {
+		source, target := l.Source, l.Target
+		if key, ok := groups[source]; ok {
+			source = key
+		}
+		if key, ok := groups[target]; ok {
+			target = key
+		}
+		if source == target && l.Source != l.Target {
+			continue
+		}
+		l.Source, l.Target = source, target
+		out = append(out, l)
+	}
+	return out
+}
+
+// bundleLinks merges links with the same endpoints, relation and type, summing
+// their weights and recording how many were merged in Count.
+func bundleLinks(links []D3Link) []D3Link {
+	type linkKey struct{ source, target, relation, kind string }
+	index := make(map[linkKey]int, len(links))
+	out := make([]D3Link, 0, len(links))
+	for _, l := range links {
+		count := l.Count
+		if count == 0 {
+			count = 1
+		}
+		k := linkKey{l.Source, l.Target, l.Relation, l.Type}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(out)
+			out = append(out, l)
+			continue
+		}
+		prev := out[i].Count
+		if prev == 0 {
+			prev = 1
+		}
+		out[i].Count = prev + count
+		out[i].Weight += l.Weight
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package export
+
+import "testing"
+
+func TestD3GraphCollapse(t *testing.T) {
+	ids := []string{
+		"a/x.go:F1", "a/x.go:F2", "a/x.go:F3",
+		"a/y.go:F4", "a/y.go:F5",
+		"b/z.go:G1", "b/z.go:G2", "b/z.go:G3",
+		"b/w.go:G4", "b/w.go:G5",
+		"fmt",
+	}
+	g := &D3Graph{}
+	for _, id := range ids {
+		g.Nodes = append(g.Nodes, D3Node{ID: id, Language: "go"})
+	}
+	g.Links = []D3Link{
+		{Source: "a/x.go:F1", Target: "b/z.go:G1", Relation: "calls", Weight: 1},
+		{Source: "a/y.go:F4", Target: "b/z.go:G2", Relation: "calls", Weight: 1},
+		{Source: "a/x.go:F1", Target: "a/y.go:F5", Relation: "calls", Weight: 1},
+		{Source: "b/w.go:G4", Target: "fmt", Relation: "calls", Weight: 1},
+	}
+
+	got := g.Collapse(4)
+	if len(g.Nodes) != len(ids) || len(g.Links) != 4 {
+		t.Fatal("Collapse modified its receiver")
+	}
+	if got.OriginalNodes != len(ids) {
+		t.Errorf("OriginalNodes = %d, want %d", got.OriginalNodes, len(ids))
+	}
+
+	nodes := make(map[string]D3Node)
+	for _, n := range got.Nodes {
+		nodes[n.ID] = n
+	}
+	if len(nodes) != 4 {
+		t.Fatalf("got nodes %v, want a, b/z.go, b/w.go and fmt", nodes)
+	}
+	if a := nodes["a"]; !a.Collapsed || a.Kind != "directory" || a.ChildCount != 5 {
+		t.Errorf("a = %+v, want a collapsed directory of 5 nodes", a)
+	}
+	if z := nodes["b/z.go"]; !z.Collapsed || z.Kind != "file" || z.ChildCount != 3 {
+		t.Errorf("b/z.go = %+v, want a collapsed file of 3 nodes", z)
+	}
+	if f := nodes["fmt"]; f.Collapsed {
+		t.Errorf("fmt was collapsed: %+v", f)
+	}
+
+	links := make(map[[2]string]D3Link)
+	for _, l := range got.Links {
+		links[[2]string{l.Source, l.Target}] = l
+	}
+	if len(links) != 2 {
+		t.Fatalf("got links %v, want a -> b/z.go and b/w.go -> fmt", got.Links)
+	}
+	if l := links[[2]string{"a", "b/z.go"}]; l.Count != 2 || l.Weight != 2 {
+		t.Errorf("a -> b/z.go = %+v, want 2 bundled links", l)
+	}
+	if l := links[[2]string{"b/w.go", "fmt"}]; l.Count != 0 {
+		t.Errorf("b/w.go -> fmt = %+v, want a single unbundled link", l)
+	}
+}
+
+func TestD3GraphCollapseWithinBudget(t *testing.T) {
+	g := &D3Graph{
+		Nodes: []D3Node{{ID: "a.go:F"}, {ID: "b.go:G"}},
+		Links: []D3Link{
+			{Source: "a.go:F", Target: "b.go:G", Relation: "calls"},
+			{Source: "a.go:F", Target: "b.go:G", Relation: "calls"},
+			{Source: "a.go:F", Target: "b.go:G", Relation: "references"},
+		},
+	}
+	got := g.Collapse(10)
+	if len(got.Nodes) != 2 || got.OriginalNodes != 0 {
+		t.Errorf("graph within budget was collapsed: %+v", got)
+	}
+	if len(got.Links) != 2 || got.Links[0].Count != 2 {
+		t.Errorf("links = %+v, want the calls links bundled", got.Links)
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"strings"
 
 	"github.com/duynguyendang/gca/pkg/common"
+	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/datalog"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 )
 
@@ -25,16 +27,23 @@ type D3Node struct {
 	ParentID   string            `json:"parentId,omitempty"`    // ID of the parent file (for drilling down)
 	IsInternal *bool             `json:"is_internal,omitempty"` // True if node is internal to the project
 	Metadata   map[string]string `json:"metadata,omitempty"`    // Extra data (e.g. docs)
+	Collapsed  bool              `json:"collapsed,omitempty"`   // Stands for a file or directory of hidden nodes
+	ChildCount int               `json:"child_count,omitempty"` // Number of original nodes folded into a collapsed node
+	X          *float64          `json:"x,omitempty"`           // Precomputed layout position, when requested
+	Y          *float64          `json:"y,omitempty"`
 }
 
 // D3Link represents a link/edge in the D3 force-directed graph.
 type D3Link struct {
-	Source           string  `json:"source"`
-	Target           string  `json:"target"`
-	Relation         string  `json:"relation"`
-	Weight           float64 `json:"weight,omitempty"`
-	Type             string  `json:"type"`                 // "ast" or "virtual"
-	SourceProvenance string  `json:"provenance,omitempty"` // Renamed to avoid collision with Source field
+	Source           string            `json:"source"`
+	Target           string            `json:"target"`
+	Relation         string            `json:"relation"`
+	Weight           float64           `json:"weight,omitempty"`
+	Confidence       float64           `json:"confidence,omitempty"` // How likely an inferred link is right, 0 to 1
+	Type             string            `json:"type"`                 // "ast" or "virtual"
+	SourceProvenance string            `json:"provenance,omitempty"` // Renamed to avoid collision with Source field
+	Count            int               `json:"count,omitempty"`      // Parallel edges bundled into this one
+	Metadata         map[string]string `json:"metadata,omitempty"`   // User annotations (labels, notes)
 }
 
 // D3Graph represents the full graph structure for D3.js.
@@ -46,6 +55,12 @@ type D3Graph struct {
 	HasMore    bool   `json:"has_more,omitempty"`
 	TotalNodes int    `json:"total_nodes,omitempty"`
 	TotalLinks int    `json:"total_links,omitempty"`
+	// Warnings explain why the graph may be partial (row limit reached, query timed out)
+	Warnings []string `json:"warnings,omitempty"`
+	// OriginalNodes is the node count before the graph was collapsed to a node budget
+	OriginalNodes int `json:"original_nodes,omitempty"`
+	// Truncated is set when nodes or links were dropped to fit max_nodes/max_links
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // GraphCursor represents a pagination cursor for lazy loading graphs.
@@ -68,7 +83,10 @@ type D3Transformer struct {
 	IgnoredPredicates map[string]bool
 	Store             *meb.MEBStore
 	ExcludeTestFiles  bool
-	InternalPrefixes  []string // Prefixes that identify internal project files
+	InternalPrefixes  []string               // Prefixes that identify internal project files
+	NodeBudget        int                    // When > 0, collapse the graph by directory to at most this many nodes
+	Kinds             func(id string) string // Looks up node kinds, e.g. in a kind index; "" falls back to has_kind
+	IsIngestedFile    func(id string) bool   // Reports ingested files; when nil, a stored document marks one
 }
 
 // NewD3Transformer creates a new transformer with reference to the store.
@@ -191,7 +209,7 @@ func (t *D3Transformer) Transform(ctx context.Context, query string, results []m
 
 		// Metadata Handling (Docs, Comments)
 		// Instead of creating nodes for these, attach them to the Subject Node's Metadata
-		if pVal == "has_doc" || pVal == "has_comment" {
+		if pVal == "has_doc" || pVal == "has_comment" || pVal == "has_summary" {
 			// Ensure Subject exists
 			if _, exists := nodesMap[sVal]; !exists {
 				nodesMap[sVal] = t.createNode(sVal)
@@ -236,6 +254,8 @@ func (t *D3Transformer) Transform(ctx context.Context, query string, results []m
 		linkType := "ast"
 		if provenance == "virtual" || provenance == "inference" {
 			linkType = "virtual"
+		} else if strings.HasPrefix(provenance, "runtime:") {
+			linkType = "runtime"
 		}
 
 		links = append(links, D3Link{
@@ -254,10 +274,21 @@ func (t *D3Transformer) Transform(ctx context.Context, query string, results []m
 		nodes = append(nodes, n)
 	}
 
-	return &D3Graph{
+	graph := &D3Graph{
 		Nodes: nodes,
 		Links: links,
-	}, nil
+	}
+	if t.NodeBudget > 0 {
+		graph = graph.Collapse(t.NodeBudget)
+	}
+	graph.Sort()
+	return graph, nil
+}
+
+// Node returns the node Transform creates for id, for graphs that hold nodes
+// no row links.
+func (t *D3Transformer) Node(id string) D3Node {
+	return t.createNode(id)
 }
 
 // createNode builds a D3Node with enriched metadata.
@@ -295,10 +326,22 @@ func (t *D3Transformer) isInternalNode(id string) bool {
 	parts := strings.SplitN(id, ":", 2)
 	basePath := parts[0]
 
-	// Check if the file exists in the store (was ingested)
-	// This is the most reliable way to detect internal files
-	content, err := t.Store.GetContentByKey(string(basePath))
-	if err == nil && len(content) > 0 {
+	// Manifest ingestion marks third-party modules and their imports explicitly
+	for fact, _ := range t.Store.Scan(basePath, config.PredicateIsInternal, "") {
+		if v, ok := fact.Object.(bool); ok {
+			return v
+		}
+		if v, ok := fact.Object.(string); ok {
+			return v == "true"
+		}
+	}
+
+	// Check if the file was ingested, from the file index when there is one
+	if t.IsIngestedFile != nil {
+		if t.IsIngestedFile(basePath) {
+			return true
+		}
+	} else if content, err := gcamdb.GetDocument(t.Store, string(basePath)); err == nil && len(content) > 0 {
 		return true
 	}
 
@@ -339,11 +382,16 @@ func (t *D3Transformer) getMetadata(id string) (string, string, string) {
 	// Note: This performs individual scans per node. For massive exports, batching would be better,
 	// but Scan is efficient enough for typical export sizes.
 
-	// 1. Check for 'has_kind'
-	for fact, _ := range t.Store.Scan(id, "has_kind", "") {
-		if str, ok := fact.Object.(string); ok {
-			kind = str
-			break // Take the first one
+	// 1. Check the kind lookup, then 'has_kind'
+	if t.Kinds != nil {
+		kind = t.Kinds(id)
+	}
+	if kind == "" {
+		for fact, _ := range t.Store.Scan(id, "has_kind", "") {
+			if str, ok := fact.Object.(string); ok {
+				kind = str
+				break // Take the first one
+			}
 		}
 	}
 
@@ -356,7 +404,7 @@ func (t *D3Transformer) getMetadata(id string) (string, string, string) {
 	}
 
 	// 3. Get Source Code from DocStore (instead of FactStore)
-	content, err := t.Store.GetContentByKey(string(id))
+	content, err := gcamdb.GetDocument(t.Store, string(id))
 	if err == nil && len(content) > 0 {
 		code = string(content)
 	}
@@ -2,6 +2,7 @@ package export
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/duynguyendang/meb"
@@ -109,3 +110,89 @@ func TestD3Transformer(t *testing.T) {
 		t.Errorf("Expected 0 nodes filtered out, got %d", len(graphTest.Nodes))
 	}
 }
+
+func TestComputeLayout(t *testing.T) {
+	g := &D3Graph{
+		Nodes: []D3Node{{ID: "a.go"}, {ID: "a.go:F"}, {ID: "a.go:G"}, {ID: "b.go"}, {ID: "c.go:H"}},
+		Links: []D3Link{{Source: "a.go:F", Target: "b.go"}, {Source: "b.go", Target: "c.go:H"}},
+	}
+	opts := LayoutOptions{Size: 1000, Iterations: 50}
+	first, ok := ComputeLayout(g, opts)
+	if !ok {
+		t.Fatal("ComputeLayout refused a small graph")
+	}
+	second, _ := ComputeLayout(g, opts)
+	for _, n := range g.Nodes {
+		p, ok := first[n.ID]
+		if !ok {
+			t.Fatalf("no position for %s", n.ID)
+		}
+		if p != second[n.ID] {
+			t.Errorf("layout of %s is not deterministic: %v vs %v", n.ID, p, second[n.ID])
+		}
+		if p.X < -opts.Size || p.X > 2*opts.Size || p.Y < -opts.Size || p.Y > 2*opts.Size {
+			t.Errorf("%s placed far outside the layout area: %v", n.ID, p)
+		}
+	}
+	if first["a.go:F"] == first["a.go:G"] {
+		t.Error("symbols of one file share a position")
+	}
+
+	g.ApplyLayout(first)
+	if g.Nodes[0].X == nil || *g.Nodes[0].X != first["a.go"].X {
+		t.Errorf("ApplyLayout did not set positions: %+v", g.Nodes[0])
+	}
+	if _, ok := ComputeLayout(g, LayoutOptions{Size: 1000, Iterations: 50, MaxFiles: 2}); ok {
+		t.Error("ComputeLayout laid out a graph over MaxFiles")
+	}
+
+	reordered := &D3Graph{Nodes: append([]D3Node(nil), g.Nodes...), Links: []D3Link{g.Links[1], g.Links[0]}}
+	reordered.Nodes[0], reordered.Nodes[4] = reordered.Nodes[4], reordered.Nodes[0]
+	if g.Hash() != reordered.Hash() {
+		t.Error("Hash depends on node or link order")
+	}
+}
+
+func TestD3GraphTruncate(t *testing.T) {
+	g := &D3Graph{
+		Nodes: []D3Node{{ID: "leaf"}, {ID: "hub"}, {ID: "b"}, {ID: "a"}},
+		Links: []D3Link{
+			{Source: "hub", Target: "a", Relation: "calls", Weight: 1},
+			{Source: "hub", Target: "b", Relation: "calls", Weight: 3},
+			{Source: "hub", Target: "leaf", Relation: "calls", Weight: 1},
+			{Source: "a", Target: "b", Relation: "calls", Weight: 1},
+		},
+	}
+
+	got := g.Truncate(3, 0)
+	if len(g.Nodes) != 4 || len(g.Links) != 4 {
+		t.Fatal("Truncate modified its receiver")
+	}
+	var ids []string
+	for _, n := range got.Nodes {
+		ids = append(ids, n.ID)
+	}
+	// leaf has the lowest weighted degree. Kept nodes stay in their original order.
+	if strings.Join(ids, ",") != "hub,b,a" {
+		t.Errorf("nodes = %v, want hub,b,a", ids)
+	}
+	if len(got.Links) != 3 {
+		t.Errorf("links = %+v, want the three among the kept nodes", got.Links)
+	}
+	if !got.Truncated || got.TotalNodes != 4 || got.TotalLinks != 4 {
+		t.Errorf("truncated=%v total_nodes=%d total_links=%d, want true, 4, 4", got.Truncated, got.TotalNodes, got.TotalLinks)
+	}
+
+	got = g.Truncate(0, 2)
+	if len(got.Nodes) != 4 || len(got.Links) != 2 {
+		t.Fatalf("got %d nodes and %d links, want 4 and 2", len(got.Nodes), len(got.Links))
+	}
+	// hub->b is heaviest; of the weight-1 links a->b wins the tie by source.
+	if got.Links[0].Source != "hub" || got.Links[0].Target != "b" || got.Links[1].Source != "a" {
+		t.Errorf("links = %+v, want hub->b and a->b", got.Links)
+	}
+
+	if got := g.Truncate(10, 10); got.Truncated || len(got.Nodes) != 4 {
+		t.Errorf("graph within limits was truncated: %+v", got)
+	}
+}
@@ -0,0 +1,31 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dotEscaper escapes the characters DOT's quoted strings treat specially.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteDOT writes the graph in Graphviz DOT, a node per graph node labelled
+// with its display name and an edge per link labelled with its relation.
+func WriteDOT(w io.Writer, graph *D3Graph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph gca {")
+	for _, n := range graph.Nodes {
+		label := n.Name
+		if label == "" {
+			label = n.ID
+		}
+		fmt.Fprintf(bw, "  \"%s\" [label=\"%s\"];\n", dotEscaper.Replace(n.ID), dotEscaper.Replace(label))
+	}
+	for _, l := range graph.Links {
+		fmt.Fprintf(bw, "  \"%s\" -> \"%s\" [label=\"%s\"];\n",
+			dotEscaper.Replace(l.Source), dotEscaper.Replace(l.Target), dotEscaper.Replace(l.Relation))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
@@ -0,0 +1,28 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDOT(t *testing.T) {
+	graph := &D3Graph{
+		Nodes: []D3Node{
+			{ID: "main.go:main", Name: "main"},
+			{ID: `fmt:Printf("%s")`},
+		},
+		Links: []D3Link{{Source: "main.go:main", Target: `fmt:Printf("%s")`, Relation: "calls"}},
+	}
+	var b strings.Builder
+	if err := WriteDOT(&b, graph); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `digraph gca {
+  "main.go:main" [label="main"];
+  "fmt:Printf(\"%s\")" [label="fmt:Printf(\"%s\")"];
+  "main.go:main" -> "fmt:Printf(\"%s\")" [label="calls"];
+}
+`, b.String())
+}
@@ -0,0 +1,25 @@
+package export
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed html.tmpl
+var htmlPage string
+
+// htmlTemplate renders a graph as a page that draws it without any other file:
+// the graph is embedded as JSON next to a small SVG renderer with pan, zoom,
+// dragging, search and neighborhood highlighting.
+var htmlTemplate = template.Must(template.New("graph").Parse(htmlPage))
+
+// WriteHTML writes the graph as a self-contained HTML page titled title. Nodes
+// keep the positions ApplyLayout gave them; the page places the rest on a
+// circle.
+func WriteHTML(w io.Writer, graph *D3Graph, title string) error {
+	return htmlTemplate.Execute(w, struct {
+		Title string
+		Graph *D3Graph
+	}{title, graph})
+}
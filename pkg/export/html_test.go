@@ -0,0 +1,29 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteHTML(t *testing.T) {
+	graph := &D3Graph{
+		Nodes: []D3Node{
+			{ID: "main.go:main", Name: "main"},
+			{ID: "evil.go:</script><script>alert(1)</script>"},
+		},
+		Links: []D3Link{{Source: "main.go:main", Target: "evil.go:</script><script>alert(1)</script>", Relation: "calls"}},
+	}
+	var b strings.Builder
+	if err := WriteHTML(&b, graph, "calls <main>"); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+
+	assert.Contains(t, page, "<title>calls &lt;main&gt;</title>")
+	assert.Contains(t, page, `"id":"main.go:main"`)
+	// Node IDs cannot close the script the graph is embedded in
+	assert.Equal(t, 1, strings.Count(page, "</script>"))
+	assert.NotContains(t, page, "src=", "the page must load nothing else")
+}
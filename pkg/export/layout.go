@@ -0,0 +1,191 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Position is a node's precomputed place in the layout area.
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Hash identifies the graph's shape: its node IDs and link endpoints. Graphs with
+// the same hash get the same layout.
+func (g *D3Graph) Hash() string {
+	ids := make([]string, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	sort.Strings(ids)
+	edges := make([]string, 0, len(g.Links))
+	for _, l := range g.Links {
+		edges = append(edges, l.Source+"\x00"+l.Target)
+	}
+	sort.Strings(edges)
+
+	h := sha256.New()
+	for _, id := range ids {
+		h.Write([]byte(id))
+		h.Write([]byte{'\n'})
+	}
+	h.Write([]byte{0})
+	for _, e := range edges {
+		h.Write([]byte(e))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ApplyLayout sets X and Y on every node that has a position.
+func (g *D3Graph) ApplyLayout(positions map[string]Position) {
+	for i := range g.Nodes {
+		if p, ok := positions[g.Nodes[i].ID]; ok {
+			x, y := p.X, p.Y
+			g.Nodes[i].X, g.Nodes[i].Y = &x, &y
+		}
+	}
+}
+
+// LayoutOptions configures ComputeLayout.
+type LayoutOptions struct {
+	Size       float64 // Side of the square nodes are placed in
+	Iterations int     // Force-directed iterations
+	MaxFiles   int     // Give up on graphs spanning more files; <= 0 means no limit
+}
+
+// ComputeLayout places the graph in a Size x Size square. Files are laid out with
+// Fruchterman-Reingold on the graph aggregated to files, which keeps the
+// quadratic pass small, and each file's symbols are set on a ring around it.
+// The result is deterministic for a given graph. It reports false, without
+// positions, when the graph spans more than MaxFiles files.
+func ComputeLayout(g *D3Graph, opts LayoutOptions) (map[string]Position, bool) {
+	fileOf := func(id string) string {
+		if i := strings.Index(id, ":"); i > 0 {
+			return id[:i]
+		}
+		return id
+	}
+
+	symbols := make(map[string][]string) // file -> symbols placed around it
+	seen := make(map[string]bool)
+	var files []string
+	for _, n := range g.Nodes {
+		file := fileOf(n.ID)
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+		if file != n.ID {
+			symbols[file] = append(symbols[file], n.ID)
+		}
+	}
+	if opts.MaxFiles > 0 && len(files) > opts.MaxFiles {
+		return nil, false
+	}
+	sort.Strings(files)
+	index := make(map[string]int, len(files))
+	for i, f := range files {
+		index[f] = i
+	}
+
+	edgeSet := make(map[[2]int]bool)
+	var edges [][2]int
+	for _, l := range g.Links {
+		a, okA := index[fileOf(l.Source)]
+		b, okB := index[fileOf(l.Target)]
+		if !okA || !okB || a == b {
+			continue
+		}
+		if a > b {
+			a, b = b, a
+		}
+		if e := [2]int{a, b}; !edgeSet[e] {
+			edgeSet[e] = true
+			edges = append(edges, e)
+		}
+	}
+
+	filePos := fruchtermanReingold(len(files), edges, opts.Size, opts.Iterations)
+	positions := make(map[string]Position, len(g.Nodes))
+	k := math.Sqrt(opts.Size * opts.Size / float64(max(len(files), 1)))
+	for i, file := range files {
+		positions[file] = filePos[i]
+		members := symbols[file]
+		sort.Strings(members)
+		for j, id := range members {
+			angle := 2 * math.Pi * float64(j) / float64(len(members))
+			positions[id] = Position{
+				X: roundCoord(filePos[i].X + 0.3*k*math.Cos(angle)),
+				Y: roundCoord(filePos[i].Y + 0.3*k*math.Sin(angle)),
+			}
+		}
+	}
+	return positions, true
+}
+
+// fruchtermanReingold runs a force-directed layout of n vertices. Vertices start
+// on a golden-angle spiral so the result does not depend on map order or chance.
+func fruchtermanReingold(n int, edges [][2]int, size float64, iterations int) []Position {
+	pos := make([]Position, n)
+	if n == 0 {
+		return pos
+	}
+	center := size / 2
+	k := math.Sqrt(size * size / float64(n))
+	for i := range pos {
+		r := k * math.Sqrt(float64(i)) / 2
+		theta := float64(i) * 2.399963229728653 // golden angle
+		pos[i] = Position{X: center + r*math.Cos(theta), Y: center + r*math.Sin(theta)}
+	}
+
+	disp := make([]Position, n)
+	temp := size / 10
+	cool := temp / float64(max(iterations, 1))
+	for it := 0; it < iterations; it++ {
+		for i := range disp {
+			disp[i] = Position{}
+		}
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				dx, dy := pos[i].X-pos[j].X, pos[i].Y-pos[j].Y
+				dist := math.Max(math.Hypot(dx, dy), 0.01)
+				f := k * k / dist
+				disp[i].X += dx / dist * f
+				disp[i].Y += dy / dist * f
+				disp[j].X -= dx / dist * f
+				disp[j].Y -= dy / dist * f
+			}
+		}
+		for _, e := range edges {
+			i, j := e[0], e[1]
+			dx, dy := pos[i].X-pos[j].X, pos[i].Y-pos[j].Y
+			dist := math.Max(math.Hypot(dx, dy), 0.01)
+			f := dist * dist / k
+			disp[i].X -= dx / dist * f
+			disp[i].Y -= dy / dist * f
+			disp[j].X += dx / dist * f
+			disp[j].Y += dy / dist * f
+		}
+		for i := range pos {
+			length := math.Max(math.Hypot(disp[i].X, disp[i].Y), 0.01)
+			step := math.Min(length, temp)
+			pos[i].X = math.Min(size, math.Max(0, pos[i].X+disp[i].X/length*step))
+			pos[i].Y = math.Min(size, math.Max(0, pos[i].Y+disp[i].Y/length*step))
+		}
+		temp -= cool
+	}
+
+	for i := range pos {
+		pos[i] = Position{X: roundCoord(pos[i].X), Y: roundCoord(pos[i].Y)}
+	}
+	return pos
+}
+
+func roundCoord(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,23 @@
+package export
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Sort puts the graph in canonical order: nodes by ID, links by source,
+// target and relation, then type and provenance. Graphs built by iterating
+// maps are sorted before they are returned, so the same store always exports
+// the same bytes and client layouts do not jitter between requests.
+func (g *D3Graph) Sort() {
+	slices.SortStableFunc(g.Nodes, func(a, b D3Node) int { return cmp.Compare(a.ID, b.ID) })
+	slices.SortStableFunc(g.Links, func(a, b D3Link) int {
+		return cmp.Or(
+			cmp.Compare(a.Source, b.Source),
+			cmp.Compare(a.Target, b.Target),
+			cmp.Compare(a.Relation, b.Relation),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.SourceProvenance, b.SourceProvenance),
+		)
+	})
+}
@@ -0,0 +1,40 @@
+package export
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestD3GraphSort(t *testing.T) {
+	g := &D3Graph{
+		Nodes: []D3Node{{ID: "b.go:G"}, {ID: "a.go:F"}, {ID: "a.go"}},
+		Links: []D3Link{
+			{Source: "b.go:G", Target: "a.go:F", Relation: "calls"},
+			{Source: "a.go:F", Target: "b.go:G", Relation: "references"},
+			{Source: "a.go:F", Target: "b.go:G", Relation: "calls"},
+			{Source: "a.go", Target: "a.go:F", Relation: "defines"},
+		},
+	}
+	g.Sort()
+
+	var nodes []string
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	if want := []string{"a.go", "a.go:F", "b.go:G"}; !slices.Equal(nodes, want) {
+		t.Errorf("nodes = %v, want %v", nodes, want)
+	}
+	var links []string
+	for _, l := range g.Links {
+		links = append(links, l.Source+" "+l.Relation+" "+l.Target)
+	}
+	want := []string{
+		"a.go defines a.go:F",
+		"a.go:F calls b.go:G",
+		"a.go:F references b.go:G",
+		"b.go:G calls a.go:F",
+	}
+	if !slices.Equal(links, want) {
+		t.Errorf("links = %v, want %v", links, want)
+	}
+}
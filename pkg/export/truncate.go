@@ -0,0 +1,109 @@
+package export
+
+import "sort"
+
+// Truncate returns a copy of the graph with at most maxNodes nodes and maxLinks
+// links; a limit <= 0 leaves that side uncapped. Nodes are ranked by weighted
+// degree, the summed weight of their links, and links by weight, with ties broken
+// by ID so the same graph always truncates the same way. Links to dropped nodes
+// are dropped too. Kept nodes and links stay in their original order. A truncated
+// graph has Truncated set and TotalNodes and TotalLinks holding the original
+// sizes. The receiver is not modified.
+func (g *D3Graph) Truncate(maxNodes, maxLinks int) *D3Graph {
+	out := *g
+	nodesOver := maxNodes > 0 && len(g.Nodes) > maxNodes
+	linksOver := maxLinks > 0 && len(g.Links) > maxLinks
+	if !nodesOver && !linksOver {
+		return &out
+	}
+
+	if nodesOver {
+		score := make(map[string]float64, len(g.Nodes))
+		for _, l := range g.Links {
+			w := linkWeight(l)
+			score[l.Source] += w
+			score[l.Target] += w
+		}
+		ranked := make([]int, len(g.Nodes))
+		for i := range ranked {
+			ranked[i] = i
+		}
+		sort.SliceStable(ranked, func(a, b int) bool {
+			na, nb := g.Nodes[ranked[a]], g.Nodes[ranked[b]]
+			if sa, sb := score[na.ID], score[nb.ID]; sa != sb {
+				return sa > sb
+			}
+			return na.ID < nb.ID
+		})
+		keep := make([]bool, len(g.Nodes))
+		for _, i := range ranked[:maxNodes] {
+			keep[i] = true
+		}
+		kept := make(map[string]bool, maxNodes)
+		out.Nodes = make([]D3Node, 0, maxNodes)
+		for i, n := range g.Nodes {
+			if keep[i] {
+				out.Nodes = append(out.Nodes, n)
+				kept[n.ID] = true
+			}
+		}
+		out.Links = make([]D3Link, 0, len(g.Links))
+		for _, l := range g.Links {
+			if kept[l.Source] && kept[l.Target] {
+				out.Links = append(out.Links, l)
+			}
+		}
+	}
+
+	if maxLinks > 0 && len(out.Links) > maxLinks {
+		links := out.Links
+		ranked := make([]int, len(links))
+		for i := range ranked {
+			ranked[i] = i
+		}
+		sort.SliceStable(ranked, func(a, b int) bool {
+			la, lb := links[ranked[a]], links[ranked[b]]
+			if wa, wb := linkWeight(la), linkWeight(lb); wa != wb {
+				return wa > wb
+			}
+			if la.Source != lb.Source {
+				return la.Source < lb.Source
+			}
+			if la.Target != lb.Target {
+				return la.Target < lb.Target
+			}
+			return la.Relation < lb.Relation
+		})
+		keep := make([]bool, len(links))
+		for _, i := range ranked[:maxLinks] {
+			keep[i] = true
+		}
+		out.Links = make([]D3Link, 0, maxLinks)
+		for i, l := range links {
+			if keep[i] {
+				out.Links = append(out.Links, l)
+			}
+		}
+	}
+
+	out.Truncated = true
+	if out.TotalNodes == 0 {
+		out.TotalNodes = len(g.Nodes)
+	}
+	if out.TotalLinks == 0 {
+		out.TotalLinks = len(g.Links)
+	}
+	return &out
+}
+
+// linkWeight is a link's weight, or the number of links bundled into it when it
+// carries none.
+func linkWeight(l D3Link) float64 {
+	if l.Weight > 0 {
+		return l.Weight
+	}
+	if l.Count > 0 {
+		return float64(l.Count)
+	}
+	return 1
+}
@@ -0,0 +1,244 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// Calls are resolved in two phases. While a file is parsed, a callee is
+// rewritten to a symbol ID only when its text is a key of the symbol table.
+// Once every file is stored, resolveCalls re-resolves the calls still pointing
+// at a bare name against the complete table, using the caller's receiver and
+// directory to pick among symbols sharing a name. Component renders and hook
+// uses are resolved the same way.
+
+// resolvedPredicates are the predicates whose objects name a called symbol.
+var resolvedPredicates = []string{config.PredicateCalls, config.PredicateRenders, config.PredicateUsesHook}
+
+// callRef is a call, render or hook use of a caller, before resolution.
+type callRef struct {
+	predicate string
+	callee    string
+}
+
+// UnresolvedCall is a callee name left unresolved and how many calls use it.
+type UnresolvedCall struct {
+	Callee    string `json:"callee"`
+	Count     int    `json:"count"`
+	Ambiguous bool   `json:"ambiguous"` // Several project symbols match; otherwise none does
+}
+
+// CallReport summarizes the call resolution pass of an ingest.
+type CallReport struct {
+	Pending    int              `json:"pending"`    // Calls, renders and hook uses of a bare name after parsing
+	Resolved   int              `json:"resolved"`   // Rewritten to a symbol ID
+	Ambiguous  int              `json:"ambiguous"`  // Left as is: several symbols match
+	External   int              `json:"external"`   // Left as is: no project symbol matches
+	Unresolved []UnresolvedCall `json:"unresolved"` // Most frequent first, up to config.UnresolvedCallsReported
+}
+
+// callIndex looks up the project's symbols by short name.
+type callIndex struct {
+	table  map[string]string
+	byName map[string][]string
+}
+
+func (state *IngestState) newCallIndex() *callIndex {
+	idx := &callIndex{table: state.SymbolTable, byName: make(map[string][]string)}
+	seen := make(map[string]bool)
+	for _, keys := range state.fileSymbols {
+		for _, id := range keys {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			name := extractShortName(id)
+			idx.byName[name] = append(idx.byName[name], id)
+		}
+	}
+	for _, ids := range idx.byName {
+		sort.Strings(ids)
+	}
+	return idx
+}
+
+// resolve returns the symbol ID a call from caller to callee refers to. It
+// reports ambiguous when several symbols match and none is preferred.
+func (idx *callIndex) resolve(caller, callee string) (id string, ambiguous bool) {
+	if id, ok := idx.table[callee]; ok {
+		return id, false
+	}
+	callerFile, callerName, _ := strings.Cut(caller, ":")
+	callerDir := filepath.Dir(callerFile)
+
+	qualifier, name := "", callee
+	if i := strings.LastIndex(callee, "."); i >= 0 {
+		qualifier, name = callee[:i], callee[i+1:]
+	}
+	candidates := idx.byName[name]
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	if qualifier == "" {
+		// A plain call: the caller's package first, then anywhere (imports)
+		if id, n := pick(candidates, func(c string) bool { return filepath.Dir(symbolFile(c)) == callerDir }); n == 1 {
+			return id, false
+		} else if n == 0 && len(candidates) == 1 {
+			return candidates[0], false
+		}
+		return "", true
+	}
+
+	// A qualified call: a method of the pkg.Type the extractor typed the value
+	// with, a method on the caller's own receiver, a symbol of the package named
+	// by the qualifier, or a method declared next to the caller
+	receiver, _, isMethod := strings.Cut(callerName, ".")
+	pkg, typ, typed := strings.Cut(qualifier, ".")
+	preferences := []func(string) bool{
+		func(c string) bool {
+			return typed && extractSymbolName(c) == typ+"."+name && filepath.Base(filepath.Dir(symbolFile(c))) == pkg
+		},
+		func(c string) bool { return isMethod && receiver != "" && extractSymbolName(c) == receiver+"."+name },
+		func(c string) bool { return filepath.Base(filepath.Dir(symbolFile(c))) == qualifier },
+		func(c string) bool { return filepath.Dir(symbolFile(c)) == callerDir },
+	}
+	for _, prefer := range preferences {
+		switch id, n := pick(candidates, prefer); {
+		case n == 1:
+			return id, false
+		case n > 1:
+			return "", true
+		}
+	}
+	// The qualifier is a value of a type declared elsewhere, or of another module
+	return "", len(candidates) > 1
+}
+
+// pick returns the candidates matching prefer: the only one, and their count.
+func pick(candidates []string, prefer func(string) bool) (string, int) {
+	var id string
+	n := 0
+	for _, c := range candidates {
+		if prefer(c) {
+			id = c
+			n++
+		}
+	}
+	return id, n
+}
+
+// symbolFile returns the file part of a symbol ID.
+func symbolFile(id string) string {
+	file, _, _ := strings.Cut(id, ":")
+	return file
+}
+
+// resolveCalls is the second phase of call resolution for projectName: calls
+// whose object is still a bare name are resolved against the session's symbol
+// table and rewritten. Since facts are only deleted by subject, each caller
+// with a resolved call has all its facts rewritten, through rec so that
+// incremental runs can undo it. The report is kept in state.Calls.
+func resolveCalls(ctx context.Context, s *meb.MEBStore, projectName string, state *IngestState, rec *gcamdb.VersionRecorder) *CallReport {
+	idx := state.newCallIndex()
+	report := &CallReport{}
+	rewrites := make(map[string]map[callRef]string) // caller -> call -> symbol ID
+	unresolved := make(map[string]*UnresolvedCall)
+
+	scoped := projectScope(ctx, s, projectName)
+	for _, predicate := range resolvedPredicates {
+		for fact, err := range gcamdb.Scan(scoped, s, "", predicate, "") {
+			if err != nil || fact.Subject == "" {
+				continue
+			}
+			callee, ok := fact.Object.(string)
+			if !ok || callee == "" || strings.Contains(callee, ":") {
+				continue
+			}
+			report.Pending++
+			id, ambiguous := idx.resolve(fact.Subject, callee)
+			if id != "" {
+				report.Resolved++
+				if rewrites[fact.Subject] == nil {
+					rewrites[fact.Subject] = make(map[callRef]string)
+				}
+				rewrites[fact.Subject][callRef{predicate, callee}] = id
+				continue
+			}
+			if ambiguous {
+				report.Ambiguous++
+			} else {
+				report.External++
+			}
+			u := unresolved[callee]
+			if u == nil {
+				u = &UnresolvedCall{Callee: callee, Ambiguous: ambiguous}
+				unresolved[callee] = u
+			}
+			u.Count++
+		}
+	}
+
+	for caller, callees := range rewrites {
+		if err := rewriteCalls(ctx, s, rec, caller, callees); err != nil {
+			logger.Warn("Could not rewrite resolved calls", "caller", caller, "error", err)
+		}
+	}
+
+	for _, u := range unresolved {
+		report.Unresolved = append(report.Unresolved, *u)
+	}
+	sort.Slice(report.Unresolved, func(i, j int) bool {
+		a, b := report.Unresolved[i], report.Unresolved[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Callee < b.Callee
+	})
+	if len(report.Unresolved) > config.UnresolvedCallsReported {
+		report.Unresolved = report.Unresolved[:config.UnresolvedCallsReported]
+	}
+
+	logger.Info("Resolved calls", "project", projectName, "pending", report.Pending, "resolved", report.Resolved,
+		"ambiguous", report.Ambiguous, "external", report.External)
+	state.Calls = report
+	return report
+}
+
+// rewriteCalls replaces the calls of caller in resolved with calls to their
+// symbol IDs.
+func rewriteCalls(ctx context.Context, s *meb.MEBStore, rec *gcamdb.VersionRecorder, caller string, resolved map[callRef]string) error {
+	var facts []meb.Fact
+	seen := make(map[string]bool)
+	for fact, err := range s.ScanInTopicContext(ctx, s.TopicID(), caller, "", "") {
+		if err != nil || fact.Subject == "" {
+			continue
+		}
+		if callee, ok := fact.Object.(string); ok {
+			if id, ok := resolved[callRef{fact.Predicate, callee}]; ok {
+				fact.Object = id
+			}
+		}
+		key := fmt.Sprintf("%s\x00%T\x00%v", fact.Predicate, fact.Object, fact.Object)
+		if !seen[key] {
+			seen[key] = true
+			facts = append(facts, fact)
+		}
+	}
+	if err := rec.DeleteSubject(s, caller); err != nil {
+		return err
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		return err
+	}
+	rec.AddSubject(caller)
+	return nil
+}
@@ -0,0 +1,98 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestCallIndexResolve(t *testing.T) {
+	state := NewIngestState()
+	state.fileSymbols = map[string]map[string]string{
+		"app/main.go":       {"main": "app/main.go:main", "run": "app/main.go:run"},
+		"app/util/util.go":  {"Helper": "app/util/util.go:Helper"},
+		"app/db/db.go":      {"Close": "app/db/db.go:DB.Close", "DB.Close": "app/db/db.go:DB.Close", "flush": "app/db/db.go:DB.flush"},
+		"app/cache/lru.go":  {"Close": "app/cache/lru.go:LRU.Close", "LRU.Close": "app/cache/lru.go:LRU.Close"},
+		"app/cache/info.go": {"describe": "app/cache/info.go:describe"},
+	}
+	state.rebuildSymbolTable()
+	idx := state.newCallIndex()
+
+	for _, tc := range []struct {
+		caller, callee string
+		want           string
+		ambiguous      bool
+	}{
+		{"app/main.go:main", "run", "app/main.go:run", false},
+		{"app/main.go:main", "util.Helper", "app/util/util.go:Helper", false},
+		{"app/main.go:main", "DB.Close", "app/db/db.go:DB.Close", false},
+		{"app/db/db.go:DB.Close", "d.flush", "app/db/db.go:DB.flush", false},
+		{"app/db/db.go:DB.Close", "c.Close", "app/db/db.go:DB.Close", false},
+		{"app/cache/lru.go:LRU.Close", "describe", "app/cache/info.go:describe", false},
+		{"app/main.go:main", "conn.Close", "", true},
+		{"app/main.go:main", "client.Send", "", false},
+	} {
+		got, ambiguous := idx.resolve(tc.caller, tc.callee)
+		if got != tc.want || ambiguous != tc.ambiguous {
+			t.Errorf("resolve(%s, %s) = %q, %v; want %q, %v", tc.caller, tc.callee, got, ambiguous, tc.want, tc.ambiguous)
+		}
+	}
+}
+
+func TestResolveCallsRewritesFacts(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":      "package main\n\nfunc main() {\n\tutil.Helper()\n\tremote.Call()\n}\n",
+		"util/util.go": "package util\n\n// Helper helps.\nfunc Helper() {}\n",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := NewIngestState()
+	if err := RunContext(context.Background(), s, "app", src, state, &IngestOptions{SkipEmbeddings: true}); err != nil {
+		t.Fatal(err)
+	}
+	if state.Calls == nil || state.Calls.Resolved != 1 || state.Calls.External != 1 {
+		t.Fatalf("call report = %+v, want 1 resolved and 1 external", state.Calls)
+	}
+	if len(state.Calls.Unresolved) != 1 || state.Calls.Unresolved[0].Callee != "remote.Call" {
+		t.Errorf("unresolved calls = %+v, want remote.Call", state.Calls.Unresolved)
+	}
+
+	calls := make(map[string]bool)
+	var types int
+	for fact, err := range s.ScanInTopicContext(context.Background(), gcamdb.TopicForProject("app"), "app/main.go:main", "", "") {
+		if err != nil || fact.Subject == "" {
+			continue
+		}
+		switch fact.Predicate {
+		case config.PredicateCalls:
+			calls[fact.Object.(string)] = true
+		case config.PredicateType:
+			types++
+		}
+	}
+	if !calls["app/util/util.go:Helper"] || calls["util.Helper"] || !calls["remote.Call"] {
+		t.Errorf("calls of main = %v, want util.Helper rewritten and remote.Call kept", calls)
+	}
+	if types == 0 {
+		t.Error("rewriting calls dropped the caller's other facts")
+	}
+}
@@ -0,0 +1,59 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+)
+
+func TestComponentRendersAndHooks(t *testing.T) {
+	src := `import { useState } from "react";
+
+export function App() {
+  const [open, setOpen] = useState(false);
+  const user = useCurrentUser();
+  return (
+    <Layout>
+      <Menu.Item label="home" />
+      <div>{open && <Modal onClose={() => setOpen(false)} />}</div>
+    </Layout>
+  );
+}
+
+const Modal = ({ onClose }) => {
+  React.useEffect(() => {}, []);
+  return <button onClick={onClose}><Icon /></button>;
+};
+`
+	refs, err := NewTreeSitterExtractor().ExtractReferences("app.tsx", []byte(src), "web/app.tsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, r := range refs {
+		if r.Predicate == config.PredicateRenders || r.Predicate == config.PredicateUsesHook {
+			got[r.Subject+" "+r.Predicate+" "+r.Object] = true
+		}
+	}
+	for _, want := range []string{
+		"web/app.tsx:App renders Layout",
+		"web/app.tsx:App renders Menu.Item",
+		"web/app.tsx:App renders Modal",
+		"web/app.tsx:App uses_hook useState",
+		"web/app.tsx:App uses_hook useCurrentUser",
+		"web/app.tsx:Modal renders Icon",
+		"web/app.tsx:Modal uses_hook React.useEffect",
+	} {
+		if !got[want] {
+			t.Errorf("missing %q", want)
+		}
+	}
+	for fact := range got {
+		if fact == "web/app.tsx:App renders div" || fact == "web/app.tsx:Modal renders button" {
+			t.Errorf("DOM element recorded as component: %q", fact)
+		}
+	}
+	if len(got) != 7 {
+		t.Errorf("got %d renders and hook facts, want 7: %v", len(got), got)
+	}
+}
@@ -0,0 +1,269 @@
+package ingest
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Calls through a Go interface value are modelled in two steps. While a file
+// is parsed, a method call on a receiver, parameter or variable of a known
+// type is recorded against that type ("Store.Get" rather than "s.Get"), so
+// that call resolution points calls on an interface value at the interface's
+// method. Once every file is stored, linkImplementations records which types
+// implement each interface and adds a may_call fact from every such caller to
+// each implementation of the method.
+
+// addGoTypedNames records in locals the type of each name a parameter list,
+// function literal or var spec declares, when it is a named type.
+func addGoTypedNames(n *sitter.Node, content []byte, locals map[string]string) {
+	if n == nil {
+		return
+	}
+	switch n.Kind() {
+	case "parameter_list":
+		for i := uint(0); i < uint(n.NamedChildCount()); i++ {
+			addGoTypedNames(n.NamedChild(i), content, locals)
+		}
+	case "func_literal":
+		addGoTypedNames(n.ChildByFieldName("parameters"), content, locals)
+	case "parameter_declaration", "variadic_parameter_declaration", "var_spec":
+		typ := goNamedType(n.ChildByFieldName("type"), content)
+		values := n.ChildByFieldName("value")
+		var names []string
+		for i := uint(0); i < uint(n.NamedChildCount()); i++ {
+			if c := n.NamedChild(i); c.Kind() == "identifier" {
+				names = append(names, clean(c.Utf8Text(content)))
+			}
+		}
+		for i, name := range names {
+			t := typ
+			if t == "" && values != nil && uint(i) < uint(values.NamedChildCount()) {
+				t = goLiteralType(values.NamedChild(uint(i)), content)
+			}
+			if t != "" && name != "_" {
+				locals[name] = t
+			}
+		}
+	}
+}
+
+// addGoLiteralTypes records in locals the type of each variable a short
+// variable declaration initializes with a composite literal, such as
+// s := &Store{}, and of the value variable of a range over a typed variable.
+func addGoLiteralTypes(n *sitter.Node, content []byte, locals map[string]string) {
+	left, right := n.ChildByFieldName("left"), n.ChildByFieldName("right")
+	if left == nil || right == nil {
+		return
+	}
+	if n.Kind() == "range_clause" {
+		// Element types are what locals holds for slices and maps
+		if left.NamedChildCount() == 2 && right.Kind() == "identifier" {
+			if t, ok := locals[clean(right.Utf8Text(content))]; ok {
+				locals[clean(left.NamedChild(1).Utf8Text(content))] = t
+			}
+		}
+		return
+	}
+	for i := uint(0); i < uint(left.NamedChildCount()) && i < uint(right.NamedChildCount()); i++ {
+		if t := goLiteralType(right.NamedChild(i), content); t != "" {
+			locals[clean(left.NamedChild(i).Utf8Text(content))] = t
+		}
+	}
+}
+
+// goLiteralType returns the type of a composite literal or of its address.
+func goLiteralType(n *sitter.Node, content []byte) string {
+	switch n.Kind() {
+	case "composite_literal":
+		return goNamedType(n.ChildByFieldName("type"), content)
+	case "unary_expression":
+		if operand := n.ChildByFieldName("operand"); operand != nil {
+			return goLiteralType(operand, content)
+		}
+	}
+	return ""
+}
+
+// goNamedType returns the named type a type expression holds, in the form
+// fieldTypeName gives it, or "" for unnamed types and error.
+func goNamedType(n *sitter.Node, content []byte) string {
+	typ := fieldTypeName(n, content)
+	if typ == "" || typ == "error" {
+		return ""
+	}
+	for _, r := range typ {
+		if r != '.' && r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9') {
+			return ""
+		}
+	}
+	return typ
+}
+
+// goTypedCallee returns the callee of a method call on a variable of known
+// type as Type.Method or pkg.Type.Method, or "" if the type is not known.
+func (e *TreeSitterExtractor) goTypedCallee(funcNode *sitter.Node, content []byte) string {
+	if e.goLocals == nil || funcNode.Kind() != "selector_expression" {
+		return ""
+	}
+	operand, field := funcNode.ChildByFieldName("operand"), funcNode.ChildByFieldName("field")
+	if operand == nil || field == nil || operand.Kind() != "identifier" {
+		return ""
+	}
+	typ, ok := e.goLocals[clean(operand.Utf8Text(content))]
+	if !ok {
+		return ""
+	}
+	return typ + "." + clean(field.Utf8Text(content))
+}
+
+// goTypeKey identifies a Go type by its package directory and name, since a
+// type's methods may be declared in any file of its package.
+type goTypeKey struct {
+	dir  string
+	name string
+}
+
+// goMethodSets groups the session's Go symbols by type: the ID of each type
+// and the IDs of its methods by name. Interface methods are grouped under
+// their interface.
+func (state *IngestState) goMethodSets() (types map[goTypeKey]string, methods map[goTypeKey]map[string]string) {
+	types = make(map[goTypeKey]string)
+	methods = make(map[goTypeKey]map[string]string)
+	for file, keys := range state.fileSymbols {
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		dir := filepath.Dir(file)
+		for _, id := range keys {
+			name := extractSymbolName(id)
+			receiver, method, isMethod := strings.Cut(name, ".")
+			if !isMethod {
+				types[goTypeKey{dir, name}] = id
+				continue
+			}
+			key := goTypeKey{dir, receiver}
+			if methods[key] == nil {
+				methods[key] = make(map[string]string)
+			}
+			methods[key][method] = id
+		}
+	}
+	return types, methods
+}
+
+// linkImplementations adds implements facts from each Go type of projectName
+// to the interfaces whose method set its methods cover, and may_call facts
+// from each caller of an interface method to that method of every
+// implementation. Interfaces embedding an interface declared outside their
+// package are skipped, since their method set is not known. Facts are added
+// through rec so that incremental runs can undo them.
+func linkImplementations(ctx context.Context, s *meb.MEBStore, projectName string, state *IngestState, rec *gcamdb.VersionRecorder) {
+	scoped := projectScope(ctx, s, projectName)
+	types, methods := state.goMethodSets()
+
+	ifaces := make(map[goTypeKey]string)
+	for fact, err := range gcamdb.Scan(scoped, s, "", config.PredicateType, TypeInterface) {
+		if err != nil || fact.Subject == "" || filepath.Ext(symbolFile(fact.Subject)) != ".go" {
+			continue
+		}
+		ifaces[goTypeKey{filepath.Dir(symbolFile(fact.Subject)), extractSymbolName(fact.Subject)}] = fact.Subject
+	}
+
+	// Method sets of the interfaces, with those of embedded interfaces
+	embeds := make(map[goTypeKey][]string)
+	for key, id := range ifaces {
+		for fact, err := range gcamdb.Scan(scoped, s, id, config.PredicateEmbeds, "") {
+			if err != nil || fact.Subject == "" {
+				continue
+			}
+			if name, ok := fact.Object.(string); ok {
+				embeds[key] = append(embeds[key], name)
+			}
+		}
+	}
+	var methodSet func(key goTypeKey, depth int) (map[string]bool, bool)
+	methodSet = func(key goTypeKey, depth int) (map[string]bool, bool) {
+		if depth > 5 {
+			return nil, false
+		}
+		set := make(map[string]bool)
+		for name := range methods[key] {
+			set[name] = true
+		}
+		for _, embed := range embeds[key] {
+			embedded := goTypeKey{key.dir, embed}
+			if _, ok := ifaces[embedded]; !ok {
+				return nil, false
+			}
+			inner, ok := methodSet(embedded, depth+1)
+			if !ok {
+				return nil, false
+			}
+			for name := range inner {
+				set[name] = true
+			}
+		}
+		return set, true
+	}
+
+	var facts []meb.Fact
+	implementations := make(map[string][]string) // interface method ID -> implementing method IDs
+	implements := 0
+	for ifaceKey, ifaceID := range ifaces {
+		set, ok := methodSet(ifaceKey, 0)
+		if !ok || len(set) == 0 {
+			continue
+		}
+		for typeKey, typeID := range types {
+			if _, isIface := ifaces[typeKey]; isIface || !covers(methods[typeKey], set) {
+				continue
+			}
+			facts = append(facts, meb.Fact{Subject: typeID, Predicate: config.PredicateImplements, Object: ifaceID})
+			implements++
+			for name := range methods[ifaceKey] {
+				implementations[methods[ifaceKey][name]] = append(implementations[methods[ifaceKey][name]], methods[typeKey][name])
+			}
+		}
+	}
+
+	mayCalls := 0
+	for ifaceMethod, impls := range implementations {
+		sort.Strings(impls)
+		for fact, err := range gcamdb.Scan(scoped, s, "", config.PredicateCalls, ifaceMethod) {
+			if err != nil || fact.Subject == "" {
+				continue
+			}
+			for _, impl := range impls {
+				facts = append(facts, meb.Fact{Subject: fact.Subject, Predicate: config.PredicateMayCall, Object: impl})
+				mayCalls++
+			}
+		}
+	}
+
+	if len(facts) > 0 {
+		if err := s.AddFactBatch(facts); err != nil {
+			logger.Warn("Could not store interface implementations", "project", projectName, "error", err)
+			return
+		}
+		rec.AddFacts(facts...)
+	}
+	logger.Info("Linked interface implementations", "project", projectName, "implements", implements, "may_call", mayCalls)
+}
+
+// covers reports whether a type's methods include every name of set.
+func covers(methods map[string]string, set map[string]bool) bool {
+	for name := range set {
+		if _, ok := methods[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
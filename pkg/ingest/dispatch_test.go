@@ -0,0 +1,78 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestInterfaceDispatch(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod": "module example.com/app\n",
+		"main.go": "package main\n\nfunc main() {\n\tfor _, st := range []storage.Store{&disk.Disk{}} {\n\t\trun(st)\n\t}\n}\n\n" +
+			"func run(st storage.Store) {\n\tst.Save(\"a\")\n}\n",
+		"storage/storage.go": "package storage\n\ntype Store interface {\n\tReader\n\tSave(key string) error\n}\n\n" +
+			"type Reader interface {\n\tLoad(key string) string\n}\n\n" +
+			"type Cache struct{}\n\nfunc (c *Cache) Save(key string) error { return nil }\n",
+		"disk/disk.go":  "package disk\n\ntype Disk struct{}\n\nfunc (d *Disk) Save(key string) error { return nil }\n",
+		"disk/load.go":  "package disk\n\nfunc (d Disk) Load(key string) string { return key }\n",
+		"mem/mem.go":    "package mem\n\ntype Mem struct{ m map[string]string }\n\nfunc (m *Mem) Save(key string) error { return nil }\n\nfunc (m *Mem) Load(key string) string { return m.m[key] }\n",
+		"mem/unused.go": "package mem\n\ntype Loader struct{}\n\nfunc (l Loader) Load(key string) string { return key }\n",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RunContext(context.Background(), s, "app", src, NewIngestState(), &IngestOptions{SkipEmbeddings: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	for _, predicate := range []string{config.PredicateCalls, config.PredicateImplements, config.PredicateMayCall} {
+		for fact, err := range s.ScanInTopicContext(context.Background(), gcamdb.TopicForProject("app"), "", predicate, "") {
+			if err != nil || fact.Subject == "" {
+				continue
+			}
+			got[fact.Subject+" "+fact.Predicate+" "+fact.Object.(string)] = true
+		}
+	}
+	for _, want := range []string{
+		"app/main.go:run calls app/storage/storage.go:Store.Save",
+		"app/disk/disk.go:Disk implements app/storage/storage.go:Store",
+		"app/disk/disk.go:Disk implements app/storage/storage.go:Reader",
+		"app/mem/mem.go:Mem implements app/storage/storage.go:Store",
+		"app/mem/unused.go:Loader implements app/storage/storage.go:Reader",
+		"app/main.go:run may_call app/disk/disk.go:Disk.Save",
+		"app/main.go:run may_call app/mem/mem.go:Mem.Save",
+	} {
+		if !got[want] {
+			t.Errorf("missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{
+		"app/storage/storage.go:Cache implements app/storage/storage.go:Store",
+		"app/main.go:run may_call app/storage/storage.go:Cache.Save",
+		"app/storage/storage.go:Store implements app/storage/storage.go:Reader",
+	} {
+		if got[unwanted] {
+			t.Errorf("unexpected %q", unwanted)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ingest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+type constEmbedder struct{}
+
+func (constEmbedder) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vec := make([]float32, 1536)
+	vec[0] = 1
+	return vec, nil
+}
+
+func TestDocNodes(t *testing.T) {
+	src := "package auth\n\n// ValidateToken checks the session signature.\nfunc ValidateToken() {}\n\nfunc undocumented() {}\n"
+	bundle, err := NewTreeSitterExtractor().Extract(context.Background(), "auth/login.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docID := gcamdb.DocNodeID("auth/login.go:ValidateToken")
+	var docs int
+	for _, doc := range bundle.Documents {
+		if gcamdb.IsDocNode(doc.ID) {
+			docs++
+			if doc.ID != docID || string(doc.Content) != "// ValidateToken checks the session signature." {
+				t.Errorf("doc node %q = %q", doc.ID, doc.Content)
+			}
+			if len(doc.Metadata) != 0 {
+				t.Errorf("doc node metadata = %v, want none", doc.Metadata)
+			}
+		}
+	}
+	if docs != 1 {
+		t.Errorf("got %d doc nodes, want 1", docs)
+	}
+	var linked bool
+	for _, f := range bundle.Facts {
+		if f.Subject == docID && f.Predicate == config.PredicateDocuments {
+			linked = f.Object == "auth/login.go:ValidateToken"
+		}
+	}
+	if !linked {
+		t.Error("doc node is not linked to its symbol")
+	}
+
+	targets := embedTargets(bundle, nil)
+	if len(targets) != 1 || targets[0].symbolID != "auth/login.go:ValidateToken" || targets[0].docID != docID {
+		t.Fatalf("embed targets = %+v, want ValidateToken sharing with its doc node", targets)
+	}
+	if !gcamdb.IsUID(targets[0].uid) {
+		t.Fatalf("embed target UID = %q, want the symbol's UID", targets[0].uid)
+	}
+
+	cfg := store.DefaultConfig(t.TempDir())
+	cfg.SegmentDir = filepath.Join(cfg.DataDir, "vectors")
+	s, err := meb.NewMEBStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AddFactBatch(bundle.Facts); err != nil {
+		t.Fatal(err)
+	}
+	pool := startEmbedPool(context.Background(), s, constEmbedder{}, &IngestOptions{EmbedWorkers: 1}, nil)
+	pool.enqueue(context.Background(), targets[0])
+	pool.wait()
+	// The symbol's vector is keyed by its UID
+	for _, id := range []string{targets[0].uid, docID} {
+		dictID, found := s.LookupID(id)
+		if !found {
+			t.Fatalf("%s is not in the dictionary", id)
+		}
+		if !s.Vectors().HasVector(dictID) {
+			t.Errorf("%s has no vector", id)
+		}
+	}
+}
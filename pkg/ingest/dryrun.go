@@ -0,0 +1,191 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// DryRunCounts is what an ingest would produce for a set of files.
+type DryRunCounts struct {
+	Name       string `json:"name"`
+	Files      int    `json:"files"`
+	Symbols    int    `json:"symbols"`
+	Facts      int    `json:"facts"`
+	Embeddings int    `json:"embeddings"`
+	Bytes      int64  `json:"bytes"` // Source content
+}
+
+// EstimatedBytes estimates the store space the files would take, from the
+// per-item sizes in config.
+func (c DryRunCounts) EstimatedBytes() int64 {
+	return c.Bytes +
+		int64(c.Facts)*config.DryRunFactBytes +
+		int64(c.Symbols)*config.DryRunDocumentBytes +
+		int64(c.Embeddings)*config.DryRunVectorBytes
+}
+
+func (c *DryRunCounts) add(o DryRunCounts) {
+	c.Files += o.Files
+	c.Symbols += o.Symbols
+	c.Facts += o.Facts
+	c.Embeddings += o.Embeddings
+	c.Bytes += o.Bytes
+}
+
+// SkippedFile is a file or directory an ingest would not process.
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// DryRunReport summarizes an ingest that was parsed but not written. Only
+// per-file facts are counted; the project-wide passes run after parsing
+// (manifests, virtual triples, roles, entry points) add a few more.
+type DryRunReport struct {
+	Project     string         `json:"project"`
+	Total       DryRunCounts   `json:"total"`
+	Languages   []DryRunCounts `json:"languages"`   // Sorted by name
+	Directories []DryRunCounts `json:"directories"` // Sorted by name; "." is the source root
+	Skipped     []SkippedFile  `json:"skipped"`     // Sorted by path
+}
+
+// fileLanguages names the language of each supported file extension.
+var fileLanguages = map[string]string{
+	".go":  "go",
+	".ts":  "typescript",
+	".tsx": "typescript",
+	".js":  "javascript",
+	".py":  "python",
+	".md":  "markdown",
+}
+
+// DryRun parses sourceDir as RunWithOptions would and reports the files,
+// symbols, facts and embeddings it would produce per language and directory,
+// and the files it would skip, without touching a store. Embeddings are counted
+// as if an embedding service were available unless opts.SkipEmbeddings is set.
+func DryRun(ctx context.Context, projectName, sourceDir string, opts *IngestOptions) (*DryRunReport, error) {
+	var projectMeta *ProjectMetadata
+	metadataPath := filepath.Join(sourceDir, "project.yaml")
+	if _, err := os.Stat(metadataPath); err == nil {
+		if projectMeta, err = LoadProjectMetadata(metadataPath); err != nil {
+			logger.Warn("Failed to load project metadata", "error", err)
+		}
+	}
+	state := NewIngestState()
+	state.Owners = loadOwnership(sourceDir, projectMeta)
+
+	var mu sync.Mutex
+	languages := make(map[string]*DryRunCounts)
+	dirs := make(map[string]*DryRunCounts)
+	report := &DryRunReport{Project: projectName}
+	skip := func(path, reason string) {
+		mu.Lock()
+		report.Skipped = append(report.Skipped, SkippedFile{Path: path, Reason: reason})
+		mu.Unlock()
+	}
+	count := func(groups map[string]*DryRunCounts, name string, c DryRunCounts) {
+		g := groups[name]
+		if g == nil {
+			g = &DryRunCounts{Name: name}
+			groups[name] = g
+		}
+		g.add(c)
+	}
+
+	jobs := make(chan string, opts.jobBuffer())
+	var wg sync.WaitGroup
+	for i := 0; i < opts.parseWorkers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ext := NewTreeSitterExtractor()
+			for rel := range jobs {
+				content, generated, reason, err := loadSource(filepath.Join(sourceDir, rel))
+				if err != nil {
+					skip(rel, fmt.Sprintf("unreadable: %v", err))
+					continue
+				}
+				if reason != "" {
+					skip(rel, reason)
+					continue
+				}
+				relPath := logicalPath(rel, projectName, projectMeta)
+				bundle, err := ext.Extract(ctx, relPath, content)
+				if err != nil {
+					skip(rel, fmt.Sprintf("parse failed: %v", err))
+					continue
+				}
+				trimBundle(bundle, relPath, generated)
+
+				c := DryRunCounts{Files: 1, Bytes: int64(len(content))}
+				for _, doc := range bundle.Documents {
+					if doc.ID != relPath && !gcamdb.IsDocNode(doc.ID) {
+						c.Symbols++
+					}
+				}
+				c.Facts = len(fileFacts(relPath, bundle, state.Owners.OwnersFor(rel), projectMeta, state))
+				if !generated && (opts == nil || !opts.SkipEmbeddings) {
+					c.Embeddings = len(embedTargets(bundle, opts))
+				}
+
+				mu.Lock()
+				report.Total.add(c)
+				count(languages, fileLanguages[filepath.Ext(rel)], c)
+				count(dirs, filepath.Dir(rel), c)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	walkErr := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(sourceDir, path)
+		if d.IsDir() {
+			if d.Name() == "node_modules" || d.Name() == ".git" || d.Name() == "dist" || d.Name() == "build" || d.Name() == ".next" {
+				skip(rel+string(filepath.Separator), "excluded directory")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isSupportedFile(path) {
+			skip(rel, "unsupported file type")
+			return nil
+		}
+		jobs <- rel
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	if walkErr != nil {
+		return nil, fmt.Errorf("dry run failed: %w", walkErr)
+	}
+
+	report.Total.Name = "total"
+	report.Languages = sortedCounts(languages)
+	report.Directories = sortedCounts(dirs)
+	sort.Slice(report.Skipped, func(i, j int) bool { return report.Skipped[i].Path < report.Skipped[j].Path })
+	return report, nil
+}
+
+func sortedCounts(groups map[string]*DryRunCounts) []DryRunCounts {
+	out := make([]DryRunCounts, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"main.go":                 "package main\n\n// main starts the service.\nfunc main() { run() }\n\nfunc run() {}\n",
+		"web/app.ts":              "export function render(): void {}\n",
+		"Dockerfile":              "FROM scratch\n",
+		"node_modules/x/index.js": "module.exports = 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := DryRun(context.Background(), "app", src, nil)
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if report.Total.Files != 2 || report.Total.Symbols < 3 || report.Total.Facts == 0 {
+		t.Errorf("total = %+v, want 2 files and at least 3 symbols", report.Total)
+	}
+	if report.Total.Embeddings != 1 {
+		t.Errorf("embeddings = %d, want 1 (the documented main)", report.Total.Embeddings)
+	}
+	if len(report.Languages) != 2 || report.Languages[0].Name != "go" || report.Languages[1].Name != "typescript" {
+		t.Errorf("languages = %+v, want go and typescript", report.Languages)
+	}
+	if len(report.Directories) != 2 || report.Directories[0].Name != "." || report.Directories[1].Name != "web" {
+		t.Errorf("directories = %+v, want . and web", report.Directories)
+	}
+	want := []SkippedFile{
+		{Path: "Dockerfile", Reason: "unsupported file type"},
+		{Path: "node_modules" + string(filepath.Separator), Reason: "excluded directory"},
+	}
+	if len(report.Skipped) != len(want) || report.Skipped[0] != want[0] || report.Skipped[1] != want[1] {
+		t.Errorf("skipped = %+v, want %+v", report.Skipped, want)
+	}
+	if report.Total.EstimatedBytes() <= report.Total.Bytes {
+		t.Error("estimate does not exceed the source size")
+	}
+
+	noEmbed, err := DryRun(context.Background(), "app", src, &IngestOptions{SkipEmbeddings: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if noEmbed.Total.Embeddings != 0 {
+		t.Errorf("embeddings with SkipEmbeddings = %d, want 0", noEmbed.Total.Embeddings)
+	}
+}
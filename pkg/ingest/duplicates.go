@@ -0,0 +1,68 @@
+package ingest
+
+import (
+	"context"
+	"maps"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// detectDuplicates tags the project's near-duplicate functions and methods
+// with similar_to facts, replacing those of earlier runs. Functions with an
+// embedding are paired by the sketches of their vectors, the sketches stored
+// in this run added to the project's; the others by their code's shingles.
+// Functions shorter than config.DuplicateMinTokens tokens are left out.
+func detectDuplicates(ctx context.Context, s *meb.MEBStore, projectName string, stored map[string]gcamdb.VectorSketch) {
+	ctx = projectScope(ctx, s, projectName)
+	sketches, err := gcamdb.LoadVectorSketches(s, projectName)
+	if err != nil {
+		logger.Warn("Could not load vector sketches", "project", projectName, "error", err)
+	}
+	if sketches == nil {
+		sketches = make(map[string]gcamdb.VectorSketch)
+	}
+	maps.Copy(sketches, stored)
+	// Forget the sketches of vectors deleted with their symbols
+	for key := range sketches {
+		if dictID, found := s.LookupID(key); !found || !s.Vectors().HasVector(dictID) {
+			delete(sketches, key)
+		}
+	}
+	if err := gcamdb.SaveVectorSketches(s, projectName, sketches); err != nil {
+		logger.Warn("Could not save vector sketches", "project", projectName, "error", err)
+	}
+
+	embedded := make(map[string]gcamdb.VectorSketch) // Functions with an embedding
+	tokens := make(map[string][]string)              // Code tokens of functions without one
+	for _, kind := range []string{TypeFunction, TypeMethod} {
+		for fact, err := range gcamdb.Scan(ctx, s, "", config.PredicateType, kind) {
+			if err != nil {
+				continue
+			}
+			code, err := gcamdb.GetSymbolSnippet(ctx, s, fact.Subject)
+			if err != nil {
+				continue
+			}
+			toks := gcamdb.CodeTokens(code)
+			if len(toks) < config.DuplicateMinTokens {
+				continue
+			}
+			if sketch, ok := sketches[gcamdb.VectorKey(ctx, s, fact.Subject)]; ok {
+				embedded[fact.Subject] = sketch
+			} else {
+				tokens[fact.Subject] = toks
+			}
+		}
+	}
+
+	pairs := gcamdb.SketchPairs(embedded, config.DuplicateMinSimilarity)
+	pairs = append(pairs, gcamdb.ShinglePairs(tokens, config.DuplicateMinJaccard)...)
+	if err := gcamdb.SaveSimilarPairs(ctx, s, pairs); err != nil {
+		logger.Warn("Could not tag duplicate functions", "project", projectName, "error", err)
+		return
+	}
+	logger.Info("Tagged duplicate functions", "project", projectName, "pairs", len(pairs), "embedded", len(embedded), "shingled", len(tokens))
+}
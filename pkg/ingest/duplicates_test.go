@@ -0,0 +1,156 @@
+package ingest
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+const sumBody = `(orders []Order) (int, error) {
+	total := 0
+	for _, order := range orders {
+		if order.Cancelled {
+			continue
+		}
+		for _, line := range order.Lines {
+			total += line.Price * line.Quantity
+		}
+	}
+	if total < 0 {
+		return 0, fmt.Errorf("negative total %d", total)
+	}
+	return total, nil
+}
+`
+
+const parseBody = `(raw string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, part := range strings.Split(raw, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed field %q", part)
+		}
+		fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return fields, nil
+}
+`
+
+const countBody = `(events <-chan Event, done <-chan struct{}) map[string]int {
+	counts := map[string]int{}
+	for {
+		select {
+		case ev := <-events:
+			counts[ev.Kind]++
+			log.Printf("event %s from %s", ev.Kind, ev.Source)
+		case <-done:
+			log.Printf("counted %d kinds of events", len(counts))
+			return counts
+		}
+	}
+}
+`
+
+// textEmbedder embeds texts mentioning orders as one vector and the rest as
+// another.
+type textEmbedder struct{}
+
+func (textEmbedder) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vec := make([]float32, 1536)
+	for i := range vec {
+		if strings.Contains(text, "orders") {
+			vec[i] = float32(math.Sin(float64(i)))
+		} else {
+			vec[i] = float32(math.Cos(float64(i) * 1.7))
+		}
+	}
+	return vec, nil
+}
+
+func TestDetectDuplicates(t *testing.T) {
+	cfg := store.DefaultConfig(t.TempDir())
+	cfg.SegmentDir = filepath.Join(cfg.DataDir, "vectors")
+	s, err := meb.NewMEBStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(src, name), []byte("package shop\n\n"+content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.go", "func SumOrders"+sumBody)
+	write("b.go", "func TotalOrders"+sumBody)
+	write("c.go", "func ParseFields"+parseBody)
+	opts := &IngestOptions{SkipEmbeddings: true}
+	if err := RunWithOptions(s, "app", src, NewIngestState(), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs := func() []gcamdb.SimilarPair {
+		t.Helper()
+		pairs, err := gcamdb.SimilarPairs(projectScope(context.Background(), s, "app"), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pairs
+	}
+	// Without embeddings, copies are paired by their shingles
+	got := pairs()
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "app/a.go:SumOrders", got[0].A)
+		assert.Equal(t, "app/b.go:TotalOrders", got[0].B)
+		assert.Greater(t, got[0].Score, 0.8)
+	}
+	var both int
+	for _, id := range []string{"app/a.go:SumOrders", "app/b.go:TotalOrders"} {
+		for _, err := range s.ScanContext(context.Background(), id, config.PredicateSimilarTo, "") {
+			if err == nil {
+				both++
+			}
+		}
+	}
+	assert.Equal(t, 2, both, "similar_to is tagged both ways")
+
+	// A copy that drifts apart is no longer paired
+	write("b.go", "func TotalOrders"+countBody)
+	if err := RunIncrementalWithOptions(s, "app", src, NewIngestState(), opts); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, pairs())
+
+	// Embedded functions are paired by their vectors
+	pool := startEmbedPool(context.Background(), s, textEmbedder{}, &IngestOptions{EmbedWorkers: 1}, nil)
+	for id, text := range map[string]string{
+		"app/a.go:SumOrders":   "Sums the orders",
+		"app/b.go:TotalOrders": "Totals the orders",
+		"app/c.go:ParseFields": "Parses fields",
+	} {
+		uid := gcamdb.UIDOf(context.Background(), s, id)
+		pool.enqueue(context.Background(), symbolEmbedTarget{symbolID: id, uid: uid, text: text})
+	}
+	pool.wait()
+	detectDuplicates(context.Background(), s, "app", pool.embedded())
+	got = pairs()
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "app/a.go:SumOrders", got[0].A)
+		assert.Equal(t, "app/b.go:TotalOrders", got[0].B)
+		assert.InDelta(t, 1, got[0].Score, 1e-9)
+	}
+
+	// Later runs pair them by the sketches stored
+	detectDuplicates(context.Background(), s, "app", nil)
+	assert.Len(t, pairs(), 1)
+}
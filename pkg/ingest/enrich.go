@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+)
+
+// Enricher is a pass over a project's graph run once its files are stored and
+// its calls resolved, such as role tagging or mapping feature flags to the
+// code they guard. Enrichers run in registration order, after the built-in
+// ones; a failing enricher is logged and skipped without failing the ingest.
+type Enricher interface {
+	Name() string
+	Enrich(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error
+}
+
+// EnrichFunc is the pass of an Enricher built with NewEnricher.
+type EnrichFunc func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error
+
+type funcEnricher struct {
+	name string
+	fn   EnrichFunc
+}
+
+func (e funcEnricher) Name() string { return e.name }
+
+func (e funcEnricher) Enrich(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+	return e.fn(ctx, s, projectName, sourceDir)
+}
+
+// NewEnricher returns an Enricher running fn under name.
+func NewEnricher(name string, fn EnrichFunc) Enricher {
+	return funcEnricher{name: name, fn: fn}
+}
+
+// EnrichResult is how one enricher fared in an ingest.
+type EnrichResult struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Facts    int64         `json:"facts"` // Change in the store's fact count
+	Error    string        `json:"error,omitempty"`
+}
+
+var (
+	enrichersMu sync.Mutex
+	enrichers   = []Enricher{
+		NewEnricher("dependencies", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			return IngestManifests(s, projectName, sourceDir)
+		}),
+		NewEnricher("virtual_triples", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			spec, err := loadAPISpec(ctx, sourceDir)
+			if err != nil {
+				logger.Warn("Could not load OpenAPI spec, linking API calls by route", "project", projectName, "error", err)
+			}
+			return enhanceVirtualTriples(s, spec)
+		}),
+		NewEnricher("roles", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			return TagRoles(s)
+		}),
+		NewEnricher("entry_points", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			tagEntryPoints(s, projectName)
+			return nil
+		}),
+		NewEnricher("centrality", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			tagCentralNodes(s, projectName)
+			return nil
+		}),
+	}
+)
+
+// RegisterEnricher adds e to the enrichers every ingest runs, after those
+// already registered. Names must be unique.
+func RegisterEnricher(e Enricher) error {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	for _, registered := range enrichers {
+		if registered.Name() == e.Name() {
+			return fmt.Errorf("enricher %q is already registered", e.Name())
+		}
+	}
+	enrichers = append(enrichers, e)
+	return nil
+}
+
+// Enrichers returns the names of the registered enrichers, in run order.
+func Enrichers() []string {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	names := make([]string, len(enrichers))
+	for i, e := range enrichers {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+// runEnrichers runs the registered enrichers over projectName in order and
+// keeps their results in state.Enrichment. It stops early when ctx is done.
+func runEnrichers(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string, state *IngestState) []EnrichResult {
+	enrichersMu.Lock()
+	pipeline := append([]Enricher(nil), enrichers...)
+	enrichersMu.Unlock()
+
+	results := make([]EnrichResult, 0, len(pipeline))
+	for _, e := range pipeline {
+		if ctx.Err() != nil {
+			break
+		}
+		result := runEnricher(ctx, s, projectName, sourceDir, e)
+		if result.Error != "" {
+			logger.Warn("Enricher failed", "enricher", result.Name, "project", projectName, "duration", result.Duration, "error", result.Error)
+		} else {
+			logger.Info("Ran enricher", "enricher", result.Name, "project", projectName, "duration", result.Duration, "facts", result.Facts)
+		}
+		results = append(results, result)
+	}
+	state.Enrichment = results
+	return results
+}
+
+// runEnricher runs one enricher, turning a panic into its error.
+func runEnricher(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string, e Enricher) (result EnrichResult) {
+	result.Name = e.Name()
+	before := s.Count()
+	began := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprintf("panic: %v", r)
+		}
+		result.Duration = time.Since(began)
+		result.Facts = int64(s.Count()) - int64(before)
+	}()
+	if err := e.Enrich(ctx, s, projectName, sourceDir); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
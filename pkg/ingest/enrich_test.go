@@ -0,0 +1,67 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestRunEnrichers(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	saved := enrichers
+	defer func() { enrichers = saved }()
+	enrichers = nil
+
+	var order []string
+	for _, e := range []Enricher{
+		NewEnricher("flags", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			order = append(order, "flags")
+			return s.AddFactBatch([]meb.Fact{{Subject: projectName + "/main.go:main", Predicate: "guarded_by", Object: "new_ui"}})
+		}),
+		NewEnricher("broken", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			order = append(order, "broken")
+			return errors.New("no flag registry")
+		}),
+		NewEnricher("panicky", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			order = append(order, "panicky")
+			panic("nil map")
+		}),
+		NewEnricher("last", func(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string) error {
+			order = append(order, "last")
+			return nil
+		}),
+	} {
+		if err := RegisterEnricher(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RegisterEnricher(NewEnricher("flags", nil)); err == nil {
+		t.Error("registering a duplicate enricher name succeeded")
+	}
+
+	state := NewIngestState()
+	results := runEnrichers(context.Background(), s, "app", "", state)
+	if len(order) != 4 || order[0] != "flags" || order[3] != "last" {
+		t.Fatalf("enrichers ran in order %v, want registration order with failures isolated", order)
+	}
+	if len(state.Enrichment) != 4 {
+		t.Fatalf("state.Enrichment = %+v, want 4 results", state.Enrichment)
+	}
+	if results[0].Facts != 1 || results[0].Error != "" {
+		t.Errorf("flags result = %+v, want 1 fact and no error", results[0])
+	}
+	if results[1].Error != "no flag registry" {
+		t.Errorf("broken result = %+v, want its error", results[1])
+	}
+	if results[2].Error != "panic: nil map" {
+		t.Errorf("panicky result = %+v, want the recovered panic", results[2])
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"unicode"
 
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 	sitter "github.com/tree-sitter/go-tree-sitter"
 	golang "github.com/tree-sitter/tree-sitter-go/bindings/go"
@@ -16,12 +18,6 @@ import (
 	typescript "github.com/tree-sitter/tree-sitter-typescript/bindings/go"
 )
 
-var currentState *IngestState
-
-func SetIngestState(s *IngestState) {
-	currentState = s
-}
-
 // SymbolType constants
 const (
 	TypeFunction  = "function"
@@ -52,6 +48,9 @@ type Symbol struct {
 	StartLine  int
 	EndLine    int
 	Package    string
+	Metrics    SymbolMetrics // Only populated for functions and methods
+	Fields     []Field       // Struct and interface members
+	Embeds     []string      // Embedded or extended types
 }
 
 // lineFromOffset calculates line number from byte offset.
@@ -66,6 +65,11 @@ func lineFromOffset(content []byte, offset uint) int {
 // TreeSitterExtractor handles AST parsing and symbol extraction.
 type TreeSitterExtractor struct {
 	parser *sitter.Parser
+	files  map[string]bool // Files of the ingest session, for resolving imports; may be nil
+
+	tsConfigs []*tsConfig // Path aliases of the session's TypeScript configs
+
+	goLocals map[string]string // Types of the Go function's receiver, parameters and variables being walked
 }
 
 // NewTreeSitterExtractor creates a new extractor instance for parsing source code.
@@ -172,6 +176,7 @@ func (e *TreeSitterExtractor) ExtractReferences(filename string, content []byte,
 	root := tree.RootNode()
 
 	var refs []Reference
+	e.goLocals = nil
 
 	var walk func(n *sitter.Node, currentScope string)
 	walk = func(n *sitter.Node, currentScope string) {
@@ -271,6 +276,8 @@ func (e *TreeSitterExtractor) processMarkdownFile(relPath string, content []byte
 
 // processSymbols generates documents and facts for extracted symbols.
 func (e *TreeSitterExtractor) processSymbols(bundle *AnalysisBundle, symbols []Symbol, relPath string, filePackage string, tags []string) {
+	scope := uidScope(relPath, filePackage)
+	ranks := make(map[string]int) // Symbols seen per base UID, ranking those alike in every part
 	for _, sym := range symbols {
 		// Create Document
 		doc := Document{
@@ -301,6 +308,22 @@ func (e *TreeSitterExtractor) processSymbols(bundle *AnalysisBundle, symbols []S
 			meb.Fact{Subject: string(sym.ID), Predicate: config.PredicateName, Object: sym.Name},
 			meb.Fact{Subject: string(sym.ID), Predicate: config.PredicateHasName, Object: sym.Name},
 		)
+		_, name, _ := strings.Cut(sym.ID, ":")
+		base := gcamdb.SymbolUID(scope, sym.Type, name, sym.Signature, 0)
+		uid := gcamdb.SymbolUID(scope, sym.Type, name, sym.Signature, ranks[base])
+		ranks[base]++
+		bundle.Facts = append(bundle.Facts, meb.Fact{Subject: sym.ID, Predicate: config.PredicateHasUID, Object: uid})
+
+		// Type composition: members, their types and embedded types
+		for _, f := range sym.Fields {
+			bundle.Facts = append(bundle.Facts, meb.Fact{Subject: string(sym.ID), Predicate: config.PredicateHasField, Object: f.Name})
+			if f.Type != "" {
+				bundle.Facts = append(bundle.Facts, meb.Fact{Subject: sym.ID + "." + f.Name, Predicate: config.PredicateFieldType, Object: f.Type})
+			}
+		}
+		for _, t := range sym.Embeds {
+			bundle.Facts = append(bundle.Facts, meb.Fact{Subject: string(sym.ID), Predicate: config.PredicateEmbeds, Object: t})
+		}
 
 		// Role Tagging
 		if sym.Type == TypeStruct || sym.Type == TypeInterface || sym.Type == TypeClass {
@@ -335,6 +358,28 @@ func (e *TreeSitterExtractor) processSymbols(bundle *AnalysisBundle, symbols []S
 				Predicate: config.PredicateHasDoc,
 				Object:    sym.DocComment,
 			})
+
+			// The doc comment as a node of its own, for documentation search.
+			// Metadata would become facts making it look like a symbol of the
+			// file, so its type and symbol are only the two facts below.
+			docID := gcamdb.DocNodeID(sym.ID)
+			bundle.Documents = append(bundle.Documents, Document{
+				ID:      docID,
+				Content: []byte(sym.DocComment),
+			})
+			bundle.Facts = append(bundle.Facts,
+				meb.Fact{Subject: docID, Predicate: config.PredicateType, Object: config.SymbolKindDoc},
+				meb.Fact{Subject: docID, Predicate: config.PredicateDocuments, Object: sym.ID},
+			)
+		}
+
+		// Size and complexity metrics (functions and methods only)
+		if sym.Metrics.Complexity > 0 {
+			bundle.Facts = append(bundle.Facts,
+				meb.Fact{Subject: string(sym.ID), Predicate: config.PredicateLOC, Object: sym.Metrics.LOC},
+				meb.Fact{Subject: string(sym.ID), Predicate: config.PredicateComplexity, Object: sym.Metrics.Complexity},
+				meb.Fact{Subject: string(sym.ID), Predicate: config.PredicateParamCount, Object: sym.Metrics.Params},
+			)
 		}
 	}
 }
@@ -364,6 +409,17 @@ func (e *TreeSitterExtractor) derivePackage(relPath string) string {
 	return strings.ReplaceAll(dir, string(filepath.Separator), ".")
 }
 
+// uidScope returns the scope a file's symbols are identified in by their UIDs:
+// for Go the package, its directory and name, so that symbols keep their UID
+// when moved between the package's files; for languages whose modules are
+// files, the file without its extension.
+func uidScope(relPath, filePackage string) string {
+	if filepath.Ext(relPath) == ".go" {
+		return filepath.ToSlash(filepath.Dir(relPath)) + ":" + filePackage
+	}
+	return filepath.ToSlash(strings.TrimSuffix(relPath, filepath.Ext(relPath)))
+}
+
 // deriveTags generates architectural tags based on file path and extension.
 func (e *TreeSitterExtractor) deriveTags(relPath string) []string {
 	var tags []string
@@ -442,6 +498,9 @@ func (e *TreeSitterExtractor) extractGoNode(n *sitter.Node, content []byte, relP
 				if sym.Name != "" {
 					*symbols = append(*symbols, sym)
 				}
+				if sym.Type == TypeInterface {
+					*symbols = append(*symbols, e.extractInterfaceMethods(child, content, relPath, pkgName, sym.Name)...)
+				}
 			}
 		}
 	}
@@ -472,6 +531,8 @@ func (e *TreeSitterExtractor) extractGoRefs(n *sitter.Node, content []byte, relP
 				nextScope = fmt.Sprintf("%s:%s", relPath, funcName)
 			}
 		}
+		e.goLocals = make(map[string]string)
+		addGoTypedNames(n.ChildByFieldName("parameters"), content, e.goLocals)
 	case "method_declaration":
 		nameNode := n.ChildByFieldName("name")
 		receiverNode := n.ChildByFieldName("receiver")
@@ -486,11 +547,25 @@ func (e *TreeSitterExtractor) extractGoRefs(n *sitter.Node, content []byte, relP
 				}
 			}
 		}
+		e.goLocals = make(map[string]string)
+		addGoTypedNames(receiverNode, content, e.goLocals)
+		addGoTypedNames(n.ChildByFieldName("parameters"), content, e.goLocals)
+	case "var_spec", "func_literal":
+		if e.goLocals != nil {
+			addGoTypedNames(n, content, e.goLocals)
+		}
+	case "short_var_declaration", "range_clause":
+		if e.goLocals != nil {
+			addGoLiteralTypes(n, content, e.goLocals)
+		}
 	case "call_expression":
 		if currentScope != "" {
 			funcNode := n.ChildByFieldName("function")
 			if funcNode != nil {
 				callee := clean(funcNode.Utf8Text(content))
+				if typed := e.goTypedCallee(funcNode, content); typed != "" {
+					callee = typed
+				}
 				if callee != "" && !isStdLibCall(callee, "go") {
 					*refs = append(*refs, Reference{
 						Subject:   currentScope,
@@ -567,6 +642,7 @@ func (e *TreeSitterExtractor) extractPythonNode(n *sitter.Node, content []byte,
 				Content:    n.Utf8Text(content),
 				StartLine:  lineFromOffset(content, n.StartByte()),
 				EndLine:    lineFromOffset(content, n.EndByte()),
+				Metrics:    computeMetrics(n, content),
 			})
 		}
 	case "class_definition":
@@ -616,7 +692,7 @@ func (e *TreeSitterExtractor) extractPythonRefs(n *sitter.Node, content []byte,
 			child := n.Child(i)
 			if child.Kind() == "dotted_name" {
 				imp := clean(child.Utf8Text(content))
-				resolvedImp := resolveImportPath(relPath, imp)
+				resolvedImp := e.resolveImportPath(relPath, imp)
 				*refs = append(*refs, Reference{
 					Subject:   relPath,
 					Predicate: config.PredicateImports,
@@ -627,7 +703,7 @@ func (e *TreeSitterExtractor) extractPythonRefs(n *sitter.Node, content []byte,
 				name := child.ChildByFieldName("name")
 				if name != nil {
 					imp := clean(name.Utf8Text(content))
-					resolvedImp := resolveImportPath(relPath, imp)
+					resolvedImp := e.resolveImportPath(relPath, imp)
 					*refs = append(*refs, Reference{
 						Subject:   relPath,
 						Predicate: config.PredicateImports,
@@ -642,7 +718,7 @@ func (e *TreeSitterExtractor) extractPythonRefs(n *sitter.Node, content []byte,
 		modNameNode := n.ChildByFieldName("module_name")
 		if modNameNode != nil {
 			modName := clean(modNameNode.Utf8Text(content))
-			resolvedMod := resolveImportPath(relPath, modName)
+			resolvedMod := e.resolveImportPath(relPath, modName)
 			*refs = append(*refs, Reference{
 				Subject:   relPath,
 				Predicate: config.PredicateImports,
@@ -728,7 +804,8 @@ func (e *TreeSitterExtractor) extractJSNode(n *sitter.Node, content []byte, relP
 					// Check if it's an arrow function or function expression
 					if valNode != nil && (valNode.Kind() == "arrow_function" || valNode.Kind() == "function_expression") {
 						symType = TypeFunction
-						e.addGenericSymbol(name, symType, receiver, n, content, relPath, parentScope, symbols)
+						// Scopes what the function body defines, as for function declarations
+						newScope = e.addGenericSymbol(name, symType, receiver, n, content, relPath, parentScope, symbols)
 						name = "" // reset
 					} else {
 						if n.Parent().Kind() == "program" || n.Parent().Kind() == "export_statement" {
@@ -744,6 +821,10 @@ func (e *TreeSitterExtractor) extractJSNode(n *sitter.Node, content []byte, relP
 
 	if name != "" {
 		newScope = e.addGenericSymbol(name, symType, receiver, n, content, relPath, parentScope, symbols)
+		if kind == "interface_declaration" {
+			sym := &(*symbols)[len(*symbols)-1]
+			sym.Fields, sym.Embeds = tsInterfaceMembers(n, content)
+		}
 	}
 	return newScope
 }
@@ -766,6 +847,10 @@ func (e *TreeSitterExtractor) addGenericSymbol(name, symType, receiver string, n
 	}
 
 	sig := e.getSignature(n, content)
+	var metrics SymbolMetrics
+	if symType == TypeFunction || symType == TypeMethod {
+		metrics = computeMetrics(n, content)
+	}
 	*symbols = append(*symbols, Symbol{
 		ID:         id,
 		Name:       name,
@@ -776,6 +861,7 @@ func (e *TreeSitterExtractor) addGenericSymbol(name, symType, receiver string, n
 		Content:    n.Utf8Text(content),
 		StartLine:  lineFromOffset(content, n.StartByte()),
 		EndLine:    lineFromOffset(content, n.EndByte()),
+		Metrics:    metrics,
 	})
 	return id
 }
@@ -796,12 +882,39 @@ func (e *TreeSitterExtractor) extractJSRefs(n *sitter.Node, content []byte, relP
 				nextScope = fmt.Sprintf("%s.%s", currentScope, name)
 			}
 		}
+	case "variable_declarator":
+		// const Component = () => ...; function expressions are scoped like declarations
+		nameNode := n.ChildByFieldName("name")
+		valNode := n.ChildByFieldName("value")
+		if nameNode != nil && valNode != nil && (valNode.Kind() == "arrow_function" || valNode.Kind() == "function_expression") {
+			name := clean(nameNode.Utf8Text(content))
+			if currentScope == "" {
+				nextScope = fmt.Sprintf("%s:%s", relPath, name)
+			} else {
+				nextScope = fmt.Sprintf("%s.%s", currentScope, name)
+			}
+		}
+	case "jsx_opening_element", "jsx_self_closing_element":
+		// <Child /> inside a component renders Child; lowercase tags are DOM elements
+		if currentScope != "" {
+			if nameNode := n.ChildByFieldName("name"); nameNode != nil {
+				name := clean(nameNode.Utf8Text(content))
+				if isComponentName(name) {
+					*refs = append(*refs, Reference{
+						Subject:   currentScope,
+						Predicate: config.PredicateRenders,
+						Object:    name,
+						Line:      lineFromOffset(content, n.StartByte()),
+					})
+				}
+			}
+		}
 	case "import_statement":
 		// import { X } from 'Y'; or import X from 'Y';
 		sourceNode := n.ChildByFieldName("source")
 		if sourceNode != nil {
 			src := clean(sourceNode.Utf8Text(content))
-			resolvedSrc := resolveImportPath(relPath, src)
+			resolvedSrc := e.resolveImportPath(relPath, src)
 			*refs = append(*refs, Reference{
 				Subject:   relPath,
 				Predicate: config.PredicateImports,
@@ -822,6 +935,14 @@ func (e *TreeSitterExtractor) extractJSRefs(n *sitter.Node, content []byte, relP
 						Line:      lineFromOffset(content, n.StartByte()),
 					})
 				}
+				if isHookName(callee) {
+					*refs = append(*refs, Reference{
+						Subject:   currentScope,
+						Predicate: config.PredicateUsesHook,
+						Object:    callee,
+						Line:      lineFromOffset(content, n.StartByte()),
+					})
+				}
 			}
 		}
 	case "string", "template_string":
@@ -860,6 +981,22 @@ func (e *TreeSitterExtractor) extractJSRefs(n *sitter.Node, content []byte, relP
 
 // --- Helpers ---
 
+// isComponentName reports whether a JSX tag names a component rather than a
+// DOM element: it is capitalized or a member expression (<Menu.Item>).
+func isComponentName(tag string) bool {
+	if tag == "" || strings.ContainsAny(tag, "-:") {
+		return false
+	}
+	return strings.Contains(tag, ".") || unicode.IsUpper(rune(tag[0]))
+}
+
+// isHookName reports whether a callee is a React hook by the "useX" naming
+// rule, called directly or through a namespace (React.useState).
+func isHookName(callee string) bool {
+	name := callee[strings.LastIndex(callee, ".")+1:]
+	return len(name) > 3 && strings.HasPrefix(name, "use") && unicode.IsUpper(rune(name[3]))
+}
+
 func (e *TreeSitterExtractor) addImportRef(content []byte, node *sitter.Node, relPath string, refs *[]Reference) {
 	pathNode := node.ChildByFieldName("path")
 	if pathNode != nil {
@@ -904,6 +1041,7 @@ func (e *TreeSitterExtractor) extractFunction(n *sitter.Node, content []byte, re
 		StartLine:  lineFromOffset(content, n.StartByte()),
 		EndLine:    lineFromOffset(content, n.EndByte()),
 		Package:    pkgName,
+		Metrics:    computeMetrics(n, content),
 	}
 }
 
@@ -938,6 +1076,7 @@ func (e *TreeSitterExtractor) extractMethod(n *sitter.Node, content []byte, relP
 		StartLine:  lineFromOffset(content, n.StartByte()),
 		EndLine:    lineFromOffset(content, n.EndByte()),
 		Package:    pkgName,
+		Metrics:    computeMetrics(n, content),
 	}
 }
 
@@ -956,6 +1095,11 @@ func (e *TreeSitterExtractor) extractType(spec *sitter.Node, decl *sitter.Node,
 	if typeNode != nil && typeNode.Kind() == "interface_type" {
 		kind = TypeInterface
 	}
+	var fields []Field
+	var embeds []string
+	if typeNode != nil {
+		fields, embeds = goTypeMembers(typeNode, content)
+	}
 
 	id := fmt.Sprintf("%s:%s", relPath, name)
 	doc := e.getDocComment(decl, content) // Use decl doc
@@ -970,7 +1114,47 @@ func (e *TreeSitterExtractor) extractType(spec *sitter.Node, decl *sitter.Node,
 		StartLine:  lineFromOffset(content, spec.StartByte()),
 		EndLine:    lineFromOffset(content, spec.EndByte()),
 		Package:    pkgName,
+		Fields:     fields,
+		Embeds:     embeds,
+	}
+}
+
+// extractInterfaceMethods returns the methods an interface type spec declares,
+// with the interface as their receiver, so that calls through an interface
+// value resolve to them.
+func (e *TreeSitterExtractor) extractInterfaceMethods(spec *sitter.Node, content []byte, relPath, pkgName, iface string) []Symbol {
+	typeNode := spec.ChildByFieldName("type")
+	if typeNode == nil {
+		return nil
+	}
+	var methods []Symbol
+	for i := uint(0); i < uint(typeNode.NamedChildCount()); i++ {
+		elem := typeNode.NamedChild(i)
+		if elem.Kind() != "method_elem" {
+			continue
+		}
+		nameNode := elem.ChildByFieldName("name")
+		if nameNode == nil {
+			continue
+		}
+		name := clean(nameNode.Utf8Text(content))
+		if name == "" {
+			continue
+		}
+		methods = append(methods, Symbol{
+			ID:         fmt.Sprintf("%s:%s.%s", relPath, iface, name),
+			Name:       name,
+			Type:       TypeMethod,
+			Receiver:   iface,
+			Signature:  elem.Utf8Text(content),
+			DocComment: e.getDocComment(elem, content),
+			Content:    elem.Utf8Text(content),
+			StartLine:  lineFromOffset(content, elem.StartByte()),
+			EndLine:    lineFromOffset(content, elem.EndByte()),
+			Package:    pkgName,
+		})
 	}
+	return methods
 }
 
 func (e *TreeSitterExtractor) getDocComment(n *sitter.Node, content []byte) string {
@@ -1022,52 +1206,38 @@ func (e *TreeSitterExtractor) getReceiverType(n *sitter.Node, content []byte) st
 	return findType(n)
 }
 
-func resolveImportPath(relPath, importPath string) string {
+// resolveImportPath maps an import onto a file of the ingest session when it
+// can, or falls back to the path the import names.
+func (e *TreeSitterExtractor) resolveImportPath(relPath, importPath string) string {
 	// 1. Handle Relative Imports
 	if strings.HasPrefix(importPath, ".") {
-		dir := filepath.Dir(relPath)
-		basePath := filepath.Clean(filepath.Join(dir, importPath))
-
-		// 1a. Exact match
-		if currentState.FileIndex[basePath] {
-			return basePath
-		}
-
-		// 1b. Try extensions
-		extensions := []string{".ts", ".tsx", ".js", ".jsx", ".py", ".go"}
-		for _, ext := range extensions {
-			candidate := basePath + ext
-			if currentState.FileIndex[candidate] {
-				return candidate
-			}
+		basePath := filepath.Clean(filepath.Join(filepath.Dir(relPath), importPath))
+		if file, ok := e.resolveModuleFile(basePath); ok {
+			return file
 		}
+		return basePath // Fallback to resolved relative path even if file not found
+	}
 
-		// 1c. Handle specific TypeScript import style (.js -> .ts)
-		if strings.HasSuffix(basePath, ".js") {
-			tsPath := strings.TrimSuffix(basePath, ".js") + ".ts"
-			if currentState.FileIndex[tsPath] {
-				return tsPath
-			}
-			tsxPath := strings.TrimSuffix(basePath, ".js") + ".tsx"
-			if currentState.FileIndex[tsxPath] {
-				return tsxPath
+	// 2. TypeScript path aliases ("@/components/x") and baseUrl imports, from
+	// the tsconfig.json governing the importing file
+	if isScriptFile(relPath) {
+		if cfg := tsConfigFor(e.tsConfigs, relPath); cfg != nil {
+			for _, target := range cfg.match(importPath) {
+				if file, ok := e.resolveModuleFile(target); ok {
+					return file
+				}
 			}
-		}
-
-		// 1d. Try index files
-		for _, ext := range extensions {
-			candidate := filepath.Join(basePath, "index"+ext)
-			if currentState.FileIndex[candidate] {
-				return candidate
+			if cfg.baseURL != "" {
+				if file, ok := e.resolveModuleFile(filepath.Join(cfg.baseURL, importPath)); ok {
+					return file
+				}
 			}
 		}
-
-		return basePath // Fallback to resolved relative path even if file not found
 	}
 
-	// 2. Handle Absolute/Package Imports (Python, Go, etc.)
+	// 3. Handle Absolute/Package Imports (Python, Go, etc.)
 	// First, check if it's already a known file path (unlikely for imports but possible)
-	if currentState.FileIndex[importPath] {
+	if e.files[importPath] {
 		return importPath
 	}
 
@@ -1075,19 +1245,19 @@ func resolveImportPath(relPath, importPath string) string {
 	if !strings.Contains(importPath, "/") {
 		slashPath := strings.ReplaceAll(importPath, ".", "/")
 
-		// 2a. Check if direct mapping exists (module root at project root)
+		// 3a. Check if direct mapping exists (module root at project root)
 		candidates := []string{
 			slashPath + ".py",
 			filepath.Join(slashPath, "__init__.py"),
 		}
 
 		for _, candidate := range candidates {
-			if currentState.FileIndex[candidate] {
+			if e.files[candidate] {
 				return candidate
 			}
 		}
 
-		// 2b. Suffix Search (Full Scan)
+		// 3b. Suffix Search (Full Scan)
 		// Necessary for complex structures like langgraph/libs/checkpoint/... importing langgraph.checkpoint...
 		// We look for any file ending with the constructed path.
 		// To avoid false positives, we check for precise suffix matches (ends with /path.py)
@@ -1096,7 +1266,7 @@ func resolveImportPath(relPath, importPath string) string {
 		suffix1 := "/" + slashPath + ".py"
 		suffix2 := "/" + slashPath + "/__init__.py"
 
-		for path := range currentState.FileIndex {
+		for path := range e.files {
 			if strings.HasSuffix(path, suffix1) || strings.HasSuffix(path, suffix2) {
 				return path // Return first match. Ambiguity possible but acceptable for now.
 			}
@@ -1106,6 +1276,55 @@ func resolveImportPath(relPath, importPath string) string {
 	return importPath
 }
 
+// resolveModuleFile finds the session file a module path names, trying it
+// as is, with a source extension, compiled .js mapped back to TypeScript, and
+// as a directory with an index file.
+func (e *TreeSitterExtractor) resolveModuleFile(basePath string) (string, bool) {
+	// Exact match
+	if e.files[basePath] {
+		return basePath, true
+	}
+
+	// Try extensions
+	extensions := []string{".ts", ".tsx", ".js", ".jsx", ".py", ".go"}
+	for _, ext := range extensions {
+		candidate := basePath + ext
+		if e.files[candidate] {
+			return candidate, true
+		}
+	}
+
+	// Handle specific TypeScript import style (.js -> .ts)
+	if strings.HasSuffix(basePath, ".js") {
+		tsPath := strings.TrimSuffix(basePath, ".js") + ".ts"
+		if e.files[tsPath] {
+			return tsPath, true
+		}
+		tsxPath := strings.TrimSuffix(basePath, ".js") + ".tsx"
+		if e.files[tsxPath] {
+			return tsxPath, true
+		}
+	}
+
+	// Try index files
+	for _, ext := range extensions {
+		candidate := filepath.Join(basePath, "index"+ext)
+		if e.files[candidate] {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// isScriptFile reports whether a file is TypeScript or JavaScript.
+func isScriptFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".ts", ".tsx", ".js", ".jsx":
+		return true
+	}
+	return false
+}
+
 func isGoBuiltIn(name string) bool {
 	switch name {
 	case "string", "int", "int8", "int16", "int32", "int64":
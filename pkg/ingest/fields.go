@@ -0,0 +1,121 @@
+package ingest
+
+import (
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Field is a named member of a Go struct or TypeScript interface.
+type Field struct {
+	Name string
+	Type string // Named type of the field; see fieldTypeName
+}
+
+// goTypeMembers returns the fields of a Go struct type and the types it
+// embeds, or the interfaces embedded by an interface type.
+func goTypeMembers(typeNode *sitter.Node, content []byte) (fields []Field, embeds []string) {
+	switch typeNode.Kind() {
+	case "struct_type":
+		var list *sitter.Node
+		for i := uint(0); i < uint(typeNode.NamedChildCount()); i++ {
+			if c := typeNode.NamedChild(i); c.Kind() == "field_declaration_list" {
+				list = c
+			}
+		}
+		if list == nil {
+			return nil, nil
+		}
+		for i := uint(0); i < uint(list.NamedChildCount()); i++ {
+			decl := list.NamedChild(i)
+			if decl.Kind() != "field_declaration" {
+				continue
+			}
+			typ := fieldTypeName(decl.ChildByFieldName("type"), content)
+			var names []string
+			for j := uint(0); j < uint(decl.NamedChildCount()); j++ {
+				if c := decl.NamedChild(j); c.Kind() == "field_identifier" {
+					names = append(names, clean(c.Utf8Text(content)))
+				}
+			}
+			if len(names) == 0 {
+				// An embedded field: its type is promoted into the struct
+				if typ != "" {
+					embeds = append(embeds, typ)
+				}
+				continue
+			}
+			for _, name := range names {
+				fields = append(fields, Field{Name: name, Type: typ})
+			}
+		}
+	case "interface_type":
+		for i := uint(0); i < uint(typeNode.NamedChildCount()); i++ {
+			if c := typeNode.NamedChild(i); c.Kind() == "type_elem" && c.NamedChildCount() == 1 {
+				if typ := fieldTypeName(c.NamedChild(0), content); typ != "" {
+					embeds = append(embeds, typ)
+				}
+			}
+		}
+	}
+	return fields, embeds
+}
+
+// tsInterfaceMembers returns the properties of a TypeScript interface and the
+// interfaces it extends.
+func tsInterfaceMembers(n *sitter.Node, content []byte) (fields []Field, embeds []string) {
+	for i := uint(0); i < uint(n.NamedChildCount()); i++ {
+		c := n.NamedChild(i)
+		switch c.Kind() {
+		case "extends_type_clause":
+			for j := uint(0); j < uint(c.NamedChildCount()); j++ {
+				if typ := fieldTypeName(c.NamedChild(j), content); typ != "" {
+					embeds = append(embeds, typ)
+				}
+			}
+		case "interface_body", "object_type":
+			for j := uint(0); j < uint(c.NamedChildCount()); j++ {
+				prop := c.NamedChild(j)
+				if prop.Kind() != "property_signature" {
+					continue
+				}
+				if name := prop.ChildByFieldName("name"); name != nil {
+					fields = append(fields, Field{
+						Name: clean(name.Utf8Text(content)),
+						Type: fieldTypeName(prop.ChildByFieldName("type"), content),
+					})
+				}
+			}
+		}
+	}
+	return fields, embeds
+}
+
+// fieldTypeName returns the named type a field holds, without the pointer,
+// slice, array, channel, optional or map-key parts around it, so that fields
+// of type *Fact, []Fact and map[string]Fact all name Fact. Generic types name
+// their base type; other types (functions, unions, literals) keep their text.
+func fieldTypeName(n *sitter.Node, content []byte) string {
+	if n == nil {
+		return ""
+	}
+	switch n.Kind() {
+	case "pointer_type", "type_annotation", "parenthesized_type":
+		if n.NamedChildCount() > 0 {
+			return fieldTypeName(n.NamedChild(0), content)
+		}
+	case "slice_type", "array_type":
+		if elem := n.ChildByFieldName("element"); elem != nil {
+			return fieldTypeName(elem, content)
+		}
+		if n.NamedChildCount() > 0 {
+			return fieldTypeName(n.NamedChild(0), content) // TypeScript T[]
+		}
+	case "map_type", "channel_type":
+		return fieldTypeName(n.ChildByFieldName("value"), content)
+	case "generic_type":
+		if base := n.ChildByFieldName("type"); base != nil {
+			return fieldTypeName(base, content)
+		}
+		return fieldTypeName(n.ChildByFieldName("name"), content)
+	}
+	return clean(n.Utf8Text(content))
+}
@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+)
+
+func TestTypeCompositionFacts(t *testing.T) {
+	for _, tc := range []struct {
+		file, src string
+		want      []string
+	}{
+		{
+			file: "store/store.go",
+			src: "package store\n\ntype Store struct {\n\tBase\n\t*meb.MEBStore\n\tA, B []int `json:\"a\"`\n\tByID map[string]*meb.Fact\n\tsubs chan Event\n}\n\n" +
+				"type ReadCloser interface {\n\tio.Reader\n\tClose() error\n}\n",
+			want: []string{
+				"store/store.go:Store embeds Base",
+				"store/store.go:Store embeds meb.MEBStore",
+				"store/store.go:Store has_field A",
+				"store/store.go:Store has_field B",
+				"store/store.go:Store.A field_type int",
+				"store/store.go:Store.B field_type int",
+				"store/store.go:Store has_field ByID",
+				"store/store.go:Store.ByID field_type meb.Fact",
+				"store/store.go:Store has_field subs",
+				"store/store.go:Store.subs field_type Event",
+				"store/store.go:ReadCloser embeds io.Reader",
+			},
+		},
+		{
+			file: "web/types.ts",
+			src:  "export interface User extends Entity, Audited<Date> {\n  name: string;\n  roles?: Role[];\n  greet(): void;\n}\n",
+			want: []string{
+				"web/types.ts:User embeds Entity",
+				"web/types.ts:User embeds Audited",
+				"web/types.ts:User has_field name",
+				"web/types.ts:User.name field_type string",
+				"web/types.ts:User has_field roles",
+				"web/types.ts:User.roles field_type Role",
+			},
+		},
+	} {
+		bundle, err := NewTreeSitterExtractor().Extract(context.Background(), tc.file, []byte(tc.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]bool)
+		for _, f := range bundle.Facts {
+			switch f.Predicate {
+			case config.PredicateHasField, config.PredicateFieldType, config.PredicateEmbeds:
+				got[f.Subject+" "+f.Predicate+" "+f.Object.(string)] = true
+			}
+		}
+		for _, want := range tc.want {
+			if !got[want] {
+				t.Errorf("%s: missing %q", tc.file, want)
+			}
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %d composition facts, want %d: %v", tc.file, len(got), len(tc.want), got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+)
+
+// goModule is a Go module of the project: its module path and the graph ID of
+// the directory holding its go.mod.
+type goModule struct {
+	path string
+	dir  string
+}
+
+// loadGoMod adds the module declared by the go.mod at path, whose graph ID is
+// rel, to the session.
+func (state *IngestState) loadGoMod(path, rel string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Warn("Ignoring unreadable go.mod", "file", rel, "error", err)
+		return
+	}
+	if modulePath, _ := ParseGoMod(data); modulePath != "" {
+		state.goModules = append(state.goModules, goModule{path: modulePath, dir: filepath.Dir(rel)})
+	}
+}
+
+// goImportFiles returns the session files of the package a Go import path
+// names, if it belongs to one of the project's modules: the non-test .go files
+// of its directory. The module with the longest matching path wins, as for
+// nested modules.
+func (state *IngestState) goImportFiles(importPath string) []string {
+	var best *goModule
+	for i, mod := range state.goModules {
+		if importPath != mod.path && !strings.HasPrefix(importPath, mod.path+"/") {
+			continue
+		}
+		if best == nil || len(mod.path) > len(best.path) {
+			best = &state.goModules[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return state.goPackages[filepath.Join(best.dir, strings.TrimPrefix(importPath, best.path))]
+}
+
+// indexGoPackages groups the session's Go files by directory, for goImportFiles.
+func (state *IngestState) indexGoPackages() {
+	state.goPackages = make(map[string][]string)
+	for file := range state.FileIndex {
+		if filepath.Ext(file) == ".go" && !strings.HasSuffix(file, "_test.go") {
+			dir := filepath.Dir(file)
+			state.goPackages[dir] = append(state.goPackages[dir], file)
+		}
+	}
+	for _, files := range state.goPackages {
+		sort.Strings(files)
+	}
+}
+
+// goImportFileFacts links a Go file to the files of the first-party packages
+// among imports with imports_file facts.
+func goImportFileFacts(relPath string, imports []meb.Fact, state *IngestState) []meb.Fact {
+	var facts []meb.Fact
+	seen := make(map[string]bool)
+	for _, f := range imports {
+		importPath, ok := f.Object.(string)
+		if !ok || f.Predicate != config.PredicateImports {
+			continue
+		}
+		for _, file := range state.goImportFiles(importPath) {
+			if file != relPath && !seen[file] {
+				seen[file] = true
+				facts = append(facts, meb.Fact{Subject: relPath, Predicate: config.PredicateImportsFile, Object: file})
+			}
+		}
+	}
+	return facts
+}
@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestGoImportsFile(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":                        "module example.com/app\n\ngo 1.22\n\nrequire github.com/other/lib v1.0.0\n",
+		"main.go":                       "package main\n\nimport (\n\t\"example.com/app/pkg/store\"\n\t\"github.com/other/lib\"\n)\n\nfunc main() { store.Open(); lib.Do() }\n",
+		"pkg/store/store.go":            "package store\n\nfunc Open() {}\n",
+		"pkg/store/scan.go":             "package store\n\nfunc scan() {}\n",
+		"pkg/store/x_test.go":           "package store\n",
+		"tools/gen/go.mod":              "module example.com/tools\n",
+		"tools/gen/gen.go":              "package main\n\nimport \"example.com/tools/internal/tpl\"\n\nfunc main() { tpl.Render() }\n",
+		"tools/gen/internal/tpl/tpl.go": "package tpl\n\nfunc Render() {}\n",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RunContext(context.Background(), s, "app", src, NewIngestState(), &IngestOptions{SkipEmbeddings: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	importsFile := func(file string) []string {
+		var files []string
+		for fact, err := range s.ScanInTopicContext(context.Background(), gcamdb.TopicForProject("app"), file, config.PredicateImportsFile, "") {
+			if err == nil && fact.Subject == file {
+				files = append(files, fact.Object.(string))
+			}
+		}
+		sort.Strings(files)
+		return files
+	}
+	if got := importsFile("app/main.go"); len(got) != 2 || got[0] != "app/pkg/store/scan.go" || got[1] != "app/pkg/store/store.go" {
+		t.Errorf("main.go imports files %v, want the non-test files of pkg/store", got)
+	}
+	if got := importsFile("app/tools/gen/gen.go"); len(got) != 1 || got[0] != "app/tools/gen/internal/tpl/tpl.go" {
+		t.Errorf("nested module imports files %v, want its internal/tpl package", got)
+	}
+}
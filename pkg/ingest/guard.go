@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// generatedHeader matches the standard generated-code comment
+// (https://go.dev/s/generatedcode), as written by go:generate tools.
+var generatedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// sourceMapMarker ends files compiled or bundled from other sources.
+var sourceMapMarker = regexp.MustCompile(`(?m)^\s*(//|/\*)[#@] sourceMappingURL=`)
+
+// loadSource reads a source file and screens it before extraction. A non-empty
+// skip is the reason the file must not be ingested: it is too large, binary,
+// or minified or bundled output. generated files are ingested in reduced form
+// (see trimBundle).
+func loadSource(path string) (content []byte, generated bool, skip string, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, "", err
+	}
+	if info.Size() > config.MaxIngestFileSize {
+		return nil, false, fmt.Sprintf("too large (%d bytes, limit %d)", info.Size(), config.MaxIngestFileSize), nil
+	}
+	content, err = os.ReadFile(path)
+	if err != nil {
+		return nil, false, "", err
+	}
+	generated, skip = screenSource(path, content)
+	return content, generated, skip, nil
+}
+
+// screenSource classifies file content; see loadSource.
+func screenSource(path string, content []byte) (generated bool, skip string) {
+	if bytes.IndexByte(content[:min(len(content), config.BinarySniffBytes)], 0) >= 0 || !utf8.Valid(content) {
+		return false, "binary content"
+	}
+	if filepath.Ext(path) == ".md" {
+		return false, ""
+	}
+	if sourceMapMarker.Match(content) {
+		return false, "bundled output (sourceMappingURL)"
+	}
+	longest := 0
+	for line := range bytes.SplitSeq(content, []byte("\n")) {
+		longest = max(longest, len(line))
+	}
+	if longest > config.MaxIngestLineLength {
+		return false, fmt.Sprintf("minified (line of %d bytes, limit %d)", longest, config.MaxIngestLineLength)
+	}
+	return isGenerated(content), ""
+}
+
+// isGenerated reports whether the header of a file, before its first code
+// line, carries a generated-code marker: Go's "Code generated ... DO NOT EDIT."
+// or the "@generated" tag used by other generators.
+func isGenerated(content []byte) bool {
+	for line := range bytes.SplitSeq(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if generatedHeader.Match(line) || bytes.Contains(line, []byte("@generated")) {
+			return true
+		}
+		if !bytes.HasPrefix(line, []byte("//")) && !bytes.HasPrefix(line, []byte("#")) &&
+			!bytes.HasPrefix(line, []byte("/*")) && !bytes.HasPrefix(line, []byte("*")) {
+			return false
+		}
+	}
+	return false
+}
+
+// trimBundle bounds what an extracted file adds to the store. Strings in facts
+// (doc comments, markdown bodies, literals) are cut to config.MaxFactValueBytes.
+// A generated file keeps its symbols and facts, so calls into it still resolve,
+// but its symbols carry no source content and it is tagged is_generated.
+func trimBundle(bundle *AnalysisBundle, relPath string, generated bool) {
+	for i, f := range bundle.Facts {
+		if s, ok := f.Object.(string); ok && len(s) > config.MaxFactValueBytes {
+			bundle.Facts[i].Object = truncateUTF8(s, config.MaxFactValueBytes)
+		}
+	}
+	if !generated {
+		return
+	}
+	for i := range bundle.Documents {
+		bundle.Documents[i].Content = nil
+	}
+	bundle.Facts = append(bundle.Facts, meb.Fact{Subject: relPath, Predicate: config.PredicateIsGenerated, Object: true})
+}
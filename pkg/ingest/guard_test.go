@@ -0,0 +1,70 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+func TestLoadSource(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name          string
+		content       string
+		wantGenerated bool
+		wantSkip      string // Prefix of the skip reason; empty to ingest
+	}{
+		{"main.go", "package main\n\nfunc main() {}\n", false, ""},
+		{"gen.go", "// Code generated by stringer; DO NOT EDIT.\n\npackage main\n", true, ""},
+		{"late.go", "package main\n\n// Code generated by hand; DO NOT EDIT.\n", false, ""},
+		{"schema.py", "# @generated by protoc\nclass A: pass\n", true, ""},
+		{"bundle.js", "var a=1;\n//# sourceMappingURL=bundle.js.map\n", false, "bundled output"},
+		{"app.min.js", "var a=" + strings.Repeat("1+", config.MaxIngestLineLength) + "1;\n", false, "minified"},
+		{"blob.go", "package main\x00\x01\x02", false, "binary content"},
+		{"big.go", "package main\n" + strings.Repeat("// filler line\n", config.MaxIngestFileSize/15+1), false, "too large"},
+		{"long.md", "# Notes\n" + strings.Repeat("word ", config.MaxIngestLineLength) + "\n", false, ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, generated, skip, err := loadSource(path)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if generated != tt.wantGenerated {
+			t.Errorf("%s: generated = %v, want %v", tt.name, generated, tt.wantGenerated)
+		}
+		if (tt.wantSkip == "") != (skip == "") || !strings.HasPrefix(skip, tt.wantSkip) {
+			t.Errorf("%s: skip = %q, want prefix %q", tt.name, skip, tt.wantSkip)
+		}
+	}
+}
+
+func TestTrimBundle(t *testing.T) {
+	bundle := &AnalysisBundle{
+		Documents: []Document{{ID: "gen.go:F", Content: []byte("func F() {}")}},
+		Facts: []meb.Fact{
+			{Subject: "gen.go:F", Predicate: config.PredicateHasDoc, Object: strings.Repeat("é", config.MaxFactValueBytes)},
+			{Subject: "gen.go:F", Predicate: config.PredicateLOC, Object: 1},
+		},
+	}
+	trimBundle(bundle, "gen.go", true)
+
+	doc := bundle.Facts[0].Object.(string)
+	if len(doc) > config.MaxFactValueBytes || !strings.HasSuffix(doc, "é") {
+		t.Errorf("doc of %d bytes not cut to %d on a character boundary", len(doc), config.MaxFactValueBytes)
+	}
+	if bundle.Documents[0].Content != nil {
+		t.Error("generated symbol kept its content")
+	}
+	last := bundle.Facts[len(bundle.Facts)-1]
+	if last.Subject != "gen.go" || last.Predicate != config.PredicateIsGenerated || last.Object != true {
+		t.Errorf("last fact = %+v, want gen.go is_generated true", last)
+	}
+}
@@ -0,0 +1,360 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// Fact import formats.
+const (
+	ImportFormatNDJSON = "ndjson"
+	ImportFormatCSV    = "csv"
+)
+
+// ErrInvalidImport is wrapped by every error about the import input rather than
+// the store.
+var ErrInvalidImport = errors.New("invalid fact import")
+
+// ImportRecord is one fact produced by an external analyzer, such as a linter
+// or a runtime tracer.
+type ImportRecord struct {
+	Subject   string   `json:"subject"`
+	Predicate string   `json:"predicate"`
+	Object    any      `json:"object"` // String, number or boolean
+	Graph     string   `json:"graph,omitempty"`
+	Weight    *float64 `json:"weight,omitempty"`
+	Source    string   `json:"source,omitempty"`
+}
+
+// importLine is a record and the line it was read from.
+type importLine struct {
+	line int
+	ImportRecord
+}
+
+// ImportReport summarizes a completed import.
+type ImportReport struct {
+	Project  string `json:"project"`
+	Imported int    `json:"imported"`
+	Existing int    `json:"existing"` // Imported facts the store already had
+	Version  int    `json:"version,omitempty"`
+}
+
+// LineError is an invalid line of an import.
+type LineError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ImportError is returned when an import has invalid lines. Nothing is written.
+type ImportError struct {
+	Errors    []LineError `json:"errors"`
+	Truncated bool        `json:"truncated,omitempty"` // More lines were invalid
+}
+
+func (e *ImportError) Error() string {
+	if len(e.Errors) == 0 {
+		return ErrInvalidImport.Error()
+	}
+	first := e.Errors[0]
+	msg := fmt.Sprintf("%v: line %d: %s", ErrInvalidImport, first.Line, first.Message)
+	if n := len(e.Errors) - 1; n > 0 || e.Truncated {
+		msg += fmt.Sprintf(" (and %d more", n)
+		if e.Truncated {
+			msg += "+"
+		}
+		msg += ")"
+	}
+	return msg
+}
+
+// Is reports whether target is ErrInvalidImport.
+func (e *ImportError) Is(target error) bool {
+	return target == ErrInvalidImport
+}
+
+// ImportFacts reads facts in format (ImportFormatNDJSON or ImportFormatCSV) from
+// r and adds them to projectName's topic as one ingest version. Every line is
+// validated before anything is written, so an import with an invalid line
+// writes nothing and returns an *ImportError.
+//
+// A CSV import starts with a header naming its columns: subject, predicate and
+// object, optionally graph, weight and source. A record's graph, when set, must
+// be projectName. A record's weight is set on its fact as UpdateFactWeight
+// does; the store keeps no per-fact origin, so source is validated but not
+// stored.
+func ImportFacts(s *meb.MEBStore, projectName string, r io.Reader, format string) (*ImportReport, error) {
+	var records []importLine
+	var err error
+	switch strings.ToLower(format) {
+	case "", ImportFormatNDJSON, "jsonl":
+		records, err = readNDJSON(r)
+	case ImportFormatCSV:
+		records, err = readCSV(r)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q (want %s or %s)", ErrInvalidImport, format, ImportFormatNDJSON, ImportFormatCSV)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	facts := make([]meb.Fact, 0, len(records))
+	var weights []gcamdb.FactWeight
+	ierr := &ImportError{}
+	for _, rec := range records {
+		fact, err := rec.validate(projectName)
+		if err != nil {
+			if !ierr.add(rec.line, err) {
+				break
+			}
+			continue
+		}
+		facts = append(facts, fact)
+		if rec.Weight != nil {
+			weights = append(weights, gcamdb.FactWeight{Subject: fact.Subject, Predicate: fact.Predicate, Object: fact.Object.(string), Weight: *rec.Weight})
+		}
+	}
+	if len(ierr.Errors) > 0 {
+		return nil, ierr
+	}
+	report, err := addImportedFacts(s, projectName, facts)
+	if err != nil {
+		return nil, err
+	}
+	if err := gcamdb.UpdateFactWeights(s, weights); err != nil {
+		return nil, fmt.Errorf("set fact weights: %w", err)
+	}
+	return report, nil
+}
+
+// addImportedFacts writes validated facts to projectName's topic and records
+// the facts the store did not have as a new ingest version.
+func addImportedFacts(s *meb.MEBStore, projectName string, facts []meb.Fact) (*ImportReport, error) {
+	s.SetTopicID(gcamdb.TopicForProject(projectName))
+	if projectName != "" {
+		if err := gcamdb.RegisterProject(s, projectName); err != nil {
+			logger.Warn("Could not register project", "project", projectName, "error", err)
+		}
+	}
+	// Facts already in the store are not new in this version
+	var added []meb.Fact
+	for _, f := range facts {
+		exists := false
+		for _, err := range s.ScanInTopicContext(context.Background(), s.TopicID(), f.Subject, f.Predicate, f.Object.(string)) {
+			exists = err == nil
+			break
+		}
+		if !exists {
+			added = append(added, f)
+		}
+	}
+	for start := 0; start < len(facts); start += config.WriteBatchMaxFacts {
+		end := min(start+config.WriteBatchMaxFacts, len(facts))
+		if err := s.AddFactBatch(facts[start:end]); err != nil {
+			return nil, fmt.Errorf("write facts %d-%d: %w", start+1, end, err)
+		}
+	}
+
+	report := &ImportReport{Project: projectName, Imported: len(facts), Existing: len(facts) - len(added)}
+	rec := gcamdb.RecordVersion(s, projectName, false)
+	rec.AddFacts(added...)
+	v, err := rec.Commit()
+	if err != nil {
+		logger.Warn("Could not record import version", "project", projectName, "error", err)
+	} else {
+		report.Version = v.ID
+	}
+	return report, nil
+}
+
+// add records an invalid line, reporting false once the error limit is reached.
+func (e *ImportError) add(line int, err error) bool {
+	if len(e.Errors) >= config.FactImportMaxErrors {
+		e.Truncated = true
+		return false
+	}
+	e.Errors = append(e.Errors, LineError{Line: line, Message: err.Error()})
+	return true
+}
+
+func readNDJSON(r io.Reader) ([]importLine, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), config.FactImportMaxLineSize)
+	var records []importLine
+	ierr := &ImportError{}
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		if len(records) >= config.FactImportMaxFacts {
+			return nil, fmt.Errorf("%w: more than %d facts", ErrInvalidImport, config.FactImportMaxFacts)
+		}
+		var rec ImportRecord
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		dec.UseNumber()
+		if err := dec.Decode(&rec); err != nil {
+			if !ierr.add(line, err) {
+				break
+			}
+			continue
+		}
+		records = append(records, importLine{line, rec})
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("%w: line %d exceeds %d bytes", ErrInvalidImport, line+1, config.FactImportMaxLineSize)
+		}
+		return nil, err
+	}
+	if len(ierr.Errors) > 0 {
+		return nil, ierr
+	}
+	return records, nil
+}
+
+var importColumns = []string{"subject", "predicate", "object", "graph", "weight", "source"}
+
+func readCSV(r io.Reader) ([]importLine, error) {
+	cr := csv.NewReader(bufio.NewReaderSize(r, 64*1024))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: read CSV header: %v", ErrInvalidImport, err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(importColumns, name) {
+			return nil, fmt.Errorf("%w: unknown CSV column %q", ErrInvalidImport, name)
+		}
+		if _, dup := cols[name]; dup {
+			return nil, fmt.Errorf("%w: duplicate CSV column %q", ErrInvalidImport, name)
+		}
+		cols[name] = i
+	}
+	for _, required := range importColumns[:3] {
+		if _, ok := cols[required]; !ok {
+			return nil, fmt.Errorf("%w: CSV header has no %q column", ErrInvalidImport, required)
+		}
+	}
+
+	var records []importLine
+	ierr := &ImportError{}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, err
+			}
+			if !ierr.add(perr.Line, perr.Err) {
+				break
+			}
+			continue
+		}
+		if len(records) >= config.FactImportMaxFacts {
+			return nil, fmt.Errorf("%w: more than %d facts", ErrInvalidImport, config.FactImportMaxFacts)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(row) != len(header) {
+			if !ierr.add(line, fmt.Errorf("has %d fields, header has %d", len(row), len(header))) {
+				break
+			}
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := cols[name]; ok {
+				return row[i]
+			}
+			return ""
+		}
+		rec := ImportRecord{
+			Subject:   field("subject"),
+			Predicate: field("predicate"),
+			Object:    field("object"),
+			Graph:     field("graph"),
+			Source:    field("source"),
+		}
+		if w := field("weight"); w != "" {
+			f, err := strconv.ParseFloat(w, 64)
+			if err != nil {
+				if !ierr.add(line, fmt.Errorf("weight %q is not a number", w)) {
+					break
+				}
+				continue
+			}
+			rec.Weight = &f
+		}
+		records = append(records, importLine{line, rec})
+	}
+	if len(ierr.Errors) > 0 {
+		return nil, ierr
+	}
+	return records, nil
+}
+
+// validate checks rec for import into project and returns its fact.
+func (rec ImportRecord) validate(project string) (meb.Fact, error) {
+	if rec.Subject == "" || rec.Predicate == "" {
+		return meb.Fact{}, errors.New("subject and predicate are required")
+	}
+	if len(rec.Subject) > config.MaxSymbolIDLength {
+		return meb.Fact{}, fmt.Errorf("subject exceeds %d bytes", config.MaxSymbolIDLength)
+	}
+	if len(rec.Predicate) > config.MaxPredicateLength {
+		return meb.Fact{}, fmt.Errorf("predicate exceeds %d bytes", config.MaxPredicateLength)
+	}
+	if strings.IndexFunc(rec.Predicate, unicode.IsSpace) >= 0 {
+		return meb.Fact{}, fmt.Errorf("predicate %q contains whitespace", rec.Predicate)
+	}
+	if rec.Graph != "" && rec.Graph != project {
+		return meb.Fact{}, fmt.Errorf("graph %q does not match project %q", rec.Graph, project)
+	}
+	if rec.Weight != nil {
+		if err := gcamdb.ValidWeight(*rec.Weight); err != nil {
+			return meb.Fact{}, err
+		}
+	}
+
+	var obj string
+	switch v := rec.Object.(type) {
+	case string:
+		obj = v
+	case json.Number:
+		obj = v.String()
+	case bool:
+		obj = strconv.FormatBool(v)
+	case nil:
+	default:
+		return meb.Fact{}, errors.New("object must be a string, number or boolean")
+	}
+	if obj == "" {
+		return meb.Fact{}, errors.New("object is required")
+	}
+	return meb.Fact{Subject: rec.Subject, Predicate: rec.Predicate, Object: obj}, nil
+}
@@ -0,0 +1,112 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestImportFacts(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ndjson := `{"subject": "app/main.go:run", "predicate": "lint_issue", "object": "SA4006", "graph": "app", "weight": 0.5, "source": "staticcheck"}
+
+{"subject": "app/main.go:run", "predicate": "call_count", "object": 42}
+`
+	report, err := ImportFacts(s, "app", strings.NewReader(ndjson), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 || report.Existing != 0 || report.Version == 0 {
+		t.Errorf("report = %+v, want 2 new facts in a version", report)
+	}
+
+	csv := "subject,predicate,object,source\n" +
+		"app/main.go:run,lint_issue,SA4006,staticcheck\n" +
+		"app/util.go:help,lint_issue,\"ST1003, naming\",staticcheck\n"
+	report, err = ImportFacts(s, "app", strings.NewReader(csv), ImportFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 || report.Existing != 1 {
+		t.Errorf("report = %+v, want 2 facts with 1 already present", report)
+	}
+
+	ctx := context.Background()
+	objects := func(ctx context.Context, subj, pred string) []string {
+		t.Helper()
+		var objs []string
+		for f, err := range gcamdb.Scan(ctx, s, subj, pred, "") {
+			if err != nil {
+				t.Fatal(err)
+			}
+			objs = append(objs, fmt.Sprint(f.Object))
+		}
+		return objs
+	}
+	if got := objects(ctx, "app/main.go:run", "call_count"); len(got) != 1 || got[0] != "42" {
+		t.Errorf("call_count = %v, want [42]", got)
+	}
+	if w := gcamdb.FactWeigher(s)("app/main.go:run", "lint_issue", "SA4006"); w != 0.5 {
+		t.Errorf("lint_issue weight = %v, want the imported 0.5", w)
+	}
+	if got := objects(ctx, "app/util.go:help", "lint_issue"); len(got) != 1 || got[0] != "ST1003, naming" {
+		t.Errorf("util lint_issue = %v, want [ST1003, naming]", got)
+	}
+	before, err := gcamdb.AsOf(ctx, s, report.Version-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := objects(before, "app/util.go:help", "lint_issue"); len(got) != 0 {
+		t.Errorf("util lint_issue before the CSV import = %v, want none", got)
+	}
+	if got := objects(before, "app/main.go:run", "lint_issue"); len(got) != 1 {
+		t.Errorf("main lint_issue before the CSV import = %v, want the NDJSON fact", got)
+	}
+
+	// One invalid line rejects the whole import
+	count := s.Count()
+	bad := `{"subject": "app/a.go", "predicate": "ok", "object": "x"}
+{"subject": "app/b.go", "predicate": "has space", "object": "x"}
+{"subject": "app/c.go", "predicate": "p", "object": "x", "graph": "other"}
+{"subject": "app/d.go", "predicate": "p", "object": "x", "weight": -1}
+{"subject": "app/e.go", "predicate": "p", "object": {"nested": true}}
+{"subject": "app/f.go", "predicate": "p", "object": "x", "extra": 1}
+`
+	_, err = ImportFacts(s, "app", strings.NewReader(bad), ImportFormatNDJSON)
+	var ierr *ImportError
+	if !errors.As(err, &ierr) || !errors.Is(err, ErrInvalidImport) {
+		t.Fatalf("err = %v, want *ImportError", err)
+	}
+	if len(ierr.Errors) != 1 || ierr.Errors[0].Line != 6 {
+		t.Errorf("decode errors = %+v, want line 6", ierr.Errors)
+	}
+	_, err = ImportFacts(s, "app", strings.NewReader(bad[:strings.LastIndex(bad[:len(bad)-1], "\n")]), ImportFormatNDJSON)
+	if !errors.As(err, &ierr) {
+		t.Fatalf("err = %v, want *ImportError", err)
+	}
+	var lines []int
+	for _, e := range ierr.Errors {
+		lines = append(lines, e.Line)
+	}
+	if len(lines) != 4 || lines[0] != 2 || lines[3] != 5 {
+		t.Errorf("invalid lines = %v, want [2 3 4 5]", lines)
+	}
+	if s.Count() != count {
+		t.Errorf("fact count changed from %d to %d by a rejected import", count, s.Count())
+	}
+
+	if _, err := ImportFacts(s, "app", strings.NewReader("subject,object\n"), ImportFormatCSV); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("CSV without predicate column: err = %v, want ErrInvalidImport", err)
+	}
+}
@@ -10,13 +10,13 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 )
 
@@ -84,9 +84,10 @@ func getFileGraphName(relPath string) string {
 	return FileGraphPrefix + relPath
 }
 
-// deleteFileFacts removes all facts associated with a specific file.
-func deleteFileFacts(s *meb.MEBStore, relPath string) error {
-	if err := s.DeleteFactsBySubject(relPath); err != nil {
+// deleteFileFacts removes all facts associated with a specific file, recording
+// them as soft-deleted by the ingest version rec.
+func deleteFileFacts(s *meb.MEBStore, rec *gcamdb.VersionRecorder, relPath string) error {
+	if err := rec.DeleteSubject(s, relPath); err != nil {
 		logger.Warn("Failed to delete facts for file", "file", relPath, "error", err)
 		return err
 	}
@@ -103,14 +104,18 @@ func RunIncrementalWithState(s *meb.MEBStore, projectName string, sourceDir stri
 }
 
 func RunIncrementalWithOptions(s *meb.MEBStore, projectName string, sourceDir string, state *IngestState, opts *IngestOptions) error {
-	SetIngestState(state)
 	ctx := context.Background()
-	ext := NewTreeSitterExtractor()
 
 	// Set topic ID for project-scoped ingestion
-	topicID := hashToTopicID(projectName)
+	topicID := gcamdb.TopicForProject(projectName)
 	s.SetTopicID(topicID)
 	logger.Info("Using topic ID for incremental project", "topicID", topicID, "project", projectName)
+	if projectName != "" {
+		if err := gcamdb.RegisterProject(s, projectName); err != nil {
+			logger.Warn("Could not register project", "project", projectName, "error", err)
+		}
+	}
+	version := gcamdb.RecordVersion(s, projectName, false)
 
 	existingHashes, err := LoadFileHashes(s)
 	if err != nil {
@@ -160,13 +165,20 @@ func RunIncrementalWithOptions(s *meb.MEBStore, projectName string, sourceDir st
 		}
 	}
 
+	state.Owners = loadOwnership(sourceDir, projectMeta)
+
 	newHashes := make(FileHashMap)
 	changedFiles := []string{}
 	deletedFiles := []string{}
 
+	// The hash map is shared by every project in the store; only this project's
+	// entries can be deleted, the rest are carried over untouched.
+	scope := gcamdb.ProjectScope(projectName)
 	existingFilePaths := make(map[string]bool)
 	for path := range existingHashes {
-		existingFilePaths[path] = true
+		if projectName == "" || scope.Owns(path) {
+			existingFilePaths[path] = true
+		}
 	}
 
 	err = filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
@@ -179,6 +191,22 @@ func RunIncrementalWithOptions(s *meb.MEBStore, projectName string, sourceDir st
 			}
 			return nil
 		}
+		if isTSConfig(d.Name()) {
+			relPath, _ := filepath.Rel(sourceDir, path)
+			if projectName != "" {
+				relPath = filepath.Join(projectName, relPath)
+			}
+			state.loadTSConfig(path, relPath)
+			return nil
+		}
+		if d.Name() == "go.mod" {
+			relPath, _ := filepath.Rel(sourceDir, path)
+			if projectName != "" {
+				relPath = filepath.Join(projectName, relPath)
+			}
+			state.loadGoMod(path, relPath)
+			return nil
+		}
 		if isSupportedFile(path) {
 			relPath, _ := filepath.Rel(sourceDir, path)
 			if projectName != "" {
@@ -217,11 +245,18 @@ func RunIncrementalWithOptions(s *meb.MEBStore, projectName string, sourceDir st
 
 	if len(changedFiles) == 0 && len(deletedFiles) == 0 {
 		logger.Info("No changes detected. Skipping processing.")
-		EnhanceVirtualTriples(s)
-		TagRoles(s)
+		for _, result := range runEnrichers(ctx, s, projectName, sourceDir, state) {
+			// Enricher facts change the count the snapshot is fresh at
+			if result.Facts != 0 {
+				indexAdjacency(s, projectName)
+				break
+			}
+		}
 		return nil
 	}
 
+	var uids []string                           // UIDs of the symbols of changed and deleted files, whose vectors may be orphaned
+	var sketches map[string]gcamdb.VectorSketch // Sketches of the vectors stored by this run
 	if len(changedFiles) > 0 {
 		logger.Info("Processing changed files", "count", len(changedFiles))
 
@@ -232,53 +267,58 @@ func RunIncrementalWithOptions(s *meb.MEBStore, projectName string, sourceDir st
 			if projectName != "" {
 				rel = filepath.Join(projectName, rel)
 			}
-			if err := cleanupFileFacts(s, rel); err != nil {
+			uids = append(uids, fileUIDs(s, rel)...)
+			if err := cleanupFileFacts(s, version, rel); err != nil {
 				logger.Warn("Failed to cleanup old facts", "file", rel, "error", err)
 			}
 		}
 
-		state.SymbolTable = make(map[string]string)
-		for path := range newHashes {
-			if isSupportedFile(path) {
+		// Start from the persisted symbol table and re-extract only changed
+		// files; without one, index every file
+		var toIndex []sourceFile
+		if state.loadSymbolTable(s, projectName) {
+			state.forgetFiles(deletedFiles...)
+			for _, path := range changedFiles {
+				rel, _ := filepath.Rel(sourceDir, path)
+				if projectName != "" {
+					rel = filepath.Join(projectName, rel)
+				}
+				toIndex = append(toIndex, sourceFile{path: path, rel: rel})
+			}
+		} else {
+			for path := range newHashes {
 				fullPath := path
 				if projectName != "" {
 					fullPath = filepath.Join(sourceDir, strings.TrimPrefix(path, projectName+"/"))
 				}
-				if content, err := os.ReadFile(fullPath); err == nil {
-					symbols, _ := ext.ExtractSymbols(path, content, path)
-					for _, sym := range symbols {
-						state.SymbolTable[sym.Name] = sym.ID
-						if sym.Package != "" {
-							state.SymbolTable[sym.Package+"."+sym.Name] = sym.ID
-						}
-					}
-				}
+				toIndex = append(toIndex, sourceFile{path: fullPath, rel: path})
 			}
 		}
+		state.indexFiles(ctx, toIndex, opts.parseWorkers())
+		if err := state.saveSymbolTable(s, projectName); err != nil {
+			logger.Warn("Could not save symbol table", "project", projectName, "error", err)
+		}
 
-		jobs := make(chan string, 100)
+		jobs := make(chan string, opts.jobBuffer())
 		var wg sync.WaitGroup
-		var embeddingWg sync.WaitGroup
 		var passErr atomic.Uint64
+		var done atomic.Int64 // Files processed, for opts.Progress
+		embeds := newEmbedPool(ctx, s, embeddingService, opts, nil)
 
-		workerCount := runtime.NumCPU()
-		if workerCount > config.MaxWorkers {
-			workerCount = config.MaxWorkers
-		}
-
-		for i := 0; i < workerCount; i++ {
+		state.beginBatch(s)
+		for i := 0; i < opts.parseWorkers(); i++ {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				localExt := NewTreeSitterExtractor()
-				sem := make(chan struct{}, 10)
+				localExt := state.newExtractor()
 				for path := range jobs {
 					rel, _ := filepath.Rel(sourceDir, path)
 					logger.Debug("Processing file", "project", projectName, "file", rel)
-					if err := processFile(ctx, s, localExt, embeddingService, path, projectName, sourceDir, projectMeta, &embeddingWg, sem, state, opts); err != nil {
+					if err := processFile(ctx, s, localExt, embeds, path, projectName, sourceDir, projectMeta, state, opts); err != nil {
 						logger.Error("Error processing file", "error", err)
 						passErr.Add(1)
 					}
+					opts.progress(int(done.Add(1)), len(changedFiles))
 				}
 			}()
 		}
@@ -288,33 +328,73 @@ func RunIncrementalWithOptions(s *meb.MEBStore, projectName string, sourceDir st
 		}
 		close(jobs)
 		wg.Wait()
-
-		if embeddingService != nil {
-			logger.Info("Waiting for embeddings to complete")
-			embeddingWg.Wait()
+		if err := state.commitBatch(); err != nil {
+			return fmt.Errorf("commit changed files: %w", err)
+		}
+		for _, path := range changedFiles {
+			rel, _ := filepath.Rel(sourceDir, path)
+			if projectName != "" {
+				rel = filepath.Join(projectName, rel)
+			}
+			version.AddSubject(rel)
 		}
+
+		embeds.wait()
+		sketches = embeds.embedded()
 	}
 
 	if len(deletedFiles) > 0 {
 		logger.Info("Removing deleted files from graph", "count", len(deletedFiles))
-		removeDeletedFiles(s, projectName, deletedFiles)
+		for _, path := range deletedFiles {
+			uids = append(uids, fileUIDs(s, path)...)
+		}
+		removeDeletedFiles(s, version, deletedFiles)
+		if len(changedFiles) == 0 && state.loadSymbolTable(s, projectName) {
+			state.forgetFiles(deletedFiles...)
+			if err := state.saveSymbolTable(s, projectName); err != nil {
+				logger.Warn("Could not save symbol table", "project", projectName, "error", err)
+			}
+		}
+	}
+
+	dropOrphanVectors(s, uids)
+
+	// Callers in unchanged files may now resolve to symbols of changed files
+	if state.loadSymbolTable(s, projectName) {
+		resolveCalls(ctx, s, projectName, state, version)
+		linkImplementations(ctx, s, projectName, state, version)
 	}
 
+	for path, hash := range existingHashes {
+		if projectName != "" && !scope.Owns(path) {
+			newHashes[path] = hash
+		}
+	}
 	if err := SaveFileHashes(s, newHashes); err != nil {
 		logger.Warn("Could not save file hashes", "error", err)
 	}
+	if v, err := version.Commit(); err != nil {
+		logger.Warn("Could not record ingest version", "error", err)
+	} else {
+		logger.Info("Recorded ingest version", "version", v.ID, "added", v.Added, "removed", v.Removed)
+	}
 
-	EnhanceVirtualTriples(s)
-	TagRoles(s)
+	runEnrichers(ctx, s, projectName, sourceDir, state)
+	runVulnScan(ctx, s, projectName, sourceDir, projectMeta, opts)
+	indexTokens(s, projectName)
+	indexKinds(s, projectName)
+	detectDuplicates(ctx, s, projectName, sketches)
+	runSummaries(ctx, s, projectName, opts)
+	indexAdjacency(s, projectName)
 
 	return nil
 }
 
 // removeDeletedFiles removes all facts associated with deleted files.
 // Uses the file's graph context for efficient batch deletion.
-func removeDeletedFiles(s *meb.MEBStore, projectName string, deletedFiles []string) {
+func removeDeletedFiles(s *meb.MEBStore, rec *gcamdb.VersionRecorder, deletedFiles []string) {
 	for _, filePath := range deletedFiles {
-		if err := deleteFileFacts(s, filePath); err != nil {
+		if err := deleteFileFacts(s, rec, filePath); err != nil {
 			logger.Error("Failed to delete facts for deleted file", "file", filePath, "error", err)
 		} else {
 			logger.Info("Successfully removed facts for deleted file", "file", filePath)
@@ -324,7 +404,9 @@ func removeDeletedFiles(s *meb.MEBStore, projectName string, deletedFiles []stri
 
 // cleanupFileFacts removes all facts and vectors for a file before re-ingestion.
 // This ensures old facts and vectors are cleared when a file is modified.
-func cleanupFileFacts(s *meb.MEBStore, relPath string) error {
+// Vectors keyed by UID are left to dropOrphanVectors, since their symbols may
+// have moved to another file.
+func cleanupFileFacts(s *meb.MEBStore, rec *gcamdb.VersionRecorder, relPath string) error {
 	// First, collect symbol IDs defined in this file so we can delete their vectors
 	symbolIDs := []string{}
 	for fact, err := range s.ScanContext(context.Background(), relPath, config.PredicateDefines, "") {
@@ -337,13 +419,16 @@ func cleanupFileFacts(s *meb.MEBStore, relPath string) error {
 	}
 
 	// Delete facts first
-	if err := deleteFileFacts(s, relPath); err != nil {
+	if err := deleteFileFacts(s, rec, relPath); err != nil {
 		logger.Warn("Failed to delete facts for file", "file", relPath, "error", err)
 		return err
 	}
 
-	// Delete vectors for each symbol defined in this file
+	// Delete vectors for each symbol defined in this file, and its doc node
 	for _, symbolID := range symbolIDs {
+		if dictID, found := s.LookupID(gcamdb.DocNodeID(symbolID)); found {
+			s.Vectors().Delete(dictID)
+		}
 		dictID, found := s.LookupID(symbolID)
 		if !found {
 			continue
@@ -357,3 +442,30 @@ func cleanupFileFacts(s *meb.MEBStore, relPath string) error {
 
 	return nil
 }
+
+// fileUIDs returns the UIDs of the symbols a file defines.
+func fileUIDs(s *meb.MEBStore, relPath string) []string {
+	var uids []string
+	for fact, err := range s.ScanContext(context.Background(), relPath, config.PredicateDefines, "") {
+		if id, ok := fact.Object.(string); err == nil && ok {
+			if uid := gcamdb.UIDOf(context.Background(), s, id); uid != "" {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	return uids
+}
+
+// dropOrphanVectors deletes the vectors of the uids no symbol holds once
+// changed and deleted files are rewritten. A symbol that moved to another file
+// keeps its UID, and so its vector.
+func dropOrphanVectors(s *meb.MEBStore, uids []string) {
+	for _, uid := range uids {
+		if len(gcamdb.UIDSymbols(context.Background(), s, uid)) > 0 {
+			continue
+		}
+		if dictID, found := s.LookupID(uid); found && s.Vectors().Delete(dictID) {
+			logger.Debug("Deleted vector of removed symbol", "uid", uid)
+		}
+	}
+}
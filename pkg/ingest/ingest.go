@@ -2,11 +2,12 @@ package ingest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,25 +15,53 @@ import (
 
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 	"github.com/duynguyendang/meb/keys"
 )
 
 // IngestOptions controls embedding behavior during ingestion.
 type IngestOptions struct {
-	SkipEmbeddings bool // Skip all embedding generation
-	ReEmbed        bool // Re-embed ALL symbols (not just has_doc facts)
+	SkipEmbeddings bool   // Skip all embedding generation
+	ReEmbed        bool   // Re-embed ALL symbols (not just has_doc facts)
+	VulnScanner    string // Vulnerability enrichment: "govulncheck", "osv" or "" to disable
+	Summarize      bool   // Generate one-line LLM summaries (has_summary) for exported symbols
+
+	// Pipeline sizing; zero values use the config defaults
+	Workers      int // Parse workers (default: one per CPU, up to config.MaxWorkers)
+	JobBuffer    int // Files queued ahead of the parse workers (config.IngestJobBuffer)
+	EmbedWorkers int // Concurrent embedding requests (config.EmbeddingWorkers)
+	EmbedQueue   int // Symbols queued for embedding before parsing blocks (config.EmbeddingQueueSize)
+
+	// Progress, when set, is called with the files processed so far and the
+	// files to process, from the goroutines processing them
+	Progress func(done, total int)
 }
 
+// IngestState is the session of one ingest run: the project's symbol table
+// and file index, which resolve calls and imports across files, and the run's
+// write batching and progress journal. Concurrent ingests each use their own.
 type IngestState struct {
-	SymbolTable map[string]string
-	FileIndex   map[string]bool
+	SymbolTable map[string]string // Symbol and package-qualified names to symbol IDs
+	FileIndex   map[string]bool   // Files of the project
+	Owners      *OwnershipIndex
+	Calls       *CallReport    // Outcome of the call resolution pass, once run
+	Enrichment  []EnrichResult // Outcome of each enricher, once run
+
+	fileSymbols map[string]map[string]string // Symbol table entries by file, persisted in the store
+	tsConfigs   []*tsConfig                  // Path aliases of the project's tsconfig.json files
+	goModules   []goModule                   // Modules of the project's go.mod files
+	goPackages  map[string][]string          // Go files by directory
+
+	batch   *gcamdb.Batch  // Coalesces per-file fact writes during pass 2
+	journal *ingestJournal // Records per-file progress of a full ingest
 }
 
 func NewIngestState() *IngestState {
 	return &IngestState{
 		SymbolTable: make(map[string]string),
 		FileIndex:   make(map[string]bool),
+		fileSymbols: make(map[string]map[string]string),
 	}
 }
 
@@ -49,15 +78,24 @@ func RunWithState(s *meb.MEBStore, projectName string, sourceDir string, state *
 
 // RunWithOptions executes the ingestion process with explicit state and embedding options.
 func RunWithOptions(s *meb.MEBStore, projectName string, sourceDir string, state *IngestState, opts *IngestOptions) error {
-	SetIngestState(state)
-	ctx := context.Background()
-	ext := NewTreeSitterExtractor()
+	return RunContext(context.Background(), s, projectName, sourceDir, state, opts)
+}
 
+// RunContext executes the ingestion process until it completes or ctx is done.
+// Progress is journaled in the store per file, so a run interrupted by ctx, or
+// a crash, resumes on the next run: files already stored are skipped and
+// missing embeddings are redone.
+func RunContext(ctx context.Context, s *meb.MEBStore, projectName string, sourceDir string, state *IngestState, opts *IngestOptions) error {
 	// Set topic ID for project-scoped ingestion
 	// Uses a hash of the project name to generate a unique 24-bit topic ID
-	topicID := hashToTopicID(projectName)
+	topicID := gcamdb.TopicForProject(projectName)
 	s.SetTopicID(topicID)
 	logger.Info("Using topic ID for project", "topic_id", topicID, "project", projectName)
+	if projectName != "" {
+		if err := gcamdb.RegisterProject(s, projectName); err != nil {
+			logger.Warn("Could not register project", "project", projectName, "error", err)
+		}
+	}
 
 	var embeddingService *EmbeddingService
 	var embeddingErr error
@@ -76,8 +114,7 @@ func RunWithOptions(s *meb.MEBStore, projectName string, sourceDir string, state
 	}
 
 	logger.Info("Pass 1: Collecting symbols and index", "project", projectName)
-	state.SymbolTable = make(map[string]string)
-	state.FileIndex = make(map[string]bool)
+	state.fileSymbols = make(map[string]map[string]string)
 
 	// Check for project metadata
 	var projectMeta *ProjectMetadata
@@ -110,72 +147,99 @@ func RunWithOptions(s *meb.MEBStore, projectName string, sourceDir string, state
 		}
 	}
 
+	state.Owners = loadOwnership(sourceDir, projectMeta)
+
+	var files []sourceFile
 	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if d.IsDir() {
 			if d.Name() == "node_modules" || d.Name() == ".git" || d.Name() == "dist" || d.Name() == "build" || d.Name() == ".next" {
 				return filepath.SkipDir
 			}
 			return nil
 		}
-		if isSupportedFile(path) {
+		if isSupportedFile(path) || isTSConfig(d.Name()) || d.Name() == "go.mod" {
 			relPath, _ := filepath.Rel(sourceDir, path)
 			if projectName != "" {
 				relPath = filepath.Join(projectName, relPath)
 			}
-			state.FileIndex[relPath] = true
-
-			content, _ := os.ReadFile(path)
-			symbols, _ := ext.ExtractSymbols(path, content, relPath)
-			for _, sym := range symbols {
-				state.SymbolTable[sym.Name] = sym.ID
-				if sym.Package != "" {
-					state.SymbolTable[sym.Package+"."+sym.Name] = sym.ID
-				}
+			if isTSConfig(d.Name()) {
+				state.loadTSConfig(path, relPath)
+				return nil
 			}
+			if d.Name() == "go.mod" {
+				state.loadGoMod(path, relPath)
+				return nil
+			}
+			files = append(files, sourceFile{path: path, rel: relPath})
 		}
 		return nil
 	})
 	if err != nil {
 		return fmt.Errorf("pass 1 failed: %w", err)
 	}
+	state.indexFiles(ctx, files, opts.parseWorkers())
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("pass 1 failed: %w", err)
+	}
+	if err := state.saveSymbolTable(s, projectName); err != nil {
+		logger.Warn("Could not save symbol table", "project", projectName, "error", err)
+	}
 
 	// Pass 2: Concurrent Processing
 	logger.Info("Pass 2: Processing files", "project", projectName)
-	jobs := make(chan string, 100)
+	state.beginBatch(s)
+	state.journal = openJournal(s, projectName, state.batch)
+	if state.journal.resuming() {
+		logger.Info("Resuming interrupted ingest", "project", projectName)
+	}
+	jobs := make(chan fileJob, opts.jobBuffer())
 	var wg sync.WaitGroup
-	var embeddingWg sync.WaitGroup // Wait for embeddings to finish
 	var pass2Err atomic.Uint64
+	var resumed atomic.Int64
+	var done atomic.Int64 // Files processed or resumed, for opts.Progress
+	embeds := newEmbedPool(ctx, s, embeddingService, opts, state.journal)
 
-	workerCount := runtime.NumCPU()
-	if workerCount > config.MaxWorkers {
-		workerCount = config.MaxWorkers
-	}
-
-	for i := 0; i < workerCount; i++ {
+	for i := 0; i < opts.parseWorkers(); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			localExt := NewTreeSitterExtractor()
-			// Global semaphore for embeddings limit (max 10 concurrent)
-			sem := make(chan struct{}, 10)
-			for path := range jobs {
-				rel, _ := filepath.Rel(sourceDir, path)
-				logger.Debug("Processing file", "project", projectName, "file", rel)
-				if err := processFile(ctx, s, localExt, embeddingService, path, projectName, sourceDir, projectMeta, &embeddingWg, sem, state, opts); err != nil {
+			localExt := state.newExtractor()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				rel, _ := filepath.Rel(sourceDir, job.path)
+				state.journal.begin(rel, job.hash)
+				var err error
+				if job.stage == stageParsed {
+					logger.Debug("Resuming embeddings", "project", projectName, "file", rel)
+					err = embedParsedFile(ctx, localExt, embeds, job.path, projectName, sourceDir, projectMeta, state, opts)
+				} else {
+					logger.Debug("Processing file", "project", projectName, "file", rel)
+					err = processFile(ctx, s, localExt, embeds, job.path, projectName, sourceDir, projectMeta, state, opts)
+				}
+				if err != nil {
 					logger.Error("Failed to process file", "error", err)
 					pass2Err.Add(1)
 				}
+				opts.progress(int(done.Add(1)), len(files))
 			}
 		}()
 	}
 
-	filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+	walkErr := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if d.IsDir() {
 			if d.Name() == "node_modules" || d.Name() == ".git" || d.Name() == "dist" || d.Name() == "build" || d.Name() == ".next" {
 				return filepath.SkipDir
@@ -183,29 +247,83 @@ func RunWithOptions(s *meb.MEBStore, projectName string, sourceDir string, state
 			return nil
 		}
 		if isSupportedFile(path) {
-			jobs <- path
+			rel, _ := filepath.Rel(sourceDir, path)
+			hash, _, _ := computeFileHash(path)
+			stage := state.journal.stage(rel, hash)
+			if stage == stageEmbedded {
+				resumed.Add(1)
+				opts.progress(int(done.Add(1)), len(files))
+				return nil
+			}
+			jobs <- fileJob{path: path, hash: hash, stage: stage}
 		}
 		return nil
 	})
 	close(jobs)
 	wg.Wait()
+	if err := state.commitBatch(); err != nil {
+		return fmt.Errorf("pass 2 commit failed: %w", err)
+	}
+	if n := resumed.Load(); n > 0 {
+		logger.Info("Skipped files stored by the interrupted ingest", "count", n)
+	}
+	if ctx.Err() != nil {
+		embeds.wait()
+		return interrupted(ctx, state.journal)
+	}
+	if walkErr != nil {
+		logger.Warn("Pass 2 walk failed", "error", walkErr)
+	}
 
 	// Final Passes
-	EnhanceVirtualTriples(s)
-	TagRoles(s)
+	resolveCalls(ctx, s, projectName, state, nil)
+	linkImplementations(ctx, s, projectName, state, nil)
+	runEnrichers(ctx, s, projectName, sourceDir, state)
+	runVulnScan(ctx, s, projectName, sourceDir, projectMeta, opts)
+	indexTokens(s, projectName)
+	indexKinds(s, projectName)
+	runSummaries(ctx, s, projectName, opts)
+
+	embeds.wait()
+	if ctx.Err() != nil {
+		return interrupted(ctx, state.journal)
+	}
+	detectDuplicates(ctx, s, projectName, embeds.embedded())
 
-	if embeddingService != nil {
-		logger.Info("Waiting for embeddings to complete")
-		embeddingWg.Wait()
+	if v, err := gcamdb.RecordVersion(s, projectName, true).Commit(); err != nil {
+		logger.Warn("Could not record ingest version", "error", err)
+	} else {
+		logger.Info("Recorded ingest version", "version", v.ID)
 	}
+	state.journal.finish()
+	indexAdjacency(s, projectName)
 
 	return nil
 }
 
+// fileJob is a file queued for pass 2 with its content hash and journal stage.
+type fileJob struct {
+	path  string
+	hash  string
+	stage string
+}
+
+// interrupted saves the journal of an ingest cut short by ctx, once its
+// embedding workers have stopped, so the next run resumes. It returns the
+// context's error.
+func interrupted(ctx context.Context, journal *ingestJournal) error {
+	journal.save()
+	logger.Info("Ingest interrupted; progress saved, run it again to resume")
+	return fmt.Errorf("ingest interrupted: %w", ctx.Err())
+}
+
 // symbolEmbedTarget holds a symbol ID and text to embed
 type symbolEmbedTarget struct {
 	symbolID string
+	uid      string // Key of the symbol's vector, when it has a UID
 	text     string
+	docID    string // Doc node sharing the symbol's vector, if any
+	file     string // Source-relative path, for the ingest journal
 }
 
 // buildEmbedText constructs embedding text for re-embedding.
@@ -248,29 +366,25 @@ func buildEmbedText(symbolID string, bundleFacts []meb.Fact, content []byte) str
 	return strings.Join(parts, "\n---\n")
 }
 
-func processFile(ctx context.Context, s *meb.MEBStore, ext Extractor, embedder *EmbeddingService, path string, projectName string, sourceRoot string, meta *ProjectMetadata, embeddingWg *sync.WaitGroup, sem chan struct{}, state *IngestState, opts *IngestOptions) error {
+func processFile(ctx context.Context, s *meb.MEBStore, ext Extractor, embeds *embedPool, path string, projectName string, sourceRoot string, meta *ProjectMetadata, state *IngestState, opts *IngestOptions) error {
 	relPath, _ := filepath.Rel(sourceRoot, path)
+	file := relPath
+	owners := state.Owners.OwnersFor(relPath)
+	relPath = logicalPath(relPath, projectName, meta)
 
-	// Apply Logical Path Mapping from Metadata
-	if meta != nil && meta.Components != nil {
-		for compName, compMeta := range meta.Components {
-			// Check if path starts with component path (handle directory boundaries)
-			basePrefix := compMeta.Path
-			if relPath == basePrefix || strings.HasPrefix(relPath, basePrefix+string(os.PathSeparator)) {
-				// Rewrite path: replace physical prefix with logical component name
-				suffix := strings.TrimPrefix(relPath, basePrefix)
-				suffix = strings.TrimPrefix(suffix, string(os.PathSeparator))
-				relPath = filepath.Join(compName, suffix)
-				break // Match first component found
-			}
-		}
+	content, generated, skip, err := loadSource(path)
+	if err != nil {
+		return err
 	}
-
-	if projectName != "" {
-		relPath = filepath.Join(projectName, relPath)
+	if skip != "" {
+		logger.Info("Skipping file", "file", relPath, "reason", skip)
+		state.journal.parsed(file)
+		return nil
 	}
 
-	content, err := os.ReadFile(path)
+	// The revision this ingest builds on; another writer storing other content
+	// for the file meanwhile makes the write below fail rather than clobber it
+	baseRev, err := gcamdb.CurrentRevision(s, string(relPath))
 	if err != nil {
 		return err
 	}
@@ -280,13 +394,18 @@ func processFile(ctx context.Context, s *meb.MEBStore, ext Extractor, embedder *
 	if err != nil {
 		return err
 	}
+	trimBundle(bundle, relPath, generated)
 
-	// Retry AddDocument to handle potential DB conflicts
+	// Retry AddDocument to handle potential DB conflicts; a revision conflict is final
 	var addErr error
 	for retries := 0; retries < 3; retries++ {
-		addErr = s.AddDocumentWithTopic(s.TopicID(), string(relPath), content, nil, map[string]any{"project": projectName})
+		var rev gcamdb.DocumentRevision
+		rev, addErr = gcamdb.PutDocumentRevision(s, s.TopicID(), string(relPath), content, map[string]any{"project": projectName}, baseRev, "ingest")
 		if addErr == nil {
-			logger.Debug("Successfully stored raw content", "file", relPath)
+			logger.Debug("Successfully stored raw content", "file", relPath, "rev", rev.Rev)
+			break
+		}
+		if errors.Is(addErr, gcamdb.ErrRevisionConflict) {
 			break
 		}
 		// fast retry for conflicts
@@ -304,96 +423,111 @@ func processFile(ctx context.Context, s *meb.MEBStore, ext Extractor, embedder *
 		}
 	}
 
-	// Embed documentation for semantic search (AFTER symbols are added to ensure IDs exist)
-	if embedder != nil {
-		docFactsFound := 0
-
-		// Determine which symbols to embed
-		var symbolsToEmbed []symbolEmbedTarget
-
-		if opts != nil && opts.ReEmbed {
-			// ReEmbed mode: embed ALL symbols from their source code
-			for _, doc := range bundle.Documents {
-				// Build embed text from name + doc + content
-				text := buildEmbedText(doc.ID, bundle.Facts, doc.Content)
-				if len(text) > 10 {
-					symbolsToEmbed = append(symbolsToEmbed, symbolEmbedTarget{
-						symbolID: doc.ID,
-						text:     text,
-					})
-				}
-			}
-			logger.Debug("Re-embed mode: embedding all symbols", "count", len(symbolsToEmbed))
-		} else {
-			// Normal mode: only embed has_doc facts > 10 chars
-			for _, fact := range bundle.Facts {
-				if fact.Predicate == config.PredicateHasDoc {
-					docFactsFound++
-					docText, ok := fact.Object.(string)
-					if ok && len(docText) > 10 {
-						symbolsToEmbed = append(symbolsToEmbed, symbolEmbedTarget{
-							symbolID: fact.Subject,
-							text:     docText,
-						})
-					}
-				}
-			}
-		}
+	// Embed documentation for semantic search (AFTER symbols are added to ensure IDs exist).
+	// Generated code is not embedded.
+	if embeds != nil && !generated {
+		enqueueEmbeddings(ctx, embeds, file, embedTargets(bundle, opts), state.journal)
+	}
 
-		for _, target := range symbolsToEmbed {
-			if embeddingWg != nil {
-				embeddingWg.Add(1)
-			}
+	finalFacts := fileFacts(relPath, bundle, owners, meta, state)
+	logger.Debug("Total facts being added", "total", len(finalFacts))
 
-			go func(symbolID string, text string) {
-				defer func() {
-					if r := recover(); r != nil {
-						logger.Error("Panic in embedding goroutine", "symbol", symbolID, "panic", r)
-					}
-				}()
-
-				// Acquire semaphore
-				if sem != nil {
-					sem <- struct{}{}
-					defer func() { <-sem }()
-				}
+	if err := state.writeFacts(s, finalFacts); err != nil {
+		return err
+	}
+	state.journal.parsed(file)
+	return nil
+}
 
-				if embeddingWg != nil {
-					defer embeddingWg.Done()
-				}
+// embedParsedFile queues the embeddings of a file whose facts an interrupted
+// ingest already stored.
+func embedParsedFile(ctx context.Context, ext Extractor, embeds *embedPool, path string, projectName string, sourceRoot string, meta *ProjectMetadata, state *IngestState, opts *IngestOptions) error {
+	file, _ := filepath.Rel(sourceRoot, path)
+	relPath := logicalPath(file, projectName, meta)
+	content, generated, skip, err := loadSource(path)
+	if err != nil {
+		return err
+	}
+	if skip == "" && !generated && embeds != nil {
+		bundle, err := ext.Extract(ctx, relPath, content)
+		if err != nil {
+			return err
+		}
+		trimBundle(bundle, relPath, generated)
+		enqueueEmbeddings(ctx, embeds, file, embedTargets(bundle, opts), state.journal)
+	}
+	state.journal.parsed(file)
+	return nil
+}
 
-				// Add a timeout to prevent hanging
-				ctxWithTimeout, cancel := context.WithTimeout(context.Background(), config.EmbeddingTimeout)
-				defer cancel()
+// enqueueEmbeddings queues the embedding targets of a file, recording them in
+// the journal first so the file is not marked embedded before they are done.
+func enqueueEmbeddings(ctx context.Context, embeds *embedPool, file string, targets []symbolEmbedTarget, journal *ingestJournal) {
+	journal.expect(file, len(targets))
+	for _, target := range targets {
+		target.file = file
+		embeds.enqueue(ctx, target)
+	}
+}
 
-				logger.Debug("Generating embedding", "symbol", symbolID, "length", len(text))
-				embed, err := embedder.GetEmbedding(ctxWithTimeout, text)
-				if err != nil {
-					logger.Error("Error generating embedding", "symbol", symbolID, "error", err)
-					return
-				}
+// embedTargets returns the symbols of a bundle to embed: documented symbols, or
+// every symbol in re-embed mode.
+func embedTargets(bundle *AnalysisBundle, opts *IngestOptions) []symbolEmbedTarget {
+	var symbolsToEmbed []symbolEmbedTarget
+	uids := make(map[string]string)
+	for _, fact := range bundle.Facts {
+		if uid, ok := fact.Object.(string); ok && fact.Predicate == config.PredicateHasUID {
+			uids[fact.Subject] = uid
+		}
+	}
 
-				if len(embed) == 0 {
-					logger.Error("Empty embedding", "symbol", symbolID)
-					return
-				}
+	if opts != nil && opts.ReEmbed {
+		// ReEmbed mode: embed ALL symbols from their source code
+		for _, doc := range bundle.Documents {
+			// Build embed text from name + doc + content
+			text := buildEmbedText(doc.ID, bundle.Facts, doc.Content)
+			if len(text) > 10 {
+				symbolsToEmbed = append(symbolsToEmbed, symbolEmbedTarget{
+					symbolID: doc.ID,
+					uid:      uids[doc.ID],
+					text:     text,
+				})
+			}
+		}
+		logger.Debug("Re-embed mode: embedding all symbols", "count", len(symbolsToEmbed))
+		return symbolsToEmbed
+	}
 
-				// Look up the correct dictionary ID for the symbol
-				dictID, found := s.LookupID(string(symbolID))
-				if !found {
-					logger.Error("ID not found in dictionary, cannot store vector", "symbol", symbolID)
-					return
+	// Normal mode: only embed has_doc facts > 10 chars, for the symbol and its
+	// doc node alike
+	docNodes := make(map[string]bool)
+	for _, doc := range bundle.Documents {
+		if gcamdb.IsDocNode(doc.ID) {
+			docNodes[doc.ID] = true
+		}
+	}
+	for _, fact := range bundle.Facts {
+		if fact.Predicate == config.PredicateHasDoc {
+			docText, ok := fact.Object.(string)
+			if ok && len(docText) > 10 {
+				target := symbolEmbedTarget{
+					symbolID: fact.Subject,
+					uid:      uids[fact.Subject],
+					text:     docText,
 				}
-
-				if err := s.Vectors().Add(dictID, embed); err != nil {
-					logger.Error("Error adding vector to store", "symbol", symbolID, "error", err)
-				} else {
-					logger.Info("Successfully stored embedding", "symbol", symbolID, "dict_id", dictID)
+				if docID := gcamdb.DocNodeID(fact.Subject); docNodes[docID] {
+					target.docID = docID
 				}
-			}(target.symbolID, target.text)
+				symbolsToEmbed = append(symbolsToEmbed, target)
+			}
 		}
 	}
+	return symbolsToEmbed
+}
 
+// fileFacts returns the facts stored for a file: its extracted facts with calls
+// resolved through the symbol table, plus role tags, its file type and ownership.
+func fileFacts(relPath string, bundle *AnalysisBundle, owners []string, meta *ProjectMetadata, state *IngestState) []meb.Fact {
 	finalFacts := make([]meb.Fact, 0, len(bundle.Facts)+2)
 
 	// Inject Role Tags based on path or metadata
@@ -419,9 +553,18 @@ func processFile(ctx context.Context, s *meb.MEBStore, ext Extractor, embedder *
 	// Make sure file has type "file"
 	finalFacts = append(finalFacts, meb.Fact{Subject: string(relPath), Predicate: config.PredicateType, Object: config.SymbolKindFile})
 
-	hasNameCount := 0
+	// Ownership: the file and every symbol it defines inherit the file's owners
+	if len(owners) > 0 {
+		finalFacts = append(finalFacts, ownerFacts(relPath, owners)...)
+		for _, doc := range bundle.Documents {
+			if doc.ID != relPath {
+				finalFacts = append(finalFacts, ownerFacts(doc.ID, owners)...)
+			}
+		}
+	}
+
 	for _, f := range bundle.Facts {
-		if f.Predicate == config.PredicateCalls {
+		if slices.Contains(resolvedPredicates, f.Predicate) {
 			if objStr, ok := f.Object.(string); ok {
 				if resolved, ok := state.SymbolTable[objStr]; ok {
 					f.Object = resolved
@@ -429,35 +572,184 @@ func processFile(ctx context.Context, s *meb.MEBStore, ext Extractor, embedder *
 			}
 		}
 
-		// Track has_name facts for debug logging
-		if f.Predicate == config.PredicateHasName {
-			hasNameCount++
+		finalFacts = append(finalFacts, f)
+	}
+	if strings.HasSuffix(relPath, ".go") {
+		finalFacts = append(finalFacts, goImportFileFacts(relPath, bundle.Facts, state)...)
+	}
+
+	return finalFacts
+}
+
+// beginBatch starts coalescing fact writes into larger store batches. If another
+// batch is already open on the store, writes go straight to the store instead.
+func (state *IngestState) beginBatch(s *meb.MEBStore) {
+	b, err := gcamdb.BeginBatch(s)
+	if err != nil {
+		logger.Warn("Write coalescing disabled", "error", err)
+		return
+	}
+	state.batch = b
+}
+
+// commitBatch flushes and closes the batch opened by beginBatch.
+func (state *IngestState) commitBatch() error {
+	if state.batch == nil {
+		return nil
+	}
+	b := state.batch
+	state.batch = nil
+	return b.Commit()
+}
+
+// writeFacts adds facts through the open batch, if any.
+func (state *IngestState) writeFacts(s *meb.MEBStore, facts []meb.Fact) error {
+	if state.batch == nil {
+		return s.AddFactBatch(facts)
+	}
+	_, err := state.batch.Add(facts...)
+	return err
+}
+
+// logicalPath maps a path relative to the source root onto the file ID used in the graph:
+// component prefixes from project metadata are rewritten to the component name and the
+// project name is prepended.
+func logicalPath(relPath, projectName string, meta *ProjectMetadata) string {
+	// Apply Logical Path Mapping from Metadata
+	if meta != nil && meta.Components != nil {
+		for compName, compMeta := range meta.Components {
+			// Check if path starts with component path (handle directory boundaries)
+			basePrefix := compMeta.Path
+			if relPath == basePrefix || strings.HasPrefix(relPath, basePrefix+string(os.PathSeparator)) {
+				// Rewrite path: replace physical prefix with logical component name
+				suffix := strings.TrimPrefix(relPath, basePrefix)
+				suffix = strings.TrimPrefix(suffix, string(os.PathSeparator))
+				relPath = filepath.Join(compName, suffix)
+				break // Match first component found
+			}
 		}
+	}
 
-		finalFacts = append(finalFacts, f)
+	if projectName != "" {
+		relPath = filepath.Join(projectName, relPath)
 	}
+	return relPath
+}
 
-	logger.Debug("Total facts being added", "total", len(finalFacts), "has_name_count", hasNameCount)
+// runVulnScan runs the vulnerability enrichment pass when enabled in opts.
+// Scanner failures are logged rather than failing the ingestion.
+func runVulnScan(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string, meta *ProjectMetadata, opts *IngestOptions) {
+	if opts == nil || opts.VulnScanner == "" {
+		return
+	}
+	scanner, err := NewVulnScanner(opts.VulnScanner)
+	if err != nil {
+		logger.Warn("Vulnerability scan disabled", "error", err)
+		return
+	}
+	if err := AnnotateVulnerabilities(ctx, s, projectName, sourceDir, meta, scanner); err != nil {
+		logger.Warn("Vulnerability scan failed", "scanner", opts.VulnScanner, "error", err)
+	}
+}
 
-	return s.AddFactBatch(finalFacts)
+// loadOwnership builds the ownership index for sourceDir, logging and ignoring failures
+// so that a malformed CODEOWNERS file never blocks ingestion.
+func loadOwnership(sourceDir string, meta *ProjectMetadata) *OwnershipIndex {
+	owners, err := NewOwnershipIndex(sourceDir, meta)
+	if err != nil {
+		logger.Warn("Failed to load ownership rules", "error", err)
+		return nil
+	}
+	if owners != nil {
+		logger.Info("Loaded ownership rules", "rules", len(owners.rules))
+	}
+	return owners
 }
 
-func isSupportedFile(path string) bool {
-	ext := filepath.Ext(path)
-	return ext == ".go" || ext == ".ts" || ext == ".tsx" || ext == ".js" || ext == ".py" || ext == ".md"
+// ownerFacts emits one owned_by fact per owner for the given subject.
+func ownerFacts(subject string, owners []string) []meb.Fact {
+	facts := make([]meb.Fact, 0, len(owners))
+	for _, owner := range owners {
+		facts = append(facts, meb.Fact{Subject: subject, Predicate: config.PredicateOwnedBy, Object: owner})
+	}
+	return facts
+}
+
+// indexTokens rebuilds the project's keyword search index, which serves search
+// when no embedding provider is configured.
+func indexTokens(s *meb.MEBStore, projectName string) {
+	idx := gcamdb.BuildTokenIndex(projectScope(context.Background(), s, projectName), s)
+	if err := gcamdb.SaveTokenIndex(s, projectName, idx); err != nil {
+		logger.Warn("Could not save token index", "project", projectName, "error", err)
+		return
+	}
+	logger.Info("Indexed symbol tokens", "project", projectName, "symbols", len(idx.Docs))
+}
+
+// indexKinds rebuilds the project's kind index, which labels graph nodes
+// without hydrating them.
+func indexKinds(s *meb.MEBStore, projectName string) {
+	idx := gcamdb.BuildKindIndex(projectScope(context.Background(), s, projectName), s)
+	if err := gcamdb.SaveKindIndex(s, projectName, idx); err != nil {
+		logger.Warn("Could not save kind index", "project", projectName, "error", err)
+		return
+	}
+	logger.Info("Indexed node kinds", "project", projectName, "nodes", len(idx.Kinds))
 }
 
-// hashToTopicID generates a deterministic 24-bit topic ID from a project name.
-func hashToTopicID(name string) uint32 {
-	if name == "" {
-		return 1
+// indexAdjacency saves the project's adjacency snapshot, which serves graph
+// algorithms its structural edges. It runs last: the snapshot is only used
+// while the store's fact count is the one it was built at, so the count is
+// recalculated first, as adding a fact the store already holds counts it again.
+// This is the run's only recount; callers of a run need not recount after it.
+func indexAdjacency(s *meb.MEBStore, projectName string) {
+	facts, err := s.RecalculateStats()
+	if err != nil {
+		logger.Warn("Could not count facts", "error", err)
+		facts = s.Count()
 	}
-	var h uint32 = 2166136261 // FNV-1a offset basis
-	for i := 0; i < len(name); i++ {
-		h ^= uint32(name[i])
-		h *= 16777619 // FNV-1a prime
+	adj := gcamdb.BuildAdjacency(projectScope(context.Background(), s, projectName), s, facts)
+	if err := gcamdb.SaveAdjacency(s, projectName, adj); err != nil {
+		logger.Warn("Could not save adjacency snapshot", "project", projectName, "error", err)
+		return
 	}
-	return (h & 0xFFFFFF) | 1 // ensure non-zero (0 is reserved)
+	logger.Info("Saved adjacency snapshot", "project", projectName, "nodes", len(adj.Nodes()))
+}
+
+// tagEntryPoints records the project's entry points as is_entry_point facts.
+func tagEntryPoints(s *meb.MEBStore, projectName string) {
+	entries, err := gcamdb.TagEntryPoints(projectScope(context.Background(), s, projectName), s)
+	if err != nil {
+		logger.Warn("Could not tag entry points", "project", projectName, "error", err)
+		return
+	}
+	logger.Info("Tagged entry points", "project", projectName, "count", len(entries))
+}
+
+// tagCentralNodes records the project's most central nodes as has_centrality
+// facts, which server warmups start from.
+func tagCentralNodes(s *meb.MEBStore, projectName string) {
+	nodes, err := gcamdb.TagCentralNodes(projectScope(context.Background(), s, projectName), s, config.CentralNodesTagged)
+	if err != nil {
+		logger.Warn("Could not tag central nodes", "project", projectName, "error", err)
+		return
+	}
+	logger.Info("Tagged central nodes", "project", projectName, "count", len(nodes))
+}
+
+// projectScope limits ctx to projectName's facts in the store's current topic.
+// Passes reading the store after a project's ingest use it so that a shared
+// store's other projects are left out.
+func projectScope(ctx context.Context, s *meb.MEBStore, projectName string) context.Context {
+	if projectName == "" {
+		return ctx
+	}
+	return gcamdb.WithScope(ctx, gcamdb.Scope{Project: projectName, Topic: s.TopicID()})
+}
+
+func isSupportedFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".go" || ext == ".ts" || ext == ".tsx" || ext == ".js" || ext == ".py" || ext == ".md"
 }
 
 func TagRoles(s *meb.MEBStore) error {
@@ -0,0 +1,202 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// JournalKeyPrefix prefixes the store key of a project's ingest journal.
+const JournalKeyPrefix = "gca:ingest_journal:"
+
+// Stages of a file in the ingest journal.
+const (
+	stagePending  = "pending"  // Queued; its facts may be missing or partial
+	stageParsed   = "parsed"   // Facts stored; some embeddings may be missing
+	stageEmbedded = "embedded" // Facts and embeddings stored
+)
+
+// journalEntry is the ingest state of one file.
+type journalEntry struct {
+	Hash  string `json:"hash"`
+	Stage string `json:"stage"`
+
+	embedsLeft int  // Embeddings queued but not done
+	stored     bool // Facts written
+}
+
+// ingestJournal records per-file progress of a full ingest in the store, so
+// an interrupted ingest resumes where it stopped: files whose facts and
+// embeddings are stored are skipped, and parsed files only have their
+// embeddings redone. It is saved every config.IngestJournalFlushFiles
+// transitions, after flushing buffered facts, and removed once the ingest
+// completes. A nil journal records nothing.
+type ingestJournal struct {
+	mu      sync.Mutex
+	s       *meb.MEBStore
+	key     string
+	files   map[string]*journalEntry
+	batch   *gcamdb.Batch // Flushed before a save so parsed files' facts are durable
+	changes int
+}
+
+// openJournal loads the journal of an interrupted ingest of projectName, or
+// starts an empty one. batch, if not nil, buffers the ingest's fact writes.
+func openJournal(s *meb.MEBStore, projectName string, batch *gcamdb.Batch) *ingestJournal {
+	j := &ingestJournal{
+		s:     s,
+		key:   JournalKeyPrefix + projectName,
+		files: make(map[string]*journalEntry),
+		batch: batch,
+	}
+	content, err := s.GetContentByKey(j.key)
+	if err != nil || len(content) == 0 {
+		return j
+	}
+	if err := json.Unmarshal(content, &j.files); err != nil {
+		logger.Warn("Ignoring unreadable ingest journal", "project", projectName, "error", err)
+		j.files = make(map[string]*journalEntry)
+	}
+	return j
+}
+
+// resuming reports whether the journal holds progress from an earlier run.
+func (j *ingestJournal) resuming() bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.files) > 0
+}
+
+// stage returns the stage recorded for a file with the given content hash, or
+// stagePending if the file is new or changed since.
+func (j *ingestJournal) stage(file, hash string) string {
+	if j == nil {
+		return stagePending
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if e, ok := j.files[file]; ok && e.Hash == hash {
+		return e.Stage
+	}
+	return stagePending
+}
+
+// begin records a file as pending with the given hash.
+func (j *ingestJournal) begin(file, hash string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.files[file] = &journalEntry{Hash: hash, Stage: stagePending}
+	j.changedLocked()
+}
+
+// expect records n embeddings about to be queued for a file.
+func (j *ingestJournal) expect(file string, n int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if e, ok := j.files[file]; ok {
+		e.embedsLeft += n
+	}
+}
+
+// parsed records that the facts of a file are written.
+func (j *ingestJournal) parsed(file string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if e, ok := j.files[file]; ok {
+		e.stored = true
+		j.advanceLocked(e)
+	}
+}
+
+// embedded records that one queued embedding of a file is done.
+func (j *ingestJournal) embedded(file string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if e, ok := j.files[file]; ok {
+		e.embedsLeft--
+		j.advanceLocked(e)
+	}
+}
+
+func (j *ingestJournal) advanceLocked(e *journalEntry) {
+	stage := e.Stage
+	if e.stored {
+		stage = stageParsed
+		if e.embedsLeft <= 0 {
+			stage = stageEmbedded
+		}
+	}
+	if stage != e.Stage {
+		e.Stage = stage
+		j.changedLocked()
+	}
+}
+
+func (j *ingestJournal) changedLocked() {
+	if j.changes++; j.changes >= config.IngestJournalFlushFiles {
+		j.saveLocked()
+	}
+}
+
+// save writes the journal to the store.
+func (j *ingestJournal) save() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.saveLocked()
+}
+
+func (j *ingestJournal) saveLocked() {
+	j.changes = 0
+	// A committed batch has written everything
+	if j.batch != nil {
+		if err := j.batch.Flush(); err != nil && !errors.Is(err, gcamdb.ErrBatchClosed) {
+			logger.Warn("Could not flush facts before saving the ingest journal", "error", err)
+			return
+		}
+	}
+	data, err := json.Marshal(j.files)
+	if err != nil {
+		logger.Warn("Could not encode ingest journal", "error", err)
+		return
+	}
+	if err := j.s.AddDocument(j.key, data, nil, nil); err != nil {
+		logger.Warn("Could not save ingest journal", "error", err)
+	}
+}
+
+// finish removes the journal of a completed ingest.
+func (j *ingestJournal) finish() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.files = make(map[string]*journalEntry)
+	if err := j.s.DeleteDocument(j.key); err != nil {
+		// Fall back to an empty journal, which resumes nothing
+		j.saveLocked()
+	}
+}
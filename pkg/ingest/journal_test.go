@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestJournalStages(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(gcamdb.TopicForProject("app"))
+
+	j := openJournal(s, "app", nil)
+	if j.resuming() {
+		t.Fatal("new journal is resuming")
+	}
+	j.begin("a.go", "h1")
+	j.expect("a.go", 2)
+	j.embedded("a.go") // Embeddings can finish before the facts are written
+	j.parsed("a.go")
+	if got := j.stage("a.go", "h1"); got != stageParsed {
+		t.Errorf("stage with an embedding left = %s, want parsed", got)
+	}
+	j.begin("b.go", "h2")
+	j.parsed("b.go")
+	j.begin("c.go", "h3")
+	j.save()
+
+	j = openJournal(s, "app", nil)
+	if !j.resuming() {
+		t.Fatal("saved journal is not resuming")
+	}
+	for _, tt := range []struct{ file, hash, want string }{
+		{"a.go", "h1", stageParsed},
+		{"b.go", "h2", stageEmbedded},
+		{"b.go", "changed", stagePending},
+		{"c.go", "h3", stagePending},
+		{"d.go", "h4", stagePending},
+	} {
+		if got := j.stage(tt.file, tt.hash); got != tt.want {
+			t.Errorf("stage(%s, %s) = %s, want %s", tt.file, tt.hash, got, tt.want)
+		}
+	}
+
+	j.finish()
+	if openJournal(s, "app", nil).resuming() {
+		t.Error("finished journal is still resuming")
+	}
+}
+
+func TestRunContextResumes(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"done.go": "package main\n\nfunc Done() {}\n",
+		"todo.go": "package main\n\nfunc Todo() {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An interrupted run stored done.go, then a cancelled run changes nothing
+	s.SetTopicID(gcamdb.TopicForProject("app"))
+	hash, _, err := computeFileHash(filepath.Join(src, "done.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := openJournal(s, "app", nil)
+	j.begin("done.go", hash)
+	j.parsed("done.go")
+	j.save()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := RunContext(ctx, s, "app", src, NewIngestState(), &IngestOptions{SkipEmbeddings: true}); err == nil {
+		t.Fatal("cancelled ingest succeeded")
+	}
+	if !openJournal(s, "app", nil).resuming() {
+		t.Fatal("cancelled ingest dropped the journal")
+	}
+
+	if err := RunContext(context.Background(), s, "app", src, NewIngestState(), &IngestOptions{SkipEmbeddings: true}); err != nil {
+		t.Fatalf("resumed ingest failed: %v", err)
+	}
+	defines := func(file string) bool {
+		for fact, err := range s.Scan(file, config.PredicateDefines, "") {
+			if err == nil && fact.Subject == file {
+				return true
+			}
+		}
+		return false
+	}
+	if defines("app/done.go") {
+		t.Error("file stored by the interrupted run was ingested again")
+	}
+	if !defines("app/todo.go") {
+		t.Error("remaining file was not ingested")
+	}
+	if openJournal(s, "app", nil).resuming() {
+		t.Error("journal kept after the ingest completed")
+	}
+	// The run ends with its one recount, at which the adjacency snapshot is built
+	adj, err := gcamdb.LoadAdjacency(s, "app")
+	if err != nil || !adj.Fresh(s) {
+		t.Errorf("adjacency snapshot after the run: %v, fresh = %v; want a fresh one", err, adj.Fresh(s))
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/prompts"
+
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/core/api"
 	"github.com/firebase/genkit/go/genkit"
@@ -22,11 +27,12 @@ type EmbeddingService struct {
 	embeddingModel string
 }
 
-// NewEmbeddingService creates a new service instance.
-func NewEmbeddingService(ctx context.Context) (*EmbeddingService, error) {
+// llmPlugins returns the genkit plugins of the provider configured by
+// LLM_PROVIDER and LLM_API_KEY, and the provider's name.
+func llmPlugins() ([]api.Plugin, string, error) {
 	apiKey := os.Getenv("LLM_API_KEY")
 	if apiKey == "" {
-		return nil, fmt.Errorf("LLM_API_KEY not set")
+		return nil, "", fmt.Errorf("LLM_API_KEY not set")
 	}
 
 	provider := os.Getenv("LLM_PROVIDER")
@@ -52,6 +58,15 @@ func NewEmbeddingService(ctx context.Context) (*EmbeddingService, error) {
 	default:
 		plugins = append(plugins, &googlegenai.GoogleAI{APIKey: apiKey})
 	}
+	return plugins, provider, nil
+}
+
+// NewEmbeddingService creates a new service instance.
+func NewEmbeddingService(ctx context.Context) (*EmbeddingService, error) {
+	plugins, provider, err := llmPlugins()
+	if err != nil {
+		return nil, err
+	}
 
 	model := os.Getenv("EMBEDDING_MODEL")
 	if model == "" {
@@ -111,3 +126,99 @@ func (s *EmbeddingService) GetEmbedding(ctx context.Context, text string) ([]flo
 	}
 	return result, nil
 }
+
+// SummaryService writes symbol summaries with the configured text model
+// (LLM_MODEL, or the provider's default).
+type SummaryService struct {
+	g      *genkit.Genkit
+	model  string
+	prompt *prompts.Prompt
+}
+
+// NewSummaryService creates a summarizer of symbols for the configured LLM
+// provider.
+func NewSummaryService(ctx context.Context) (*SummaryService, error) {
+	return newSummaryService(ctx, "summarize")
+}
+
+// NewFileSummaryService creates a summarizer of whole files for the configured
+// LLM provider, for SummarizeFiles.
+func NewFileSummaryService(ctx context.Context) (*SummaryService, error) {
+	return newSummaryService(ctx, "summarize_files")
+}
+
+func newSummaryService(ctx context.Context, promptName string) (*SummaryService, error) {
+	plugins, provider, err := llmPlugins()
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := prompts.LoadPrompt(config.PromptPaths[promptName])
+	if err != nil {
+		return nil, err
+	}
+
+	model := os.Getenv("LLM_MODEL")
+	if model == "" {
+		switch provider {
+		case "openai":
+			model = "openai/gpt-4o-mini"
+		case "anthropic":
+			model = "anthropic/claude-3-5-haiku-20241022"
+		case "ollama":
+			model = "ollama/llama3.2"
+		default:
+			model = "googleai/gemini-2.5-flash"
+		}
+	} else if !strings.Contains(model, "/") {
+		model = provider + "/" + model
+	}
+
+	return &SummaryService{
+		g:      genkit.Init(ctx, genkit.WithPlugins(plugins...)),
+		model:  model,
+		prompt: prompt,
+	}, nil
+}
+
+// summaryLine matches a numbered answer line such as "3: Parses the config file."
+var summaryLine = regexp.MustCompile(`^\s*\[?(\d+)[\]:.)]\s*(.+)$`)
+
+// Summarize implements Summarizer with one model request for the whole batch.
+func (s *SummaryService) Summarize(ctx context.Context, symbols []SummaryInput) ([]string, error) {
+	type numbered struct {
+		N int
+		SummaryInput
+	}
+	items := make([]numbered, len(symbols))
+	for i, sym := range symbols {
+		items[i] = numbered{i + 1, sym}
+	}
+	promptStr, err := s.prompt.Execute(map[string]any{"symbols": items})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.SummaryTimeout)
+	defer cancel()
+
+	resp, err := genkit.Generate(ctx, s.g,
+		ai.WithModelName(s.model),
+		ai.WithPrompt(promptStr),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("summary generation failed: %w", err)
+	}
+
+	summaries := make([]string, len(symbols))
+	for _, line := range strings.Split(resp.Text(), "\n") {
+		m := summaryLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		if n >= 1 && n <= len(summaries) && summaries[n-1] == "" {
+			summaries[n-1] = strings.TrimSpace(m[2])
+		}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,267 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+)
+
+// Dependency ecosystems recognised in manifests.
+const (
+	EcosystemGo  = "go"
+	EcosystemNPM = "npm"
+)
+
+// Dependency is a third-party module declared in a manifest (go.mod, package.json).
+type Dependency struct {
+	Module    string
+	Version   string
+	Ecosystem string
+}
+
+// ID returns the external-package node ID ("module@version").
+func (d Dependency) ID() string {
+	if d.Version == "" {
+		return d.Module
+	}
+	return d.Module + "@" + d.Version
+}
+
+// Manifests aggregates every manifest found under a source tree.
+// FirstParty holds the module names declared by the project itself
+// (go.mod "module" directives, package.json "name" fields).
+type Manifests struct {
+	FirstParty   map[string]bool
+	Dependencies []Dependency
+}
+
+// ParseGoMod extracts the module path and required modules from go.mod content.
+func ParseGoMod(data []byte) (string, []Dependency) {
+	var modulePath string
+	var deps []Dependency
+	inRequire := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "//"); idx != -1 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if inRequire {
+			if line == ")" {
+				inRequire = false
+				continue
+			}
+			if dep, ok := parseGoRequire(line); ok {
+				deps = append(deps, dep)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "module "):
+			modulePath = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`)
+		case line == "require (":
+			inRequire = true
+		case strings.HasPrefix(line, "require "):
+			if dep, ok := parseGoRequire(strings.TrimPrefix(line, "require ")); ok {
+				deps = append(deps, dep)
+			}
+		}
+	}
+	return modulePath, deps
+}
+
+func parseGoRequire(line string) (Dependency, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Dependency{}, false
+	}
+	return Dependency{
+		Module:    strings.Trim(fields[0], `"`),
+		Version:   fields[1],
+		Ecosystem: EcosystemGo,
+	}, true
+}
+
+// ParsePackageJSON extracts the package name and dependencies from package.json content.
+// Runtime, dev and peer dependencies are all included.
+func ParsePackageJSON(data []byte) (string, []Dependency, error) {
+	var pkg struct {
+		Name                 string            `json:"name"`
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		PeerDependencies     map[string]string `json:"peerDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return "", nil, err
+	}
+
+	seen := make(map[string]bool)
+	var deps []Dependency
+	for _, group := range []map[string]string{pkg.Dependencies, pkg.PeerDependencies, pkg.OptionalDependencies, pkg.DevDependencies} {
+		names := make([]string, 0, len(group))
+		for name := range group {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			deps = append(deps, Dependency{Module: name, Version: group[name], Ecosystem: EcosystemNPM})
+		}
+	}
+	return pkg.Name, deps, nil
+}
+
+// FindManifests walks sourceDir and parses every go.mod and package.json it finds.
+// Dependencies that point at another first-party module (monorepos) are dropped.
+func FindManifests(sourceDir string) (*Manifests, error) {
+	m := &Manifests{FirstParty: make(map[string]bool)}
+	var all []Dependency
+
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" || d.Name() == ".git" || d.Name() == "dist" || d.Name() == "build" || d.Name() == ".next" || d.Name() == "vendor" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch d.Name() {
+		case "go.mod":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			modulePath, deps := ParseGoMod(data)
+			if modulePath != "" {
+				m.FirstParty[modulePath] = true
+			}
+			all = append(all, deps...)
+		case "package.json":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			name, deps, err := ParsePackageJSON(data)
+			if err != nil {
+				rel, _ := filepath.Rel(sourceDir, path)
+				logger.Warn("Skipping malformed package.json", "file", rel, "error", err)
+				return nil
+			}
+			if name != "" {
+				m.FirstParty[name] = true
+			}
+			all = append(all, deps...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, dep := range all {
+		if m.isFirstParty(dep.Module) || seen[dep.Ecosystem+"|"+dep.ID()] {
+			continue
+		}
+		seen[dep.Ecosystem+"|"+dep.ID()] = true
+		m.Dependencies = append(m.Dependencies, dep)
+	}
+	return m, nil
+}
+
+// isFirstParty reports whether module is, or is nested under, a module declared by the project.
+func (m *Manifests) isFirstParty(module string) bool {
+	for name := range m.FirstParty {
+		if module == name || strings.HasPrefix(module, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveImport returns the dependency that provides importPath, preferring the longest module match.
+func (m *Manifests) ResolveImport(importPath string) (Dependency, bool) {
+	var best Dependency
+	found := false
+	for _, dep := range m.Dependencies {
+		if importPath == dep.Module || strings.HasPrefix(importPath, dep.Module+"/") {
+			if !found || len(dep.Module) > len(best.Module) {
+				best = dep
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+// IngestManifests emits depends_on facts and external-package nodes for the project's
+// manifest dependencies, then marks imports resolving to those modules as external.
+func IngestManifests(s *meb.MEBStore, projectName, sourceDir string) error {
+	manifests, err := FindManifests(sourceDir)
+	if err != nil {
+		return err
+	}
+	if len(manifests.Dependencies) == 0 {
+		return nil
+	}
+
+	var facts []meb.Fact
+	for _, dep := range manifests.Dependencies {
+		id := dep.ID()
+		facts = append(facts,
+			meb.Fact{Subject: projectName, Predicate: config.PredicateDependsOn, Object: id},
+			meb.Fact{Subject: id, Predicate: config.PredicateHasKind, Object: config.SymbolKindExternalPackage},
+			meb.Fact{Subject: id, Predicate: config.PredicateIsInternal, Object: false},
+			meb.Fact{Subject: id, Predicate: config.PredicateHasTag, Object: dep.Ecosystem},
+		)
+	}
+
+	external := 0
+	seen := make(map[string]bool)
+	for fact, err := range s.Scan("", config.PredicateImports, "") {
+		if err != nil {
+			continue
+		}
+		target, ok := fact.Object.(string)
+		if !ok || seen[target] {
+			continue
+		}
+		seen[target] = true
+		if dep, ok := manifests.ResolveImport(target); ok {
+			facts = append(facts,
+				meb.Fact{Subject: target, Predicate: config.PredicateIsInternal, Object: false},
+				meb.Fact{Subject: target, Predicate: config.PredicateProvidedBy, Object: dep.ID()},
+			)
+			external++
+		}
+	}
+
+	if err := s.AddFactBatch(facts); err != nil {
+		return err
+	}
+	logger.Info("Ingested dependency manifests", "dependencies", len(manifests.Dependencies), "external_imports", external)
+	return nil
+}
@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGoMod(t *testing.T) {
+	modulePath, deps := ParseGoMod([]byte(`module github.com/acme/app
+
+go 1.22
+
+require github.com/spf13/cobra v1.8.0
+
+require (
+	github.com/gin-gonic/gin v1.9.1
+	golang.org/x/sync v0.5.0 // indirect
+)
+`))
+	if modulePath != "github.com/acme/app" {
+		t.Errorf("module path = %q", modulePath)
+	}
+	if len(deps) != 3 {
+		t.Fatalf("expected 3 deps, got %d: %+v", len(deps), deps)
+	}
+	if deps[1].ID() != "github.com/gin-gonic/gin@v1.9.1" {
+		t.Errorf("unexpected dep ID %q", deps[1].ID())
+	}
+}
+
+func TestFindManifestsResolveImport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/acme/app\n\nrequire (\n\tgithub.com/gin-gonic/gin v1.9.1\n\tgithub.com/acme/app/tools v0.1.0\n)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	web := filepath.Join(dir, "web")
+	if err := os.MkdirAll(web, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(web, "package.json"), []byte(`{"name":"acme-web","dependencies":{"react":"^18.2.0"},"devDependencies":{"@types/react":"^18.0.0"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := FindManifests(dir)
+	if err != nil {
+		t.Fatalf("FindManifests failed: %v", err)
+	}
+	if !m.FirstParty["github.com/acme/app"] || !m.FirstParty["acme-web"] {
+		t.Errorf("first-party modules not detected: %v", m.FirstParty)
+	}
+	if len(m.Dependencies) != 3 {
+		t.Errorf("expected 3 dependencies, got %d: %+v", len(m.Dependencies), m.Dependencies)
+	}
+
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{"github.com/gin-gonic/gin/binding", "github.com/gin-gonic/gin@v1.9.1"},
+		{"react", "react@^18.2.0"},
+		{"@types/react", "@types/react@^18.0.0"},
+		{"github.com/acme/app/pkg/api", ""},
+		{"fmt", ""},
+	}
+	for _, tt := range tests {
+		dep, ok := m.ResolveImport(tt.importPath)
+		got := ""
+		if ok {
+			got = dep.ID()
+		}
+		if got != tt.want {
+			t.Errorf("ResolveImport(%q) = %q, want %q", tt.importPath, got, tt.want)
+		}
+	}
+}
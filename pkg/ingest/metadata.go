@@ -9,9 +9,10 @@ import (
 
 // ComponentMetadata defines metadata for a specific component within the project.
 type ComponentMetadata struct {
-	Type     string `yaml:"type"`
-	Language string `yaml:"language"`
-	Path     string `yaml:"path"`
+	Type     string   `yaml:"type"`
+	Language string   `yaml:"language"`
+	Path     string   `yaml:"path"`
+	Owners   []string `yaml:"owners"`
 }
 
 // ProjectMetadata defines the structure of the project.yaml file.
@@ -20,7 +21,9 @@ type ProjectMetadata struct {
 	Description string                       `yaml:"description"`
 	Version     string                       `yaml:"version"`
 	Tags        []string                     `yaml:"tags"`
+	Owners      []string                     `yaml:"owners"`
 	Components  map[string]ComponentMetadata `yaml:"components"`
+	OpenAPI     string                       `yaml:"openapi"` // Spec path relative to the source tree, or the URL serving it
 }
 
 // LoadProjectMetadata reads and parses the project.yaml file from the given path.
@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"strings"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// SymbolMetrics holds size and complexity measurements for a function or method.
+type SymbolMetrics struct {
+	LOC        int // Non-blank source lines
+	Complexity int // McCabe cyclomatic complexity (1 + decision points)
+	Params     int // Declared parameter count
+}
+
+// branchNodes are tree-sitter node kinds that add a decision point, across Go, Python and JS/TS.
+var branchNodes = map[string]bool{
+	// Go
+	"if_statement":       true,
+	"for_statement":      true,
+	"expression_case":    true,
+	"type_case":          true,
+	"communication_case": true,
+	// Python
+	"elif_clause":            true,
+	"while_statement":        true,
+	"except_clause":          true,
+	"conditional_expression": true,
+	"boolean_operator":       true,
+	"case_clause":            true,
+	"if_clause":              true,
+	// JS/TS
+	"for_in_statement":   true,
+	"do_statement":       true,
+	"switch_case":        true,
+	"catch_clause":       true,
+	"ternary_expression": true,
+}
+
+// computeMetrics measures the function rooted at n.
+func computeMetrics(n *sitter.Node, content []byte) SymbolMetrics {
+	if n == nil {
+		return SymbolMetrics{}
+	}
+	return SymbolMetrics{
+		LOC:        countLOC(n.Utf8Text(content)),
+		Complexity: 1 + countBranches(n, content),
+		Params:     countParams(n),
+	}
+}
+
+func countLOC(text string) int {
+	loc := 0
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			loc++
+		}
+	}
+	return loc
+}
+
+func countBranches(n *sitter.Node, content []byte) int {
+	count := 0
+	if branchNodes[n.Kind()] {
+		count++
+	} else if n.Kind() == "binary_expression" {
+		if op := n.ChildByFieldName("operator"); op != nil {
+			switch op.Utf8Text(content) {
+			case "&&", "||", "??":
+				count++
+			}
+		}
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if child := n.Child(i); child != nil {
+			count += countBranches(child, content)
+		}
+	}
+	return count
+}
+
+// countParams counts declared parameters. JS arrow functions assigned to variables
+// keep their parameters on the nested function node, so one level of declarators is searched.
+func countParams(n *sitter.Node) int {
+	params := n.ChildByFieldName("parameters")
+	if params == nil {
+		for i := uint(0); i < n.NamedChildCount() && params == nil; i++ {
+			decl := n.NamedChild(i)
+			if decl == nil || decl.Kind() != "variable_declarator" {
+				continue
+			}
+			if val := decl.ChildByFieldName("value"); val != nil {
+				if single := val.ChildByFieldName("parameter"); single != nil {
+					return 1
+				}
+				params = val.ChildByFieldName("parameters")
+			}
+		}
+	}
+	if params == nil {
+		return 0
+	}
+
+	count := 0
+	for i := uint(0); i < params.NamedChildCount(); i++ {
+		p := params.NamedChild(i)
+		if p == nil || p.Kind() == "comment" {
+			continue
+		}
+		// Go groups names sharing a type: (a, b int) is one parameter_declaration
+		if p.Kind() == "parameter_declaration" || p.Kind() == "variadic_parameter_declaration" {
+			names := 0
+			for j := uint(0); j < p.NamedChildCount(); j++ {
+				if c := p.NamedChild(j); c != nil && c.Kind() == "identifier" {
+					names++
+				}
+			}
+			if names == 0 {
+				names = 1
+			}
+			count += names
+			continue
+		}
+		count++
+	}
+	return count
+}
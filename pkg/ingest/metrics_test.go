@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+)
+
+func TestComplexityMetricsGo(t *testing.T) {
+	src := []byte(`package demo
+
+func Classify(a, b int, name string) string {
+	if a > 0 && b > 0 {
+		return "both"
+	}
+	for i := 0; i < a; i++ {
+		switch {
+		case i == 1:
+			return "one"
+		case i == 2:
+			return "two"
+		}
+	}
+	return name
+}
+
+func Simple() {}
+`)
+
+	e := NewTreeSitterExtractor()
+	bundle, err := e.Extract(context.Background(), "demo/classify.go", src)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	got := make(map[string]map[string]any)
+	for _, f := range bundle.Facts {
+		switch f.Predicate {
+		case config.PredicateLOC, config.PredicateComplexity, config.PredicateParamCount:
+			if got[f.Subject] == nil {
+				got[f.Subject] = make(map[string]any)
+			}
+			got[f.Subject][f.Predicate] = f.Object
+		}
+	}
+
+	classify := got["demo/classify.go:Classify"]
+	if classify == nil {
+		t.Fatalf("no metrics for Classify, got %v", got)
+	}
+	// 1 + if + && + for + 2 cases
+	if classify[config.PredicateComplexity] != 6 {
+		t.Errorf("complexity = %v, want 6", classify[config.PredicateComplexity])
+	}
+	if classify[config.PredicateParamCount] != 3 {
+		t.Errorf("params = %v, want 3", classify[config.PredicateParamCount])
+	}
+	if classify[config.PredicateLOC] != 14 {
+		t.Errorf("loc = %v, want 14", classify[config.PredicateLOC])
+	}
+
+	simple := got["demo/classify.go:Simple"]
+	if simple == nil || simple[config.PredicateComplexity] != 1 || simple[config.PredicateParamCount] != 0 {
+		t.Errorf("unexpected metrics for Simple: %v", simple)
+	}
+
+	// Metadata becomes facts too; the metrics are only written as the facts above
+	for _, doc := range bundle.Documents {
+		for _, key := range []string{"loc", "complexity", "params"} {
+			if _, ok := doc.Metadata[key]; ok {
+				t.Errorf("document %s carries %q metadata", doc.ID, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIFileNames are the spec files looked for in a source tree when
+// project.yaml does not name one.
+var openAPIFileNames = map[string]bool{
+	"openapi.yaml": true, "openapi.yml": true, "openapi.json": true,
+	"swagger.yaml": true, "swagger.yml": true, "swagger.json": true,
+}
+
+// apiOperation is an operation declared by an OpenAPI spec. Path is the full
+// path template, with the server's base path, e.g. "/v1/projects/{id}".
+type apiOperation struct {
+	Method      string
+	Path        string
+	OperationID string
+}
+
+// apiSpec is the set of operations declared by a project's OpenAPI specs.
+type apiSpec struct {
+	Operations []apiOperation
+	paths      []apiPath // Distinct operation paths, sorted
+}
+
+// apiPath is a path template of a spec and how many of its leading segments
+// are the server's base path.
+type apiPath struct {
+	Path string
+	Base int
+}
+
+// openAPIDocument is the part of an OpenAPI 3 or Swagger 2 document read.
+type openAPIDocument struct {
+	OpenAPI  string `yaml:"openapi"`
+	Swagger  string `yaml:"swagger"`
+	BasePath string `yaml:"basePath"`
+	Servers  []struct {
+		URL string `yaml:"url"`
+	} `yaml:"servers"`
+	Paths map[string]map[string]yaml.Node `yaml:"paths"`
+}
+
+// loadAPISpec returns the OpenAPI spec of a source tree: the one project.yaml
+// names under "openapi", a path or the URL of a running service, or else the
+// openapi and swagger files found in the tree. It returns nil when there is
+// none.
+func loadAPISpec(ctx context.Context, sourceDir string) (*apiSpec, error) {
+	if sourceDir == "" {
+		return nil, nil
+	}
+	spec := &apiSpec{}
+	if meta, err := LoadProjectMetadata(filepath.Join(sourceDir, "project.yaml")); err == nil && meta.OpenAPI != "" {
+		data, err := readAPISpec(ctx, sourceDir, meta.OpenAPI)
+		if err != nil {
+			return nil, err
+		}
+		if err := spec.parse(data); err != nil {
+			return nil, fmt.Errorf("openapi spec %s: %w", meta.OpenAPI, err)
+		}
+	} else {
+		err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == "node_modules" || d.Name() == ".git" || d.Name() == "dist" || d.Name() == "build" || d.Name() == ".next" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !openAPIFileNames[strings.ToLower(d.Name())] {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := spec.parse(data); err != nil {
+				logger.Warn("Skipping unreadable OpenAPI spec", "path", path, "error", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(spec.Operations) == 0 {
+		return nil, nil
+	}
+	return spec, nil
+}
+
+// readAPISpec reads a spec from a URL, or a path relative to sourceDir.
+func readAPISpec(ctx context.Context, sourceDir, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		if !filepath.IsAbs(location) {
+			location = filepath.Join(sourceDir, location)
+		}
+		return os.ReadFile(location)
+	}
+	ctx, cancel := context.WithTimeout(ctx, config.OpenAPIFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching openapi spec: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching openapi spec: %s returned %s", location, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parse adds the operations of an OpenAPI 3 or Swagger 2 document, in YAML
+// or JSON.
+func (spec *apiSpec) parse(data []byte) error {
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.OpenAPI == "" && doc.Swagger == "" {
+		return fmt.Errorf("not an OpenAPI document")
+	}
+	base := doc.BasePath
+	if len(doc.Servers) > 0 {
+		base = serverBasePath(doc.Servers[0].URL)
+	}
+	baseSegments := 0
+	if trimmed := strings.Trim(base, "/"); trimmed != "" {
+		baseSegments = strings.Count(trimmed, "/") + 1
+	}
+	for path, item := range doc.Paths {
+		full := joinRoute(base, path)
+		spec.paths = append(spec.paths, apiPath{Path: full, Base: baseSegments})
+		for method, node := range item {
+			method = strings.ToUpper(method)
+			switch method {
+			case "GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "HEAD":
+			default:
+				continue // parameters, summary, ...
+			}
+			var op struct {
+				OperationID string `yaml:"operationId"`
+			}
+			_ = node.Decode(&op)
+			spec.Operations = append(spec.Operations, apiOperation{Method: method, Path: full, OperationID: op.OperationID})
+		}
+	}
+	sort.Slice(spec.Operations, func(i, j int) bool {
+		if spec.Operations[i].Path != spec.Operations[j].Path {
+			return spec.Operations[i].Path < spec.Operations[j].Path
+		}
+		return spec.Operations[i].Method < spec.Operations[j].Method
+	})
+	sort.Slice(spec.paths, func(i, j int) bool { return spec.paths[i].Path < spec.paths[j].Path })
+	return nil
+}
+
+// serverBasePath returns the path of an OpenAPI server URL, which may be
+// relative ("/v1") or templated ("{scheme}://api.example.com/v1").
+func serverBasePath(server string) string {
+	if i := strings.Index(server, "://"); i != -1 {
+		server = server[i+3:]
+		if j := strings.Index(server, "/"); j != -1 {
+			server = server[j:]
+		} else {
+			server = ""
+		}
+	}
+	if u, err := url.Parse(server); err == nil {
+		server = u.Path
+	}
+	return strings.TrimSuffix(server, "/")
+}
+
+// match returns the spec path a concrete or templated URL addresses. Spec
+// parameters ("{id}") match any segment, the URL's own parameters ("${id}" in
+// a template literal, ":id", "{id}" or "*" in a router path) only a spec
+// parameter. A URL missing the server's base path matches too, as clients
+// often prefix it from configuration, but ranks below one including it.
+// Otherwise the path with the most literal segments in common wins.
+func (spec *apiSpec) match(rawURL string) (string, bool) {
+	segments := urlSegments(rawURL)
+	if segments == nil {
+		return "", false
+	}
+	best, bestScore := "", -1
+	for _, path := range spec.paths {
+		tmpl := strings.Split(strings.Trim(path.Path, "/"), "/")
+		if score, ok := matchSegments(tmpl, segments); ok && 2*score > bestScore {
+			best, bestScore = path.Path, 2*score
+		} else if score, ok := matchSegments(tmpl[path.Base:], segments); ok && path.Base > 0 && 2*score-1 > bestScore {
+			best, bestScore = path.Path, 2*score-1
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// urlSegments splits the path of a URL referenced in code, marking its
+// parameters as "", or returns nil when it is not an absolute path.
+func urlSegments(rawURL string) []string {
+	if i := strings.Index(rawURL, "://"); i != -1 {
+		rest := rawURL[i+3:]
+		j := strings.Index(rest, "/")
+		if j == -1 {
+			return nil
+		}
+		rawURL = rest[j:]
+	}
+	if i := strings.IndexAny(rawURL, "?#"); i != -1 {
+		rawURL = rawURL[:i]
+	}
+	if !strings.HasPrefix(rawURL, "/") || strings.ContainsAny(rawURL, " \t") {
+		return nil
+	}
+	segments := strings.Split(strings.Trim(rawURL, "/"), "/")
+	for i, seg := range segments {
+		if strings.Contains(seg, "${") || strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") || (strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")) {
+			segments[i] = ""
+		}
+	}
+	return segments
+}
+
+// matchSegments reports whether URL segments fit a path template and how
+// many literal segments they share.
+func matchSegments(tmpl, segments []string) (int, bool) {
+	if len(tmpl) != len(segments) {
+		return 0, false
+	}
+	score := 0
+	for i, t := range tmpl {
+		param := strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}")
+		switch {
+		case param:
+		case t == segments[i]:
+			score++
+		default:
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+// linkAPICalls links frontend code to backend handlers through a spec: each
+// URL a frontend symbol references that matches a spec path yields a
+// calls_api fact to that path and a calls fact to its handler, the backend
+// route matching the same path. Spec paths get handled_by and has_operation
+// facts.
+func linkAPICalls(s *meb.MEBStore, spec *apiSpec, routeMap map[string]string, isFrontend func(string) bool) error {
+	var facts []meb.Fact
+	for _, op := range spec.Operations {
+		if op.OperationID != "" {
+			facts = append(facts, meb.Fact{Subject: op.Path, Predicate: config.PredicateHasOperation, Object: op.OperationID})
+		}
+	}
+
+	routes := make([]string, 0, len(routeMap))
+	for route := range routeMap {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	handlers := make(map[string]string)
+	for _, route := range routes {
+		path, ok := spec.match(route)
+		if !ok {
+			continue
+		}
+		if _, taken := handlers[path]; !taken {
+			handlers[path] = routeMap[route]
+			if path != route {
+				facts = append(facts, meb.Fact{Subject: path, Predicate: config.PredicateHandledBy, Object: routeMap[route]})
+			}
+		}
+	}
+
+	linked := 0
+	evidence := make(map[[2]string]int) // References of each frontend symbol to each spec path
+	for fact, err := range s.Scan("", config.PredicateReferences, "") {
+		if err != nil || fact.Subject == "" || !isFrontend(fact.Subject) {
+			continue
+		}
+		ref, ok := fact.Object.(string)
+		if !ok {
+			continue
+		}
+		path, ok := spec.match(ref)
+		if !ok {
+			continue
+		}
+		facts = append(facts, meb.Fact{Subject: fact.Subject, Predicate: config.PredicateCallsAPI, Object: path})
+		if handler, ok := handlers[path]; ok {
+			facts = append(facts, meb.Fact{Subject: fact.Subject, Predicate: config.PredicateCalls, Object: handler})
+		}
+		evidence[[2]string{fact.Subject, path}]++
+		linked++
+	}
+	logger.Info("Linked API calls through OpenAPI spec", "operations", len(spec.Operations), "handlers", len(handlers), "calls", linked)
+	if err := s.AddFactBatch(facts); err != nil {
+		return err
+	}
+
+	var links []gcamdb.LinkEvidence
+	for call, n := range evidence {
+		links = append(links, gcamdb.LinkEvidence{Subject: call[0], Predicate: config.PredicateCallsAPI, Object: call[1], Method: gcamdb.LinkMethodOpenAPI, Evidence: n})
+		if handler, ok := handlers[call[1]]; ok {
+			links = append(links, gcamdb.LinkEvidence{Subject: call[0], Predicate: config.PredicateCalls, Object: handler, Method: gcamdb.LinkMethodOpenAPI, Evidence: n})
+		}
+	}
+	return gcamdb.RecordLinkEvidence(s, links)
+}
@@ -0,0 +1,105 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+const testOpenAPISpec = `openapi: 3.0.0
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /projects:
+    get:
+      operationId: listProjects
+  /projects/{id}:
+    parameters:
+      - name: id
+        in: path
+    get:
+      operationId: getProject
+    delete:
+      operationId: deleteProject
+  /projects/search:
+    get:
+      operationId: searchProjects
+`
+
+func TestAPISpecMatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "api", "openapi.yaml"), []byte(testOpenAPISpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := loadAPISpec(context.Background(), dir)
+	if err != nil || spec == nil {
+		t.Fatalf("loadAPISpec() = %v, %v", spec, err)
+	}
+	if len(spec.Operations) != 4 {
+		t.Fatalf("got %d operations, want 4: %+v", len(spec.Operations), spec.Operations)
+	}
+
+	for url, want := range map[string]string{
+		"/v1/projects/${projectId}":                 "/v1/projects/{id}",
+		"/v1/projects/42?verbose=1":                 "/v1/projects/{id}",
+		"/v1/projects/search":                       "/v1/projects/search",
+		"/projects":                                 "/v1/projects",
+		"https://api.example.com/v1/projects/${id}": "/v1/projects/{id}",
+		"/v1/projects/:id":                          "/v1/projects/{id}",
+		"/v1/users":                                 "",
+		"/v1/projects/${id}/files":                  "",
+	} {
+		got, ok := spec.match(url)
+		if got != want || ok != (want != "") {
+			t.Errorf("match(%q) = %q, %v, want %q", url, got, ok, want)
+		}
+	}
+
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "web/api.ts:fetchProject", Predicate: config.PredicateReferences, Object: "/v1/projects/${id}"},
+		{Subject: "server/routes.go:setup", Predicate: config.PredicateReferences, Object: "/v1/projects/:id"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	routeMap := map[string]string{"/v1/projects/:id": "server/projects.go:getProject"}
+	isFrontend := func(id string) bool { return filepath.Dir(id) == "web" }
+	if err := linkAPICalls(s, spec, routeMap, isFrontend); err != nil {
+		t.Fatal(err)
+	}
+
+	has := func(sub, pred, obj string) bool {
+		for f, err := range s.Scan(sub, pred, obj) {
+			if err == nil && f.Subject != "" {
+				return true
+			}
+		}
+		return false
+	}
+	for _, f := range [][3]string{
+		{"web/api.ts:fetchProject", config.PredicateCallsAPI, "/v1/projects/{id}"},
+		{"web/api.ts:fetchProject", config.PredicateCalls, "server/projects.go:getProject"},
+		{"/v1/projects/{id}", config.PredicateHandledBy, "server/projects.go:getProject"},
+		{"/v1/projects/{id}", config.PredicateHasOperation, "getProject"},
+		{"/v1/projects/{id}", config.PredicateHasOperation, "deleteProject"},
+	} {
+		if !has(f[0], f[1], f[2]) {
+			t.Errorf("missing fact %v", f)
+		}
+	}
+	if has("server/routes.go:setup", config.PredicateCallsAPI, "") {
+		t.Error("a backend route string was linked as an API call")
+	}
+}
@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeOwnersLocations lists where GitHub/GitLab look for a CODEOWNERS file, in priority order.
+var codeOwnersLocations = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+}
+
+// OwnerRule is a single CODEOWNERS line: a path pattern and its owners.
+type OwnerRule struct {
+	Pattern string
+	Owners  []string
+	re      *regexp.Regexp
+}
+
+// OwnershipIndex resolves the owners of a file from CODEOWNERS rules and project.yaml metadata.
+// CODEOWNERS rules take precedence, followed by component owners and finally project-wide owners.
+type OwnershipIndex struct {
+	rules           []OwnerRule
+	componentOwners map[string][]string // component path -> owners
+	defaultOwners   []string
+}
+
+// NewOwnershipIndex builds an index from the CODEOWNERS file in sourceDir (if any)
+// and the owners declared in project metadata. It returns nil when no ownership
+// information is available so callers can skip owner facts entirely.
+func NewOwnershipIndex(sourceDir string, meta *ProjectMetadata) (*OwnershipIndex, error) {
+	idx := &OwnershipIndex{componentOwners: make(map[string][]string)}
+
+	for _, loc := range codeOwnersLocations {
+		data, err := os.ReadFile(filepath.Join(sourceDir, loc))
+		if err != nil {
+			continue
+		}
+		rules, err := ParseCodeOwners(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", loc, err)
+		}
+		idx.rules = rules
+		break
+	}
+
+	if meta != nil {
+		idx.defaultOwners = meta.Owners
+		for _, comp := range meta.Components {
+			if len(comp.Owners) > 0 && comp.Path != "" {
+				idx.componentOwners[filepath.ToSlash(comp.Path)] = comp.Owners
+			}
+		}
+	}
+
+	if len(idx.rules) == 0 && len(idx.componentOwners) == 0 && len(idx.defaultOwners) == 0 {
+		return nil, nil
+	}
+	return idx, nil
+}
+
+// ParseCodeOwners parses CODEOWNERS content into ordered rules.
+// Comments, blank lines and section headers ("[Section]") are skipped.
+func ParseCodeOwners(data []byte) ([]OwnerRule, error) {
+	var rules []OwnerRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+		if idx := strings.Index(line, " #"); idx != -1 {
+			line = strings.TrimSpace(line[:idx])
+		}
+
+		fields := strings.Fields(line)
+		pattern := fields[0]
+		re, err := compileOwnerPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, OwnerRule{
+			Pattern: pattern,
+			Owners:  fields[1:],
+			re:      re,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// OwnersFor returns the owners of a file path relative to the source root.
+// As in CODEOWNERS, the last matching rule wins; a matching rule with no owners
+// explicitly clears ownership.
+func (o *OwnershipIndex) OwnersFor(relPath string) []string {
+	if o == nil {
+		return nil
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	for i := len(o.rules) - 1; i >= 0; i-- {
+		if o.rules[i].re.MatchString(relPath) {
+			return o.rules[i].Owners
+		}
+	}
+
+	longest := ""
+	for compPath := range o.componentOwners {
+		if (relPath == compPath || strings.HasPrefix(relPath, compPath+"/")) && len(compPath) > len(longest) {
+			longest = compPath
+		}
+	}
+	if longest != "" {
+		return o.componentOwners[longest]
+	}
+
+	return o.defaultOwners
+}
+
+// compileOwnerPattern converts a gitignore-style CODEOWNERS pattern into a regular expression.
+func compileOwnerPattern(pattern string) (*regexp.Regexp, error) {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	trimmed := strings.Trim(pattern, "/")
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(trimmed, "/")
+
+	if trimmed == "" || trimmed == "*" || trimmed == "**" {
+		return regexp.Compile(`^.*$`)
+	}
+
+	var sb strings.Builder
+	if anchored {
+		sb.WriteString("^")
+	} else {
+		sb.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		switch {
+		case c == '*' && strings.HasPrefix(trimmed[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(trimmed[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if dirOnly {
+		sb.WriteString("/.*$")
+	} else {
+		sb.WriteString("(?:/.*)?$")
+	}
+
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,70 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnersFor(t *testing.T) {
+	rules, err := ParseCodeOwners([]byte(`
+# Default owners
+*                 @org/core
+
+[Backend]
+/pkg/             @org/backend
+*.ts              @org/frontend # inline comment
+docs/**/*.md      @org/docs
+/pkg/legacy/
+`))
+	if err != nil {
+		t.Fatalf("ParseCodeOwners failed: %v", err)
+	}
+	idx := &OwnershipIndex{rules: rules}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"main.go", []string{"@org/core"}},
+		{"pkg/ingest/ingest.go", []string{"@org/backend"}},
+		{"web/src/app.ts", []string{"@org/frontend"}},
+		{"docs/guide/intro.md", []string{"@org/docs"}},
+		{"docs/intro.md", []string{"@org/docs"}},
+		{"pkg/legacy/old.go", []string{}},
+		{"src/pkg/other.go", []string{"@org/core"}},
+	}
+	for _, tt := range tests {
+		got := idx.OwnersFor(tt.path)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OwnersFor(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestOwnersForMetadataFallback(t *testing.T) {
+	meta := &ProjectMetadata{
+		Owners: []string{"@org/everyone"},
+		Components: map[string]ComponentMetadata{
+			"api": {Type: "service", Path: "services/api", Owners: []string{"@org/api"}},
+		},
+	}
+	idx, err := NewOwnershipIndex(t.TempDir(), meta)
+	if err != nil {
+		t.Fatalf("NewOwnershipIndex failed: %v", err)
+	}
+
+	if got := idx.OwnersFor("services/api/handler.go"); !reflect.DeepEqual(got, []string{"@org/api"}) {
+		t.Errorf("component owners = %v", got)
+	}
+	if got := idx.OwnersFor("cmd/main.go"); !reflect.DeepEqual(got, []string{"@org/everyone"}) {
+		t.Errorf("default owners = %v", got)
+	}
+
+	var nilIdx *OwnershipIndex
+	if got := nilIdx.OwnersFor("cmd/main.go"); got != nil {
+		t.Errorf("nil index should return nil, got %v", got)
+	}
+}
@@ -0,0 +1,203 @@
+package ingest
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// parseWorkers returns the number of files parsed concurrently.
+func (o *IngestOptions) parseWorkers() int {
+	if o != nil && o.Workers > 0 {
+		return o.Workers
+	}
+	return min(runtime.NumCPU(), config.MaxWorkers)
+}
+
+// progress reports done of total files processed to the Progress callback, if
+// any.
+func (o *IngestOptions) progress(done, total int) {
+	if o != nil && o.Progress != nil {
+		o.Progress(done, total)
+	}
+}
+
+// jobBuffer returns the number of files queued ahead of the parse workers.
+func (o *IngestOptions) jobBuffer() int {
+	if o != nil && o.JobBuffer > 0 {
+		return o.JobBuffer
+	}
+	return config.IngestJobBuffer
+}
+
+// embedWorkers returns the number of concurrent embedding requests.
+func (o *IngestOptions) embedWorkers() int {
+	if o != nil && o.EmbedWorkers > 0 {
+		return o.EmbedWorkers
+	}
+	return config.EmbeddingWorkers
+}
+
+// embedQueue returns the number of symbols queued for embedding.
+func (o *IngestOptions) embedQueue() int {
+	if o != nil && o.EmbedQueue > 0 {
+		return o.EmbedQueue
+	}
+	return config.EmbeddingQueueSize
+}
+
+// embedPool embeds symbols on a fixed set of workers fed by a bounded queue.
+// When embedding falls behind, enqueue blocks, so parse workers wait for it
+// instead of piling up work. Once ctx is done, queued symbols are dropped and
+// left for a resumed ingest. A nil pool embeds nothing.
+type embedPool struct {
+	ctx      context.Context
+	s        *meb.MEBStore
+	embedder embedder
+	journal  *ingestJournal
+	jobs     chan symbolEmbedTarget
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	sketches map[string]gcamdb.VectorSketch // Sketches of the vectors stored, by key
+}
+
+// embedder computes the vector of a text, as EmbeddingService does.
+type embedder interface {
+	GetEmbedding(ctx context.Context, text string) ([]float32, error)
+}
+
+// newEmbedPool starts the embedding workers, or returns nil without an embedding service.
+func newEmbedPool(ctx context.Context, s *meb.MEBStore, service *EmbeddingService, opts *IngestOptions, journal *ingestJournal) *embedPool {
+	if service == nil {
+		return nil
+	}
+	return startEmbedPool(ctx, s, service, opts, journal)
+}
+
+// startEmbedPool starts opts.embedWorkers() workers embedding with e. Each
+// embedded symbol is recorded in journal.
+func startEmbedPool(ctx context.Context, s *meb.MEBStore, e embedder, opts *IngestOptions, journal *ingestJournal) *embedPool {
+	p := &embedPool{
+		ctx:      ctx,
+		s:        s,
+		embedder: e,
+		journal:  journal,
+		jobs:     make(chan symbolEmbedTarget, opts.embedQueue()),
+		sketches: make(map[string]gcamdb.VectorSketch),
+	}
+	for i := 0; i < opts.embedWorkers(); i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for target := range p.jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				p.embed(target)
+				if ctx.Err() == nil {
+					p.journal.embedded(target.file)
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// enqueue queues a symbol for embedding, blocking while the queue is full. It
+// gives up when ctx is done.
+func (p *embedPool) enqueue(ctx context.Context, target symbolEmbedTarget) {
+	if p == nil {
+		return
+	}
+	select {
+	case p.jobs <- target:
+	case <-ctx.Done():
+	}
+}
+
+// wait stops accepting symbols and returns once the queued ones are embedded.
+func (p *embedPool) wait() {
+	if p == nil {
+		return
+	}
+	logger.Info("Waiting for embeddings to complete")
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// embed computes and stores the vector of one symbol.
+func (p *embedPool) embed(target symbolEmbedTarget) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Panic in embedding worker", "symbol", target.symbolID, "panic", r)
+		}
+	}()
+
+	// Add a timeout to prevent hanging
+	ctx, cancel := context.WithTimeout(p.ctx, config.EmbeddingTimeout)
+	defer cancel()
+
+	logger.Debug("Generating embedding", "symbol", target.symbolID, "length", len(target.text))
+	embed, err := p.embedder.GetEmbedding(ctx, target.text)
+	if err != nil {
+		logger.Error("Error generating embedding", "symbol", target.symbolID, "error", err)
+		return
+	}
+	if len(embed) == 0 {
+		logger.Error("Empty embedding", "symbol", target.symbolID)
+		return
+	}
+
+	// Key the vector by the symbol's UID, so it follows the symbol when moved,
+	// else by its ID
+	var dictID uint64
+	if target.uid != "" {
+		if dictID, err = gcamdb.VectorID(p.s, target.uid); err != nil {
+			logger.Error("Error adding UID to dictionary, cannot store vector", "symbol", target.symbolID, "error", err)
+			return
+		}
+	} else {
+		var found bool
+		if dictID, found = p.s.LookupID(target.symbolID); !found {
+			logger.Error("ID not found in dictionary, cannot store vector", "symbol", target.symbolID)
+			return
+		}
+	}
+	if err := p.s.Vectors().Add(dictID, embed); err != nil {
+		logger.Error("Error adding vector to store", "symbol", target.symbolID, "error", err)
+	} else {
+		logger.Info("Successfully stored embedding", "symbol", target.symbolID, "dict_id", dictID)
+		// Sketch the vector, which the store cannot give back, for duplicate detection
+		key := target.uid
+		if key == "" {
+			key = target.symbolID
+		}
+		sketch := gcamdb.SketchVector(embed)
+		p.mu.Lock()
+		p.sketches[key] = sketch
+		p.mu.Unlock()
+	}
+	if target.docID == "" {
+		return
+	}
+	if docDictID, found := p.s.LookupID(target.docID); found {
+		if err := p.s.Vectors().Add(docDictID, embed); err != nil {
+			logger.Error("Error adding vector to store", "symbol", target.docID, "error", err)
+		}
+	}
+}
+
+// embedded returns the sketches of the vectors the pool stored, keyed as the
+// vectors are. Call it after wait.
+func (p *embedPool) embedded() map[string]gcamdb.VectorSketch {
+	if p == nil {
+		return nil
+	}
+	return p.sketches
+}
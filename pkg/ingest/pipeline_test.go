@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+// gatedEmbedder blocks every embedding until its gate is closed.
+type gatedEmbedder struct {
+	gate  chan struct{}
+	calls atomic.Int64
+}
+
+func (e *gatedEmbedder) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	<-e.gate
+	e.calls.Add(1)
+	return []float32{1, 0}, nil
+}
+
+func TestEmbedPoolBackpressure(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	e := &gatedEmbedder{gate: make(chan struct{})}
+	pool := startEmbedPool(context.Background(), s, e, &IngestOptions{EmbedWorkers: 1, EmbedQueue: 2}, nil)
+
+	// One symbol in the worker and two queued fill the pool; the fourth waits
+	enqueued := make(chan int, 4)
+	go func() {
+		for i := 0; i < 4; i++ {
+			pool.enqueue(context.Background(), symbolEmbedTarget{symbolID: "a.go:F", text: "doc"})
+			enqueued <- i
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-enqueued:
+		case <-time.After(time.Second):
+			t.Fatalf("enqueue %d blocked with room in the queue", i)
+		}
+	}
+	select {
+	case <-enqueued:
+		t.Fatal("enqueue did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(e.gate)
+	<-enqueued
+	pool.wait()
+	if got := e.calls.Load(); got != 4 {
+		t.Errorf("embedded %d symbols, want 4", got)
+	}
+
+	// A cancelled context stops a blocked enqueue
+	blocked := startEmbedPool(context.Background(), s, &gatedEmbedder{gate: make(chan struct{})}, &IngestOptions{EmbedWorkers: 1, EmbedQueue: 1}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			blocked.enqueue(ctx, symbolEmbedTarget{symbolID: "a.go:G", text: "doc"})
+		}
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("enqueue ignored the cancelled context")
+	}
+}
+
+func TestIngestOptionsDefaults(t *testing.T) {
+	var nilOpts *IngestOptions
+	if nilOpts.parseWorkers() < 1 || nilOpts.embedWorkers() < 1 || nilOpts.embedQueue() < 1 || nilOpts.jobBuffer() < 1 {
+		t.Error("nil options give an empty pipeline")
+	}
+	opts := &IngestOptions{Workers: 3, JobBuffer: 4, EmbedWorkers: 5, EmbedQueue: 6}
+	if opts.parseWorkers() != 3 || opts.jobBuffer() != 4 || opts.embedWorkers() != 5 || opts.embedQueue() != 6 {
+		t.Error("options not applied")
+	}
+}
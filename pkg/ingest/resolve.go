@@ -261,6 +261,18 @@ func (sr *SymbolResolver) BuildCallGraph(store *meb.MEBStore) (*CallGraph, error
 		cg.CalledBy[callee] = append(cg.CalledBy[callee], caller)
 	}
 
+	// Implementations a call through an interface may reach, so that callers
+	// and callees follow dynamic dispatch
+	for fact, err := range store.ScanContext(ctx, "", config.PredicateMayCall, "") {
+		if err != nil || fact.Subject == "" {
+			continue
+		}
+		if callee, ok := fact.Object.(string); ok && callee != "" {
+			cg.Calls[fact.Subject] = append(cg.Calls[fact.Subject], callee)
+			cg.CalledBy[callee] = append(cg.CalledBy[callee], fact.Subject)
+		}
+	}
+
 	return cg, nil
 }
 
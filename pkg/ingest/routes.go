@@ -0,0 +1,225 @@
+package ingest
+
+import (
+	"strconv"
+	"strings"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+	golang "github.com/tree-sitter/tree-sitter-go/bindings/go"
+)
+
+// goRoute is an HTTP route registered in Go source with gin, echo, chi or
+// net/http: its method, full path and handler candidates, the innermost
+// wrapped handler first and the wrappers after it.
+type goRoute struct {
+	Method   string
+	Path     string
+	Handlers []string
+}
+
+// routeMethods maps the router methods registering a handler to the HTTP
+// method they serve; "ANY" for every method.
+var routeMethods = map[string]string{
+	"GET": "GET", "POST": "POST", "PUT": "PUT", "DELETE": "DELETE", "PATCH": "PATCH", "OPTIONS": "OPTIONS", "HEAD": "HEAD", "Any": "ANY",
+	"Get": "GET", "Post": "POST", "Put": "PUT", "Delete": "DELETE", "Patch": "PATCH", "Options": "OPTIONS", "Head": "HEAD",
+	"Handle": "ANY", "HandleFunc": "ANY",
+}
+
+// extractGoRoutes returns the routes registered in a Go file. Path prefixes
+// are followed through router groups assigned to a variable (gin and echo
+// Group, chi With), chi Route and Group blocks, and method chains; a prefix
+// passed to another function is not.
+func extractGoRoutes(content []byte) []goRoute {
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(sitter.NewLanguage(golang.Language()))
+	tree := parser.Parse(content, nil)
+	if tree == nil {
+		return nil
+	}
+	defer tree.Close()
+
+	var routes []goRoute
+	var walk func(n *sitter.Node, prefixes map[string]string)
+	walk = func(n *sitter.Node, prefixes map[string]string) {
+		switch n.Kind() {
+		case "function_declaration", "method_declaration":
+			prefixes = make(map[string]string)
+		case "short_var_declaration", "assignment_statement":
+			left, right := n.ChildByFieldName("left"), n.ChildByFieldName("right")
+			if left != nil && right != nil && left.NamedChildCount() == right.NamedChildCount() {
+				for i := uint(0); i < left.NamedChildCount(); i++ {
+					if prefix, ok := routerPrefix(right.NamedChild(i), content, prefixes); ok {
+						prefixes[left.NamedChild(i).Utf8Text(content)] = prefix
+					}
+				}
+			}
+		case "call_expression":
+			fn, args := n.ChildByFieldName("function"), n.ChildByFieldName("arguments")
+			if fn == nil || args == nil || fn.Kind() != "selector_expression" {
+				break
+			}
+			operand, field := fn.ChildByFieldName("operand"), fn.ChildByFieldName("field")
+			if operand == nil || field == nil {
+				break
+			}
+			prefix := operandPrefix(operand, content, prefixes)
+			list := namedArgs(args)
+			switch name := field.Utf8Text(content); name {
+			case "Route", "Group":
+				// chi: r.Route("/v1", func(r chi.Router) {...}) and r.Group(func(r chi.Router) {...})
+				if len(list) == 0 || list[len(list)-1].Kind() != "func_literal" {
+					break
+				}
+				inner := prefix
+				if name == "Route" {
+					p, ok := stringLiteral(list[0], content)
+					if !ok {
+						break
+					}
+					inner = joinRoute(prefix, p)
+				}
+				scoped := make(map[string]string, len(prefixes)+1)
+				for k, v := range prefixes {
+					scoped[k] = v
+				}
+				if param := firstParamName(list[len(list)-1], content); param != "" {
+					scoped[param] = inner
+				}
+				walk(list[len(list)-1], scoped)
+				return
+			case "Method":
+				// chi: r.Method("GET", "/path", handler)
+				if len(list) >= 3 {
+					method, ok1 := stringLiteral(list[0], content)
+					p, ok2 := stringLiteral(list[1], content)
+					if ok1 && ok2 {
+						routes = append(routes, goRoute{Method: strings.ToUpper(method), Path: joinRoute(prefix, p), Handlers: handlerCandidates(list[len(list)-1], content)})
+					}
+				}
+			default:
+				method, ok := routeMethods[name]
+				if !ok || len(list) < 2 {
+					break
+				}
+				if p, ok := stringLiteral(list[0], content); ok {
+					// gin and echo take middleware before the handler: the handler is last
+					routes = append(routes, goRoute{Method: method, Path: joinRoute(prefix, p), Handlers: handlerCandidates(list[len(list)-1], content)})
+				}
+			}
+		}
+		for i := uint(0); i < n.ChildCount(); i++ {
+			walk(n.Child(i), prefixes)
+		}
+	}
+	walk(tree.RootNode(), make(map[string]string))
+	return routes
+}
+
+// routerPrefix returns the path prefix of a router an expression yields: a
+// group of a known router, or a router derived from one by a method chain.
+func routerPrefix(n *sitter.Node, content []byte, prefixes map[string]string) (string, bool) {
+	if n.Kind() != "call_expression" {
+		return "", false
+	}
+	fn, args := n.ChildByFieldName("function"), n.ChildByFieldName("arguments")
+	if fn == nil || args == nil || fn.Kind() != "selector_expression" {
+		return "", false
+	}
+	operand, field := fn.ChildByFieldName("operand"), fn.ChildByFieldName("field")
+	if operand == nil || field == nil {
+		return "", false
+	}
+	switch field.Utf8Text(content) {
+	case "Group":
+		if list := namedArgs(args); len(list) > 0 {
+			if p, ok := stringLiteral(list[0], content); ok {
+				return joinRoute(operandPrefix(operand, content, prefixes), p), true
+			}
+		}
+	case "With", "Use":
+		return operandPrefix(operand, content, prefixes), true
+	}
+	return "", false
+}
+
+// operandPrefix returns the path prefix of the router a call is made on.
+func operandPrefix(n *sitter.Node, content []byte, prefixes map[string]string) string {
+	if n.Kind() == "call_expression" {
+		if prefix, ok := routerPrefix(n, content, prefixes); ok {
+			return prefix
+		}
+		return ""
+	}
+	return prefixes[n.Utf8Text(content)]
+}
+
+// handlerCandidates unwraps a handler argument such as auth(gin.WrapF(h)):
+// the arguments of each wrapper call come first, innermost first, then the
+// wrapper itself, which may be a factory returning the handler.
+func handlerCandidates(n *sitter.Node, content []byte) []string {
+	switch n.Kind() {
+	case "identifier", "selector_expression":
+		return []string{clean(n.Utf8Text(content))}
+	case "call_expression":
+		var candidates []string
+		if args := n.ChildByFieldName("arguments"); args != nil {
+			list := namedArgs(args)
+			for i := len(list) - 1; i >= 0; i-- {
+				candidates = append(candidates, handlerCandidates(list[i], content)...)
+			}
+		}
+		if fn := n.ChildByFieldName("function"); fn != nil && (fn.Kind() == "identifier" || fn.Kind() == "selector_expression") {
+			candidates = append(candidates, clean(fn.Utf8Text(content)))
+		}
+		return candidates
+	}
+	return nil
+}
+
+// namedArgs returns the arguments of an argument list.
+func namedArgs(args *sitter.Node) []*sitter.Node {
+	list := make([]*sitter.Node, 0, args.NamedChildCount())
+	for i := uint(0); i < args.NamedChildCount(); i++ {
+		if c := args.NamedChild(i); c.Kind() != "comment" {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// firstParamName returns the name of a function literal's first parameter.
+func firstParamName(fn *sitter.Node, content []byte) string {
+	params := fn.ChildByFieldName("parameters")
+	if params == nil || params.NamedChildCount() == 0 {
+		return ""
+	}
+	if name := params.NamedChild(0).ChildByFieldName("name"); name != nil {
+		return name.Utf8Text(content)
+	}
+	return ""
+}
+
+// stringLiteral returns the value of a Go string literal.
+func stringLiteral(n *sitter.Node, content []byte) (string, bool) {
+	switch n.Kind() {
+	case "interpreted_string_literal":
+		s, err := strconv.Unquote(n.Utf8Text(content))
+		return s, err == nil
+	case "raw_string_literal":
+		return strings.Trim(n.Utf8Text(content), "`"), true
+	}
+	return "", false
+}
+
+// joinRoute appends a route path to a group prefix.
+func joinRoute(prefix, path string) string {
+	joined := strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+	if path == "" || path == "/" {
+		joined = strings.TrimSuffix(joined, "/")
+	}
+	if joined == "" {
+		return "/"
+	}
+	return joined
+}
@@ -0,0 +1,63 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractGoRoutes(t *testing.T) {
+	src := `package api
+
+func (s *Server) routes() {
+	r := gin.New()
+	r.GET("/health", s.handleHealth)
+	v1 := r.Group("/v1")
+	v1.POST("/users", authRequired(), s.handleCreateUser)
+	admin := v1.Group("admin")
+	admin.DELETE("/users/:id", gin.WrapF(s.handleDeleteUser))
+	r.Group("/v2").GET("/users", listUsers)
+}
+
+func echoRoutes(e *echo.Echo) {
+	g := e.Group("/api")
+	g.GET("/items", echo.WrapHandler(http.HandlerFunc(listItems)))
+}
+
+func chiRoutes(r chi.Router) {
+	r.Route("/v1", func(r chi.Router) {
+		r.With(logging).Get("/orders", orders)
+		r.Group(func(r chi.Router) {
+			r.Post("/orders", auth(createOrder))
+		})
+		r.Method("PUT", "/orders/{id}", newOrderHandler(db))
+	})
+	r.Get("/", index)
+}
+`
+	got := extractGoRoutes([]byte(src))
+	want := []goRoute{
+		{Method: "GET", Path: "/health", Handlers: []string{"s.handleHealth"}},
+		{Method: "POST", Path: "/v1/users", Handlers: []string{"s.handleCreateUser"}},
+		{Method: "DELETE", Path: "/v1/admin/users/:id", Handlers: []string{"s.handleDeleteUser", "gin.WrapF"}},
+		{Method: "GET", Path: "/v2/users", Handlers: []string{"listUsers"}},
+		{Method: "GET", Path: "/api/items", Handlers: []string{"listItems", "http.HandlerFunc", "echo.WrapHandler"}},
+		{Method: "GET", Path: "/v1/orders", Handlers: []string{"orders"}},
+		{Method: "POST", Path: "/v1/orders", Handlers: []string{"createOrder", "auth"}},
+		{Method: "PUT", Path: "/v1/orders/{id}", Handlers: []string{"db", "newOrderHandler"}},
+		{Method: "GET", Path: "/", Handlers: []string{"index"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractGoRoutes() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	lookup := map[string][]string{
+		"handleDeleteUser": {"api/server.go:Server.handleDeleteUser"},
+		"newOrderHandler":  {"orders/http.go:newOrderHandler", "api/orders.go:newOrderHandler"},
+	}
+	if id := resolveRouteHandler(want[2].Handlers, lookup, "api"); id != "api/server.go:Server.handleDeleteUser" {
+		t.Errorf("wrapped handler resolved to %q", id)
+	}
+	if id := resolveRouteHandler(want[7].Handlers, lookup, "api"); id != "api/orders.go:newOrderHandler" {
+		t.Errorf("handler factory resolved to %q, want the one in the route's directory", id)
+	}
+}
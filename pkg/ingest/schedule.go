@@ -0,0 +1,352 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/jobs"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+	"gopkg.in/yaml.v3"
+)
+
+// jobProjectPattern keeps scheduled project names usable as a store folder.
+var jobProjectPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,254}$`)
+
+// Errors of Scheduler.Start.
+var (
+	ErrNoJob      = errors.New("no scheduled job for the project")
+	ErrJobRunning = errors.New("the project's scheduled ingest is already running")
+)
+
+// Job re-ingests a project's source folder on a cron schedule.
+type Job struct {
+	Project  string `yaml:"project" json:"project"`
+	Source   string `yaml:"source" json:"source"`
+	Schedule string `yaml:"schedule" json:"schedule"`                     // Cron expression, see CronSchedule
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"` // IANA zone the schedule is read in (default: the server's)
+	Full     bool   `yaml:"full,omitempty" json:"full"`                   // Re-ingest every file rather than the changed ones
+	Pull     bool   `yaml:"pull,omitempty" json:"pull"`                   // Run "git pull --ff-only" in the source folder first
+}
+
+// JobsFile is the layout of the file given to serve --schedule.
+type JobsFile struct {
+	Jobs []Job `yaml:"jobs"`
+}
+
+// LoadJobs reads and validates a jobs file. Relative source folders are
+// resolved against the file's directory.
+func LoadJobs(path string) ([]Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jobs file: %w", err)
+	}
+	var file JobsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse jobs file %s: %w", path, err)
+	}
+	if len(file.Jobs) == 0 {
+		return nil, fmt.Errorf("jobs file %s lists no jobs", path)
+	}
+	projects := make(map[string]bool, len(file.Jobs))
+	for i := range file.Jobs {
+		j := &file.Jobs[i]
+		if !jobProjectPattern.MatchString(j.Project) {
+			return nil, fmt.Errorf("job %d: invalid project %q", i, j.Project)
+		}
+		if projects[j.Project] {
+			return nil, fmt.Errorf("project %s is scheduled twice", j.Project)
+		}
+		projects[j.Project] = true
+		if j.Source == "" {
+			return nil, fmt.Errorf("job %s: source is required", j.Project)
+		}
+		if !filepath.IsAbs(j.Source) {
+			j.Source = filepath.Join(filepath.Dir(path), j.Source)
+		}
+		j.Source = filepath.Clean(j.Source)
+		if _, err := ParseCron(j.Schedule); err != nil {
+			return nil, fmt.Errorf("job %s: %w", j.Project, err)
+		}
+		if _, err := time.LoadLocation(j.Timezone); err != nil {
+			return nil, fmt.Errorf("job %s: unknown timezone %q", j.Project, j.Timezone)
+		}
+	}
+	return file.Jobs, nil
+}
+
+// JobStatus reports a scheduled job's runs.
+type JobStatus struct {
+	Job
+	Running   bool      `json:"running"`
+	NextRun   time.Time `json:"next_run,omitzero"`
+	LastRun   time.Time `json:"last_run,omitzero"`
+	LastTook  string    `json:"last_took,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	Runs      int       `json:"runs"`
+	Skipped   int       `json:"skipped"`          // Runs due while the previous one was still going
+	JobID     string    `json:"job_id,omitempty"` // Of the last run, when runs are tracked as jobs
+}
+
+type scheduledJob struct {
+	Job
+	cron *CronSchedule
+	loc  *time.Location
+
+	mu     sync.Mutex // Held by a running ingest, so runs never overlap
+	status JobStatus  // Guarded by the scheduler's statMu
+}
+
+// Scheduler re-ingests projects on cron schedules. Runs are incremental
+// unless a job asks for a full ingest; a run due while the job's previous run
+// is still going is skipped.
+type Scheduler struct {
+	open    func(project string) (*meb.MEBStore, func(), error)
+	opts    *IngestOptions
+	jobs    []*scheduledJob
+	onSync  func(SyncReport)
+	tracker *jobs.Manager // nil unless runs are tracked as jobs
+	now     func() time.Time
+	wg      sync.WaitGroup // Runs in progress
+
+	statMu sync.Mutex      // Guards ctx and the jobs' status
+	ctx    context.Context // Run's, which runs are interrupted by
+}
+
+// NewScheduler creates a scheduler for jobs, validated by LoadJobs, ingesting
+// into the stores returned by open. The store is fetched again for every run
+// and released when the run is done, so a server may close and reopen it in
+// between, but not during one.
+func NewScheduler(open func(project string) (*meb.MEBStore, func(), error), jobs []Job, opts *IngestOptions) *Scheduler {
+	s := &Scheduler{open: open, opts: opts, now: time.Now, ctx: context.Background()}
+	for _, j := range jobs {
+		cron, err := ParseCron(j.Schedule)
+		if err != nil {
+			logger.Warn("Skipping job with invalid schedule", "project", j.Project, "error", err)
+			continue
+		}
+		loc, err := time.LoadLocation(j.Timezone)
+		if err != nil {
+			loc = time.Local
+		}
+		s.jobs = append(s.jobs, &scheduledJob{Job: j, cron: cron, loc: loc, status: JobStatus{Job: j}})
+	}
+	return s
+}
+
+// OnSync makes the scheduler call fn after every run, successful or not, from
+// the goroutine running it. Call it before Run.
+func (s *Scheduler) OnSync(fn func(SyncReport)) {
+	s.onSync = fn
+}
+
+// TrackJobs makes the scheduler run each ingest as an "ingest" job of m,
+// which reports the files processed and can be cancelled. Full ingests stop
+// when cancelled and resume at their next run; incremental ones finish once
+// started. Call it before Run.
+func (s *Scheduler) TrackJobs(m *jobs.Manager) {
+	s.tracker = m
+}
+
+// Run runs the jobs on their schedules until ctx is done, then waits for the
+// runs in progress and returns ctx's error. Full ingests are interrupted by
+// ctx and resume at their next run.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.statMu.Lock()
+	s.ctx = ctx
+	s.statMu.Unlock()
+	var loops sync.WaitGroup
+	for _, j := range s.jobs {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.loop(ctx, j)
+		}()
+	}
+	loops.Wait()
+	s.wg.Wait()
+	return ctx.Err()
+}
+
+// loop waits for each of a job's runs and starts it, unless the previous run
+// still holds the job.
+func (s *Scheduler) loop(ctx context.Context, j *scheduledJob) {
+	for {
+		next := j.cron.Next(s.now().In(j.loc))
+		s.statMu.Lock()
+		j.status.NextRun = next
+		s.statMu.Unlock()
+		if next.IsZero() {
+			logger.Warn("Scheduled job never runs", "project", j.Project, "schedule", j.Schedule)
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if !s.start(j) {
+			s.statMu.Lock()
+			j.status.Skipped++
+			s.statMu.Unlock()
+			logger.Warn("Scheduled ingest still running, skipping this run", "project", j.Project)
+		}
+	}
+}
+
+// Start runs project's job now, in the background, unless it is already
+// running.
+func (s *Scheduler) Start(project string) error {
+	for _, j := range s.jobs {
+		if j.Project == project {
+			if !s.start(j) {
+				return fmt.Errorf("%w: %s", ErrJobRunning, project)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNoJob, project)
+}
+
+// start runs j in the background, unless its previous run still holds it.
+func (s *Scheduler) start(j *scheduledJob) bool {
+	if !j.mu.TryLock() {
+		return false
+	}
+	s.statMu.Lock()
+	ctx := s.ctx
+	s.statMu.Unlock()
+	s.wg.Add(1)
+	if s.tracker == nil {
+		go func() {
+			defer s.wg.Done()
+			defer j.mu.Unlock()
+			s.run(ctx, j, nil)
+		}()
+		return true
+	}
+	// Set the job's ID before it can run, so that its status never names the
+	// previous run's job while this one is running
+	s.statMu.Lock()
+	defer s.statMu.Unlock()
+	job := s.tracker.Start(ctx, "ingest", j.Project, func(ctx context.Context, run *jobs.Run) (any, error) {
+		defer s.wg.Done()
+		defer j.mu.Unlock()
+		report := s.run(ctx, j, run)
+		return map[string]any{"facts": report.Facts, "vectors": report.VectorsAfter}, report.Err
+	})
+	j.status.JobID = job.ID
+	return true
+}
+
+// run ingests a job's project, reporting to job unless it is nil. Callers
+// hold j.mu.
+func (s *Scheduler) run(ctx context.Context, j *scheduledJob, job *jobs.Run) SyncReport {
+	s.statMu.Lock()
+	j.status.Running = true
+	s.statMu.Unlock()
+
+	logf := func(format string, args ...any) {
+		if job != nil {
+			job.Logf(format, args...)
+		}
+	}
+	opts := s.opts
+	if job != nil {
+		opts = &IngestOptions{}
+		if s.opts != nil {
+			*opts = *s.opts
+		}
+		opts.Progress = func(done, total int) {
+			job.Progress("files", done, total)
+		}
+	}
+
+	began := time.Now()
+	report := SyncReport{Project: j.Project, Trigger: TriggerSchedule}
+	var err error
+	if j.Pull {
+		logf("Pulling %s", j.Source)
+		err = gitPull(ctx, j.Source)
+	}
+	var store *meb.MEBStore
+	var release func()
+	if err == nil {
+		store, release, err = s.open(j.Project)
+	}
+	if err == nil {
+		defer release()
+		report.VectorsBefore = store.Vectors().Count()
+		if j.Full {
+			logf("Ingesting every file of %s", j.Source)
+			err = RunContext(ctx, store, j.Project, j.Source, NewIngestState(), opts)
+		} else if err = ctx.Err(); err == nil {
+			logf("Ingesting the files of %s changed since the last run", j.Source)
+			err = RunIncrementalWithOptions(store, j.Project, j.Source, NewIngestState(), opts)
+		}
+		report.VectorsAfter = store.Vectors().Count()
+	}
+	if err == nil {
+		// The run recounted the facts as it finished
+		report.Facts = store.Count()
+	}
+	report.Duration = time.Since(began)
+	report.Err = err
+
+	s.statMu.Lock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = began.UTC()
+	j.status.LastTook = report.Duration.Round(time.Millisecond).String()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+	s.statMu.Unlock()
+
+	if err != nil {
+		logger.Warn("Scheduled ingest failed", "project", j.Project, "error", err)
+	} else {
+		logger.Info("Scheduled ingest completed", "project", j.Project, "took", report.Duration)
+		logf("Ingested %d facts in %s", report.Facts, report.Duration.Round(time.Millisecond))
+	}
+	if s.onSync != nil {
+		s.onSync(report)
+	}
+	return report
+}
+
+// Status reports every job, ordered by project.
+func (s *Scheduler) Status() []JobStatus {
+	s.statMu.Lock()
+	defer s.statMu.Unlock()
+	out := make([]JobStatus, len(s.jobs))
+	for i, j := range s.jobs {
+		out[i] = j.status
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Project < out[j].Project })
+	return out
+}
+
+// gitPull fast-forwards the checkout in dir.
+func gitPull(ctx context.Context, dir string) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "pull", "--ff-only", "--quiet")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git pull in %s: %v: %s", dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/jobs"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC) // A Wednesday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 15 * fri", time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)}, // Either day field matches
+		{"5,10 10 * * *", time.Date(2024, 1, 31, 10, 10, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", time.Date(2024, 1, 31, 10, 9, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := ParseCron(c.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", c.expr, err)
+			continue
+		}
+		assert.Equal(t, c.want, s.Next(from), c.expr)
+	}
+
+	never, err := ParseCron("0 0 30 feb *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, never.Next(from).IsZero(), "February 30th never comes")
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@every 10ms", "@often"} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestLoadJobs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		t.Helper()
+		path := filepath.Join(dir, "jobs.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	jobs, err := LoadJobs(write("jobs:\n  - project: app\n    source: src/app\n    schedule: \"0 */6 * * *\"\n    timezone: Europe/Paris\n    pull: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, filepath.Join(dir, "src", "app"), jobs[0].Source)
+		assert.True(t, jobs[0].Pull)
+		assert.False(t, jobs[0].Full)
+	}
+
+	for name, content := range map[string]string{
+		"no jobs":      "jobs: []\n",
+		"bad project":  "jobs:\n  - project: ../app\n    source: .\n    schedule: \"@daily\"\n",
+		"twice":        "jobs:\n  - project: app\n    source: .\n    schedule: \"@daily\"\n  - project: app\n    source: .\n    schedule: \"@hourly\"\n",
+		"no source":    "jobs:\n  - project: app\n    schedule: \"@daily\"\n",
+		"bad schedule": "jobs:\n  - project: app\n    source: .\n    schedule: \"every day\"\n",
+		"bad timezone": "jobs:\n  - project: app\n    source: .\n    schedule: \"@daily\"\n    timezone: Mars/Olympus\n",
+	} {
+		_, err := LoadJobs(write(content))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSchedulerStart(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	var held atomic.Int32
+	open := func(project string) (*meb.MEBStore, func(), error) {
+		<-release
+		held.Add(1)
+		return s, func() { held.Add(-1) }, nil
+	}
+	sch := NewScheduler(open, []Job{{Project: "app", Source: src, Schedule: "@yearly"}}, &IngestOptions{SkipEmbeddings: true})
+	reports := make(chan SyncReport, 2)
+	sch.OnSync(func(r SyncReport) { reports <- r })
+
+	assert.True(t, errors.Is(sch.Start("other"), ErrNoJob))
+	if err := sch.Start("app"); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, errors.Is(sch.Start("app"), ErrJobRunning), "runs must not overlap")
+	close(release)
+
+	r := <-reports
+	if r.Err != nil || r.Project != "app" || r.Trigger != TriggerSchedule || r.Facts == 0 {
+		t.Errorf("report = %+v", r)
+	}
+	sch.wg.Wait()
+	status := sch.Status()
+	if assert.Len(t, status, 1) {
+		assert.Equal(t, 1, status[0].Runs)
+		assert.False(t, status[0].Running)
+		assert.Empty(t, status[0].LastError)
+	}
+	assert.Zero(t, held.Load(), "a finished run must release its store")
+	assert.NoError(t, sch.Start("app"), "a finished job can run again")
+	<-reports
+	sch.wg.Wait()
+}
+
+func TestSchedulerJobs(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	for _, name := range []string{"main.go", "util.go"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte("package main\n\nfunc "+strings.TrimSuffix(name, ".go")+"() {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	release := make(chan struct{})
+	open := func(project string) (*meb.MEBStore, func(), error) {
+		<-release
+		return s, func() {}, nil
+	}
+	sch := NewScheduler(open, []Job{
+		{Project: "app", Source: src, Schedule: "@yearly", Full: true},
+	}, &IngestOptions{SkipEmbeddings: true})
+	m := jobs.NewManager()
+	sch.TrackJobs(m)
+
+	if err := sch.Start("app"); err != nil {
+		t.Fatal(err)
+	}
+	id := sch.Status()[0].JobID
+	close(release)
+	m.Wait()
+	job, ok := m.Get(id)
+	if assert.True(t, ok) {
+		assert.Equal(t, jobs.StateSucceeded, job.State)
+		assert.Equal(t, "ingest", job.Kind)
+		assert.Equal(t, "app", job.Project)
+		assert.Equal(t, jobs.Progress{Stage: "files", Done: 2, Total: 2}, job.Progress)
+		assert.NotEmpty(t, job.Logs)
+	}
+
+	// A cancelled run stops and is reported cancelled
+	release = make(chan struct{})
+	if err := sch.Start("app"); err != nil {
+		t.Fatal(err)
+	}
+	id = sch.Status()[0].JobID
+	if _, err := m.Cancel(id); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	m.Wait()
+	job, _ = m.Get(id)
+	assert.Equal(t, jobs.StateCancelled, job.State)
+	assert.Contains(t, sch.Status()[0].LastError, context.Canceled.Error())
+}
@@ -0,0 +1,237 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// summaryCachePrefix prefixes the document caching each project's summaries by
+// the hash of the summarized code.
+const summaryCachePrefix = "gca:summary_cache:"
+
+// SummaryInput is a symbol to summarize.
+type SummaryInput struct {
+	ID   string
+	Code string
+}
+
+// Summarizer writes one-line descriptions of symbols.
+type Summarizer interface {
+	// Summarize returns one summary per symbol, in order; an empty summary
+	// means the symbol was skipped.
+	Summarize(ctx context.Context, symbols []SummaryInput) ([]string, error)
+}
+
+// SummarizeSymbols is an enrichment pass that writes a has_summary fact on every
+// exported symbol of projectName. Symbols are sent to summarizer in batches of
+// config.SummaryBatchSize, and summaries are cached by the hash of the symbol's
+// code, so a re-ingest only asks for the symbols that changed. It returns the
+// number of summaries written.
+func SummarizeSymbols(ctx context.Context, s *meb.MEBStore, projectName string, summarizer Summarizer) (int, error) {
+	ctx = projectScope(ctx, s, projectName)
+	cache, err := loadSummaryCache(s, projectName)
+	if err != nil {
+		logger.Warn("Could not load summary cache", "project", projectName, "error", err)
+		cache = make(map[string]string)
+	}
+
+	current := make(map[string]string)
+	for fact, err := range gcamdb.Scan(ctx, s, "", config.PredicateHasSummary, "") {
+		if str, ok := fact.Object.(string); err == nil && ok {
+			current[fact.Subject] = str
+		}
+	}
+	exports := make(map[string]bool)
+	for fact, err := range gcamdb.Scan(ctx, s, "", config.PredicateExports, "") {
+		if id, ok := fact.Object.(string); err == nil && ok {
+			exports[id] = true
+		}
+	}
+
+	// Hash every exported symbol's code; misses go to the summarizer
+	hashes := make(map[string]string) // symbol ID -> code hash
+	var misses []SummaryInput
+	missed := make(map[string]bool) // hashes already queued
+	for fact, err := range gcamdb.Scan(ctx, s, "", config.PredicateHasName, "") {
+		name, ok := fact.Object.(string)
+		if err != nil || !ok || !IsExportedSymbol(fact.Subject, name, exports) {
+			continue
+		}
+		code, err := gcamdb.GetSymbolSnippet(ctx, s, fact.Subject)
+		if err != nil || strings.TrimSpace(code) == "" {
+			continue
+		}
+		code = truncateUTF8(code, config.SummaryMaxSnippet)
+		sum := sha256.Sum256([]byte(code))
+		hash := hex.EncodeToString(sum[:])
+		hashes[fact.Subject] = hash
+		if _, ok := cache[hash]; !ok && !missed[hash] {
+			missed[hash] = true
+			misses = append(misses, SummaryInput{ID: fact.Subject, Code: code})
+		}
+	}
+
+	for start := 0; start < len(misses); start += config.SummaryBatchSize {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		batch := misses[start:min(start+config.SummaryBatchSize, len(misses))]
+		summaries, err := summarizer.Summarize(ctx, batch)
+		if err != nil {
+			logger.Warn("Summary batch failed", "project", projectName, "symbols", len(batch), "error", err)
+			continue
+		}
+		for i, summary := range summaries {
+			if i < len(batch) {
+				if summary = cleanSummary(summary); summary != "" {
+					cache[hashes[batch[i].ID]] = summary
+				}
+			}
+		}
+	}
+
+	var facts []meb.Fact
+	written := 0
+	used := make(map[string]string, len(hashes))
+	for id, hash := range hashes {
+		summary, ok := cache[hash]
+		if !ok {
+			continue
+		}
+		used[hash] = summary
+		switch old, had := current[id]; {
+		case had && old == summary:
+		case had:
+			if err := replaceSummary(s, id, summary); err != nil {
+				logger.Warn("Could not replace summary", "symbol", id, "error", err)
+				continue
+			}
+			written++
+		default:
+			facts = append(facts, meb.Fact{Subject: id, Predicate: config.PredicateHasSummary, Object: summary})
+		}
+	}
+	for start := 0; start < len(facts); start += config.WriteBatchMaxFacts {
+		if err := s.AddFactBatch(facts[start:min(start+config.WriteBatchMaxFacts, len(facts))]); err != nil {
+			return written, fmt.Errorf("write summaries: %w", err)
+		}
+	}
+	written += len(facts)
+
+	// Only the summaries of current code are kept
+	if err := saveSummaryCache(s, projectName, used); err != nil {
+		logger.Warn("Could not save summary cache", "project", projectName, "error", err)
+	}
+	return written, nil
+}
+
+// runSummaries runs the summary enrichment pass when enabled in opts. Model
+// failures are logged rather than failing the ingestion.
+func runSummaries(ctx context.Context, s *meb.MEBStore, projectName string, opts *IngestOptions) {
+	if opts == nil || !opts.Summarize {
+		return
+	}
+	summarizer, err := NewSummaryService(ctx)
+	if err != nil {
+		logger.Warn("Symbol summaries disabled", "error", err)
+		return
+	}
+	n, err := SummarizeSymbols(ctx, s, projectName, summarizer)
+	if err != nil {
+		logger.Warn("Symbol summaries failed", "project", projectName, "error", err)
+		return
+	}
+	logger.Info("Summarized symbols", "project", projectName, "written", n)
+}
+
+// replaceSummary swaps a symbol's has_summary fact. The store deletes facts by
+// subject only, so the symbol's other facts are rewritten.
+func replaceSummary(s *meb.MEBStore, id, summary string) error {
+	var facts []meb.Fact
+	for fact, err := range s.ScanInTopicContext(context.Background(), s.TopicID(), id, "", "") {
+		if err == nil && fact.Predicate != config.PredicateHasSummary {
+			facts = append(facts, fact)
+		}
+	}
+	if err := s.DeleteFactsBySubject(id); err != nil {
+		return err
+	}
+	facts = append(facts, meb.Fact{Subject: id, Predicate: config.PredicateHasSummary, Object: summary})
+	return s.AddFactBatch(facts)
+}
+
+// IsExportedSymbol reports whether a symbol is part of its package's public API:
+// capitalized in Go, not underscore-prefixed elsewhere, or exported by its file.
+func IsExportedSymbol(id, name string, exports map[string]bool) bool {
+	if exports[id] {
+		return true
+	}
+	file, _, ok := strings.Cut(id, ":")
+	if !ok || name == "" {
+		return false // Files are not summarized
+	}
+	// Methods are named "Type.Method"
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	if filepath.Ext(file) == ".go" {
+		return unicode.IsUpper(r)
+	}
+	return r != '_' && r != '#' && r != utf8.RuneError
+}
+
+// cleanSummary trims a model answer to a single bounded line.
+func cleanSummary(summary string) string {
+	summary, _, _ = strings.Cut(strings.TrimSpace(summary), "\n")
+	summary = strings.Trim(strings.TrimSpace(summary), "\"`")
+	return truncateUTF8(summary, config.SummaryMaxLength)
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func loadSummaryCache(s *meb.MEBStore, project string) (map[string]string, error) {
+	cache := make(map[string]string)
+	key := summaryCachePrefix + project
+	ok, err := s.HasDocument(key)
+	if err != nil || !ok {
+		return cache, err
+	}
+	data, err := gcamdb.GetDocument(s, key)
+	if err != nil {
+		return cache, err
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return make(map[string]string), err
+	}
+	return cache, nil
+}
+
+func saveSummaryCache(s *meb.MEBStore, project string, cache map[string]string) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return gcamdb.PutDocument(s, s.TopicID(), summaryCachePrefix+project, data, nil)
+}
@@ -0,0 +1,219 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// FileSummaryOptions tunes SummarizeFiles. Zero values take the
+// config.FileSummary* defaults.
+type FileSummaryOptions struct {
+	Force       bool          // Summarize files that already have a summary, bypassing the cache
+	BatchSize   int           // Files per model request
+	Concurrency int           // Model requests in flight at once
+	Interval    time.Duration // Least time between two requests starting
+}
+
+// FileSummaryProgress is reported after each batch of SummarizeFiles.
+type FileSummaryProgress struct {
+	Done    int      `json:"done"` // Files handled so far, including skipped and cached ones
+	Total   int      `json:"total"`
+	Written []string `json:"written,omitempty"` // Files of the batch whose summary was written
+	Error   string   `json:"error,omitempty"`   // Why the batch failed
+}
+
+// FileSummaryReport summarizes a SummarizeFiles run.
+type FileSummaryReport struct {
+	Project string `json:"project"`
+	Files   int    `json:"files"`
+	Written int    `json:"written"` // has_summary facts written or replaced
+	Cached  int    `json:"cached"`  // Summaries taken from the summary cache
+	Skipped int    `json:"skipped"` // Files already summarized, or without content
+	Failed  int    `json:"failed"`  // Files of failed batches, or the model skipped
+}
+
+// SummarizeFiles writes a has_summary fact on each of files, IDs of files of
+// projectName, so that a file browser can show what every file does without a
+// model request per file. Files are sent to summarizer in batches run
+// concurrently under a rate limit; summaries are cached by the hash of the
+// file's content, like symbol summaries. progress, if not nil, is called after
+// each batch, one call at a time. A failed batch is reported and the others go
+// on; SummarizeFiles only fails when ctx is done or the store cannot be
+// written. Callers own the store's topic for the duration.
+func SummarizeFiles(ctx context.Context, s *meb.MEBStore, projectName string, files []string, summarizer Summarizer, opts FileSummaryOptions, progress func(FileSummaryProgress)) (*FileSummaryReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = config.FileSummaryBatchSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = config.FileSummaryConcurrency
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = config.FileSummaryRequestInterval
+	}
+	s.SetTopicID(gcamdb.TopicForProject(projectName))
+	scoped := projectScope(ctx, s, projectName)
+	report := &FileSummaryReport{Project: projectName, Files: len(files)}
+
+	cache, err := loadSummaryCache(s, projectName)
+	if err != nil {
+		logger.Warn("Could not load summary cache", "project", projectName, "error", err)
+		cache = make(map[string]string)
+	}
+	current := make(map[string]string)
+	for fact, err := range gcamdb.Scan(scoped, s, "", config.PredicateHasSummary, "") {
+		if str, ok := fact.Object.(string); err == nil && ok {
+			current[fact.Subject] = str
+		}
+	}
+
+	var mu sync.Mutex // Guards the store's writes, cache, report, done and progress calls
+	done := 0
+	write := func(summaries map[string]string) ([]string, error) {
+		var added []meb.Fact
+		var written []string
+		for id, summary := range summaries {
+			switch old, had := current[id]; {
+			case had && old == summary:
+				continue
+			case had:
+				if err := replaceSummary(s, id, summary); err != nil {
+					return written, fmt.Errorf("replace summary of %s: %w", id, err)
+				}
+			default:
+				added = append(added, meb.Fact{Subject: id, Predicate: config.PredicateHasSummary, Object: summary})
+			}
+			current[id] = summary
+			written = append(written, id)
+		}
+		sort.Strings(written)
+		if len(added) > 0 {
+			if err := s.AddFactBatch(added); err != nil {
+				return nil, fmt.Errorf("write summaries: %w", err)
+			}
+		}
+		return written, nil
+	}
+	notify := func(p FileSummaryProgress) {
+		p.Done, p.Total = done, len(files)
+		if progress != nil {
+			progress(p)
+		}
+	}
+
+	// Files with a summary or a cached one need no request
+	hashes := make(map[string]string, len(files))
+	cached := make(map[string]string)
+	var misses []SummaryInput
+	for _, id := range files {
+		if _, had := current[id]; had && !opts.Force {
+			report.Skipped++
+			continue
+		}
+		content, err := gcamdb.GetDocument(s, id)
+		if err != nil || strings.TrimSpace(string(content)) == "" {
+			report.Skipped++
+			continue
+		}
+		code := truncateUTF8(string(content), config.FileSummaryMaxSnippet)
+		sum := sha256.Sum256([]byte(code))
+		hashes[id] = hex.EncodeToString(sum[:])
+		if summary, ok := cache[hashes[id]]; ok && !opts.Force {
+			cached[id] = summary
+			continue
+		}
+		misses = append(misses, SummaryInput{ID: id, Code: code})
+	}
+	written, err := write(cached)
+	if err != nil {
+		return report, err
+	}
+	report.Cached = len(cached)
+	report.Written += len(written)
+	done = len(files) - len(misses)
+	notify(FileSummaryProgress{Written: written})
+
+	var batches [][]SummaryInput
+	for start := 0; start < len(misses); start += opts.BatchSize {
+		batches = append(batches, misses[start:min(start+opts.BatchSize, len(misses))])
+	}
+	limiter := time.NewTicker(opts.Interval)
+	defer limiter.Stop()
+	queue := make(chan []SummaryInput)
+	var writeErr error
+	var wg sync.WaitGroup
+	for range min(opts.Concurrency, len(batches)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range queue {
+				select {
+				case <-ctx.Done():
+					continue
+				case <-limiter.C:
+				}
+				summaries, err := summarizer.Summarize(ctx, batch)
+
+				mu.Lock()
+				done += len(batch)
+				if err != nil {
+					logger.Warn("File summary batch failed", "project", projectName, "files", len(batch), "error", err)
+					report.Failed += len(batch)
+					notify(FileSummaryProgress{Error: err.Error()})
+					mu.Unlock()
+					continue
+				}
+				got := make(map[string]string, len(batch))
+				for i, in := range batch {
+					summary := ""
+					if i < len(summaries) {
+						summary = cleanSummary(summaries[i])
+					}
+					if summary == "" {
+						report.Failed++
+						continue
+					}
+					cache[hashes[in.ID]] = summary
+					got[in.ID] = summary
+				}
+				written, err := write(got)
+				report.Written += len(written)
+				if err != nil && writeErr == nil {
+					writeErr = err
+				}
+				notify(FileSummaryProgress{Written: written})
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, batch := range batches {
+		mu.Lock()
+		failed := writeErr != nil
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+		queue <- batch
+	}
+	close(queue)
+	wg.Wait()
+
+	// Summaries are cached even when the run stops early
+	if err := saveSummaryCache(s, projectName, cache); err != nil {
+		logger.Warn("Could not save summary cache", "project", projectName, "error", err)
+	}
+	if writeErr != nil {
+		return report, writeErr
+	}
+	return report, ctx.Err()
+}
@@ -0,0 +1,178 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+// fakeSummarizer summarizes a symbol as "Summary of <name>." and records the
+// symbols it was asked about.
+type fakeSummarizer struct {
+	mu    sync.Mutex
+	asked []string
+}
+
+func (f *fakeSummarizer) Summarize(ctx context.Context, symbols []SummaryInput) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]string, len(symbols))
+	for i, sym := range symbols {
+		f.asked = append(f.asked, sym.ID)
+		out[i] = "Summary of " + sym.ID[strings.LastIndex(sym.ID, ":")+1:] + "."
+	}
+	return out, nil
+}
+
+func TestSummarizeSymbols(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(src, "lib.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package lib\n\n// Open opens.\nfunc Open() error {\n\treturn nil\n}\n\nfunc helper() int {\n\treturn 1\n}\n")
+	if err := RunWithOptions(s, "app", src, NewIngestState(), &IngestOptions{SkipEmbeddings: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries := func() map[string]string {
+		t.Helper()
+		got := make(map[string]string)
+		for fact, err := range s.ScanContext(context.Background(), "", config.PredicateHasSummary, "") {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[fact.Subject] = fact.Object.(string)
+		}
+		return got
+	}
+
+	f := &fakeSummarizer{}
+	n, err := SummarizeSymbols(context.Background(), s, "app", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summaries(); n != 1 || len(got) != 1 || got["app/lib.go:Open"] != "Summary of Open." {
+		t.Fatalf("summaries = %v (%d written), want only the exported Open", got, n)
+	}
+
+	// Unchanged code is served from the cache
+	f.asked = nil
+	if n, err := SummarizeSymbols(context.Background(), s, "app", f); err != nil || n != 0 || len(f.asked) != 0 {
+		t.Fatalf("re-run wrote %d, asked %v, err %v; want nothing", n, f.asked, err)
+	}
+
+	// Changed code is summarized again and replaces the old summary
+	write("package lib\n\n// Open opens.\nfunc Open() error {\n\treturn os.ErrClosed\n}\n\n// Close closes.\nfunc Close() {}\n")
+	if err := RunIncrementalWithOptions(s, "app", src, NewIngestState(), &IngestOptions{SkipEmbeddings: true}); err != nil {
+		t.Fatal(err)
+	}
+	f.asked = nil
+	if _, err := SummarizeSymbols(context.Background(), s, "app", f); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(f.asked)
+	if strings.Join(f.asked, ",") != "app/lib.go:Close,app/lib.go:Open" {
+		t.Errorf("asked %v, want Close and Open", f.asked)
+	}
+	var count int
+	for _, err := range s.ScanContext(context.Background(), "app/lib.go:Open", config.PredicateHasSummary, "") {
+		if err == nil {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Open has %d summaries, want 1", count)
+	}
+}
+
+func TestIsExportedSymbol(t *testing.T) {
+	tests := []struct {
+		id, name string
+		want     bool
+	}{
+		{"a/b.go:Open", "Open", true},
+		{"a/b.go:open", "open", false},
+		{"a/b.go:Server.Start", "Server.Start", true},
+		{"a/b.go:Server.start", "Server.start", false},
+		{"a/b.py:load", "load", true},
+		{"a/b.py:_load", "_load", false},
+		{"a/b.go", "b.go", false},
+	}
+	for _, tt := range tests {
+		if got := IsExportedSymbol(tt.id, tt.name, nil); got != tt.want {
+			t.Errorf("IsExportedSymbol(%q, %q) = %v, want %v", tt.id, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeFiles(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"a.go": "package lib\n\nfunc A() {}\n",
+		"b.go": "package lib\n\nfunc B() {}\n",
+		"c.go": "package lib\n\nfunc C() {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RunWithOptions(s, "app", src, NewIngestState(), &IngestOptions{SkipEmbeddings: true}); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{"app/a.go", "app/b.go", "app/c.go"}
+	opts := FileSummaryOptions{BatchSize: 2, Concurrency: 2, Interval: time.Millisecond}
+
+	f := &fakeSummarizer{}
+	var calls []FileSummaryProgress
+	report, err := SummarizeFiles(context.Background(), s, "app", files, f, opts, func(p FileSummaryProgress) {
+		calls = append(calls, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Written != 3 || report.Failed != 0 || len(f.asked) != 3 {
+		t.Fatalf("report = %+v after asking %v, want all 3 files written", report, f.asked)
+	}
+	if last := calls[len(calls)-1]; len(calls) != 3 || last.Done != 3 || last.Total != 3 {
+		t.Errorf("progress = %+v, want one call up front and one per batch, ending at 3/3", calls)
+	}
+	for fact, err := range s.ScanContext(context.Background(), "app/b.go", config.PredicateHasSummary, "") {
+		if err != nil || fact.Object != "Summary of app/b.go." {
+			t.Errorf("b.go summary = %v (%v)", fact.Object, err)
+		}
+	}
+
+	// Summarized files are skipped unless forced, and forcing bypasses the cache
+	f.asked = nil
+	report, err = SummarizeFiles(context.Background(), s, "app", files, f, opts, nil)
+	if err != nil || report.Skipped != 3 || len(f.asked) != 0 {
+		t.Fatalf("re-run = %+v, asked %v, err %v; want every file skipped", report, f.asked, err)
+	}
+	opts.Force = true
+	report, err = SummarizeFiles(context.Background(), s, "app", files[:1], f, opts, nil)
+	if err != nil || len(f.asked) != 1 || report.Written != 0 {
+		t.Errorf("forced run = %+v, asked %v, err %v; want a.go asked again and its summary unchanged", report, f.asked, err)
+	}
+}
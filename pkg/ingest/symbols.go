@@ -0,0 +1,126 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// SymbolTableKeyPrefix prefixes the store key of a project's symbol table.
+const SymbolTableKeyPrefix = "gca:symbol_table:"
+
+// sourceFile is a file to index: its path on disk and its ID in the graph.
+type sourceFile struct {
+	path string
+	rel  string
+}
+
+// newExtractor returns an extractor resolving imports against the session's
+// files and TypeScript path aliases.
+func (state *IngestState) newExtractor() *TreeSitterExtractor {
+	return &TreeSitterExtractor{parser: sitter.NewParser(), files: state.FileIndex, tsConfigs: state.tsConfigs}
+}
+
+// indexFiles extracts the symbols of files on workers goroutines, replacing
+// what the session knew of them, and rebuilds the symbol table. Unreadable and
+// skipped files are indexed without symbols.
+func (state *IngestState) indexFiles(ctx context.Context, files []sourceFile, workers int) {
+	jobs := make(chan sourceFile)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ext := NewTreeSitterExtractor()
+			for f := range jobs {
+				keys := make(map[string]string)
+				if content, _, skip, err := loadSource(f.path); err == nil && skip == "" {
+					symbols, _ := ext.ExtractSymbols(f.path, content, f.rel)
+					for _, sym := range symbols {
+						keys[sym.Name] = sym.ID
+						if sym.Package != "" {
+							keys[sym.Package+"."+sym.Name] = sym.ID
+						}
+						if sym.Receiver != "" {
+							keys[sym.Receiver+"."+sym.Name] = sym.ID
+						}
+					}
+				}
+				mu.Lock()
+				state.fileSymbols[f.rel] = keys
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+	state.rebuildSymbolTable()
+}
+
+// forgetFiles drops files from the session, as when they are deleted.
+func (state *IngestState) forgetFiles(rels ...string) {
+	for _, rel := range rels {
+		delete(state.fileSymbols, rel)
+	}
+	state.rebuildSymbolTable()
+}
+
+// rebuildSymbolTable derives SymbolTable, FileIndex and the Go package index
+// from the per-file symbols. Files are merged in path order, so when several define the same
+// name the last path wins, as in a directory walk.
+func (state *IngestState) rebuildSymbolTable() {
+	files := make([]string, 0, len(state.fileSymbols))
+	for rel := range state.fileSymbols {
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+
+	state.SymbolTable = make(map[string]string)
+	state.FileIndex = make(map[string]bool, len(files))
+	for _, rel := range files {
+		state.FileIndex[rel] = true
+		for key, id := range state.fileSymbols[rel] {
+			state.SymbolTable[key] = id
+		}
+	}
+	state.indexGoPackages()
+}
+
+// saveSymbolTable persists the session's symbols for projectName, so later
+// ingests of the project start from them instead of re-extracting every file.
+func (state *IngestState) saveSymbolTable(s *meb.MEBStore, projectName string) error {
+	data, err := json.Marshal(state.fileSymbols)
+	if err != nil {
+		return err
+	}
+	return s.AddDocument(SymbolTableKeyPrefix+projectName, data, nil, nil)
+}
+
+// loadSymbolTable restores the symbols persisted for projectName. It reports
+// false when the project has none.
+func (state *IngestState) loadSymbolTable(s *meb.MEBStore, projectName string) bool {
+	content, err := s.GetContentByKey(SymbolTableKeyPrefix + projectName)
+	if err != nil || len(content) == 0 {
+		return false
+	}
+	files := make(map[string]map[string]string)
+	if err := json.Unmarshal(content, &files); err != nil {
+		logger.Warn("Ignoring unreadable symbol table", "project", projectName, "error", err)
+		return false
+	}
+	state.fileSymbols = files
+	state.rebuildSymbolTable()
+	return true
+}
@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestSymbolTableSession(t *testing.T) {
+	src := t.TempDir()
+	var files []sourceFile
+	for name, content := range map[string]string{
+		"a.go":       "package app\n\nfunc Alpha() {}\n",
+		"b.go":       "package app\n\nfunc Beta() {}\n",
+		"web/api.ts": "export function fetchUser() {}\n",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, sourceFile{path: path, rel: filepath.Join("app", name)})
+	}
+
+	state := NewIngestState()
+	state.indexFiles(context.Background(), files, 2)
+	for _, key := range []string{"Alpha", "Beta", "fetchUser"} {
+		if state.SymbolTable[key] == "" {
+			t.Errorf("symbol %s not indexed", key)
+		}
+	}
+	if len(state.FileIndex) != 3 {
+		t.Errorf("file index has %d files, want 3", len(state.FileIndex))
+	}
+
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(gcamdb.TopicForProject("app"))
+	if err := state.saveSymbolTable(s, "app"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewIngestState()
+	if !loaded.loadSymbolTable(s, "app") {
+		t.Fatal("persisted symbol table not found")
+	}
+	if loaded.SymbolTable["Alpha"] != state.SymbolTable["Alpha"] || !loaded.FileIndex["app/web/api.ts"] {
+		t.Errorf("loaded table differs: %v", loaded.SymbolTable)
+	}
+	loaded.forgetFiles("app/a.go")
+	if _, ok := loaded.SymbolTable["Alpha"]; ok || loaded.FileIndex["app/a.go"] {
+		t.Error("forgotten file still indexed")
+	}
+	if NewIngestState().loadSymbolTable(s, "other") {
+		t.Error("found a symbol table for another project")
+	}
+
+	// Each session resolves imports against its own files
+	other := NewIngestState()
+	if got := state.newExtractor().resolveImportPath("app/web/page.ts", "./api"); got != "app/web/api.ts" {
+		t.Errorf("resolved ./api to %s, want app/web/api.ts", got)
+	}
+	if got := other.newExtractor().resolveImportPath("app/web/page.ts", "./api"); got != "app/web/api" {
+		t.Errorf("another session resolved ./api to %s, want the unresolved app/web/api", got)
+	}
+}
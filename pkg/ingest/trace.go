@@ -0,0 +1,435 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/google/pprof/profile"
+)
+
+// Trace formats accepted by ImportTrace.
+const (
+	TraceFormatPprof = "pprof"
+	TraceFormatOTel  = "otel"
+)
+
+// TraceReport summarizes a trace import.
+type TraceReport struct {
+	*ImportReport
+	Format     string    `json:"format"`
+	Edges      int       `json:"edges"`               // Distinct caller/callee pairs observed
+	Unresolved int       `json:"unresolved"`          // Distinct frames or spans matching no symbol, such as library code
+	ExpiresAt  time.Time `json:"expires_at,omitzero"` // When the observed calls expire, under the store's retention policy
+}
+
+// ImportTrace reads a Go pprof profile or an OpenTelemetry trace export (OTLP
+// JSON, as written by the collector's file exporter) from r and adds an
+// actually_calls fact for every call it observed between symbols of
+// projectName. Observed weights are kept with gcamdb.AddRuntimeEdges: for a
+// profile, the samples through the call (the first sample type counted in
+// samples); for a trace, the spans of the callee under the caller.
+//
+// Frames and spans are matched to symbols by file path and function name,
+// falling back to the symbol whose lines contain the frame. Calls through
+// unmatched frames, such as the standard library, link the nearest matched
+// frames on either side. An empty format is detected from the input. Under a
+// retention policy for gcamdb.GraphRuntime, the calls expire after its TTL,
+// unless observed again.
+func ImportTrace(s *meb.MEBStore, projectName string, r io.Reader, format string) (*TraceReport, error) {
+	br := bufio.NewReader(r)
+	if format == "" {
+		format = detectTraceFormat(br)
+	}
+	s.SetTopicID(gcamdb.TopicForProject(projectName))
+	res := newFrameResolver(s, projectName)
+
+	var observed map[[2]string]float64
+	var err error
+	switch strings.ToLower(format) {
+	case TraceFormatPprof:
+		observed, err = pprofCalls(br, res)
+	case TraceFormatOTel, "otlp":
+		format = TraceFormatOTel
+		observed, err = otelCalls(br, res)
+	default:
+		return nil, fmt.Errorf("%w: unsupported trace format %q (want %s or %s)", ErrInvalidImport, format, TraceFormatPprof, TraceFormatOTel)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	facts := make([]meb.Fact, 0, len(observed))
+	edges := make([]gcamdb.RuntimeEdge, 0, len(observed))
+	for call, weight := range observed {
+		facts = append(facts, meb.Fact{Subject: call[0], Predicate: config.PredicateActuallyCalls, Object: call[1]})
+		edges = append(edges, gcamdb.RuntimeEdge{Caller: call[0], Callee: call[1], Weight: weight, Sources: []string{format}})
+	}
+	report, err := addImportedFacts(s, projectName, facts)
+	if err != nil {
+		return nil, err
+	}
+	if err := gcamdb.AddRuntimeEdges(s, edges); err != nil {
+		return nil, err
+	}
+	policy, err := gcamdb.Retention(s)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := policy.ExpiresAt(gcamdb.GraphRuntime, time.Now())
+	if err := gcamdb.ExpireFacts(s, projectName, gcamdb.GraphRuntime, facts, expiresAt); err != nil {
+		return nil, err
+	}
+	return &TraceReport{ImportReport: report, Format: format, Edges: len(observed), Unresolved: res.unresolved, ExpiresAt: expiresAt}, nil
+}
+
+// detectTraceFormat reports OTel for JSON input and pprof otherwise.
+func detectTraceFormat(br *bufio.Reader) string {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return TraceFormatPprof
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		case '{', '[':
+			return TraceFormatOTel
+		default:
+			return TraceFormatPprof
+		}
+	}
+}
+
+// pprofCalls sums, per caller and callee symbol, the samples whose stacks pass
+// through the call. A call is counted once per sample, however deep the
+// recursion.
+func pprofCalls(r io.Reader, res *frameResolver) (map[[2]string]float64, error) {
+	p, err := profile.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parse pprof profile: %v", ErrInvalidImport, err)
+	}
+	valueIdx := 0
+	for i, st := range p.SampleType {
+		if st.Unit == "count" {
+			valueIdx = i
+			break
+		}
+	}
+
+	observed := make(map[[2]string]float64)
+	for _, sample := range p.Sample {
+		if valueIdx >= len(sample.Value) || sample.Value[valueIdx] <= 0 {
+			continue
+		}
+		// Leaf first; a location's lines are its inlined calls, innermost first
+		var stack []string
+		for _, loc := range sample.Location {
+			for _, line := range loc.Line {
+				if line.Function == nil {
+					continue
+				}
+				id := res.resolve(line.Function.Name, line.Function.Filename, line.Line)
+				if id != "" && (len(stack) == 0 || stack[len(stack)-1] != id) {
+					stack = append(stack, id)
+				}
+			}
+		}
+		seen := make(map[[2]string]bool)
+		for i := 0; i+1 < len(stack); i++ {
+			call := [2]string{stack[i+1], stack[i]}
+			if !seen[call] {
+				seen[call] = true
+				observed[call] += float64(sample.Value[valueIdx])
+			}
+		}
+	}
+	return observed, nil
+}
+
+// OTLP JSON trace export, reduced to what call edges need.
+type otlpExport struct {
+	ResourceSpans []struct {
+		ScopeSpans                  []otlpScopeSpans `json:"scopeSpans"`
+		InstrumentationLibrarySpans []otlpScopeSpans `json:"instrumentationLibrarySpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpScopeSpans struct {
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string         `json:"stringValue"`
+		IntValue    json.RawMessage `json:"intValue"` // A string in proto3 JSON, a number from some exporters
+	} `json:"value"`
+}
+
+func (a otlpAttribute) str() string {
+	if a.Value.StringValue != nil {
+		return *a.Value.StringValue
+	}
+	return strings.Trim(string(a.Value.IntValue), `"`)
+}
+
+// otelCalls counts, per caller and callee symbol, the spans of the callee under
+// a span of the caller. Spans matching no symbol pass calls through to the
+// nearest matched ancestor.
+func otelCalls(r io.Reader, res *frameResolver) (map[[2]string]float64, error) {
+	type spanKey struct{ trace, span string }
+	parents := make(map[spanKey]spanKey)
+	symbols := make(map[spanKey]string)
+	var order []spanKey
+
+	dec := json.NewDecoder(r)
+	for {
+		var export otlpExport
+		if err := dec.Decode(&export); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: decode OTLP JSON: %v", ErrInvalidImport, err)
+		}
+		for _, rs := range export.ResourceSpans {
+			for _, ss := range append(rs.ScopeSpans, rs.InstrumentationLibrarySpans...) {
+				for _, sp := range ss.Spans {
+					key := spanKey{sp.TraceID, sp.SpanID}
+					if sp.ParentSpanID != "" {
+						parents[key] = spanKey{sp.TraceID, sp.ParentSpanID}
+					}
+					symbols[key] = res.resolveSpan(sp)
+					order = append(order, key)
+					if len(order) > config.FactImportMaxFacts {
+						return nil, fmt.Errorf("%w: more than %d spans", ErrInvalidImport, config.FactImportMaxFacts)
+					}
+				}
+			}
+		}
+	}
+
+	observed := make(map[[2]string]float64)
+	for _, key := range order {
+		callee := symbols[key]
+		if callee == "" {
+			continue
+		}
+		// Walk up to the nearest matched ancestor; parent links can be cyclic in
+		// malformed exports
+		seen := map[spanKey]bool{key: true}
+		parent, ok := parents[key]
+		for ok && !seen[parent] && symbols[parent] == "" {
+			seen[parent] = true
+			parent, ok = parents[parent]
+		}
+		if caller := symbols[parent]; ok && caller != "" && caller != callee {
+			observed[[2]string{caller, callee}]++
+		}
+	}
+	return observed, nil
+}
+
+// frameResolver matches profile frames and trace spans to symbols of a project
+// by file path, then by name or line.
+type frameResolver struct {
+	store      *meb.MEBStore
+	project    string
+	files      map[string]map[string]bool // file ID -> symbol IDs it defines
+	byBase     map[string][]string        // file base name -> file IDs
+	lines      map[string][]symbolLines   // file ID -> symbol line ranges, loaded on demand
+	cache      map[string]string
+	unresolved int
+}
+
+type symbolLines struct {
+	id         string
+	start, end int64
+}
+
+func newFrameResolver(store *meb.MEBStore, project string) *frameResolver {
+	r := &frameResolver{
+		store:   store,
+		project: project,
+		files:   make(map[string]map[string]bool),
+		byBase:  make(map[string][]string),
+		lines:   make(map[string][]symbolLines),
+		cache:   make(map[string]string),
+	}
+	ctx := context.Background()
+	for fact, err := range store.ScanInTopicContext(ctx, store.TopicID(), "", config.PredicateDefines, "") {
+		sym, ok := fact.Object.(string)
+		if err != nil || !ok || strings.Contains(fact.Subject, ":") {
+			continue
+		}
+		syms, ok := r.files[fact.Subject]
+		if !ok {
+			syms = make(map[string]bool)
+			r.files[fact.Subject] = syms
+			base := path.Base(fact.Subject)
+			r.byBase[base] = append(r.byBase[base], fact.Subject)
+		}
+		syms[sym] = true
+	}
+	return r
+}
+
+// resolveSpan matches a span by its OpenTelemetry code attributes, both the
+// current (code.function.name, code.file.path, code.line.number) and the
+// deprecated ones.
+func (r *frameResolver) resolveSpan(sp otlpSpan) string {
+	var fn, ns, file string
+	var line int64
+	for _, a := range sp.Attributes {
+		switch a.Key {
+		case "code.function.name", "code.function":
+			fn = a.str()
+		case "code.namespace":
+			ns = a.str()
+		case "code.file.path", "code.filepath":
+			file = a.str()
+		case "code.line.number", "code.lineno":
+			line, _ = strconv.ParseInt(a.str(), 10, 64)
+		}
+	}
+	if fn != "" && ns != "" && !strings.HasPrefix(fn, ns+".") {
+		fn = ns + "." + fn
+	}
+	return r.resolve(fn, file, line)
+}
+
+// resolve returns the symbol ID of a frame, or "" when it matches none.
+func (r *frameResolver) resolve(function, file string, line int64) string {
+	key := function + "\x00" + file + "\x00" + strconv.FormatInt(line, 10)
+	if id, ok := r.cache[key]; ok {
+		return id
+	}
+	id := ""
+	if fileID := r.matchFile(file); fileID != "" {
+		for _, name := range frameSymbolNames(function) {
+			if r.files[fileID][fileID+":"+name] {
+				id = fileID + ":" + name
+				break
+			}
+		}
+		if id == "" && line > 0 {
+			id = r.symbolAt(fileID, line)
+		}
+	}
+	if id == "" {
+		r.unresolved++
+	}
+	r.cache[key] = id
+	return id
+}
+
+// matchFile returns the file ID sharing the longest path suffix with file, a
+// path on the machine that produced the trace.
+func (r *frameResolver) matchFile(file string) string {
+	if file == "" {
+		return ""
+	}
+	p := filepath.ToSlash(file)
+	best, bestLen := "", 0
+	for _, id := range r.byBase[path.Base(p)] {
+		n := commonSuffixSegments(p, id)
+		if rel := strings.TrimPrefix(id, r.project+"/"); rel != id {
+			n = max(n, commonSuffixSegments(p, rel))
+		}
+		if n > bestLen || n == bestLen && id < best {
+			best, bestLen = id, n
+		}
+	}
+	return best
+}
+
+func commonSuffixSegments(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[len(as)-1-n] == bs[len(bs)-1-n] {
+		n++
+	}
+	return n
+}
+
+// symbolAt returns the innermost symbol of fileID whose lines contain line.
+func (r *frameResolver) symbolAt(fileID string, line int64) string {
+	ranges, ok := r.lines[fileID]
+	if !ok {
+		ctx := context.Background()
+		lineOf := func(sym, pred string) int64 {
+			for fact, err := range r.store.ScanInTopicContext(ctx, r.store.TopicID(), sym, pred, "") {
+				if err == nil {
+					n, _ := strconv.ParseInt(fmt.Sprint(fact.Object), 10, 64)
+					return n
+				}
+			}
+			return 0
+		}
+		for sym := range r.files[fileID] {
+			start, end := lineOf(sym, config.PredicateStartLine), lineOf(sym, config.PredicateEndLine)
+			if start > 0 && end >= start {
+				ranges = append(ranges, symbolLines{sym, start, end})
+			}
+		}
+		r.lines[fileID] = ranges
+	}
+	best := symbolLines{}
+	for _, sl := range ranges {
+		if sl.start <= line && line <= sl.end && (best.id == "" || sl.start > best.start || sl.start == best.start && sl.id < best.id) {
+			best = sl
+		}
+	}
+	return best.id
+}
+
+var (
+	typeArgsPattern     = regexp.MustCompile(`\[[^\[\]]*\]`)
+	closureSegmentRegex = regexp.MustCompile(`^(func|gowrap)?\d+$`)
+)
+
+// frameSymbolNames returns the names a function may be defined under in its
+// file, most qualified first: "github.com/x/pkg.(*T).M.func1" yields "pkg.T.M",
+// "T.M" and "M". Closures are attributed to their enclosing function.
+func frameSymbolNames(function string) []string {
+	if function == "" {
+		return nil
+	}
+	name := function
+	for typeArgsPattern.MatchString(name) {
+		name = typeArgsPattern.ReplaceAllString(name, "")
+	}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.NewReplacer("(*", "", "(", "", ")", "", "*", "").Replace(strings.TrimSuffix(name, "-fm"))
+	parts := strings.Split(name, ".")
+	for len(parts) > 1 && closureSegmentRegex.MatchString(parts[len(parts)-1]) {
+		parts = parts[:len(parts)-1]
+	}
+	names := make([]string, 0, len(parts))
+	for i := range parts {
+		if n := strings.Join(parts[i:], "."); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
@@ -0,0 +1,116 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/google/pprof/profile"
+)
+
+func TestImportTrace(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(gcamdb.TopicForProject("app"))
+	err = s.AddFactBatch([]meb.Fact{
+		{Subject: "app/main.go", Predicate: config.PredicateDefines, Object: "app/main.go:main"},
+		{Subject: "app/server.go", Predicate: config.PredicateDefines, Object: "app/server.go:Server.Handle"},
+		{Subject: "app/db.go", Predicate: config.PredicateDefines, Object: "app/db.go:query"},
+		{Subject: "app/db.go:query", Predicate: config.PredicateStartLine, Object: "10"},
+		{Subject: "app/db.go:query", Predicate: config.PredicateEndLine, Object: "30"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// main -> Server.Handle -> (stdlib) -> query, sampled 3 and 2 times
+	fn := func(id uint64, name, file string) *profile.Function {
+		return &profile.Function{ID: id, Name: name, Filename: file}
+	}
+	mainFn := fn(1, "example.com/app.main", "/build/src/app/main.go")
+	handleFn := fn(2, "example.com/app.(*Server).Handle.func1", "/build/src/app/server.go")
+	sortFn := fn(3, "sort.Slice", "/usr/local/go/src/sort/slice.go")
+	queryFn := fn(4, "example.com/app.query[go.shape.int]", "/build/src/app/db.go")
+	loc := func(id uint64, f *profile.Function) *profile.Location {
+		return &profile.Location{ID: id, Line: []profile.Line{{Function: f, Line: 20}}}
+	}
+	locs := []*profile.Location{loc(1, mainFn), loc(2, handleFn), loc(3, sortFn), loc(4, queryFn)}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		Function:   []*profile.Function{mainFn, handleFn, sortFn, queryFn},
+		Location:   locs,
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{locs[3], locs[2], locs[1], locs[0]}, Value: []int64{3, 30}},
+			{Location: []*profile.Location{locs[1], locs[0]}, Value: []int64{2, 20}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	report, err := ImportTrace(s, "app", &buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Format != TraceFormatPprof || report.Edges != 2 || report.Unresolved != 1 {
+		t.Errorf("pprof report = %+v, want 2 edges and 1 unmatched frame", report)
+	}
+
+	otel := `{"resourceSpans": [{"scopeSpans": [{"spans": [
+	{"traceId": "t1", "spanId": "a", "name": "GET /", "attributes": [
+		{"key": "code.function", "value": {"stringValue": "Server.Handle"}},
+		{"key": "code.filepath", "value": {"stringValue": "app/server.go"}}]},
+	{"traceId": "t1", "spanId": "b", "parentSpanId": "a", "name": "db", "attributes": [
+		{"key": "code.file.path", "value": {"stringValue": "/srv/app/db.go"}},
+		{"key": "code.line.number", "value": {"intValue": "12"}}]}
+]}]}]}`
+	report, err = ImportTrace(s, "app", strings.NewReader(otel), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Format != TraceFormatOTel || report.Edges != 1 || report.Existing != 1 {
+		t.Errorf("otel report = %+v, want 1 edge already observed", report)
+	}
+
+	edges, err := gcamdb.RuntimeEdges(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[[2]string]float64{
+		{"app/main.go:main", "app/server.go:Server.Handle"}: 5,
+		{"app/server.go:Server.Handle", "app/db.go:query"}:  4,
+	}
+	if len(edges) != len(want) {
+		t.Errorf("runtime edges = %v, want %v", edges, want)
+	}
+	for call, weight := range want {
+		e, ok := edges[call[0]+"\x00"+call[1]]
+		if !ok || e.Weight != weight {
+			t.Errorf("edge %v = %+v, want weight %v", call, e, weight)
+		}
+	}
+
+	res, err := gcamdb.QueryWithOptions(context.Background(), s, `triples("app/server.go:Server.Handle", "actually_calls", ?callee)`, gcamdb.QueryOptions{Provenance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0][gcamdb.RowWeightKey] != 4.0 || res.Rows[0][gcamdb.RowSourceKey] != "runtime:pprof,otel" {
+		t.Errorf("rows = %v, want query weighted 4 from runtime:pprof,otel", res.Rows)
+	}
+}
+
+func TestFrameSymbolNames(t *testing.T) {
+	got := frameSymbolNames("github.com/x/pkg.(*Store[go.shape.int]).Get.func2.1")
+	want := []string{"pkg.Store.Get", "Store.Get", "Get"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("frameSymbolNames = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/logger"
+)
+
+// maxTSConfigExtends bounds the "extends" chain followed from a tsconfig.
+const maxTSConfigExtends = 5
+
+// tsConfig is the module resolution part of a tsconfig.json or jsconfig.json.
+// Paths are graph IDs, like the session's files.
+type tsConfig struct {
+	dir     string // Directory of the config file
+	baseURL string // compilerOptions.baseUrl; empty if unset
+	aliases []tsPathAlias
+}
+
+// tsPathAlias is a compilerOptions.paths entry. A pattern and its targets hold
+// at most one "*", standing for the same text.
+type tsPathAlias struct {
+	pattern string
+	targets []string
+}
+
+type tsConfigFile struct {
+	Extends         string `json:"extends"`
+	CompilerOptions struct {
+		BaseURL *string             `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+// isTSConfig reports whether a file name is a TypeScript or JavaScript project config.
+func isTSConfig(name string) bool {
+	return name == "tsconfig.json" || name == "jsconfig.json"
+}
+
+// loadTSConfig adds the path mappings of the config file at path, whose graph
+// ID is rel, to the session. Unreadable configs are logged and ignored.
+func (state *IngestState) loadTSConfig(path, rel string) {
+	cfg := &tsConfig{dir: filepath.Dir(rel)}
+	if err := cfg.load(path, cfg.dir, 0); err != nil {
+		logger.Warn("Ignoring unreadable TypeScript config", "file", rel, "error", err)
+		return
+	}
+	if cfg.baseURL == "" && len(cfg.aliases) == 0 {
+		return
+	}
+	state.tsConfigs = append(state.tsConfigs, cfg)
+}
+
+// load reads the config at path, located at dir in the graph, after the
+// configs it extends so that its own settings win.
+func (cfg *tsConfig) load(path, dir string, depth int) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file tsConfigFile
+	if err := json.Unmarshal(stripJSONC(data), &file); err != nil {
+		return err
+	}
+	// Package configs ("@tsconfig/node18") are not in the tree
+	if ext := file.Extends; strings.HasPrefix(ext, ".") && depth < maxTSConfigExtends {
+		if filepath.Ext(ext) != ".json" {
+			ext += ".json"
+		}
+		if err := cfg.load(filepath.Join(filepath.Dir(path), ext), filepath.Join(dir, filepath.Dir(ext)), depth+1); err != nil {
+			return err
+		}
+	}
+
+	// Without baseUrl, paths are relative to the config that declares them
+	base := dir
+	if file.CompilerOptions.BaseURL != nil {
+		cfg.baseURL = filepath.Join(dir, *file.CompilerOptions.BaseURL)
+		base = cfg.baseURL
+	} else if cfg.baseURL != "" {
+		base = cfg.baseURL
+	}
+	if file.CompilerOptions.Paths != nil {
+		cfg.aliases = nil
+		for pattern, targets := range file.CompilerOptions.Paths {
+			alias := tsPathAlias{pattern: pattern}
+			for _, t := range targets {
+				alias.targets = append(alias.targets, filepath.Join(base, t))
+			}
+			cfg.aliases = append(cfg.aliases, alias)
+		}
+	}
+	return nil
+}
+
+// match returns the alias targets for importPath, with "*" substituted, from
+// the pattern with the longest prefix, as TypeScript does.
+func (cfg *tsConfig) match(importPath string) []string {
+	var best *tsPathAlias
+	var star string
+	bestLen := -1
+	for i, a := range cfg.aliases {
+		prefix, suffix, wildcard := strings.Cut(a.pattern, "*")
+		switch {
+		case !wildcard && a.pattern == importPath:
+			return a.targets
+		case wildcard && len(prefix) > bestLen && len(importPath) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(importPath, prefix) && strings.HasSuffix(importPath, suffix):
+			best, bestLen = &cfg.aliases[i], len(prefix)
+			star = importPath[len(prefix) : len(importPath)-len(suffix)]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	targets := make([]string, len(best.targets))
+	for i, t := range best.targets {
+		targets[i] = strings.Replace(t, "*", star, 1)
+	}
+	return targets
+}
+
+// tsConfigFor returns the config governing a file: the one in its nearest
+// enclosing directory.
+func tsConfigFor(configs []*tsConfig, relPath string) *tsConfig {
+	var nearest *tsConfig
+	for _, cfg := range configs {
+		if cfg.dir != "." && !strings.HasPrefix(relPath, cfg.dir+"/") {
+			continue
+		}
+		if nearest == nil || len(cfg.dir) > len(nearest.dir) || nearest.dir == "." {
+			nearest = cfg
+		}
+	}
+	return nearest
+}
+
+// stripJSONC turns tsconfig's JSON-with-comments into JSON: comments, then
+// trailing commas, are removed.
+func stripJSONC(data []byte) []byte {
+	var out bytes.Buffer
+	scanJSON(data, func(i int) int {
+		switch {
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			return i
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			if end := bytes.Index(data[i+2:], []byte("*/")); end >= 0 {
+				return i + 2 + end + 2
+			}
+			return len(data)
+		}
+		out.WriteByte(data[i])
+		return i + 1
+	}, &out)
+
+	stripped := out.Bytes()
+	var clean bytes.Buffer
+	scanJSON(stripped, func(i int) int {
+		if stripped[i] == ',' {
+			rest := bytes.TrimLeft(stripped[i+1:], " \t\r\n")
+			if len(rest) > 0 && (rest[0] == '}' || rest[0] == ']') {
+				return i + 1
+			}
+		}
+		clean.WriteByte(stripped[i])
+		return i + 1
+	}, &clean)
+	return clean.Bytes()
+}
+
+// scanJSON copies the strings of data to out and hands every other byte offset
+// to visit, which returns the offset to continue from.
+func scanJSON(data []byte, visit func(i int) int, out *bytes.Buffer) {
+	for i := 0; i < len(data); {
+		if data[i] != '"' {
+			i = visit(i)
+			continue
+		}
+		j := i + 1
+		for j < len(data) && data[j] != '"' {
+			if data[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		j = min(j+1, len(data))
+		out.Write(data[i:j])
+		i = j
+	}
+}
@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTSConfigPathAliases(t *testing.T) {
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"web/tsconfig.base.json": `{
+			// Shared settings
+			"compilerOptions": {
+				"baseUrl": "src",
+				"paths": {"@/*": ["./*"], "@lib": ["lib/index.ts"],},
+			},
+		}`,
+		"web/tsconfig.json": `{"extends": "./tsconfig.base", /* no overrides */ "include": ["src/**/*"]}`,
+		"api/tsconfig.json": `{"compilerOptions": {"paths": {"@api/*": ["handlers/*"]}}}`,
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := NewIngestState()
+	state.loadTSConfig(filepath.Join(src, "web/tsconfig.json"), "app/web/tsconfig.json")
+	state.loadTSConfig(filepath.Join(src, "api/tsconfig.json"), "app/api/tsconfig.json")
+	for _, f := range []string{
+		"app/web/src/pages/home.tsx",
+		"app/web/src/components/button.tsx",
+		"app/web/src/lib/index.ts",
+		"app/web/src/services/user/index.ts",
+		"app/api/handlers/user.ts",
+	} {
+		state.fileSymbols[f] = map[string]string{}
+	}
+	state.rebuildSymbolTable()
+	ext := state.newExtractor()
+
+	for _, tc := range []struct {
+		from, imp, want string
+	}{
+		{"app/web/src/pages/home.tsx", "@/components/button", "app/web/src/components/button.tsx"},
+		{"app/web/src/pages/home.tsx", "@lib", "app/web/src/lib/index.ts"},
+		{"app/web/src/pages/home.tsx", "services/user", "app/web/src/services/user/index.ts"},
+		{"app/web/src/pages/home.tsx", "../components/button", "app/web/src/components/button.tsx"},
+		{"app/web/src/pages/home.tsx", "react", "react"},
+		{"app/api/handlers/user.ts", "@api/user", "app/api/handlers/user.ts"},
+		{"app/api/handlers/user.ts", "@/components/button", "@/components/button"},
+	} {
+		if got := ext.resolveImportPath(tc.from, tc.imp); got != tc.want {
+			t.Errorf("resolveImportPath(%s, %s) = %s, want %s", tc.from, tc.imp, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ingest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolUIDs(t *testing.T) {
+	uidOf := func(relPath, src, id string) string {
+		t.Helper()
+		bundle, err := NewTreeSitterExtractor().Extract(context.Background(), relPath, []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range bundle.Facts {
+			if f.Subject == id && f.Predicate == config.PredicateHasUID {
+				return f.Object.(string)
+			}
+		}
+		t.Fatalf("%s has no UID", id)
+		return ""
+	}
+
+	uid := uidOf("auth/login.go", "package auth\n\nfunc Validate(token string) error { return nil }\n", "auth/login.go:Validate")
+	assert.True(t, gcamdb.IsUID(uid))
+	assert.Equal(t, uid, uidOf("auth/session.go", "package auth\n\n// Validate moved here.\n\n\nfunc Validate(token string) error {\n\treturn nil\n}\n", "auth/session.go:Validate"),
+		"moving a symbol within its package keeps its UID")
+	assert.NotEqual(t, uid, uidOf("session/login.go", "package session\n\nfunc Validate(token string) error { return nil }\n", "session/login.go:Validate"))
+	assert.NotEqual(t, uid, uidOf("auth/login.go", "package auth\n\nfunc Validate(token string, strict bool) error { return nil }\n", "auth/login.go:Validate"))
+}
+
+func TestDropOrphanVectors(t *testing.T) {
+	cfg := store.DefaultConfig(t.TempDir())
+	cfg.SegmentDir = filepath.Join(cfg.DataDir, "vectors")
+	s, err := meb.NewMEBStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	moved := gcamdb.SymbolUID("auth:auth", "function", "Validate", "func Validate()", 0)
+	removed := gcamdb.SymbolUID("auth:auth", "function", "Refresh", "func Refresh()", 0)
+	vec, _ := constEmbedder{}.GetEmbedding(context.Background(), "")
+	for _, uid := range []string{moved, removed} {
+		dictID, err := gcamdb.VectorID(s, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Vectors().Add(dictID, vec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Validate moved to another file; Refresh was deleted
+	if err := s.AddFact(meb.Fact{Subject: "auth/session.go:Validate", Predicate: config.PredicateHasUID, Object: moved}); err != nil {
+		t.Fatal(err)
+	}
+
+	dropOrphanVectors(s, []string{moved, removed})
+	for uid, want := range map[string]bool{moved: true, removed: false} {
+		dictID, _ := s.LookupID(uid)
+		assert.Equal(t, want, s.Vectors().HasVector(dictID), uid)
+	}
+}
@@ -8,10 +8,18 @@ import (
 	"github.com/duynguyendang/gca/pkg/common"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 )
 
 func EnhanceVirtualTriples(s *meb.MEBStore) error {
+	return enhanceVirtualTriples(s, nil)
+}
+
+// enhanceVirtualTriples links routes, API calls, service calls and data
+// contracts. Given a project's OpenAPI spec, frontend API calls are matched
+// against the spec's paths instead of against the route strings.
+func enhanceVirtualTriples(s *meb.MEBStore, spec *apiSpec) error {
 	feSet := make(map[string]bool)
 	beSet := make(map[string]bool)
 
@@ -56,7 +64,7 @@ func EnhanceVirtualTriples(s *meb.MEBStore) error {
 	}
 
 	routeMap := make(map[string]string)
-	symbolLookup := make(map[string]string)
+	symbolLookup := make(map[string][]string)
 
 	isTagged := func(id string, set map[string]bool) bool {
 		if set[id] {
@@ -82,64 +90,70 @@ func EnhanceVirtualTriples(s *meb.MEBStore) error {
 				continue
 			}
 			name := common.ExtractSymbolName(sID)
-			symbolLookup[name] = sID
+			symbolLookup[name] = append(symbolLookup[name], sID)
 		}
 	}
 
-	routeRegex := regexp.MustCompile(`\.(GET|POST|PUT|DELETE|PATCH|OPTIONS|HEAD)\(\s*"([^"]+)"\s*,\s*([^,\)]+)`)
-
 	for id := range beSet {
-		if strings.Contains(id, ":") {
+		if strings.Contains(id, ":") || !strings.HasSuffix(id, ".go") {
 			continue
 		}
-		doc, err := s.GetContentByKey(string(id))
+		doc, err := gcamdb.GetDocument(s, string(id))
 		if err != nil {
 			continue
 		}
 		content := string(doc)
-		if !strings.Contains(content, "gin.Default") && !strings.Contains(content, "gin.New") && !strings.Contains(content, ".Group") && !strings.Contains(content, "Router") {
+		if !strings.Contains(content, "gin.") && !strings.Contains(content, "echo.") && !strings.Contains(content, "chi.") && !strings.Contains(content, ".Group") && !strings.Contains(content, "Router") {
 			continue
 		}
 
-		matches := routeRegex.FindAllStringSubmatch(content, -1)
-		for _, match := range matches {
-			route := match[2]
-			rawHandler := strings.TrimSpace(match[3])
-
-			handlerToken := rawHandler
-			if idx := strings.LastIndex(rawHandler, "."); idx != -1 {
-				handlerToken = rawHandler[idx+1:]
-			}
-
-			handlerToken = strings.Trim(handlerToken, " ),;")
-
-			if targetID, ok := symbolLookup[handlerToken]; ok {
-				routeMap[route] = targetID
-				s.AddFact(meb.Fact{Subject: string(route), Predicate: config.PredicateHandledBy, Object: targetID})
-				s.AddFact(meb.Fact{Subject: string(targetID), Predicate: config.PredicateHasRole, Object: config.RoleAPIHandler})
-			} else {
-				logger.Warn("Failed to link route to handler", "route", route, "handler", rawHandler, "token", handlerToken)
+		for _, r := range extractGoRoutes(doc) {
+			targetID := resolveRouteHandler(r.Handlers, symbolLookup, filepath.Dir(id))
+			if targetID == "" {
+				logger.Warn("Failed to link route to handler", "route", r.Path, "method", r.Method, "handlers", r.Handlers)
+				continue
 			}
+			routeMap[r.Path] = targetID
+			s.AddFact(meb.Fact{Subject: string(r.Path), Predicate: config.PredicateHandledBy, Object: targetID})
+			s.AddFact(meb.Fact{Subject: string(targetID), Predicate: config.PredicateHasRole, Object: config.RoleAPIHandler})
 		}
 	}
 
-	for fact, err := range s.Scan("", config.PredicateReferences, "") {
-		if err != nil {
-			continue
+	if spec != nil {
+		if err := linkAPICalls(s, spec, routeMap, func(id string) bool { return isTagged(id, feSet) }); err != nil {
+			return err
 		}
-		sID := fact.Subject
-		ref, ok := fact.Object.(string)
-		if !ok {
-			continue
+	} else {
+		// Each reference to a route, with or without a query string, is evidence of the call
+		evidence := make(map[[2]string]int)
+		for fact, err := range s.Scan("", config.PredicateReferences, "") {
+			if err != nil {
+				continue
+			}
+			sID := fact.Subject
+			ref, ok := fact.Object.(string)
+			if !ok {
+				continue
+			}
+			cleanRef := ref
+			if idx := strings.Index(ref, "?"); idx != -1 {
+				cleanRef = ref[:idx]
+			}
+			if _, exists := routeMap[cleanRef]; exists {
+				s.AddFact(meb.Fact{Subject: string(sID), Predicate: config.PredicateCallsAPI, Object: cleanRef})
+				targetID := routeMap[cleanRef]
+				s.AddFact(meb.Fact{Subject: string(sID), Predicate: config.PredicateCalls, Object: targetID})
+				evidence[[2]string{sID, cleanRef}]++
+			}
 		}
-		cleanRef := ref
-		if idx := strings.Index(ref, "?"); idx != -1 {
-			cleanRef = ref[:idx]
+		var links []gcamdb.LinkEvidence
+		for call, n := range evidence {
+			links = append(links,
+				gcamdb.LinkEvidence{Subject: call[0], Predicate: config.PredicateCallsAPI, Object: call[1], Method: gcamdb.LinkMethodRoute, Evidence: n},
+				gcamdb.LinkEvidence{Subject: call[0], Predicate: config.PredicateCalls, Object: routeMap[call[1]], Method: gcamdb.LinkMethodRoute, Evidence: n})
 		}
-		if _, exists := routeMap[cleanRef]; exists {
-			s.AddFact(meb.Fact{Subject: string(sID), Predicate: config.PredicateCallsAPI, Object: cleanRef})
-			targetID := routeMap[cleanRef]
-			s.AddFact(meb.Fact{Subject: string(sID), Predicate: config.PredicateCalls, Object: targetID})
+		if err := gcamdb.RecordLinkEvidence(s, links); err != nil {
+			return err
 		}
 	}
 
@@ -153,7 +167,7 @@ func EnhanceVirtualTriples(s *meb.MEBStore) error {
 		if strings.Contains(id, ":") {
 			continue
 		}
-		doc, err := s.GetContentByKey(string(id))
+		doc, err := gcamdb.GetDocument(s, string(id))
 		if err == nil {
 			content := string(doc)
 			var symbols []string
@@ -256,3 +270,25 @@ func EnhanceVirtualTriples(s *meb.MEBStore) error {
 
 	return nil
 }
+
+// resolveRouteHandler returns the first handler candidate naming a backend
+// symbol, preferring one defined in the route's own directory and otherwise
+// taking a unique match.
+func resolveRouteHandler(candidates []string, symbolLookup map[string][]string, dir string) string {
+	for _, candidate := range candidates {
+		token := candidate
+		if idx := strings.LastIndex(candidate, "."); idx != -1 {
+			token = candidate[idx+1:]
+		}
+		ids := symbolLookup[token]
+		for _, id := range ids {
+			if filepath.Dir(strings.SplitN(id, ":", 2)[0]) == dir {
+				return id
+			}
+		}
+		if len(ids) == 1 {
+			return ids[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,406 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+)
+
+// Vulnerability scanner names accepted by IngestOptions.VulnScanner.
+const (
+	VulnScannerGovulncheck = "govulncheck"
+	VulnScannerOSV         = "osv"
+)
+
+// Vulnerability is a known advisory affecting the project.
+// Affected holds dependency IDs ("module@version"); Symbols holds the first-party
+// symbols ("path/relative/to/source.go:Func") that reach it, when the scanner can
+// trace call paths (govulncheck).
+type Vulnerability struct {
+	ID       string
+	Severity string
+	Summary  string
+	FixedIn  string
+	Affected []string
+	Symbols  []string
+}
+
+// VulnScanner finds vulnerabilities for a source tree and its manifest dependencies.
+type VulnScanner interface {
+	Scan(ctx context.Context, sourceDir string, manifests *Manifests) ([]Vulnerability, error)
+}
+
+// NewVulnScanner returns the scanner registered under name.
+func NewVulnScanner(name string) (VulnScanner, error) {
+	switch name {
+	case VulnScannerGovulncheck:
+		return &GovulncheckScanner{Binary: "govulncheck"}, nil
+	case VulnScannerOSV:
+		return &OSVScanner{Client: &http.Client{Timeout: config.VulnScanTimeout}}, nil
+	default:
+		return nil, fmt.Errorf("unknown vulnerability scanner %q", name)
+	}
+}
+
+// AnnotateVulnerabilities is an enrichment pass that writes has_vulnerability facts on
+// affected packages and symbols, plus severity/summary/fix metadata on the advisory itself.
+func AnnotateVulnerabilities(ctx context.Context, s *meb.MEBStore, projectName, sourceDir string, meta *ProjectMetadata, scanner VulnScanner) error {
+	manifests, err := FindManifests(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.VulnScanTimeout)
+	defer cancel()
+
+	vulns, err := scanner.Scan(ctx, sourceDir, manifests)
+	if err != nil {
+		return err
+	}
+
+	var facts []meb.Fact
+	for _, v := range vulns {
+		severity := v.Severity
+		if severity == "" {
+			severity = "UNKNOWN"
+		}
+		facts = append(facts, meb.Fact{Subject: v.ID, Predicate: config.PredicateVulnSeverity, Object: severity})
+		if v.Summary != "" {
+			facts = append(facts, meb.Fact{Subject: v.ID, Predicate: config.PredicateVulnSummary, Object: v.Summary})
+		}
+		if v.FixedIn != "" {
+			facts = append(facts, meb.Fact{Subject: v.ID, Predicate: config.PredicateVulnFixedIn, Object: v.FixedIn})
+		}
+		for _, affected := range v.Affected {
+			facts = append(facts, meb.Fact{Subject: affected, Predicate: config.PredicateHasVulnerability, Object: v.ID})
+		}
+		for _, sym := range v.Symbols {
+			// Scanners report physical paths; map them onto graph IDs
+			file, name, _ := strings.Cut(sym, ":")
+			id := logicalPath(file, projectName, meta) + ":" + name
+			facts = append(facts, meb.Fact{Subject: id, Predicate: config.PredicateHasVulnerability, Object: v.ID})
+		}
+	}
+
+	if len(facts) == 0 {
+		return nil
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		return err
+	}
+	logger.Info("Annotated vulnerabilities", "count", len(vulns))
+	return nil
+}
+
+// GovulncheckScanner runs govulncheck in JSON mode for every Go module in the tree.
+// Only vulnerabilities whose symbols are reachable from project code are reported.
+type GovulncheckScanner struct {
+	Binary string
+}
+
+type govulnMessage struct {
+	OSV     *osvEntry      `json:"osv"`
+	Finding *govulnFinding `json:"finding"`
+}
+
+type govulnFinding struct {
+	OSV          string        `json:"osv"`
+	FixedVersion string        `json:"fixed_version"`
+	Trace        []govulnFrame `json:"trace"`
+}
+
+type govulnFrame struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Receiver string `json:"receiver"`
+	Position *struct {
+		Filename string `json:"filename"`
+	} `json:"position"`
+}
+
+// Scan implements VulnScanner.
+func (g *GovulncheckScanner) Scan(ctx context.Context, sourceDir string, manifests *Manifests) ([]Vulnerability, error) {
+	if _, err := exec.LookPath(g.Binary); err != nil {
+		return nil, fmt.Errorf("%s not found in PATH: %w", g.Binary, err)
+	}
+
+	modules, err := findGoModuleDirs(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*Vulnerability)
+	var order []string
+	for _, dir := range modules {
+		cmd := exec.CommandContext(ctx, g.Binary, "-json", "./...")
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		// govulncheck exits non-zero when it finds vulnerabilities; only fail without output
+		if err != nil && len(out) == 0 {
+			return nil, fmt.Errorf("govulncheck failed in %s: %w", dir, err)
+		}
+
+		advisories := make(map[string]*osvEntry)
+		dec := json.NewDecoder(bytes.NewReader(out))
+		for {
+			var msg govulnMessage
+			if err := dec.Decode(&msg); err != nil {
+				if err != io.EOF {
+					logger.Warn("Failed to decode govulncheck output", "dir", dir, "error", err)
+				}
+				break
+			}
+			if msg.OSV != nil {
+				advisories[msg.OSV.ID] = msg.OSV
+				continue
+			}
+			if msg.Finding == nil || len(msg.Finding.Trace) == 0 {
+				continue
+			}
+
+			f := msg.Finding
+			v, ok := byID[f.OSV]
+			if !ok {
+				v = &Vulnerability{ID: f.OSV, FixedIn: f.FixedVersion}
+				if adv := advisories[f.OSV]; adv != nil {
+					v.Severity = adv.severity()
+					v.Summary = adv.Summary
+				}
+				byID[f.OSV] = v
+				order = append(order, f.OSV)
+			}
+
+			vulnFrame := f.Trace[0]
+			dep := Dependency{Module: vulnFrame.Module, Version: vulnFrame.Version}
+			v.Affected = appendUnique(v.Affected, dep.ID())
+
+			// The last frame is the project code that reaches the vulnerable symbol
+			if len(f.Trace) > 1 {
+				if id := g.symbolID(sourceDir, dir, f.Trace[len(f.Trace)-1]); id != "" {
+					v.Symbols = appendUnique(v.Symbols, id)
+				}
+			}
+		}
+	}
+
+	result := make([]Vulnerability, 0, len(order))
+	for _, id := range order {
+		result = append(result, *byID[id])
+	}
+	return result, nil
+}
+
+// symbolID maps a govulncheck frame onto the "file.go:Receiver.Func" IDs produced by the extractor.
+// Relative frame positions are resolved against the module directory govulncheck ran in.
+func (g *GovulncheckScanner) symbolID(sourceDir, moduleDir string, frame govulnFrame) string {
+	if frame.Position == nil || frame.Position.Filename == "" || frame.Function == "" {
+		return ""
+	}
+	file := frame.Position.Filename
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(moduleDir, file)
+	}
+	file, err := filepath.Rel(sourceDir, file)
+	if err != nil || strings.HasPrefix(file, "..") {
+		return ""
+	}
+	name := frame.Function
+	if frame.Receiver != "" {
+		name = strings.TrimPrefix(frame.Receiver, "*") + "." + name
+	}
+	return file + ":" + name
+}
+
+// OSVScanner queries the OSV database for every manifest dependency.
+// It works for both Go and npm dependencies but cannot attribute findings to symbols.
+type OSVScanner struct {
+	Client *http.Client
+}
+
+type osvEntry struct {
+	ID       string `json:"id"`
+	Summary  string `json:"summary"`
+	Severity []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+	Affected []struct {
+		Ranges []struct {
+			Events []struct {
+				Fixed string `json:"fixed"`
+			} `json:"events"`
+		} `json:"ranges"`
+	} `json:"affected"`
+}
+
+// severity prefers the textual severity (GHSA style) and falls back to the raw CVSS vector.
+func (e *osvEntry) severity() string {
+	if e.DatabaseSpecific.Severity != "" {
+		return strings.ToUpper(e.DatabaseSpecific.Severity)
+	}
+	if len(e.Severity) > 0 {
+		return e.Severity[0].Score
+	}
+	return ""
+}
+
+func (e *osvEntry) fixedIn() string {
+	for _, a := range e.Affected {
+		for _, r := range a.Ranges {
+			for _, ev := range r.Events {
+				if ev.Fixed != "" {
+					return ev.Fixed
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Scan implements VulnScanner.
+func (o *OSVScanner) Scan(ctx context.Context, sourceDir string, manifests *Manifests) ([]Vulnerability, error) {
+	if len(manifests.Dependencies) == 0 {
+		return nil, nil
+	}
+
+	type osvQuery struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Version string `json:"version"`
+	}
+	queries := make([]osvQuery, len(manifests.Dependencies))
+	for i, dep := range manifests.Dependencies {
+		queries[i].Package.Name = dep.Module
+		queries[i].Package.Ecosystem = osvEcosystem(dep.Ecosystem)
+		queries[i].Version = osvVersion(dep)
+	}
+
+	var batch struct {
+		Results []struct {
+			Vulns []struct {
+				ID string `json:"id"`
+			} `json:"vulns"`
+		} `json:"results"`
+	}
+	if err := o.do(ctx, http.MethodPost, config.OSVQueryBatchURL, map[string]any{"queries": queries}, &batch); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*Vulnerability)
+	var order []string
+	for i, res := range batch.Results {
+		if i >= len(manifests.Dependencies) {
+			break
+		}
+		depID := manifests.Dependencies[i].ID()
+		for _, hit := range res.Vulns {
+			v, ok := byID[hit.ID]
+			if !ok {
+				v = &Vulnerability{ID: hit.ID}
+				var entry osvEntry
+				if err := o.do(ctx, http.MethodGet, config.OSVVulnURL+hit.ID, nil, &entry); err != nil {
+					logger.Warn("Failed to fetch OSV advisory", "id", hit.ID, "error", err)
+				} else {
+					v.Severity = entry.severity()
+					v.Summary = entry.Summary
+					v.FixedIn = entry.fixedIn()
+				}
+				byID[hit.ID] = v
+				order = append(order, hit.ID)
+			}
+			v.Affected = appendUnique(v.Affected, depID)
+		}
+	}
+
+	result := make([]Vulnerability, 0, len(order))
+	for _, id := range order {
+		result = append(result, *byID[id])
+	}
+	return result, nil
+}
+
+func (o *OSVScanner) do(ctx context.Context, method, url string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("osv request failed: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func osvEcosystem(ecosystem string) string {
+	if ecosystem == EcosystemGo {
+		return "Go"
+	}
+	return ecosystem
+}
+
+// osvVersion strips semver range operators (npm) so OSV receives a concrete version.
+func osvVersion(dep Dependency) string {
+	return strings.TrimLeft(dep.Version, "^~>=<v ")
+}
+
+func findGoModuleDirs(sourceDir string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" || d.Name() == ".git" || d.Name() == "vendor" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "go.mod" {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, existing := range list {
+		if existing == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
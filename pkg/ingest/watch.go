@@ -0,0 +1,251 @@
+package ingest
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+)
+
+// Freshness reports how current a watched project's graph is with its source tree.
+type Freshness struct {
+	Project   string    `json:"project"`
+	SourceDir string    `json:"source_dir"`
+	Watching  bool      `json:"watching"`
+	Syncing   bool      `json:"syncing"`
+	LastSync  time.Time `json:"last_sync,omitzero"`
+	Pending   []string  `json:"pending"` // Source files changed since the last sync
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// What started a sync.
+const (
+	TriggerWatch    = "watch"    // A Watcher saw files change
+	TriggerSchedule = "schedule" // A Scheduler job came due
+)
+
+// SyncReport describes a finished watch sync or scheduled ingest.
+type SyncReport struct {
+	Project       string
+	Trigger       string
+	Files         []string // Source files changed since the previous sync
+	Facts         uint64   // In the store, after the sync
+	VectorsBefore int      // Embeddings in the store before the sync
+	VectorsAfter  int
+	Duration      time.Duration
+	Err           error
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher keeps a project's graph in step with its source tree: it polls the
+// tree for changed, added and deleted files and re-ingests them incrementally
+// once they have stopped changing.
+type Watcher struct {
+	open      func() (*meb.MEBStore, func(), error)
+	project   string
+	sourceDir string
+	opts      *IngestOptions
+	interval  time.Duration
+	settle    time.Duration
+	onSync    func(SyncReport)
+
+	mu         sync.Mutex
+	stamps     map[string]fileStamp // source tree as of the last sync
+	pending    map[string]bool
+	lastChange time.Time
+	lastSync   time.Time
+	lastErr    error
+	syncing    bool
+}
+
+// NewWatcher creates a watcher re-ingesting sourceDir into project's graph in
+// the store returned by open. The store is fetched again for every sync and
+// released when the sync is done, so a server may close and reopen it in
+// between, but not during one.
+func NewWatcher(open func() (*meb.MEBStore, func(), error), project, sourceDir string, opts *IngestOptions) *Watcher {
+	return &Watcher{
+		open:      open,
+		project:   project,
+		sourceDir: sourceDir,
+		opts:      opts,
+		interval:  config.WatchPollInterval,
+		settle:    config.WatchSettleTime,
+		pending:   make(map[string]bool),
+	}
+}
+
+// Project returns the project the watcher ingests into.
+func (w *Watcher) Project() string {
+	return w.project
+}
+
+// OnSync makes the watcher call fn after every sync, successful or not, from
+// the goroutine running it. Call it before Run.
+func (w *Watcher) OnSync(fn func(SyncReport)) {
+	w.onSync = fn
+}
+
+// Run syncs the graph with the source tree, then keeps it in sync until ctx is
+// done.
+func (w *Watcher) Run(ctx context.Context) error {
+	stamps, err := w.scan()
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.stamps = stamps
+	w.mu.Unlock()
+	w.sync()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		stamps, err := w.scan()
+		if err != nil {
+			logger.Warn("Watch scan failed", "project", w.project, "error", err)
+			continue
+		}
+
+		w.mu.Lock()
+		now := time.Now()
+		for path, st := range stamps {
+			if old, ok := w.stamps[path]; !ok || old != st {
+				w.markPendingLocked(path, now)
+			}
+		}
+		for path := range w.stamps {
+			if _, ok := stamps[path]; !ok {
+				w.markPendingLocked(path, now)
+			}
+		}
+		w.stamps = stamps
+		ready := len(w.pending) > 0 && now.Sub(w.lastChange) >= w.settle
+		w.mu.Unlock()
+
+		if ready {
+			w.sync()
+		}
+	}
+}
+
+// markPendingLocked records a changed file. Callers hold w.mu.
+func (w *Watcher) markPendingLocked(path string, now time.Time) {
+	if !w.pending[path] {
+		w.pending[path] = true
+		w.lastChange = now
+	}
+}
+
+// sync runs an incremental ingest of the pending files. Changes made while it
+// runs are picked up by the next scan.
+func (w *Watcher) sync() {
+	w.mu.Lock()
+	w.syncing = true
+	synced := w.pending
+	w.pending = make(map[string]bool)
+	w.mu.Unlock()
+
+	began := time.Now()
+	report := SyncReport{Project: w.project, Trigger: TriggerWatch, Files: make([]string, 0, len(synced))}
+	for path := range synced {
+		report.Files = append(report.Files, path)
+	}
+	sort.Strings(report.Files)
+	s, release, err := w.open()
+	if err == nil {
+		defer release()
+		report.VectorsBefore = s.Vectors().Count()
+		err = RunIncrementalWithOptions(s, w.project, w.sourceDir, NewIngestState(), w.opts)
+		report.VectorsAfter = s.Vectors().Count()
+	}
+	if err == nil {
+		// The run recounted the facts as it finished
+		report.Facts = s.Count()
+	}
+	report.Duration = time.Since(began)
+	report.Err = err
+	if w.onSync != nil {
+		defer w.onSync(report)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.syncing = false
+	w.lastErr = err
+	if err != nil {
+		logger.Warn("Watch sync failed", "project", w.project, "error", err)
+		for path := range synced {
+			w.pending[path] = true
+		}
+		return
+	}
+	w.lastSync = time.Now().UTC()
+	logger.Info("Watch sync completed", "project", w.project, "files", len(synced))
+}
+
+// scan stamps the files ingest would read from the source tree.
+func (w *Watcher) scan() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	err := filepath.WalkDir(w.sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == w.sourceDir {
+				return err
+			}
+			return nil // Removed while walking
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" || d.Name() == ".git" || d.Name() == "dist" || d.Name() == "build" || d.Name() == ".next" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isSupportedFile(path) && d.Name() != "project.yaml" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		rel, _ := filepath.Rel(w.sourceDir, path)
+		stamps[filepath.ToSlash(rel)] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return stamps, err
+}
+
+// Freshness reports the last sync and the files changed since.
+func (w *Watcher) Freshness() Freshness {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f := Freshness{
+		Project:   w.project,
+		SourceDir: w.sourceDir,
+		Watching:  true,
+		Syncing:   w.syncing,
+		LastSync:  w.lastSync,
+		Pending:   make([]string, 0, len(w.pending)),
+	}
+	for path := range w.pending {
+		f.Pending = append(f.Pending, path)
+	}
+	sort.Strings(f.Pending)
+	if w.lastErr != nil {
+		f.LastError = w.lastErr.Error()
+	}
+	return f
+}
@@ -0,0 +1,89 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestWatcher(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := t.TempDir()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(src, "main.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package main\n\nfunc main() {}\n")
+
+	w := NewWatcher(func() (*meb.MEBStore, func(), error) { return s, func() {}, nil }, "app", src, &IngestOptions{SkipEmbeddings: true})
+	w.interval, w.settle = 20*time.Millisecond, 20*time.Millisecond
+	reports := make(chan SyncReport, 4)
+	w.OnSync(func(r SyncReport) { reports <- r })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := w.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s; freshness = %+v", what, w.Freshness())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	defines := func(sym string) bool {
+		for _, err := range s.ScanContext(context.Background(), "app/main.go", config.PredicateDefines, sym) {
+			return err == nil
+		}
+		return false
+	}
+
+	waitFor("initial sync", func() bool { return !w.Freshness().LastSync.IsZero() })
+	if !defines("app/main.go:main") {
+		t.Fatal("main not ingested by the initial sync")
+	}
+	first := w.Freshness().LastSync
+	if r := <-reports; r.Err != nil || r.Project != "app" || r.Facts == 0 {
+		t.Errorf("initial sync report = %+v", r)
+	}
+
+	// Make sure the new content gets a different modification time
+	time.Sleep(10 * time.Millisecond)
+	write("package main\n\nfunc main() { helper() }\n\nfunc helper() {}\n")
+	waitFor("resync", func() bool {
+		f := w.Freshness()
+		return f.LastSync.After(first) && len(f.Pending) == 0 && !f.Syncing
+	})
+	if !defines("app/main.go:helper") {
+		t.Error("helper not ingested after the file changed")
+	}
+	if r := <-reports; r.Err != nil || len(r.Files) != 1 || r.Files[0] != "main.go" {
+		t.Errorf("resync report = %+v", r)
+	}
+	if f := w.Freshness(); !f.Watching || f.Project != "app" || f.LastError != "" {
+		t.Errorf("freshness = %+v", f)
+	}
+}
@@ -0,0 +1,248 @@
+// Package jobs runs long operations in the background, such as ingests,
+// clustering and batch summaries, and keeps their status, progress and logs,
+// so that clients can follow them and cancel them.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// State is where a job is in its life.
+type State string
+
+// Job states. A job runs from the moment it is started; the other states are
+// final.
+const (
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Errors of Manager.Cancel.
+var (
+	ErrNotFound = errors.New("no such job")
+	ErrFinished = errors.New("the job has already finished")
+)
+
+// Progress is how far a job has come: done of total units of its current
+// stage, total 0 while unknown.
+type Progress struct {
+	Stage string `json:"stage,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// Job is a snapshot of a job.
+type Job struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"` // "ingest", "cluster", "summary", "compact", ...
+	Project    string    `json:"project,omitempty"`
+	State      State     `json:"state"`
+	Cancelling bool      `json:"cancelling,omitempty"` // Cancelled while running, not yet stopped
+	Progress   Progress  `json:"progress"`
+	Logs       []string  `json:"logs,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Result     any       `json:"result,omitempty"` // What the job returned, once it succeeded
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished,omitzero"`
+}
+
+// Func is the work of a job. It should return soon after ctx is cancelled,
+// with ctx's error; its result is kept in the job when it succeeds.
+type Func func(ctx context.Context, run *Run) (any, error)
+
+// Filter selects jobs to list; empty fields match every job.
+type Filter struct {
+	Kind    string
+	Project string
+	State   State
+}
+
+type job struct {
+	Job
+	cancel context.CancelFunc
+}
+
+// Manager runs jobs and keeps the running ones and the config.JobHistory last
+// finished ones.
+type Manager struct {
+	mu       sync.Mutex
+	jobs     map[string]*job
+	finished []string // IDs of the finished jobs kept, oldest first
+	wg       sync.WaitGroup
+	now      func() time.Time
+}
+
+// NewManager creates a manager with no jobs.
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string]*job), now: time.Now}
+}
+
+// Start runs fn as a new job of kind on project ("" for none) in the
+// background and returns its snapshot. The job's context is derived from ctx,
+// so pass one outliving the request starting it.
+func (m *Manager) Start(ctx context.Context, kind, project string, fn Func) Job {
+	ctx, cancel := context.WithCancel(ctx)
+	j := &job{
+		Job:    Job{ID: uuid.New().String(), Kind: kind, Project: project, State: StateRunning, Started: m.now().UTC()},
+		cancel: cancel,
+	}
+	m.mu.Lock()
+	m.jobs[j.ID] = j
+	snapshot := j.snapshot(true)
+	m.mu.Unlock()
+
+	logger.Info("Job started", "job", j.ID, "kind", kind, "project", project)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer cancel()
+		result, err := call(ctx, fn, &Run{m: m, job: j})
+		m.finish(ctx, j, result, err)
+	}()
+	return snapshot
+}
+
+// call runs fn, turning a panic into an error.
+func call(ctx context.Context, fn Func, run *Run) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx, run)
+}
+
+// finish records how j ended and drops the oldest finished jobs beyond
+// config.JobHistory.
+func (m *Manager) finish(ctx context.Context, j *job, result any, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.Finished = m.now().UTC()
+	j.Cancelling = false
+	switch {
+	case err == nil:
+		j.State = StateSucceeded
+		j.Result = result
+	case ctx.Err() != nil:
+		j.State = StateCancelled
+		j.Error = err.Error()
+	default:
+		j.State = StateFailed
+		j.Error = err.Error()
+	}
+	logger.Info("Job finished", "job", j.ID, "kind", j.Kind, "project", j.Project, "state", j.State, "took", j.Finished.Sub(j.Started))
+
+	m.finished = append(m.finished, j.ID)
+	for len(m.finished) > config.JobHistory {
+		delete(m.jobs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+}
+
+// Get returns the job with id, logs and result included.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.snapshot(true), true
+}
+
+// List returns the jobs filter selects, newest first, without their logs and
+// results; Get has them.
+func (m *Manager) List(filter Filter) []Job {
+	m.mu.Lock()
+	out := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if (filter.Kind == "" || j.Kind == filter.Kind) &&
+			(filter.Project == "" || j.Project == filter.Project) &&
+			(filter.State == "" || j.State == filter.State) {
+			out = append(out, j.snapshot(false))
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, k int) bool {
+		if !out[i].Started.Equal(out[k].Started) {
+			return out[i].Started.After(out[k].Started)
+		}
+		return out[i].ID < out[k].ID
+	})
+	return out
+}
+
+// Cancel cancels the running job with id. The job stops when its function
+// returns, and is then reported cancelled.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if j.State != StateRunning {
+		return j.snapshot(true), fmt.Errorf("%w: %s", ErrFinished, id)
+	}
+	j.cancel()
+	j.Cancelling = true
+	return j.snapshot(true), nil
+}
+
+// Wait returns once no job is running.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// snapshot copies j, with its logs and result when full is set. Callers hold
+// the manager's lock.
+func (j *job) snapshot(full bool) Job {
+	out := j.Job
+	out.Logs, out.Result = nil, nil
+	if full {
+		out.Logs = append([]string(nil), j.Logs...)
+		out.Result = j.Result
+	}
+	return out
+}
+
+// Run is the handle through which a job's function reports on it.
+type Run struct {
+	m   *Manager
+	job *job
+}
+
+// ID returns the job's ID.
+func (r *Run) ID() string {
+	return r.job.ID
+}
+
+// Progress records that done of total units of stage are done.
+func (r *Run) Progress(stage string, done, total int) {
+	r.m.mu.Lock()
+	r.job.Progress = Progress{Stage: stage, Done: done, Total: total}
+	r.m.mu.Unlock()
+}
+
+// Logf adds a line to the job's log, dropping the oldest beyond
+// config.JobLogLines.
+func (r *Run) Logf(format string, args ...any) {
+	line := r.m.now().UTC().Format(time.RFC3339) + " " + fmt.Sprintf(format, args...)
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.job.Logs = append(r.job.Logs, line)
+	if n := len(r.job.Logs) - config.JobLogLines; n > 0 {
+		r.job.Logs = append(r.job.Logs[:0], r.job.Logs[n:]...)
+	}
+}
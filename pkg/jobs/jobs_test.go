@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager()
+	ctx := context.Background()
+
+	done := m.Start(ctx, "summary", "app", func(ctx context.Context, run *Run) (any, error) {
+		run.Progress("files", 2, 2)
+		run.Logf("summarized %d files", 2)
+		return map[string]int{"written": 2}, nil
+	})
+	failed := m.Start(ctx, "cluster", "app", func(ctx context.Context, run *Run) (any, error) {
+		return nil, errors.New("no nodes")
+	})
+	panicked := m.Start(ctx, "compact", "lib", func(ctx context.Context, run *Run) (any, error) {
+		panic("boom")
+	})
+	started := make(chan struct{})
+	blocked := m.Start(ctx, "ingest", "app", func(ctx context.Context, run *Run) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, StateRunning, blocked.State)
+	<-started
+
+	job, err := m.Cancel(blocked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, job.Cancelling)
+	m.Wait()
+
+	job, ok := m.Get(done.ID)
+	if assert.True(t, ok) {
+		assert.Equal(t, StateSucceeded, job.State)
+		assert.Equal(t, Progress{Stage: "files", Done: 2, Total: 2}, job.Progress)
+		if assert.Len(t, job.Logs, 1) {
+			assert.Contains(t, job.Logs[0], "summarized 2 files")
+		}
+		assert.Equal(t, map[string]int{"written": 2}, job.Result)
+		assert.False(t, job.Finished.IsZero())
+	}
+	job, _ = m.Get(failed.ID)
+	assert.Equal(t, StateFailed, job.State)
+	assert.Equal(t, "no nodes", job.Error)
+	job, _ = m.Get(panicked.ID)
+	assert.Equal(t, StateFailed, job.State)
+	assert.Contains(t, job.Error, "boom")
+	job, _ = m.Get(blocked.ID)
+	assert.Equal(t, StateCancelled, job.State)
+	assert.False(t, job.Cancelling)
+
+	_, err = m.Cancel(done.ID)
+	assert.True(t, errors.Is(err, ErrFinished))
+	_, err = m.Cancel("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	list := m.List(Filter{Project: "app"})
+	assert.Len(t, list, 3)
+	for _, j := range list {
+		assert.Nil(t, j.Logs, "lists leave logs out")
+		assert.Nil(t, j.Result, "lists leave results out")
+	}
+	list = m.List(Filter{Kind: "ingest", State: StateCancelled})
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, blocked.ID, list[0].ID)
+	}
+}
+
+func TestManagerHistory(t *testing.T) {
+	m := NewManager()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tick int
+	m.now = func() time.Time {
+		tick++
+		return base.Add(time.Duration(tick) * time.Second)
+	}
+	var first Job
+	for i := 0; i <= config.JobHistory; i++ {
+		j := m.Start(context.Background(), "compact", "app", func(ctx context.Context, run *Run) (any, error) {
+			for n := 0; n < config.JobLogLines+5; n++ {
+				run.Logf("line %d", n)
+			}
+			return nil, nil
+		})
+		m.Wait()
+		if i == 0 {
+			first = j
+		}
+	}
+	_, ok := m.Get(first.ID)
+	assert.False(t, ok, "the oldest finished job is dropped")
+	list := m.List(Filter{})
+	if assert.Len(t, list, config.JobHistory) {
+		assert.True(t, list[0].Started.After(list[1].Started), "newest first")
+		job, _ := m.Get(list[0].ID)
+		if assert.Len(t, job.Logs, config.JobLogLines) {
+			assert.Contains(t, job.Logs[len(job.Logs)-1], fmt.Sprintf("line %d", config.JobLogLines+4))
+		}
+	}
+}
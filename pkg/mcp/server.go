@@ -9,6 +9,7 @@ import (
 
 	"github.com/duynguyendang/gca/internal/manager"
 	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/gca/pkg/service"
 	"github.com/duynguyendang/meb"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -128,6 +129,11 @@ func Run(ctx context.Context, store *meb.MEBStore) error {
 			mcp.WithString("subject", mcp.Description("Subject filter")),
 			mcp.WithString("predicate", mcp.Description("Predicate filter")),
 			mcp.WithString("object", mcp.Description("Object filter")),
+			mcp.WithString("object_kinds", mcp.Description("Comma-separated types the object must have one of, e.g. function,method")),
+			mcp.WithNumber("min_weight", mcp.Description("Lowest fact weight; facts weigh 1 unless weighted")),
+			mcp.WithNumber("max_weight", mcp.Description("Highest fact weight")),
+			mcp.WithString("projects", mcp.Description("Comma-separated projects whose graphs the facts must belong to")),
+			mcp.WithNumber("limit", mcp.Description("Max number of facts (default 50)")),
 		),
 		ms.handleScanFacts,
 	)
@@ -200,7 +206,7 @@ func (ms *MCPServer) handleFileContent(ctx context.Context, request mcp.ReadReso
 
 	// Retrieve document
 	// DocumentID in store seems to be just the string path/ID
-	doc, err := ms.store.GetContentByKey(string(path))
+	doc, err := gcamdb.GetDocument(ms.store, string(path))
 	if err != nil {
 		return nil, fmt.Errorf("file not found: %s", path)
 	}
@@ -338,20 +344,30 @@ func (ms *MCPServer) handleScanFacts(ctx context.Context, request mcp.CallToolRe
 	p, _ := args["predicate"].(string)
 	o, _ := args["object"].(string)
 
-	var formatted []string
-	count := 0
 	maxResults := 50 // Safety limit
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		maxResults = int(l)
+	}
+	opts := gcamdb.ScanOptions{Limit: maxResults + 1} // One more tells whether it was truncated
+	if kinds, _ := args["object_kinds"].(string); kinds != "" {
+		opts.ObjectKinds = splitList(kinds)
+	}
+	if projects, _ := args["projects"].(string); projects != "" {
+		opts.Graphs = splitList(projects)
+	}
+	opts.MinWeight, _ = args["min_weight"].(float64)
+	opts.MaxWeight, _ = args["max_weight"].(float64)
 
-	for fact, err := range ms.store.Scan(s, p, o) {
+	var formatted []string
+	for fact, err := range gcamdb.ScanMatching(ctx, ms.store, s, p, o, opts) {
 		if err != nil {
 			continue // Skip errors during iteration
 		}
-		formatted = append(formatted, fmt.Sprintf("%s --[%s]--> %s", fact.Subject, fact.Predicate, fact.Object))
-		count++
-		if count >= maxResults {
+		if len(formatted) == maxResults {
 			formatted = append(formatted, "... (truncated)")
 			break
 		}
+		formatted = append(formatted, fmt.Sprintf("%s --[%s]--> %s", fact.Subject, fact.Predicate, fact.Object))
 	}
 
 	if len(formatted) == 0 {
@@ -471,3 +487,14 @@ func (ms *MCPServer) handleTraceImpactPath(ctx context.Context, request mcp.Call
 	}
 	return mcp.NewToolResultText(string(jsonBytes)), nil
 }
+
+// splitList splits a comma-separated argument, dropping blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,494 @@
+package meb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// adjacencyPrefix prefixes the adjacency snapshot document of each project.
+const adjacencyPrefix = "gca:adjacency:"
+
+// adjacencyMagic starts an encoded adjacency snapshot and names its format.
+const adjacencyMagic = "GCAADJ1\n"
+
+var errAdjacencyCorrupt = errors.New("corrupt adjacency snapshot")
+
+// Adjacency is a snapshot of a project's structural edges, the facts of
+// config.StructuralPredicates, in compressed sparse row form: nodes are
+// numbered in ID order and, per predicate, a node's neighbors are one slice of
+// a shared array. Ingest saves one per project, so graph algorithms such as
+// pathfinding and clustering reach a node's neighbors without scanning the
+// store. The snapshot is only valid while the store holds as many facts as it
+// did when the snapshot was built; see Fresh.
+type Adjacency struct {
+	Facts uint64 // Store fact count when the snapshot was built
+
+	nodes []string
+	index map[string]uint32
+	out   map[string]*csr // Subject to objects, per predicate
+	in    map[string]*csr // Object to subjects, per predicate
+}
+
+// csr holds one predicate's edges: the targets of node i are
+// targets[offsets[i]:offsets[i+1]], in ascending order.
+type csr struct {
+	offsets []uint32
+	targets []uint32
+}
+
+func (c *csr) row(i uint32) []uint32 {
+	return c.targets[c.offsets[i]:c.offsets[i+1]]
+}
+
+// newCSR lays out the edges between n nodes, dropping repeated ones.
+func newCSR(n int, edges [][2]uint32) *csr {
+	c := &csr{offsets: make([]uint32, n+1), targets: make([]uint32, len(edges))}
+	for _, e := range edges {
+		c.offsets[e[0]+1]++
+	}
+	for i := 1; i <= n; i++ {
+		c.offsets[i] += c.offsets[i-1]
+	}
+	next := slices.Clone(c.offsets[:n])
+	for _, e := range edges {
+		c.targets[next[e[0]]] = e[1]
+		next[e[0]]++
+	}
+
+	// Sort each row and squeeze out repeats
+	w := uint32(0)
+	start := uint32(0)
+	for i := 0; i < n; i++ {
+		row := c.targets[start:c.offsets[i+1]]
+		start = c.offsets[i+1]
+		slices.Sort(row)
+		c.offsets[i] = w
+		for k, t := range row {
+			if k == 0 || t != c.targets[w-1] {
+				c.targets[w] = t
+				w++
+			}
+		}
+	}
+	c.offsets[n] = w
+	c.targets = c.targets[:w]
+	return c
+}
+
+// transpose returns c with every edge reversed.
+func (c *csr) transpose(n int) *csr {
+	edges := make([][2]uint32, 0, len(c.targets))
+	for i := 0; i < n; i++ {
+		for _, t := range c.row(uint32(i)) {
+			edges = append(edges, [2]uint32{t, uint32(i)})
+		}
+	}
+	return newCSR(n, edges)
+}
+
+// newAdjacency returns a snapshot of nodes, which are sorted, with no edges.
+func newAdjacency(facts uint64, nodes []string) *Adjacency {
+	a := &Adjacency{
+		Facts: facts,
+		nodes: nodes,
+		index: make(map[string]uint32, len(nodes)),
+		out:   make(map[string]*csr),
+		in:    make(map[string]*csr),
+	}
+	for i, id := range nodes {
+		a.index[id] = uint32(i)
+	}
+	return a
+}
+
+// BuildAdjacency snapshots the structural edges visible to ctx. facts is the
+// store's fact count the snapshot is fresh at, store.Count() unless the caller
+// has just recounted.
+func BuildAdjacency(ctx context.Context, store *meb.MEBStore, facts uint64) *Adjacency {
+	type edge struct{ s, o string }
+	edges := make(map[string][]edge)
+	ids := make(map[string]bool)
+	for _, pred := range config.StructuralPredicates {
+		for fact, err := range Scan(ctx, store, "", pred, "") {
+			obj, ok := fact.Object.(string)
+			if err != nil || !ok || obj == "" || fact.Subject == "" {
+				continue
+			}
+			edges[pred] = append(edges[pred], edge{fact.Subject, obj})
+			ids[fact.Subject], ids[obj] = true, true
+		}
+	}
+
+	nodes := slices.Sorted(maps.Keys(ids))
+	a := newAdjacency(facts, nodes)
+	for pred, es := range edges {
+		pairs := make([][2]uint32, len(es))
+		for i, e := range es {
+			pairs[i] = [2]uint32{a.index[e.s], a.index[e.o]}
+		}
+		a.out[pred] = newCSR(len(nodes), pairs)
+		a.in[pred] = a.out[pred].transpose(len(nodes))
+	}
+	return a
+}
+
+// Fresh reports whether a describes store as it is now: a snapshot taken
+// before facts were added or removed is stale. A nil snapshot is never fresh.
+func (a *Adjacency) Fresh(store *meb.MEBStore) bool {
+	return a != nil && a.Facts == store.Count()
+}
+
+// Nodes returns the IDs of the nodes with an edge, sorted. Callers must not
+// modify the slice.
+func (a *Adjacency) Nodes() []string {
+	return a.nodes
+}
+
+// Predicates returns the predicates that have edges, sorted.
+func (a *Adjacency) Predicates() []string {
+	return slices.Sorted(maps.Keys(a.out))
+}
+
+// Out returns the objects of id's pred facts.
+func (a *Adjacency) Out(id, pred string) []string {
+	return a.neighbors(a.out[pred], id)
+}
+
+// In returns the subjects of the pred facts pointing to id.
+func (a *Adjacency) In(id, pred string) []string {
+	return a.neighbors(a.in[pred], id)
+}
+
+// Degree returns how many pred facts point to id and how many it has.
+func (a *Adjacency) Degree(id, pred string) (in, out int) {
+	i, ok := a.index[id]
+	if !ok {
+		return 0, 0
+	}
+	if c := a.in[pred]; c != nil {
+		in = len(c.row(i))
+	}
+	if c := a.out[pred]; c != nil {
+		out = len(c.row(i))
+	}
+	return in, out
+}
+
+// Targets returns the nodes pred facts point to, sorted.
+func (a *Adjacency) Targets(pred string) []string {
+	c := a.in[pred]
+	if c == nil {
+		return nil
+	}
+	var targets []string
+	for i, id := range a.nodes {
+		if c.offsets[i] != c.offsets[i+1] {
+			targets = append(targets, id)
+		}
+	}
+	return targets
+}
+
+func (a *Adjacency) neighbors(c *csr, id string) []string {
+	i, ok := a.index[id]
+	if c == nil || !ok {
+		return nil
+	}
+	row := c.row(i)
+	out := make([]string, len(row))
+	for k, t := range row {
+		out[k] = a.nodes[t]
+	}
+	return out
+}
+
+// Edges yields the subject and object of every pred fact, in node order.
+func (a *Adjacency) Edges(pred string) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		c := a.out[pred]
+		if c == nil {
+			return
+		}
+		for i := range a.nodes {
+			for _, t := range c.row(uint32(i)) {
+				if !yield(a.nodes[i], a.nodes[t]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// facts yields the pred facts of a for ScanMatching, see ScanOptions.Index:
+// those of object obj when it is set, else those of the objects starting with
+// opts.ObjectPrefix, in object order, when it is set, else those of the
+// subjects starting with any of opts.SubjectPrefixes, in subject order. They
+// start after the fact after when it is set.
+func (a *Adjacency) facts(pred, obj string, opts ScanOptions, after *cursorKey) iter.Seq2[meb.Fact, error] {
+	return func(yield func(meb.Fact, error) bool) {
+		byObject := obj != "" || opts.ObjectPrefix != ""
+		c := a.out[pred]
+		if byObject {
+			c = a.in[pred]
+		}
+		if c == nil {
+			return
+		}
+
+		var ranges [][2]int
+		switch {
+		case obj != "":
+			if i, ok := a.index[obj]; ok {
+				ranges = append(ranges, [2]int{int(i), int(i) + 1})
+			}
+		case byObject:
+			ranges = append(ranges, a.prefixRange(opts.ObjectPrefix))
+		case len(opts.SubjectPrefixes) > 0:
+			for _, prefix := range opts.SubjectPrefixes {
+				ranges = append(ranges, a.prefixRange(prefix))
+			}
+			ranges = mergeRanges(ranges)
+		default:
+			ranges = append(ranges, [2]int{0, len(a.nodes)})
+		}
+
+		// Seek past the fact after, by its node in the order and then its neighbor
+		start, minor := 0, ""
+		if after != nil {
+			major := after.Subject
+			minor = after.Object
+			if byObject {
+				major, minor = after.Object, after.Subject
+			}
+			start = sort.SearchStrings(a.nodes, major)
+			if start == len(a.nodes) || a.nodes[start] != major {
+				minor = ""
+			}
+		}
+
+		for _, r := range ranges {
+			for i := max(r[0], start); i < r[1]; i++ {
+				row := c.row(uint32(i))
+				if i == start && minor != "" {
+					row = row[sort.Search(len(row), func(k int) bool { return a.nodes[row[k]] > minor }):]
+				}
+				for _, t := range row {
+					fact := meb.Fact{Subject: a.nodes[i], Predicate: pred, Object: a.nodes[t]}
+					if byObject {
+						fact.Subject, fact.Object = a.nodes[t], a.nodes[i]
+					}
+					if !yield(fact, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// prefixRange returns the span of nodes starting with prefix, which being
+// sorted lie together.
+func (a *Adjacency) prefixRange(prefix string) [2]int {
+	lo := sort.SearchStrings(a.nodes, prefix)
+	hi := lo + sort.Search(len(a.nodes)-lo, func(i int) bool { return !strings.HasPrefix(a.nodes[lo+i], prefix) })
+	return [2]int{lo, hi}
+}
+
+// mergeRanges sorts spans of nodes and joins those that overlap, as the spans
+// of one prefix and a longer one do.
+func mergeRanges(ranges [][2]int) [][2]int {
+	slices.SortFunc(ranges, func(x, y [2]int) int { return x[0] - y[0] })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// MarshalBinary encodes a compactly: node IDs share their prefix with the
+// previous one, and each row of targets is delta encoded. The reverse edges
+// are rebuilt when decoding.
+func (a *Adjacency) MarshalBinary() ([]byte, error) {
+	buf := []byte(adjacencyMagic)
+	buf = binary.AppendUvarint(buf, a.Facts)
+	buf = binary.AppendUvarint(buf, uint64(len(a.nodes)))
+	prev := ""
+	for _, id := range a.nodes {
+		shared := 0
+		for shared < len(prev) && shared < len(id) && prev[shared] == id[shared] {
+			shared++
+		}
+		buf = binary.AppendUvarint(buf, uint64(shared))
+		buf = binary.AppendUvarint(buf, uint64(len(id)-shared))
+		buf = append(buf, id[shared:]...)
+		prev = id
+	}
+
+	preds := a.Predicates()
+	buf = binary.AppendUvarint(buf, uint64(len(preds)))
+	for _, pred := range preds {
+		c := a.out[pred]
+		buf = binary.AppendUvarint(buf, uint64(len(pred)))
+		buf = append(buf, pred...)
+		for i := range a.nodes {
+			buf = binary.AppendUvarint(buf, uint64(c.offsets[i+1]-c.offsets[i]))
+		}
+		for i := range a.nodes {
+			last := uint32(0)
+			for _, t := range c.row(uint32(i)) {
+				buf = binary.AppendUvarint(buf, uint64(t-last))
+				last = t
+			}
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a snapshot encoded by MarshalBinary.
+func (a *Adjacency) UnmarshalBinary(data []byte) error {
+	if len(data) < len(adjacencyMagic) || string(data[:len(adjacencyMagic)]) != adjacencyMagic {
+		return errAdjacencyCorrupt
+	}
+	r := &adjacencyReader{data: data[len(adjacencyMagic):]}
+	facts := r.uvarint()
+	n := r.count()
+	nodes := make([]string, 0, n)
+	prev := ""
+	for range n {
+		shared := r.uvarint()
+		if shared > uint64(len(prev)) {
+			r.fail()
+			shared = 0
+		}
+		id := prev[:shared] + string(r.bytes(r.uvarint()))
+		nodes = append(nodes, id)
+		prev = id
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	decoded := newAdjacency(facts, nodes)
+	for range r.count() {
+		pred := string(r.bytes(r.uvarint()))
+		c := &csr{offsets: make([]uint32, n+1)}
+		total := uint64(0)
+		for i := range n {
+			total += uint64(r.count())
+			if total > uint64(len(r.data)) {
+				r.fail()
+			}
+			c.offsets[i+1] = uint32(total)
+		}
+		if r.err != nil {
+			return r.err
+		}
+		c.targets = make([]uint32, 0, c.offsets[n])
+		for i := range n {
+			last := uint64(0)
+			for range c.offsets[i+1] - c.offsets[i] {
+				last += r.uvarint()
+				if last >= uint64(n) {
+					return errAdjacencyCorrupt
+				}
+				c.targets = append(c.targets, uint32(last))
+			}
+		}
+		decoded.out[pred] = c
+		decoded.in[pred] = c.transpose(n)
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*a = *decoded
+	return nil
+}
+
+// adjacencyReader reads an encoded snapshot, remembering the first error so
+// callers check once.
+type adjacencyReader struct {
+	data []byte
+	err  error
+}
+
+func (r *adjacencyReader) fail() {
+	if r.err == nil {
+		r.err = errAdjacencyCorrupt
+	}
+	r.data = nil
+}
+
+func (r *adjacencyReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// count reads a number of items still to come, each at least one byte long.
+func (r *adjacencyReader) count() int {
+	v := r.uvarint()
+	if v > uint64(len(r.data)) {
+		r.fail()
+		return 0
+	}
+	return int(v)
+}
+
+func (r *adjacencyReader) bytes(n uint64) []byte {
+	if n > uint64(len(r.data)) {
+		r.fail()
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// SaveAdjacency stores a as project's adjacency snapshot.
+func SaveAdjacency(store *meb.MEBStore, project string, a *Adjacency) error {
+	data, err := a.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := PutDocument(store, store.TopicID(), adjacencyPrefix+project, data, nil); err != nil {
+		return fmt.Errorf("save adjacency: %w", err)
+	}
+	return nil
+}
+
+// LoadAdjacency returns project's adjacency snapshot, or nil if it has none.
+// The snapshot may be stale; check Fresh before using it.
+func LoadAdjacency(store *meb.MEBStore, project string) (*Adjacency, error) {
+	key := adjacencyPrefix + project
+	ok, err := store.HasDocument(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, key)
+	if err != nil {
+		return nil, fmt.Errorf("load adjacency: %w", err)
+	}
+	var a Adjacency
+	if err := a.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("decode adjacency: %w", err)
+	}
+	return &a, nil
+}
@@ -0,0 +1,94 @@
+package meb
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdjacency(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(1)
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "api/server.go", Predicate: config.PredicateDefines, Object: "api/server.go:Serve"},
+		{Subject: "api/server.go", Predicate: config.PredicateDefines, Object: "api/server.go:route"},
+		{Subject: "api/server.go", Predicate: config.PredicateImports, Object: "net/http"},
+		{Subject: "api/server.go:Serve", Predicate: config.PredicateCalls, Object: "api/server.go:route"},
+		{Subject: "api/server.go:Serve", Predicate: config.PredicateCalls, Object: "api/store.go:Open"},
+		{Subject: "api/server.go:route", Predicate: config.PredicateCalls, Object: "api/store.go:Open"},
+		{Subject: "api/server.go:Serve", Predicate: config.PredicateType, Object: config.SymbolKindFunc},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	built := BuildAdjacency(context.Background(), s, s.Count())
+	assert.True(t, built.Fresh(s))
+	assert.Equal(t, []string{config.PredicateCalls, config.PredicateDefines, config.PredicateImports}, built.Predicates())
+	assert.NotContains(t, built.Nodes(), config.SymbolKindFunc, "type facts are not edges")
+
+	if err := SaveAdjacency(s, "demo", built); err != nil {
+		t.Fatal(err)
+	}
+	a, err := LoadAdjacency(s, "demo")
+	if err != nil || a == nil {
+		t.Fatalf("LoadAdjacency = %v, %v", a, err)
+	}
+	assert.Equal(t, built.Nodes(), a.Nodes())
+	assert.Equal(t, []string{"api/server.go:route", "api/store.go:Open"}, a.Out("api/server.go:Serve", config.PredicateCalls))
+	assert.Equal(t, []string{"api/server.go:Serve", "api/server.go:route"}, a.In("api/store.go:Open", config.PredicateCalls))
+	assert.Equal(t, []string{"api/server.go"}, a.In("api/server.go:Serve", config.PredicateDefines))
+	assert.Empty(t, a.Out("api/store.go:Open", config.PredicateCalls))
+	in, out := a.Degree("api/server.go:route", config.PredicateCalls)
+	assert.Equal(t, [2]int{1, 1}, [2]int{in, out})
+	assert.Empty(t, a.Out("missing", config.PredicateCalls))
+	assert.Empty(t, a.Out("api/server.go", config.PredicateEmbeds))
+
+	var edges [][2]string
+	for src, dst := range a.Edges(config.PredicateDefines) {
+		edges = append(edges, [2]string{src, dst})
+	}
+	assert.Equal(t, [][2]string{{"api/server.go", "api/server.go:Serve"}, {"api/server.go", "api/server.go:route"}}, edges)
+
+	if err := s.AddFact(meb.Fact{Subject: "api/store.go:Open", Predicate: config.PredicateCalls, Object: "db.go:Dial"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, a.Fresh(s), "a snapshot is stale once facts are added")
+
+	missing, err := LoadAdjacency(s, "other")
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestAdjacencyCorrupt(t *testing.T) {
+	a := newAdjacency(3, []string{"a", "b"})
+	a.out[config.PredicateCalls] = newCSR(2, [][2]uint32{{0, 1}, {0, 1}, {1, 0}})
+	data, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Adjacency
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"b"}, decoded.Out("a", config.PredicateCalls), "repeated edges are dropped")
+
+	for n := range len(data) {
+		var d Adjacency
+		if err := d.UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("decoding %d of %d bytes succeeded", n, len(data))
+		}
+	}
+	garbled := slices.Clone(data)
+	garbled[len(garbled)-1] = 9 // A target past the last node
+	var d Adjacency
+	assert.Error(t, d.UnmarshalBinary(garbled))
+}
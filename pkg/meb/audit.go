@@ -0,0 +1,281 @@
+package meb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+// The audit log records every Datalog, path and AI request a server executes.
+// It lives in a store of its own next to the project stores, so it can be
+// written while they are served read-only. Entries are buffered and written in
+// immutable segments of config.AuditSegmentSize entries; a head document
+// counts the segments. Nothing is ever rewritten or pruned.
+
+// Kinds of audited requests.
+const (
+	AuditDatalog = "datalog"
+	AuditPath    = "path"
+	AuditAI      = "ai"
+)
+
+const (
+	auditHeadKey          = "gca:audit:head"
+	auditSegmentKeyFormat = "gca:audit:segment:%012d"
+)
+
+// AuditEntry is one executed request.
+type AuditEntry struct {
+	ID         uint64    `json:"id"`
+	Time       time.Time `json:"time"`
+	User       string    `json:"user,omitempty"`
+	Project    string    `json:"project"`
+	Kind       string    `json:"kind"`
+	Endpoint   string    `json:"endpoint,omitempty"`
+	Query      string    `json:"query,omitempty"`      // Datalog, or the question of an AI request
+	Raw        bool      `json:"raw,omitempty"`        // Datalog answered as bindings, not a graph
+	Limit      int       `json:"limit,omitempty"`      // Datalog row limit requested
+	Source     string    `json:"source,omitempty"`     // Path search
+	Target     string    `json:"target,omitempty"`     // Path search
+	Predicates []string  `json:"predicates,omitempty"` // Path search edge whitelist
+	Direction  string    `json:"direction,omitempty"`  // Path search direction
+	Depth      int       `json:"depth,omitempty"`      // Path search depth limit
+	LatencyUS  int64     `json:"latency_us"`
+	Rows       int       `json:"rows"` // Result rows, or nodes of a graph answer
+	Error      string    `json:"error,omitempty"`
+}
+
+// AuditFilter selects audit entries. Zero fields match everything.
+type AuditFilter struct {
+	Project    string
+	User       string
+	Kind       string
+	Contains   string // Substring of the query
+	Since      time.Time
+	Until      time.Time
+	MinLatency time.Duration
+	ErrorsOnly bool
+	Before     uint64 // Only entries with a lower ID, for paging
+	Limit      int
+}
+
+// Match reports whether e is selected by f, ignoring Limit.
+func (f AuditFilter) Match(e *AuditEntry) bool {
+	switch {
+	case f.Before > 0 && e.ID >= f.Before,
+		f.Project != "" && e.Project != f.Project,
+		f.User != "" && e.User != f.User,
+		f.Kind != "" && e.Kind != f.Kind,
+		f.Contains != "" && !strings.Contains(e.Query, f.Contains),
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && !e.Time.Before(f.Until),
+		f.MinLatency > 0 && time.Duration(e.LatencyUS)*time.Microsecond < f.MinLatency,
+		f.ErrorsOnly && e.Error == "":
+		return false
+	}
+	return true
+}
+
+type auditHead struct {
+	Segments int    `json:"segments"`
+	NextID   uint64 `json:"next_id"`
+}
+
+// AuditLog appends entries to the audit store and reads them back. It is safe
+// for concurrent use. A nil AuditLog records nothing.
+type AuditLog struct {
+	store    *meb.MEBStore
+	readOnly bool
+
+	mu      sync.Mutex
+	head    auditHead
+	pending []AuditEntry
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenAuditLog opens the audit store in dir, creating it when missing. A
+// writable log flushes buffered entries every config.AuditFlushInterval until
+// Close.
+func OpenAuditLog(dir string, readOnly bool) (*AuditLog, error) {
+	cfg := store.DefaultConfig(dir)
+	cfg.ReadOnly = readOnly
+	cfg.BlockCacheSize = 16 << 20
+	cfg.IndexCacheSize = 16 << 20
+	cfg.Verbose = false
+	s, err := meb.NewMEBStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open audit store: %w", err)
+	}
+	l := &AuditLog{store: s, readOnly: readOnly, stop: make(chan struct{}), done: make(chan struct{})}
+	if ok, err := s.HasDocument(auditHeadKey); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("read audit head: %w", err)
+	} else if ok {
+		data, err := GetDocument(s, auditHeadKey)
+		if err == nil {
+			err = json.Unmarshal(data, &l.head)
+		}
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("read audit head: %w", err)
+		}
+	}
+	if l.head.NextID == 0 {
+		l.head.NextID = 1
+	}
+	if readOnly {
+		close(l.done)
+	} else {
+		go l.flushLoop()
+	}
+	return l, nil
+}
+
+func (l *AuditLog) flushLoop() {
+	defer close(l.done)
+	ticker := time.NewTicker(config.AuditFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.Flush(); err != nil {
+				logger.Warn("Audit log flush failed", "error", err)
+			}
+		}
+	}
+}
+
+// Append records e, stamping it with the next ID and, when unset, the current
+// time. Entries reach the store on the next flush.
+func (l *AuditLog) Append(e AuditEntry) {
+	if l == nil || l.readOnly {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	l.mu.Lock()
+	e.ID = l.head.NextID
+	l.head.NextID++
+	l.pending = append(l.pending, e)
+	full := len(l.pending) >= config.AuditSegmentSize
+	l.mu.Unlock()
+	if full {
+		if err := l.Flush(); err != nil {
+			logger.Warn("Audit log flush failed", "error", err)
+		}
+	}
+}
+
+// Flush writes the buffered entries as new segments.
+func (l *AuditLog) Flush() error {
+	if l == nil || l.readOnly {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.pending) > 0 {
+		n := min(len(l.pending), config.AuditSegmentSize)
+		data, err := json.Marshal(l.pending[:n])
+		if err != nil {
+			return fmt.Errorf("encode audit segment: %w", err)
+		}
+		key := fmt.Sprintf(auditSegmentKeyFormat, l.head.Segments)
+		if err := PutDocument(l.store, l.store.TopicID(), key, data, nil); err != nil {
+			return fmt.Errorf("write audit segment: %w", err)
+		}
+		head := l.head
+		head.Segments++
+		data, err = json.Marshal(head)
+		if err != nil {
+			return fmt.Errorf("encode audit head: %w", err)
+		}
+		if err := PutDocument(l.store, l.store.TopicID(), auditHeadKey, data, nil); err != nil {
+			return fmt.Errorf("write audit head: %w", err)
+		}
+		l.head.Segments = head.Segments
+		l.pending = l.pending[n:]
+	}
+	return nil
+}
+
+// Entries returns the entries matching f, newest first, flushed or not.
+func (l *AuditLog) Entries(f AuditFilter) ([]AuditEntry, error) {
+	if l == nil {
+		return nil, nil
+	}
+	if f.Limit <= 0 {
+		f.Limit = config.AuditDefaultLimit
+	}
+	l.mu.Lock()
+	pending := append([]AuditEntry(nil), l.pending...)
+	segments := l.head.Segments
+	l.mu.Unlock()
+
+	var out []AuditEntry
+	collect := func(entries []AuditEntry) bool {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if f.Match(&entries[i]) {
+				out = append(out, entries[i])
+				if len(out) >= f.Limit {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if !collect(pending) {
+		return out, nil
+	}
+	for seg := segments - 1; seg >= 0; seg-- {
+		data, err := GetDocument(l.store, fmt.Sprintf(auditSegmentKeyFormat, seg))
+		if err != nil {
+			return nil, fmt.Errorf("read audit segment %d: %w", seg, err)
+		}
+		var entries []AuditEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("decode audit segment %d: %w", seg, err)
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		if f.Before > 0 && entries[0].ID >= f.Before {
+			continue // Newer than the page asked for
+		}
+		if !collect(entries) {
+			break
+		}
+		// Segments are in time order: older ones can't match once this one starts before Since
+		if !f.Since.IsZero() && entries[0].Time.Before(f.Since) {
+			break
+		}
+	}
+	return out, nil
+}
+
+// Close flushes the buffered entries and closes the audit store.
+func (l *AuditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	if !l.readOnly {
+		close(l.stop)
+		<-l.done
+	}
+	err := l.Flush()
+	if cerr := l.store.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
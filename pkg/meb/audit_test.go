@@ -0,0 +1,82 @@
+package meb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	log, err := OpenAuditLog(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC()
+	total := config.AuditSegmentSize + 3 // One full segment, flushed by Append, and some pending
+	for i := 0; i < total; i++ {
+		e := AuditEntry{Project: "alpha", Kind: AuditDatalog, Query: "triples(S, P, O)", LatencyUS: 100}
+		switch {
+		case i == total-1:
+			e.Kind, e.Query, e.Project = AuditAI, "who calls main?", "beta"
+		case i%100 == 0:
+			e.LatencyUS, e.Error = 5000, "timeout"
+		}
+		log.Append(e)
+	}
+
+	entries, err := log.Entries(AuditFilter{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != uint64(total) || entries[1].ID != uint64(total-1) {
+		t.Fatalf("newest entries = %+v, want IDs %d and %d", entries, total, total-1)
+	}
+	if entries[0].Time.Before(start.Add(-time.Second)) {
+		t.Errorf("entry time = %v, want stamped at append", entries[0].Time)
+	}
+
+	// Paging with Before crosses from pending entries into the flushed segment
+	older, err := log.Entries(AuditFilter{Before: entries[1].ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(older) != 10 || older[0].ID != entries[1].ID-1 {
+		t.Errorf("page before %d = %d entries starting at %d", entries[1].ID, len(older), older[0].ID)
+	}
+
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything survives a reopen, read-only
+	log, err = OpenAuditLog(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	log.Append(AuditEntry{Project: "ignored"})
+
+	for name, tc := range map[string]struct {
+		filter AuditFilter
+		want   int
+	}{
+		"all":         {AuditFilter{Limit: total * 2}, total},
+		"project":     {AuditFilter{Project: "beta", Limit: 10}, 1},
+		"kind":        {AuditFilter{Kind: AuditAI, Limit: 10}, 1},
+		"contains":    {AuditFilter{Contains: "calls main", Limit: 10}, 1},
+		"errors":      {AuditFilter{ErrorsOnly: true, Limit: 100}, (total + 99) / 100},
+		"min latency": {AuditFilter{MinLatency: time.Millisecond, Limit: 100}, (total + 99) / 100},
+		"until":       {AuditFilter{Until: start.Add(-time.Hour), Limit: 10}, 0},
+		"limit":       {AuditFilter{Limit: 7}, 7},
+	} {
+		got, err := log.Entries(tc.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != tc.want {
+			t.Errorf("%s: %d entries, want %d", name, len(got), tc.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package meb
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// ErrNestedBatch is returned by BeginBatch when the store already has an open batch.
+var ErrNestedBatch = errors.New("store already has an open write batch")
+
+// ErrBatchClosed is returned when a batch is used after Commit or Discard.
+var ErrBatchClosed = errors.New("write batch is closed")
+
+// openBatches tracks the stores that currently have an open Batch.
+var openBatches sync.Map // map[*meb.MEBStore]*Batch
+
+// Batch coalesces many small fact writes into a few large AddFactBatch calls.
+// It is safe for concurrent use; each flush is a single store write.
+type Batch struct {
+	store   *meb.MEBStore
+	maxSize int
+
+	mu      sync.Mutex
+	pending []meb.Fact
+	flushes int
+	closed  bool
+}
+
+// BeginBatch opens a write batch on store. Only one batch may be open per store
+// at a time; opening a second one returns ErrNestedBatch.
+func BeginBatch(store *meb.MEBStore) (*Batch, error) {
+	b := &Batch{store: store, maxSize: config.WriteBatchMaxFacts}
+	if _, loaded := openBatches.LoadOrStore(store, b); loaded {
+		return nil, ErrNestedBatch
+	}
+	return b, nil
+}
+
+// Add buffers facts and flushes once the buffer reaches the batch size.
+// It reports whether a flush happened.
+func (b *Batch) Add(facts ...meb.Fact) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false, ErrBatchClosed
+	}
+	b.pending = append(b.pending, facts...)
+	if len(b.pending) < b.maxSize {
+		return false, nil
+	}
+	return true, b.flushLocked()
+}
+
+// Flush writes any buffered facts without closing the batch.
+func (b *Batch) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrBatchClosed
+	}
+	return b.flushLocked()
+}
+
+// Commit writes the remaining buffered facts and closes the batch.
+func (b *Batch) Commit() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrBatchClosed
+	}
+	err := b.flushLocked()
+	b.closeLocked()
+	return err
+}
+
+// Discard drops the buffered facts and closes the batch. Facts already flushed
+// stay in the store. Discarding a closed batch is a no-op.
+func (b *Batch) Discard() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.pending = nil
+		b.closeLocked()
+	}
+}
+
+// Flushes returns the number of store writes the batch has issued.
+func (b *Batch) Flushes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushes
+}
+
+func (b *Batch) flushLocked() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	facts := b.pending
+	b.pending = nil
+	b.flushes++
+	return b.store.AddFactBatch(facts)
+}
+
+func (b *Batch) closeLocked() {
+	b.closed = true
+	openBatches.CompareAndDelete(b.store, b)
+}
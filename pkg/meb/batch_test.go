@@ -0,0 +1,61 @@
+package meb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+)
+
+func TestBatchCoalescesConcurrentWrites(t *testing.T) {
+	s := newQueryTestStore(t)
+	before := s.Count()
+
+	b, err := BeginBatch(s)
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	if _, err := BeginBatch(s); !errors.Is(err, ErrNestedBatch) {
+		t.Fatalf("nested BeginBatch error = %v, want ErrNestedBatch", err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				fact := meb.Fact{Subject: fmt.Sprintf("pkg/batch/w%d.go", w), Predicate: "batch_test_defines", Object: fmt.Sprintf("Sym%d", i)}
+				if _, err := b.Add(fact); err != nil {
+					t.Errorf("Add: %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if s.Count() != before {
+		t.Errorf("Count() = %d before Commit, want %d", s.Count(), before)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if got := s.Count() - before; got != 20 {
+		t.Errorf("Commit wrote %d facts, want 20", got)
+	}
+	if b.Flushes() != 1 {
+		t.Errorf("Flushes() = %d, want 1", b.Flushes())
+	}
+	if _, err := b.Add(meb.Fact{Subject: "x", Predicate: "y", Object: "z"}); !errors.Is(err, ErrBatchClosed) {
+		t.Errorf("Add after Commit error = %v, want ErrBatchClosed", err)
+	}
+
+	// The store accepts a new batch once the previous one is closed
+	next, err := BeginBatch(s)
+	if err != nil {
+		t.Fatalf("BeginBatch after Commit: %v", err)
+	}
+	next.Discard()
+}
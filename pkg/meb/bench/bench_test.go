@@ -0,0 +1,183 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	if h.Count() != 10000 {
+		t.Fatalf("count = %d, want 10000", h.Count())
+	}
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 5000 * time.Microsecond},
+		{90, 9000 * time.Microsecond},
+		{99, 9900 * time.Microsecond},
+		{100, 10000 * time.Microsecond},
+	} {
+		got := h.Percentile(tt.p)
+		if diff := (got - tt.want).Abs(); float64(diff) > float64(tt.want)*0.001 {
+			t.Errorf("p%v = %v, want %v within 0.1%%", tt.p, got, tt.want)
+		}
+	}
+	if h.Min() != time.Microsecond || h.Max() != 10000*time.Microsecond {
+		t.Errorf("min/max = %v/%v", h.Min(), h.Max())
+	}
+	if mean := h.Mean(); mean != 5000500*time.Nanosecond {
+		t.Errorf("mean = %v, want 5.0005ms", mean)
+	}
+
+	// Small values are exact
+	small := NewHistogram()
+	for _, v := range []time.Duration{3, 1, 2} {
+		small.Record(v)
+	}
+	if got := small.Percentile(50); got != 2 {
+		t.Errorf("small p50 = %v, want 2ns", got)
+	}
+
+	merged := NewHistogram()
+	merged.Merge(small)
+	merged.Merge(h)
+	if merged.Count() != 10003 || merged.Min() != 1 || merged.Max() != h.Max() {
+		t.Errorf("merged count/min/max = %d/%v/%v", merged.Count(), merged.Min(), merged.Max())
+	}
+}
+
+func TestRun(t *testing.T) {
+	var calls atomic.Int64
+	res, err := Run(context.Background(), "fixed", Config{Workers: 4, Requests: 100, Warmup: 2}, func(ctx context.Context, worker int) error {
+		if calls.Add(1)%10 == 0 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 100 || res.Workers != 4 {
+		t.Errorf("requests/workers = %d/%d, want 100/4", res.Requests, res.Workers)
+	}
+	// Calls 1-8 are warmup; of the measured calls 9-108, the ten multiples of 10 fail
+	if res.Errors != 10 || res.FirstError == nil {
+		t.Errorf("errors = %d (%v), want 10", res.Errors, res.FirstError)
+	}
+
+	res, err = Run(context.Background(), "timed", Config{Workers: 2, Duration: 20 * time.Millisecond}, func(ctx context.Context, worker int) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests == 0 || res.Elapsed < 20*time.Millisecond || res.Errors != 0 {
+		t.Errorf("timed run = %d requests in %v, %d errors", res.Requests, res.Elapsed, res.Errors)
+	}
+
+	if _, err := Run(context.Background(), "unbounded", Config{}, nil); err == nil {
+		t.Error("Run without requests or duration succeeded")
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	h := NewHistogram()
+	h.Record(2 * time.Millisecond)
+	results := []*Result{{Name: "lookup", Workers: 2, Requests: 1, Elapsed: time.Second, Latency: h}}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != strings.Join(csvHeader, ",") {
+		t.Fatalf("csv = %q", buf.String())
+	}
+	if want := "lookup,2,1,0,1000.00,1.00,2000.00,2000.00,0.00,2000.00,2000.00,2000.00,2000.00,2000.00"; lines[1] != want {
+		t.Errorf("csv row = %q, want %q", lines[1], want)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"p99_us": 2000`) {
+		t.Errorf("json = %s", buf.String())
+	}
+}
+
+func TestQueryLogReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf, 1)
+	for _, r := range []QueryRecord{
+		{Kind: QueryDatalog, Project: "p", Query: `triples(?a, "calls", ?b)`},
+		{Kind: QueryKeyword, Project: "p", Tokens: []string{"open", "store"}, K: 10},
+		{Kind: QueryVector, Project: "p", Vector: []float32{0.5, -1}, K: 5},
+	} {
+		if !rec.Sampled() {
+			t.Fatal("rate 1 skipped a query")
+		}
+		if err := rec.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rec.Count() != 3 {
+		t.Errorf("count = %d, want 3", rec.Count())
+	}
+	if (*Recorder)(nil).Sampled() || NewRecorder(&buf, 0).Sampled() {
+		t.Error("nil or zero-rate recorder sampled a query")
+	}
+
+	records, err := ReadQueryLog(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[2].Vector[1] != -1 || records[1].Tokens[1] != "store" {
+		t.Fatalf("records = %+v", records)
+	}
+
+	var seen atomic.Int64
+	exec := func(ctx context.Context, r QueryRecord) error {
+		seen.Add(1)
+		if r.Kind == QueryVector {
+			return errors.New("no vectors")
+		}
+		return nil
+	}
+	// Paced replays go through the log once, whatever the request limit
+	results, err := Replay(context.Background(), ReplayConfig{Config: Config{Workers: 2, Requests: 100}, Speed: 100}, records, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.Name
+	}
+	if got := strings.Join(names, ","); got != "datalog,keyword,vector,total" {
+		t.Errorf("results = %s", got)
+	}
+	total := results[len(results)-1]
+	if seen.Load() != 3 || total.Requests != 3 || total.Errors != 1 {
+		t.Errorf("paced replay ran %d queries, total %d requests, %d errors", seen.Load(), total.Requests, total.Errors)
+	}
+
+	// Back-to-back replays cycle through the log
+	results, err = Replay(context.Background(), ReplayConfig{Config: Config{Workers: 2, Requests: 30}}, records, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := results[len(results)-1]; total.Requests != 30 || results[0].Requests != 10 {
+		t.Errorf("replay total = %d, datalog = %d, want 30 and 10", total.Requests, results[0].Requests)
+	}
+}
@@ -0,0 +1,139 @@
+// Package bench measures store performance: HDR-style latency histograms,
+// concurrent load generation and CSV/JSON reports that can be compared
+// across releases.
+package bench
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the histogram's resolution: 2^11 sub-buckets per power of
+// two keep every recorded value within 0.1% (three significant digits).
+const (
+	subBucketBits  = 11
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+	subBucketMask  = subBucketCount - 1
+)
+
+// Histogram records latencies in log-linear buckets, in the manner of an HDR
+// histogram: constant memory per order of magnitude and three significant
+// digits at any scale. A Histogram is not safe for concurrent use; give each
+// worker its own and Merge them.
+type Histogram struct {
+	counts []int64
+	count  int64
+	min    int64
+	max    int64
+	sum    float64
+	sumSq  float64
+}
+
+// NewHistogram returns an empty histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{min: math.MaxInt64}
+}
+
+// bucketIndex maps a value in nanoseconds to its counts slot.
+func bucketIndex(v int64) int {
+	exp := bits.Len64(uint64(v)|subBucketMask) - subBucketBits
+	return exp*subBucketHalf + int(uint64(v)>>exp)
+}
+
+// bucketValue is the highest value counted in slot i.
+func bucketValue(i int) int64 {
+	exp, sub := 0, i
+	if i >= subBucketCount {
+		exp = (i-subBucketCount)/subBucketHalf + 1
+		sub = (i-subBucketCount)%subBucketHalf + subBucketHalf
+	}
+	return int64(sub+1)<<exp - 1
+}
+
+// Record adds one latency. Negative durations count as zero.
+func (h *Histogram) Record(d time.Duration) {
+	v := max(int64(d), 0)
+	i := bucketIndex(v)
+	if i >= len(h.counts) {
+		grown := make([]int64, i+subBucketHalf)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	h.counts[i]++
+	h.count++
+	h.min = min(h.min, v)
+	h.max = max(h.max, v)
+	h.sum += float64(v)
+	h.sumSq += float64(v) * float64(v)
+}
+
+// Merge adds the latencies recorded by other.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		grown := make([]int64, len(other.counts))
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.count += other.count
+	h.min = min(h.min, other.min)
+	h.max = max(h.max, other.max)
+	h.sum += other.sum
+	h.sumSq += other.sumSq
+}
+
+// Count returns the number of latencies recorded.
+func (h *Histogram) Count() int64 { return h.count }
+
+// Min returns the lowest latency recorded, or zero when empty.
+func (h *Histogram) Min() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.min)
+}
+
+// Max returns the highest latency recorded.
+func (h *Histogram) Max() time.Duration { return time.Duration(h.max) }
+
+// Mean returns the exact mean latency.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / float64(h.count))
+}
+
+// StdDev returns the standard deviation of the latencies.
+func (h *Histogram) StdDev() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	mean := h.sum / float64(h.count)
+	return time.Duration(math.Sqrt(max(h.sumSq/float64(h.count)-mean*mean, 0)))
+}
+
+// Percentile returns the latency at or below which p percent (0-100) of the
+// recorded latencies fall, accurate to three significant digits and never
+// above Max.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	p = min(max(p, 0), 100)
+	rank := max(int64(math.Ceil(p/100*float64(h.count))), 1)
+	var seen int64
+	for i, n := range h.counts {
+		if seen += n; seen >= rank {
+			return time.Duration(min(bucketValue(i), h.max))
+		}
+	}
+	return time.Duration(h.max)
+}
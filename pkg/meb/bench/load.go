@@ -0,0 +1,152 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Op is one request of a workload. worker identifies the calling goroutine, so
+// an Op can keep per-worker state such as a random source without locking.
+type Op func(ctx context.Context, worker int) error
+
+// Config controls a load run. A run ends when Requests operations have been
+// issued, when Duration has passed, or when the context is done, whichever
+// comes first; at least one of Requests and Duration must be set.
+type Config struct {
+	Workers  int           // Concurrent goroutines issuing operations (default 1)
+	Requests int64         // Total operations across workers; 0 means no limit
+	Duration time.Duration // Wall-clock limit; 0 means no limit
+	Warmup   int64         // Operations per worker run before measuring
+}
+
+// Result is the outcome of a load run.
+type Result struct {
+	Name       string
+	Workers    int
+	Requests   int64 // Operations measured, including failed ones
+	Errors     int64
+	FirstError error
+	Elapsed    time.Duration
+	Latency    *Histogram
+}
+
+// Throughput returns the measured operations per second.
+func (r *Result) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// Run drives op from cfg.Workers goroutines and records the latency of every
+// operation. Failed operations are counted and their latencies recorded too.
+func Run(ctx context.Context, name string, cfg Config, op Op) (*Result, error) {
+	results, err := run(ctx, cfg, func(ctx context.Context, worker int) (string, func() error, bool) {
+		return name, func() error { return op(ctx, worker) }, true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res, ok := results[name]; ok {
+		return res, nil
+	}
+	return &Result{Name: name, Workers: max(cfg.Workers, 1), Latency: NewHistogram()}, nil
+}
+
+// nextOp picks a worker's next operation, returning the result it counts
+// toward and the call to time. Time spent in nextOp itself, such as waiting for
+// a scheduled start, is not measured. ok false stops the worker.
+type nextOp func(ctx context.Context, worker int) (label string, call func() error, ok bool)
+
+// run drives the operations picked by next like Run and returns one result
+// per label.
+func run(ctx context.Context, cfg Config, next nextOp) (map[string]*Result, error) {
+	if cfg.Requests <= 0 && cfg.Duration <= 0 {
+		return nil, errors.New("bench: a request count or a duration is required")
+	}
+	workers := max(cfg.Workers, 1)
+
+	for w := 0; w < workers; w++ {
+		for i := int64(0); i < cfg.Warmup; i++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if _, call, ok := next(ctx, w); ok {
+				_ = call()
+			}
+		}
+	}
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	var issued atomic.Int64
+	perWorker := make([]map[string]*Result, workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		results := make(map[string]*Result)
+		perWorker[w] = results
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if cfg.Requests > 0 && issued.Add(1) > cfg.Requests {
+					return
+				}
+				label, call, ok := next(ctx, worker)
+				if !ok || ctx.Err() != nil {
+					return
+				}
+				began := time.Now()
+				err := call()
+				elapsed := time.Since(began)
+				// Operations cut short by the end of the run are not measured
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+				res := results[label]
+				if res == nil {
+					res = &Result{Name: label, Workers: workers, Latency: NewHistogram()}
+					results[label] = res
+				}
+				res.Latency.Record(elapsed)
+				if err != nil {
+					res.Errors++
+					if res.FirstError == nil {
+						res.FirstError = fmt.Errorf("worker %d: %w", worker, err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	merged := make(map[string]*Result)
+	for _, results := range perWorker {
+		for label, r := range results {
+			m := merged[label]
+			if m == nil {
+				m = &Result{Name: label, Workers: workers, Elapsed: elapsed, Latency: NewHistogram()}
+				merged[label] = m
+			}
+			m.Latency.Merge(r.Latency)
+			m.Errors += r.Errors
+			if m.FirstError == nil {
+				m.FirstError = r.FirstError
+			}
+		}
+	}
+	for _, m := range merged {
+		m.Requests = m.Latency.Count()
+	}
+	return merged, nil
+}
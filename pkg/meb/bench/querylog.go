@@ -0,0 +1,178 @@
+package bench
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kinds of recorded queries.
+const (
+	QueryDatalog = "datalog"
+	QueryVector  = "vector"
+	QueryKeyword = "keyword"
+	QueryPath    = "path"
+)
+
+// QueryRecord is one query served in production, as written to a query log.
+// Records are anonymized: they carry no client address, headers or request
+// IDs, times are offsets from the start of the recording, and search text is
+// never kept; vector searches record the query embedding and keyword searches
+// their normalized tokens.
+type QueryRecord struct {
+	Offset     time.Duration `json:"offset"` // Since the recording started
+	Kind       string        `json:"kind"`
+	Project    string        `json:"project"`
+	Query      string        `json:"query,omitempty"`      // Datalog
+	Raw        bool          `json:"raw,omitempty"`        // Datalog answered as bindings, not a graph
+	Limit      int           `json:"limit,omitempty"`      // Datalog row limit requested
+	Tokens     []string      `json:"tokens,omitempty"`     // Keyword search
+	Vector     []float32     `json:"vector,omitempty"`     // Vector search
+	K          int           `json:"k,omitempty"`          // Search result count
+	Source     string        `json:"source,omitempty"`     // Path search
+	Target     string        `json:"target,omitempty"`     // Path search
+	Predicates []string      `json:"predicates,omitempty"` // Path search edge whitelist
+	Direction  string        `json:"direction,omitempty"`  // Path search direction
+	Depth      int           `json:"depth,omitempty"`      // Path search depth limit
+	LatencyUS  int64         `json:"latency_us"`           // Served latency
+	Error      bool          `json:"error,omitempty"`
+}
+
+// Recorder appends a sample of served queries to a log, one JSON record per
+// line. A nil Recorder records nothing. It is safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	rate  float64
+	start time.Time
+	count atomic.Int64
+}
+
+// NewRecorder returns a recorder writing to w that keeps each query with
+// probability rate (0-1].
+func NewRecorder(w io.Writer, rate float64) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), rate: min(max(rate, 0), 1), start: time.Now()}
+}
+
+// Sampled reports whether the next query should be recorded. Callers check it
+// before building an expensive record.
+func (r *Recorder) Sampled() bool {
+	return r != nil && r.rate > 0 && (r.rate >= 1 || rand.Float64() < r.rate)
+}
+
+// Record writes rec, stamped with its offset from the start of the recording.
+func (r *Recorder) Record(rec QueryRecord) error {
+	if r == nil {
+		return nil
+	}
+	rec.Offset = time.Since(r.start)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(rec); err != nil {
+		return fmt.Errorf("record query: %w", err)
+	}
+	r.count.Add(1)
+	return nil
+}
+
+// Count returns the number of queries recorded.
+func (r *Recorder) Count() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.count.Load()
+}
+
+// ReadQueryLog reads the records of a query log, sorted by offset.
+func ReadQueryLog(rd io.Reader) ([]QueryRecord, error) {
+	var records []QueryRecord
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 64<<10), 16<<20) // Vector records are long lines
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec QueryRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Offset < records[j].Offset })
+	return records, nil
+}
+
+// ReplayConfig controls a replay. With Speed 0 records are replayed back to
+// back, cycling through the log until the run's limits; otherwise each record
+// starts at its recorded offset divided by Speed (2 replays twice as fast as
+// recorded), the log is replayed once and Warmup is ignored.
+type ReplayConfig struct {
+	Config
+	Speed float64
+}
+
+// Replay runs the recorded queries through exec and returns one result per
+// query kind, sorted by kind, followed by a "total" result over all of them.
+func Replay(ctx context.Context, cfg ReplayConfig, records []QueryRecord, exec func(context.Context, QueryRecord) error) ([]*Result, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("bench: no queries to replay")
+	}
+	n := int64(len(records))
+	if cfg.Speed > 0 {
+		cfg.Warmup = 0
+		if cfg.Requests <= 0 || cfg.Requests > n {
+			cfg.Requests = n
+		}
+	}
+
+	var pos atomic.Int64
+	var start time.Time
+	var startOnce sync.Once
+	byKind, err := run(ctx, cfg.Config, func(ctx context.Context, worker int) (string, func() error, bool) {
+		i := pos.Add(1) - 1
+		rec := records[i%n]
+		if cfg.Speed > 0 {
+			if i >= n {
+				return "", nil, false
+			}
+			startOnce.Do(func() { start = time.Now().Add(-time.Duration(float64(records[0].Offset) / cfg.Speed)) })
+			wait := time.Until(start.Add(time.Duration(float64(rec.Offset) / cfg.Speed)))
+			if wait > 0 {
+				select {
+				case <-ctx.Done():
+					return "", nil, false
+				case <-time.After(wait):
+				}
+			}
+		}
+		return rec.Kind, func() error { return exec(ctx, rec) }, true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Result, 0, len(byKind)+1)
+	total := &Result{Name: "total", Workers: max(cfg.Workers, 1), Latency: NewHistogram()}
+	for _, r := range byKind {
+		results = append(results, r)
+		total.Latency.Merge(r.Latency)
+		total.Errors += r.Errors
+		total.Elapsed = r.Elapsed
+		if total.FirstError == nil {
+			total.FirstError = r.FirstError
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	total.Requests = total.Latency.Count()
+	return append(results, total), nil
+}
@@ -0,0 +1,122 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Row is the flat summary of a Result written to reports. Latencies are in
+// microseconds so rows from different runs compare directly.
+type Row struct {
+	Name       string  `json:"name"`
+	Workers    int     `json:"workers"`
+	Requests   int64   `json:"requests"`
+	Errors     int64   `json:"errors"`
+	ElapsedMS  float64 `json:"elapsed_ms"`
+	Throughput float64 `json:"throughput"` // Operations per second
+	MinUS      float64 `json:"min_us"`
+	MeanUS     float64 `json:"mean_us"`
+	StdDevUS   float64 `json:"stddev_us"`
+	P50US      float64 `json:"p50_us"`
+	P90US      float64 `json:"p90_us"`
+	P99US      float64 `json:"p99_us"`
+	P999US     float64 `json:"p999_us"`
+	MaxUS      float64 `json:"max_us"`
+}
+
+// csvHeader names the columns of WriteCSV, in Row field order.
+var csvHeader = []string{
+	"name", "workers", "requests", "errors", "elapsed_ms", "throughput",
+	"min_us", "mean_us", "stddev_us", "p50_us", "p90_us", "p99_us", "p999_us", "max_us",
+}
+
+// Row summarizes the result for a report.
+func (r *Result) Row() Row {
+	us := func(d time.Duration) float64 { return float64(d) / float64(time.Microsecond) }
+	h := r.Latency
+	return Row{
+		Name:       r.Name,
+		Workers:    r.Workers,
+		Requests:   r.Requests,
+		Errors:     r.Errors,
+		ElapsedMS:  float64(r.Elapsed) / float64(time.Millisecond),
+		Throughput: r.Throughput(),
+		MinUS:      us(h.Min()),
+		MeanUS:     us(h.Mean()),
+		StdDevUS:   us(h.StdDev()),
+		P50US:      us(h.Percentile(50)),
+		P90US:      us(h.Percentile(90)),
+		P99US:      us(h.Percentile(99)),
+		P999US:     us(h.Percentile(99.9)),
+		MaxUS:      us(h.Max()),
+	}
+}
+
+// WriteCSV writes one row per result under a header row.
+func WriteCSV(w io.Writer, results []*Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	for _, r := range results {
+		row := r.Row()
+		if err := cw.Write([]string{
+			row.Name, strconv.Itoa(row.Workers),
+			strconv.FormatInt(row.Requests, 10), strconv.FormatInt(row.Errors, 10),
+			f(row.ElapsedMS), f(row.Throughput),
+			f(row.MinUS), f(row.MeanUS), f(row.StdDevUS),
+			f(row.P50US), f(row.P90US), f(row.P99US), f(row.P999US), f(row.MaxUS),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the results as an indented JSON array of rows.
+func WriteJSON(w io.Writer, results []*Result) error {
+	rows := make([]Row, len(results))
+	for i, r := range results {
+		rows[i] = r.Row()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// WriteText writes the results as an aligned table for terminals.
+func WriteText(w io.Writer, results []*Result) error {
+	if _, err := fmt.Fprintf(w, "%-16s %7s %10s %7s %12s %10s %10s %10s %10s %10s\n",
+		"workload", "workers", "requests", "errors", "ops/s", "mean", "p50", "p99", "p99.9", "max"); err != nil {
+		return err
+	}
+	for _, r := range results {
+		h := r.Latency
+		if _, err := fmt.Fprintf(w, "%-16s %7d %10d %7d %12.1f %10s %10s %10s %10s %10s\n",
+			r.Name, r.Workers, r.Requests, r.Errors, r.Throughput(),
+			round(h.Mean()), round(h.Percentile(50)), round(h.Percentile(99)),
+			round(h.Percentile(99.9)), round(h.Max())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// round trims a latency to three significant digits for display.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	case d >= time.Microsecond:
+		return d.Round(10 * time.Nanosecond)
+	}
+	return d
+}
@@ -0,0 +1,86 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// BlobBackend stores content blobs outside the store, keyed by BlobKey. Blobs
+// are content-addressed, so a key is written once and never changes.
+type BlobBackend interface {
+	Name() string // e.g. "s3://bucket/prefix", for logs
+	Put(ctx context.Context, key string, blob []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Has(ctx context.Context, key string) (bool, error)
+}
+
+// ErrNoBlobBackend is returned when a document's content lives in a blob
+// backend and none is configured.
+var ErrNoBlobBackend = errors.New("document content is in a blob backend, but none is configured")
+
+var (
+	blobMu      sync.RWMutex
+	blobBackend BlobBackend
+	blobCache   *lru.Cache[string, []byte]
+)
+
+// SetBlobBackend makes documents written from now on keep their content in b,
+// leaving only a reference in the store, and lets documents written to b be
+// read back. A nil b keeps content in the store, the default.
+func SetBlobBackend(b BlobBackend) {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	blobBackend = b
+	blobCache, _ = lru.New[string, []byte](config.BlobCacheEntries)
+}
+
+// currentBlobBackend returns the configured blob backend, or nil.
+func currentBlobBackend() BlobBackend {
+	blobMu.RLock()
+	defer blobMu.RUnlock()
+	return blobBackend
+}
+
+// putRemoteBlob uploads blob under key unless the backend already holds it.
+func putRemoteBlob(b BlobBackend, key string, blob []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.BlobRequestTimeout)
+	defer cancel()
+	exists, err := b.Has(ctx, key)
+	if err != nil {
+		return fmt.Errorf("check content blob in %s: %w", b.Name(), err)
+	}
+	if exists {
+		return nil
+	}
+	if err := b.Put(ctx, key, blob); err != nil {
+		return fmt.Errorf("store content blob in %s: %w", b.Name(), err)
+	}
+	return nil
+}
+
+// getRemoteBlob downloads the blob under key, from the cache when it was
+// read before.
+func getRemoteBlob(key string) ([]byte, error) {
+	blobMu.RLock()
+	b, cache := blobBackend, blobCache
+	blobMu.RUnlock()
+	if b == nil {
+		return nil, ErrNoBlobBackend
+	}
+	if blob, ok := cache.Get(key); ok {
+		return blob, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.BlobRequestTimeout)
+	defer cancel()
+	blob, err := b.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("load content blob %s from %s: %w", key, b.Name(), err)
+	}
+	cache.Add(key, blob)
+	return blob, nil
+}
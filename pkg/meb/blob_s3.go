@@ -0,0 +1,238 @@
+package meb
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// gcsEndpoint is the S3-compatible XML API of Google Cloud Storage, reached
+// with HMAC keys.
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// S3Backend keeps blobs in an S3 bucket, or any store speaking the S3 API:
+// Google Cloud Storage through its interoperability endpoint, MinIO, R2.
+// Requests are signed with AWS Signature Version 4.
+type S3Backend struct {
+	Endpoint     string // e.g. "https://s3.us-east-1.amazonaws.com"; objects are addressed path-style
+	Region       string // "auto" for GCS
+	Bucket       string
+	Prefix       string // Prepended to blob keys, e.g. "gca/"
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // Temporary credentials only
+	Client       *http.Client
+	scheme       string
+}
+
+// ParseBlobBackend returns the blob backend a URL names, or nil for "" and
+// "local", which keep content in the store:
+//
+//	s3://bucket/prefix?region=eu-west-1          AWS, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+//	s3://bucket/prefix?endpoint=http://minio:9000 any S3-compatible store
+//	gs://bucket/prefix                           GCS, GCS_HMAC_ACCESS_KEY and GCS_HMAC_SECRET
+func ParseBlobBackend(raw string) (BlobBackend, error) {
+	if raw == "" || raw == "local" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("blob backend %q: %w", raw, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("blob backend %q: missing bucket", raw)
+	}
+	b := &S3Backend{
+		Bucket: u.Host,
+		Prefix: strings.Trim(u.Path, "/"),
+		Client: http.DefaultClient,
+		scheme: u.Scheme,
+	}
+	if b.Prefix != "" {
+		b.Prefix += "/"
+	}
+	q := u.Query()
+	switch u.Scheme {
+	case "s3":
+		b.Region = q.Get("region")
+		if b.Region == "" {
+			b.Region = os.Getenv("AWS_REGION")
+		}
+		if b.Region == "" {
+			b.Region = "us-east-1"
+		}
+		b.Endpoint = "https://s3." + b.Region + ".amazonaws.com"
+		b.AccessKey, b.SecretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		b.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	case "gs":
+		b.Region = "auto"
+		b.Endpoint = gcsEndpoint
+		b.AccessKey, b.SecretKey = os.Getenv("GCS_HMAC_ACCESS_KEY"), os.Getenv("GCS_HMAC_SECRET")
+	default:
+		return nil, fmt.Errorf("blob backend %q: unknown scheme %q (want s3 or gs)", raw, u.Scheme)
+	}
+	if endpoint := q.Get("endpoint"); endpoint != "" {
+		b.Endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+	if b.AccessKey == "" || b.SecretKey == "" {
+		return nil, fmt.Errorf("blob backend %q: missing credentials", raw)
+	}
+	return b, nil
+}
+
+// Name implements BlobBackend.
+func (b *S3Backend) Name() string {
+	scheme := b.scheme
+	if scheme == "" {
+		scheme = "s3"
+	}
+	return scheme + "://" + b.Bucket + "/" + b.Prefix
+}
+
+// Put implements BlobBackend.
+func (b *S3Backend) Put(ctx context.Context, key string, blob []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, key, blob)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Get implements BlobBackend.
+func (b *S3Backend) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Has implements BlobBackend.
+func (b *S3Backend) Has(ctx context.Context, key string) (bool, error) {
+	resp, err := b.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, responseError(resp)
+}
+
+// do sends a signed request for the object holding key.
+func (b *S3Backend) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	// Blob keys hold colons, which must reach S3 escaped as they were signed
+	path := uriEncode("/" + b.Bucket + "/" + b.Prefix + key)
+	req, err := http.NewRequestWithContext(ctx, method, b.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	b.sign(req, path, body, time.Now().UTC())
+	return b.Client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 headers to req, whose URI-encoded path
+// is path.
+func (b *S3Backend) sign(req *http.Request, path string, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("x-amz-date", now.Format("20060102T150405Z"))
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if b.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", b.SessionToken)
+		signed = append(signed, "x-amz-security-token")
+	}
+	signV4(req, path, signed, payloadHash, b.Region, "s3", b.AccessKey, b.SecretKey, now)
+}
+
+// signV4 sets the AWS Signature Version 4 Authorization header of req for
+// service in region. canonicalURI is req's path, URI-encoded; signed lists the
+// lowercase names, sorted, of the headers covered, each already set on req but
+// host. The query string is not signed, so req must have none.
+func signV4(req *http.Request, canonicalURI string, signed []string, payloadHash, region, service, accessKey, secretKey string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signed {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		"", // No query string
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// uriEncode escapes every byte of path but '/' and the RFC 3986 unreserved
+// characters, as SigV4 canonical URIs require. url.URL.EscapedPath leaves ':'
+// and the other sub-delimiters alone, which S3 would sign differently.
+func uriEncode(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// responseError reports an unexpected response, with the start of its body.
+func responseError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %s: %s %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
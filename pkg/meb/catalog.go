@@ -0,0 +1,87 @@
+package meb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/meb"
+)
+
+// Catalog is what a full scan of a store found: its facts, in all and by
+// predicate. The store's own counter is kept in memory between persists and
+// drifts after crashes; RefreshCatalog reconciles it with the catalog.
+type Catalog struct {
+	Facts      uint64            `json:"facts"`
+	Predicates map[string]uint64 `json:"predicates"` // Facts by predicate
+	Drift      int64             `json:"drift"`      // Count() less Facts before the counter was corrected
+	Refreshed  time.Time         `json:"refreshed"`
+	Took       time.Duration     `json:"took"`
+}
+
+var catalogs sync.Map // *meb.MEBStore -> Catalog
+
+// catalogPace is how many facts RefreshCatalog scans between checks of its rate.
+const catalogPace = 4096
+
+// RefreshCatalog scans every fact of store, at most rate facts a second (0 for
+// no limit), and records the catalog it builds as store's. When the store's
+// counter disagrees with the scan it is recounted, persisting the correction.
+// It stops early, with ctx's error, when ctx is done; the previous catalog is
+// then kept.
+func RefreshCatalog(ctx context.Context, store *meb.MEBStore, rate int) (Catalog, error) {
+	began := time.Now()
+	cat := Catalog{Predicates: make(map[string]uint64)}
+	for fact, err := range store.ScanContext(ctx, "", "", "") {
+		if err != nil {
+			return Catalog{}, fmt.Errorf("scan facts: %w", err)
+		}
+		cat.Facts++
+		cat.Predicates[fact.Predicate]++
+		if cat.Facts%catalogPace != 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return Catalog{}, err
+		}
+		if rate > 0 {
+			// Sleep off any lead over the rate
+			due := began.Add(time.Duration(cat.Facts) * time.Second / time.Duration(rate))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return Catalog{}, ctx.Err()
+				}
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return Catalog{}, err
+	}
+
+	if count := store.Count(); count != cat.Facts {
+		cat.Drift = int64(count) - int64(cat.Facts)
+		if _, err := store.RecalculateStats(); err != nil {
+			return Catalog{}, fmt.Errorf("recount facts: %w", err)
+		}
+	}
+	cat.Refreshed = time.Now()
+	cat.Took = cat.Refreshed.Sub(began)
+	catalogs.Store(store, cat)
+	return cat, nil
+}
+
+// CatalogOf returns store's catalog and whether RefreshCatalog built one.
+func CatalogOf(store *meb.MEBStore) (Catalog, bool) {
+	if cat, ok := catalogs.Load(store); ok {
+		return cat.(Catalog), true
+	}
+	return Catalog{}, false
+}
+
+// DropCatalog forgets store's catalog. Call it when the store closes.
+func DropCatalog(store *meb.MEBStore) {
+	catalogs.Delete(store)
+}
@@ -0,0 +1,60 @@
+package meb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshCatalog(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DropCatalog(s)
+		s.Close()
+	})
+
+	var facts []meb.Fact
+	for i := range 3 * catalogPace {
+		id := fmt.Sprintf("f%d.go:F", i)
+		facts = append(facts, meb.Fact{Subject: id, Predicate: "type", Object: "function"})
+		if i%2 == 0 {
+			facts = append(facts, meb.Fact{Subject: id, Predicate: "calls", Object: "main.go:main"})
+		}
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := CatalogOf(s)
+	assert.False(t, ok)
+
+	cat, err := RefreshCatalog(context.Background(), s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint64(len(facts)), cat.Facts)
+	assert.Equal(t, map[string]uint64{"type": 3 * catalogPace, "calls": 3 * catalogPace / 2}, cat.Predicates)
+	assert.Zero(t, cat.Drift)
+	assert.Equal(t, s.Count(), cat.Facts)
+
+	recorded, ok := CatalogOf(s)
+	assert.True(t, ok)
+	assert.Equal(t, cat, recorded)
+
+	// Scans over their rate are slowed down and give up with ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = RefreshCatalog(ctx, s, catalogPace)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// The previous catalog is kept
+	recorded, _ = CatalogOf(s)
+	assert.Equal(t, cat, recorded)
+}
@@ -0,0 +1,51 @@
+package meb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+)
+
+// The MEB store keeps its fact counter in memory and persists it on Close and
+// every few thousand facts, so a crash leaves Count() behind the data on disk.
+// A marker file records that a writer had the store open; if it is still there
+// on the next open, the previous session never reached a clean close, and the
+// next writer recounts once rather than every writer recounting as it goes.
+
+// Recover recounts the facts of the store in dir when the previous writer did
+// not shut down cleanly. It reports whether a recount was needed.
+func Recover(store *meb.MEBStore, dir string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(dir, config.DirtyMarkerFile)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("check dirty marker: %w", err)
+	}
+
+	before := store.Count()
+	count, err := store.RecalculateStats()
+	if err != nil {
+		return true, err
+	}
+	logger.Warn("Recovered fact count after unclean shutdown", "dir", dir, "before", before, "after", count)
+	return true, nil
+}
+
+// MarkDirty records that a writer has opened the store in dir.
+func MarkDirty(dir string) error {
+	return os.WriteFile(filepath.Join(dir, config.DirtyMarkerFile), nil, 0o644)
+}
+
+// MarkClean records that the store in dir was closed cleanly.
+func MarkClean(dir string) error {
+	err := os.Remove(filepath.Join(dir, config.DirtyMarkerFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/dict"
+)
+
+// compactSampleSize caps the number of orphan strings listed in a CompactReport.
+const compactSampleSize = 20
+
+// CompactReport summarizes a dictionary compaction.
+type CompactReport struct {
+	DryRun           bool     `json:"dry_run"`
+	Scanned          int      `json:"scanned"`           // Dictionary entries examined
+	Referenced       int      `json:"referenced"`        // Entries still used by a fact, document or vector
+	Orphans          int      `json:"orphans"`           // Entries with no remaining references
+	Skipped          int      `json:"skipped"`           // Unreferenced numeric strings kept for safety
+	Deleted          int      `json:"deleted"`           // Orphans actually removed (0 in dry-run mode)
+	ReclaimableBytes int64    `json:"reclaimable_bytes"` // Approximate key+value bytes held by orphans
+	Sample           []string `json:"sample,omitempty"`  // A few orphan strings, for inspection
+}
+
+// CompactDictionary drops dictionary strings that no fact, document or vector
+// references any more. Deleting facts never removes their strings, so the
+// dictionary otherwise grows with every re-ingest.
+//
+// It is a mark-and-sweep over the whole store: every fact is scanned to mark the
+// strings in use, then each dictionary entry allocated before the scan started
+// is swept. In dry-run mode nothing is deleted and the report only describes
+// what would be reclaimed. Unless it is a dry run, the caller must keep every
+// writer off the store until it returns: a string found orphaned could be
+// reused by a concurrent write, and then swept from under it.
+func CompactDictionary(ctx context.Context, store *meb.MEBStore, dryRun bool) (*CompactReport, error) {
+	// Entries allocated after this point belong to concurrent writes and are never swept
+	limit, err := dictionaryNextID(store)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := markReferencedStrings(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompactReport{DryRun: dryRun}
+	var orphans []string
+	err = store.View(func(txn *meb.StoreTxn) error {
+		for id := uint64(1); id < limit; id++ {
+			if id%1024 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			str, err := txn.GetString(id)
+			if errors.Is(err, dict.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("read dictionary entry %d: %w", id, err)
+			}
+			report.Scanned++
+
+			if _, ok := used[str]; ok || referencedOutsideFacts(store, id, str) {
+				report.Referenced++
+				continue
+			}
+			// Numeric objects come back from scans re-typed, so their original
+			// spelling cannot be matched reliably; keep them
+			if looksNumeric(str) {
+				report.Skipped++
+				continue
+			}
+
+			report.Orphans++
+			// Forward (prefix+string -> id) and reverse (prefix+id -> string) entries
+			report.ReclaimableBytes += int64(2 * (len(str) + 9 + 8))
+			if len(report.Sample) < compactSampleSize {
+				report.Sample = append(report.Sample, str)
+			}
+			orphans = append(orphans, str)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	d := store.Dict()
+	for _, str := range orphans {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if err := d.DeleteID(str); err != nil {
+			return report, fmt.Errorf("delete dictionary entry %q: %w", str, err)
+		}
+		report.Deleted++
+	}
+	logger.Info("Dictionary compacted", "scanned", report.Scanned, "deleted", report.Deleted, "reclaimed_bytes", report.ReclaimableBytes)
+	return report, nil
+}
+
+// dictionaryNextID returns the next ID the dictionary will allocate.
+func dictionaryNextID(store *meb.MEBStore) (uint64, error) {
+	statter, ok := store.Dict().(interface{ Stats() map[string]interface{} })
+	if !ok {
+		return 0, fmt.Errorf("dictionary does not expose allocation stats")
+	}
+	next, ok := statter.Stats()["next_id"].(uint64)
+	if !ok {
+		return 0, fmt.Errorf("dictionary stats lack next_id")
+	}
+	return next, nil
+}
+
+// markReferencedStrings collects every subject, predicate and string object
+// used by a fact, across all topics.
+func markReferencedStrings(ctx context.Context, store *meb.MEBStore) (map[string]struct{}, error) {
+	used := make(map[string]struct{})
+	for fact, err := range store.ScanContext(ctx, "", "", "") {
+		if err != nil {
+			return nil, fmt.Errorf("mark referenced strings: %w", err)
+		}
+		used[fact.Subject] = struct{}{}
+		used[fact.Predicate] = struct{}{}
+		if obj, ok := fact.Object.(string); ok {
+			used[obj] = struct{}{}
+		}
+	}
+	return used, nil
+}
+
+// referencedOutsideFacts reports whether a dictionary entry keys stored content
+// or an embedding vector.
+func referencedOutsideFacts(store *meb.MEBStore, id uint64, str string) bool {
+	if store.Vectors().HasVector(id) {
+		return true
+	}
+	has, err := store.HasDocument(str)
+	// Treat lookup failures as references: never drop a string we could not check
+	return err != nil || has
+}
+
+func looksNumeric(s string) bool {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
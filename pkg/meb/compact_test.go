@@ -0,0 +1,70 @@
+package meb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+)
+
+func TestCompactDictionary(t *testing.T) {
+	s := newQueryTestStore(t)
+	ctx := context.Background()
+
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "pkg/gone/old.go", Predicate: "limit_test_defines", Object: "OrphanSymbol"},
+		{Subject: "pkg/gone/old.go", Predicate: "compact_test_only", Object: "pkg/limits/f1.go"},
+	}); err != nil {
+		t.Fatalf("AddFactBatch: %v", err)
+	}
+	if err := s.AddDocument("pkg/docs/readme.md", []byte("kept by content"), nil, nil); err != nil {
+		t.Fatalf("AddDocument: %v", err)
+	}
+	if err := s.DeleteFactsBySubject("pkg/gone/old.go"); err != nil {
+		t.Fatalf("DeleteFactsBySubject: %v", err)
+	}
+
+	report, err := CompactDictionary(ctx, s, true)
+	if err != nil {
+		t.Fatalf("CompactDictionary(dry run): %v", err)
+	}
+	want := map[string]bool{"pkg/gone/old.go": true, "OrphanSymbol": true, "compact_test_only": true}
+	if report.Orphans != len(want) || report.Deleted != 0 || report.ReclaimableBytes == 0 {
+		t.Fatalf("dry run report = %+v, want %d orphans and nothing deleted", report, len(want))
+	}
+	for _, str := range report.Sample {
+		if !want[str] {
+			t.Errorf("unexpected orphan %q", str)
+		}
+	}
+	if _, ok := s.LookupID("OrphanSymbol"); !ok {
+		t.Fatal("dry run removed a dictionary entry")
+	}
+
+	report, err = CompactDictionary(ctx, s, false)
+	if err != nil {
+		t.Fatalf("CompactDictionary: %v", err)
+	}
+	if report.Deleted != len(want) {
+		t.Errorf("Deleted = %d, want %d", report.Deleted, len(want))
+	}
+	if _, ok := s.LookupID("OrphanSymbol"); ok {
+		t.Error("orphan string still in the dictionary")
+	}
+	for _, key := range []string{"pkg/limits/f1.go", "limit_test_defines", "Sym3", "pkg/docs/readme.md"} {
+		if _, ok := s.LookupID(key); !ok {
+			t.Errorf("referenced string %q was removed", key)
+		}
+	}
+
+	n := 0
+	for _, err := range s.Scan("", "limit_test_defines", "") {
+		if err != nil {
+			t.Fatalf("Scan after compaction: %v", err)
+		}
+		n++
+	}
+	if n != 10 {
+		t.Errorf("Scan after compaction returned %d facts, want 10", n)
+	}
+}
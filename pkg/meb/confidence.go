@@ -0,0 +1,131 @@
+package meb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// Links ingest infers, such as calls from frontend code to the handlers of the
+// routes it requests, may be wrong. Each is scored by how it was inferred and
+// how much evidence backs it; facts have no attributes of their own, so the
+// evidence is kept in a ledger keyed by fact, like fact weights.
+
+// LinkEvidenceKey is the document holding the evidence of inferred links.
+const LinkEvidenceKey = "gca:link_evidence"
+
+// Methods by which links are inferred.
+const (
+	LinkMethodOpenAPI = "openapi" // A URL referenced by frontend code matched to an OpenAPI path
+	LinkMethodRoute   = "route"   // A URL referenced by frontend code equal to a backend route
+	LinkMethodNaming  = "naming"  // An implementation named after its interface (FooImpl, DefaultFoo)
+	LinkMethodManual  = "manual"  // A virtual link added through the annotations API
+)
+
+// LinkMethodPriors are the precisions assumed of each method before users
+// confirm or reject any of its links.
+var LinkMethodPriors = map[string]float64{
+	LinkMethodOpenAPI: 0.9,
+	LinkMethodRoute:   0.7,
+	LinkMethodNaming:  0.6,
+	LinkMethodManual:  0.95,
+}
+
+// LinkEvidence is how an inferred fact was found: by which method, and how
+// many times, such as the number of references to a route.
+type LinkEvidence struct {
+	Subject   string `json:"subject"`
+	Predicate string `json:"predicate"`
+	Object    string `json:"object"`
+	Method    string `json:"method"`
+	Evidence  int    `json:"evidence"`
+}
+
+// Key returns the ledger key of e's fact.
+func (e LinkEvidence) Key() string {
+	return factKey(meb.Fact{Subject: e.Subject, Predicate: e.Predicate, Object: e.Object})
+}
+
+// LinkKey returns the ledger key of the fact subj pred obj.
+func LinkKey(subj, pred, obj string) string {
+	return factKey(meb.Fact{Subject: subj, Predicate: pred, Object: obj})
+}
+
+// MethodPrecision returns the precision of a method given the links of it users
+// confirmed and rejected: its prior, worth config.LinkConfidencePriorWeight
+// verdicts, updated with theirs.
+func MethodPrecision(method string, confirmed, rejected int) float64 {
+	prior, ok := LinkMethodPriors[method]
+	if !ok {
+		prior = 0.5
+	}
+	k := float64(config.LinkConfidencePriorWeight)
+	return (prior*k + float64(confirmed)) / (k + float64(confirmed+rejected))
+}
+
+// LinkConfidence returns the confidence in a link found evidence times by a
+// method of the given precision, each time counting as an independent chance
+// of being right.
+func LinkConfidence(precision float64, evidence int) float64 {
+	if evidence < 1 {
+		evidence = 1
+	}
+	return 1 - math.Pow(1-precision, float64(evidence))
+}
+
+// LinkEvidenceFor returns the evidence of the store's inferred links, keyed by
+// LinkKey.
+func LinkEvidenceFor(store *meb.MEBStore) (map[string]LinkEvidence, error) {
+	ok, err := store.HasDocument(LinkEvidenceKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, LinkEvidenceKey)
+	if err != nil {
+		return nil, fmt.Errorf("load link evidence: %w", err)
+	}
+	var list []LinkEvidence
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode link evidence: %w", err)
+	}
+	evidence := make(map[string]LinkEvidence, len(list))
+	for _, e := range list {
+		evidence[e.Key()] = e
+	}
+	return evidence, nil
+}
+
+// RecordLinkEvidence merges evidence into the ledger, replacing what was
+// recorded of the same facts by an earlier ingest.
+func RecordLinkEvidence(store *meb.MEBStore, evidence []LinkEvidence) error {
+	if len(evidence) == 0 {
+		return nil
+	}
+	byKey, err := LinkEvidenceFor(store)
+	if err != nil {
+		return err
+	}
+	if byKey == nil {
+		byKey = make(map[string]LinkEvidence, len(evidence))
+	}
+	for _, e := range evidence {
+		byKey[e.Key()] = e
+	}
+	list := make([]LinkEvidence, 0, len(byKey))
+	for _, e := range byKey {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key() < list[j].Key() })
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := PutDocument(store, store.TopicID(), LinkEvidenceKey, data, nil); err != nil {
+		return fmt.Errorf("save link evidence: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package meb
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLinkConfidence(t *testing.T) {
+	if p := MethodPrecision(LinkMethodRoute, 0, 0); p != LinkMethodPriors[LinkMethodRoute] {
+		t.Errorf("precision without verdicts = %v, want the prior", p)
+	}
+	if up, down := MethodPrecision(LinkMethodRoute, 4, 0), MethodPrecision(LinkMethodRoute, 0, 4); up <= 0.7 || down >= 0.7 {
+		t.Errorf("precision after 4 confirmations = %v, after 4 rejections = %v", up, down)
+	}
+	if c := LinkConfidence(0.7, 2); math.Abs(c-0.91) > 1e-9 {
+		t.Errorf("LinkConfidence(0.7, 2) = %v, want 0.91", c)
+	}
+
+	s := newQueryTestStore(t)
+	if evidence, err := LinkEvidenceFor(s); err != nil || len(evidence) != 0 {
+		t.Fatalf("LinkEvidenceFor on a new store = %v, %v", evidence, err)
+	}
+	call := LinkEvidence{Subject: "web/app.ts:load", Predicate: "calls_api", Object: "/api/users", Method: LinkMethodRoute, Evidence: 1}
+	if err := RecordLinkEvidence(s, []LinkEvidence{call}); err != nil {
+		t.Fatal(err)
+	}
+	call.Evidence = 3
+	other := LinkEvidence{Subject: "web/app.ts:save", Predicate: "calls_api", Object: "/api/users", Method: LinkMethodOpenAPI, Evidence: 1}
+	if err := RecordLinkEvidence(s, []LinkEvidence{call, other}); err != nil {
+		t.Fatal(err)
+	}
+	evidence, err := LinkEvidenceFor(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evidence) != 2 || evidence[LinkKey("web/app.ts:load", "calls_api", "/api/users")].Evidence != 3 {
+		t.Errorf("evidence = %+v, want the re-recorded link replaced", evidence)
+	}
+}
@@ -0,0 +1,145 @@
+package meb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/duynguyendang/meb"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Documents written by PutDocument start with one of these headers. Content
+// without a header predates them and is returned unchanged.
+var (
+	zstdHeader   = []byte("GCAZ\x01") // zstd frame follows
+	refHeader    = []byte("GCAR\x01") // key of a shared content blob follows
+	remoteHeader = []byte("GCAX\x01") // key of a content blob in the blob backend follows
+)
+
+// BlobKeyPrefix prefixes the document keys of deduplicated content blobs.
+const BlobKeyPrefix = "gca:blob:"
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func zstdCodecs() (*zstd.Encoder, *zstd.Decoder) {
+	zstdOnce.Do(func() {
+		// Neither constructor fails without options that reject their input
+		zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		zstdDecoder, _ = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder
+}
+
+// BlobKey returns the document key under which content is stored once,
+// however many documents share it.
+func BlobKey(content []byte) string {
+	sum := sha256.Sum256(content)
+	return BlobKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// PutDocument stores a document like AddDocumentWithTopic, compressing content
+// with zstd and deduplicating it by hash: the bytes live in a shared blob and
+// the document only records the blob's key. The blob is a document of the
+// store, or an object of the blob backend when one is set. Read documents back
+// with GetDocument or DecodeContent.
+func PutDocument(store *meb.MEBStore, topicID uint32, docKey string, content []byte, metadata map[string]any) error {
+	ref, err := storeContent(store, topicID, content)
+	if err != nil {
+		return err
+	}
+	return store.AddDocumentWithTopic(topicID, docKey, ref, nil, metadata)
+}
+
+// storeContent writes content as a shared blob, in the store or the blob
+// backend, and returns the reference a document stores in its place. Empty
+// content has no blob and a nil reference.
+func storeContent(store *meb.MEBStore, topicID uint32, content []byte) ([]byte, error) {
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	blobKey := BlobKey(content)
+	if backend := currentBlobBackend(); backend != nil {
+		enc, _ := zstdCodecs()
+		blob := append(append([]byte{}, zstdHeader...), enc.EncodeAll(content, nil)...)
+		if err := putRemoteBlob(backend, blobKey, blob); err != nil {
+			return nil, err
+		}
+		return append(append([]byte{}, remoteHeader...), blobKey...), nil
+	}
+
+	exists, err := store.HasDocument(blobKey)
+	if err != nil {
+		return nil, fmt.Errorf("check content blob: %w", err)
+	}
+	if !exists {
+		enc, _ := zstdCodecs()
+		blob := append(append([]byte{}, zstdHeader...), enc.EncodeAll(content, nil)...)
+		if err := store.AddDocumentWithTopic(topicID, blobKey, blob, nil, nil); err != nil {
+			return nil, fmt.Errorf("store content blob: %w", err)
+		}
+	}
+	return append(append([]byte{}, refHeader...), blobKey...), nil
+}
+
+// GetDocument returns the content of a document, resolving shared blobs and
+// decompressing as needed, or taking them from the store's hot cache.
+func GetDocument(store *meb.MEBStore, docKey string) ([]byte, error) {
+	raw, err := store.GetContentByKey(docKey)
+	if err != nil {
+		return nil, err
+	}
+	if hc := HotCacheOf(store); hc != nil {
+		content, err := hc.DecodeContent(raw, store.GetContentByKey)
+		return bytes.Clone(content), err
+	}
+	return DecodeContent(raw, store.GetContentByKey)
+}
+
+// DecodeContent turns stored document bytes back into the original content.
+// fetch loads the raw bytes of another document and is used to follow blob
+// references, so callers inside a transaction can resolve them in the same txn.
+func DecodeContent(raw []byte, fetch func(docKey string) ([]byte, error)) ([]byte, error) {
+	if bytes.HasPrefix(raw, refHeader) {
+		blobKey := string(raw[len(refHeader):])
+		blob, err := fetch(blobKey)
+		if err != nil {
+			return nil, fmt.Errorf("load content blob %s: %w", blobKey, err)
+		}
+		raw = blob
+	} else if bytes.HasPrefix(raw, remoteHeader) {
+		blob, err := getRemoteBlob(string(raw[len(remoteHeader):]))
+		if err != nil {
+			return nil, err
+		}
+		raw = blob
+	}
+	if bytes.HasPrefix(raw, zstdHeader) {
+		_, dec := zstdCodecs()
+		content, err := dec.DecodeAll(raw[len(zstdHeader):], nil)
+		if err != nil {
+			return nil, fmt.Errorf("decompress content: %w", err)
+		}
+		return content, nil
+	}
+	return raw, nil
+}
+
+// TxnContentFetcher resolves document keys to raw content inside txn, for use
+// with DecodeContent.
+func TxnContentFetcher(txn *meb.StoreTxn) func(string) ([]byte, error) {
+	return func(docKey string) ([]byte, error) {
+		id, err := txn.GetID(docKey)
+		if err != nil {
+			return nil, err
+		}
+		return txn.GetContent(id)
+	}
+}
@@ -0,0 +1,74 @@
+package meb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+)
+
+func TestPutDocumentDedupAndCompression(t *testing.T) {
+	s := newQueryTestStore(t)
+	content := []byte(strings.Repeat("package main\n\nfunc main() {}\n", 200))
+
+	for _, key := range []string{"vendor/a/main.go", "vendor/b/main.go"} {
+		if err := PutDocument(s, s.TopicID(), key, content, map[string]any{"project": "p"}); err != nil {
+			t.Fatalf("PutDocument(%s): %v", key, err)
+		}
+	}
+	if err := s.AddDocument("legacy.go", []byte("package legacy"), nil, nil); err != nil {
+		t.Fatalf("AddDocument: %v", err)
+	}
+
+	for _, key := range []string{"vendor/a/main.go", "vendor/b/main.go"} {
+		got, err := GetDocument(s, key)
+		if err != nil {
+			t.Fatalf("GetDocument(%s): %v", key, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("GetDocument(%s) returned %d bytes, want the original %d", key, len(got), len(content))
+		}
+		raw, _ := s.GetContentByKey(key)
+		if len(raw) >= len(content) {
+			t.Errorf("%s stores %d bytes, want a short blob reference", key, len(raw))
+		}
+	}
+
+	// Both documents share one compressed blob
+	blob, err := s.GetContentByKey(BlobKey(content))
+	if err != nil {
+		t.Fatalf("blob missing: %v", err)
+	}
+	if len(blob) >= len(content)/4 {
+		t.Errorf("blob is %d bytes for %d bytes of content, want it compressed", len(blob), len(content))
+	}
+
+	// Content written before compression reads back unchanged
+	if got, err := GetDocument(s, "legacy.go"); err != nil || string(got) != "package legacy" {
+		t.Errorf("GetDocument(legacy.go) = %q, %v", got, err)
+	}
+
+	// Readers inside a transaction resolve references in the same txn
+	err = s.View(func(txn *meb.StoreTxn) error {
+		id, err := txn.GetID("vendor/b/main.go")
+		if err != nil {
+			return err
+		}
+		raw, err := txn.GetContent(id)
+		if err != nil {
+			return err
+		}
+		got, err := DecodeContent(raw, TxnContentFetcher(txn))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("DecodeContent in txn returned %d bytes, want %d", len(got), len(content))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package meb
+
+import "strings"
+
+// A documented symbol's doc comment is also stored as a node of its own,
+// linked to the symbol by a documents fact and sharing its embedding, so that
+// documentation can be searched apart from code.
+
+// docNodeSuffix turns a symbol ID into the ID of its doc node.
+const docNodeSuffix = "#doc"
+
+// DocNodeID returns the ID of the doc node of a symbol.
+func DocNodeID(symbolID string) string {
+	return symbolID + docNodeSuffix
+}
+
+// IsDocNode reports whether id is the ID of a doc node.
+func IsDocNode(id string) bool {
+	return strings.HasSuffix(id, docNodeSuffix)
+}
+
+// DocNodeSymbol returns the symbol a doc node documents.
+func DocNodeSymbol(docID string) string {
+	return strings.TrimSuffix(docID, docNodeSuffix)
+}
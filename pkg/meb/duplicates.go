@@ -0,0 +1,365 @@
+package meb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/dict"
+)
+
+// Near-duplicate functions are paired at ingest by the similarity of their
+// embeddings, or for those without one, by the overlap of their code's token
+// shingles. Stored vectors are quantized and cannot be read back, so each is
+// sketched as it is stored: a SimHash signature whose bits agree with another's
+// in proportion to the angle between their vectors. Shingle sets are compared
+// through MinHash signatures bucketed by band, so that only functions sharing
+// a band are compared exactly. Each pair is tagged with similar_to facts both
+// ways, weighted by its similarity.
+
+// vectorSketchesPrefix prefixes the document of each project's vector
+// sketches, keyed as the vectors are.
+const vectorSketchesPrefix = "gca:vector_sketches:"
+
+// MinHash signature layout: minHashBands bands of minHashes/minHashBands
+// rows. Sets overlapping by 0.7 share a band 99% of the time, by 0.3 12%.
+const (
+	minHashes    = 64
+	minHashBands = 16
+)
+
+// VectorSketch is the SimHash signature of a vector: the side of each of 256
+// fixed random hyperplanes it lies on.
+type VectorSketch [4]uint64
+
+// SketchVector returns the sketch of vec.
+func SketchVector(vec []float32) VectorSketch {
+	var sketch VectorSketch
+	for bit := 0; bit < 256; bit++ {
+		var dot float64
+		seed := uint64(bit) << 32
+		for i, v := range vec {
+			// Hyperplane coordinates are ±1, drawn from the bit and dimension
+			if mix64(seed|uint64(i))&1 == 0 {
+				dot += float64(v)
+			} else {
+				dot -= float64(v)
+			}
+		}
+		if dot >= 0 {
+			sketch[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	return sketch
+}
+
+// Similarity estimates the cosine similarity of the vectors sketched.
+func (a VectorSketch) Similarity(b VectorSketch) float64 {
+	differ := 0
+	for i := range a {
+		differ += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return math.Cos(math.Pi * float64(differ) / 256)
+}
+
+// SketchPairs returns the pairs of symbols whose sketches estimate a cosine
+// similarity of at least minSimilarity, scored by it and sorted.
+func SketchPairs(sketches map[string]VectorSketch, minSimilarity float64) []SimilarPair {
+	ids := make([]string, 0, len(sketches))
+	for id := range sketches {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var pairs []SimilarPair
+	for i := range ids {
+		for j := i + 1; j < len(ids); j++ {
+			if score := sketches[ids[i]].Similarity(sketches[ids[j]]); score >= minSimilarity {
+				pairs = append(pairs, NewSimilarPair(ids[i], ids[j], score))
+			}
+		}
+	}
+	return pairs
+}
+
+// SaveVectorSketches replaces project's vector sketches.
+func SaveVectorSketches(store *meb.MEBStore, project string, sketches map[string]VectorSketch) error {
+	data, err := json.Marshal(sketches)
+	if err != nil {
+		return err
+	}
+	if err := PutDocument(store, store.TopicID(), vectorSketchesPrefix+project, data, nil); err != nil {
+		return fmt.Errorf("save vector sketches: %w", err)
+	}
+	return nil
+}
+
+// LoadVectorSketches returns project's vector sketches, or nil if it has none.
+func LoadVectorSketches(store *meb.MEBStore, project string) (map[string]VectorSketch, error) {
+	key := vectorSketchesPrefix + project
+	ok, err := store.HasDocument(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, key)
+	if err != nil {
+		return nil, fmt.Errorf("load vector sketches: %w", err)
+	}
+	var sketches map[string]VectorSketch
+	if err := json.Unmarshal(data, &sketches); err != nil {
+		return nil, fmt.Errorf("decode vector sketches: %w", err)
+	}
+	return sketches, nil
+}
+
+// SimilarPair is two near-duplicate symbols, A before B, and their
+// similarity from 0 to 1.
+type SimilarPair struct {
+	A     string  `json:"a"`
+	B     string  `json:"b"`
+	Score float64 `json:"score"`
+}
+
+// NewSimilarPair returns the pair of symbols x and y, ordered, with score
+// clamped to 1.
+func NewSimilarPair(x, y string, score float64) SimilarPair {
+	if y < x {
+		x, y = y, x
+	}
+	return SimilarPair{A: x, B: y, Score: min(score, 1)}
+}
+
+// CodeTokens splits source code into lowercase words and numbers, so that code
+// formatted or punctuated differently, even in another language, shares its
+// tokens where it shares its names.
+func CodeTokens(code string) []string {
+	return strings.FieldsFunc(strings.ToLower(code), func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ShinglePairs returns the pairs of symbols whose tokens' shingles of
+// config.DuplicateShingleSize tokens overlap by at least minJaccard, scored by
+// their Jaccard similarity and sorted.
+func ShinglePairs(tokens map[string][]string, minJaccard float64) []SimilarPair {
+	ids := make([]string, 0, len(tokens))
+	for id := range tokens {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	type bucket struct {
+		band int
+		hash uint64
+	}
+	sets := make([]map[uint64]bool, len(ids))
+	buckets := make(map[bucket][]int)
+	for i, id := range ids {
+		sets[i] = shingles(tokens[id])
+		if len(sets[i]) == 0 {
+			continue
+		}
+		sig := minHash(sets[i])
+		rows := minHashes / minHashBands
+		for band := 0; band < minHashBands; band++ {
+			h := fnv.New64a()
+			for _, v := range sig[band*rows : (band+1)*rows] {
+				h.Write(binary.LittleEndian.AppendUint64(nil, v))
+			}
+			b := bucket{band, h.Sum64()}
+			buckets[b] = append(buckets[b], i)
+		}
+	}
+
+	compared := make(map[[2]int]bool)
+	var pairs []SimilarPair
+	for _, members := range buckets {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				i, j := members[x], members[y]
+				if compared[[2]int{i, j}] {
+					continue
+				}
+				compared[[2]int{i, j}] = true
+				if score := jaccard(sets[i], sets[j]); score >= minJaccard {
+					pairs = append(pairs, NewSimilarPair(ids[i], ids[j], score))
+				}
+			}
+		}
+	}
+	sortSimilarPairs(pairs)
+	return pairs
+}
+
+// shingles hashes every run of config.DuplicateShingleSize tokens.
+func shingles(tokens []string) map[uint64]bool {
+	set := make(map[uint64]bool)
+	for i := 0; i+config.DuplicateShingleSize <= len(tokens); i++ {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+config.DuplicateShingleSize] {
+			h.Write([]byte(t))
+			h.Write([]byte{0})
+		}
+		set[h.Sum64()] = true
+	}
+	return set
+}
+
+// minHash returns the MinHash signature of a shingle set, each row seeding
+// its own permutation of the hashes.
+func minHash(set map[uint64]bool) [minHashes]uint64 {
+	var sig [minHashes]uint64
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for h := range set {
+		for i := range sig {
+			sig[i] = min(sig[i], mix64(h^uint64(i+1)*0x9e3779b97f4a7c15))
+		}
+	}
+	return sig
+}
+
+// mix64 is the splitmix64 finalizer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+func jaccard(a, b map[uint64]bool) float64 {
+	shared := 0
+	for h := range a {
+		if b[h] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func sortSimilarPairs(pairs []SimilarPair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].A != pairs[j].A {
+			return pairs[i].A < pairs[j].A
+		}
+		return pairs[i].B < pairs[j].B
+	})
+}
+
+// SimilarPairs returns the near-duplicate pairs tagged among the symbols
+// visible to ctx, sorted, with the similarity their facts weigh.
+func SimilarPairs(ctx context.Context, store *meb.MEBStore) ([]SimilarPair, error) {
+	weights, err := FactWeights(store)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[SimilarPair]bool)
+	var pairs []SimilarPair
+	for fact, err := range Scan(ctx, store, "", config.PredicateSimilarTo, "") {
+		if errors.Is(err, dict.ErrNotFound) {
+			break // Never tagged
+		}
+		if err != nil {
+			return nil, err
+		}
+		other, ok := fact.Object.(string)
+		if !ok {
+			continue
+		}
+		score, ok := weights[factKey(fact)]
+		if !ok {
+			score = 1
+		}
+		p := NewSimilarPair(fact.Subject, other, score)
+		if key := (SimilarPair{A: p.A, B: p.B}); !seen[key] {
+			seen[key] = true
+			pairs = append(pairs, p)
+		}
+	}
+	sortSimilarPairs(pairs)
+	return pairs, nil
+}
+
+// SaveSimilarPairs replaces the similar_to facts among the symbols visible to
+// ctx with pairs, tagged both ways and weighted by their score. A pair listed
+// twice keeps its best score. Facts can only be deleted by subject, so the
+// other facts of a subject losing one are written back.
+func SaveSimilarPairs(ctx context.Context, store *meb.MEBStore, pairs []SimilarPair) error {
+	best := make(map[SimilarPair]float64)
+	for _, p := range pairs {
+		key := SimilarPair{A: p.A, B: p.B}
+		best[key] = max(best[key], p.Score)
+	}
+	want := make(map[string]bool, 2*len(best))
+	var facts []meb.Fact
+	var weights []FactWeight
+	for key, score := range best {
+		for _, f := range []meb.Fact{
+			{Subject: key.A, Predicate: config.PredicateSimilarTo, Object: key.B},
+			{Subject: key.B, Predicate: config.PredicateSimilarTo, Object: key.A},
+		} {
+			want[factKey(f)] = true
+			facts = append(facts, f)
+			weights = append(weights, FactWeight{Subject: f.Subject, Predicate: f.Predicate, Object: f.Object.(string), Weight: score})
+		}
+	}
+
+	have := make(map[string]bool)
+	stale := make(map[string]bool)
+	for fact, err := range Scan(ctx, store, "", config.PredicateSimilarTo, "") {
+		if errors.Is(err, dict.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("scan similar_to: %w", err)
+		}
+		if key := factKey(fact); want[key] {
+			have[key] = true
+		} else {
+			stale[fact.Subject] = true
+		}
+	}
+	for subject := range stale {
+		var kept []meb.Fact
+		for fact, err := range store.ScanInTopicContext(ctx, store.TopicID(), subject, "", "") {
+			if err != nil {
+				return fmt.Errorf("scan %s: %w", subject, err)
+			}
+			if fact.Predicate != config.PredicateSimilarTo || want[factKey(fact)] {
+				kept = append(kept, fact)
+			}
+		}
+		if err := store.DeleteFactsBySubject(subject); err != nil {
+			return fmt.Errorf("delete %s: %w", subject, err)
+		}
+		if len(kept) > 0 {
+			if err := store.AddFactBatch(kept); err != nil {
+				return fmt.Errorf("restore %s: %w", subject, err)
+			}
+		}
+	}
+
+	var added []meb.Fact
+	for _, f := range facts {
+		if !have[factKey(f)] {
+			added = append(added, f)
+		}
+	}
+	if len(added) > 0 {
+		if err := store.AddFactBatch(added); err != nil {
+			return fmt.Errorf("write similar_to: %w", err)
+		}
+	}
+	return setFactWeights(store, weights)
+}
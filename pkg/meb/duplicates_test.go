@@ -0,0 +1,111 @@
+package meb
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSketchPairs(t *testing.T) {
+	vec := func(f func(i int) float64) []float32 {
+		v := make([]float32, 1536)
+		for i := range v {
+			v[i] = float32(f(i))
+		}
+		return v
+	}
+	a := vec(func(i int) float64 { return math.Sin(float64(i)) })
+	near := vec(func(i int) float64 { return math.Sin(float64(i)) + 0.01*math.Cos(float64(i)*3) })
+	other := vec(func(i int) float64 { return math.Cos(float64(i) * 1.7) })
+
+	assert.Equal(t, 1.0, SketchVector(a).Similarity(SketchVector(a)))
+	assert.Greater(t, SketchVector(a).Similarity(SketchVector(near)), 0.95)
+	assert.Less(t, math.Abs(SketchVector(a).Similarity(SketchVector(other))), 0.3, "unrelated vectors are near orthogonal")
+
+	pairs := SketchPairs(map[string]VectorSketch{
+		"b.go:B": SketchVector(near),
+		"a.go:A": SketchVector(a),
+		"c.go:C": SketchVector(other),
+	}, 0.95)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, "a.go:A", pairs[0].A)
+		assert.Equal(t, "b.go:B", pairs[0].B)
+	}
+}
+
+func TestShinglePairs(t *testing.T) {
+	code := `for _, order := range orders { if order.Cancelled { continue }; total += order.Price * order.Quantity }; return total, nil`
+	tokens := map[string][]string{
+		"a.go:Sum":   CodeTokens(code),
+		"b.go:Total": CodeTokens(strings.ReplaceAll(code, ";", "\n")),
+		"c.go:Parse": CodeTokens(`fields := map[string]string{}; for _, part := range strings.Split(raw, ";") { key, value, _ := strings.Cut(part, "=") }`),
+	}
+	assert.Contains(t, tokens["a.go:Sum"], "cancelled", "tokens are lowercase")
+
+	pairs := ShinglePairs(tokens, 0.7)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, SimilarPair{A: "a.go:Sum", B: "b.go:Total", Score: 1}, pairs[0])
+	}
+}
+
+func TestSaveSimilarPairs(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if err := s.AddFact(meb.Fact{Subject: "a.go:A", Predicate: config.PredicateType, Object: "function"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := SimilarPairs(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, pairs)
+
+	if err := SaveSimilarPairs(ctx, s, []SimilarPair{
+		NewSimilarPair("b.go:B", "a.go:A", 0.9),
+		NewSimilarPair("a.go:A", "b.go:B", 0.97),
+		NewSimilarPair("a.go:A", "c.go:C", 0.8),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	pairs, err = SimilarPairs(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []SimilarPair{
+		{A: "a.go:A", B: "b.go:B", Score: 0.97},
+		{A: "a.go:A", B: "c.go:C", Score: 0.8},
+	}, pairs, "a pair listed twice keeps its best score")
+
+	// Pairs no longer found are dropped, the other facts of their symbols kept
+	if err := SaveSimilarPairs(ctx, s, []SimilarPair{NewSimilarPair("a.go:A", "b.go:B", 0.96)}); err != nil {
+		t.Fatal(err)
+	}
+	pairs, err = SimilarPairs(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []SimilarPair{{A: "a.go:A", B: "b.go:B", Score: 0.96}}, pairs)
+	var kinds int
+	for _, err := range s.ScanContext(ctx, "a.go:A", config.PredicateType, "") {
+		if err == nil {
+			kinds++
+		}
+	}
+	assert.Equal(t, 1, kinds)
+	for _, err := range s.ScanContext(ctx, "c.go:C", config.PredicateSimilarTo, "") {
+		if err == nil {
+			t.Error("stale similar_to fact of c.go:C was kept")
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/dict"
+)
+
+// EntryPoint is a symbol or file where execution enters the project.
+type EntryPoint struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"` // config.EntryPointMain, EntryPointHTTPHandler, ...
+}
+
+var (
+	// Go: var fooCmd = &cobra.Command{...}
+	cobraCommand = regexp.MustCompile(`&cobra\.Command\{`)
+	// Python: @click.command(), @app.command(), @cli.group() on a top-level def
+	pythonCommand = regexp.MustCompile(`(?m)^@[\w.]*\.(?:command|group)\b.*\n(?:@.*\n)*(?:async\s+)?def\s+(\w+)`)
+	pythonMain    = regexp.MustCompile(`(?m)^if\s+__name__\s*==\s*["']__main__["']\s*:`)
+	// React: createRoot(el).render(<App />), ReactDOM.render(<App />, el), hydrateRoot(el, <App />)
+	reactRender = regexp.MustCompile(`(?:createRoot\(.*?\)\s*\.\s*render|ReactDOM\.render|hydrateRoot)\s*\(`)
+	jsxTag      = regexp.MustCompile(`<\s*([A-Z][\w.]*)`)
+)
+
+// DetectEntryPoints finds the entry points of the code visible to ctx: Go main
+// functions and files running Python's __main__ block, HTTP handlers (targets
+// of handled_by), CLI commands (cobra commands and click-style decorated
+// functions) and React root components (the component rendered by createRoot,
+// ReactDOM.render or hydrateRoot). Entry points are sorted by ID.
+func DetectEntryPoints(ctx context.Context, store *meb.MEBStore) ([]EntryPoint, error) {
+	found := make(map[EntryPoint]bool)
+	add := func(id, kind string) {
+		if id != "" {
+			found[EntryPoint{ID: id, Kind: kind}] = true
+		}
+	}
+
+	// Symbols by file and by name, for resolving names found in source text
+	defined := make(map[string]bool)
+	byName := make(map[string][]string)
+	for fact, err := range Scan(ctx, store, "", config.PredicateDefines, "") {
+		if errors.Is(err, dict.ErrNotFound) {
+			break // Predicates never written are missing from the dictionary
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scan defines: %w", err)
+		}
+		id, ok := fact.Object.(string)
+		if !ok {
+			continue
+		}
+		defined[id] = true
+		if _, name, ok := strings.Cut(id, ":"); ok {
+			byName[name] = append(byName[name], id)
+			if name == "main" && path.Ext(fact.Subject) == ".go" {
+				add(id, config.EntryPointMain)
+			}
+		}
+	}
+
+	for fact, err := range Scan(ctx, store, "", config.PredicateHandledBy, "") {
+		if errors.Is(err, dict.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scan handled_by: %w", err)
+		}
+		if handler, ok := fact.Object.(string); ok && InScope(ctx, handler) {
+			add(handler, config.EntryPointHTTPHandler)
+		}
+	}
+
+	for fact, err := range Scan(ctx, store, "", config.PredicateType, config.SymbolKindFile) {
+		if errors.Is(err, dict.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scan files: %w", err)
+		}
+		file := fact.Subject
+		ext := path.Ext(file)
+		if ext != ".go" && ext != ".py" && ext != ".js" && ext != ".jsx" && ext != ".ts" && ext != ".tsx" {
+			continue
+		}
+		content, err := GetDocument(store, file)
+		if err != nil || len(content) == 0 {
+			continue
+		}
+		src := string(content)
+		switch ext {
+		case ".go":
+			if cobraCommand.MatchString(src) {
+				add(file, config.EntryPointCLICommand)
+			}
+		case ".py":
+			if pythonMain.MatchString(src) {
+				add(file, config.EntryPointMain)
+			}
+			for _, m := range pythonCommand.FindAllStringSubmatch(src, -1) {
+				if id := file + ":" + m[1]; defined[id] {
+					add(id, config.EntryPointCLICommand)
+				}
+			}
+		default:
+			if loc := reactRender.FindStringIndex(src); loc != nil {
+				add(reactRoot(ctx, store, file, src[loc[1]:], defined, byName), config.EntryPointReactRoot)
+			}
+		}
+	}
+
+	entries := make([]EntryPoint, 0, len(found))
+	for e := range found {
+		entries = append(entries, e)
+	}
+	sortEntryPoints(entries)
+	return entries, nil
+}
+
+// reactRoot resolves the component rendered by a root render call, given the
+// source following the call's opening parenthesis. Wrappers such as StrictMode,
+// providers and routers are skipped. When the component cannot be resolved to a
+// symbol, the file itself is the root.
+func reactRoot(ctx context.Context, store *meb.MEBStore, file, call string, defined map[string]bool, byName map[string][]string) string {
+	if len(call) > 1000 {
+		call = call[:1000]
+	}
+	for _, m := range jsxTag.FindAllStringSubmatch(call, -1) {
+		name := m[1][strings.LastIndex(m[1], ".")+1:]
+		if name == "StrictMode" || name == "Suspense" || name == "Fragment" ||
+			strings.HasSuffix(name, "Provider") || strings.HasSuffix(name, "Router") {
+			continue
+		}
+		if id := file + ":" + name; defined[id] {
+			return id
+		}
+		candidates := byName[name]
+		// Prefer the definition in a file the root imports
+		for fact, err := range Scan(ctx, store, file, config.PredicateImports, "") {
+			imp, ok := fact.Object.(string)
+			if err != nil || !ok {
+				continue
+			}
+			for _, id := range candidates {
+				candidateFile, _, _ := strings.Cut(id, ":")
+				if strings.TrimSuffix(candidateFile, path.Ext(candidateFile)) == imp {
+					return id
+				}
+			}
+		}
+		if len(candidates) == 1 {
+			return candidates[0]
+		}
+		break
+	}
+	return file
+}
+
+func sortEntryPoints(entries []EntryPoint) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].ID != entries[j].ID {
+			return entries[i].ID < entries[j].ID
+		}
+		return entries[i].Kind < entries[j].Kind
+	})
+}
+
+// EntryPoints returns the entry points tagged at ingest time, or detects them
+// for stores ingested before entry points were tagged.
+func EntryPoints(ctx context.Context, store *meb.MEBStore) ([]EntryPoint, error) {
+	var entries []EntryPoint
+	for fact, err := range Scan(ctx, store, "", config.PredicateIsEntryPoint, "") {
+		if errors.Is(err, dict.ErrNotFound) {
+			break // Never tagged
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind, ok := fact.Object.(string); ok {
+			entries = append(entries, EntryPoint{ID: fact.Subject, Kind: kind})
+		}
+	}
+	if len(entries) == 0 {
+		return DetectEntryPoints(ctx, store)
+	}
+	sortEntryPoints(entries)
+	return entries, nil
+}
+
+// TagEntryPoints detects the entry points visible to ctx and records them as
+// is_entry_point facts whose object is the entry point's kind.
+func TagEntryPoints(ctx context.Context, store *meb.MEBStore) ([]EntryPoint, error) {
+	entries, err := DetectEntryPoints(ctx, store)
+	if err != nil || len(entries) == 0 {
+		return entries, err
+	}
+	facts := make([]meb.Fact, len(entries))
+	for i, e := range entries {
+		facts[i] = meb.Fact{Subject: e.ID, Predicate: config.PredicateIsEntryPoint, Object: e.Kind}
+	}
+	if err := store.AddFactBatch(facts); err != nil {
+		return nil, fmt.Errorf("write entry points: %w", err)
+	}
+	return entries, nil
+}
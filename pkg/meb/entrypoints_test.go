@@ -0,0 +1,83 @@
+package meb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestEntryPoints(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(1)
+
+	files := map[string]string{
+		"cmd/main.go":       "package main\n\nfunc main() {}\n",
+		"cmd/serve.go":      "package main\n\nvar serveCmd = &cobra.Command{Use: \"serve\"}\n",
+		"api/routes.go":     "package api\n\nfunc GetUser() {}\n",
+		"tools/cli.py":      "import click\n\n@click.command()\n@click.option('--n')\ndef run(n):\n    pass\n\nif __name__ == \"__main__\":\n    run()\n",
+		"web/src/main.tsx":  "import App from './App'\n\ncreateRoot(document.getElementById('root')!).render(\n  <React.StrictMode>\n    <App />\n  </React.StrictMode>\n)\n",
+		"web/src/App.tsx":   "export default function App() { return <div/> }\n",
+		"web/src/Other.tsx": "export function App() { return null }\n",
+	}
+	var facts []meb.Fact
+	for file, content := range files {
+		if err := PutDocument(s, 1, file, []byte(content), nil); err != nil {
+			t.Fatal(err)
+		}
+		facts = append(facts, meb.Fact{Subject: file, Predicate: config.PredicateType, Object: config.SymbolKindFile})
+	}
+	facts = append(facts,
+		meb.Fact{Subject: "cmd/main.go", Predicate: config.PredicateDefines, Object: "cmd/main.go:main"},
+		meb.Fact{Subject: "api/routes.go", Predicate: config.PredicateDefines, Object: "api/routes.go:GetUser"},
+		meb.Fact{Subject: "/users", Predicate: config.PredicateHandledBy, Object: "api/routes.go:GetUser"},
+		meb.Fact{Subject: "tools/cli.py", Predicate: config.PredicateDefines, Object: "tools/cli.py:run"},
+		meb.Fact{Subject: "web/src/App.tsx", Predicate: config.PredicateDefines, Object: "web/src/App.tsx:App"},
+		meb.Fact{Subject: "web/src/Other.tsx", Predicate: config.PredicateDefines, Object: "web/src/Other.tsx:App"},
+		meb.Fact{Subject: "web/src/main.tsx", Predicate: config.PredicateImports, Object: "web/src/App"},
+	)
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []EntryPoint{
+		{ID: "api/routes.go:GetUser", Kind: config.EntryPointHTTPHandler},
+		{ID: "cmd/main.go:main", Kind: config.EntryPointMain},
+		{ID: "cmd/serve.go", Kind: config.EntryPointCLICommand},
+		{ID: "tools/cli.py", Kind: config.EntryPointMain},
+		{ID: "tools/cli.py:run", Kind: config.EntryPointCLICommand},
+		{ID: "web/src/App.tsx:App", Kind: config.EntryPointReactRoot},
+	}
+	ctx := context.Background()
+	got, err := DetectEntryPoints(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DetectEntryPoints = %v, want %v", got, want)
+	}
+
+	// Tagged entry points are read back from is_entry_point facts
+	if _, err := TagEntryPoints(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := range s.ScanContext(ctx, "", config.PredicateIsEntryPoint, "") {
+		if err == nil {
+			n++
+		}
+	}
+	if n != len(want) {
+		t.Errorf("%d is_entry_point facts, want %d", n, len(want))
+	}
+	if got, err := EntryPoints(ctx, s); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("EntryPoints = %v, %v, want %v", got, err, want)
+	}
+}
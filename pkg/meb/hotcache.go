@@ -0,0 +1,219 @@
+package meb
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// A HotCache keeps the decoded facts of a store's most-read subjects and its
+// most-read document contents in memory, so hydrating popular nodes such as
+// entry points and core types again skips Badger and zstd. Keys are only
+// admitted once they were read config.HotCacheAdmitReads times, so one-off
+// reads of a large graph don't flush the hot set, and the least recently used
+// entries are evicted to stay within a byte budget.
+//
+// Document contents are cached by the key of their content blob, which names
+// the content itself, so they can't go stale. Facts can: a subject's facts are
+// only cached for stores nobody writes to.
+type HotCache struct {
+	budget int64
+	facts  bool
+
+	mu      sync.Mutex
+	used    int64
+	lru     *list.List // Of *hotEntry, most recently used first
+	entries map[hotKey]*list.Element
+	reads   map[hotKey]uint32 // Reads of keys not cached yet
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type hotKind uint8
+
+const (
+	hotFacts hotKind = iota
+	hotBlob
+)
+
+type hotKey struct {
+	kind  hotKind
+	topic uint32 // Topic the facts were scanned in; 0 when unscoped
+	key   string
+}
+
+type hotEntry struct {
+	key     hotKey
+	facts   []meb.Fact
+	content []byte
+	size    int64
+}
+
+// HotCacheStats reports a HotCache's size and effectiveness.
+type HotCacheStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	Budget  int64 `json:"budget"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+var hotCaches sync.Map // *meb.MEBStore -> *HotCache
+
+// EnableHotCache gives store a hot cache of budget bytes. Pass readOnly when
+// nothing writes to the store while it is open, which lets the cache hold facts
+// as well as documents. A budget of 0 or less leaves the store uncached.
+func EnableHotCache(store *meb.MEBStore, budget int64, readOnly bool) {
+	if budget <= 0 {
+		return
+	}
+	hotCaches.Store(store, &HotCache{
+		budget:  budget,
+		facts:   readOnly,
+		lru:     list.New(),
+		entries: make(map[hotKey]*list.Element),
+		reads:   make(map[hotKey]uint32),
+	})
+}
+
+// DropHotCache releases store's hot cache. Call it when the store closes.
+func DropHotCache(store *meb.MEBStore) {
+	hotCaches.Delete(store)
+}
+
+// HotCacheOf returns store's hot cache, or nil when it has none. A nil
+// HotCache reads through to the store.
+func HotCacheOf(store *meb.MEBStore) *HotCache {
+	if c, ok := hotCaches.Load(store); ok {
+		return c.(*HotCache)
+	}
+	return nil
+}
+
+// SubjectFacts returns every fact of subj, as TxnScan(ctx, txn, subj, "", "")
+// does, from the cache when it holds them.
+func (c *HotCache) SubjectFacts(ctx context.Context, txn *meb.StoreTxn, subj string) ([]meb.Fact, error) {
+	// Views of past versions overlay the scan; they are rare enough to skip
+	if c == nil || !c.facts || ctx.Value(asOfKey{}) != nil {
+		return collectFacts(TxnScan(ctx, txn, subj, "", ""))
+	}
+	key := hotKey{kind: hotFacts, key: subj}
+	if sc, ok := ScopeFrom(ctx); ok {
+		key.topic = sc.Topic
+	}
+	if e := c.get(key); e != nil {
+		return e.facts, nil
+	}
+	facts, err := collectFacts(TxnScan(ctx, txn, subj, "", ""))
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(subj)) + 64
+	for _, f := range facts {
+		size += factSize(f)
+	}
+	c.add(&hotEntry{key: key, facts: facts, size: size})
+	return facts, nil
+}
+
+// DecodeContent is DecodeContent, with the contents of shared blobs served
+// from the cache. The content returned may be shared: don't modify it.
+func (c *HotCache) DecodeContent(raw []byte, fetch func(docKey string) ([]byte, error)) ([]byte, error) {
+	if c == nil || !bytes.HasPrefix(raw, refHeader) {
+		return DecodeContent(raw, fetch)
+	}
+	key := hotKey{kind: hotBlob, key: string(raw[len(refHeader):])}
+	if e := c.get(key); e != nil {
+		return e.content, nil
+	}
+	content, err := DecodeContent(raw, fetch)
+	if err != nil {
+		return nil, err
+	}
+	c.add(&hotEntry{key: key, content: content, size: int64(len(content)+len(key.key)) + 64})
+	return content, nil
+}
+
+// Stats returns the cache's current size and hit counts.
+func (c *HotCache) Stats() HotCacheStats {
+	if c == nil {
+		return HotCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return HotCacheStats{
+		Entries: c.lru.Len(),
+		Bytes:   c.used,
+		Budget:  c.budget,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+}
+
+// get returns the cached entry for key, counting the read either way.
+func (c *HotCache) get(key hotKey) *hotEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		c.hits.Add(1)
+		return el.Value.(*hotEntry)
+	}
+	c.misses.Add(1)
+	if len(c.reads) >= config.HotCacheTrackedKeys {
+		// Forget old counts rather than track every key ever read
+		clear(c.reads)
+	}
+	c.reads[key]++
+	return nil
+}
+
+// add caches e if its key was read often enough and it fits, evicting the
+// least recently used entries to make room.
+func (c *HotCache) add(e *hotEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reads[e.key] < config.HotCacheAdmitReads || e.size > c.budget/config.HotCacheMaxEntryShare {
+		return
+	}
+	if _, ok := c.entries[e.key]; ok {
+		return // Cached by a concurrent reader
+	}
+	delete(c.reads, e.key)
+	for c.used+e.size > c.budget && c.lru.Len() > 0 {
+		oldest := c.lru.Remove(c.lru.Back()).(*hotEntry)
+		delete(c.entries, oldest.key)
+		c.used -= oldest.size
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.used += e.size
+}
+
+// collectFacts drains a scan, keeping the facts read before any error.
+func collectFacts(scan func(yield func(meb.Fact, error) bool)) ([]meb.Fact, error) {
+	var facts []meb.Fact
+	for f, err := range scan {
+		if err != nil {
+			return facts, err
+		}
+		facts = append(facts, f)
+	}
+	return facts, nil
+}
+
+// factSize approximates the memory a fact holds.
+func factSize(f meb.Fact) int64 {
+	size := int64(len(f.Subject)+len(f.Predicate)) + 48
+	if s, ok := f.Object.(string); ok {
+		size += int64(len(s))
+	} else {
+		size += 16
+	}
+	return size
+}
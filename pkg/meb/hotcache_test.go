@@ -0,0 +1,136 @@
+package meb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func newHotCacheStore(t *testing.T) *meb.MEBStore {
+	t.Helper()
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DropHotCache(s)
+		s.Close()
+	})
+	return s
+}
+
+func TestHotCacheSubjectFacts(t *testing.T) {
+	s := newHotCacheStore(t)
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "main.go:main", Predicate: "type", Object: "function"},
+		{Subject: "main.go:main", Predicate: "calls", Object: "main.go:run"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(hc *HotCache) []meb.Fact {
+		var facts []meb.Fact
+		err := s.View(func(txn *meb.StoreTxn) error {
+			var err error
+			facts, err = hc.SubjectFacts(context.Background(), txn, "main.go:main")
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return facts
+	}
+
+	// Without a cache, facts are read from the store
+	assert.Nil(t, HotCacheOf(s))
+	assert.Len(t, read(nil), 2)
+
+	EnableHotCache(s, 1<<20, true)
+	hc := HotCacheOf(s)
+	for i := 0; i < config.HotCacheAdmitReads+1; i++ {
+		assert.Len(t, read(hc), 2)
+	}
+	stats := hc.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(config.HotCacheAdmitReads), stats.Misses)
+
+	// Facts of writable stores could go stale and are never cached
+	EnableHotCache(s, 1<<20, false)
+	hc = HotCacheOf(s)
+	for i := 0; i < config.HotCacheAdmitReads+1; i++ {
+		assert.Len(t, read(hc), 2)
+	}
+	assert.Equal(t, 0, hc.Stats().Entries)
+}
+
+func TestHotCacheDocuments(t *testing.T) {
+	s := newHotCacheStore(t)
+	EnableHotCache(s, 1<<20, false)
+	if err := PutDocument(s, s.TopicID(), "a.go", []byte("package a"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < config.HotCacheAdmitReads+1; i++ {
+		got, err := GetDocument(s, "a.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "package a", string(got))
+		got[0] = 'X' // Callers own what GetDocument returns
+	}
+	stats := HotCacheOf(s).Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(1), stats.Hits)
+}
+
+func TestHotCacheBudget(t *testing.T) {
+	s := newHotCacheStore(t)
+	const budget = 16 << 10
+	EnableHotCache(s, budget, false)
+	content := func(i int) []byte { return fmt.Appendf(nil, "package p%d // %0500d", i, i) }
+	for i := 0; i < 100; i++ {
+		if err := PutDocument(s, s.TopicID(), fmt.Sprintf("p%d.go", i), content(i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for round := 0; round < config.HotCacheAdmitReads; round++ {
+		for i := 0; i < 100; i++ {
+			got, err := GetDocument(s, fmt.Sprintf("p%d.go", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, content(i), got)
+		}
+	}
+	stats := HotCacheOf(s).Stats()
+	assert.LessOrEqual(t, stats.Bytes, int64(budget))
+	assert.Positive(t, stats.Entries)
+	assert.Less(t, stats.Entries, 100)
+
+	// The documents read last are the ones kept
+	before := stats.Hits
+	if _, err := GetDocument(s, "p99.go"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, before+1, HotCacheOf(s).Stats().Hits)
+
+	// Entries larger than their share of the budget are never cached
+	big := make([]byte, budget)
+	if err := PutDocument(s, s.TopicID(), "big.go", big, nil); err != nil {
+		t.Fatal(err)
+	}
+	entries := HotCacheOf(s).Stats().Entries
+	for i := 0; i < config.HotCacheAdmitReads+1; i++ {
+		if _, err := GetDocument(s, "big.go"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, entries, HotCacheOf(s).Stats().Entries)
+}
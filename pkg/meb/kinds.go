@@ -0,0 +1,102 @@
+package meb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// kindIndexPrefix prefixes the kind index document of each project.
+const kindIndexPrefix = "gca:kind_index:"
+
+// objectKinds are the kinds implied for a node by a fact pointing to it, for
+// nodes that have no kind of their own: import targets and external modules
+// are never parsed, endpoints are only ever referenced.
+var objectKinds = map[string]string{
+	config.PredicateImports:    config.SymbolKindPackage,
+	config.PredicateInPackage:  config.SymbolKindPackage,
+	config.PredicateImplements: config.SymbolKindInterface,
+	config.PredicateCallsAPI:   config.SymbolKindEndpoint,
+}
+
+// KindIndex maps node IDs to their kind, so graphs can label nodes without
+// hydrating them. Ingested files are the nodes of kind file or document, which
+// makes it the project's file index too.
+type KindIndex struct {
+	Kinds map[string]string `json:"kinds"`
+}
+
+// Kind returns the kind of id, or "" when the index does not know it.
+func (idx *KindIndex) Kind(id string) string {
+	if idx == nil {
+		return ""
+	}
+	return idx.Kinds[id]
+}
+
+// IsIngestedFile reports whether id is a file ingested into the project, as
+// opposed to a package, module or path that is only referenced.
+func (idx *KindIndex) IsIngestedFile(id string) bool {
+	kind := idx.Kind(id)
+	return kind == config.SymbolKindFile || kind == config.TypeDocument
+}
+
+// BuildKindIndex indexes the kinds of the nodes visible to ctx: the has_kind
+// or type facts of a node, or else the kind implied by the facts pointing to
+// it, see objectKinds.
+func BuildKindIndex(ctx context.Context, store *meb.MEBStore) *KindIndex {
+	kinds := make(map[string]string)
+	for _, pred := range []string{config.PredicateType, config.PredicateHasKind} {
+		for fact, err := range Scan(ctx, store, "", pred, "") {
+			if kind, ok := fact.Object.(string); err == nil && ok && kind != "" && fact.Subject != "" {
+				kinds[fact.Subject] = kind // has_kind is scanned last and wins
+			}
+		}
+	}
+	for pred, kind := range objectKinds {
+		for fact, err := range Scan(ctx, store, "", pred, "") {
+			if id, ok := fact.Object.(string); err == nil && ok && id != "" && kinds[id] == "" {
+				kinds[id] = kind
+			}
+		}
+	}
+	for fact, err := range Scan(ctx, store, "", config.PredicateHandledBy, "") {
+		if err == nil && fact.Subject != "" && kinds[fact.Subject] == "" {
+			kinds[fact.Subject] = config.SymbolKindEndpoint
+		}
+	}
+	return &KindIndex{Kinds: kinds}
+}
+
+// SaveKindIndex stores idx as project's kind index.
+func SaveKindIndex(store *meb.MEBStore, project string, idx *KindIndex) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := PutDocument(store, store.TopicID(), kindIndexPrefix+project, data, nil); err != nil {
+		return fmt.Errorf("save kind index: %w", err)
+	}
+	return nil
+}
+
+// LoadKindIndex returns project's kind index, or nil if it has none.
+func LoadKindIndex(store *meb.MEBStore, project string) (*KindIndex, error) {
+	key := kindIndexPrefix + project
+	ok, err := store.HasDocument(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, key)
+	if err != nil {
+		return nil, fmt.Errorf("load kind index: %w", err)
+	}
+	var idx KindIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("decode kind index: %w", err)
+	}
+	return &idx, nil
+}
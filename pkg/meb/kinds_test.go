@@ -0,0 +1,69 @@
+package meb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestKindIndex(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(1)
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "api/server.go", Predicate: config.PredicateType, Object: config.SymbolKindFile},
+		{Subject: "api/server.go:Serve", Predicate: config.PredicateType, Object: config.SymbolKindFunc},
+		{Subject: "api/server.go", Predicate: config.PredicateImports, Object: "net/http"},
+		{Subject: "api/server.go", Predicate: config.PredicateImports, Object: "github.com/acme/log"},
+		{Subject: "github.com/acme/log", Predicate: config.PredicateHasKind, Object: config.SymbolKindExternalPackage},
+		{Subject: "api/server.go:Store", Predicate: config.PredicateImplements, Object: "api/store.go:Repository"},
+		{Subject: "web/app.ts:load", Predicate: config.PredicateCallsAPI, Object: "/v1/items"},
+		{Subject: "/v1/users", Predicate: config.PredicateHandledBy, Object: "api/server.go:Serve"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := BuildKindIndex(context.Background(), s)
+	if err := SaveKindIndex(s, "demo", idx); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadKindIndex(s, "demo")
+	if err != nil || loaded == nil {
+		t.Fatalf("LoadKindIndex = %v, %v", loaded, err)
+	}
+
+	tests := map[string]string{
+		"api/server.go":           config.SymbolKindFile,
+		"api/server.go:Serve":     config.SymbolKindFunc,
+		"net/http":                config.SymbolKindPackage,
+		"github.com/acme/log":     config.SymbolKindExternalPackage,
+		"api/store.go:Repository": config.SymbolKindInterface,
+		"/v1/items":               config.SymbolKindEndpoint,
+		"/v1/users":               config.SymbolKindEndpoint,
+		"unknown":                 "",
+	}
+	for id, want := range tests {
+		if got := loaded.Kind(id); got != want {
+			t.Errorf("Kind(%q) = %q, want %q", id, got, want)
+		}
+	}
+
+	if !loaded.IsIngestedFile("api/server.go") {
+		t.Error("api/server.go is not an ingested file")
+	}
+	for _, id := range []string{"api/server.go:Serve", "net/http", "github.com/acme/log"} {
+		if loaded.IsIngestedFile(id) {
+			t.Errorf("%s is an ingested file", id)
+		}
+	}
+
+	if missing, err := LoadKindIndex(s, "other"); err != nil || missing != nil {
+		t.Errorf("LoadKindIndex of a project without one = %v, %v", missing, err)
+	}
+}
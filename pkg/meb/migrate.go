@@ -0,0 +1,230 @@
+package meb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/meb"
+)
+
+// Stores outlive the code that wrote them: key layouts, index documents and
+// registries change between releases. Every store records the format version
+// it was last brought up to, and opening it for writing runs the migrations
+// above that version in order, recording each as it completes so that an
+// interrupted upgrade resumes where it stopped.
+
+// FormatKey is the document recording a store's format version.
+const FormatKey = "gca:format"
+
+// ErrFormatTooNew is returned for stores written by a newer gca, which this
+// one must not read or write.
+var ErrFormatTooNew = errors.New("store format is newer than this gca supports")
+
+// Format is the content of a store's FormatKey document.
+type Format struct {
+	Version int                `json:"version"`
+	History []AppliedMigration `json:"history,omitempty"`
+}
+
+// AppliedMigration records one migration run on a store.
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+	Took      string    `json:"took"`
+}
+
+// MigrationProgress is reported while a migration runs.
+type MigrationProgress struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"` // 0 when the migration cannot tell
+}
+
+// Migration upgrades a store from the previous format version to Version.
+// Run gets the project a per-project store holds, "" for a shared one, and
+// reports its progress through progress; it must be safe to run again after
+// an interruption.
+type Migration struct {
+	Version int
+	Name    string
+	Run     func(ctx context.Context, store *meb.MEBStore, project string, progress func(done, total int)) error
+}
+
+// migrations are the store's migrations, by ascending version.
+var migrations = []Migration{
+	{Version: 1, Name: "register-project", Run: migrateRegisterProject},
+	{Version: 2, Name: "kind-indexes", Run: migrateKindIndexes},
+}
+
+// CurrentFormat is the format version this gca writes.
+var CurrentFormat = migrations[len(migrations)-1].Version
+
+// MigrationReport describes a Migrate call.
+type MigrationReport struct {
+	From    int                `json:"from"`
+	To      int                `json:"to"`
+	Applied []AppliedMigration `json:"applied,omitempty"`
+}
+
+// StoreFormat returns the format recorded in store. A store without one is a
+// new, empty store, at CurrentFormat, or one written before formats were
+// recorded, at version 0.
+func StoreFormat(store *meb.MEBStore) (Format, error) {
+	ok, err := store.HasDocument(FormatKey)
+	if err != nil {
+		return Format{}, err
+	}
+	if !ok {
+		if store.Count() == 0 {
+			return Format{Version: CurrentFormat}, nil
+		}
+		return Format{}, nil
+	}
+	data, err := store.GetContentByKey(FormatKey)
+	if err != nil {
+		return Format{}, fmt.Errorf("load store format: %w", err)
+	}
+	var f Format
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Format{}, fmt.Errorf("decode store format: %w", err)
+	}
+	return f, nil
+}
+
+// PendingMigrations returns the migrations store still needs, in the order
+// they run. It fails with ErrFormatTooNew for stores of a newer gca.
+func PendingMigrations(store *meb.MEBStore) ([]Migration, error) {
+	f, err := StoreFormat(store)
+	if err != nil {
+		return nil, err
+	}
+	if f.Version > CurrentFormat {
+		return nil, fmt.Errorf("%w: version %d, this gca writes %d", ErrFormatTooNew, f.Version, CurrentFormat)
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > f.Version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate brings store up to CurrentFormat, running the pending migrations in
+// order and recording each as it completes. project is the project a
+// per-project store holds, "" for a shared store. progress, if not nil, is
+// called as migrations advance. A new store is only stamped with the current
+// format.
+func Migrate(ctx context.Context, store *meb.MEBStore, project string, progress func(MigrationProgress)) (MigrationReport, error) {
+	f, err := StoreFormat(store)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	pending, err := PendingMigrations(store)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	report := MigrationReport{From: f.Version, To: f.Version}
+	if ok, err := store.HasDocument(FormatKey); err == nil && !ok && len(pending) == 0 {
+		// A new store: nothing to upgrade, but record the format it was created in
+		return report, saveFormat(store, f)
+	}
+
+	for _, m := range pending {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		logger.Info("Migrating store", "version", m.Version, "migration", m.Name, "project", project)
+		began := time.Now()
+		err := m.Run(ctx, store, project, func(done, total int) {
+			if progress != nil {
+				progress(MigrationProgress{Version: m.Version, Name: m.Name, Done: done, Total: total})
+			}
+		})
+		if err != nil {
+			return report, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		applied := AppliedMigration{
+			Version:   m.Version,
+			Name:      m.Name,
+			AppliedAt: time.Now().UTC(),
+			Took:      time.Since(began).Round(time.Millisecond).String(),
+		}
+		f.Version = m.Version
+		f.History = append(f.History, applied)
+		if err := saveFormat(store, f); err != nil {
+			return report, err
+		}
+		report.To = m.Version
+		report.Applied = append(report.Applied, applied)
+	}
+	return report, nil
+}
+
+func saveFormat(store *meb.MEBStore, f Format) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := store.AddDocument(FormatKey, data, nil, nil); err != nil {
+		return fmt.Errorf("save store format: %w", err)
+	}
+	return nil
+}
+
+// migrateRegisterProject registers the project of a per-project store
+// ingested before stores kept a project registry, so that it is listed and
+// later migrations find it.
+func migrateRegisterProject(ctx context.Context, store *meb.MEBStore, project string, progress func(done, total int)) error {
+	if project == "" {
+		return nil
+	}
+	projects, err := RegisteredProjects(store)
+	if err != nil || len(projects) > 0 {
+		return err
+	}
+	// Only claim the facts when they were written under the project's topic
+	for _, err := range Scan(WithScope(ctx, ProjectScope(project)), store, "", "", "") {
+		if err != nil {
+			return err
+		}
+		if err := RegisterProject(store, project); err != nil {
+			return err
+		}
+		progress(1, 1)
+		return nil
+	}
+	return nil
+}
+
+// migrateKindIndexes builds the kind index of every registered project that
+// was ingested before kind indexes were saved, which graphs otherwise rebuild
+// from a scan of the store whenever it changes.
+func migrateKindIndexes(ctx context.Context, store *meb.MEBStore, project string, progress func(done, total int)) error {
+	projects, err := RegisteredProjects(store)
+	if err != nil {
+		return err
+	}
+	for i, p := range projects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		idx, err := LoadKindIndex(store, p)
+		if err != nil {
+			return err
+		}
+		if idx == nil {
+			if err := SaveKindIndex(store, p, BuildKindIndex(WithScope(ctx, ProjectScope(p)), store)); err != nil {
+				return err
+			}
+		}
+		progress(i+1, len(projects))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package meb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	f, err := StoreFormat(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, CurrentFormat, f.Version, "an empty store starts at the current format")
+
+	// A store ingested before formats, registries and kind indexes
+	s.SetTopicID(TopicForProject("app"))
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "app/main.go", Predicate: config.PredicateType, Object: config.SymbolKindFile},
+		{Subject: "app/main.go:main", Predicate: config.PredicateType, Object: config.SymbolKindFunc},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := PendingMigrations(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, pending, len(migrations))
+
+	var progress []MigrationProgress
+	report, err := Migrate(context.Background(), s, "app", func(p MigrationProgress) { progress = append(progress, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, report.From)
+	assert.Equal(t, CurrentFormat, report.To)
+	assert.Len(t, report.Applied, len(migrations))
+	assert.NotEmpty(t, progress)
+
+	projects, err := RegisteredProjects(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"app"}, projects)
+	idx, err := LoadKindIndex(s, "app")
+	if err != nil || idx == nil {
+		t.Fatalf("LoadKindIndex = %v, %v", idx, err)
+	}
+	assert.Equal(t, config.SymbolKindFunc, idx.Kind("app/main.go:main"))
+
+	report, err = Migrate(context.Background(), s, "app", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, report.Applied, "a migrated store has nothing left to run")
+	f, err = StoreFormat(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, f.History, len(migrations))
+
+	// Another project's name must not claim the facts
+	other, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	other.SetTopicID(TopicForProject("app"))
+	if err := other.AddFact(meb.Fact{Subject: "app/main.go", Predicate: config.PredicateType, Object: config.SymbolKindFile}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(context.Background(), other, "shared", nil); err != nil {
+		t.Fatal(err)
+	}
+	projects, err = RegisteredProjects(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, projects)
+}
+
+func TestMigrateRefusesNewerFormat(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	data, _ := json.Marshal(Format{Version: CurrentFormat + 1})
+	if err := s.AddDocument(FormatKey, data, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Migrate(context.Background(), s, "app", nil)
+	if !errors.Is(err, ErrFormatTooNew) {
+		t.Errorf("Migrate = %v, want ErrFormatTooNew", err)
+	}
+}
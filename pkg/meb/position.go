@@ -0,0 +1,72 @@
+package meb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// Location is where a symbol is defined: its file and, when ingest recorded
+// them, its first and last lines (1-based).
+type Location struct {
+	ID        string `json:"id"`
+	File      string `json:"file"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+}
+
+// SymbolLocation returns the location of a symbol, its file taken from the ID
+// ("pkg/a.go" for "pkg/a.go:Foo") and its lines from its start_line and
+// end_line facts within the scope on ctx.
+func SymbolLocation(ctx context.Context, store *meb.MEBStore, id string) Location {
+	loc := Location{ID: id, File: id}
+	if i := strings.Index(id, ":"); i > 0 {
+		loc.File = id[:i]
+	}
+	store.View(func(txn *meb.StoreTxn) error {
+		if start, end, ok := symbolLineRange(ctx, txn, id); ok {
+			loc.StartLine, loc.EndLine = start, end
+		}
+		return nil
+	})
+	return loc
+}
+
+// SymbolAt returns the innermost symbol of file whose lines contain line
+// (1-based): of the symbols the file defines and those they define in turn,
+// such as a class's methods, the one with the shortest range. It reports false
+// when no symbol with recorded lines covers the line.
+func SymbolAt(ctx context.Context, store *meb.MEBStore, file string, line int) (Location, bool) {
+	var best Location
+	found := false
+	store.View(func(txn *meb.StoreTxn) error {
+		seen := map[string]bool{file: true}
+		queue := []string{file}
+		for len(queue) > 0 {
+			parent := queue[0]
+			queue = queue[1:]
+			for fact, err := range TxnScan(ctx, txn, parent, config.PredicateDefines, "") {
+				id, ok := fact.Object.(string)
+				if err != nil || !ok || seen[id] || !strings.HasPrefix(id, file+":") {
+					continue
+				}
+				seen[id] = true
+				queue = append(queue, id)
+
+				start, end, ok := symbolLineRange(ctx, txn, id)
+				if !ok || line < start || line > end {
+					continue
+				}
+				if !found || end-start < best.EndLine-best.StartLine ||
+					end-start == best.EndLine-best.StartLine && id < best.ID {
+					best = Location{ID: id, File: file, StartLine: start, EndLine: end}
+					found = true
+				}
+			}
+		}
+		return nil
+	})
+	return best, found
+}
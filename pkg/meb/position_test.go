@@ -0,0 +1,47 @@
+package meb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolAt(t *testing.T) {
+	s := newQueryTestStore(t)
+	// A class spanning lines 3-20 with a method on 5-9, and a function on 22-30
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "p/a.py", Predicate: "defines", Object: "p/a.py:Repo"},
+		{Subject: "p/a.py", Predicate: "defines", Object: "p/a.py:main"},
+		{Subject: "p/a.py:Repo", Predicate: "defines", Object: "p/a.py:Repo.save"},
+		{Subject: "p/a.py:Repo", Predicate: "start_line", Object: 3},
+		{Subject: "p/a.py:Repo", Predicate: "end_line", Object: 20},
+		{Subject: "p/a.py:Repo.save", Predicate: "start_line", Object: 5},
+		{Subject: "p/a.py:Repo.save", Predicate: "end_line", Object: 9},
+		{Subject: "p/a.py:main", Predicate: "start_line", Object: "22"},
+		{Subject: "p/a.py:main", Predicate: "end_line", Object: "30"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		line int
+		want string
+	}{
+		{7, "p/a.py:Repo.save"}, // the method, not the class around it
+		{12, "p/a.py:Repo"},
+		{22, "p/a.py:main"},
+		{1, ""},
+		{21, ""},
+	}
+	for _, tt := range tests {
+		loc, ok := SymbolAt(ctx, s, "p/a.py", tt.line)
+		assert.Equal(t, tt.want != "", ok, "line %d", tt.line)
+		assert.Equal(t, tt.want, loc.ID, "line %d", tt.line)
+	}
+
+	loc := SymbolLocation(ctx, s, "p/a.py:Repo.save")
+	assert.Equal(t, Location{ID: "p/a.py:Repo.save", File: "p/a.py", StartLine: 5, EndLine: 9}, loc)
+}
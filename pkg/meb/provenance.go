@@ -0,0 +1,125 @@
+package meb
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/datalog"
+	"github.com/duynguyendang/meb"
+)
+
+// Row keys carrying the provenance of a query row's fact when
+// QueryOptions.Provenance is set.
+const (
+	RowSourceKey = "_source"
+	RowWeightKey = "_weight"
+)
+
+// ProvenanceVirtual is the source of facts inferred after extraction, such as
+// API routes and exports, rather than read from a line of code.
+const ProvenanceVirtual = "virtual"
+
+// virtualPredicates are the relations ingest infers in its final passes.
+var virtualPredicates = map[string]bool{
+	config.PredicateCallsAPI:      true,
+	config.PredicateHandledBy:     true,
+	config.PredicateExposesModel:  true,
+	config.PredicateExports:       true,
+	config.PredicateCalledBy:      true,
+	config.PredicateParentDefines: true,
+	config.PredicateIsEntryPoint:  true,
+}
+
+// provenanceResolver finds where facts came from. The store keeps no per-fact
+// origin, so a fact is attributed to its subject's definition: "file:line" for
+// a symbol with a start line, the file path for a file, and ProvenanceVirtual
+// for inferred relations.
+type provenanceResolver struct {
+	ctx     context.Context
+	store   *meb.MEBStore
+	lines   map[string]string // subject -> start line, "" when it has none
+	weights *weightResolver
+}
+
+func newProvenanceResolver(ctx context.Context, store *meb.MEBStore) *provenanceResolver {
+	return &provenanceResolver{ctx: ctx, store: store, lines: make(map[string]string), weights: newWeightResolver(store)}
+}
+
+func (r *provenanceResolver) source(subj, pred string) string {
+	if virtualPredicates[pred] {
+		return ProvenanceVirtual
+	}
+	file := subj
+	if i := strings.Index(subj, ":"); i > 0 {
+		file = subj[:i]
+	}
+	if path.Ext(file) == "" {
+		return "" // Not a file or symbol ID
+	}
+	if file == subj {
+		return file
+	}
+	line, ok := r.lines[subj]
+	if !ok {
+		for fact, err := range Scan(r.ctx, r.store, subj, config.PredicateStartLine, "") {
+			if err == nil {
+				line = fmt.Sprint(fact.Object)
+				break
+			}
+		}
+		r.lines[subj] = line
+	}
+	if line == "" {
+		return file
+	}
+	return file + ":" + line
+}
+
+// FactSource returns where a fact of subj and pred came from, as query
+// provenance reports it: "file:line" of subj's definition, the file for a
+// file, ProvenanceVirtual for an inferred relation, or "" when unknown.
+func FactSource(ctx context.Context, store *meb.MEBStore, subj, pred string) string {
+	return newProvenanceResolver(ctx, store).source(subj, pred)
+}
+
+// FactSources returns FactSource for many facts, sharing the definition lines
+// it looks up.
+func FactSources(ctx context.Context, store *meb.MEBStore) func(subj, pred string) string {
+	return newProvenanceResolver(ctx, store).source
+}
+
+// addProvenance sets RowSourceKey and RowWeightKey on each row for the fact the
+// atom bound in it. Facts carry no weight of their own: a fact weighs what was
+// set with UpdateFactWeight, else what traces observed for an actually_calls
+// fact, else 1.
+func addProvenance(ctx context.Context, store *meb.MEBStore, atom datalog.Atom, rows []map[string]any) {
+	if len(atom.Args) != 3 {
+		return
+	}
+	r := newProvenanceResolver(ctx, store)
+	bound := func(arg string, row map[string]any) string {
+		if isVariable(arg) {
+			if v, ok := row[arg]; ok {
+				return fmt.Sprint(v)
+			}
+			return ""
+		}
+		return resolveArg(arg)
+	}
+	for _, row := range rows {
+		subj, pred, obj := bound(atom.Args[0], row), bound(atom.Args[1], row), bound(atom.Args[2], row)
+		row[RowWeightKey] = r.weights.weight(subj, pred, obj)
+		if pred == config.PredicateActuallyCalls {
+			if e, ok := r.weights.runtimeEdge(subj, obj); ok {
+				row[RowSourceKey] = ProvenanceRuntimePrefix + strings.Join(e.Sources, ",")
+				continue
+			}
+		}
+		if source := r.source(subj, pred); source != "" {
+			row[RowSourceKey] = source
+		}
+	}
+}
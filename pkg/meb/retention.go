@@ -0,0 +1,332 @@
+package meb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// Enrichment added on top of the extracted graph, such as calls observed in
+// traces or links drawn by hand, goes stale as the code moves on. A store's
+// retention policy gives such graphs a time to live: their facts are written
+// with an expiry, kept in a ledger since facts have no attributes of their
+// own, and SweepExpired deletes the ones whose time has come.
+
+// Graphs a retention policy can give a time to live.
+const (
+	GraphRuntime     = "runtime"     // actually_calls facts and weights imported from traces
+	GraphAnnotations = "annotations" // Notes and labels added through the annotations API
+	GraphVirtual     = "virtual"     // Links added through the annotations API
+)
+
+// RetentionGraphs lists the graphs a retention policy can name.
+var RetentionGraphs = []string{GraphRuntime, GraphAnnotations, GraphVirtual}
+
+// RetentionKey is the document holding a store's retention policy.
+const RetentionKey = "gca:retention"
+
+// ExpiriesKey is the document listing the facts due to expire.
+const ExpiriesKey = "gca:expiries"
+
+// RetentionPolicy is the time to live of each graph's facts. Graphs it does
+// not name are kept forever.
+type RetentionPolicy map[string]time.Duration
+
+// ParseRetention parses a policy such as "runtime=168h,virtual=720h". A TTL of
+// 0 keeps the graph forever.
+func ParseRetention(spec string) (RetentionPolicy, error) {
+	p := make(RetentionPolicy)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		graph, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("retention %q: want graph=ttl", pair)
+		}
+		graph = strings.TrimSpace(graph)
+		if !slices.Contains(RetentionGraphs, graph) {
+			return nil, fmt.Errorf("retention %q: unknown graph %q (want %s)", pair, graph, strings.Join(RetentionGraphs, ", "))
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("retention %q: invalid ttl", pair)
+		}
+		if ttl > 0 {
+			p[graph] = ttl
+		}
+	}
+	return p, nil
+}
+
+// String formats p as ParseRetention reads it, by graph.
+func (p RetentionPolicy) String() string {
+	var pairs []string
+	for _, graph := range RetentionGraphs {
+		if ttl, ok := p[graph]; ok {
+			pairs = append(pairs, graph+"="+ttl.String())
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ExpiresAt returns when facts of graph written at now expire, or the zero
+// time when they never do.
+func (p RetentionPolicy) ExpiresAt(graph string, now time.Time) time.Time {
+	ttl, ok := p[graph]
+	if !ok || ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl).UTC()
+}
+
+// Retention returns store's retention policy, empty when it has none.
+func Retention(store *meb.MEBStore) (RetentionPolicy, error) {
+	ok, err := store.HasDocument(RetentionKey)
+	if err != nil || !ok {
+		return RetentionPolicy{}, err
+	}
+	data, err := store.GetContentByKey(RetentionKey)
+	if err != nil {
+		return nil, fmt.Errorf("load retention policy: %w", err)
+	}
+	var ttls map[string]string
+	if err := json.Unmarshal(data, &ttls); err != nil {
+		return nil, fmt.Errorf("decode retention policy: %w", err)
+	}
+	p := make(RetentionPolicy, len(ttls))
+	for graph, ttl := range ttls {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("decode retention policy: %s: %w", graph, err)
+		}
+		p[graph] = d
+	}
+	return p, nil
+}
+
+// SaveRetention makes p store's retention policy. It applies to facts written
+// from then on; those already written keep their expiry.
+func SaveRetention(store *meb.MEBStore, p RetentionPolicy) error {
+	ttls := make(map[string]string, len(p))
+	for graph, ttl := range p {
+		ttls[graph] = ttl.String()
+	}
+	data, err := json.Marshal(ttls)
+	if err != nil {
+		return err
+	}
+	if err := store.AddDocument(RetentionKey, data, nil, nil); err != nil {
+		return fmt.Errorf("save retention policy: %w", err)
+	}
+	return nil
+}
+
+// FactExpiry is a fact due to be deleted.
+type FactExpiry struct {
+	Graph     string    `json:"graph"`
+	Project   string    `json:"project,omitempty"`
+	Topic     uint32    `json:"topic"`
+	Subject   string    `json:"subject"`
+	Predicate string    `json:"predicate"`
+	Object    string    `json:"object"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (e FactExpiry) key() string {
+	return fmt.Sprintf("%d\x00%s\x00%s\x00%s", e.Topic, e.Subject, e.Predicate, e.Object)
+}
+
+// Expiries returns the facts due to expire, soonest first.
+func Expiries(store *meb.MEBStore) ([]FactExpiry, error) {
+	ok, err := store.HasDocument(ExpiriesKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, ExpiriesKey)
+	if err != nil {
+		return nil, fmt.Errorf("load expiries: %w", err)
+	}
+	var expiries []FactExpiry
+	if err := json.Unmarshal(data, &expiries); err != nil {
+		return nil, fmt.Errorf("decode expiries: %w", err)
+	}
+	return expiries, nil
+}
+
+func saveExpiries(store *meb.MEBStore, expiries []FactExpiry) error {
+	sort.SliceStable(expiries, func(i, j int) bool { return expiries[i].ExpiresAt.Before(expiries[j].ExpiresAt) })
+	data, err := json.Marshal(expiries)
+	if err != nil {
+		return err
+	}
+	if err := PutDocument(store, store.TopicID(), ExpiriesKey, data, nil); err != nil {
+		return fmt.Errorf("save expiries: %w", err)
+	}
+	return nil
+}
+
+// ExpireFacts records that facts of graph, written to project's topic, expire
+// at expiresAt. Facts recorded before get the new expiry, so that facts
+// written again live on.
+func ExpireFacts(store *meb.MEBStore, project, graph string, facts []meb.Fact, expiresAt time.Time) error {
+	if len(facts) == 0 || expiresAt.IsZero() {
+		return nil
+	}
+	expiries, err := Expiries(store)
+	if err != nil {
+		return err
+	}
+	index := make(map[string]int, len(expiries))
+	for i, e := range expiries {
+		index[e.key()] = i
+	}
+	topic := TopicForProject(project)
+	for _, f := range facts {
+		e := FactExpiry{
+			Graph:     graph,
+			Project:   project,
+			Topic:     topic,
+			Subject:   f.Subject,
+			Predicate: f.Predicate,
+			Object:    fmt.Sprint(f.Object),
+			ExpiresAt: expiresAt.UTC(),
+		}
+		if i, ok := index[e.key()]; ok {
+			expiries[i] = e
+			continue
+		}
+		index[e.key()] = len(expiries)
+		expiries = append(expiries, e)
+	}
+	return saveExpiries(store, expiries)
+}
+
+// SweepReport summarizes a SweepExpired run.
+type SweepReport struct {
+	Facts        int `json:"facts"`         // Expired facts deleted
+	RuntimeEdges int `json:"runtime_edges"` // Observed weights dropped with their actually_calls facts
+	Remaining    int `json:"remaining"`     // Facts still due to expire
+}
+
+// SweepExpired deletes the facts that expired by now, recording the deletions
+// of each project as an ingest version. Facts can only be deleted by subject,
+// so the other facts of an expired fact's subject are written back. Run it
+// while nothing else sets the store's topic.
+func SweepExpired(ctx context.Context, store *meb.MEBStore, now time.Time) (SweepReport, error) {
+	var report SweepReport
+	expiries, err := Expiries(store)
+	if err != nil || len(expiries) == 0 {
+		return report, err
+	}
+	type subjectKey struct {
+		project string
+		topic   uint32
+		subject string
+	}
+	due := make(map[subjectKey]map[string]bool) // Expired predicate and object pairs by subject
+	var remaining []FactExpiry
+	runtime := make(map[string]bool)
+	for _, e := range expiries {
+		if e.ExpiresAt.After(now) {
+			remaining = append(remaining, e)
+			continue
+		}
+		k := subjectKey{e.Project, e.Topic, e.Subject}
+		if due[k] == nil {
+			due[k] = make(map[string]bool)
+		}
+		due[k][e.Predicate+"\x00"+e.Object] = true
+		if e.Graph == GraphRuntime && e.Predicate == config.PredicateActuallyCalls {
+			runtime[runtimeEdgeKey(e.Subject, e.Object)] = true
+		}
+	}
+	report.Remaining = len(remaining)
+	if len(due) == 0 {
+		return report, nil
+	}
+
+	keys := make([]subjectKey, 0, len(due))
+	for k := range due {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].project != keys[j].project {
+			return keys[i].project < keys[j].project
+		}
+		return keys[i].subject < keys[j].subject
+	})
+	previousTopic := store.TopicID()
+	defer store.SetTopicID(previousTopic)
+	recorders := make(map[string]*VersionRecorder)
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		store.SetTopicID(k.topic)
+		var kept []meb.Fact
+		expired := 0
+		for fact, err := range store.ScanInTopicContext(ctx, k.topic, k.subject, "", "") {
+			if err != nil {
+				return report, fmt.Errorf("scan %s: %w", k.subject, err)
+			}
+			if due[k][fact.Predicate+"\x00"+fmt.Sprint(fact.Object)] {
+				expired++
+				continue
+			}
+			kept = append(kept, fact)
+		}
+		if expired == 0 {
+			continue // Already gone
+		}
+		rec, ok := recorders[k.project]
+		if !ok {
+			rec = RecordVersion(store, k.project, false)
+			recorders[k.project] = rec
+		}
+		if err := rec.DeleteSubject(store, k.subject); err != nil {
+			return report, fmt.Errorf("delete %s: %w", k.subject, err)
+		}
+		if len(kept) > 0 {
+			if err := store.AddFactBatch(kept); err != nil {
+				return report, fmt.Errorf("restore %s: %w", k.subject, err)
+			}
+			rec.AddSubject(k.subject)
+		}
+		report.Facts += expired
+	}
+	for project, rec := range recorders {
+		store.SetTopicID(TopicForProject(project))
+		if _, err := rec.Commit(); err != nil {
+			return report, err
+		}
+	}
+	store.SetTopicID(previousTopic)
+
+	if len(runtime) > 0 {
+		edges, err := RuntimeEdges(store)
+		if err != nil {
+			return report, err
+		}
+		for key := range runtime {
+			if _, ok := edges[key]; ok {
+				delete(edges, key)
+				report.RuntimeEdges++
+			}
+		}
+		if report.RuntimeEdges > 0 {
+			if err := saveRuntimeEdges(store, edges); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, saveExpiries(store, remaining)
+}
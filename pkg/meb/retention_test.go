@@ -0,0 +1,110 @@
+package meb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetention(t *testing.T) {
+	p, err := ParseRetention("runtime=168h, virtual=30m,annotations=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, RetentionPolicy{GraphRuntime: 168 * time.Hour, GraphVirtual: 30 * time.Minute}, p)
+	assert.Equal(t, "runtime=168h0m0s,virtual=30m0s", p.String())
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, now.Add(30*time.Minute), p.ExpiresAt(GraphVirtual, now))
+	assert.True(t, p.ExpiresAt(GraphAnnotations, now).IsZero())
+
+	for _, spec := range []string{"runtime", "traces=1h", "runtime=soon", "runtime=-1h"} {
+		_, err := ParseRetention(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSweepExpired(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(TopicForProject("app"))
+
+	policy := RetentionPolicy{GraphRuntime: time.Hour}
+	if err := SaveRetention(s, policy); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Retention(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, policy, loaded)
+
+	static := meb.Fact{Subject: "app/a.go:main", Predicate: config.PredicateCalls, Object: "app/a.go:run"}
+	stale := meb.Fact{Subject: "app/a.go:main", Predicate: config.PredicateActuallyCalls, Object: "app/a.go:run"}
+	fresh := meb.Fact{Subject: "app/a.go:run", Predicate: config.PredicateActuallyCalls, Object: "app/a.go:save"}
+	if err := s.AddFactBatch([]meb.Fact{static, stale, fresh}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddRuntimeEdges(s, []RuntimeEdge{
+		{Caller: stale.Subject, Callee: "app/a.go:run", Weight: 3, Sources: []string{"pprof"}},
+		{Caller: fresh.Subject, Callee: "app/a.go:save", Weight: 1, Sources: []string{"pprof"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := ExpireFacts(s, "app", GraphRuntime, []meb.Fact{stale, fresh}, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// Observed again: fresh lives on
+	if err := ExpireFacts(s, "app", GraphRuntime, []meb.Fact{fresh}, now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	expiries, err := Expiries(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, expiries, 2)
+
+	report, err := SweepExpired(context.Background(), s, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, SweepReport{Remaining: 2}, report, "nothing has expired yet")
+
+	report, err = SweepExpired(context.Background(), s, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, SweepReport{Facts: 1, RuntimeEdges: 1, Remaining: 1}, report)
+
+	has := func(f meb.Fact) bool {
+		for _, err := range s.ScanInTopicContext(context.Background(), s.TopicID(), f.Subject, f.Predicate, f.Object.(string)) {
+			return err == nil
+		}
+		return false
+	}
+	assert.False(t, has(stale))
+	assert.True(t, has(static), "the subject's other facts are kept")
+	assert.True(t, has(fresh))
+	edges, err := RuntimeEdges(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, edges, 1)
+
+	versions, err := Versions(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, versions, 1) {
+		assert.Equal(t, 2, versions[0].Removed)
+		assert.Equal(t, 1, versions[0].Added)
+	}
+}
@@ -0,0 +1,161 @@
+package meb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// Documents written with PutDocumentRevision carry a revision number that goes
+// up by one each time their content changes. Writers pass the revision they
+// read, so a writer that lost a race with another one is told so instead of
+// overwriting its content. The history of each document lives in a document of
+// its own, newest revision first.
+
+// RevisionKeyPrefix prefixes the document keys of revision histories.
+const RevisionKeyPrefix = "gca:rev:"
+
+// AnyRevision as a base revision writes over whichever revision is current.
+const AnyRevision = ^uint64(0)
+
+// ErrRevisionConflict is returned when a document changed since the writer read
+// it, to different content than the writer's.
+var ErrRevisionConflict = errors.New("document revision conflict")
+
+// DocumentRevision describes one revision of a document's content.
+type DocumentRevision struct {
+	Rev    uint64    `json:"rev"`
+	Hash   string    `json:"hash"` // SHA-256 of the content, hex
+	Size   int       `json:"size"`
+	Time   time.Time `json:"time"`
+	Writer string    `json:"writer,omitempty"` // e.g. ingest, watch
+}
+
+// revisionLocks serialize the read-check-write of documents' revisions. A
+// store is only ever open in one process, so locking in process is enough.
+var revisionLocks [64]sync.Mutex
+
+func revisionLock(docKey string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(docKey))
+	return &revisionLocks[h.Sum32()%uint32(len(revisionLocks))]
+}
+
+// PutDocumentRevision stores a document like PutDocument and records a new
+// revision of it, provided the document is still at revision base (0 for a
+// document the writer believes new, AnyRevision to skip the check).
+//
+// Writing the content the document already has is a no-op, whatever base is:
+// the current revision is returned and only metadata is added. Otherwise, when
+// the document moved past base, the write is refused with ErrRevisionConflict
+// and the current revision is returned. Empty content leaves the stored bytes
+// as they are, as PutDocument does.
+func PutDocumentRevision(store *meb.MEBStore, topicID uint32, docKey string, content []byte, metadata map[string]any, base uint64, writer string) (DocumentRevision, error) {
+	sum := sha256.Sum256(content)
+	rev := DocumentRevision{Hash: hex.EncodeToString(sum[:]), Size: len(content), Time: time.Now().UTC(), Writer: writer}
+
+	mu := revisionLock(docKey)
+	mu.Lock()
+	defer mu.Unlock()
+
+	history, err := GetDocumentRevisions(store, docKey)
+	if err != nil {
+		return DocumentRevision{}, err
+	}
+	var current DocumentRevision
+	if len(history) > 0 {
+		current = history[0]
+	}
+	if current.Rev > 0 && current.Hash == rev.Hash {
+		if len(metadata) > 0 {
+			if err := store.AddDocumentWithTopic(topicID, docKey, nil, nil, metadata); err != nil {
+				return current, err
+			}
+		}
+		return current, nil
+	}
+	if base != AnyRevision && base != current.Rev {
+		return current, fmt.Errorf("%w: %s is at revision %d, not %d", ErrRevisionConflict, docKey, current.Rev, base)
+	}
+
+	ref, err := storeContent(store, topicID, content)
+	if err != nil {
+		return current, err
+	}
+	rev.Rev = current.Rev + 1
+	history = append([]DocumentRevision{rev}, history[:min(len(history), config.DocumentRevisionHistory-1)]...)
+	data, err := json.Marshal(history)
+	if err != nil {
+		return current, fmt.Errorf("encode revisions: %w", err)
+	}
+
+	// Content and history change together or not at all
+	err = store.Update(func(txn *meb.StoreTxn) error {
+		id, err := txn.GetOrCreateID(docKey)
+		if err != nil {
+			return err
+		}
+		if len(metadata) > 0 {
+			facts := make([]meb.Fact, 0, len(metadata))
+			for key, value := range metadata {
+				facts = append(facts, meb.Fact{Subject: docKey, Predicate: key, Object: value})
+			}
+			if err := txn.AddFactBatchWithTopic(facts, topicID); err != nil {
+				return err
+			}
+		}
+		if ref != nil {
+			if err := txn.SetContent(id, ref); err != nil {
+				return err
+			}
+		}
+		historyID, err := txn.GetOrCreateID(RevisionKeyPrefix + docKey)
+		if err != nil {
+			return err
+		}
+		return txn.SetContent(historyID, data)
+	})
+	if err != nil {
+		return current, fmt.Errorf("store revision %d of %s: %w", rev.Rev, docKey, err)
+	}
+	return rev, nil
+}
+
+// GetDocumentRevisions returns the recorded revisions of a document, newest
+// first, up to config.DocumentRevisionHistory of them. Documents never written
+// with PutDocumentRevision have none.
+func GetDocumentRevisions(store *meb.MEBStore, docKey string) ([]DocumentRevision, error) {
+	key := RevisionKeyPrefix + docKey
+	if ok, err := store.HasDocument(key); err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, key)
+	if err != nil {
+		return nil, fmt.Errorf("read revisions of %s: %w", docKey, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var history []DocumentRevision
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("decode revisions of %s: %w", docKey, err)
+	}
+	return history, nil
+}
+
+// CurrentRevision returns a document's revision number, 0 when it has none.
+func CurrentRevision(store *meb.MEBStore, docKey string) (uint64, error) {
+	history, err := GetDocumentRevisions(store, docKey)
+	if err != nil || len(history) == 0 {
+		return 0, err
+	}
+	return history[0].Rev, nil
+}
@@ -0,0 +1,103 @@
+package meb
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestPutDocumentRevision(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	put := func(content string, base uint64) (DocumentRevision, error) {
+		return PutDocumentRevision(s, s.TopicID(), "a.go", []byte(content), map[string]any{"project": "p"}, base, "test")
+	}
+
+	rev, err := put("package a", 0)
+	if err != nil || rev.Rev != 1 {
+		t.Fatalf("first write = %+v, %v; want revision 1", rev, err)
+	}
+	// Retrying the same write is a no-op, even from a stale base
+	if again, err := put("package a", 0); err != nil || again.Rev != 1 {
+		t.Errorf("repeated write = %+v, %v; want revision 1 unchanged", again, err)
+	}
+	if rev, err = put("package a // v2", 1); err != nil || rev.Rev != 2 {
+		t.Fatalf("second write = %+v, %v; want revision 2", rev, err)
+	}
+
+	// A writer still on revision 1 with other content is refused
+	cur, err := put("package a // stale", 1)
+	if !errors.Is(err, ErrRevisionConflict) || cur.Rev != 2 {
+		t.Errorf("stale write = %+v, %v; want a conflict at revision 2", cur, err)
+	}
+	if got, _ := GetDocument(s, "a.go"); string(got) != "package a // v2" {
+		t.Errorf("content = %q after a refused write", got)
+	}
+	if rev, err = put("package a // forced", AnyRevision); err != nil || rev.Rev != 3 {
+		t.Errorf("forced write = %+v, %v; want revision 3", rev, err)
+	}
+
+	history, err := GetDocumentRevisions(s, "a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Rev != 3 || history[2].Rev != 1 || history[0].Writer != "test" {
+		t.Errorf("history = %+v, want revisions 3, 2, 1", history)
+	}
+	if history[0].Hash == history[1].Hash || history[0].Size != len("package a // forced") {
+		t.Errorf("newest revision = %+v", history[0])
+	}
+	if rev, err := CurrentRevision(s, "missing.go"); err != nil || rev != 0 {
+		t.Errorf("revision of a missing document = %d, %v; want 0", rev, err)
+	}
+
+	for i := 0; i < config.DocumentRevisionHistory+5; i++ {
+		if _, err := put(string(rune('A'+i)), AnyRevision); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if history, _ := GetDocumentRevisions(s, "a.go"); len(history) != config.DocumentRevisionHistory {
+		t.Errorf("history length = %d, want capped at %d", len(history), config.DocumentRevisionHistory)
+	}
+}
+
+func TestPutDocumentRevisionConcurrent(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Writers that all read revision 0: exactly one wins
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var won, lost int
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := PutDocumentRevision(s, s.TopicID(), "b.go", []byte{byte('a' + i)}, nil, 0, "")
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.Is(err, ErrRevisionConflict):
+				lost++
+			default:
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if won != 1 || lost != 7 {
+		t.Errorf("won %d, lost %d; want 1 and 7", won, lost)
+	}
+}
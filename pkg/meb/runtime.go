@@ -0,0 +1,101 @@
+package meb
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/duynguyendang/meb"
+)
+
+// RuntimeCallsKey is the document holding the observed weight of every
+// actually_calls edge. Facts carry no weight, so trace imports keep it here and
+// query provenance reports it. Symbol IDs carry their project prefix, so one
+// document serves a shared store.
+const RuntimeCallsKey = "gca:runtime_calls"
+
+// ProvenanceRuntimePrefix prefixes the source of facts observed at runtime,
+// followed by the trace format, e.g. "runtime:pprof".
+const ProvenanceRuntimePrefix = "runtime:"
+
+// RuntimeEdge is a call observed in profiles or traces.
+type RuntimeEdge struct {
+	Caller  string   `json:"caller"`
+	Callee  string   `json:"callee"`
+	Weight  float64  `json:"weight"`  // Samples or spans observing the call, summed over imports
+	Sources []string `json:"sources"` // Trace formats that observed it
+}
+
+func runtimeEdgeKey(caller, callee string) string {
+	return caller + "\x00" + callee
+}
+
+// RuntimeEdges returns the store's observed calls keyed by caller and callee.
+func RuntimeEdges(store *meb.MEBStore) (map[string]RuntimeEdge, error) {
+	ok, err := store.HasDocument(RuntimeCallsKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, RuntimeCallsKey)
+	if err != nil {
+		return nil, fmt.Errorf("load runtime calls: %w", err)
+	}
+	var list []RuntimeEdge
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode runtime calls: %w", err)
+	}
+	edges := make(map[string]RuntimeEdge, len(list))
+	for _, e := range list {
+		edges[runtimeEdgeKey(e.Caller, e.Callee)] = e
+	}
+	return edges, nil
+}
+
+// AddRuntimeEdges merges observed calls into the store's runtime weights:
+// weights of calls seen before are summed.
+func AddRuntimeEdges(store *meb.MEBStore, observed []RuntimeEdge) error {
+	edges, err := RuntimeEdges(store)
+	if err != nil {
+		return err
+	}
+	if edges == nil {
+		edges = make(map[string]RuntimeEdge, len(observed))
+	}
+	for _, o := range observed {
+		key := runtimeEdgeKey(o.Caller, o.Callee)
+		e, ok := edges[key]
+		if !ok {
+			e = RuntimeEdge{Caller: o.Caller, Callee: o.Callee}
+		}
+		e.Weight += o.Weight
+		for _, src := range o.Sources {
+			if !slices.Contains(e.Sources, src) {
+				e.Sources = append(e.Sources, src)
+			}
+		}
+		edges[key] = e
+	}
+	return saveRuntimeEdges(store, edges)
+}
+
+func saveRuntimeEdges(store *meb.MEBStore, edges map[string]RuntimeEdge) error {
+	list := make([]RuntimeEdge, 0, len(edges))
+	for _, e := range edges {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Caller != list[j].Caller {
+			return list[i].Caller < list[j].Caller
+		}
+		return list[i].Callee < list[j].Callee
+	})
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := PutDocument(store, store.TopicID(), RuntimeCallsKey, data, nil); err != nil {
+		return fmt.Errorf("save runtime calls: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package meb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// SavedQueriesKey is the document holding the store's saved queries, the
+// named Datalog queries a team curates for its projects.
+const SavedQueriesKey = "gca:saved_queries"
+
+// ErrSavedQueryNotFound is returned for a saved query the project does not have.
+var ErrSavedQueryNotFound = errors.New("saved query not found")
+
+// ErrInvalidSavedQuery is returned for saved queries, and parameters given to
+// them, that cannot be saved or run.
+var ErrInvalidSavedQuery = errors.New("invalid saved query")
+
+// ReservedQueryNames are the names taken by the pre-defined query routes under
+// /api/v1/queries.
+var ReservedQueryNames = []string{"execute", "reload", "predefined"}
+
+// SavedQueryParam is a parameter of a saved query, written {name} in its query.
+type SavedQueryParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// SavedQuery is a named Datalog query of a project.
+type SavedQuery struct {
+	Name        string            `json:"name"`
+	Project     string            `json:"project"`
+	Description string            `json:"description,omitempty"`
+	Query       string            `json:"query"`
+	Parameters  []SavedQueryParam `json:"parameters,omitempty"`
+	Question    string            `json:"question,omitempty"` // The question the query was generated from, if any
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+var (
+	queryNamePattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	placeholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// ValidQueryName reports whether name can name a saved query: letters, digits,
+// '_', '.' and '-', not starting with a punctuation mark nor reserved.
+func ValidQueryName(name string) error {
+	if name == "" || len(name) > config.MaxQueryNameLength || !queryNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: name %q must be letters, digits, '_', '.' or '-'", ErrInvalidSavedQuery, name)
+	}
+	if slices.Contains(ReservedQueryNames, name) {
+		return fmt.Errorf("%w: name %q is reserved", ErrInvalidSavedQuery, name)
+	}
+	return nil
+}
+
+// Bind substitutes params, and the defaults of the parameters params lacks,
+// for the {name} placeholders of q's query. Values are quoted by the query, so
+// they may not hold quotes, backslashes or line breaks.
+func (q SavedQuery) Bind(params map[string]string) (string, error) {
+	for name := range params {
+		if !slices.ContainsFunc(q.Parameters, func(p SavedQueryParam) bool { return p.Name == name }) {
+			return "", fmt.Errorf("%w: unknown parameter %q", ErrInvalidSavedQuery, name)
+		}
+	}
+	values := make(map[string]string, len(q.Parameters))
+	for _, p := range q.Parameters {
+		v, ok := params[p.Name]
+		if !ok {
+			if p.Required {
+				return "", fmt.Errorf("%w: parameter %q is required", ErrInvalidSavedQuery, p.Name)
+			}
+			v = p.Default
+		}
+		if strings.ContainsAny(v, "\"\\\n\r") {
+			return "", fmt.Errorf("%w: parameter %q holds a quote, backslash or line break", ErrInvalidSavedQuery, p.Name)
+		}
+		values[p.Name] = v
+	}
+	return placeholderPattern.ReplaceAllStringFunc(q.Query, func(m string) string {
+		return values[m[1:len(m)-1]]
+	}), nil
+}
+
+// SavedQueries returns project's saved queries by name. An empty project
+// returns the queries of every project, by project.
+func SavedQueries(store *meb.MEBStore, project string) ([]SavedQuery, error) {
+	all, err := loadSavedQueries(store)
+	if err != nil || project == "" {
+		return all, err
+	}
+	var queries []SavedQuery
+	for _, q := range all {
+		if q.Project == project {
+			queries = append(queries, q)
+		}
+	}
+	return queries, nil
+}
+
+// GetSavedQuery returns project's query called name.
+func GetSavedQuery(store *meb.MEBStore, project, name string) (SavedQuery, error) {
+	all, err := loadSavedQueries(store)
+	if err != nil {
+		return SavedQuery{}, err
+	}
+	for _, q := range all {
+		if q.Project == project && q.Name == name {
+			return q, nil
+		}
+	}
+	return SavedQuery{}, fmt.Errorf("%w: %s", ErrSavedQueryNotFound, name)
+}
+
+// SaveQuery saves q under its name and project, replacing the query of that
+// name, whose creation time it keeps. Placeholders of the query that no
+// parameter declares are added as required parameters. It returns the query
+// as saved and whether it is new.
+func SaveQuery(store *meb.MEBStore, q SavedQuery, now time.Time) (SavedQuery, bool, error) {
+	if err := ValidQueryName(q.Name); err != nil {
+		return q, false, err
+	}
+	q.Query = strings.TrimSpace(q.Query)
+	if q.Query == "" {
+		return q, false, fmt.Errorf("%w: query is required", ErrInvalidSavedQuery)
+	}
+	declared := make(map[string]bool, len(q.Parameters))
+	for _, p := range q.Parameters {
+		if !placeholderPattern.MatchString("{" + p.Name + "}") {
+			return q, false, fmt.Errorf("%w: parameter name %q", ErrInvalidSavedQuery, p.Name)
+		}
+		if declared[p.Name] {
+			return q, false, fmt.Errorf("%w: parameter %q declared twice", ErrInvalidSavedQuery, p.Name)
+		}
+		declared[p.Name] = true
+	}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(q.Query, -1) {
+		if !declared[m[1]] {
+			declared[m[1]] = true
+			q.Parameters = append(q.Parameters, SavedQueryParam{Name: m[1], Required: true})
+		}
+	}
+
+	all, err := loadSavedQueries(store)
+	if err != nil {
+		return q, false, err
+	}
+	q.CreatedAt, q.UpdatedAt = now.UTC(), now.UTC()
+	created := true
+	for i, old := range all {
+		if old.Project == q.Project && old.Name == q.Name {
+			q.CreatedAt = old.CreatedAt
+			all[i] = q
+			created = false
+			break
+		}
+	}
+	if created {
+		all = append(all, q)
+	}
+	return q, created, saveSavedQueries(store, all)
+}
+
+// DeleteSavedQuery deletes project's query called name.
+func DeleteSavedQuery(store *meb.MEBStore, project, name string) error {
+	all, err := loadSavedQueries(store)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(all, func(q SavedQuery) bool { return q.Project == project && q.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrSavedQueryNotFound, name)
+	}
+	return saveSavedQueries(store, slices.Delete(all, i, i+1))
+}
+
+func loadSavedQueries(store *meb.MEBStore) ([]SavedQuery, error) {
+	ok, err := store.HasDocument(SavedQueriesKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := store.GetContentByKey(SavedQueriesKey)
+	if err != nil {
+		return nil, fmt.Errorf("load saved queries: %w", err)
+	}
+	var queries []SavedQuery
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("decode saved queries: %w", err)
+	}
+	return queries, nil
+}
+
+func saveSavedQueries(store *meb.MEBStore, queries []SavedQuery) error {
+	sort.Slice(queries, func(i, j int) bool {
+		if queries[i].Project != queries[j].Project {
+			return queries[i].Project < queries[j].Project
+		}
+		return queries[i].Name < queries[j].Name
+	})
+	data, err := json.Marshal(queries)
+	if err != nil {
+		return err
+	}
+	if err := store.AddDocument(SavedQueriesKey, data, nil, nil); err != nil {
+		return fmt.Errorf("save saved queries: %w", err)
+	}
+	return nil
+}
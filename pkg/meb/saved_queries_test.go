@@ -0,0 +1,83 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSavedQueries(t *testing.T) {
+	s := newQueryTestStore(t)
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	q := SavedQuery{
+		Name:       "defined-by",
+		Project:    "p",
+		Query:      `triples("{file}", "limit_test_defines", ?s)`,
+		Parameters: []SavedQueryParam{{Name: "file", Default: "pkg/limits/f1.go"}},
+	}
+	if _, _, err := SaveQuery(s, SavedQuery{Name: "execute", Project: "p", Query: q.Query}, created); !errors.Is(err, ErrInvalidSavedQuery) {
+		t.Errorf("saving a reserved name: err = %v, want ErrInvalidSavedQuery", err)
+	}
+	if _, isNew, err := SaveQuery(s, q, created); err != nil || !isNew {
+		t.Fatalf("SaveQuery = new %v, %v; want a new query", isNew, err)
+	}
+	other := SavedQuery{Name: "defined-by", Project: "other", Query: `triples(?f, "{pred}", ?s)`}
+	saved, _, err := SaveQuery(s, other, created)
+	if err != nil {
+		t.Fatalf("SaveQuery: %v", err)
+	}
+	if len(saved.Parameters) != 1 || saved.Parameters[0].Name != "pred" || !saved.Parameters[0].Required {
+		t.Errorf("undeclared placeholder gave parameters %+v, want a required pred", saved.Parameters)
+	}
+
+	// Updates keep the creation time
+	q.Description = "Symbols a file defines"
+	saved, isNew, err := SaveQuery(s, q, created.Add(time.Hour))
+	if err != nil || isNew {
+		t.Fatalf("SaveQuery = new %v, %v; want an update", isNew, err)
+	}
+	if !saved.CreatedAt.Equal(created) || !saved.UpdatedAt.Equal(created.Add(time.Hour)) {
+		t.Errorf("updated query created %v, updated %v", saved.CreatedAt, saved.UpdatedAt)
+	}
+
+	list, err := SavedQueries(s, "p")
+	if err != nil || len(list) != 1 || list[0].Description != q.Description {
+		t.Fatalf("SavedQueries(p) = %+v, %v", list, err)
+	}
+	if all, _ := SavedQueries(s, ""); len(all) != 2 {
+		t.Errorf("SavedQueries of every project = %d queries, want 2", len(all))
+	}
+
+	got, err := GetSavedQuery(s, "p", "defined-by")
+	if err != nil {
+		t.Fatalf("GetSavedQuery: %v", err)
+	}
+	query, err := got.Bind(nil)
+	if err != nil {
+		t.Fatalf("Bind with defaults: %v", err)
+	}
+	rows, err := Query(context.Background(), s, query)
+	if err != nil || len(rows) != 1 || rows[0]["?s"] != "Sym1" {
+		t.Errorf("%s = %v, %v; want Sym1", query, rows, err)
+	}
+	if query, _ := got.Bind(map[string]string{"file": "pkg/limits/f2.go"}); query != `triples("pkg/limits/f2.go", "limit_test_defines", ?s)` {
+		t.Errorf("Bind = %s", query)
+	}
+	for _, params := range []map[string]string{{"file": `a", ?x) ; triples(?y`}, {"nope": "x"}} {
+		if _, err := got.Bind(params); !errors.Is(err, ErrInvalidSavedQuery) {
+			t.Errorf("Bind(%v): err = %v, want ErrInvalidSavedQuery", params, err)
+		}
+	}
+
+	if err := DeleteSavedQuery(s, "p", "defined-by"); err != nil {
+		t.Fatalf("DeleteSavedQuery: %v", err)
+	}
+	if _, err := GetSavedQuery(s, "p", "defined-by"); !errors.Is(err, ErrSavedQueryNotFound) {
+		t.Errorf("GetSavedQuery after delete: err = %v, want ErrSavedQueryNotFound", err)
+	}
+	if _, err := GetSavedQuery(s, "other", "defined-by"); err != nil {
+		t.Errorf("deleting p's query deleted other's: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package meb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// ErrInvalidCursor is returned when a continuation token is malformed or was issued
+// before the store changed.
+var ErrInvalidCursor = errors.New("invalid scan cursor")
+
+// ScanOptions narrows and pages a fact scan. Filters are applied as the scan
+// iterates, cheapest first; empty ones keep every fact.
+type ScanOptions struct {
+	Limit           int      // Maximum facts per page; <= 0 returns everything
+	Cursor          string   // Continuation token from a previous ScanPage
+	SubjectPrefixes []string // Keep facts whose subject starts with any of these
+	ObjectPrefix    string   // Keep facts whose object starts with this
+	ObjectKinds     []string // Keep facts whose object has a type fact naming one of these, such as "function"
+	MinWeight       float64  // Keep facts weighing at least this (0: no bound); facts weigh 1 unless weighted
+	MaxWeight       float64  // Keep facts weighing at most this (0: no bound)
+	Graphs          []string // Keep facts of any of these projects' graphs, whose subject the project owns
+
+	// Index, when it is fresh and the scan is of a structural predicate with no
+	// subject, serves the scan from memory instead of the store. Its facts come
+	// in order, by object when ObjectPrefix is set and by subject otherwise, so
+	// the prefixes are ranges of them and a cursor resumes with a seek.
+	Index *Adjacency
+}
+
+// ScanPage is one page of a paged scan.
+type ScanPage struct {
+	Facts      []meb.Fact
+	NextCursor string // Empty once the scan is exhausted
+}
+
+// ScanMatching streams the facts matching the S/P/O pattern and the filters in
+// opts within the scope on ctx, stopping after opts.Limit of them when it is
+// positive. Cursor is ignored.
+func ScanMatching(ctx context.Context, store *meb.MEBStore, subj, pred, obj string, opts ScanOptions) iter.Seq2[meb.Fact, error] {
+	return scanAfter(ctx, store, subj, pred, obj, opts, nil)
+}
+
+// scanAfter is ScanMatching resuming after the fact a cursor names, or from
+// the start when after is nil.
+func scanAfter(ctx context.Context, store *meb.MEBStore, subj, pred, obj string, opts ScanOptions, after *cursorKey) iter.Seq2[meb.Fact, error] {
+	return func(yield func(meb.Fact, error) bool) {
+		f := newScanFilter(ctx, store, opts)
+		matched := 0
+		for fact, err := range candidateFacts(ctx, store, subj, pred, obj, opts, after) {
+			if err != nil {
+				if !yield(meb.Fact{}, err) {
+					return
+				}
+				continue
+			}
+			if !f.matches(fact) {
+				continue
+			}
+			if !yield(fact, nil) {
+				return
+			}
+			if matched++; opts.Limit > 0 && matched == opts.Limit {
+				return
+			}
+		}
+	}
+}
+
+// ScanPaged returns one page of the facts matching the S/P/O pattern and prefix filters.
+// The cursor names the last fact returned together with the fact count it was issued
+// against; a cursor from before an ingest is rejected with ErrInvalidCursor rather than
+// skipping or repeating facts. A scan opts.Index serves seeks to that fact; the store
+// offers no seek, so other scans read up to it again.
+func ScanPaged(ctx context.Context, store *meb.MEBStore, subj, pred, obj string, opts ScanOptions) (*ScanPage, error) {
+	factCount := store.Count()
+	var after *cursorKey
+	if opts.Cursor != "" {
+		var err error
+		after, err = decodeCursor(opts.Cursor, factCount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page := &ScanPage{Facts: []meb.Fact{}}
+	all := opts
+	all.Limit = 0 // The page counts its own limit, seeing one match past it
+	for fact, err := range scanAfter(ctx, store, subj, pred, obj, all, after) {
+		if err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		if opts.Limit > 0 && len(page.Facts) == opts.Limit {
+			page.NextCursor = encodeCursor(factCount, keyOf(page.Facts[len(page.Facts)-1]))
+			break
+		}
+		page.Facts = append(page.Facts, fact)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// candidateFacts yields the facts of the S/P/O pattern that follow after, from
+// opts.Index when it serves the scan. The filters of opts are left to the
+// caller; the index only uses the prefixes to skip to the facts they allow.
+func candidateFacts(ctx context.Context, store *meb.MEBStore, subj, pred, obj string, opts ScanOptions, after *cursorKey) iter.Seq2[meb.Fact, error] {
+	if _, pinned := AsOfVersion(ctx); !pinned && subj == "" && opts.Index.Fresh(store) && slices.Contains(config.StructuralPredicates, pred) {
+		return opts.Index.facts(pred, obj, opts, after)
+	}
+	facts := Scan(ctx, store, subj, pred, obj)
+	if after == nil {
+		return facts
+	}
+	return func(yield func(meb.Fact, error) bool) {
+		resumed := false
+		for fact, err := range facts {
+			if !resumed {
+				resumed = err == nil && keyOf(fact) == *after
+				continue
+			}
+			if !yield(fact, err) {
+				return
+			}
+		}
+	}
+}
+
+// scanFilter applies the filters of ScanOptions to the facts of one scan,
+// loading the weights and the objects' types it needs as it goes.
+type scanFilter struct {
+	ctx     context.Context
+	store   *meb.MEBStore
+	opts    ScanOptions
+	weights *weightResolver
+	kinds   map[string]bool // Wanted object kinds
+	objects map[string]bool // Whether each object seen has one of them
+}
+
+func newScanFilter(ctx context.Context, store *meb.MEBStore, opts ScanOptions) *scanFilter {
+	f := &scanFilter{ctx: ctx, store: store, opts: opts, weights: newWeightResolver(store)}
+	if len(opts.ObjectKinds) > 0 {
+		f.kinds = make(map[string]bool, len(opts.ObjectKinds))
+		for _, kind := range opts.ObjectKinds {
+			f.kinds[kind] = true
+		}
+		f.objects = make(map[string]bool)
+	}
+	return f
+}
+
+func (f *scanFilter) matches(fact meb.Fact) bool {
+	o := f.opts
+	if !o.matches(fact) {
+		return false
+	}
+	if len(o.Graphs) > 0 {
+		owned := false
+		for _, project := range o.Graphs {
+			if ProjectScope(project).Owns(fact.Subject) {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			return false
+		}
+	}
+	if o.MinWeight > 0 || o.MaxWeight > 0 {
+		w := f.weights.weight(fact.Subject, fact.Predicate, fmt.Sprint(fact.Object))
+		if (o.MinWeight > 0 && w < o.MinWeight) || (o.MaxWeight > 0 && w > o.MaxWeight) {
+			return false
+		}
+	}
+	if f.kinds != nil {
+		obj, ok := fact.Object.(string)
+		if !ok {
+			return false
+		}
+		matched, seen := f.objects[obj]
+		if !seen {
+			for typeFact, err := range Scan(f.ctx, f.store, obj, config.PredicateType, "") {
+				if kind, ok := typeFact.Object.(string); err == nil && ok && f.kinds[kind] {
+					matched = true
+					break
+				}
+			}
+			f.objects[obj] = matched
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matches applies the prefix filters, which need no lookups.
+func (o ScanOptions) matches(fact meb.Fact) bool {
+	if len(o.SubjectPrefixes) > 0 {
+		matched := false
+		for _, prefix := range o.SubjectPrefixes {
+			if strings.HasPrefix(fact.Subject, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if o.ObjectPrefix != "" {
+		obj, ok := fact.Object.(string)
+		if !ok {
+			obj = fmt.Sprintf("%v", fact.Object)
+		}
+		if !strings.HasPrefix(obj, o.ObjectPrefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// cursorKey names a fact, for a cursor to resume after.
+type cursorKey struct {
+	Subject   string `json:"s"`
+	Predicate string `json:"p"`
+	Object    string `json:"o"`
+}
+
+func keyOf(fact meb.Fact) cursorKey {
+	obj, ok := fact.Object.(string)
+	if !ok {
+		obj = fmt.Sprintf("%v", fact.Object)
+	}
+	return cursorKey{Subject: fact.Subject, Predicate: fact.Predicate, Object: obj}
+}
+
+// scanCursor is the content of a continuation token.
+type scanCursor struct {
+	Facts uint64    `json:"n"` // Store fact count it was issued against
+	After cursorKey `json:"after"`
+}
+
+func encodeCursor(factCount uint64, after cursorKey) string {
+	raw, _ := json.Marshal(scanCursor{Facts: factCount, After: after})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(cursor string, factCount uint64) (*cursorKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c scanCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidCursor)
+	}
+	if c.Facts != factCount {
+		return nil, fmt.Errorf("%w: the project changed since this cursor was issued", ErrInvalidCursor)
+	}
+	return &c.After, nil
+}
@@ -0,0 +1,233 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestScanPaged(t *testing.T) {
+	s := newQueryTestStore(t)
+	ctx := context.Background()
+
+	var all []string
+	opts := ScanOptions{Limit: 4}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("paging did not terminate")
+		}
+		page, err := ScanPaged(ctx, s, "", "limit_test_defines", "", opts)
+		if err != nil {
+			t.Fatalf("ScanPaged: %v", err)
+		}
+		if len(page.Facts) > 4 {
+			t.Fatalf("page has %d facts, want at most 4", len(page.Facts))
+		}
+		for _, f := range page.Facts {
+			all = append(all, f.Subject)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	seen := make(map[string]bool)
+	for _, subj := range all {
+		if seen[subj] {
+			t.Errorf("subject %s returned twice", subj)
+		}
+		seen[subj] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("paged over %d subjects, want 10", len(seen))
+	}
+
+	// A cursor issued before the store changed is rejected
+	page, err := ScanPaged(ctx, s, "", "limit_test_defines", "", ScanOptions{Limit: 4})
+	if err != nil {
+		t.Fatalf("ScanPaged: %v", err)
+	}
+	if err := s.AddFact(meb.Fact{Subject: "pkg/limits/extra.go", Predicate: "limit_test_defines", Object: "Extra"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ScanPaged(ctx, s, "", "limit_test_defines", "", ScanOptions{Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("stale cursor error = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := ScanPaged(ctx, s, "", "limit_test_defines", "", ScanOptions{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("malformed cursor error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestScanPagedPrefixes(t *testing.T) {
+	s := newQueryTestStore(t)
+
+	page, err := ScanPaged(context.Background(), s, "", "limit_test_defines", "", ScanOptions{
+		SubjectPrefixes: []string{"pkg/limits/f1", "pkg/limits/f2"},
+		ObjectPrefix:    "Sym",
+	})
+	if err != nil {
+		t.Fatalf("ScanPaged: %v", err)
+	}
+	if len(page.Facts) != 2 || page.NextCursor != "" {
+		t.Errorf("got %d facts, cursor %q; want 2 facts and no cursor", len(page.Facts), page.NextCursor)
+	}
+
+	page, err = ScanPaged(context.Background(), s, "", "limit_test_defines", "", ScanOptions{ObjectPrefix: "Sym7"})
+	if err != nil {
+		t.Fatalf("ScanPaged: %v", err)
+	}
+	if len(page.Facts) != 1 || page.Facts[0].Subject != "pkg/limits/f7.go" {
+		t.Errorf("object prefix matched %v, want f7.go", page.Facts)
+	}
+}
+
+func TestScanPagedIndex(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var facts []meb.Fact
+	for _, file := range []string{"api/a.go", "api/b.go", "cmd/main.go", "internal/c.go"} {
+		for _, pkg := range []string{"api", "apix", "cmd"} {
+			facts = append(facts, meb.Fact{Subject: file, Predicate: config.PredicateImports, Object: pkg})
+		}
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	index := BuildAdjacency(ctx, s, s.Count())
+
+	// Pages of the index resume at the cursor's fact, whatever the page size
+	page := func(opts ScanOptions) []string {
+		t.Helper()
+		var got []string
+		for pages := 0; ; pages++ {
+			if pages > len(facts) {
+				t.Fatal("paging did not terminate")
+			}
+			p, err := ScanPaged(ctx, s, "", config.PredicateImports, "", opts)
+			if err != nil {
+				t.Fatalf("ScanPaged: %v", err)
+			}
+			for _, f := range p.Facts {
+				got = append(got, f.Subject+" "+f.Object.(string))
+			}
+			if p.NextCursor == "" {
+				return got
+			}
+			opts.Cursor = p.NextCursor
+		}
+	}
+	for _, opts := range []ScanOptions{
+		{SubjectPrefixes: []string{"api/", "api/b", "internal/"}},
+		{ObjectPrefix: "api"},
+		{ObjectPrefix: "api", SubjectPrefixes: []string{"cmd/"}},
+	} {
+		scanned := page(opts)
+		sort.Strings(scanned)
+		for _, limit := range []int{1, 2, 5} {
+			indexed := opts
+			indexed.Index, indexed.Limit = index, limit
+			got := page(indexed)
+			if !slices.IsSortedFunc(got, func(a, b string) int {
+				if opts.ObjectPrefix != "" { // In object order
+					a, b = a[strings.Index(a, " "):], b[strings.Index(b, " "):]
+				}
+				return strings.Compare(a, b)
+			}) {
+				t.Errorf("%+v limit %d: facts out of order: %v", opts, limit, got)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, scanned) {
+				t.Errorf("%+v limit %d: index gave %v, store %v", opts, limit, got, scanned)
+			}
+		}
+	}
+
+	// A stale index is not used
+	if err := s.AddFact(meb.Fact{Subject: "web/d.go", Predicate: config.PredicateImports, Object: "api"}); err != nil {
+		t.Fatal(err)
+	}
+	got := page(ScanOptions{ObjectPrefix: "api", SubjectPrefixes: []string{"web/"}, Index: index})
+	if !slices.Equal(got, []string{"web/d.go api"}) {
+		t.Errorf("stale index: got %v", got)
+	}
+}
+
+func TestScanMatchingFilters(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "app/a.go:F", Predicate: config.PredicateCalls, Object: "app/b.go:G"},
+		{Subject: "app/a.go:F", Predicate: config.PredicateCalls, Object: "app/b.go:T"},
+		{Subject: "lib/x.go:H", Predicate: config.PredicateCalls, Object: "app/b.go:G"},
+		{Subject: "app/b.go:G", Predicate: config.PredicateType, Object: "function"},
+		{Subject: "app/b.go:T", Predicate: config.PredicateType, Object: "struct"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateFactWeight(s, meb.Fact{Subject: "app/a.go:F", Predicate: config.PredicateCalls, Object: "app/b.go:G"}, 0.2); err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func(opts ScanOptions) []string {
+		t.Helper()
+		var got []string
+		for fact, err := range ScanMatching(context.Background(), s, "", config.PredicateCalls, "", opts) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, fact.Subject+" "+fact.Object.(string))
+		}
+		sort.Strings(got)
+		return got
+	}
+	cases := []struct {
+		name string
+		opts ScanOptions
+		want []string
+	}{
+		{"object kinds", ScanOptions{ObjectKinds: []string{"function", "method"}}, []string{"app/a.go:F app/b.go:G", "lib/x.go:H app/b.go:G"}},
+		{"min weight", ScanOptions{MinWeight: 0.5}, []string{"app/a.go:F app/b.go:T", "lib/x.go:H app/b.go:G"}},
+		{"max weight", ScanOptions{MaxWeight: 0.5}, []string{"app/a.go:F app/b.go:G"}},
+		{"graphs", ScanOptions{Graphs: []string{"lib"}}, []string{"lib/x.go:H app/b.go:G"}},
+		{"combined", ScanOptions{Graphs: []string{"app", "lib"}, ObjectKinds: []string{"function"}, MinWeight: 0.5}, []string{"lib/x.go:H app/b.go:G"}},
+	}
+	for _, c := range cases {
+		if got := scan(c.opts); !slices.Equal(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+	if got := scan(ScanOptions{Limit: 2}); len(got) != 2 {
+		t.Errorf("limit 2 returned %d facts", len(got))
+	}
+
+	// Paging counts filtered facts only
+	page, err := ScanPaged(context.Background(), s, "", config.PredicateCalls, "", ScanOptions{ObjectKinds: []string{"function"}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Facts) != 1 || page.NextCursor == "" {
+		t.Fatalf("first page: %d facts, cursor %q", len(page.Facts), page.NextCursor)
+	}
+	page, err = ScanPaged(context.Background(), s, "", config.PredicateCalls, "", ScanOptions{ObjectKinds: []string{"function"}, Limit: 1, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Facts) != 1 || page.NextCursor != "" {
+		t.Errorf("second page: %d facts, cursor %q", len(page.Facts), page.NextCursor)
+	}
+}
@@ -0,0 +1,171 @@
+package meb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/meb"
+)
+
+// Several projects can be ingested into one store. Ingest writes each project
+// under its own topic, which meb packs into subject and object IDs, and prefixes
+// every file and symbol ID with the project name. A Scope carried on the context
+// restricts scans to one of those projects; without one, scans see the whole store
+// as before.
+
+// ProjectRegistryKey is the document listing the projects ingested into a store.
+const ProjectRegistryKey = "gca:projects"
+
+// Scope is one project's graph context inside a shared store.
+type Scope struct {
+	Project string
+	Topic   uint32
+}
+
+type scopeKey struct{}
+
+// TopicForProject returns the deterministic 24-bit topic ID ingest uses for a project.
+func TopicForProject(name string) uint32 {
+	if name == "" {
+		return 1
+	}
+	var h uint32 = 2166136261 // FNV-1a offset basis
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619 // FNV-1a prime
+	}
+	return (h & 0xFFFFFF) | 1 // ensure non-zero (0 is reserved)
+}
+
+// ProjectScope returns the scope of a project ingested under its own name.
+func ProjectScope(projectID string) Scope {
+	return Scope{Project: projectID, Topic: TopicForProject(projectID)}
+}
+
+// WithScope returns a context whose scans are restricted to sc.
+func WithScope(ctx context.Context, sc Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, sc)
+}
+
+// ScopeFrom returns the scope carried by ctx, if any.
+func ScopeFrom(ctx context.Context) (Scope, bool) {
+	sc, ok := ctx.Value(scopeKey{}).(Scope)
+	return sc, ok
+}
+
+// Owns reports whether id belongs to the project: the project node itself or
+// anything under its path prefix.
+func (sc Scope) Owns(id string) bool {
+	return id == sc.Project || strings.HasPrefix(id, sc.Project+"/")
+}
+
+// InScope reports whether id belongs to the scope on ctx; everything is in scope
+// when ctx carries none. Lookups that bypass Scan, such as vector search, filter
+// their hits with it.
+func InScope(ctx context.Context, id string) bool {
+	sc, ok := ScopeFrom(ctx)
+	return !ok || sc.Owns(id)
+}
+
+// Scan streams the facts matching the S/P/O pattern within the scope on ctx.
+// With a bound subject or object the lookup is packed with the project's topic.
+// meb's topic prefix for fully unbound scans only covers a single key, so those
+// fall back to a store-wide scan filtered by the project's ID prefix.
+// A context from AsOf also rolls the scan back to that ingest version.
+func Scan(ctx context.Context, store *meb.MEBStore, subj, pred, obj string) iter.Seq2[meb.Fact, error] {
+	return asOf(ctx, scopedScan(ctx, store, subj, pred, obj), subj, pred, obj)
+}
+
+func scopedScan(ctx context.Context, store *meb.MEBStore, subj, pred, obj string) iter.Seq2[meb.Fact, error] {
+	sc, ok := ScopeFrom(ctx)
+	if !ok {
+		return store.ScanContext(ctx, subj, pred, obj)
+	}
+	if subj != "" || obj != "" {
+		return store.ScanInTopicContext(ctx, sc.Topic, subj, pred, obj)
+	}
+	return ownedBy(sc, store.ScanContext(ctx, "", pred, ""))
+}
+
+// TxnScan is Scan inside a read transaction.
+func TxnScan(ctx context.Context, txn *meb.StoreTxn, subj, pred, obj string) iter.Seq2[meb.Fact, error] {
+	return asOf(ctx, scopedTxnScan(ctx, txn, subj, pred, obj), subj, pred, obj)
+}
+
+func scopedTxnScan(ctx context.Context, txn *meb.StoreTxn, subj, pred, obj string) iter.Seq2[meb.Fact, error] {
+	sc, ok := ScopeFrom(ctx)
+	if !ok {
+		return txn.Scan(subj, pred, obj)
+	}
+	if subj != "" || obj != "" {
+		return txn.ScanInTopic(sc.Topic, subj, pred, obj)
+	}
+	return ownedBy(sc, txn.Scan("", pred, ""))
+}
+
+func asOf(ctx context.Context, facts iter.Seq2[meb.Fact, error], subj, pred, obj string) iter.Seq2[meb.Fact, error] {
+	view, ok := ctx.Value(asOfKey{}).(*versionView)
+	if !ok {
+		return facts
+	}
+	return view.overlay(ctx, facts, subj, pred, obj)
+}
+
+func ownedBy(sc Scope, facts iter.Seq2[meb.Fact, error]) iter.Seq2[meb.Fact, error] {
+	return func(yield func(meb.Fact, error) bool) {
+		for fact, err := range facts {
+			if err == nil && !sc.Owns(fact.Subject) {
+				continue
+			}
+			if !yield(fact, err) {
+				return
+			}
+		}
+	}
+}
+
+// RegisterProject records projectID in the store's project registry.
+func RegisterProject(store *meb.MEBStore, projectID string) error {
+	projects, err := RegisteredProjects(store)
+	if err != nil {
+		return err
+	}
+	i := sort.SearchStrings(projects, projectID)
+	if i < len(projects) && projects[i] == projectID {
+		return nil
+	}
+	projects = append(projects, "")
+	copy(projects[i+1:], projects[i:])
+	projects[i] = projectID
+
+	data, err := json.Marshal(projects)
+	if err != nil {
+		return err
+	}
+	if err := store.AddDocument(ProjectRegistryKey, data, nil, nil); err != nil {
+		return fmt.Errorf("save project registry: %w", err)
+	}
+	return nil
+}
+
+// RegisteredProjects returns the sorted IDs of the projects ingested into the store.
+func RegisteredProjects(store *meb.MEBStore) ([]string, error) {
+	ok, err := store.HasDocument(ProjectRegistryKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := store.GetContentByKey(ProjectRegistryKey)
+	if err != nil {
+		return nil, fmt.Errorf("load project registry: %w", err)
+	}
+	var projects []string
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("decode project registry: %w", err)
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
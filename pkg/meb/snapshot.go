@@ -0,0 +1,270 @@
+package meb
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+)
+
+// A snapshot bundle is a gzipped tar of a store directory: the Badger files
+// holding its facts, dictionary and vectors, the WAL, and project metadata,
+// followed by a manifest listing every file with its checksum. Read replicas
+// download bundles from a primary and serve them as they are.
+
+// SnapshotFormat is the bundle format version written to the manifest.
+const SnapshotFormat = 1
+
+// SnapshotManifestName is the name of the manifest entry, the last of a bundle.
+const SnapshotManifestName = "manifest.json"
+
+// ErrBadSnapshot is returned when a bundle is malformed or fails verification.
+var ErrBadSnapshot = errors.New("invalid snapshot bundle")
+
+// SnapshotManifest describes the store a bundle was made from.
+type SnapshotManifest struct {
+	Format    int            `json:"format"`
+	Project   string         `json:"project"`
+	Facts     uint64         `json:"facts"`
+	ETag      string         `json:"etag"`
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []SnapshotFile `json:"files"`
+}
+
+// SnapshotFile is one file of a bundle, by its slash-separated path in the store directory.
+type SnapshotFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// snapshotSkip lists the files of a store directory left out of bundles: the
+// Badger directory lock, the marker of an open writer and, in a replica, the
+// ETag of the bundle the store came from.
+var snapshotSkip = map[string]bool{
+	"LOCK":                 true,
+	config.DirtyMarkerFile: true,
+	config.ReplicaETagFile: true,
+}
+
+// snapshotFiles lists the files of the store in dir that go into a bundle,
+// sorted by path.
+func snapshotFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || snapshotSkip[d.Name()] {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list store files in %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// SnapshotETag identifies the state of the store in dir by the names, sizes
+// and modification times of its files, so a replica can tell whether its copy
+// is current without downloading a bundle. The store must not be written to
+// while it is computed.
+func SnapshotETag(dir string) (string, error) {
+	files, err := snapshotFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, f := range files {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			return "", fmt.Errorf("stat %s: %w", f, err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", f, info.Size(), info.ModTime().UnixNano())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+// WriteSnapshot writes a bundle of the store in dir to w, completing m with
+// the files written. The store must not be written to while the bundle is made.
+func WriteSnapshot(w io.Writer, dir string, m *SnapshotManifest) error {
+	files, err := snapshotFiles(dir)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	m.Format = SnapshotFormat
+	m.Files = m.Files[:0]
+	for _, f := range files {
+		entry, err := writeSnapshotFile(tw, dir, f)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, entry)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal snapshot manifest: %w", err)
+	}
+	hdr := &tar.Header{Name: SnapshotManifestName, Mode: 0o644, Size: int64(len(data)), ModTime: m.CreatedAt}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write snapshot manifest: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("write snapshot manifest: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+	return gz.Close()
+}
+
+// writeSnapshotFile adds the file f of dir to tw, returning its manifest entry.
+func writeSnapshotFile(tw *tar.Writer, dir, f string) (SnapshotFile, error) {
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(f)))
+	if err != nil {
+		return SnapshotFile{}, fmt.Errorf("open %s: %w", f, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return SnapshotFile{}, fmt.Errorf("stat %s: %w", f, err)
+	}
+	hdr := &tar.Header{Name: "store/" + f, Mode: 0o644, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return SnapshotFile{}, fmt.Errorf("write %s: %w", f, err)
+	}
+	h := sha256.New()
+	// The size in the header is binding: a file that changed under us fails here
+	if _, err := io.Copy(tw, io.TeeReader(io.LimitReader(file, info.Size()), h)); err != nil {
+		return SnapshotFile{}, fmt.Errorf("write %s: %w", f, err)
+	}
+	return SnapshotFile{Path: f, Size: info.Size(), SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// ReadSnapshot extracts a bundle from r into dir, which is created if missing
+// and must hold none of the bundle's files, and verifies every file against the
+// manifest. On error dir is left for the caller to remove.
+func ReadSnapshot(r io.Reader, dir string) (*SnapshotManifest, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create snapshot dir: %w", err)
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	got := make(map[string]SnapshotFile)
+	var manifest *SnapshotManifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+		}
+		if manifest != nil {
+			return nil, fmt.Errorf("%w: entries after the manifest", ErrBadSnapshot)
+		}
+		if hdr.Name == SnapshotManifestName {
+			manifest = &SnapshotManifest{}
+			if err := json.NewDecoder(io.LimitReader(tr, 64<<20)).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%w: manifest: %v", ErrBadSnapshot, err)
+			}
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrBadSnapshot, hdr.Name)
+		}
+		entry, err := readSnapshotFile(tr, hdr, dir)
+		if err != nil {
+			return nil, err
+		}
+		got[entry.Path] = entry
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: no manifest", ErrBadSnapshot)
+	}
+	if manifest.Format != SnapshotFormat {
+		return nil, fmt.Errorf("%w: format %d, want %d", ErrBadSnapshot, manifest.Format, SnapshotFormat)
+	}
+	if len(manifest.Files) != len(got) {
+		return nil, fmt.Errorf("%w: %d files, manifest lists %d", ErrBadSnapshot, len(got), len(manifest.Files))
+	}
+	for _, want := range manifest.Files {
+		if got[want.Path] != want {
+			return nil, fmt.Errorf("%w: %s does not match the manifest", ErrBadSnapshot, want.Path)
+		}
+	}
+	return manifest, nil
+}
+
+// readSnapshotFile extracts the file entry hdr of a bundle under dir.
+func readSnapshotFile(tr *tar.Reader, hdr *tar.Header, dir string) (SnapshotFile, error) {
+	name, ok := cutStorePrefix(hdr.Name)
+	if !ok {
+		return SnapshotFile{}, fmt.Errorf("%w: unexpected entry %q", ErrBadSnapshot, hdr.Name)
+	}
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return SnapshotFile{}, err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return SnapshotFile{}, fmt.Errorf("extract %s: %w", name, err)
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), tr)
+	if cerr := out.Close(); err == nil && cerr != nil {
+		return SnapshotFile{}, fmt.Errorf("extract %s: %w", name, cerr)
+	}
+	if err != nil {
+		// Mostly a truncated or corrupt download
+		return SnapshotFile{}, fmt.Errorf("%w: extract %s: %v", ErrBadSnapshot, name, err)
+	}
+	return SnapshotFile{Path: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// cutStorePrefix returns the path of a bundle's file entry within the store
+// directory, refusing paths that would leave it.
+func cutStorePrefix(name string) (string, bool) {
+	rel, ok := strings.CutPrefix(name, "store/")
+	if !ok {
+		return "", false
+	}
+	rel = path.Clean(rel)
+	if rel == "." || path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return rel, true
+}
@@ -0,0 +1,97 @@
+package meb
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	s, err := meb.NewMEBStore(store.DefaultConfig(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "a.go:A", Predicate: config.PredicateCalls, Object: "b.go:B"},
+		{Subject: "b.go:B", Predicate: config.PredicateCalls, Object: "c.go:C"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutDocument(s, s.TopicID(), "a.go", []byte("package a"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkDirty(src); err != nil {
+		t.Fatal(err)
+	}
+
+	etag, err := SnapshotETag(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := SnapshotETag(src); again != etag {
+		t.Errorf("SnapshotETag changed on an untouched store: %s, %s", etag, again)
+	}
+
+	var bundle bytes.Buffer
+	m := &SnapshotManifest{Project: "demo", Facts: 2, ETag: etag, CreatedAt: time.Now()}
+	if err := WriteSnapshot(&bundle, src, m); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range m.Files {
+		if f.Path == config.DirtyMarkerFile {
+			t.Errorf("bundle includes the dirty marker")
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "demo")
+	got, err := ReadSnapshot(bytes.NewReader(bundle.Bytes()), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Project != "demo" || got.ETag != etag || len(got.Files) != len(m.Files) {
+		t.Errorf("manifest = %+v, want %+v", got, m)
+	}
+	replica, err := meb.NewMEBStore(store.DefaultConfig(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+	if replica.Count() != 2 {
+		t.Errorf("replica Count() = %d, want 2", replica.Count())
+	}
+	content, err := GetDocument(replica, "a.go")
+	if err != nil || string(content) != "package a" {
+		t.Errorf("replica document = %q, %v", content, err)
+	}
+
+	// A flipped byte fails the checksum
+	corrupt := bytes.Clone(bundle.Bytes())
+	corrupt[len(corrupt)/2] ^= 0xff
+	if _, err := ReadSnapshot(bytes.NewReader(corrupt), filepath.Join(t.TempDir(), "x")); !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("ReadSnapshot(corrupt) = %v, want ErrBadSnapshot", err)
+	}
+}
+
+func TestCutStorePrefix(t *testing.T) {
+	for name, want := range map[string]bool{
+		"store/badger/MANIFEST":  true,
+		"store/../../etc/passwd": false,
+		"store/..":               false,
+		"store/":                 false,
+		"other/file":             false,
+	} {
+		if _, ok := cutStorePrefix(name); ok != want {
+			t.Errorf("cutStorePrefix(%q) ok = %v, want %v", name, ok, want)
+		}
+	}
+}
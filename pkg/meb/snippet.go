@@ -0,0 +1,226 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// ErrNoSnippet is returned when neither a symbol nor its parent file has stored content.
+var ErrNoSnippet = errors.New("no source available for symbol")
+
+// GetSymbolSnippet returns the source of a symbol. Ingestion stores symbols
+// without content, so the code is cut out of the parent file document
+// ("pkg/a.go" for "pkg/a.go:Foo") using the symbol's start_line..end_line.
+// A symbol with its own content returns that instead, and one without a line
+// range returns the whole file. Line facts are read within the scope on ctx.
+func GetSymbolSnippet(ctx context.Context, store *meb.MEBStore, id string) (string, error) {
+	var snippet string
+	err := store.View(func(txn *meb.StoreTxn) error {
+		fetch := TxnContentFetcher(txn)
+		if raw, err := fetch(id); err == nil && len(raw) > 0 {
+			content, err := DecodeContent(raw, fetch)
+			if err != nil {
+				return err
+			}
+			snippet = string(content)
+			return nil
+		}
+
+		idx := strings.Index(id, ":")
+		if idx == -1 {
+			return ErrNoSnippet
+		}
+		raw, err := fetch(id[:idx])
+		if err != nil || len(raw) == 0 {
+			return ErrNoSnippet
+		}
+		content, err := DecodeContent(raw, fetch)
+		if err != nil {
+			return err
+		}
+
+		start, end, ok := symbolLineRange(ctx, txn, id)
+		if !ok {
+			snippet = string(content)
+			return nil
+		}
+		snippet = SnippetLines(strings.Split(string(content), "\n"), start, end)
+		return nil
+	})
+	return snippet, err
+}
+
+// SnippetLines joins lines start..end (1-based, inclusive), clamped to the file.
+func SnippetLines(lines []string, start, end int) string {
+	from := start - 1
+	if from < 0 {
+		from = 0
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if from >= end {
+		return ""
+	}
+	return strings.Join(lines[from:end], "\n")
+}
+
+// symbolLineRange reads a symbol's start_line and end_line facts.
+func symbolLineRange(ctx context.Context, txn *meb.StoreTxn, id string) (start, end int, ok bool) {
+	var hasStart, hasEnd bool
+	for fact, err := range TxnScan(ctx, txn, id, "", "") {
+		if err != nil {
+			continue
+		}
+		switch fact.Predicate {
+		case config.PredicateStartLine:
+			start, hasStart = lineNumber(fact.Object)
+		case config.PredicateEndLine:
+			end, hasEnd = lineNumber(fact.Object)
+		}
+	}
+	return start, end, hasStart && hasEnd
+}
+
+func lineNumber(obj any) (int, bool) {
+	switch v := obj.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// SymbolSource is the source of a symbol cut out of its file, with the doc
+// comment above it and the file's imports its code uses.
+type SymbolSource struct {
+	ID        string   `json:"id"`
+	File      string   `json:"file"`
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	Code      string   `json:"code"`
+	Doc       string   `json:"doc,omitempty"`
+	DocStart  int      `json:"doc_start_line,omitempty"` // First line of Doc when it was read from the file
+	Imports   []string `json:"imports,omitempty"`
+}
+
+// GetSymbolSource returns the exact source range of a symbol from its
+// start_line and end_line facts. Doc is the comment block right above the
+// range, or the symbol's has_doc fact when the file has none there. Imports
+// are the file's imports whose package name appears in the code.
+func GetSymbolSource(ctx context.Context, store *meb.MEBStore, id string) (*SymbolSource, error) {
+	idx := strings.Index(id, ":")
+	if idx == -1 {
+		return nil, ErrNoSnippet
+	}
+	src := &SymbolSource{ID: id, File: id[:idx]}
+	err := store.View(func(txn *meb.StoreTxn) error {
+		fetch := TxnContentFetcher(txn)
+		raw, err := fetch(src.File)
+		if err != nil || len(raw) == 0 {
+			return ErrNoSnippet
+		}
+		content, err := DecodeContent(raw, fetch)
+		if err != nil {
+			return err
+		}
+		start, end, ok := symbolLineRange(ctx, txn, id)
+		if !ok {
+			return ErrNoSnippet
+		}
+		lines := strings.Split(string(content), "\n")
+		if end > len(lines) {
+			end = len(lines)
+		}
+		src.StartLine, src.EndLine = start, end
+		src.Code = SnippetLines(lines, start, end)
+		src.DocStart, src.Doc = commentAbove(lines, start)
+
+		for fact, err := range TxnScan(ctx, txn, id, config.PredicateHasDoc, "") {
+			if doc, ok := fact.Object.(string); err == nil && ok && src.Doc == "" {
+				src.Doc = doc
+			}
+		}
+		for fact, err := range TxnScan(ctx, txn, src.File, config.PredicateImports, "") {
+			if path, ok := fact.Object.(string); err == nil && ok && fact.Subject == src.File && usesImport(src.Code, path) {
+				src.Imports = append(src.Imports, path)
+			}
+		}
+		sort.Strings(src.Imports)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// commentAbove returns the first line and text of the comment block ending
+// right above line start (1-based): "//", "#" and "/* ... */" lines with no
+// blank line in between. It returns 0 and "" when there is none.
+func commentAbove(lines []string, start int) (int, string) {
+	first := start - 1
+	for i := start - 2; i >= 0 && i < len(lines); i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || !(strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "/*") || strings.HasPrefix(line, "*")) {
+			break
+		}
+		first = i
+	}
+	if first == start-1 {
+		return 0, ""
+	}
+	return first + 1, strings.Join(lines[first:start-1], "\n")
+}
+
+// usesImport reports whether code refers to an import by its package name:
+// the last element of its path, without a file extension or a Go major
+// version suffix ("github.com/x/yaml/v3" is "yaml", "./api/client.ts" is
+// "client").
+func usesImport(code, path string) bool {
+	parts := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	if len(parts) == 0 {
+		return false
+	}
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = parts[len(parts)-2]
+	}
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name = name[:i] // "client.ts", Python's "os.path"
+	}
+	if name == "" {
+		return false
+	}
+	for i := strings.Index(code, name); i != -1; {
+		end := i + len(name)
+		if (i == 0 || !isIdentByte(code[i-1])) && (end == len(code) || !isIdentByte(code[end])) {
+			return true
+		}
+		next := strings.Index(code[end:], name)
+		if next == -1 {
+			break
+		}
+		i = end + next
+	}
+	return false
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
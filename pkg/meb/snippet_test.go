@@ -0,0 +1,93 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+)
+
+func TestGetSymbolSnippet(t *testing.T) {
+	s := newQueryTestStore(t)
+	file := "package a\n\nfunc Foo() {\n\treturn\n}\n\nfunc Bar() {}\n"
+	if err := PutDocument(s, s.TopicID(), "p/a.go", []byte(file), nil); err != nil {
+		t.Fatalf("PutDocument: %v", err)
+	}
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "p/a.go:Foo", Predicate: "start_line", Object: 3},
+		{Subject: "p/a.go:Foo", Predicate: "end_line", Object: 5},
+		{Subject: "p/a.go:Bar", Predicate: "start_line", Object: 7},
+		{Subject: "p/a.go:Bar", Predicate: "end_line", Object: 40},
+	}); err != nil {
+		t.Fatalf("AddFactBatch: %v", err)
+	}
+
+	tests := []struct {
+		id, want string
+	}{
+		{"p/a.go:Foo", "func Foo() {\n\treturn\n}"},
+		{"p/a.go:Bar", "func Bar() {}\n"}, // end_line past EOF is clamped
+		{"p/a.go:NoRange", file},          // no line range: the whole file
+	}
+	for _, tt := range tests {
+		got, err := GetSymbolSnippet(context.Background(), s, tt.id)
+		if err != nil {
+			t.Errorf("GetSymbolSnippet(%s): %v", tt.id, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("GetSymbolSnippet(%s) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+
+	if _, err := GetSymbolSnippet(context.Background(), s, "p/missing.go:Foo"); !errors.Is(err, ErrNoSnippet) {
+		t.Errorf("GetSymbolSnippet(missing) error = %v, want ErrNoSnippet", err)
+	}
+}
+
+func TestGetSymbolSource(t *testing.T) {
+	s := newQueryTestStore(t)
+	file := "package a\n\nimport (\n\t\"fmt\"\n\t\"gopkg.in/yaml.v3\"\n\t\"github.com/acme/log/v2\"\n)\n\n// Foo prints a value.\n// It never fails.\nfunc Foo() {\n\tlog.Debug(fmt.Sprint(1))\n}\n\nfunc Bar() {}\n"
+	if err := PutDocument(s, s.TopicID(), "p/a.go", []byte(file), nil); err != nil {
+		t.Fatalf("PutDocument: %v", err)
+	}
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "p/a.go", Predicate: "imports", Object: "fmt"},
+		{Subject: "p/a.go", Predicate: "imports", Object: "gopkg.in/yaml.v3"},
+		{Subject: "p/a.go", Predicate: "imports", Object: "github.com/acme/log/v2"},
+		{Subject: "p/a.go:Foo", Predicate: "start_line", Object: 11},
+		{Subject: "p/a.go:Foo", Predicate: "end_line", Object: 13},
+		{Subject: "p/a.go:Bar", Predicate: "start_line", Object: 15},
+		{Subject: "p/a.go:Bar", Predicate: "end_line", Object: 15},
+		{Subject: "p/a.go:Bar", Predicate: "has_doc", Object: "Bar does nothing."},
+	}); err != nil {
+		t.Fatalf("AddFactBatch: %v", err)
+	}
+
+	src, err := GetSymbolSource(context.Background(), s, "p/a.go:Foo")
+	if err != nil {
+		t.Fatalf("GetSymbolSource(Foo): %v", err)
+	}
+	if src.File != "p/a.go" || src.StartLine != 11 || src.EndLine != 13 || src.Code != "func Foo() {\n\tlog.Debug(fmt.Sprint(1))\n}" {
+		t.Errorf("Foo source = %+v", src)
+	}
+	if src.DocStart != 9 || src.Doc != "// Foo prints a value.\n// It never fails." {
+		t.Errorf("Foo doc = %d %q", src.DocStart, src.Doc)
+	}
+	if len(src.Imports) != 2 || src.Imports[0] != "fmt" || src.Imports[1] != "github.com/acme/log/v2" {
+		t.Errorf("Foo imports = %v, want fmt and github.com/acme/log/v2", src.Imports)
+	}
+
+	src, err = GetSymbolSource(context.Background(), s, "p/a.go:Bar")
+	if err != nil {
+		t.Fatalf("GetSymbolSource(Bar): %v", err)
+	}
+	if src.Doc != "Bar does nothing." || src.DocStart != 0 || len(src.Imports) != 0 {
+		t.Errorf("Bar source = %+v, want the has_doc fact and no imports", src)
+	}
+
+	if _, err := GetSymbolSource(context.Background(), s, "p/a.go:NoRange"); !errors.Is(err, ErrNoSnippet) {
+		t.Errorf("GetSymbolSource(no range) error = %v, want ErrNoSnippet", err)
+	}
+}
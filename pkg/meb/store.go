@@ -3,7 +3,10 @@ package meb
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,7 +28,7 @@ type QueryCache struct {
 }
 
 type cacheEntry struct {
-	results   []map[string]any
+	result    QueryResult
 	expiresAt time.Time
 	createdAt time.Time
 }
@@ -43,23 +46,23 @@ func NewQueryCache(ttl time.Duration, maxSize int, enabled bool) *QueryCache {
 	return cache
 }
 
-func (c *QueryCache) get(key string) ([]map[string]any, bool) {
+func (c *QueryCache) get(key string) (QueryResult, bool) {
 	if !c.enabled {
-		return nil, false
+		return QueryResult{}, false
 	}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	entry, ok := c.entries[key]
 	if !ok {
-		return nil, false
+		return QueryResult{}, false
 	}
 	if time.Now().After(entry.expiresAt) {
-		return nil, false
+		return QueryResult{}, false
 	}
-	return entry.results, true
+	return entry.result, true
 }
 
-func (c *QueryCache) set(key string, results []map[string]any) {
+func (c *QueryCache) set(key string, result QueryResult) {
 	if !c.enabled {
 		return
 	}
@@ -69,7 +72,7 @@ func (c *QueryCache) set(key string, results []map[string]any) {
 		c.evictOldest()
 	}
 	c.entries[key] = &cacheEntry{
-		results:   results,
+		result:    result,
 		expiresAt: time.Now().Add(c.ttl),
 		createdAt: time.Now(),
 	}
@@ -121,17 +124,66 @@ func NewStore(db *meb.MEBStore) *Store {
 	return &Store{db}
 }
 
+// QueryOptions bounds the work a single query may do.
+type QueryOptions struct {
+	Limit   int           // Maximum rows returned; <= 0 uses config.QueryResultLimit
+	Timeout time.Duration // Execution deadline; <= 0 uses config.QueryTimeout
+	AsOf    int           // Read the store as of this ingest version; <= 0 reads the current store
+	// Provenance adds RowSourceKey and RowWeightKey to each row for the fact bound
+	// by the query's first triples atom, the one exported as a graph link
+	Provenance bool
+}
+
+// QueryResult holds the rows produced by a query and whether they are complete.
+type QueryResult struct {
+	Rows      []map[string]any `json:"results"`
+	Truncated bool             `json:"truncated,omitempty"` // The row limit was reached
+	TimedOut  bool             `json:"timed_out,omitempty"` // The deadline expired before the scan finished
+	Warnings  []string         `json:"warnings,omitempty"`
+}
+
 func Query(ctx context.Context, store *meb.MEBStore, q string) ([]map[string]any, error) {
 	return QueryWithLimit(ctx, store, q, config.QueryResultLimit)
 }
 
 func QueryWithLimit(ctx context.Context, store *meb.MEBStore, q string, limit int) ([]map[string]any, error) {
-	cacheKey := globalQueryCache.hashKey(q)
-	if cached, ok := globalQueryCache.get(cacheKey); ok {
-		if len(cached) > limit {
-			return cached[:limit], nil
+	res, err := QueryWithOptions(ctx, store, q, QueryOptions{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return res.Rows, nil
+}
+
+// QueryWithOptions executes a Datalog query under a row cap and an execution deadline.
+// Hitting either bound is not an error: the rows found so far are returned with
+// Truncated or TimedOut set and a warning describing why the result is partial.
+// Cancellation of ctx by the caller aborts the query with an error.
+func QueryWithOptions(ctx context.Context, store *meb.MEBStore, q string, opts QueryOptions) (*QueryResult, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = config.QueryResultLimit
+	}
+	if limit > config.QueryMaxResultLimit {
+		limit = config.QueryMaxResultLimit
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = config.QueryTimeout
+	}
+
+	if v, ok := AsOfVersion(ctx); opts.AsOf > 0 && (!ok || v != opts.AsOf) {
+		var err error
+		if ctx, err = AsOf(ctx, store, opts.AsOf); err != nil {
+			return nil, err
 		}
-		return cached, nil
+	}
+
+	// Keyed by store, scope, version, fact count and weight updates so results never leak across projects or survive an ingest
+	scope, scoped := ScopeFrom(ctx)
+	version, pinned := AsOfVersion(ctx)
+	cacheKey := globalQueryCache.hashKey(fmt.Sprintf("%p|%s|%d|%d|%d|%d|%t|%s", store, scope.Project, version, store.Count(), weightsGeneration.Load(), limit, opts.Provenance, q))
+	if cached, ok := globalQueryCache.get(cacheKey); ok {
+		return &cached, nil
 	}
 
 	atoms, err := datalog.Parse(q)
@@ -158,34 +210,55 @@ func QueryWithLimit(ctx context.Context, store *meb.MEBStore, q string, limit in
 		return nil, fmt.Errorf("query must contain at least one triples atom")
 	}
 
+	// The deadline also releases scan goroutines left behind when a join loop stops early
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	var results []map[string]any
+	var truncated bool
 
 	if len(triplesAtoms) == 1 {
-		results = executeSingleAtomQuery(ctx, store, triplesAtoms[0], limit)
+		results, truncated = executeSingleAtomQuery(execCtx, store, triplesAtoms[0], constraintAtoms, limit)
+	} else if scoped || pinned {
+		// LFTJ walks the raw indexes across every topic and version, so scoped
+		// and as-of joins go through Scan
+		results, truncated = executeSequentialJoinQuery(execCtx, store, triplesAtoms, constraintAtoms, limit)
 	} else {
-		results = executeLFTJQuery(ctx, store, triplesAtoms, limit)
-		if len(results) == 0 && len(triplesAtoms) > 1 {
+		results, truncated = executeLFTJQuery(execCtx, store, triplesAtoms, constraintAtoms, limit)
+		if len(results) == 0 && execCtx.Err() == nil {
 			logger.Debug("LFTJ engine returned no results, falling back to sequential join")
-			results = executeSequentialJoinQuery(ctx, store, triplesAtoms, limit)
+			results, truncated = executeSequentialJoinQuery(execCtx, store, triplesAtoms, constraintAtoms, limit)
 		}
 	}
 
-	results = applyConstraints(results, constraintAtoms)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return nil, fmt.Errorf("query canceled: %w", ctx.Err())
+	}
+	if opts.Provenance {
+		addProvenance(ctx, store, triplesAtoms[0], results)
+	}
 
-	if len(results) > limit {
-		results = results[:limit]
+	res := &QueryResult{Rows: results, Truncated: truncated}
+	if truncated {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("result limit of %d rows reached; results may be incomplete", limit))
+	}
+	if execCtx.Err() != nil {
+		res.TimedOut = true
+		res.Warnings = append(res.Warnings, fmt.Sprintf("query deadline exceeded; returning %d partial rows", len(results)))
+		logger.Warn("Query deadline exceeded, returning partial results", "rows", len(results), "query", q)
+		return res, nil
 	}
 
-	globalQueryCache.set(cacheKey, results)
+	globalQueryCache.set(cacheKey, *res)
 
-	return results, nil
+	return res, nil
 }
 
 func (s *Store) Query(ctx context.Context, q string) ([]map[string]any, error) {
 	return Query(ctx, s.MEBStore, q)
 }
 
-// scanFacts scans facts using meb's ScanContext with proper SPO index support.
+// scanFacts scans facts within the scope on ctx with proper SPO index support.
 func scanFacts(ctx context.Context, store *meb.MEBStore, subj, pred, obj string) <-chan struct {
 	Fact meb.Fact
 	Err  error
@@ -197,19 +270,27 @@ func scanFacts(ctx context.Context, store *meb.MEBStore, subj, pred, obj string)
 
 	go func() {
 		defer close(ch)
-		for fact, err := range store.ScanContext(ctx, subj, pred, obj) {
-			ch <- struct {
+		for fact, err := range Scan(ctx, store, subj, pred, obj) {
+			select {
+			case ch <- struct {
 				Fact meb.Fact
 				Err  error
-			}{Fact: fact, Err: err}
+			}{Fact: fact, Err: err}:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
 	return ch
 }
 
-func executeSingleAtomQuery(ctx context.Context, store *meb.MEBStore, atom datalog.Atom, limit int) []map[string]any {
+// The execute* functions stop when ctx is done or limit rows satisfying the
+// constraints have been collected; the bool result reports the latter.
+
+func executeSingleAtomQuery(ctx context.Context, store *meb.MEBStore, atom datalog.Atom, constraints []datalog.Atom, limit int) ([]map[string]any, bool) {
 	var results []map[string]any
+	matches := rowMatcher(store, constraints)
 
 	subj := resolveArg(atom.Args[0])
 	pred := resolveArg(atom.Args[1])
@@ -220,6 +301,9 @@ func executeSingleAtomQuery(ctx context.Context, store *meb.MEBStore, atom datal
 	objIsVar := isVariable(atom.Args[2])
 
 	for item := range scanFacts(ctx, store, subj, pred, obj) {
+		if ctx.Err() != nil {
+			break
+		}
 		if item.Err != nil {
 			continue
 		}
@@ -236,38 +320,42 @@ func executeSingleAtomQuery(ctx context.Context, store *meb.MEBStore, atom datal
 			result[atom.Args[2]] = fact.Object
 		}
 
-		if len(result) > 0 {
+		if len(result) > 0 && matches(result) {
 			results = append(results, result)
 			if limit > 0 && len(results) >= limit {
-				break
+				return results, true
 			}
 		}
 	}
 
-	return results
+	return results, false
 }
 
-func executeLFTJQuery(ctx context.Context, store *meb.MEBStore, atoms []datalog.Atom, limit int) []map[string]any {
+func executeLFTJQuery(ctx context.Context, store *meb.MEBStore, atoms []datalog.Atom, constraints []datalog.Atom, limit int) ([]map[string]any, bool) {
 	var results []map[string]any
+	matches := rowMatcher(store, constraints)
 
 	relations, resultVars, err := buildLFTJRelations(store, atoms)
 	if err != nil {
-		return results
+		return results, false
 	}
 	if len(relations) == 0 {
-		return results
+		return results, false
 	}
 
 	boundVars := make(map[string]uint64)
 
 	engine := store.LFTJEngine()
 	if engine == nil {
-		return results
+		return results, false
 	}
 
 	var mu sync.Mutex
 
 	for joinResult, err := range engine.Execute(ctx, relations, boundVars, resultVars) {
+		if ctx.Err() != nil {
+			break
+		}
 		if err != nil {
 			continue
 		}
@@ -286,22 +374,23 @@ func executeLFTJQuery(ctx context.Context, store *meb.MEBStore, atoms []datalog.
 			row[varName] = strVal
 		}
 
-		if len(row) > 0 {
+		if len(row) > 0 && matches(row) {
 			mu.Lock()
 			results = append(results, row)
 			if limit > 0 && len(results) >= limit {
 				mu.Unlock()
-				break
+				return results, true
 			}
 			mu.Unlock()
 		}
 	}
 
-	return results
+	return results, false
 }
 
-func executeSequentialJoinQuery(ctx context.Context, store *meb.MEBStore, atoms []datalog.Atom, limit int) []map[string]any {
+func executeSequentialJoinQuery(ctx context.Context, store *meb.MEBStore, atoms []datalog.Atom, constraints []datalog.Atom, limit int) ([]map[string]any, bool) {
 	var results []map[string]any
+	matches := rowMatcher(store, constraints)
 
 	firstAtom := atoms[0]
 	subj := resolveArg(firstAtom.Args[0])
@@ -309,6 +398,9 @@ func executeSequentialJoinQuery(ctx context.Context, store *meb.MEBStore, atoms
 	obj := resolveArg(firstAtom.Args[2])
 
 	for item := range scanFacts(ctx, store, subj, pred, obj) {
+		if ctx.Err() != nil {
+			break
+		}
 		if item.Err != nil {
 			continue
 		}
@@ -360,16 +452,16 @@ func executeSequentialJoinQuery(ctx context.Context, store *meb.MEBStore, atoms
 			}
 		}
 
-		if len(row) > 0 {
+		if len(row) > 0 && matches(row) {
 			results = append(results, row)
 			if limit > 0 && len(results) >= limit {
-				break
+				return results, true
 			}
 		}
 	nextFact:
 	}
 
-	return results
+	return results, false
 }
 
 func buildLFTJRelations(store *meb.MEBStore, atoms []datalog.Atom) ([]query.RelationPattern, []string, error) {
@@ -423,20 +515,11 @@ func buildLFTJRelations(store *meb.MEBStore, atoms []datalog.Atom) ([]query.Rela
 	return relations, resultVars, nil
 }
 
-func applyConstraints(results []map[string]any, constraintAtoms []datalog.Atom) []map[string]any {
-	if len(constraintAtoms) == 0 {
-		return results
-	}
-
-	filtered := make([]map[string]any, 0, len(results))
-
-	for _, result := range results {
-		if matchesConstraints(result, constraintAtoms) {
-			filtered = append(filtered, result)
-		}
-	}
-
-	return filtered
+// MatchesConstraints reports whether a result row satisfies every constraint atom
+// (neq, eq, comparisons, string tests, between). Other atoms, including weight
+// constraints, which need the store, are ignored.
+func MatchesConstraints(row map[string]any, constraints []datalog.Atom) bool {
+	return matchesConstraints(row, constraints)
 }
 
 func matchesConstraints(result map[string]any, constraints []datalog.Atom) bool {
@@ -462,11 +545,168 @@ func matchesConstraints(result map[string]any, constraints []datalog.Atom) bool
 					}
 				}
 			}
+		case "gt", ">", "lt", "<", "gte", ">=", "lte", "<=":
+			if len(atom.Args) >= 2 {
+				cmp, ok := compareNumeric(constraintValue(result, atom.Args[0]), constraintValue(result, atom.Args[1]))
+				if !ok {
+					return false
+				}
+				switch atom.Predicate {
+				case "gt", ">":
+					ok = cmp > 0
+				case "lt", "<":
+					ok = cmp < 0
+				case "gte", ">=":
+					ok = cmp >= 0
+				case "lte", "<=":
+					ok = cmp <= 0
+				}
+				if !ok {
+					return false
+				}
+			}
+		case "starts_with", "ends_with", "contains":
+			if len(atom.Args) >= 2 {
+				val, ok := constraintString(result, atom.Args[0])
+				if !ok {
+					return false
+				}
+				needle, ok := constraintString(result, atom.Args[1])
+				if !ok {
+					return false
+				}
+				switch atom.Predicate {
+				case "starts_with":
+					ok = strings.HasPrefix(val, needle)
+				case "ends_with":
+					ok = strings.HasSuffix(val, needle)
+				case "contains":
+					ok = strings.Contains(val, needle)
+				}
+				if !ok {
+					return false
+				}
+			}
+		case "between":
+			// between(?x, low, high) is inclusive on both ends
+			if len(atom.Args) >= 3 {
+				val := constraintValue(result, atom.Args[0])
+				lowCmp, ok := compareNumeric(val, constraintValue(result, atom.Args[1]))
+				if !ok || lowCmp < 0 {
+					return false
+				}
+				highCmp, ok := compareNumeric(val, constraintValue(result, atom.Args[2]))
+				if !ok || highCmp > 0 {
+					return false
+				}
+			}
 		}
 	}
 	return true
 }
 
+// constraintValue resolves a constraint argument: variables are looked up in the
+// result row (nil when unbound), constants have their quotes stripped.
+func constraintValue(result map[string]any, arg string) any {
+	if isVariable(arg) {
+		if val, ok := result[arg]; ok || arg[0] == '?' {
+			return val
+		}
+		// Quoted constants reach us unquoted, so an unbound capitalised
+		// argument is treated as a literal rather than a variable.
+		return arg
+	}
+	return resolveArg(arg)
+}
+
+// stringFuncs are the functions that may wrap a string constraint argument,
+// e.g. starts_with(lowercase(?path), "pkg/meb").
+var stringFuncs = map[string]func(string) string{
+	"lowercase": strings.ToLower,
+	"uppercase": strings.ToUpper,
+	"trim":      strings.TrimSpace,
+}
+
+// constraintString resolves a constraint argument to a string, applying any
+// wrapping string functions. Unbound variables report ok=false.
+func constraintString(result map[string]any, arg string) (string, bool) {
+	arg = strings.TrimSpace(arg)
+	if open := strings.Index(arg, "("); open > 0 && strings.HasSuffix(arg, ")") {
+		if fn, ok := stringFuncs[arg[:open]]; ok {
+			inner, ok := constraintString(result, arg[open+1:len(arg)-1])
+			if !ok {
+				return "", false
+			}
+			return fn(inner), true
+		}
+	}
+	val := constraintValue(result, arg)
+	if val == nil {
+		return "", false
+	}
+	if str, ok := val.(string); ok {
+		return str, true
+	}
+	return fmt.Sprintf("%v", val), true
+}
+
+// compareNumeric compares two values after numeric coercion, returning -1, 0 or 1.
+// Values that cannot be coerced (or are unbound) report ok=false.
+func compareNumeric(a, b any) (int, bool) {
+	left, ok := toNumber(a)
+	if !ok {
+		return 0, false
+	}
+	right, ok := toNumber(b)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case left < right:
+		return -1, true
+	case left > right:
+		return 1, true
+	}
+	return 0, true
+}
+
+// toNumber coerces a bound value to float64. Numeric types and numeric strings are
+// accepted, as are booleans (0/1) and RFC 3339 timestamps (Unix seconds).
+func toNumber(val any) (float64, bool) {
+	switch v := val.(type) {
+	case nil:
+		return 0, false
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		return float64(v.Unix()), true
+	}
+
+	str := strings.TrimSpace(fmt.Sprintf("%v", val))
+	if f, err := strconv.ParseFloat(str, 64); err == nil {
+		return f, true
+	}
+	if ts, err := time.Parse(time.RFC3339, str); err == nil {
+		return float64(ts.Unix()), true
+	}
+	return 0, false
+}
+
 func isVariable(arg string) bool {
 	return len(arg) > 0 && (arg[0] == '?' || (arg[0] >= 'A' && arg[0] <= 'Z'))
 }
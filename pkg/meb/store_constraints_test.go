@@ -0,0 +1,66 @@
+package meb
+
+import (
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/datalog"
+)
+
+func TestMatchesConstraintsNumeric(t *testing.T) {
+	tests := []struct {
+		name       string
+		row        map[string]any
+		constraint datalog.Atom
+		want       bool
+	}{
+		{"gt int", map[string]any{"?loc": 250}, datalog.Atom{Predicate: "gt", Args: []string{"?loc", "200"}}, true},
+		{"gt string number", map[string]any{"?loc": "150"}, datalog.Atom{Predicate: "gt", Args: []string{"?loc", "200"}}, false},
+		{"gt equal", map[string]any{"?loc": 200}, datalog.Atom{Predicate: "gt", Args: []string{"?loc", "200"}}, false},
+		{"lt float", map[string]any{"?c": 2.5}, datalog.Atom{Predicate: "lt", Args: []string{"?c", "3"}}, true},
+		{"gt non-numeric", map[string]any{"?loc": "main.go"}, datalog.Atom{Predicate: "gt", Args: []string{"?loc", "1"}}, false},
+		{"gt unbound", map[string]any{}, datalog.Atom{Predicate: "gt", Args: []string{"?loc", "1"}}, false},
+		{"gte equal", map[string]any{"?l": int32(100)}, datalog.Atom{Predicate: "gte", Args: []string{"?l", "100"}}, true},
+		{"lte greater", map[string]any{"?l": 101}, datalog.Atom{Predicate: "lte", Args: []string{"?l", "100"}}, false},
+		{"between inclusive", map[string]any{"?w": "0.5"}, datalog.Atom{Predicate: "between", Args: []string{"?w", "0.5", "1"}}, true},
+		{"between outside", map[string]any{"?w": 1.5}, datalog.Atom{Predicate: "between", Args: []string{"?w", "0", "1"}}, false},
+		{"compare two variables", map[string]any{"?start": 10, "?end": 42}, datalog.Atom{Predicate: "lt", Args: []string{"?start", "?end"}}, true},
+		{"timestamp", map[string]any{"?t": "2024-06-01T00:00:00Z"}, datalog.Atom{Predicate: "gt", Args: []string{"?t", "2024-01-01T00:00:00Z"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesConstraints(tt.row, []datalog.Atom{tt.constraint}); got != tt.want {
+				t.Errorf("matchesConstraints(%v, %v) = %v, want %v", tt.row, tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesConstraintsString(t *testing.T) {
+	row := map[string]any{"?path": "pkg/meb/store.go", "?name": "HandleQuery", "?line": 42}
+
+	tests := []struct {
+		name       string
+		constraint datalog.Atom
+		want       bool
+	}{
+		{"starts_with", datalog.Atom{Predicate: "starts_with", Args: []string{"?path", "pkg/meb"}}, true},
+		{"starts_with miss", datalog.Atom{Predicate: "starts_with", Args: []string{"?path", "pkg/ingest"}}, false},
+		{"ends_with", datalog.Atom{Predicate: "ends_with", Args: []string{"?path", ".go"}}, true},
+		{"contains", datalog.Atom{Predicate: "contains", Args: []string{"?path", "meb/"}}, true},
+		{"capitalised literal", datalog.Atom{Predicate: "starts_with", Args: []string{"?name", "Handle"}}, true},
+		{"case sensitive", datalog.Atom{Predicate: "contains", Args: []string{"?name", "query"}}, false},
+		{"lowercase", datalog.Atom{Predicate: "contains", Args: []string{"lowercase(?name)", "query"}}, true},
+		{"uppercase", datalog.Atom{Predicate: "starts_with", Args: []string{"uppercase(?path)", "PKG/"}}, true},
+		{"non-string value", datalog.Atom{Predicate: "starts_with", Args: []string{"?line", "4"}}, true},
+		{"unbound", datalog.Atom{Predicate: "contains", Args: []string{"?missing", "x"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesConstraints(row, []datalog.Atom{tt.constraint}); got != tt.want {
+				t.Errorf("matchesConstraints(%v) = %v, want %v", tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
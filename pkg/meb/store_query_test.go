@@ -0,0 +1,113 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func newQueryTestStore(t *testing.T) *meb.MEBStore {
+	t.Helper()
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.SetTopicID(1)
+
+	for i := 0; i < 10; i++ {
+		fact := meb.Fact{Subject: fmt.Sprintf("pkg/limits/f%d.go", i), Predicate: "limit_test_defines", Object: fmt.Sprintf("Sym%d", i)}
+		if err := s.AddFact(fact); err != nil {
+			t.Fatalf("AddFact(%+v): %v", fact, err)
+		}
+	}
+	return s
+}
+
+func TestQueryWithOptionsLimit(t *testing.T) {
+	s := newQueryTestStore(t)
+	ctx := context.Background()
+
+	res, err := QueryWithOptions(ctx, s, `triples(?f, "limit_test_defines", ?s)`, QueryOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("QueryWithOptions: %v", err)
+	}
+	if len(res.Rows) != 3 || !res.Truncated || len(res.Warnings) != 1 {
+		t.Errorf("got %d rows, truncated=%v, warnings=%v; want 3 rows, truncated with one warning", len(res.Rows), res.Truncated, res.Warnings)
+	}
+
+	// The limit counts rows that survive the constraints
+	res, err = QueryWithOptions(ctx, s, `triples(?f, "limit_test_defines", ?s), ends_with(?f, "9.go")`, QueryOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("QueryWithOptions: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0]["?f"] != "pkg/limits/f9.go" {
+		t.Errorf("constrained rows = %v, want f9.go", res.Rows)
+	}
+
+	res, err = QueryWithOptions(ctx, s, `triples(?f, "limit_test_defines", ?s)`, QueryOptions{Limit: 50})
+	if err != nil {
+		t.Fatalf("QueryWithOptions: %v", err)
+	}
+	if len(res.Rows) != 10 || res.Truncated || res.TimedOut || len(res.Warnings) != 0 {
+		t.Errorf("unbounded query = %d rows, %+v; want all 10 rows without warnings", len(res.Rows), res)
+	}
+}
+
+func TestQueryWithOptionsCanceled(t *testing.T) {
+	s := newQueryTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := QueryWithOptions(ctx, s, `triples(?f, "limit_test_defines", "Sym4")`, QueryOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestQueryWithOptionsProvenance(t *testing.T) {
+	s := newQueryTestStore(t)
+	facts := []meb.Fact{
+		{Subject: "pkg/a.go:Run", Predicate: config.PredicateStartLine, Object: "12"},
+		{Subject: "pkg/a.go:Run", Predicate: "prov_test_calls", Object: "pkg/b.go:Helper"},
+		{Subject: "pkg/a.go", Predicate: "prov_test_calls", Object: "pkg/c.go"},
+		{Subject: "pkg/a.go:Run", Predicate: config.PredicateCallsAPI, Object: "/api/run"},
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := func(q string, opts QueryOptions) map[string]any {
+		t.Helper()
+		res, err := QueryWithOptions(context.Background(), s, q, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]any)
+		for _, row := range res.Rows {
+			got[fmt.Sprint(row["?s"])] = row[RowSourceKey]
+			if opts.Provenance && row[RowWeightKey] != 1.0 {
+				t.Errorf("row %v has weight %v, want 1", row, row[RowWeightKey])
+			}
+		}
+		return got
+	}
+
+	got := sources(`triples(?s, "prov_test_calls", ?o)`, QueryOptions{Provenance: true})
+	if got["pkg/a.go:Run"] != "pkg/a.go:12" || got["pkg/a.go"] != "pkg/a.go" {
+		t.Errorf("sources = %v, want pkg/a.go:12 for the symbol and pkg/a.go for the file", got)
+	}
+	got = sources(`triples(?s, "calls_api", ?o)`, QueryOptions{Provenance: true})
+	if got["pkg/a.go:Run"] != ProvenanceVirtual {
+		t.Errorf("calls_api source = %v, want %q", got["pkg/a.go:Run"], ProvenanceVirtual)
+	}
+	got = sources(`triples(?s, "prov_test_calls", ?o)`, QueryOptions{})
+	if got["pkg/a.go:Run"] != nil {
+		t.Errorf("rows carry provenance without Provenance set: %v", got)
+	}
+}
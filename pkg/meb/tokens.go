@@ -0,0 +1,255 @@
+package meb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// tokenIndexPrefix prefixes the token index document of each project.
+const tokenIndexPrefix = "gca:token_index:"
+
+// BM25 parameters for TokenIndex.Search.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// TokenIndex is a keyword index over symbol names, IDs and doc comments. It
+// ranks symbols by TF-IDF (BM25 weighting) and serves search when no embedding
+// provider is available.
+type TokenIndex struct {
+	Docs     []string              `json:"docs"`     // Symbol IDs
+	Lengths  []int32               `json:"lengths"`  // Tokens per symbol
+	Postings map[string][][2]int32 `json:"postings"` // Token -> [doc, term frequency], by ascending doc
+}
+
+// TokenHit is a symbol matching a keyword search.
+type TokenHit struct {
+	ID    string
+	Score float64
+}
+
+// BuildTokenIndex indexes the symbols visible to ctx: the tokens of each
+// symbol's name count twice, then the ID's path and the doc comment.
+func BuildTokenIndex(ctx context.Context, store *meb.MEBStore) *TokenIndex {
+	names := make(map[string]string)
+	for fact, err := range Scan(ctx, store, "", config.PredicateHasName, "") {
+		if name, ok := fact.Object.(string); err == nil && ok {
+			names[fact.Subject] = name
+		}
+	}
+	docs := make(map[string]string)
+	for fact, err := range Scan(ctx, store, "", config.PredicateHasDoc, "") {
+		if doc, ok := fact.Object.(string); err == nil && ok {
+			docs[fact.Subject] += " " + doc
+		}
+	}
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	for id := range docs {
+		if _, ok := names[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	return newTokenIndex(ids, func(id string) []string {
+		name := names[id]
+		if name == "" {
+			name = id[strings.LastIndex(id, ":")+1:]
+		}
+		tokens := Tokenize(name)
+		tokens = append(tokens, tokens...)
+		tokens = append(tokens, Tokenize(id)...)
+		return append(tokens, Tokenize(docs[id])...)
+	})
+}
+
+// BuildDocTokenIndex indexes the doc nodes visible to ctx by the tokens of
+// their doc comment alone, for searching documentation apart from code.
+func BuildDocTokenIndex(ctx context.Context, store *meb.MEBStore) *TokenIndex {
+	docs := make(map[string]string)
+	for fact, err := range Scan(ctx, store, "", config.PredicateHasDoc, "") {
+		if doc, ok := fact.Object.(string); err == nil && ok {
+			docs[fact.Subject] += " " + doc
+		}
+	}
+	symbols := make(map[string]string)
+	var ids []string
+	for fact, err := range Scan(ctx, store, "", config.PredicateDocuments, "") {
+		if symbol, ok := fact.Object.(string); err == nil && ok && docs[symbol] != "" {
+			symbols[fact.Subject] = symbol
+			ids = append(ids, fact.Subject)
+		}
+	}
+	return newTokenIndex(ids, func(id string) []string { return Tokenize(docs[symbols[id]]) })
+}
+
+// newTokenIndex indexes ids by the tokens each has.
+func newTokenIndex(ids []string, tokensOf func(id string) []string) *TokenIndex {
+	sort.Strings(ids)
+	ids = slices.Compact(ids)
+	idx := &TokenIndex{Docs: ids, Lengths: make([]int32, len(ids)), Postings: make(map[string][][2]int32)}
+	for i, id := range ids {
+		tokens := tokensOf(id)
+		idx.Lengths[i] = int32(len(tokens))
+		tf := make(map[string]int32)
+		for _, t := range tokens {
+			tf[t]++
+		}
+		for t, n := range tf {
+			idx.Postings[t] = append(idx.Postings[t], [2]int32{int32(i), n})
+		}
+	}
+	return idx
+}
+
+// Search returns the k symbols best matching query that keep passes, best
+// first. A nil keep keeps every symbol.
+func (idx *TokenIndex) Search(query string, k int, keep func(id string) bool) []TokenHit {
+	if idx == nil || len(idx.Docs) == 0 || k <= 0 {
+		return nil
+	}
+	var total int64
+	for _, n := range idx.Lengths {
+		total += int64(n)
+	}
+	avgLen := float64(total) / float64(len(idx.Docs))
+	n := float64(len(idx.Docs))
+
+	scores := make(map[int32]float64)
+	seen := make(map[string]bool)
+	for _, t := range Tokenize(query) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		postings := idx.Postings[t]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p[1])
+			norm := 1 - bm25B + bm25B*float64(idx.Lengths[p[0]])/avgLen
+			scores[p[0]] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	hits := make([]TokenHit, 0, len(scores))
+	for doc, score := range scores {
+		id := idx.Docs[doc]
+		if keep == nil || keep(id) {
+			hits = append(hits, TokenHit{ID: id, Score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// SaveTokenIndex stores idx as project's token index.
+func SaveTokenIndex(store *meb.MEBStore, project string, idx *TokenIndex) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := PutDocument(store, store.TopicID(), tokenIndexPrefix+project, data, nil); err != nil {
+		return fmt.Errorf("save token index: %w", err)
+	}
+	return nil
+}
+
+// LoadTokenIndex returns project's token index, or nil if it has none.
+func LoadTokenIndex(store *meb.MEBStore, project string) (*TokenIndex, error) {
+	key := tokenIndexPrefix + project
+	ok, err := store.HasDocument(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, key)
+	if err != nil {
+		return nil, fmt.Errorf("load token index: %w", err)
+	}
+	var idx TokenIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("decode token index: %w", err)
+	}
+	return &idx, nil
+}
+
+// stopWords are common English words carrying no meaning in a code search.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "how": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "the": true, "this": true, "that": true, "to": true,
+	"what": true, "when": true, "where": true, "which": true, "with": true, "does": true,
+}
+
+// Tokenize splits text into lowercase search tokens: identifiers are split at
+// case changes, digits and punctuation ("parseHTTPRequest" gives "parse",
+// "http", "request"), stop words are dropped and plurals reduced.
+func Tokenize(text string) []string {
+	var tokens []string
+	emit := func(word []rune) {
+		if len(word) < 2 {
+			return
+		}
+		t := strings.ToLower(string(word))
+		if stopWords[t] {
+			return
+		}
+		switch {
+		case len(t) > 4 && strings.HasSuffix(t, "ies"):
+			t = t[:len(t)-3] + "y"
+		case len(t) > 3 && strings.HasSuffix(t, "s") && !strings.HasSuffix(t, "ss"):
+			t = t[:len(t)-1]
+		}
+		tokens = append(tokens, t)
+	}
+	runes := []rune(text)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				emit(runes[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		// Boundaries: fooBar, HTTPServer (before "Se"), foo2
+		lowerToUpper := unicode.IsLower(prev) && unicode.IsUpper(r)
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(r) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		digitChange := unicode.IsDigit(prev) != unicode.IsDigit(r)
+		if lowerToUpper || acronymEnd || digitChange {
+			emit(runes[start:i])
+			start = i
+		}
+	}
+	if start >= 0 {
+		emit(runes[start:])
+	}
+	return tokens
+}
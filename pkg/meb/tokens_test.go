@@ -0,0 +1,98 @@
+package meb
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"parseHTTPRequest", []string{"parse", "http", "request"}},
+		{"pkg/auth/login.go:ValidateTokens", []string{"pkg", "auth", "login", "go", "validate", "token"}},
+		{"How does the retry logic work?", []string{"retry", "logic", "work"}},
+		{"Base64Entries", []string{"base", "64", "entry"}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTokenIndex(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(1)
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "auth/login.go:ValidateToken", Predicate: config.PredicateHasName, Object: "ValidateToken"},
+		{Subject: "auth/login.go:ValidateToken", Predicate: config.PredicateHasDoc, Object: "checks the session signature"},
+		{Subject: "net/retry.go:Backoff", Predicate: config.PredicateHasName, Object: "Backoff"},
+		{Subject: "net/retry.go:Backoff", Predicate: config.PredicateHasDoc, Object: "waits before retrying a request"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := BuildTokenIndex(context.Background(), s)
+	if err := SaveTokenIndex(s, "demo", idx); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTokenIndex(s, "demo")
+	if err != nil || loaded == nil {
+		t.Fatalf("LoadTokenIndex = %v, %v", loaded, err)
+	}
+
+	hits := loaded.Search("how are session tokens validated", 5, nil)
+	if len(hits) == 0 || hits[0].ID != "auth/login.go:ValidateToken" {
+		t.Fatalf("Search(session tokens) = %v, want ValidateToken first", hits)
+	}
+	hits = loaded.Search("retry requests", 5, nil)
+	if len(hits) != 1 || hits[0].ID != "net/retry.go:Backoff" {
+		t.Fatalf("Search(retry) = %v, want only Backoff", hits)
+	}
+	if hits := loaded.Search("retry", 5, func(string) bool { return false }); len(hits) != 0 {
+		t.Errorf("Search with rejecting filter = %v, want none", hits)
+	}
+	if idx, err := LoadTokenIndex(s, "other"); err != nil || idx != nil {
+		t.Errorf("LoadTokenIndex(other) = %v, %v, want nil", idx, err)
+	}
+}
+
+func TestDocTokenIndex(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(1)
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "auth/login.go:ValidateToken", Predicate: config.PredicateHasName, Object: "ValidateToken"},
+		{Subject: "auth/login.go:ValidateToken", Predicate: config.PredicateHasDoc, Object: "checks the session signature"},
+		{Subject: DocNodeID("auth/login.go:ValidateToken"), Predicate: config.PredicateDocuments, Object: "auth/login.go:ValidateToken"},
+		{Subject: "auth/session.go:Session", Predicate: config.PredicateHasName, Object: "Session"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := BuildDocTokenIndex(context.Background(), s)
+	hits := idx.Search("session", 5, nil)
+	if len(hits) != 1 || hits[0].ID != "auth/login.go:ValidateToken#doc" {
+		t.Fatalf("Search(session) = %v, want only the doc node of ValidateToken", hits)
+	}
+	if hits := idx.Search("validate token", 5, nil); len(hits) != 0 {
+		t.Errorf("Search(validate token) = %v, want none: names are not documentation", hits)
+	}
+	if !IsDocNode(hits[0].ID) || DocNodeSymbol(hits[0].ID) != "auth/login.go:ValidateToken" {
+		t.Errorf("doc node %q does not map back to its symbol", hits[0].ID)
+	}
+}
@@ -0,0 +1,101 @@
+package meb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+)
+
+// Symbol IDs name a symbol by its file, so moving it to another file gives it
+// a new ID. A symbol's UID instead hashes what identifies it in its language:
+// its package, kind, qualified name and signature. Ingest records it in a
+// has_uid fact, whose SPO and OPS entries map IDs to UIDs and back, and keys
+// the symbol's embedding by it, so that vectors and annotations follow the
+// symbol through moves. Symbols of one scope that share all of these are told
+// apart by their rank in the file.
+
+// uidPrefix starts every UID, telling UIDs apart from symbol IDs.
+const uidPrefix = "uid:"
+
+// SymbolUID returns the UID of the nth symbol (from 0) of scope with the given
+// kind, name and signature; whitespace in the signature does not count.
+func SymbolUID(scope, kind, name, signature string, n int) string {
+	h := sha256.New()
+	for _, part := range []string{scope, kind, name, strings.Join(strings.Fields(signature), " ")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if n > 0 {
+		h.Write([]byte(strconv.Itoa(n)))
+	}
+	return uidPrefix + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// IsUID reports whether id is a symbol UID.
+func IsUID(id string) bool {
+	return strings.HasPrefix(id, uidPrefix)
+}
+
+// UIDOf returns the UID of a symbol, "" when it has none.
+func UIDOf(ctx context.Context, store *meb.MEBStore, id string) string {
+	for fact, err := range Scan(ctx, store, id, config.PredicateHasUID, "") {
+		if uid, ok := fact.Object.(string); err == nil && ok {
+			return uid
+		}
+	}
+	return ""
+}
+
+// UIDSymbols returns the IDs of the symbols holding uid, sorted. There is
+// usually one; symbols of different files alike in every identifying part,
+// such as build-tagged variants of a function, share their UID.
+func UIDSymbols(ctx context.Context, store *meb.MEBStore, uid string) []string {
+	var ids []string
+	for fact, err := range Scan(ctx, store, "", config.PredicateHasUID, uid) {
+		if err == nil {
+			ids = append(ids, fact.Subject)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// VectorKey returns the key a symbol's embedding is stored under: its UID, or
+// for symbols ingested before UIDs, its ID.
+func VectorKey(ctx context.Context, store *meb.MEBStore, id string) string {
+	if uid := UIDOf(ctx, store, id); uid != "" {
+		return uid
+	}
+	return id
+}
+
+// VectorSymbols returns the symbols an embedding's key stands for, those
+// holding it when it is a UID.
+func VectorSymbols(ctx context.Context, store *meb.MEBStore, key string) []string {
+	if IsUID(key) {
+		return UIDSymbols(ctx, store, key)
+	}
+	return []string{key}
+}
+
+// VectorID returns the dictionary ID to store the embedding keyed by key
+// under, adding key to the dictionary if needed: a UID may not be there yet
+// when its symbol is embedded ahead of its facts being written.
+func VectorID(store *meb.MEBStore, key string) (uint64, error) {
+	if id, ok := store.LookupID(key); ok {
+		return id, nil
+	}
+	var id uint64
+	err := store.Update(func(txn *meb.StoreTxn) error {
+		var err error
+		id, err = txn.GetOrCreateID(key)
+		return err
+	})
+	return id, err
+}
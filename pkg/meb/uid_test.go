@@ -0,0 +1,53 @@
+package meb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolUID(t *testing.T) {
+	uid := SymbolUID("pkg/auth:auth", "function", "Login", "func Login(user string) error", 0)
+	assert.True(t, IsUID(uid))
+	assert.False(t, IsUID("pkg/auth/login.go:Login"))
+	assert.Equal(t, uid, SymbolUID("pkg/auth:auth", "function", "Login", "func  Login(user\tstring)\n error", 0),
+		"whitespace in the signature does not count")
+	for _, other := range []string{
+		SymbolUID("pkg/auth:auth", "function", "Login", "func Login(user string) error", 1),
+		SymbolUID("pkg/auth:auth", "method", "Login", "func Login(user string) error", 0),
+		SymbolUID("pkg/auth:auth", "function", "Login", "func Login(user, password string) error", 0),
+		SymbolUID("pkg/session:session", "function", "Login", "func Login(user string) error", 0),
+	} {
+		assert.NotEqual(t, uid, other)
+	}
+
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "pkg/auth/login.go:Login", Predicate: config.PredicateHasUID, Object: uid},
+		{Subject: "pkg/auth/login.go:Logout", Predicate: config.PredicateType, Object: "function"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	assert.Equal(t, uid, UIDOf(ctx, s, "pkg/auth/login.go:Login"))
+	assert.Equal(t, []string{"pkg/auth/login.go:Login"}, UIDSymbols(ctx, s, uid))
+	assert.Equal(t, uid, VectorKey(ctx, s, "pkg/auth/login.go:Login"))
+	assert.Equal(t, "pkg/auth/login.go:Logout", VectorKey(ctx, s, "pkg/auth/login.go:Logout"), "symbols without a UID keep ID-keyed vectors")
+	assert.Equal(t, []string{"pkg/auth/login.go:Login"}, VectorSymbols(ctx, s, uid))
+	assert.Equal(t, []string{"pkg/auth/login.go:Logout"}, VectorSymbols(ctx, s, "pkg/auth/login.go:Logout"))
+
+	id, err := VectorID(s, SymbolUID("pkg/auth:auth", "function", "Refresh", "func Refresh()", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := VectorID(s, SymbolUID("pkg/auth:auth", "function", "Refresh", "func Refresh()", 0))
+	assert.Equal(t, id, again)
+}
@@ -0,0 +1,290 @@
+package meb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/meb"
+)
+
+// Every ingest run is recorded as a numbered version. Incremental runs soft-delete:
+// the facts they remove and the facts they write are kept in a changes document
+// per version, so a read as of an earlier version can undo the later runs. Full
+// ingests are recorded as baselines without their facts, so history before the
+// latest full ingest is not available.
+
+// VersionLogKey is the document listing the store's ingest versions.
+const VersionLogKey = "gca:versions"
+
+// versionChangesPrefix prefixes the changes document of each version.
+const versionChangesPrefix = "gca:version:"
+
+// ErrUnknownVersion is returned when a read asks for a version the store cannot
+// reconstruct.
+var ErrUnknownVersion = errors.New("unknown ingest version")
+
+// Version describes one ingest run.
+type Version struct {
+	ID        int       `json:"id"`
+	Project   string    `json:"project,omitempty"`
+	Full      bool      `json:"full,omitempty"` // Full ingest; the facts it wrote are not tracked
+	Added     int       `json:"added"`          // Facts written by an incremental run
+	Removed   int       `json:"removed"`        // Facts soft-deleted by an incremental run
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type versionChanges struct {
+	Added   []meb.Fact `json:"added,omitempty"`
+	Removed []meb.Fact `json:"removed,omitempty"`
+}
+
+// Versions returns the store's ingest versions, oldest first.
+func Versions(store *meb.MEBStore) ([]Version, error) {
+	ok, err := store.HasDocument(VersionLogKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := store.GetContentByKey(VersionLogKey)
+	if err != nil {
+		return nil, fmt.Errorf("load version log: %w", err)
+	}
+	var versions []Version
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("decode version log: %w", err)
+	}
+	return versions, nil
+}
+
+// VersionRecorder collects the changes of one ingest run. A nil recorder is
+// valid and records nothing.
+type VersionRecorder struct {
+	store   *meb.MEBStore
+	project string
+	full    bool
+
+	mu      sync.Mutex
+	changes versionChanges
+}
+
+// RecordVersion starts recording an ingest run of project. Nothing is written
+// until Commit.
+func RecordVersion(store *meb.MEBStore, project string, full bool) *VersionRecorder {
+	return &VersionRecorder{store: store, project: project, full: full}
+}
+
+// DeleteSubject soft-deletes the facts of subject in the store's current topic:
+// they are recorded as removed by this run, then deleted. It takes the store so
+// that a nil recorder still deletes.
+func (r *VersionRecorder) DeleteSubject(store *meb.MEBStore, subject string) error {
+	if r != nil && !r.full {
+		facts := subjectFacts(store, subject)
+		r.mu.Lock()
+		r.changes.Removed = append(r.changes.Removed, facts...)
+		r.mu.Unlock()
+	}
+	return store.DeleteFactsBySubject(subject)
+}
+
+// AddSubject records the facts of subject in the store's current topic as
+// written by this run.
+func (r *VersionRecorder) AddSubject(subject string) {
+	if r == nil || r.full {
+		return
+	}
+	facts := subjectFacts(r.store, subject)
+	r.mu.Lock()
+	r.changes.Added = append(r.changes.Added, facts...)
+	r.mu.Unlock()
+}
+
+// AddFacts records facts as written by this run.
+func (r *VersionRecorder) AddFacts(facts ...meb.Fact) {
+	if r == nil || r.full {
+		return
+	}
+	r.mu.Lock()
+	r.changes.Added = append(r.changes.Added, facts...)
+	r.mu.Unlock()
+}
+
+func subjectFacts(store *meb.MEBStore, subject string) []meb.Fact {
+	var facts []meb.Fact
+	for fact, err := range store.ScanInTopicContext(context.Background(), store.TopicID(), subject, "", "") {
+		if err == nil {
+			facts = append(facts, fact)
+		}
+	}
+	return facts
+}
+
+// Commit appends the run to the version log and returns it.
+func (r *VersionRecorder) Commit() (Version, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions, err := Versions(r.store)
+	if err != nil {
+		return Version{}, err
+	}
+	v := Version{
+		ID:        1,
+		Project:   r.project,
+		Full:      r.full,
+		Added:     len(r.changes.Added),
+		Removed:   len(r.changes.Removed),
+		CreatedAt: time.Now().UTC(),
+	}
+	if len(versions) > 0 {
+		v.ID = versions[len(versions)-1].ID + 1
+	}
+
+	if v.Added > 0 || v.Removed > 0 {
+		data, err := json.Marshal(&r.changes)
+		if err != nil {
+			return Version{}, err
+		}
+		if err := PutDocument(r.store, r.store.TopicID(), versionChangesKey(v.ID), data, nil); err != nil {
+			return Version{}, fmt.Errorf("save version %d changes: %w", v.ID, err)
+		}
+	}
+	data, err := json.Marshal(append(versions, v))
+	if err != nil {
+		return Version{}, err
+	}
+	if err := r.store.AddDocument(VersionLogKey, data, nil, nil); err != nil {
+		return Version{}, fmt.Errorf("save version log: %w", err)
+	}
+	return v, nil
+}
+
+// VersionChanges returns the facts an incremental ingest version wrote and
+// soft-deleted. Full ingests and runs that changed nothing have none.
+func VersionChanges(store *meb.MEBStore, v Version) (added, removed []meb.Fact, err error) {
+	if v.Full || v.Added == 0 && v.Removed == 0 {
+		return nil, nil, nil
+	}
+	data, err := GetDocument(store, versionChangesKey(v.ID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("load version %d changes: %w", v.ID, err)
+	}
+	var changes versionChanges
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, nil, fmt.Errorf("decode version %d changes: %w", v.ID, err)
+	}
+	return changes.Added, changes.Removed, nil
+}
+
+func versionChangesKey(id int) string {
+	return versionChangesPrefix + strconv.Itoa(id)
+}
+
+// versionView is the difference between the current store and an earlier version.
+type versionView struct {
+	version  int
+	touched  map[string]bool // fact key -> present as of version
+	restored []meb.Fact      // facts removed since version, present as of it
+}
+
+type asOfKey struct{}
+
+// AsOf returns a context whose scans and queries read store as it was right after
+// the given ingest version. It fails with ErrUnknownVersion for versions that
+// were never recorded or that precede the latest full ingest; with a Scope on
+// ctx, only full ingests of the scoped project count.
+func AsOf(ctx context.Context, store *meb.MEBStore, version int) (context.Context, error) {
+	versions, err := Versions(store)
+	if err != nil {
+		return nil, err
+	}
+	idx := -1
+	for i, v := range versions {
+		if v.ID == version {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	sc, scoped := ScopeFrom(ctx)
+	view := &versionView{version: version, touched: make(map[string]bool)}
+	facts := make(map[string]meb.Fact)
+	// Undo the later runs, newest first
+	for i := len(versions) - 1; i > idx; i-- {
+		later := versions[i]
+		if later.Full && scoped && later.Project != sc.Project {
+			continue // Another project's facts, invisible in this scope
+		}
+		if later.Full {
+			return nil, fmt.Errorf("%w: %d precedes full ingest %d", ErrUnknownVersion, version, later.ID)
+		}
+		added, removed, err := VersionChanges(store, later)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range added {
+			view.touched[factKey(f)] = false
+		}
+		for _, f := range removed {
+			key := factKey(f)
+			view.touched[key] = true
+			facts[key] = f
+		}
+	}
+	keys := make([]string, 0, len(facts))
+	for key := range facts {
+		if view.touched[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys) // stable scan order
+	for _, key := range keys {
+		view.restored = append(view.restored, facts[key])
+	}
+	return context.WithValue(ctx, asOfKey{}, view), nil
+}
+
+// AsOfVersion reports the version ctx reads as of, if any.
+func AsOfVersion(ctx context.Context) (int, bool) {
+	view, ok := ctx.Value(asOfKey{}).(*versionView)
+	if !ok {
+		return 0, false
+	}
+	return view.version, true
+}
+
+// overlay turns a scan of the current store into one as of the view's version:
+// facts written since are hidden and facts removed since come back.
+func (v *versionView) overlay(ctx context.Context, facts iter.Seq2[meb.Fact, error], subj, pred, obj string) iter.Seq2[meb.Fact, error] {
+	return func(yield func(meb.Fact, error) bool) {
+		for fact, err := range facts {
+			if err == nil {
+				if _, changed := v.touched[factKey(fact)]; changed {
+					continue
+				}
+			}
+			if !yield(fact, err) {
+				return
+			}
+		}
+		for _, fact := range v.restored {
+			if subj != "" && fact.Subject != subj || pred != "" && fact.Predicate != pred ||
+				obj != "" && fmt.Sprint(fact.Object) != obj || !InScope(ctx, fact.Subject) {
+				continue
+			}
+			if !yield(fact, nil) {
+				return
+			}
+		}
+	}
+}
+
+func factKey(f meb.Fact) string {
+	return f.Subject + "\x00" + f.Predicate + "\x00" + fmt.Sprint(f.Object)
+}
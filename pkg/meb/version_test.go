@@ -0,0 +1,100 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestAsOf(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetTopicID(1)
+	ctx := context.Background()
+
+	add := func(facts ...meb.Fact) {
+		t.Helper()
+		if err := s.AddFactBatch(facts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func(rec *VersionRecorder) int {
+		t.Helper()
+		v, err := rec.Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.ID
+	}
+
+	// Version 1: full ingest
+	add(meb.Fact{Subject: "a.go", Predicate: "defines", Object: "a.go:Old"},
+		meb.Fact{Subject: "b.go", Predicate: "defines", Object: "b.go:B"})
+	v1 := commit(RecordVersion(s, "", true))
+
+	// Version 2: a.go renames Old to New, b.go is deleted
+	rec := RecordVersion(s, "", false)
+	if err := rec.DeleteSubject(s, "a.go"); err != nil {
+		t.Fatal(err)
+	}
+	add(meb.Fact{Subject: "a.go", Predicate: "defines", Object: "a.go:New"})
+	rec.AddSubject("a.go")
+	if err := rec.DeleteSubject(s, "b.go"); err != nil {
+		t.Fatal(err)
+	}
+	v2 := commit(rec)
+
+	defined := func(ctx context.Context) []string {
+		t.Helper()
+		var objs []string
+		for f, err := range Scan(ctx, s, "", "defines", "") {
+			if err != nil {
+				t.Fatal(err)
+			}
+			objs = append(objs, f.Object.(string))
+		}
+		sort.Strings(objs)
+		return objs
+	}
+
+	if got := defined(ctx); len(got) != 1 || got[0] != "a.go:New" {
+		t.Errorf("current defines = %v, want [a.go:New]", got)
+	}
+	old, err := AsOf(ctx, s, v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := defined(old); len(got) != 2 || got[0] != "a.go:Old" || got[1] != "b.go:B" {
+		t.Errorf("defines as of v%d = %v, want [a.go:Old b.go:B]", v1, got)
+	}
+	latest, err := AsOf(ctx, s, v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := defined(latest); len(got) != 1 || got[0] != "a.go:New" {
+		t.Errorf("defines as of v%d = %v, want [a.go:New]", v2, got)
+	}
+
+	res, err := QueryWithOptions(ctx, s, `triples("a.go", "defines", ?sym)`, QueryOptions{AsOf: v1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0]["?sym"] != "a.go:Old" {
+		t.Errorf("query as of v%d = %v, want a.go:Old", v1, res.Rows)
+	}
+
+	if _, err := AsOf(ctx, s, 99); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("AsOf(99): err = %v, want ErrUnknownVersion", err)
+	}
+	commit(RecordVersion(s, "", true))
+	if _, err := AsOf(ctx, s, v1); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("AsOf before a full ingest: err = %v, want ErrUnknownVersion", err)
+	}
+}
@@ -0,0 +1,199 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/dict"
+)
+
+// CentralNode is a node and its degree centrality, 1 for the most central node
+// of its project.
+type CentralNode struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// WarmupSpec says how much of a store Warmup reads.
+type WarmupSpec struct {
+	Nodes   int  // Most central nodes warmed
+	Content bool // Also read the source files defining them
+	Vectors bool // Also read their embeddings
+}
+
+// WarmupStats reports what Warmup read.
+type WarmupStats struct {
+	Nodes     int           `json:"nodes"`
+	Facts     int           `json:"facts"`
+	Documents int           `json:"documents"`
+	Vectors   int           `json:"vectors"`
+	Took      time.Duration `json:"took"`
+}
+
+// RankCentralNodes ranks the nodes visible to ctx by degree centrality: the
+// calls and imports going in and out of them, scaled so that the most central
+// node scores 1. At most limit nodes are returned, most central first.
+func RankCentralNodes(ctx context.Context, store *meb.MEBStore, limit int) ([]CentralNode, error) {
+	degree := make(map[string]int)
+	for _, pred := range []string{config.PredicateCalls, config.PredicateImports} {
+		for fact, err := range Scan(ctx, store, "", pred, "") {
+			if errors.Is(err, dict.ErrNotFound) {
+				break // No such edges
+			}
+			if err != nil {
+				return nil, fmt.Errorf("scan %s: %w", pred, err)
+			}
+			degree[fact.Subject]++
+			if obj, ok := fact.Object.(string); ok {
+				degree[obj]++
+			}
+		}
+	}
+
+	nodes := make([]CentralNode, 0, len(degree))
+	most := 0
+	for id, d := range degree {
+		nodes = append(nodes, CentralNode{ID: id, Score: float64(d)})
+		most = max(most, d)
+	}
+	for i := range nodes {
+		nodes[i].Score /= float64(most)
+	}
+	sortCentralNodes(nodes)
+	return nodes[:min(limit, len(nodes))], nil
+}
+
+// TagCentralNodes ranks the nodes visible to ctx and records the limit most
+// central as has_centrality facts whose object is the node's score.
+func TagCentralNodes(ctx context.Context, store *meb.MEBStore, limit int) ([]CentralNode, error) {
+	nodes, err := RankCentralNodes(ctx, store, limit)
+	if err != nil || len(nodes) == 0 {
+		return nodes, err
+	}
+	facts := make([]meb.Fact, len(nodes))
+	for i, n := range nodes {
+		facts[i] = meb.Fact{Subject: n.ID, Predicate: config.PredicateHasCentrality, Object: n.Score}
+	}
+	if err := store.AddFactBatch(facts); err != nil {
+		return nil, fmt.Errorf("write centrality: %w", err)
+	}
+	return nodes, nil
+}
+
+// CentralNodes returns the n most central nodes tagged at ingest time. Stores
+// ingested before nodes were tagged fall back on their entry points.
+func CentralNodes(ctx context.Context, store *meb.MEBStore, n int) ([]CentralNode, error) {
+	scores := make(map[string]float64)
+	for fact, err := range Scan(ctx, store, "", config.PredicateHasCentrality, "") {
+		if errors.Is(err, dict.ErrNotFound) {
+			break // Never tagged
+		}
+		if err != nil {
+			return nil, err
+		}
+		// A node tagged by several ingests keeps its best score
+		if score, ok := toNumber(fact.Object); ok && score > scores[fact.Subject] {
+			scores[fact.Subject] = score
+		}
+	}
+	if len(scores) == 0 {
+		entries, err := EntryPoints(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			scores[e.ID] = 1
+		}
+	}
+
+	nodes := make([]CentralNode, 0, len(scores))
+	for id, score := range scores {
+		nodes = append(nodes, CentralNode{ID: id, Score: score})
+	}
+	sortCentralNodes(nodes)
+	return nodes[:min(n, len(nodes))], nil
+}
+
+func sortCentralNodes(nodes []CentralNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Score != nodes[j].Score {
+			return nodes[i].Score > nodes[j].Score
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+}
+
+// Warmup reads what queries of the store's most central nodes will read, so
+// that the first queries after the store opens find Badger's block and index
+// caches, the dictionary's LRU and the vector index warm: each node's facts in
+// both directions and, as spec asks, the source files defining the nodes and
+// the nodes' embeddings. It stops early, with ctx's error, when ctx is done.
+func Warmup(ctx context.Context, store *meb.MEBStore, spec WarmupSpec) (WarmupStats, error) {
+	began := time.Now()
+	var stats WarmupStats
+	if spec.Nodes <= 0 {
+		return stats, nil
+	}
+	nodes, err := CentralNodes(ctx, store, spec.Nodes)
+	if err != nil {
+		return stats, fmt.Errorf("rank nodes: %w", err)
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	err = store.View(func(txn *meb.StoreTxn) error {
+		for _, n := range nodes {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for _, scan := range [][3]string{{n.ID, "", ""}, {"", "", n.ID}} {
+				for _, err := range TxnScan(ctx, txn, scan[0], scan[1], scan[2]) {
+					if err == nil {
+						stats.Facts++
+					}
+				}
+			}
+			if spec.Vectors {
+				key := n.ID // Embeddings are keyed by UID when the symbol has one
+				for fact, err := range TxnScan(ctx, txn, n.ID, config.PredicateHasUID, "") {
+					if uid, ok := fact.Object.(string); err == nil && ok {
+						key = uid
+					}
+				}
+				if id, err := txn.GetID(key); err == nil && store.Vectors().HasVector(id) {
+					if _, err := store.Vectors().GetFullVector(id); err == nil {
+						stats.Vectors++
+					}
+				}
+			}
+			if file, _, _ := strings.Cut(n.ID, ":"); spec.Content && !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+			stats.Nodes++
+		}
+		return nil
+	})
+	if err != nil {
+		stats.Took = time.Since(began)
+		return stats, err
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			stats.Took = time.Since(began)
+			return stats, err
+		}
+		if content, err := GetDocument(store, file); err == nil && len(content) > 0 {
+			stats.Documents++
+		}
+	}
+	stats.Took = time.Since(began)
+	return stats, nil
+}
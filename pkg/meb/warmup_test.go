@@ -0,0 +1,63 @@
+package meb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmup(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "main.go", Predicate: config.PredicateDefines, Object: "main.go:main"},
+		{Subject: "main.go:main", Predicate: config.PredicateCalls, Object: "core.go:Run"},
+		{Subject: "cli.go:Execute", Predicate: config.PredicateCalls, Object: "core.go:Run"},
+		{Subject: "core.go:Run", Predicate: config.PredicateCalls, Object: "core.go:load"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutDocument(s, s.TopicID(), "core.go", []byte("package core"), nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Before tagging, the entry points stand in for central nodes
+	nodes, err := CentralNodes(ctx, s, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []CentralNode{{ID: "main.go:main", Score: 1}}, nodes)
+
+	tagged, err := TagCentralNodes(ctx, s, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []CentralNode{{ID: "core.go:Run", Score: 1}, {ID: "cli.go:Execute", Score: 1.0 / 3}}, tagged)
+	nodes, err = CentralNodes(ctx, s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tagged[:1], nodes)
+
+	stats, err := Warmup(ctx, s, WarmupSpec{Nodes: 10, Content: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, stats.Nodes)
+	// core.go:Run has 3 call facts and a has_centrality fact; cli.go:Execute 1 and 1
+	assert.Equal(t, 6, stats.Facts)
+	assert.Equal(t, 1, stats.Documents, "core.go is stored, cli.go is not")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = Warmup(cancelled, s, WarmupSpec{Nodes: 10})
+	assert.ErrorIs(t, err, context.Canceled)
+}
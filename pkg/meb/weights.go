@@ -0,0 +1,255 @@
+package meb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/datalog"
+	"github.com/duynguyendang/meb"
+)
+
+// FactWeightsKey is the document holding the weights set on facts after they
+// were written. Facts carry no weight of their own, so every fact weighs 1
+// unless it is listed here or is an actually_calls fact observed in traces.
+const FactWeightsKey = "gca:fact_weights"
+
+// ErrFactNotFound is returned when weighing a fact the store does not hold.
+var ErrFactNotFound = errors.New("fact not found")
+
+// ErrInvalidWeight is returned for weights that are not finite positive numbers.
+var ErrInvalidWeight = errors.New("invalid fact weight")
+
+// FactWeight is the weight set on a fact.
+type FactWeight struct {
+	Subject   string  `json:"subject"`
+	Predicate string  `json:"predicate"`
+	Object    string  `json:"object"`
+	Weight    float64 `json:"weight"`
+}
+
+// weightsGeneration counts weight updates, so that cached query results
+// filtered or annotated by weight do not outlive them.
+var weightsGeneration atomic.Uint64
+
+// ValidWeight reports whether w can be set on a fact: a weight of 0 would read
+// as no weight at all.
+func ValidWeight(w float64) error {
+	if w <= 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+		return fmt.Errorf("%w: %v must be a finite number > 0", ErrInvalidWeight, w)
+	}
+	return nil
+}
+
+// FactWeights returns the weights set on facts, keyed by factKey.
+func FactWeights(store *meb.MEBStore) (map[string]float64, error) {
+	ok, err := store.HasDocument(FactWeightsKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	data, err := GetDocument(store, FactWeightsKey)
+	if err != nil {
+		return nil, fmt.Errorf("load fact weights: %w", err)
+	}
+	var list []FactWeight
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode fact weights: %w", err)
+	}
+	weights := make(map[string]float64, len(list))
+	for _, w := range list {
+		weights[factKey(meb.Fact{Subject: w.Subject, Predicate: w.Predicate, Object: w.Object})] = w.Weight
+	}
+	return weights, nil
+}
+
+// UpdateFactWeight sets the weight of fact, which must be in the store's
+// current topic, replacing any weight set before.
+func UpdateFactWeight(store *meb.MEBStore, fact meb.Fact, w float64) error {
+	return UpdateFactWeights(store, []FactWeight{{
+		Subject:   fact.Subject,
+		Predicate: fact.Predicate,
+		Object:    fmt.Sprint(fact.Object),
+		Weight:    w,
+	}})
+}
+
+// UpdateFactWeights sets the weights of many facts at once. Every weight is
+// checked before any is written: a weight that is not positive fails with
+// ErrInvalidWeight and a fact missing from the store's current topic with
+// ErrFactNotFound.
+func UpdateFactWeights(store *meb.MEBStore, updates []FactWeight) error {
+	for _, u := range updates {
+		if err := ValidWeight(u.Weight); err != nil {
+			return err
+		}
+		found := false
+		for _, err := range store.ScanInTopicContext(context.Background(), store.TopicID(), u.Subject, u.Predicate, u.Object) {
+			found = err == nil
+			break
+		}
+		if !found {
+			return fmt.Errorf("%w: %s %s %s", ErrFactNotFound, u.Subject, u.Predicate, u.Object)
+		}
+	}
+	return setFactWeights(store, updates)
+}
+
+// setFactWeights merges updates into the store's fact weights.
+func setFactWeights(store *meb.MEBStore, updates []FactWeight) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	ok, err := store.HasDocument(FactWeightsKey)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]FactWeight)
+	if ok {
+		data, err := GetDocument(store, FactWeightsKey)
+		if err != nil {
+			return fmt.Errorf("load fact weights: %w", err)
+		}
+		var list []FactWeight
+		if err := json.Unmarshal(data, &list); err != nil {
+			return fmt.Errorf("decode fact weights: %w", err)
+		}
+		for _, w := range list {
+			byKey[factKey(meb.Fact{Subject: w.Subject, Predicate: w.Predicate, Object: w.Object})] = w
+		}
+	}
+	for _, u := range updates {
+		byKey[factKey(meb.Fact{Subject: u.Subject, Predicate: u.Predicate, Object: u.Object})] = u
+	}
+	list := make([]FactWeight, 0, len(byKey))
+	for _, w := range byKey {
+		list = append(list, w)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Subject != list[j].Subject {
+			return list[i].Subject < list[j].Subject
+		}
+		if list[i].Predicate != list[j].Predicate {
+			return list[i].Predicate < list[j].Predicate
+		}
+		return list[i].Object < list[j].Object
+	})
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := PutDocument(store, store.TopicID(), FactWeightsKey, data, nil); err != nil {
+		return fmt.Errorf("save fact weights: %w", err)
+	}
+	weightsGeneration.Add(1)
+	return nil
+}
+
+// weightResolver finds the weight of facts: the weight set on the fact, else
+// the observed weight of an actually_calls fact, else 1. It loads the store's
+// weights on first use.
+type weightResolver struct {
+	store   *meb.MEBStore
+	set     map[string]float64
+	runtime map[string]RuntimeEdge
+}
+
+func newWeightResolver(store *meb.MEBStore) *weightResolver {
+	return &weightResolver{store: store}
+}
+
+func (r *weightResolver) runtimeEdge(caller, callee string) (RuntimeEdge, bool) {
+	if r.runtime == nil {
+		edges, err := RuntimeEdges(r.store)
+		if err != nil || edges == nil {
+			edges = map[string]RuntimeEdge{}
+		}
+		r.runtime = edges
+	}
+	e, ok := r.runtime[runtimeEdgeKey(caller, callee)]
+	return e, ok
+}
+
+func (r *weightResolver) weight(subj, pred, obj string) float64 {
+	if r.set == nil {
+		weights, err := FactWeights(r.store)
+		if err != nil || weights == nil {
+			weights = map[string]float64{}
+		}
+		r.set = weights
+	}
+	if w, ok := r.set[factKey(meb.Fact{Subject: subj, Predicate: pred, Object: obj})]; ok {
+		return w
+	}
+	if pred == config.PredicateActuallyCalls {
+		if e, ok := r.runtimeEdge(subj, obj); ok {
+			return e.Weight
+		}
+	}
+	return 1
+}
+
+// FactWeigher returns the weight of facts as query provenance reports it,
+// sharing the store's weights across calls.
+func FactWeigher(store *meb.MEBStore) func(subj, pred, obj string) float64 {
+	return newWeightResolver(store).weight
+}
+
+// weightConstraints are the constraints on the weight of a fact, written
+// weight_gt(?s, "calls", ?o, 10) for the fact triples(?s, "calls", ?o).
+var weightConstraints = map[string]func(w, bound float64) bool{
+	"weight_gt":  func(w, bound float64) bool { return w > bound },
+	"weight_gte": func(w, bound float64) bool { return w >= bound },
+	"weight_lt":  func(w, bound float64) bool { return w < bound },
+	"weight_lte": func(w, bound float64) bool { return w <= bound },
+}
+
+// rowMatcher returns a test of rows against constraints. Weight constraints
+// need the store's weights, which are only loaded when a query has one.
+func rowMatcher(store *meb.MEBStore, constraints []datalog.Atom) func(row map[string]any) bool {
+	var weighed []datalog.Atom
+	for _, atom := range constraints {
+		if _, ok := weightConstraints[atom.Predicate]; ok {
+			weighed = append(weighed, atom)
+		}
+	}
+	if len(weighed) == 0 {
+		return func(row map[string]any) bool { return matchesConstraints(row, constraints) }
+	}
+	r := newWeightResolver(store)
+	return func(row map[string]any) bool {
+		return matchesConstraints(row, constraints) && matchesWeights(r, row, weighed)
+	}
+}
+
+// matchesWeights reports whether the facts row binds satisfy every weight
+// constraint. A constraint with an unbound argument or a bound that is not a
+// number is not satisfied.
+func matchesWeights(r *weightResolver, row map[string]any, constraints []datalog.Atom) bool {
+	for _, atom := range constraints {
+		if len(atom.Args) != 4 {
+			return false
+		}
+		var spo [3]string
+		for i, arg := range atom.Args[:3] {
+			val := constraintValue(row, arg)
+			if val == nil {
+				return false
+			}
+			spo[i] = fmt.Sprint(val)
+		}
+		bound, err := strconv.ParseFloat(fmt.Sprint(constraintValue(row, atom.Args[3])), 64)
+		if err != nil {
+			return false
+		}
+		if !weightConstraints[atom.Predicate](r.weight(spo[0], spo[1], spo[2]), bound) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package meb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+)
+
+func TestUpdateFactWeight(t *testing.T) {
+	s := newQueryTestStore(t)
+	ctx := context.Background()
+	hot := meb.Fact{Subject: "pkg/limits/f3.go", Predicate: "limit_test_defines", Object: "Sym3"}
+
+	if err := UpdateFactWeight(s, hot, 0); err == nil {
+		t.Error("weight 0 accepted, want an error")
+	}
+	missing := meb.Fact{Subject: "pkg/limits/f3.go", Predicate: "limit_test_defines", Object: "Sym4"}
+	if err := UpdateFactWeight(s, missing, 2); !errors.Is(err, ErrFactNotFound) {
+		t.Errorf("weighing a missing fact: err = %v, want ErrFactNotFound", err)
+	}
+
+	query := `triples(?f, "limit_test_defines", ?s), weight_gt(?f, "limit_test_defines", ?s, 5)`
+	res, err := QueryWithOptions(ctx, s, query, QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryWithOptions: %v", err)
+	}
+	if len(res.Rows) != 0 {
+		t.Errorf("rows before weighing = %v, want none", res.Rows)
+	}
+
+	if err := UpdateFactWeight(s, hot, 8); err != nil {
+		t.Fatalf("UpdateFactWeight: %v", err)
+	}
+	// The cached result must not outlive the update
+	res, err = QueryWithOptions(ctx, s, query, QueryOptions{Provenance: true})
+	if err != nil {
+		t.Fatalf("QueryWithOptions: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0]["?s"] != "Sym3" || res.Rows[0][RowWeightKey] != 8.0 {
+		t.Errorf("rows = %v, want Sym3 weighing 8", res.Rows)
+	}
+
+	// Later updates replace the weight
+	if err := UpdateFactWeight(s, hot, 4); err != nil {
+		t.Fatalf("UpdateFactWeight: %v", err)
+	}
+	res, err = QueryWithOptions(ctx, s, `triples(?f, "limit_test_defines", ?s), weight_lte(?f, "limit_test_defines", ?s, 1)`, QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryWithOptions: %v", err)
+	}
+	if len(res.Rows) != 9 {
+		t.Errorf("got %d rows of weight <= 1, want the 9 unweighted facts", len(res.Rows))
+	}
+	if w := FactWeigher(s)("pkg/limits/f3.go", "limit_test_defines", "Sym3"); w != 4 {
+		t.Errorf("weight = %v, want 4", w)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/gca/pkg/prompts"
 	"github.com/duynguyendang/meb"
 )
@@ -533,7 +534,7 @@ func extractPathString(data interface{}) string {
 }
 
 func appendSymbolContext(ctx context.Context, store *meb.MEBStore, symbolID string, sb *strings.Builder) error {
-	contentBytes, err := store.GetContentByKey(symbolID)
+	contentBytes, err := gcamdb.GetDocument(store, symbolID)
 	if err != nil {
 		return err
 	}
@@ -88,7 +88,7 @@ func (s *QueryService) ExecuteQuery(c *gin.Context) {
 }
 
 // ListQueries returns all available pre-defined queries
-// GET /api/v1/queries
+// GET /api/v1/queries/predefined
 func (s *QueryService) ListQueries(c *gin.Context) {
 	category := c.Query("category")
 
@@ -113,7 +113,7 @@ func (s *QueryService) ListQueries(c *gin.Context) {
 }
 
 // GetQuery returns details for a specific query
-// GET /api/v1/queries/:name
+// GET /api/v1/queries/predefined/:name
 func (s *QueryService) GetQuery(c *gin.Context) {
 	name := c.Param("name")
 
@@ -127,7 +127,7 @@ func (s *QueryService) GetQuery(c *gin.Context) {
 }
 
 // RegisterQuery registers a new pre-defined query
-// POST /api/v1/queries
+// POST /api/v1/queries/predefined
 func (s *QueryService) RegisterQuery(c *gin.Context) {
 	var req RegisterQueryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -177,14 +177,16 @@ func (s *QueryService) ReloadQueries(c *gin.Context) {
 	})
 }
 
-// AddRoute adds query service routes to the router
+// AddRoute adds query service routes to the router. Pre-defined queries are
+// listed under /predefined, leaving /api/v1/queries to the saved queries of
+// projects.
 func (s *QueryService) AddRoute(router *gin.Engine) {
 	api := router.Group("/api/v1/queries")
 	{
 		api.POST("/execute", s.ExecuteQuery)
-		api.GET("", s.ListQueries)
-		api.GET("/:name", s.GetQuery)
-		api.POST("", s.RegisterQuery)
+		api.GET("/predefined", s.ListQueries)
+		api.GET("/predefined/:name", s.GetQuery)
+		api.POST("/predefined", s.RegisterQuery)
 		api.POST("/reload", s.ReloadQueries)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 )
 
@@ -17,7 +18,7 @@ func HandleShow(ctx context.Context, s *meb.MEBStore, arg string) {
 	targetID := string(arg)
 
 	// Fetch document from DocStore
-	content, err := s.GetContentByKey(targetID)
+	content, err := gcamdb.GetDocument(s, targetID)
 	if err != nil {
 		fmt.Printf("❌ Failed to get document: %v\n", err)
 		return
@@ -1,10 +1,14 @@
 package repl
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 )
 
@@ -47,11 +51,10 @@ func GenerateProjectSummary(s *meb.MEBStore) (*ProjectSummary, error) {
 	// Step 4: System Statistics
 	stats := gatherStats(s, len(predicates), len(packages), len(topSymbols))
 
-	// Step 5: Entry Points
+	// Step 5: Entry Points (best effort; the summary is still useful without them)
 	entryPoints, err := extractEntryPoints(s)
 	if err != nil {
-		// Log error but don't fail summary?
-		// For now return existing
+		logger.Warn("Entry point detection failed", "error", err)
 	}
 
 	return &ProjectSummary{
@@ -134,48 +137,18 @@ func gatherStats(s *meb.MEBStore, uniquePredicates, uniquePackages, topSymbolsCo
 	return stats
 }
 
-// extractEntryPoints finds main functions and HTTP handlers.
+// extractEntryPoints lists the IDs of the project's entry points: main
+// functions, HTTP handlers, CLI commands and React root components.
 func extractEntryPoints(s *meb.MEBStore) ([]string, error) {
-	entryPoints := []string{}
-	// Scan for "defines" of "main"
-	// Heuristic: "defines" ?s where ?s ends with ":main"
-	// We can't regex scan efficiently without full scan.
-	// But we can scan symbols with specific suffix if dictionary supports it? No.
-	// Iterate valid "defines" facts.
-	// Or use SearchSymbols?
-	// Let's scan all facts with predicate "defines" and filter in memory.
-	// For large repos this is slow.
-	// Better: Use `s.IterateSymbols` to find symbols ending in ":main" or containing "Handler".
-	// But IterateSymbols iterates *all* strings.
-	// Let's iterate `defines` facts, it's safer.
-
-	// Limit to first 50 entry points to be safe.
-
-	count := 0
-	for fact, err := range s.Scan("", "defines", "") {
-		if err != nil {
-			continue
-		}
-
-		sym := string(fact.Subject)
-
-		// 1. main function
-		if strings.HasSuffix(sym, ":main") {
-			entryPoints = append(entryPoints, sym)
-			count++
-		}
-
-		// 2. HTTP Handler (heuristic naming)
-		if strings.Contains(sym, "Handler") || strings.Contains(sym, "Controller") {
-			// Check if it's a function? Need kind metadata.
-			// Just add it for now as "potential entry point".
-			// Maybe limit to avoid noise.
-		}
-
-		if count > 50 {
-			break
+	entries, err := gcamdb.EntryPoints(context.Background(), s)
+	if err != nil {
+		return []string{}, err
+	}
+	entryPoints := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !slices.Contains(entryPoints, e.ID) {
+			entryPoints = append(entryPoints, e.ID)
 		}
 	}
-	sort.Strings(entryPoints)
 	return entryPoints, nil
 }
@@ -5,10 +5,10 @@ import (
 	"strings"
 )
 
-// extractSuggestedQueries extracts suggested follow-up queries from an AI explanation.
-// It looks for common patterns like "Suggested Follow-up Queries:" or numbered lists after
-// certain keywords.
-func extractSuggestedQueries(explanation string) string {
+// ExtractSuggestedQueries extracts suggested follow-up queries from an AI explanation,
+// one per line. It looks for common patterns like "Suggested Follow-up Queries:" or
+// numbered lists after certain keywords.
+func ExtractSuggestedQueries(explanation string) string {
 	// Pattern 1: Look for "Suggested" section followed by numbered or bulleted lists
 	suggestedPattern := regexp.MustCompile(`(?i)### Suggested.*?(?:Queries|Follow.*?up).*?\n((?:.*?\n)*?)(?:\n\n|$)`)
 	matches := suggestedPattern.FindStringSubmatch(explanation)
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/datalog"
 	"github.com/duynguyendang/gca/pkg/export"
 	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/gca/pkg/prompts"
@@ -155,7 +157,7 @@ func loadPromptTemplates() (*prompts.Prompt, *prompts.Prompt, *prompts.Prompt) {
 	return nlPrompt, explainPrompt, plannerPrompt
 }
 
-// processCommand handles special REPL commands (plan, export, search, show).
+// processCommand handles special REPL commands (plan, export, search, show, \saved).
 func processCommand(ctx context.Context, cfg Config, s *meb.MEBStore, line string, projectContext *ProjectSummary, plannerPrompt *prompts.Prompt) bool {
 	if strings.HasPrefix(line, "plan ") {
 		goal := strings.TrimPrefix(line, "plan ")
@@ -185,9 +187,91 @@ func processCommand(ctx context.Context, cfg Config, s *meb.MEBStore, line strin
 		return true
 	}
 
+	if line == `\saved` || strings.HasPrefix(line, `\saved `) {
+		processSavedCommand(ctx, s, strings.TrimSpace(strings.TrimPrefix(line, `\saved`)))
+		return true
+	}
+
 	return false
 }
 
+// processSavedCommand lists the saved queries of the store's projects, or
+// runs one: \saved [project/]name [param=value ...].
+func processSavedCommand(ctx context.Context, s *meb.MEBStore, args string) {
+	queries, err := gcamdb.SavedQueries(s, "")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		if len(queries) == 0 {
+			fmt.Println("📭 No saved queries. Save some with POST /api/v1/queries.")
+			return
+		}
+		for _, q := range queries {
+			fmt.Printf("%s/%s", q.Project, q.Name)
+			for _, p := range q.Parameters {
+				fmt.Printf(" %s=", p.Name)
+				if !p.Required {
+					fmt.Printf("%q", p.Default)
+				}
+			}
+			fmt.Println()
+			if q.Description != "" {
+				fmt.Printf("   %s\n", q.Description)
+			}
+		}
+		return
+	}
+
+	project, name := "", fields[0]
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		project, name = name[:i], name[i+1:]
+	}
+	var matches []gcamdb.SavedQuery
+	for _, q := range queries {
+		if q.Name == name && (project == "" || q.Project == project) {
+			matches = append(matches, q)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		fmt.Printf("No saved query %s\n", fields[0])
+		return
+	case 1:
+	default:
+		fmt.Printf("%d projects have a query %s; name one as project/%s\n", len(matches), name, name)
+		return
+	}
+
+	params := make(map[string]string)
+	for _, f := range fields[1:] {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			fmt.Printf("Usage: \\saved [project/]name [param=value ...]\n")
+			return
+		}
+		params[k] = v
+	}
+	query, err := matches[0].Bind(params)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("📝 %s\n", query)
+	// In a store shared by several projects the query reads its own project
+	if projects, _ := gcamdb.RegisteredProjects(s); slices.Contains(projects, matches[0].Project) {
+		ctx = gcamdb.WithScope(ctx, gcamdb.ProjectScope(matches[0].Project))
+	}
+	results, err := gcamdb.Query(ctx, s, query)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	displayResults(results)
+}
+
 // processExportCommand handles the export command.
 func processExportCommand(s *meb.MEBStore, line string) {
 	argsStr := strings.TrimPrefix(line, "export ")
@@ -312,6 +396,10 @@ func processQuery(ctx context.Context, cfg Config, s *meb.MEBStore, line string,
 		datalogQuery = line
 	}
 
+	datalogQuery = recoverQuery(datalogQuery)
+	if datalogQuery == "" {
+		return
+	}
 	results, err := gcamdb.Query(context.Background(), s, datalogQuery)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -331,7 +419,7 @@ func processQuery(ctx context.Context, cfg Config, s *meb.MEBStore, line string,
 		} else {
 			fmt.Printf("\n📊 %s\n\n", explanation)
 
-			suggestedQueries := extractSuggestedQueries(explanation)
+			suggestedQueries := ExtractSuggestedQueries(explanation)
 
 			session.AddTurn(ConversationTurn{
 				UserInput:        line,
@@ -345,6 +433,29 @@ func processQuery(ctx context.Context, cfg Config, s *meb.MEBStore, line string,
 	}
 }
 
+// recoverQuery parses query tolerantly, printing each syntax error with the
+// offending line as a warning. It returns the query itself when it parses, the
+// atoms that did parse when some did not, or "" when none did.
+func recoverQuery(query string) string {
+	atoms, errs := datalog.ParseTolerant(query)
+	for _, perr := range errs {
+		fmt.Printf("⚠️  %v\n%s\n", perr, perr.Context())
+	}
+	if len(errs) == 0 {
+		return query
+	}
+	if len(atoms) == 0 {
+		return ""
+	}
+	parts := make([]string, len(atoms))
+	for i, atom := range atoms {
+		parts[i] = atom.String()
+	}
+	recovered := strings.Join(parts, ", ")
+	fmt.Printf("📝 Running the part that parsed: %s\n", recovered)
+	return recovered
+}
+
 // displayResults formats and displays query results.
 func displayResults(results []map[string]any) {
 	if len(results) == 0 {
@@ -387,17 +498,7 @@ func explainResults(ctx context.Context, cfg Config, session *SessionContext, ex
 		return "", fmt.Errorf("no result summary available")
 	}
 
-	data := map[string]interface{}{
-		"nl_query":            session.LastNLQuery,
-		"datalog":             session.LastDatalog,
-		"total_count":         session.ResultSummary.TotalCount,
-		"is_truncated":        session.ResultSummary.IsTruncated,
-		"sample_results":      session.ResultSummary.SampleResults,
-		"frequent_predicates": session.ResultSummary.FrequentPredicates,
-		"frequent_subjects":   session.ResultSummary.FrequentSubjects,
-	}
-
-	promptStr, err := explainPrompt.Execute(data)
+	promptStr, err := explainPrompt.Execute(ExplainData(session.LastNLQuery, session.LastDatalog, session.ResultSummary))
 	if err != nil {
 		return "", fmt.Errorf("failed to execute explain template: %w", err)
 	}
@@ -481,10 +582,11 @@ func executePlanCommand(ctx context.Context, cfg Config, s *meb.MEBStore, goal s
 	fmt.Println("\n🧠 Analyzing codebase and generating execution plan...")
 
 	data := map[string]interface{}{
-		"Query":      goal,
-		"Packages":   projectContext.Packages,
-		"Predicates": projectContext.Predicates,
-		"TopSymbols": projectContext.TopSymbols,
+		"Query":       goal,
+		"Packages":    projectContext.Packages,
+		"Predicates":  projectContext.Predicates,
+		"TopSymbols":  projectContext.TopSymbols,
+		"EntryPoints": projectContext.EntryPoints,
 	}
 
 	promptStr, err := plannerPrompt.Execute(data)
@@ -0,0 +1,22 @@
+package repl
+
+import "testing"
+
+func TestRecoverQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"valid", `triples(?s, "calls", ?o)`, `triples(?s, "calls", ?o)`},
+		{"partly valid", `triples(?s, "calls", ?o), bogus, regex(?o, "x")`, `triples(?s, calls, ?o), regex(?o, x)`},
+		{"invalid", `bogus`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recoverQuery(tt.query); got != tt.want {
+				t.Errorf("recoverQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -21,11 +21,25 @@ const (
 
 // ResultSummary provides a structured summary of query results.
 type ResultSummary struct {
-	TotalCount         int
-	SampleResults      []string
-	FrequentPredicates map[string]int
-	FrequentSubjects   map[string]int
-	IsTruncated        bool
+	TotalCount         int            `json:"total_count"`
+	SampleResults      []string       `json:"sample_results"`
+	FrequentPredicates map[string]int `json:"frequent_predicates"`
+	FrequentSubjects   map[string]int `json:"frequent_subjects"`
+	IsTruncated        bool           `json:"is_truncated"`
+}
+
+// ExplainData returns the input of the explain_results prompt for the results
+// summary describes, of datalog asked as nlQuery, which may be empty.
+func ExplainData(nlQuery, datalog string, summary *ResultSummary) map[string]interface{} {
+	return map[string]interface{}{
+		"nl_query":            nlQuery,
+		"datalog":             datalog,
+		"total_count":         summary.TotalCount,
+		"is_truncated":        summary.IsTruncated,
+		"sample_results":      summary.SampleResults,
+		"frequent_predicates": summary.FrequentPredicates,
+		"frequent_subjects":   summary.FrequentSubjects,
+	}
 }
 
 // SummarizeResults creates an intelligent summary of query results.
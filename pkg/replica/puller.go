@@ -0,0 +1,188 @@
+// Package replica keeps a read replica's stores in step with a primary server
+// by downloading the primary's snapshot bundles.
+package replica
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// Puller polls a primary for newer snapshots of its projects and installs them
+// in a replica's StoreManager. Projects dropped by the primary are kept.
+type Puller struct {
+	primary  string
+	apiKey   string
+	projects []string // Projects to pull; all the primary lists when empty
+	manager  *manager.StoreManager
+	client   *http.Client
+	interval time.Duration
+}
+
+// NewPuller creates a puller copying projects from the primary server at
+// primaryURL, such as "http://primary:8080" or, for a multi-tenant primary,
+// "http://primary:8080/t/<tenant>", into mgr's data root. apiKey, when set, is
+// sent as X-API-Key.
+func NewPuller(primaryURL, apiKey string, projects []string, mgr *manager.StoreManager) *Puller {
+	return &Puller{
+		primary:  strings.TrimSuffix(primaryURL, "/"),
+		apiKey:   apiKey,
+		projects: projects,
+		manager:  mgr,
+		client:   &http.Client{},
+		interval: config.ReplicaPullInterval,
+	}
+}
+
+// SetInterval sets how often Run polls the primary.
+func (p *Puller) SetInterval(d time.Duration) {
+	if d > 0 {
+		p.interval = d
+	}
+}
+
+// Run syncs the replica, then keeps syncing it every interval until ctx is done.
+// Failed syncs are logged and retried on the next tick.
+func (p *Puller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.Sync(ctx); err != nil && ctx.Err() == nil {
+			logger.Warn("Replica sync failed", "primary", p.primary, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync pulls every project whose snapshot on the primary differs from the
+// replica's copy. It returns the projects updated.
+func (p *Puller) Sync(ctx context.Context) ([]string, error) {
+	projects := p.projects
+	if len(projects) == 0 {
+		var err error
+		if projects, err = p.listProjects(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var updated []string
+	var errs []error
+	for _, project := range projects {
+		ok, err := p.pull(ctx, project)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", project, err))
+			continue
+		}
+		if ok {
+			updated = append(updated, project)
+		}
+	}
+	return updated, errors.Join(errs...)
+}
+
+// listProjects returns the IDs of the primary's projects.
+func (p *Puller) listProjects(ctx context.Context) ([]string, error) {
+	resp, err := p.get(ctx, "/api/v1/projects", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list projects: primary answered %s", resp.Status)
+	}
+	var list []manager.ProjectMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("list projects: %w", err)
+	}
+	ids := make([]string, 0, len(list))
+	for _, m := range list {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// pull downloads project's snapshot unless the replica already has it, and
+// installs it. It reports whether the project was updated.
+func (p *Puller) pull(ctx context.Context, project string) (bool, error) {
+	if err := validateProject(project); err != nil {
+		return false, err
+	}
+	dir := filepath.Join(p.manager.BaseDir(), project)
+	etag, _ := os.ReadFile(filepath.Join(dir, config.ReplicaETagFile))
+
+	resp, err := p.get(ctx, "/api/v1/replica/snapshot?project="+url.QueryEscape(project), string(etag))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("primary answered %s", resp.Status)
+	}
+
+	incoming, err := os.MkdirTemp(p.manager.BaseDir(), "."+project+".incoming-")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(incoming) // Gone already once installed
+	manifest, err := gcamdb.ReadSnapshot(resp.Body, incoming)
+	if err != nil {
+		return false, err
+	}
+	newTag := resp.Header.Get("ETag")
+	if newTag == "" {
+		newTag = manifest.ETag
+	}
+	if err := os.WriteFile(filepath.Join(incoming, config.ReplicaETagFile), []byte(newTag), 0o644); err != nil {
+		return false, err
+	}
+	if err := p.manager.ReplaceProject(project, incoming); err != nil {
+		return false, err
+	}
+	logger.Info("Replica project updated", "project", project, "facts", manifest.Facts, "files", len(manifest.Files))
+	return true, nil
+}
+
+// get sends a GET for path on the primary.
+func (p *Puller) get(ctx context.Context, path, ifNoneMatch string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.primary+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.apiKey != "" {
+		req.Header.Set("X-API-Key", p.apiKey)
+	}
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	// Bundles are gzipped already
+	req.Header.Set("Accept-Encoding", "identity")
+	return p.client.Do(req)
+}
+
+// validateProject refuses project IDs from the primary that are not a plain
+// directory name under the data root.
+func validateProject(project string) error {
+	if project == "" || project != filepath.Base(project) || strings.HasPrefix(project, ".") {
+		return fmt.Errorf("invalid project ID %q", project)
+	}
+	return nil
+}
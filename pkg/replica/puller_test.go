@@ -0,0 +1,84 @@
+package replica
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/server"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+// addFacts writes facts to the closed store in dir.
+func addFacts(t *testing.T, dir string, facts ...meb.Fact) {
+	t.Helper()
+	s, err := meb.NewMEBStore(store.DefaultConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPullerSync(t *testing.T) {
+	primaryDir := t.TempDir()
+	addFacts(t, filepath.Join(primaryDir, "demo"),
+		meb.Fact{Subject: "a.go:A", Predicate: config.PredicateCalls, Object: "b.go:B"})
+
+	primaryMgr := manager.NewStoreManager(primaryDir, manager.MemoryProfileLow, true)
+	defer primaryMgr.CloseAll()
+	primary := httptest.NewServer(server.NewServer(primaryMgr, "").Handler())
+	defer primary.Close()
+
+	replicaMgr := manager.NewStoreManager(t.TempDir(), manager.MemoryProfileLow, true)
+	defer replicaMgr.CloseAll()
+	p := NewPuller(primary.URL, "", nil, replicaMgr)
+	ctx := context.Background()
+
+	updated, err := p.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(updated, []string{"demo"}) {
+		t.Fatalf("first Sync updated %v, want [demo]", updated)
+	}
+	s, err := replicaMgr.GetStore("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 1 {
+		t.Errorf("replica Count() = %d, want 1", s.Count())
+	}
+
+	if updated, err := p.Sync(ctx); err != nil || len(updated) != 0 {
+		t.Errorf("Sync of an unchanged primary = %v, %v, want no updates", updated, err)
+	}
+
+	// The primary's store changes while the replica serves its copy
+	primaryMgr.CloseAll()
+	addFacts(t, filepath.Join(primaryDir, "demo"),
+		meb.Fact{Subject: "b.go:B", Predicate: config.PredicateCalls, Object: "c.go:C"})
+	if updated, err := p.Sync(ctx); err != nil || !slices.Equal(updated, []string{"demo"}) {
+		t.Fatalf("Sync after a primary change = %v, %v, want [demo]", updated, err)
+	}
+	s, err = replicaMgr.GetStore("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 2 {
+		t.Errorf("replica Count() after update = %d, want 2", s.Count())
+	}
+	projects, err := replicaMgr.ListProjects()
+	if err != nil || len(projects) != 1 {
+		t.Errorf("replica projects = %v, %v, want only demo", projects, err)
+	}
+}
@@ -0,0 +1,93 @@
+package review
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LineRange is a span of lines (1-based, inclusive).
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// ParseDiff reads a unified diff, such as "git diff -U0" writes, and returns
+// the changed lines of each file in the new revision. Lines only deleted
+// are marked by the two lines around the deletion. A deleted file is listed
+// under its old path with no ranges.
+func ParseDiff(r io.Reader) (map[string][]LineRange, error) {
+	changed := make(map[string][]LineRange)
+	var oldPath, file string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			oldPath = diffPath(line[4:], "a/")
+		case strings.HasPrefix(line, "+++ "):
+			file = diffPath(line[4:], "b/")
+			if file == "" {
+				// Deleted: the old path is all there is
+				if oldPath != "" {
+					changed[oldPath] = nil
+				}
+				continue
+			}
+			if _, ok := changed[file]; !ok {
+				changed[file] = nil
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			lr, err := parseHunk(line)
+			if err != nil {
+				return nil, err
+			}
+			changed[file] = append(changed[file], lr)
+		}
+	}
+	return changed, sc.Err()
+}
+
+// diffPath returns the path of a "---" or "+++" line without its a/ or b/
+// prefix, or "" for /dev/null.
+func diffPath(p, prefix string) string {
+	if i := strings.IndexByte(p, '\t'); i >= 0 {
+		p = p[:i]
+	}
+	if strings.HasPrefix(p, `"`) {
+		if unq, err := strconv.Unquote(p); err == nil {
+			p = unq
+		}
+	}
+	if p == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(p, prefix)
+}
+
+// parseHunk returns the new-side lines of a "@@ -a,b +c,d @@" header.
+func parseHunk(header string) (LineRange, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return LineRange{}, fmt.Errorf("malformed hunk header %q", header)
+	}
+	startStr, countStr, hasCount := strings.Cut(fields[2][1:], ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return LineRange{}, fmt.Errorf("malformed hunk header %q", header)
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return LineRange{}, fmt.Errorf("malformed hunk header %q", header)
+		}
+	}
+	if count == 0 {
+		// Lines deleted after line start
+		return LineRange{Start: max(start, 1), End: start + 1}, nil
+	}
+	return LineRange{Start: start, End: start + count - 1}, nil
+}
@@ -0,0 +1,118 @@
+package review
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+)
+
+// Marker opens every summary, so that a bot updating its PR comment can find
+// the one it posted before.
+const Marker = "<!-- gca-pr-review -->"
+
+// WriteMarkdown writes the report as a PR comment: a one-line verdict, a
+// table of counts and a section per finding, each listing at most
+// config.ReviewMaxListed items.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, Marker)
+	fmt.Fprintln(bw, "## Architectural impact")
+	fmt.Fprintln(bw)
+	if r.Base != "" || r.Head != "" {
+		fmt.Fprintf(bw, "`%s` → `%s`\n\n", short(r.Base), short(r.Head))
+	}
+
+	switch {
+	case len(r.BrokenCallers) > 0:
+		fmt.Fprintf(bw, "**%s to removed symbols remain.**\n\n", plural(len(r.BrokenCallers), "call", "calls"))
+	case len(r.NewDependencies) > 0:
+		fmt.Fprintf(bw, "**Adds %s between packages.**\n\n", plural(len(r.NewDependencies), "dependency", "dependencies"))
+	default:
+		fmt.Fprintln(bw, "No new package dependencies or broken callers.")
+		fmt.Fprintln(bw)
+	}
+
+	fmt.Fprintln(bw, "| | |")
+	fmt.Fprintln(bw, "|---|---:|")
+	for _, row := range []struct {
+		label string
+		n     int
+	}{
+		{"Files changed", len(r.ChangedFiles)},
+		{"Symbols added", len(r.AddedSymbols)},
+		{"Symbols removed", len(r.RemovedSymbols)},
+		{"Symbols changed", len(r.ChangedSymbols)},
+		{"New package dependencies", len(r.NewDependencies)},
+		{"Removed package dependencies", len(r.RemovedDependencies)},
+		{"New imports", len(r.NewImports)},
+		{"Broken callers", len(r.BrokenCallers)},
+		{"Entry points reaching changed code", len(r.TouchedEntryPoints)},
+	} {
+		fmt.Fprintf(bw, "| %s | %d |\n", row.label, row.n)
+	}
+
+	section(bw, "Broken callers", "These callers survive the change but call symbols it removed.", r.BrokenCallers, func(e Edge) string {
+		return fmt.Sprintf("%s → %s", code(e.From), code(e.To))
+	})
+	section(bw, "New package dependencies", "", r.NewDependencies, func(d Dependency) string {
+		return fmt.Sprintf("%s → %s (%s, e.g. %s → %s)", code(d.From), code(d.To),
+			plural(len(d.Calls), "call", "calls"), code(d.Calls[0].From), code(d.Calls[0].To))
+	})
+	section(bw, "Removed package dependencies", "", r.RemovedDependencies, func(d Dependency) string {
+		return fmt.Sprintf("%s → %s", code(d.From), code(d.To))
+	})
+	section(bw, "New imports", "", r.NewImports, code)
+	section(bw, "Entry points reaching changed code", "", r.TouchedEntryPoints, func(e EntryPoint) string {
+		if e.Hops == 0 {
+			return fmt.Sprintf("%s (%s), changed itself", code(e.ID), e.Kind)
+		}
+		return fmt.Sprintf("%s (%s) reaches %s in %s", code(e.ID), e.Kind, code(e.Changed), plural(e.Hops, "call", "calls"))
+	})
+	section(bw, "Removed symbols", "", r.RemovedSymbols, code)
+	return bw.Flush()
+}
+
+// section writes a heading and a bullet per item, up to config.ReviewMaxListed,
+// or nothing when there are no items.
+func section[T any](w io.Writer, title, intro string, items []T, format func(T) string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### %s\n\n", title)
+	if intro != "" {
+		fmt.Fprintf(w, "%s\n\n", intro)
+	}
+	for i, item := range items {
+		if i == config.ReviewMaxListed {
+			fmt.Fprintf(w, "- …and %d more\n", len(items)-i)
+			break
+		}
+		fmt.Fprintf(w, "- %s\n", format(item))
+	}
+}
+
+// code formats an ID as inline code, escaping the backticks it may hold.
+func code(s string) string {
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// short abbreviates a commit hash.
+func short(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
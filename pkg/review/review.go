@@ -0,0 +1,339 @@
+// Package review compares the code graphs of two revisions of a project, as a
+// pull request reviewer would: the package dependencies the change adds, the
+// callers left calling symbols it removed and the entry points whose behavior
+// it may change.
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/dict"
+)
+
+// Symbol is a defined symbol's place in its file.
+type Symbol struct {
+	File      string
+	StartLine int // 0 when ingest recorded no lines
+	EndLine   int
+}
+
+// Edge is a fact between two nodes: a call between symbols or an import of a
+// file.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the part of a revision's graph a review compares.
+type Graph struct {
+	Symbols     map[string]Symbol
+	Calls       map[Edge]bool
+	Imports     map[Edge]bool     // File to import path
+	EntryPoints map[string]string // Entry point ID to kind
+}
+
+// Load reads a revision's graph from its store.
+func Load(ctx context.Context, store *meb.MEBStore) (*Graph, error) {
+	g := &Graph{
+		Symbols:     make(map[string]Symbol),
+		Calls:       make(map[Edge]bool),
+		Imports:     make(map[Edge]bool),
+		EntryPoints: make(map[string]string),
+	}
+	err := scan(ctx, store, config.PredicateDefines, func(subj, obj string) {
+		if file, _, ok := strings.Cut(obj, ":"); ok {
+			g.Symbols[obj] = Symbol{File: file}
+		}
+	})
+	for _, p := range []struct {
+		pred  string
+		apply func(subj, obj string)
+	}{
+		{config.PredicateStartLine, func(subj, obj string) { g.setLine(subj, obj, true) }},
+		{config.PredicateEndLine, func(subj, obj string) { g.setLine(subj, obj, false) }},
+		{config.PredicateCalls, func(subj, obj string) { g.Calls[Edge{subj, obj}] = true }},
+		{config.PredicateImports, func(subj, obj string) { g.Imports[Edge{subj, obj}] = true }},
+	} {
+		if err != nil {
+			break
+		}
+		err = scan(ctx, store, p.pred, p.apply)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := gcamdb.EntryPoints(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		g.EntryPoints[e.ID] = e.Kind
+	}
+	return g, nil
+}
+
+// scan calls apply with the subject and object of every pred fact. A
+// predicate the store never saw has no facts.
+func scan(ctx context.Context, store *meb.MEBStore, pred string, apply func(subj, obj string)) error {
+	for fact, err := range gcamdb.Scan(ctx, store, "", pred, "") {
+		if errors.Is(err, dict.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("scan %s: %w", pred, err)
+		}
+		apply(fact.Subject, fmt.Sprint(fact.Object))
+	}
+	return nil
+}
+
+func (g *Graph) setLine(id, value string, start bool) {
+	sym, ok := g.Symbols[id]
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	if start {
+		sym.StartLine = n
+	} else {
+		sym.EndLine = n
+	}
+	g.Symbols[id] = sym
+}
+
+// PackageOf returns the package of a file or symbol: the directory of its
+// file.
+func PackageOf(id string) string {
+	file, _, _ := strings.Cut(id, ":")
+	return path.Dir(file)
+}
+
+// Dependency is a package calling into another, with the calls that make it.
+type Dependency struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Calls []Edge `json:"calls"`
+}
+
+// EntryPoint is an entry point reaching changed code.
+type EntryPoint struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Changed string `json:"changed"` // The closest changed symbol it calls, or itself
+	Hops    int    `json:"hops"`    // Calls from the entry point to Changed
+}
+
+// Report is the architectural impact of a change.
+type Report struct {
+	Base string `json:"base"`
+	Head string `json:"head"`
+
+	ChangedFiles   []string `json:"changed_files"`
+	AddedSymbols   []string `json:"added_symbols"`
+	RemovedSymbols []string `json:"removed_symbols"`
+	ChangedSymbols []string `json:"changed_symbols"` // Kept, with changed lines
+
+	NewDependencies     []Dependency `json:"new_dependencies"`     // Package pairs with no call between them before
+	RemovedDependencies []Dependency `json:"removed_dependencies"` // Package pairs with no call between them after
+	NewImports          []string     `json:"new_imports"`          // Import paths no file imported before
+	BrokenCallers       []Edge       `json:"broken_callers"`       // Calls left to symbols the change removed
+	TouchedEntryPoints  []EntryPoint `json:"touched_entry_points"`
+}
+
+// Compare reports the impact of going from base to head. changed holds the
+// changed line ranges of each changed file, by node ID ("project/pkg/a.go"),
+// in the head revision.
+func Compare(base, head *Graph, changed map[string][]LineRange) *Report {
+	r := &Report{
+		ChangedFiles:        sortedKeys(changed),
+		AddedSymbols:        []string{},
+		RemovedSymbols:      []string{},
+		ChangedSymbols:      []string{},
+		NewImports:          []string{},
+		BrokenCallers:       []Edge{},
+		TouchedEntryPoints:  []EntryPoint{},
+		NewDependencies:     []Dependency{},
+		RemovedDependencies: []Dependency{},
+	}
+
+	// Symbols
+	touched := make(map[string]bool) // Added or changed, in head
+	for id, sym := range head.Symbols {
+		if _, ok := base.Symbols[id]; !ok {
+			r.AddedSymbols = append(r.AddedSymbols, id)
+			touched[id] = true
+		} else if overlaps(changed[sym.File], sym) {
+			r.ChangedSymbols = append(r.ChangedSymbols, id)
+			touched[id] = true
+		}
+	}
+	for id := range base.Symbols {
+		if _, ok := head.Symbols[id]; !ok {
+			r.RemovedSymbols = append(r.RemovedSymbols, id)
+		}
+	}
+	sort.Strings(r.AddedSymbols)
+	sort.Strings(r.RemovedSymbols)
+	sort.Strings(r.ChangedSymbols)
+
+	// Package dependencies
+	before, after := dependencies(base), dependencies(head)
+	r.NewDependencies = missingFrom(after, before)
+	r.RemovedDependencies = missingFrom(before, after)
+
+	// Imports
+	imported := make(map[string]bool)
+	for e := range base.Imports {
+		imported[e.To] = true
+	}
+	seen := make(map[string]bool)
+	for e := range head.Imports {
+		if !imported[e.To] && !seen[e.To] {
+			seen[e.To] = true
+			r.NewImports = append(r.NewImports, e.To)
+		}
+	}
+	sort.Strings(r.NewImports)
+
+	// A call to a removed symbol is left broken when the caller survives and
+	// either still makes the call or was not changed to drop it
+	for e := range base.Calls {
+		if _, defined := base.Symbols[e.To]; !defined {
+			continue
+		}
+		if _, kept := head.Symbols[e.To]; kept {
+			continue
+		}
+		if _, ok := head.Symbols[e.From]; ok && (head.Calls[e] || !touched[e.From]) {
+			r.BrokenCallers = append(r.BrokenCallers, e)
+		}
+	}
+	sortEdges(r.BrokenCallers)
+
+	r.TouchedEntryPoints = reachingEntryPoints(head, touched)
+	return r
+}
+
+// dependencies groups the calls between symbols of different packages by
+// package pair. Calls to symbols the revision does not define are left out.
+func dependencies(g *Graph) map[Edge][]Edge {
+	deps := make(map[Edge][]Edge)
+	for e := range g.Calls {
+		if _, ok := g.Symbols[e.To]; !ok {
+			continue
+		}
+		from, to := PackageOf(e.From), PackageOf(e.To)
+		if from != to {
+			pair := Edge{from, to}
+			deps[pair] = append(deps[pair], e)
+		}
+	}
+	return deps
+}
+
+// missingFrom returns the dependencies of a that b lacks, sorted.
+func missingFrom(a, b map[Edge][]Edge) []Dependency {
+	out := []Dependency{}
+	for pair, calls := range a {
+		if _, ok := b[pair]; !ok {
+			sortEdges(calls)
+			out = append(out, Dependency{From: pair.From, To: pair.To, Calls: calls})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].From != out[j].From {
+			return out[i].From < out[j].From
+		}
+		return out[i].To < out[j].To
+	})
+	return out
+}
+
+// reachingEntryPoints returns head's entry points that reach a touched symbol
+// within config.ReviewEntryPointDepth calls, by a breadth-first walk back
+// along the calls from the touched symbols.
+func reachingEntryPoints(head *Graph, touched map[string]bool) []EntryPoint {
+	callers := make(map[string][]string)
+	for e := range head.Calls {
+		callers[e.To] = append(callers[e.To], e.From)
+	}
+	type visit struct {
+		changed string
+		hops    int
+	}
+	reached := make(map[string]visit)
+	frontier := sortedKeys(touched)
+	for _, id := range frontier {
+		reached[id] = visit{id, 0}
+	}
+	for hops := 1; hops <= config.ReviewEntryPointDepth && len(frontier) > 0; hops++ {
+		var next []string
+		for _, id := range frontier {
+			from := callers[id]
+			sort.Strings(from)
+			for _, caller := range from {
+				if _, ok := reached[caller]; !ok {
+					reached[caller] = visit{reached[id].changed, hops}
+					next = append(next, caller)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	out := []EntryPoint{}
+	for id, kind := range head.EntryPoints {
+		if v, ok := reached[id]; ok {
+			out = append(out, EntryPoint{ID: id, Kind: kind, Changed: v.changed, Hops: v.hops})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Hops != out[j].Hops {
+			return out[i].Hops < out[j].Hops
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// overlaps reports whether a symbol's lines meet one of the ranges. A symbol
+// without recorded lines meets any change to its file.
+func overlaps(ranges []LineRange, sym Symbol) bool {
+	for _, lr := range ranges {
+		if sym.StartLine == 0 || lr.Start <= sym.EndLine && sym.StartLine <= lr.End {
+			return true
+		}
+	}
+	return false
+}
+
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
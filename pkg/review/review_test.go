@@ -0,0 +1,118 @@
+package review
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func graph(symbols map[string]Symbol, calls []Edge, entries map[string]string) *Graph {
+	g := &Graph{Symbols: symbols, Calls: make(map[Edge]bool), Imports: make(map[Edge]bool), EntryPoints: entries}
+	for _, e := range calls {
+		g.Calls[e] = true
+	}
+	return g
+}
+
+func TestCompare(t *testing.T) {
+	base := graph(map[string]Symbol{
+		"p/cmd/main.go:main":      {File: "p/cmd/main.go", StartLine: 1, EndLine: 10},
+		"p/api/h.go:Handle":       {File: "p/api/h.go", StartLine: 1, EndLine: 20},
+		"p/api/h.go:legacy":       {File: "p/api/h.go", StartLine: 22, EndLine: 30},
+		"p/svc/s.go:Save":         {File: "p/svc/s.go", StartLine: 1, EndLine: 10},
+		"p/worker/w.go:Run":       {File: "p/worker/w.go", StartLine: 1, EndLine: 10},
+		"p/worker/w.go:Untouched": {File: "p/worker/w.go", StartLine: 12, EndLine: 20},
+	}, []Edge{
+		{"p/cmd/main.go:main", "p/api/h.go:Handle"},
+		{"p/api/h.go:Handle", "p/svc/s.go:Save"},
+		{"p/worker/w.go:Run", "p/api/h.go:legacy"},
+	}, map[string]string{"p/cmd/main.go:main": "main"})
+
+	// Head drops legacy (worker still calls it), and Handle now reaches the
+	// database directly
+	head := graph(map[string]Symbol{
+		"p/cmd/main.go:main":      {File: "p/cmd/main.go", StartLine: 1, EndLine: 10},
+		"p/api/h.go:Handle":       {File: "p/api/h.go", StartLine: 1, EndLine: 24},
+		"p/svc/s.go:Save":         {File: "p/svc/s.go", StartLine: 1, EndLine: 10},
+		"p/db/q.go:Exec":          {File: "p/db/q.go", StartLine: 1, EndLine: 5},
+		"p/worker/w.go:Run":       {File: "p/worker/w.go", StartLine: 1, EndLine: 10},
+		"p/worker/w.go:Untouched": {File: "p/worker/w.go", StartLine: 12, EndLine: 20},
+	}, []Edge{
+		{"p/cmd/main.go:main", "p/api/h.go:Handle"},
+		{"p/api/h.go:Handle", "p/svc/s.go:Save"},
+		{"p/api/h.go:Handle", "p/db/q.go:Exec"},
+		{"p/worker/w.go:Run", "p/api/h.go:legacy"},
+	}, map[string]string{"p/cmd/main.go:main": "main"})
+	head.Imports[Edge{"p/db/q.go", "database/sql"}] = true
+
+	r := Compare(base, head, map[string][]LineRange{
+		"p/api/h.go": {{Start: 15, End: 24}},
+		"p/db/q.go":  {{Start: 1, End: 5}},
+	})
+
+	assert.Equal(t, []string{"p/api/h.go", "p/db/q.go"}, r.ChangedFiles)
+	assert.Equal(t, []string{"p/db/q.go:Exec"}, r.AddedSymbols)
+	assert.Equal(t, []string{"p/api/h.go:legacy"}, r.RemovedSymbols)
+	assert.Equal(t, []string{"p/api/h.go:Handle"}, r.ChangedSymbols)
+	assert.Equal(t, []Dependency{{From: "p/api", To: "p/db", Calls: []Edge{{"p/api/h.go:Handle", "p/db/q.go:Exec"}}}}, r.NewDependencies)
+	assert.Equal(t, []Dependency{{From: "p/worker", To: "p/api", Calls: []Edge{{"p/worker/w.go:Run", "p/api/h.go:legacy"}}}}, r.RemovedDependencies)
+	assert.Equal(t, []string{"database/sql"}, r.NewImports)
+	assert.Equal(t, []Edge{{"p/worker/w.go:Run", "p/api/h.go:legacy"}}, r.BrokenCallers)
+	assert.Equal(t, []EntryPoint{{ID: "p/cmd/main.go:main", Kind: "main", Changed: "p/api/h.go:Handle", Hops: 1}}, r.TouchedEntryPoints)
+
+	var b strings.Builder
+	if err := r.WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	md := b.String()
+	assert.True(t, strings.HasPrefix(md, Marker))
+	assert.Contains(t, md, "**1 call to removed symbols remain.**")
+	assert.Contains(t, md, "| Broken callers | 1 |")
+	assert.Contains(t, md, "- `p/worker/w.go:Run` → `p/api/h.go:legacy`")
+	assert.Contains(t, md, "- `p/api` → `p/db` (1 call, e.g. `p/api/h.go:Handle` → `p/db/q.go:Exec`)")
+	assert.Contains(t, md, "- `p/cmd/main.go:main` (main) reaches `p/api/h.go:Handle` in 1 call")
+}
+
+func TestCompareCallerDroppingTheCall(t *testing.T) {
+	base := graph(map[string]Symbol{
+		"p/a.go:Old":    {File: "p/a.go", StartLine: 1, EndLine: 5},
+		"p/b.go:Caller": {File: "p/b.go", StartLine: 1, EndLine: 5},
+	}, []Edge{{"p/b.go:Caller", "p/a.go:Old"}}, nil)
+	head := graph(map[string]Symbol{
+		"p/b.go:Caller": {File: "p/b.go", StartLine: 1, EndLine: 6},
+	}, nil, nil)
+
+	r := Compare(base, head, map[string][]LineRange{"p/a.go": nil, "p/b.go": {{Start: 3, End: 3}}})
+	assert.Empty(t, r.BrokenCallers, "the caller was changed and no longer calls Old")
+}
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/api/h.go b/api/h.go
+index 1..2 100644
+--- a/api/h.go
++++ b/api/h.go
+@@ -15,2 +15,10 @@ func Handle() {
+@@ -40 +48 @@
+@@ -60,3 +67,0 @@
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,5 +0,0 @@
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,3 @@
+`
+	got, err := ParseDiff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string][]LineRange{
+		"api/h.go": {{15, 24}, {48, 48}, {67, 68}},
+		"old.go":   nil,
+		"new.go":   {{1, 3}},
+	}, got)
+}
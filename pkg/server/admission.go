@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrOverBudget is returned for requests whose estimate alone exceeds the budget.
+	ErrOverBudget = stderrors.New("request needs more memory than the server admits")
+	// ErrAdmissionQueueFull is returned when too many requests already wait for room.
+	ErrAdmissionQueueFull = stderrors.New("too many requests waiting for memory")
+)
+
+// Admission accounts for the memory heavy requests are estimated to need and,
+// with a budget, keeps their sum within it: a request that doesn't fit waits
+// for others to finish, up to config.AdmissionQueueTimeout, and one that could
+// never fit is refused. Without a budget requests are only accounted for.
+type Admission struct {
+	budget int64
+
+	mu       sync.Mutex
+	inUse    int64
+	inFlight int
+	queued   int
+	peak     int64
+	freed    chan struct{} // Closed, and replaced, whenever memory is released
+
+	admitted int64
+	waited   int64
+	rejected int64
+}
+
+// AdmissionStats reports an Admission's state and history.
+type AdmissionStats struct {
+	Budget   int64 `json:"budget"` // 0 when requests are only accounted for
+	InUse    int64 `json:"in_use"`
+	Peak     int64 `json:"peak"`
+	InFlight int   `json:"in_flight"`
+	Queued   int   `json:"queued"`
+	Admitted int64 `json:"admitted"`
+	Waited   int64 `json:"waited"` // Admitted after waiting for room
+	Rejected int64 `json:"rejected"`
+}
+
+// NewAdmission returns an Admission keeping requests within budget bytes, or
+// only accounting for them when budget is 0.
+func NewAdmission(budget int64) *Admission {
+	return &Admission{budget: budget, freed: make(chan struct{})}
+}
+
+// Acquire reserves est bytes, waiting for room while ctx allows. Call release
+// once the request's response is written.
+func (a *Admission) Acquire(ctx context.Context, est int64) (release func(), err error) {
+	a.mu.Lock()
+	if a.budget > 0 && est > a.budget {
+		a.rejected++
+		a.mu.Unlock()
+		return nil, fmt.Errorf("%w: estimated %d MB, budget %d MB", ErrOverBudget, est>>20, a.budget>>20)
+	}
+	waited := false
+	for a.budget > 0 && a.inUse+est > a.budget {
+		if !waited && a.queued >= config.AdmissionMaxQueued {
+			a.rejected++
+			a.mu.Unlock()
+			return nil, ErrAdmissionQueueFull
+		}
+		if !waited {
+			a.queued++
+			waited = true
+		}
+		freed := a.freed
+		a.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			a.mu.Lock()
+			a.queued--
+			a.rejected++
+			a.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		a.mu.Lock()
+	}
+	if waited {
+		a.queued--
+		a.waited++
+	}
+	a.inUse += est
+	a.inFlight++
+	a.admitted++
+	a.peak = max(a.peak, a.inUse)
+	a.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.inUse -= est
+			a.inFlight--
+			close(a.freed)
+			a.freed = make(chan struct{})
+		})
+	}, nil
+}
+
+// Stats returns the admission's current state.
+func (a *Admission) Stats() AdmissionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AdmissionStats{
+		Budget:   a.budget,
+		InUse:    a.inUse,
+		Peak:     a.peak,
+		InFlight: a.inFlight,
+		Queued:   a.queued,
+		Admitted: a.admitted,
+		Waited:   a.waited,
+		Rejected: a.rejected,
+	}
+}
+
+// queryEstimate is the memory a Datalog query returning up to limit rows is
+// estimated to need, hydrated rows carrying their nodes' source.
+func queryEstimate(limit int, hydrate bool) int64 {
+	if limit <= 0 {
+		limit = config.QueryResultLimit
+	}
+	if hydrate {
+		return int64(limit) * config.AdmissionNodeBytes
+	}
+	return int64(limit) * config.AdmissionRowBytes
+}
+
+// hydrateEstimate is the memory hydrating a node depth levels deep is estimated
+// to need: the node and up to childLimit children of each node on every level,
+// at most config.QueryMaxResultLimit nodes in all.
+func hydrateEstimate(depth, childLimit int) int64 {
+	depth = max(depth, config.HydrateDefaultDepth)
+	if childLimit <= 0 {
+		childLimit = config.HydrateDefaultChildLimit
+	}
+	nodes, level := 1, 1
+	for range depth {
+		level *= childLimit
+		nodes += level
+		if nodes >= config.QueryMaxResultLimit {
+			nodes = config.QueryMaxResultLimit
+			break
+		}
+	}
+	return int64(nodes) * config.AdmissionNodeBytes
+}
+
+// pathEstimate is the memory a path search is estimated to need.
+func pathEstimate() int64 {
+	return config.PathFindingMaxNodes * config.AdmissionRowBytes
+}
+
+// admit reserves est bytes for the request being served. When the request
+// can't be admitted it is answered and ok is false; otherwise call release
+// when the request is done.
+func (s *Server) admit(c *gin.Context, est int64) (release func(), ok bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), config.AdmissionQueueTimeout)
+	defer cancel()
+	release, err := s.admission.Acquire(ctx, est)
+	switch {
+	case err == nil:
+		return release, true
+	case stderrors.Is(err, ErrOverBudget):
+		handleError(c, errors.NewAppError(http.StatusRequestEntityTooLarge, err.Error()+"; lower the limit or depth", err))
+	default:
+		c.Header("Retry-After", strconv.Itoa(config.AdmissionRetryAfterSec))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":       "Server is busy with memory-heavy requests. Please try again later.",
+			"retry_after": config.AdmissionRetryAfterSec,
+		})
+	}
+	return nil, false
+}
+
+// MemoryReport is the server's memory diagnostics.
+type MemoryReport struct {
+	HeapAlloc   uint64                          `json:"heap_alloc"`
+	HeapInuse   uint64                          `json:"heap_inuse"`
+	Sys         uint64                          `json:"sys"`
+	MemoryLimit int64                           `json:"memory_limit"` // GOMEMLIMIT; math.MaxInt64 when unset
+	NumGC       uint32                          `json:"num_gc"`
+	Goroutines  int                             `json:"goroutines"`
+	Profile     string                          `json:"profile"`
+	Admission   AdmissionStats                  `json:"admission"`
+	HotCaches   map[string]gcamdb.HotCacheStats `json:"hot_caches"` // Of the open stores, by project
+}
+
+// handleMemory reports the process's memory use, the admission controller's
+// state and the open stores' hot caches.
+func (s *Server) handleMemory(c *gin.Context) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	c.JSON(http.StatusOK, MemoryReport{
+		HeapAlloc:   ms.HeapAlloc,
+		HeapInuse:   ms.HeapInuse,
+		Sys:         ms.Sys,
+		MemoryLimit: debug.SetMemoryLimit(-1),
+		NumGC:       ms.NumGC,
+		Goroutines:  runtime.NumGoroutine(),
+		Profile:     string(s.manager.Profile()),
+		Admission:   s.admission.Stats(),
+		HotCaches:   s.manager.HotCacheStats(),
+	})
+}
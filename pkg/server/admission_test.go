@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmission(t *testing.T) {
+	a := NewAdmission(100)
+	ctx := context.Background()
+
+	release60, err := a.Acquire(ctx, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release40, err := a.Acquire(ctx, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A request that could never fit is refused at once
+	_, err = a.Acquire(ctx, 101)
+	assert.True(t, errors.Is(err, ErrOverBudget), "got %v", err)
+
+	// One that doesn't fit now waits for room, until its deadline
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = a.Acquire(short, 50)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	admitted := make(chan func())
+	go func() {
+		release, err := a.Acquire(ctx, 50)
+		if err != nil {
+			t.Error(err)
+		}
+		admitted <- release
+	}()
+	assert.Eventually(t, func() bool { return a.Stats().Queued == 1 }, time.Second, time.Millisecond)
+	release40()
+	select {
+	case <-admitted:
+		t.Fatal("admitted with 40 bytes free")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release60()
+	release60() // Releasing twice is harmless
+	release50 := <-admitted
+
+	stats := a.Stats()
+	assert.Equal(t, int64(50), stats.InUse)
+	assert.Equal(t, int64(100), stats.Peak)
+	assert.Equal(t, 1, stats.InFlight)
+	assert.Equal(t, int64(3), stats.Admitted)
+	assert.Equal(t, int64(1), stats.Waited)
+	assert.Equal(t, int64(2), stats.Rejected)
+	release50()
+	assert.Equal(t, int64(0), a.Stats().InUse)
+
+	// Without a budget requests are only accounted for
+	unbounded := NewAdmission(0)
+	release, err := unbounded.Acquire(ctx, 1<<40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1<<40), unbounded.Stats().InUse)
+	release()
+}
+
+func TestAdmissionEstimates(t *testing.T) {
+	assert.Equal(t, int64(config.QueryResultLimit*config.AdmissionRowBytes), queryEstimate(0, false))
+	assert.Equal(t, int64(10*config.AdmissionNodeBytes), queryEstimate(10, true))
+	// A node and its default children, one level deep
+	assert.Equal(t, int64((1+config.HydrateDefaultChildLimit)*config.AdmissionNodeBytes), hydrateEstimate(0, 0))
+	// Deep trees of wide nodes are capped
+	assert.Equal(t, int64(config.QueryMaxResultLimit*config.AdmissionNodeBytes), hydrateEstimate(config.HydrateMaxDepth, config.HydrateMaxChildLimit))
+	// The low memory budget admits the largest hydration
+	assert.LessOrEqual(t, hydrateEstimate(config.HydrateMaxDepth, config.HydrateMaxChildLimit), int64(config.AdmissionBudgetLow))
+}
+
+func TestMemoryEndpoint(t *testing.T) {
+	mgr := manager.NewStoreManager(t.TempDir(), manager.MemoryProfileLow, true)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, "")
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/memory", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var report MemoryReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(manager.MemoryProfileLow), report.Profile)
+	assert.Equal(t, int64(config.AdmissionBudgetLow), report.Admission.Budget)
+	assert.Positive(t, report.HeapAlloc)
+
+	// Requests whose estimate exceeds the whole budget are refused
+	s.admission = NewAdmission(1)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hydrate?project=p&id=a.go:A", nil))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+}
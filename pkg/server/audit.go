@@ -0,0 +1,174 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditTo makes the server record every Datalog, path and AI request it
+// executes in log, and serve the log at GET /api/v1/audit. Call it before
+// serving.
+func (s *Server) AuditTo(log *gcamdb.AuditLog) {
+	s.auditLog = log
+}
+
+// audit completes an executed request's entry with who sent it and how it was
+// served, and appends it to the audit log.
+func (s *Server) audit(c *gin.Context, e gcamdb.AuditEntry, began time.Time, rows int, err error) {
+	if s.auditLog == nil {
+		return
+	}
+	e.Time = began.UTC()
+	e.User = auditUser(c)
+	e.Endpoint = c.FullPath()
+	e.LatencyUS = time.Since(began).Microseconds()
+	e.Rows = rows
+	if err != nil {
+		e.Error = err.Error()
+	}
+	s.auditLog.Append(e)
+}
+
+// resultRows returns the rows of a query result, or 0 for none.
+func resultRows(res *gcamdb.QueryResult) int {
+	if res == nil {
+		return 0
+	}
+	return len(res.Rows)
+}
+
+// graphNodes returns the nodes of a graph answer, or 0 for none.
+func graphNodes(g *export.D3Graph) int {
+	if g == nil {
+		return 0
+	}
+	return len(g.Nodes)
+}
+
+// auditUser names the sender of a request: the X-User-ID header when set,
+// otherwise a fingerprint of its API key, otherwise its address. Keys are
+// never recorded.
+func auditUser(c *gin.Context) string {
+	if user := c.GetHeader("X-User-ID"); user != "" && len(user) <= 128 {
+		return SanitizeString(user)
+	}
+	if key := requestAPIKey(c.Request); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:4])
+	}
+	return c.ClientIP()
+}
+
+// handleAudit lists audited requests, newest first.
+// Query parameters (all optional):
+//   - project, user, kind (datalog, path, ai): exact matches
+//   - q: substring of the query
+//   - since, until: RFC 3339 times
+//   - min_latency: duration such as 500ms
+//   - errors: "true" for failed requests only
+//   - before: entry ID; only older entries, for paging
+//   - limit: entries returned (default 100, max 1000)
+//   - format: "jsonl" for one entry per line, as read by "gca audit replay"
+//
+// Response: {"entries": [...], "next_before": <id>} where next_before pages
+// on when the page is full.
+func (s *Server) handleAudit(c *gin.Context) {
+	if s.auditLog == nil {
+		handleError(c, errors.NewAppError(http.StatusNotFound, "Audit log is not enabled", nil))
+		return
+	}
+	f, err := parseAuditFilter(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	entries, err := s.auditLog.Entries(f)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if c.Query("format") == "jsonl" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		enc := json.NewEncoder(c.Writer)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		}
+		return
+	}
+	resp := gin.H{"entries": entries}
+	if len(entries) == f.Limit {
+		resp["next_before"] = entries[len(entries)-1].ID
+	}
+	if entries == nil {
+		resp["entries"] = []gcamdb.AuditEntry{}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseAuditFilter reads the filters of GET /api/v1/audit.
+func parseAuditFilter(c *gin.Context) (gcamdb.AuditFilter, error) {
+	f := gcamdb.AuditFilter{
+		Project:    c.Query("project"),
+		User:       c.Query("user"),
+		Kind:       c.Query("kind"),
+		Contains:   c.Query("q"),
+		ErrorsOnly: c.Query("errors") == "true",
+		Limit:      config.AuditDefaultLimit,
+	}
+	switch f.Kind {
+	case "", gcamdb.AuditDatalog, gcamdb.AuditPath, gcamdb.AuditAI:
+	default:
+		return f, &ValidationError{Field: "kind", Message: "must be datalog, path or ai"}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, &ValidationError{Field: p.name, Message: "must be an RFC 3339 time"}
+			}
+			*p.dst = t
+		}
+	}
+	if v := c.Query("min_latency"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return f, &ValidationError{Field: "min_latency", Message: "must be a duration such as 500ms"}
+		}
+		f.MinLatency = d
+	}
+	if v := c.Query("before"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return f, &ValidationError{Field: "before", Message: "must be an entry ID"}
+		}
+		f.Before = id
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return f, &ValidationError{Field: "limit", Message: "must be an integer"}
+		}
+		if err := ValidateLimit(limit, config.AuditMaxLimit); err != nil {
+			return f, err
+		}
+		f.Limit = limit
+	}
+	return f, nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestAuditLog(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(dataDir, "projA")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddFactBatch([]meb.Fact{
+		{Subject: "a.go:A", Predicate: config.PredicateCalls, Object: "b.go:B"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	mgr := manager.NewStoreManager(dataDir, manager.MemoryProfileLow, true)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, "")
+
+	do := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/api/v1/audit", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("audit without a log = %d, want 404", w.Code)
+	}
+
+	log, err := gcamdb.OpenAuditLog(filepath.Join(dataDir, config.AuditStoreDir), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	s.AuditTo(log)
+
+	query := `{"query": "triples(?S, \"calls\", ?O)"}`
+	if w := do("POST", "/api/v1/query?project=projA&raw=true", query, map[string]string{"X-User-ID": "alice"}); w.Code != http.StatusOK {
+		t.Fatalf("query = %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/v1/query?project=missing&raw=true", query, map[string]string{"X-API-Key": "secret"}); w.Code != http.StatusNotFound {
+		t.Fatalf("query of a missing project = %d, want 404", w.Code)
+	}
+
+	list := func(params string) ([]gcamdb.AuditEntry, map[string]json.RawMessage) {
+		t.Helper()
+		w := do("GET", "/api/v1/audit"+params, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("audit%s = %d: %s", params, w.Code, w.Body.String())
+		}
+		var resp map[string]json.RawMessage
+		var entries []gcamdb.AuditEntry
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(resp["entries"], &entries); err != nil {
+			t.Fatal(err)
+		}
+		return entries, resp
+	}
+
+	entries, _ := list("")
+	if len(entries) != 2 {
+		t.Fatalf("audit entries = %+v, want 2", entries)
+	}
+	failed, ok := entries[0], entries[1]
+	if ok.User != "alice" || ok.Project != "projA" || ok.Kind != gcamdb.AuditDatalog || !ok.Raw ||
+		ok.Endpoint != "/api/v1/query" || ok.Rows != 1 || ok.Error != "" {
+		t.Errorf("audited query = %+v", ok)
+	}
+	if failed.Error == "" || !strings.HasPrefix(failed.User, "key:") || strings.Contains(failed.User, "secret") {
+		t.Errorf("audited failure = %+v, want an error and a key fingerprint as user", failed)
+	}
+
+	if entries, _ := list("?user=alice"); len(entries) != 1 || entries[0].ID != ok.ID {
+		t.Errorf("user filter = %+v", entries)
+	}
+	if entries, _ := list("?errors=true"); len(entries) != 1 || entries[0].ID != failed.ID {
+		t.Errorf("errors filter = %+v", entries)
+	}
+	entries, resp := list("?limit=1")
+	var next uint64
+	if err := json.Unmarshal(resp["next_before"], &next); err != nil || len(entries) != 1 || next != failed.ID {
+		t.Errorf("limit 1 = %+v, next_before %s", entries, resp["next_before"])
+	}
+	if entries, _ := list("?before=" + string(resp["next_before"])); len(entries) != 1 || entries[0].ID != ok.ID {
+		t.Errorf("next page = %+v", entries)
+	}
+
+	for _, params := range []string{"?kind=sql", "?since=yesterday", "?min_latency=fast", "?limit=0", "?before=x"} {
+		if w := do("GET", "/api/v1/audit"+params, "", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("audit%s = %d, want 400", params, w.Code)
+		}
+	}
+}
@@ -24,6 +24,12 @@ func CompressionMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Don't compress WebSocket upgrades; the connection is handed over
+		if c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
 		// Don't compress if response is already compressed
 		if c.Writer.Header().Get("Content-Encoding") != "" {
 			c.Next()
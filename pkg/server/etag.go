@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// conditional tags the 200 responses of a graph endpoint with an ETag derived
+// from the project's content version and the request, and answers a request
+// whose If-None-Match names the current tag with 304 and no body, before the
+// graph is computed. Requests without a project, or whose project cannot be
+// read, pass through untagged for the handler to reject.
+//
+// The tag is weak: the same graph may be sent gzipped or not.
+func (s *Server) conditional(c *gin.Context) {
+	projectID := c.Query("project")
+	if projectID == "" {
+		c.Next()
+		return
+	}
+	version, err := s.graphService.ContentVersion(c.Request.Context(), projectID)
+	if err != nil {
+		c.Next()
+		return
+	}
+	etag := graphETag(version, c.Request)
+	if etagMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Header("ETag", etag)
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Writer = &etagWriter{ResponseWriter: c.Writer, etag: etag}
+	c.Next()
+}
+
+// graphETag returns the weak ETag of the response to r under a project
+// content version. Query parameters are taken in sorted order.
+func graphETag(version string, r *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(version + "\x00" + r.URL.Path + "\x00" + r.URL.Query().Encode()))
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+}
+
+// etagMatch reports whether an If-None-Match header names etag, comparing
+// weakly as RFC 9110 requires for If-None-Match.
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// etagWriter sets the ETag header on successful responses only, so errors are
+// never revalidated against it.
+type etagWriter struct {
+	gin.ResponseWriter
+	etag string
+}
+
+// WriteHeader sets the ETag header when code is 200, then records code.
+func (w *etagWriter) WriteHeader(code int) {
+	if code == http.StatusOK {
+		w.Header().Set("ETag", w.etag)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestGraphETag(t *testing.T) {
+	tmpDir := t.TempDir()
+	pDir := filepath.Join(tmpDir, "projA")
+	if err := os.Mkdir(pDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := meb.NewMEBStore(store.DefaultConfig(pDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddFactBatch([]meb.Fact{
+		{Subject: "a.go:F", Predicate: "calls", Object: "b.go:G"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	mgr := manager.NewStoreManager(tmpDir, manager.MemoryProfileDefault, false)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, tmpDir)
+
+	get := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/v1/graph/backbone?project=projA", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("got %d with ETag %q, want 200 with an ETag", w.Code, etag)
+	}
+	if w = get("/api/v1/graph/backbone?project=projA", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("revalidation got %d with %d bytes, want 304 without a body", w.Code, w.Body.Len())
+	}
+	if other := get("/api/v1/graph/backbone?project=projA&aggregate=true", "").Header().Get("ETag"); other == etag {
+		t.Error("different parameters got the same ETag")
+	}
+	if w = get("/api/v1/graph/backbone?project=missing", etag); w.Header().Get("ETag") != "" {
+		t.Errorf("unknown project got ETag %q", w.Header().Get("ETag"))
+	}
+
+	// An annotation changes the graph responses
+	req := httptest.NewRequest("POST", "/api/v1/annotations?project=projA", strings.NewReader(`{"node": "a.go:F", "note": "hot"}`))
+	req.Header.Set("Content-Type", "application/json")
+	aw := httptest.NewRecorder()
+	s.router.ServeHTTP(aw, req)
+	if aw.Code != http.StatusCreated {
+		t.Fatalf("annotation got %d: %s", aw.Code, aw.Body.String())
+	}
+	if w = get("/api/v1/graph/backbone?project=projA", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("after an annotation got %d with ETag %q, want 200 with a new ETag", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	etag := `W/"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"xyz", W/"abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		if got := etagMatch(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatch(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -1,14 +1,22 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/duynguyendang/gca/pkg/common/errors"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	"github.com/duynguyendang/gca/pkg/jobs"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/meb/bench"
+	"github.com/duynguyendang/gca/pkg/service"
 	"github.com/duynguyendang/gca/pkg/service/ai"
 	"github.com/gin-gonic/gin"
 )
@@ -33,8 +41,18 @@ func (s *Server) handleProjects(c *gin.Context) {
 //   - lazy: enable lazy loading (default: false)
 //   - raw: return raw results instead of graph (default: false)
 //   - nocluster: disable auto-clustering (default: false)
+//   - owner: keep only nodes owned by this CODEOWNERS owner (optional)
+//   - limit: maximum result rows (default: 1000, max: 10000)
+//   - timeout: execution deadline as a Go duration, e.g. "5s" (default: 30s, max: 2m)
+//   - as_of: read the project as of this ingest version (see /api/v1/versions)
+//   - include_provenance: add _source ("file:line" or "virtual") and _weight to raw rows (default: false)
+//   - collapse: fold nodes by directory to fit a node budget (default: false)
+//   - node_budget: node budget for collapse (default: 300, max: 5000); implies collapse
+//   - seed: seed of auto-clustering, for reproducible clusters (default: 1)
+//   - max_nodes, max_links, layout: as for every graph endpoint, see respondGraph
 //
-// Response: JSON graph with nodes and links, or raw query results.
+// Response: JSON graph with nodes and links, or raw query results. Either form carries
+// a warnings array when the row limit or deadline cut the result short.
 func (s *Server) handleQuery(c *gin.Context) {
 	var req struct {
 		Query string `json:"query"`
@@ -66,37 +84,283 @@ func (s *Server) handleQuery(c *gin.Context) {
 	hydrate := c.Query("hydrate") != "false" // Hydrate by default unless ?hydrate=false
 	raw := c.Query("raw") == "true"
 	autocluster := c.Query("nocluster") != "true" // Auto-cluster by default unless ?nocluster=true
+	owner := c.Query("owner")                     // Optional: keep only nodes owned by this team/user
 
+	opts, err := parseQueryOptions(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	budget, err := parseNodeBudget(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	seed, err := parseGraphSeed(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	release, ok := s.admit(c, queryEstimate(opts.Limit, hydrate && !raw))
+	if !ok {
+		return
+	}
+	defer release()
+
+	sampled := s.queryLog.Sampled()
+	began := time.Now()
 	if raw {
-		results, err := s.graphService.ExecuteQuery(c.Request.Context(), projectID, req.Query)
+		res, err := s.graphService.ExecuteQueryWithOptions(c.Request.Context(), projectID, req.Query, opts)
+		if sampled {
+			s.recordQuery(bench.QueryRecord{Kind: bench.QueryDatalog, Project: projectID, Query: req.Query, Raw: true, Limit: opts.Limit}, began, err)
+		}
+		s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditDatalog, Project: projectID, Query: req.Query, Raw: true, Limit: opts.Limit}, began, resultRows(res), err)
 		if err != nil {
 			handleError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"results": results})
+		c.JSON(http.StatusOK, res)
 		return
 	}
 
 	// Delegate to service
-	graph, err := s.graphService.ExportGraph(c.Request.Context(), projectID, req.Query, hydrate, lazy)
+	graph, err := s.graphService.ExportGraphWithOptions(c.Request.Context(), projectID, req.Query, hydrate, lazy, opts)
+	if sampled {
+		s.recordQuery(bench.QueryRecord{Kind: bench.QueryDatalog, Project: projectID, Query: req.Query, Limit: opts.Limit}, began, err)
+	}
+	s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditDatalog, Project: projectID, Query: req.Query, Limit: opts.Limit}, began, graphNodes(graph), err)
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
+	if owner != "" {
+		graph, err = s.graphService.FilterGraphByOwner(c.Request.Context(), projectID, graph, owner)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		autocluster = false // Clustering re-runs the unfiltered query
+	}
+
+	if budget > 0 {
+		graph = graph.Collapse(budget)
+		autocluster = false // The caller asked for the directory view instead
+	}
+
 	// Auto-cluster if too many nodes
+	// Auto-cluster if too many nodes, unless clustering is at its limit
 	if autocluster && len(graph.Nodes) > config.AutoClusterThreshold {
-		clustered, clusterErr := s.graphService.GetClusterGraph(c.Request.Context(), projectID, req.Query)
-		if clusterErr == nil && len(clustered.Nodes) > 0 {
-			c.JSON(http.StatusOK, clustered)
-			return
+		if release, ok := s.tryLimit(OpCluster); ok {
+			clustered, clusterErr := s.graphService.GetClusterGraph(c.Request.Context(), projectID, req.Query, seed)
+			release()
+			if clusterErr == nil && len(clustered.Nodes) > 0 {
+				graph = clustered
+			}
 		}
 		// Fall back to original if clustering fails
 	}
 
+	s.respondGraph(c, projectID, graph)
+}
+
+// handleFederatedQuery runs one Datalog query across several projects and merges
+// the rows, each tagged with the project it came from under ?project. Triples
+// atoms sharing a variable join across projects.
+// Request body: {"query": "<datalog query>", "projects": ["frontend", "backend"]}
+// Query parameters: limit, timeout and include_provenance, as for handleQuery.
+func (s *Server) handleFederatedQuery(c *gin.Context) {
+	var req struct {
+		Query    string   `json:"query"`
+		Projects []string `json:"projects"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+
+	sanitizedQuery, err := ValidateAndSanitizeQuery(req.Query)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if len(req.Projects) == 0 {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "projects is required", nil))
+		return
+	}
+	for _, projectID := range req.Projects {
+		if err := ValidateProjectID(projectID); err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+	}
+
+	opts, err := parseQueryOptions(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if opts.AsOf > 0 {
+		// Version numbers are per store, so one version means nothing across projects
+		err := &ValidationError{Field: "as_of", Message: "is not supported for federated queries"}
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	// Every project's rows are held until they are merged
+	release, ok := s.admit(c, int64(len(req.Projects))*queryEstimate(opts.Limit, false))
+	if !ok {
+		return
+	}
+	defer release()
+
+	began := time.Now()
+	res, err := s.graphService.ExecuteFederatedQueryWithOptions(c.Request.Context(), req.Projects, sanitizedQuery, opts)
+	s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditDatalog, Project: strings.Join(req.Projects, ","), Query: sanitizedQuery, Raw: true, Limit: opts.Limit}, began, resultRows(res), err)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// parseQueryOptions reads the optional limit, timeout, as_of and include_provenance
+// query parameters.
+func parseQueryOptions(c *gin.Context) (gcamdb.QueryOptions, error) {
+	var opts gcamdb.QueryOptions
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return opts, &ValidationError{Field: "limit", Message: "must be an integer"}
+		}
+		if err := ValidateLimit(limit, config.QueryMaxResultLimit); err != nil {
+			return opts, err
+		}
+		opts.Limit = limit
+	}
+	if timeoutStr := c.Query("timeout"); timeoutStr != "" {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil || timeout <= 0 {
+			return opts, &ValidationError{Field: "timeout", Message: "must be a positive duration such as 5s"}
+		}
+		if timeout > config.QueryMaxTimeout {
+			return opts, &ValidationError{Field: "timeout", Message: fmt.Sprintf("exceeds maximum of %s", config.QueryMaxTimeout)}
+		}
+		opts.Timeout = timeout
+	}
+	if asOfStr := c.Query("as_of"); asOfStr != "" {
+		version, err := strconv.Atoi(asOfStr)
+		if err != nil || version <= 0 {
+			return opts, &ValidationError{Field: "as_of", Message: "must be a positive ingest version"}
+		}
+		opts.AsOf = version
+	}
+	opts.Provenance = c.Query("include_provenance") == "true"
+	return opts, nil
+}
+
+// parseNodeBudget reads the collapse and node_budget parameters; 0 means the
+// graph is returned uncollapsed.
+func parseNodeBudget(c *gin.Context) (int, error) {
+	budgetStr := c.Query("node_budget")
+	if budgetStr == "" {
+		if c.Query("collapse") == "true" {
+			return config.GraphNodeBudget, nil
+		}
+		return 0, nil
+	}
+	budget, err := strconv.Atoi(budgetStr)
+	if err != nil || budget <= 0 {
+		return 0, &ValidationError{Field: "node_budget", Message: "must be a positive integer"}
+	}
+	if budget > config.GraphMaxNodeBudget {
+		return 0, &ValidationError{Field: "node_budget", Message: fmt.Sprintf("exceeds maximum of %d", config.GraphMaxNodeBudget)}
+	}
+	return budget, nil
+}
+
+// respondGraph writes a graph response. Every graph endpoint goes through it so
+// they share these query parameters:
+//   - max_nodes: most central nodes to keep (default: 5000, max: 20000)
+//   - max_links: heaviest links to keep (default: 20000, max: 100000)
+//   - layout: attach precomputed x/y node positions (default: false)
+//   - min_confidence: drop inferred links scoring below it, 0 to 1 (default: 0)
+//
+// A graph cut down to the limits carries truncated:true and its original size in
+// total_nodes and total_links. The project's annotations are merged in first,
+// then inferred links are scored.
+func (s *Server) respondGraph(c *gin.Context, projectID string, graph *export.D3Graph) {
+	limits, err := parseGraphLimits(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	var minConfidence float64
+	if str := c.Query("min_confidence"); str != "" {
+		minConfidence, err = strconv.ParseFloat(str, 64)
+		if err != nil || minConfidence < 0 || minConfidence > 1 {
+			err := &ValidationError{Field: "min_confidence", Message: "must be a number between 0 and 1"}
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+	}
+	if err := s.graphService.AnnotateGraph(projectID, graph); err != nil {
+		logger.Warn("Annotating graph failed", "project", projectID, "error", err)
+	}
+	if err := s.graphService.ScoreLinks(projectID, graph, minConfidence); err != nil {
+		logger.Warn("Scoring graph links failed", "project", projectID, "error", err)
+	}
+	graph = s.graphService.LimitGraph(graph, limits)
+	if c.Query("layout") == "true" {
+		s.graphService.LayoutGraph(graph)
+	}
 	c.JSON(http.StatusOK, graph)
 }
 
+// parseGraphLimits reads the max_nodes and max_links parameters; unset limits
+// are left zero for the service defaults.
+func parseGraphLimits(c *gin.Context) (service.GraphLimits, error) {
+	var limits service.GraphLimits
+	params := []struct {
+		name  string
+		max   int
+		value *int
+	}{
+		{"max_nodes", config.GraphMaxNodesLimit, &limits.MaxNodes},
+		{"max_links", config.GraphMaxLinksLimit, &limits.MaxLinks},
+	}
+	for _, p := range params {
+		str := c.Query(p.name)
+		if str == "" {
+			continue
+		}
+		n, err := strconv.Atoi(str)
+		if err != nil || n <= 0 {
+			return limits, &ValidationError{Field: p.name, Message: "must be a positive integer"}
+		}
+		if n > p.max {
+			return limits, &ValidationError{Field: p.name, Message: fmt.Sprintf("exceeds maximum of %d", p.max)}
+		}
+		*p.value = n
+	}
+	return limits, nil
+}
+
+// parseGraphSeed reads the seed parameter that makes clustered graphs
+// reproducible; unset, it is config.GraphDefaultSeed.
+func parseGraphSeed(c *gin.Context) (int64, error) {
+	str := c.Query("seed")
+	if str == "" {
+		return config.GraphDefaultSeed, nil
+	}
+	seed, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, &ValidationError{Field: "seed", Message: "must be an integer"}
+	}
+	return seed, nil
+}
+
 // handleGraph returns a composite graph for a specific file.
 // Query parameters:
 //   - project: project ID
@@ -124,7 +388,7 @@ func (s *Server) handleGraph(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
 
 // handleSource returns source code for a given file ID.
@@ -133,8 +397,11 @@ func (s *Server) handleGraph(c *gin.Context) {
 //   - id: file or symbol ID
 //   - start: optional start line number (1-based)
 //   - end: optional end line number
+//   - mode: "symbol" to return a symbol's own range instead (start and end ignored)
 //
-// Response: Plain text source code for the specified range.
+// Response: Plain text source code for the specified range, or with
+// mode=symbol a JSON object with the code, its line range, the doc comment
+// above it and the imports it uses.
 func (s *Server) handleSource(c *gin.Context) {
 	id := c.Query("id")
 	projectID := c.Query("project")
@@ -148,6 +415,17 @@ func (s *Server) handleSource(c *gin.Context) {
 		return
 	}
 
+	// mode=symbol: the symbol's own range, found server-side, as JSON
+	if c.Query("mode") == "symbol" {
+		src, err := s.graphService.GetSymbolSource(c.Request.Context(), projectID, id)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, src)
+		return
+	}
+
 	content, err := s.graphService.GetSource(projectID, id)
 	if err != nil {
 		handleError(c, err)
@@ -187,6 +465,31 @@ func (s *Server) handleSource(c *gin.Context) {
 	c.String(http.StatusOK, result)
 }
 
+// handleSourceRevisions lists the revisions ingests recorded for a file.
+// Query parameters:
+//   - project: project ID
+//   - id: file ID
+//
+// Response: JSON array of {rev, hash, size, time, writer}, newest first.
+func (s *Server) handleSourceRevisions(c *gin.Context) {
+	id := c.Query("id")
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if err := ValidateSymbolID(id); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	revs, err := s.graphService.GetSourceRevisions(projectID, id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, revs)
+}
+
 // handleSummary returns the project summary.
 func (s *Server) handleSummary(c *gin.Context) {
 	projectID := c.Query("project")
@@ -293,6 +596,7 @@ func (s *Server) handleSymbols(c *gin.Context) {
 
 // handleFiles returns a list of all ingested files for the project.
 // Optional: ?prefix=path/to/package to filter files by prefix
+// Optional: ?limit=N&cursor=TOKEN to page; the next page's token is sent in X-Next-Cursor
 func (s *Server) handleFiles(c *gin.Context) {
 	projectID := c.Query("project")
 	prefix := c.Query("prefix")
@@ -320,9 +624,9 @@ func (s *Server) handleFiles(c *gin.Context) {
 		}
 	}
 
-	files, err := s.graphService.ListFiles(projectID)
+	opts, err := parseScanOptions(c)
 	if err != nil {
-		handleError(c, err)
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
 		return
 	}
 
@@ -334,50 +638,95 @@ func (s *Server) handleFiles(c *gin.Context) {
 		if idx := strings.LastIndex(prefix, "/"); idx != -1 {
 			pkgSuffix = prefix[idx+1:]
 		}
-		dirPrefix := pkgSuffix + "/"
+		// Match either full prefix OR directory prefix
+		opts.SubjectPrefixes = []string{prefix, pkgSuffix + "/"}
+	}
 
-		var filtered []string
-		for _, f := range files {
-			// Match either full prefix OR directory prefix
-			if strings.HasPrefix(f, prefix) || strings.HasPrefix(f, dirPrefix) {
-				filtered = append(filtered, f)
-			}
-		}
-		files = filtered
+	files, next, err := s.graphService.ListFiles(c.Request.Context(), projectID, opts)
+	if err != nil {
+		handleError(c, err)
+		return
 	}
 
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
 	c.JSON(http.StatusOK, files)
 }
 
-// handleGraphMap returns a high-level view of file dependencies.
+// parseScanOptions reads the optional limit and cursor paging parameters.
+func parseScanOptions(c *gin.Context) (gcamdb.ScanOptions, error) {
+	var opts gcamdb.ScanOptions
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return opts, &ValidationError{Field: "limit", Message: "must be an integer"}
+		}
+		if err := ValidateLimit(limit, config.MaxLimit); err != nil {
+			return opts, err
+		}
+		opts.Limit = limit
+	}
+	opts.Cursor = c.Query("cursor")
+	if err := ValidateCursor(opts.Cursor); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// handleGraphMap returns a high-level view of file dependencies, clustered
+// with the seed parameter when too large.
 func (s *Server) handleGraphMap(c *gin.Context) {
 	projectID := c.Query("project")
 	if err := ValidateProjectID(projectID); err != nil {
 		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
 		return
 	}
+	seed, err := parseGraphSeed(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
 
 	autocluster := c.Query("nocluster") != "true"
+	owner := c.Query("owner")
 
+	release, ok := s.limit(c, OpBackbone)
+	if !ok {
+		return
+	}
 	graph, err := s.graphService.GetProjectMap(c.Request.Context(), projectID)
+	release()
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	// Auto-cluster if too many nodes
-	if autocluster && len(graph.Nodes) > config.AutoClusterThreshold {
-		clustered, clusterErr := s.graphService.ClusterGraphData(graph)
-		if clusterErr == nil && len(clustered.Nodes) > 0 {
-			c.JSON(http.StatusOK, clustered)
+	if owner != "" {
+		graph, err = s.graphService.FilterGraphByOwner(c.Request.Context(), projectID, graph, owner)
+		if err != nil {
+			handleError(c, err)
 			return
 		}
+		autocluster = false // Clustering re-runs the unfiltered query
 	}
 
-	c.JSON(http.StatusOK, graph)
+	// Auto-cluster if too many nodes, unless clustering is at its limit
+	if autocluster && len(graph.Nodes) > config.AutoClusterThreshold {
+		if release, ok := s.tryLimit(OpCluster); ok {
+			clustered, clusterErr := s.graphService.ClusterGraphData(graph, seed)
+			release()
+			if clusterErr == nil && len(clustered.Nodes) > 0 {
+				graph = clustered
+			}
+		}
+	}
+
+	s.respondGraph(c, projectID, graph)
 }
 
 // handleGraphManifest returns a compressed project manifest for the AI.
+// Optional: ?limit=N&cursor=TOKEN to page; the next page's token is sent in X-Next-Cursor
 func (s *Server) handleGraphManifest(c *gin.Context) {
 	projectID := c.Query("project")
 	if err := ValidateProjectID(projectID); err != nil {
@@ -385,12 +734,21 @@ func (s *Server) handleGraphManifest(c *gin.Context) {
 		return
 	}
 
-	manifest, err := s.graphService.GetManifest(c.Request.Context(), projectID)
+	opts, err := parseScanOptions(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	manifest, next, err := s.graphService.GetManifest(c.Request.Context(), projectID, opts)
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
 	c.JSON(http.StatusOK, manifest)
 }
 
@@ -414,10 +772,18 @@ func (s *Server) handleFileDetails(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
 
-// handleHydrate returns the full hydrated symbol for a given ID.
+// handleHydrate returns the hydrated symbol for a given ID.
+// Optional: ?fields=metadata,content,children (default: metadata,content) to limit what is fetched
+// With children:
+//   - depth: levels of children (default 1, max 5)
+//   - child_limit: children per symbol on each level (default 100, max 1000)
+//   - after: a symbol's next_children token, for the next page of its children
+//
+// Symbols with children report child_count, and next_children when a page
+// stopped short of them.
 func (s *Server) handleHydrate(c *gin.Context) {
 	projectID := c.Query("project")
 	id := c.Query("id")
@@ -430,7 +796,38 @@ func (s *Server) handleHydrate(c *gin.Context) {
 		return
 	}
 
-	symbol, err := s.graphService.GetSymbol(c.Request.Context(), projectID, id)
+	fields, err := service.ParseHydrateFields(c.Query("fields"))
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	opts := service.HydrateOptions{Fields: fields, After: c.Query("after")}
+	for _, p := range []struct {
+		name string
+		dst  *int
+		max  int
+	}{{"depth", &opts.Depth, config.HydrateMaxDepth}, {"child_limit", &opts.ChildLimit, config.HydrateMaxChildLimit}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > p.max {
+			verr := &ValidationError{Field: p.name, Message: fmt.Sprintf("must be between 1 and %d", p.max)}
+			handleError(c, errors.NewAppError(http.StatusBadRequest, verr.Error(), verr))
+			return
+		}
+		*p.dst = n
+	}
+
+	release, ok := s.admit(c, hydrateEstimate(opts.Depth, opts.ChildLimit))
+	if !ok {
+		return
+	}
+	defer release()
+
+	symbol, err := s.graphService.GetSymbolWithOptions(c.Request.Context(), projectID, id, opts)
 	if err != nil {
 		handleError(c, err)
 		return
@@ -439,6 +836,31 @@ func (s *Server) handleHydrate(c *gin.Context) {
 	c.JSON(http.StatusOK, symbol)
 }
 
+// handleOwners returns the owners of a file or symbol (from CODEOWNERS / project.yaml).
+func (s *Server) handleOwners(c *gin.Context) {
+	projectID := c.Query("project")
+	id := c.Query("id")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if err := ValidateSymbolID(id); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	owners, err := s.graphService.GetOwners(c.Request.Context(), projectID, id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if owners == nil {
+		owners = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "owners": owners})
+}
+
 // handleGraphBackbone returns a filtered graph showing only cross-file dependencies.
 func (s *Server) handleGraphBackbone(c *gin.Context) {
 	projectID := c.Query("project")
@@ -448,13 +870,186 @@ func (s *Server) handleGraphBackbone(c *gin.Context) {
 	}
 
 	aggregate := c.Query("aggregate") == "true"
+	release, ok := s.limit(c, OpBackbone)
+	if !ok {
+		return
+	}
 	graph, err := s.graphService.GetBackboneGraph(c.Request.Context(), projectID, aggregate)
+	release()
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
+}
+
+// handleGraphPackages returns calls and imports rolled up to package level, with
+// link weights counting the underlying edges.
+// Optional: ?external=false to leave out packages outside the project
+func (s *Server) handleGraphPackages(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	external := c.Query("external") != "false"
+	release, ok := s.limit(c, OpBackbone)
+	if !ok {
+		return
+	}
+	graph, err := s.graphService.GetPackageGraph(c.Request.Context(), projectID, external)
+	release()
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	s.respondGraph(c, projectID, graph)
+}
+
+// handleEntryPoints lists the project's entry points with their kinds.
+func (s *Server) handleEntryPoints(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	entries, err := s.graphService.EntryPoints(c.Request.Context(), projectID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if entries == nil {
+		entries = []gcamdb.EntryPoint{}
+	}
+	c.JSON(http.StatusOK, gin.H{"project": projectID, "entry_points": entries})
+}
+
+// handleModuleSummary summarizes a module (a package directory) from its files,
+// exported symbols, dependencies and consumers, citing the IDs it draws on.
+// Required: ?project=X&path=pkg/meb
+func (s *Server) handleModuleSummary(c *gin.Context) {
+	if s.aiService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI service not initialized (missing API Key)"})
+		return
+	}
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	modulePath := c.Query("path")
+	if err := ValidateSymbolID(modulePath); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	mc, err := s.graphService.GetModuleContext(c.Request.Context(), projectID, modulePath)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	summary, err := s.aiService.SummarizeModule(c.Request.Context(), mc)
+	if err != nil {
+		logger.Error("Module summary failed", "project", projectID, "path", mc.Path, "error", err)
+		handleError(c, errors.NewAppError(http.StatusInternalServerError, "module summary failed", err))
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// handleExplainResults explains what a query's results say about the code's
+// architecture and suggests follow-up queries, as the REPL does after each
+// natural language query. The results are the rows the client got from
+// /api/v1/query?raw=true; large ones are summarized before reaching the model.
+//
+// Request body: {"query": "<datalog>", "results": [{...}], "question": "optional NL question"}
+// Response: {"explanation", "suggestions", "summary"}.
+func (s *Server) handleExplainResults(c *gin.Context) {
+	if s.aiService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI service not initialized (missing API Key)"})
+		return
+	}
+	var req struct {
+		Query    string           `json:"query"`
+		Results  []map[string]any `json:"results"`
+		Question string           `json:"question"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+	query, err := ValidateAndSanitizeQuery(req.Query)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if len(req.Results) > config.QueryMaxResultLimit {
+		err := &ValidationError{Field: "results", Message: fmt.Sprintf("too many rows (maximum %d)", config.QueryMaxResultLimit)}
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if req.Question != "" {
+		if err := ValidateQuery(req.Question); err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+	}
+
+	explanation, err := s.aiService.ExplainResults(c.Request.Context(), req.Question, query, req.Results)
+	if err != nil {
+		logger.Error("Result explanation failed", "error", err)
+		handleError(c, errors.NewAppError(http.StatusInternalServerError, "result explanation failed", err))
+		return
+	}
+	c.JSON(http.StatusOK, explanation)
+}
+
+// handleSmartSearch answers a question about the code in one request:
+// NL -> Datalog, execution as a graph, semantic search when the query finds
+// nothing, and an answer over the graph citing its nodes. The response holds
+// what each stage produced and a step log, so that the UI can show them.
+// Required: ?project=X, body {"query": "how are requests authenticated?"}
+func (s *Server) handleSmartSearch(c *gin.Context) {
+	if s.aiService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI service not initialized (missing API Key)"})
+		return
+	}
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	var req struct {
+		Query string `json:"query"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+	if err := ValidateQuery(req.Query); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	began := time.Now()
+	res, err := s.aiService.SmartSearch(c.Request.Context(), s.graphService, projectID, req.Query)
+	rows := 0
+	if res != nil {
+		rows = len(res.Graph.Nodes)
+	}
+	s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditAI, Project: projectID, Query: req.Query}, began, rows, err)
+	if deadlineExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusNotFound, "project not found: "+projectID, err))
+		return
+	}
+	c.JSON(http.StatusOK, res)
 }
 
 // handleFileCalls returns a recursive file-to-file call graph.
@@ -490,12 +1085,15 @@ func (s *Server) handleFileCalls(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
 
 // handleError is a helper that converts errors to JSON responses.
 // It uses the errors.MapError function to convert errors to AppError with HTTP status codes.
 func handleError(c *gin.Context, err error) {
+	if deadlineExceeded(c, err) {
+		return
+	}
 	appErr := errors.MapError(err)
 	c.JSON(appErr.Code, gin.H{"error": appErr.Message})
 }
@@ -519,16 +1117,28 @@ func (s *Server) handleFlowPath(c *gin.Context) {
 		return
 	}
 
+	release, ok := s.admit(c, pathEstimate())
+	if !ok {
+		return
+	}
+	defer release()
+
+	began := time.Now()
 	graph, err := s.graphService.GetFlowPath(c.Request.Context(), projectID, from, to)
+	s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditPath, Project: projectID, Source: from, Target: to}, began, graphNodes(graph), err)
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
 
 // handleGraphPath returns the shortest interaction path between two symbols using BFS.
+// Optional query parameters restrict the edges followed:
+//   - predicates: comma-separated predicates, e.g. "calls" or "imports" (default: all)
+//   - direction: "forward" (default), "reverse" or "undirected"
+//   - max_depth: edges in the path (default and maximum: 10)
 func (s *Server) handleGraphPath(c *gin.Context) {
 	projectID := c.Query("project")
 	source := c.Query("source")
@@ -547,22 +1157,74 @@ func (s *Server) handleGraphPath(c *gin.Context) {
 		return
 	}
 
-	graph, err := s.graphService.FindShortestPath(c.Request.Context(), projectID, source, target)
+	opts, err := parsePathOptions(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	release, ok := s.admit(c, pathEstimate())
+	if !ok {
+		return
+	}
+	defer release()
+
+	sampled := s.queryLog.Sampled()
+	began := time.Now()
+	graph, err := s.graphService.FindShortestPathWithOptions(c.Request.Context(), projectID, source, target, opts)
+	if sampled {
+		s.recordQuery(bench.QueryRecord{Kind: bench.QueryPath, Project: projectID, Source: source, Target: target,
+			Predicates: opts.Predicates, Direction: opts.Direction, Depth: opts.MaxDepth}, began, err)
+	}
+	s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditPath, Project: projectID, Source: source, Target: target,
+		Predicates: opts.Predicates, Direction: opts.Direction, Depth: opts.MaxDepth}, began, graphNodes(graph), err)
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
+}
+
+// parsePathOptions reads the optional predicates, direction and max_depth query
+// parameters of a path search.
+func parsePathOptions(c *gin.Context) (service.PathOptions, error) {
+	var opts service.PathOptions
+	for _, pred := range strings.Split(c.Query("predicates"), ",") {
+		if pred = strings.TrimSpace(pred); pred != "" {
+			opts.Predicates = append(opts.Predicates, pred)
+		}
+	}
+	switch direction := c.Query("direction"); direction {
+	case "", service.PathForward, service.PathReverse, service.PathUndirected:
+		opts.Direction = direction
+	default:
+		return opts, &ValidationError{Field: "direction", Message: "must be forward, reverse or undirected"}
+	}
+	if depthStr := c.Query("max_depth"); depthStr != "" {
+		depth, err := strconv.Atoi(depthStr)
+		if err != nil {
+			return opts, &ValidationError{Field: "max_depth", Message: "must be an integer"}
+		}
+		if err := ValidateDepth(depth); err != nil {
+			return opts, err
+		}
+		opts.MaxDepth = depth
+	}
+	return opts, nil
 }
 
 // handleSemanticSearch performs vector similarity search on embedded documentation.
+// Without an embedding provider, or when the project has no embeddings, it falls
+// back to keyword search over symbol names and doc comments.
 // Query parameters:
 //   - project: project ID
 //   - q: search query string
 //   - k: number of results to return (default: 10, max: 50)
+//   - mode: "keyword" to skip vector search
 //
-// Response: JSON with query, count, and results array of matching symbols.
+// Response: JSON with query, count, mode ("embedding" or "keyword"), and results
+// array of matching symbols.
 func (s *Server) handleSemanticSearch(c *gin.Context) {
 	projectID := c.Query("project")
 	query := c.Query("q")
@@ -592,27 +1254,109 @@ func (s *Server) handleSemanticSearch(c *gin.Context) {
 		return
 	}
 
-	// Get embedding for query using AI Service
-	if s.aiService == nil {
-		handleError(c, errors.NewAppError(http.StatusServiceUnavailable, "AI service not initialized", nil))
+	mode := "keyword"
+	var results []service.SemanticSearchResult
+	sampled := s.queryLog.Sampled()
+	if s.aiService != nil && c.Query("mode") != "keyword" {
+		embedder := &embeddingRecorder{embedder: s.aiService}
+		began := time.Now()
+		var err error
+		results, err = s.graphService.SemanticSearch(c.Request.Context(), projectID, query, k, embedder)
+		if sampled && embedder.vector != nil {
+			s.recordQuery(bench.QueryRecord{Kind: bench.QueryVector, Project: projectID, Vector: embedder.vector, K: k}, began, err)
+		}
+		if err != nil {
+			logger.Warn("Semantic search failed, falling back to keyword search", "project", projectID, "error", err)
+		} else if len(results) > 0 {
+			mode = "embedding"
+		}
+	}
+	if mode == "keyword" {
+		began := time.Now()
+		var err error
+		results, err = s.graphService.KeywordSearch(c.Request.Context(), projectID, query, k)
+		if sampled {
+			s.recordQuery(bench.QueryRecord{Kind: bench.QueryKeyword, Project: projectID, Tokens: gcamdb.Tokenize(query), K: k}, began, err)
+		}
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   query,
+		"count":   len(results),
+		"mode":    mode,
+		"results": results,
+	})
+}
+
+// handleDocSearch searches the project's doc comments apart from its code,
+// combining keyword search with vector search restricted to doc nodes.
+// GET /v1/search/docs?project=X&q=...
+// Query parameters:
+//   - project: project ID
+//   - q: search query string
+//   - k: number of results to return (default: 10, max: 50)
+//   - mode: "keyword" to skip vector search
+//
+// Response: JSON with query, count, mode ("hybrid" or "keyword"), and results
+// array of matching doc nodes with the symbol each documents.
+func (s *Server) handleDocSearch(c *gin.Context) {
+	projectID := c.Query("project")
+	query := c.Query("q")
+
+	k, err := strconv.Atoi(c.DefaultQuery("k", "10"))
+	if err != nil || k <= 0 {
+		k = 10
+	}
+	if k > 50 {
+		k = 50 // Cap results
+	}
+
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if query == "" {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Missing q parameter", nil))
+		return
+	}
+	query = SanitizeString(query)
+	if len(query) > config.MaxQueryLength {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "query exceeds maximum length", nil))
 		return
 	}
 
-	results, err := s.graphService.SemanticSearch(c.Request.Context(), projectID, query, k, s.aiService)
+	var embedder interface {
+		GetEmbedding(ctx context.Context, text string) ([]float32, error)
+	}
+	if s.aiService != nil && c.Query("mode") != "keyword" {
+		embedder = s.aiService
+	}
+	results, vectors, err := s.graphService.DocSearch(c.Request.Context(), projectID, query, k, embedder)
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
+	mode := "keyword"
+	if vectors {
+		mode = "hybrid"
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"query":   query,
 		"count":   len(results),
+		"mode":    mode,
 		"results": results,
 	})
 }
 
 // handleGraphCluster returns a clustered graph for large result sets.
-// GET /v1/graph/cluster?project=X&query=...
+// GET /v1/graph/cluster?project=X&query=...&seed=N
+// The same seed always yields the same clusters (default: 1). With
+// async=true it runs as a background job whose result is the graph.
 func (s *Server) handleGraphCluster(c *gin.Context) {
 	projectID := c.Query("project")
 	query := c.Query("query")
@@ -621,6 +1365,11 @@ func (s *Server) handleGraphCluster(c *gin.Context) {
 		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
 		return
 	}
+	seed, err := parseGraphSeed(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
 	if query == "" {
 		handleError(c, errors.NewAppError(http.StatusBadRequest, "Missing query parameter", nil))
 		return
@@ -633,19 +1382,49 @@ func (s *Server) handleGraphCluster(c *gin.Context) {
 		return
 	}
 
-	graph, err := s.graphService.GetClusterGraph(c.Request.Context(), projectID, query)
+	if async(c) {
+		s.startJob(c, "cluster", projectID, func(ctx context.Context, run *jobs.Run) (any, error) {
+			run.Progress("waiting for a "+OpCluster+" slot", 0, 0)
+			release, err := s.waitLimit(ctx, OpCluster)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+			run.Progress("clustering", 0, 0)
+			graph, err := s.graphService.GetClusterGraph(ctx, projectID, query, seed)
+			if err != nil {
+				return nil, err
+			}
+			run.Logf("Clustered %d nodes and %d links", len(graph.Nodes), len(graph.Links))
+			return graph, nil
+		})
+		return
+	}
+
+	release, ok := s.limit(c, OpCluster)
+	if !ok {
+		return
+	}
+	graph, err := s.graphService.GetClusterGraph(c.Request.Context(), projectID, query, seed)
+	release()
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
 
 // handleGraphSubgraph returns a subgraph matching the provided IDs.
+// A Datalog "query" in the body adds the nodes it binds, and "expand" (body or
+// ?expand=1) adds neighbors up to that many hops away. Either makes the
+// subgraph hold every stored edge among its nodes; without them, links come
+// from the project map.
 func (s *Server) handleGraphSubgraph(c *gin.Context) {
 	var req struct {
-		Ids []string `json:"ids"`
+		Ids    []string `json:"ids"`
+		Query  string   `json:"query"`
+		Expand int      `json:"expand"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
@@ -658,30 +1437,71 @@ func (s *Server) handleGraphSubgraph(c *gin.Context) {
 		return
 	}
 
-	// Validate IDs list
-	if err := ValidateIDs(req.Ids); err != nil {
+	if expandStr := c.Query("expand"); expandStr != "" {
+		expand, err := strconv.Atoi(expandStr)
+		if err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, "expand must be an integer", err))
+			return
+		}
+		req.Expand = expand
+	}
+	if req.Expand < 0 || req.Expand > config.SubgraphMaxExpand {
+		err := &ValidationError{Field: "expand", Message: fmt.Sprintf("must be between 0 and %d", config.SubgraphMaxExpand)}
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	if req.Query != "" {
+		if err := ValidateQuery(req.Query); err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+		if len(req.Ids) > 0 {
+			if err := ValidateIDs(req.Ids); err != nil {
+				handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+				return
+			}
+		}
+	} else if err := ValidateIDs(req.Ids); err != nil {
 		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
 		return
 	}
 
-	graph, err := s.graphService.GetSubgraph(c.Request.Context(), projectID, req.Ids)
+	var graph *export.D3Graph
+	var err error
+	if req.Query != "" || req.Expand > 0 {
+		graph, err = s.graphService.GetSubgraphWithOptions(c.Request.Context(), projectID, service.SubgraphOptions{IDs: req.Ids, Query: req.Query, Expand: req.Expand})
+	} else {
+		graph, err = s.graphService.GetSubgraph(c.Request.Context(), projectID, req.Ids)
+	}
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
 
-// handleGraphCommunities returns the hierarchical community structure.
+// handleGraphCommunities returns the hierarchical community structure, detected
+// with the seed parameter (default: 1).
 func (s *Server) handleGraphCommunities(c *gin.Context) {
 	projectID := c.Query("project")
 	if err := ValidateProjectID(projectID); err != nil {
 		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
 		return
 	}
+	seed, err := parseGraphSeed(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
 
-	hierarchy, err := s.graphService.DetectCommunityHierarchy(c.Request.Context(), projectID)
+	release, ok := s.limit(c, OpCluster)
+	if !ok {
+		return
+	}
+	hierarchy, err := s.graphService.DetectCommunityHierarchy(c.Request.Context(), projectID, seed)
+	release()
 	if err != nil {
 		handleError(c, err)
 		return
@@ -735,7 +1555,12 @@ func (s *Server) handleHybridCluster(c *gin.Context) {
 		return
 	}
 
+	release, ok := s.limit(c, OpCluster)
+	if !ok {
+		return
+	}
 	result, err := s.graphService.GetHybridClusters(c.Request.Context(), projectID, req.Embedding, req.Limit, req.Clusters)
+	release()
 	if err != nil {
 		handleError(c, err)
 		return
@@ -850,7 +1675,7 @@ func (s *Server) handleWhoCalls(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
 
 // handleWhatCalls returns all callees of a symbol (forward slice).
@@ -897,7 +1722,7 @@ func (s *Server) handleWhatCalls(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
 
 // handleCheckReachability checks if symbol A can reach symbol B.
@@ -1008,6 +1833,109 @@ func (s *Server) handleEnrichCalledBy(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "enriched", "predicate": "called_by"})
 }
 
+// handleCompactDictionary drops dictionary strings no fact, document or vector
+// references. It runs as a dry run unless dry_run=false is passed, and as a
+// background job with async=true. A compaction that deletes holds the stores
+// exclusively: it waits for the requests, ingests and sweeps using them, and
+// holds back new ones until it is done.
+func (s *Server) handleCompactDictionary(c *gin.Context) {
+	projectID := c.Query("project")
+
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	dryRun := c.DefaultQuery("dry_run", "true") != "false"
+	if !dryRun && s.readOnly(c) {
+		return
+	}
+	compact := func(ctx context.Context) (*gcamdb.CompactReport, error) {
+		hold := s.manager.Hold
+		if !dryRun {
+			hold = s.manager.HoldExclusive
+		}
+		release := hold()
+		defer release()
+		return s.graphService.CompactDictionary(ctx, projectID, dryRun)
+	}
+	if async(c) {
+		s.startJob(c, "compact", projectID, func(ctx context.Context, run *jobs.Run) (any, error) {
+			return compact(ctx)
+		})
+		return
+	}
+	report, err := compact(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// handleCatalogs returns the catalogs background maintenance built of the open
+// stores, by project: their fact counts, in all and by predicate, and when
+// they were refreshed.
+func (s *Server) handleCatalogs(c *gin.Context) {
+	c.JSON(http.StatusOK, s.manager.Catalogs())
+}
+
+// handleVersions lists the project's ingest versions, which queries can read as
+// of with ?as_of=.
+// Query parameters:
+//   - project: project ID
+//
+// Response: JSON array of versions with id, created_at and added/removed fact counts.
+func (s *Server) handleVersions(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	versions, err := s.graphService.ListVersions(c.Request.Context(), projectID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if versions == nil {
+		versions = []gcamdb.Version{}
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// handleFreshness reports how current a project's graph is with its source
+// tree. Projects watched by the server (server --watch) report their pending
+// changed files; other projects report their latest ingest version as the last
+// sync.
+// Path parameters:
+//   - id: project ID
+//
+// Response: ingest.Freshness
+func (s *Server) handleFreshness(c *gin.Context) {
+	projectID := c.Param("id")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if w, ok := s.watchers[projectID]; ok {
+		c.JSON(http.StatusOK, w.Freshness())
+		return
+	}
+
+	versions, err := s.graphService.ListVersions(c.Request.Context(), projectID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	f := ingest.Freshness{Project: projectID, Pending: []string{}}
+	if len(versions) > 0 {
+		f.LastSync = versions[len(versions)-1].CreatedAt
+	}
+	c.JSON(http.StatusOK, f)
+}
+
 // handleAsk is a unified endpoint for natural language queries.
 // It classifies the intent, converts to Datalog, executes, and synthesizes an answer.
 //
@@ -1057,7 +1985,13 @@ func (s *Server) handleAsk(c *gin.Context) {
 		Context:   req.Context,
 	}
 
+	began := time.Now()
 	resp, err := s.aiService.HandleAsk(c.Request.Context(), askReq)
+	s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditAI, Project: req.ProjectID, Query: req.Query}, began, 0, err)
+	// Failed stages are reported in the response; one that ran out of time is a timeout
+	if (err != nil || resp.Error != "") && deadlineExceeded(c, err) {
+		return
+	}
 	if err != nil {
 		handleError(c, errors.NewAppError(http.StatusInternalServerError, err.Error(), err))
 		return
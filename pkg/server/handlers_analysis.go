@@ -0,0 +1,209 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+// handleVulnerabilities lists known vulnerabilities and the packages/symbols they affect.
+// Query parameters:
+//   - project: project ID
+//   - severity: optional severity filter (e.g. "HIGH")
+//
+// Response: JSON with a vulnerabilities array, most severe first.
+func (s *Server) handleVulnerabilities(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	reports, err := s.graphService.GetVulnerabilities(c.Request.Context(), projectID, c.Query("severity"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"vulnerabilities": reports})
+}
+
+// handleDuplicates lists groups of near-duplicate functions found at ingest.
+// Query parameters:
+//   - project: project ID
+//   - min_score: lowest similarity of the pairs grouped, 0 to 1 (default: 0)
+//   - limit: number of groups to return (default: 50)
+//
+// Response: JSON with a groups array, largest first.
+func (s *Server) handleDuplicates(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	var minScore float64
+	if str := c.Query("min_score"); str != "" {
+		parsed, err := strconv.ParseFloat(str, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			err := &ValidationError{Field: "min_score", Message: "must be a number between 0 and 1"}
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+		minScore = parsed
+	}
+	limit := config.DuplicateDefaultLimit
+	if str := c.Query("limit"); str != "" {
+		parsed, err := strconv.Atoi(str)
+		if err != nil || parsed < 1 {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, "limit must be a positive integer", err))
+			return
+		}
+		limit = parsed
+	}
+
+	groups, err := s.graphService.GetDuplicates(c.Request.Context(), projectID, minScore, limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+// handleComplexity returns the most complex functions and methods in a project.
+// Query parameters:
+//   - project: project ID
+//   - top: number of symbols to return (default: 50)
+//   - sort: ranking metric, one of complexity, loc, params (default: complexity)
+//
+// Response: JSON with a symbols array of per-symbol metrics.
+func (s *Server) handleComplexity(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	top := config.DefaultSearchLimit
+	if topStr := c.Query("top"); topStr != "" {
+		parsed, err := strconv.Atoi(topStr)
+		if err != nil || parsed < 1 {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, "top must be a positive integer", err))
+			return
+		}
+		top = parsed
+	}
+
+	symbols, err := s.graphService.GetComplexityHotspots(c.Request.Context(), projectID, top, c.Query("sort"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"symbols": symbols})
+}
+
+// handleTopSymbols returns a project's most connected or most central symbols,
+// for an "important symbols" panel. Rankings are cached until the next ingest.
+// Query parameters:
+//   - project: project ID
+//   - metric: degree (calls and imports, the default), pagerank (over calls) or fanin (callers)
+//   - limit: number of symbols to return (default: 50)
+//
+// Response: JSON with a symbols array, best first, each with its centrality
+// from 0 to 1 under the metric.
+func (s *Server) handleTopSymbols(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	limit := config.DefaultSearchLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err == nil {
+			err = ValidateLimit(parsed, config.TopSymbolsMaxLimit)
+		} else {
+			err = &ValidationError{Field: "limit", Message: "must be an integer"}
+		}
+		if err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+		limit = parsed
+	}
+
+	metric := c.DefaultQuery("metric", service.MetricDegree)
+	switch metric {
+	case service.MetricDegree, service.MetricPageRank, service.MetricFanIn:
+	default:
+		err := &ValidationError{Field: "metric", Message: "must be degree, pagerank or fanin"}
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	symbols, err := s.graphService.GetTopSymbols(c.Request.Context(), projectID, metric, limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metric": metric, "symbols": symbols})
+}
+
+// handleSymbolOverview returns what a node-detail panel shows about a symbol
+// in one response: code, signature, doc comment, callers and callees, metrics,
+// tags, owners and the LLM summary. Symbol IDs hold slashes, so the id path
+// segment must be escaped (%2F); UIDs need no escaping.
+// Query parameters:
+//   - project: project ID
+//   - limit: callers and callees to list (default: 20); metrics count them all
+//
+// Response: JSON SymbolOverview
+func (s *Server) handleSymbolOverview(c *gin.Context) {
+	projectID := c.Query("project")
+	id := c.Param("id")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if err := ValidateSymbolID(id); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	limit := config.OverviewNeighborLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err == nil {
+			err = ValidateLimit(parsed, config.OverviewMaxNeighborLimit)
+		} else {
+			err = &ValidationError{Field: "limit", Message: "must be an integer"}
+		}
+		if err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+		limit = parsed
+	}
+
+	release, ok := s.admit(c, hydrateEstimate(0, 0))
+	if !ok {
+		return
+	}
+	defer release()
+
+	overview, err := s.graphService.GetSymbolOverview(c.Request.Context(), projectID, id, limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
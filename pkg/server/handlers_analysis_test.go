@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleSymbolOverview(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(dataDir, "projA")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetTopicID(gcamdb.TopicForProject("projA")) // As ingest writes it
+	if err := db.AddFactBatch([]meb.Fact{
+		{Subject: "api/get.go:Get", Predicate: config.PredicateHasKind, Object: config.SymbolKindFunc},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateCalls, Object: "db/db.go:Query"},
+		{Subject: "api/list.go:List", Predicate: config.PredicateCalls, Object: "api/get.go:Get"},
+		{Subject: "api/show.go:Show", Predicate: config.PredicateCalls, Object: "api/get.go:Get"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	mgr := manager.NewStoreManager(dataDir, manager.MemoryProfileLow, true)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, "")
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/v1/symbols/" + url.PathEscape("api/get.go:Get") + "/overview?project=projA&limit=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("overview = %d: %s", w.Code, w.Body.String())
+	}
+	var ov service.SymbolOverview
+	if err := json.Unmarshal(w.Body.Bytes(), &ov); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "api/get.go:Get", ov.ID)
+	assert.Equal(t, config.SymbolKindFunc, ov.Kind)
+	assert.Equal(t, []string{"api/list.go:List"}, ov.Callers)
+	assert.Equal(t, 2, ov.Metrics.Callers)
+	assert.Equal(t, []string{"db/db.go:Query"}, ov.Callees)
+
+	assert.Equal(t, http.StatusOK, get("/api/v1/symbols/top?project=projA").Code, "the overview route leaves top symbols reachable")
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/symbols/"+url.PathEscape("api/gone.go:Gone")+"/overview?project=projA").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/symbols/"+url.PathEscape("api/get.go:Get")+"/overview?project=projA&limit=0").Code)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+// handleAddAnnotation adds a note, status labels or a manual link to the
+// project's annotations graph. Annotations are merged into hydrated symbols and
+// every graph response.
+// Query parameters:
+//   - project: project ID
+//
+// Request body, annotating a node:
+//
+//	{"node": "<symbol ID>", "note": "...", "labels": ["deprecated"], "author": "..."}
+//
+// or an edge, adding it as a virtual link when the extractor did not find it:
+//
+//	{"source": "<ID>", "target": "<ID>", "relation": "calls", "virtual": true, "note": "..."}
+//
+// An edge annotation may also confirm or reject the edge, an inferred one
+// typically; verdicts set its confidence and tune that of links inferred the
+// same way:
+//
+//	{"source": "<ID>", "target": "<ID>", "relation": "calls_api", "verdict": "rejected"}
+//
+// Either may set "expires_at" (RFC 3339); otherwise the store's retention
+// policy for the annotations or virtual graph decides when it expires.
+//
+// Response: 201 with the stored annotation, including its ID.
+func (s *Server) handleAddAnnotation(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	var req service.Annotation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+	for _, id := range []string{req.Node, req.Source, req.Target} {
+		if id == "" {
+			continue
+		}
+		if err := ValidateSymbolID(id); err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+	}
+	if len(req.Note) > config.MaxNoteLength {
+		err := &ValidationError{Field: "note", Message: "exceeds maximum length"}
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	annotation, err := s.graphService.AddAnnotation(projectID, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, annotation)
+}
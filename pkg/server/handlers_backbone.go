@@ -3,11 +3,14 @@ package server
 import (
 	"net/http"
 
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/logger"
 	"github.com/gin-gonic/gin"
 )
 
-// handleFileBackbone returns the bidirectional file-level dependency graph backbone.
+// handleFileBackbone returns the bidirectional file-level dependency graph backbone,
+// clustered with the seed parameter when too large.
 func (s *Server) handleFileBackbone(c *gin.Context) {
 	projectID := c.Query("project")
 	fileID := c.Query("id")
@@ -25,24 +28,37 @@ func (s *Server) handleFileBackbone(c *gin.Context) {
 	}
 
 	autocluster := c.Query("nocluster") != "true"
+	seed, err := parseGraphSeed(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
 
+	release, ok := s.limit(c, OpBackbone)
+	if !ok {
+		return
+	}
 	graph, err := s.graphService.GetFileBackbone(c.Request.Context(), projectID, fileID)
+	release()
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	// Auto-cluster if too many nodes (>500)
-	if autocluster && len(graph.Nodes) > 500 {
-		logger.Debug("Auto-Clustering Backbone clustering", "nodes", len(graph.Nodes))
-		clustered, clusterErr := s.graphService.ClusterGraphData(graph)
-		if clusterErr == nil && len(clustered.Nodes) > 0 {
-			logger.Debug("Auto-Clustering Success", "clusterNodes", len(clustered.Nodes))
-			c.JSON(http.StatusOK, clustered)
-			return
+	// Auto-cluster if too many nodes, unless clustering is at its limit
+	if autocluster && len(graph.Nodes) > config.AutoClusterThreshold {
+		if release, ok := s.tryLimit(OpCluster); ok {
+			logger.Debug("Auto-Clustering Backbone clustering", "nodes", len(graph.Nodes))
+			clustered, clusterErr := s.graphService.ClusterGraphData(graph, seed)
+			release()
+			if clusterErr == nil && len(clustered.Nodes) > 0 {
+				logger.Debug("Auto-Clustering Success", "clusterNodes", len(clustered.Nodes))
+				s.respondGraph(c, projectID, clustered)
+				return
+			}
+			logger.Warn("Auto-Clustering Failed", "error", clusterErr)
 		}
-		logger.Warn("Auto-Clustering Failed", "error", clusterErr)
 	}
 
-	c.JSON(http.StatusOK, graph)
+	s.respondGraph(c, projectID, graph)
 }
@@ -0,0 +1,93 @@
+package server
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/gin-gonic/gin"
+)
+
+// handleImportFacts adds facts produced by external analyzers, such as linters
+// or runtime tracers, to a project's graph.
+// Query parameters:
+//   - project: project ID
+//   - format: ndjson (default) or csv; a text/csv body also selects csv
+//
+// Request body, one fact per line:
+//
+//	{"subject": "<ID>", "predicate": "...", "object": "...", "graph": "<project>", "weight": 0.8, "source": "golangci-lint"}
+//
+// or CSV with a header row naming the same columns. An import with an invalid
+// line writes nothing; the 400 response lists the lines in "errors".
+// Response: 201 with {"project", "imported", "existing", "version"}.
+func (s *Server) handleImportFacts(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	format := c.Query("format")
+	if format == "" && strings.HasPrefix(c.ContentType(), "text/csv") {
+		format = ingest.ImportFormatCSV
+	}
+
+	report, err := s.graphService.ImportFacts(projectID, c.Request.Body, format)
+	if err != nil {
+		var ierr *ingest.ImportError
+		if stderrors.As(err, &ierr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ierr.Error(), "errors": ierr.Errors, "truncated": ierr.Truncated})
+			return
+		}
+		handleError(c, err)
+		return
+	}
+	s.notifyImport(report)
+	c.JSON(http.StatusCreated, report)
+}
+
+// handleUpdateFactWeights sets the weights of facts already in a project's
+// graph, so that analyses such as hot-path detection can refine edge weights
+// as they learn. Weights show in query provenance and graph links, and queries
+// filter on them with weight_gt(?s, "calls", ?o, 10) and friends.
+// Query parameters:
+//   - project: project ID
+//
+// Request body:
+//
+//	{"weights": [{"subject": "<ID>", "predicate": "calls", "object": "<ID>", "weight": 12.5}]}
+//
+// Weights must be positive. Nothing is written when any fact is missing (404)
+// or any weight is invalid (400).
+// Response: 200 with {"project", "updated"}.
+func (s *Server) handleUpdateFactWeights(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	var req struct {
+		Weights []gcamdb.FactWeight `json:"weights"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+	if len(req.Weights) == 0 || len(req.Weights) > config.FactImportMaxFacts {
+		err := &ValidationError{Field: "weights", Message: "must list between 1 and " + strconv.Itoa(config.FactImportMaxFacts) + " facts"}
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	if err := s.graphService.UpdateFactWeights(projectID, req.Weights); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"project": projectID, "updated": len(req.Weights)})
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Code intelligence methods, named after the LSP requests editor plugins map
+// them to.
+const (
+	intelDefinition    = "textDocument/definition"
+	intelReferences    = "textDocument/references"
+	intelHover         = "textDocument/hover"
+	intelIncomingCalls = "callHierarchy/incomingCalls"
+	intelOutgoingCalls = "callHierarchy/outgoingCalls"
+)
+
+// JSON-RPC 2.0 error codes. Codes from -32000 down are the server's own.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcNotFound       = -32001 // The project does not exist
+)
+
+// rpcRequest is a JSON-RPC 2.0 request. A request without an ID is a
+// notification and gets no response.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// intelParams are the parameters of every code intelligence method: the
+// project and the position asked about.
+type intelParams struct {
+	Project string `json:"project"`
+	service.Position
+}
+
+// handleIntel answers code intelligence requests from editor plugins over
+// JSON-RPC 2.0, one request or a batch per POST.
+// Methods:
+//   - textDocument/definition: where the symbol at the position is defined
+//   - textDocument/references: the symbols calling or referencing it
+//   - textDocument/hover: its kind, doc comment, summary and call counts
+//   - callHierarchy/incomingCalls, callHierarchy/outgoingCalls: its callers and callees
+//
+// Params: {"project": "...", "file": "pkg/a.go", "line": 12, "word": "Save"}
+// The line is 1-based; the optional word is the identifier under the cursor,
+// which picks the symbol it names over the one enclosing the line. Locations
+// are {"id", "file", "start_line", "end_line"}. A position no symbol covers
+// yields an empty list, or null for hover.
+func (s *Server) handleIntel(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Failed to read request body", err))
+		return
+	}
+	trimmed := bytes.TrimSpace(body)
+
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil || len(batch) == 0 {
+			c.JSON(http.StatusOK, rpcFailure(nil, rpcInvalidRequest, "invalid batch"))
+			return
+		}
+		responses := make([]rpcResponse, 0, len(batch))
+		for _, raw := range batch {
+			if resp, ok := s.serveIntel(c.Request.Context(), raw); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.JSON(http.StatusOK, responses)
+		return
+	}
+
+	resp, ok := s.serveIntel(c.Request.Context(), trimmed)
+	if !ok {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// serveIntel runs one request. It reports false for notifications, which get
+// no response.
+func (s *Server) serveIntel(ctx context.Context, raw json.RawMessage) (rpcResponse, bool) {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var probe any
+		if json.Unmarshal(raw, &probe) != nil {
+			return rpcFailure(nil, rpcParseError, "parse error"), true
+		}
+		return rpcFailure(nil, rpcInvalidRequest, "invalid request"), true
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFailure(req.ID, rpcInvalidRequest, `invalid request: want "jsonrpc": "2.0" and a method`), true
+	}
+	if len(req.ID) == 0 {
+		return rpcResponse{}, false
+	}
+
+	var params intelParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return rpcFailure(req.ID, rpcInvalidParams, "invalid params: "+err.Error()), true
+	}
+	if err := ValidateProjectID(params.Project); err != nil {
+		return rpcFailure(req.ID, rpcInvalidParams, err.Error()), true
+	}
+
+	var result any
+	var err error
+	switch req.Method {
+	case intelDefinition:
+		result, err = s.graphService.Definition(ctx, params.Project, params.Position)
+	case intelReferences:
+		result, err = s.graphService.References(ctx, params.Project, params.Position)
+	case intelHover:
+		var hover *service.Hover
+		if hover, err = s.graphService.HoverAt(ctx, params.Project, params.Position); hover != nil {
+			result = hover
+		}
+	case intelIncomingCalls:
+		result, err = s.graphService.IncomingCalls(ctx, params.Project, params.Position)
+	case intelOutgoingCalls:
+		result, err = s.graphService.OutgoingCalls(ctx, params.Project, params.Position)
+	default:
+		return rpcFailure(req.ID, rpcMethodNotFound, "method not found: "+req.Method), true
+	}
+	if err != nil {
+		switch {
+		case errors.IsInvalidInput(err):
+			return rpcFailure(req.ID, rpcInvalidParams, err.Error()), true
+		case errors.IsNotFound(err):
+			return rpcFailure(req.ID, rpcNotFound, err.Error()), true
+		}
+		return rpcFailure(req.ID, rpcInternalError, errors.MapError(err).Message), true
+	}
+	if result == nil {
+		// A hover over nothing is null, which omitempty would drop
+		return rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage("null")}, true
+	}
+	return rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}, true
+}
+
+func rpcFailure(id json.RawMessage, code int, message string) rpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: message}}
+}
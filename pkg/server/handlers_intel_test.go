@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleIntel(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(dataDir, "projA")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetTopicID(gcamdb.TopicForProject("projA")) // As ingest writes it
+	if err := db.AddFactBatch([]meb.Fact{
+		{Subject: "a.go", Predicate: config.PredicateDefines, Object: "a.go:A"},
+		{Subject: "a.go:A", Predicate: config.PredicateStartLine, Object: 3},
+		{Subject: "a.go:A", Predicate: config.PredicateEndLine, Object: 9},
+		{Subject: "a.go:A", Predicate: config.PredicateCalls, Object: "b.go:B"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	mgr := manager.NewStoreManager(dataDir, manager.MemoryProfileLow, true)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, "")
+
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/intel", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+	call := func(body string) rpcResponse {
+		t.Helper()
+		w := do(body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("intel = %d: %s", w.Code, w.Body.String())
+		}
+		var resp rpcResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := call(`{"jsonrpc": "2.0", "id": 1, "method": "callHierarchy/outgoingCalls",
+		"params": {"project": "projA", "file": "a.go", "line": 5}}`)
+	assert.Nil(t, resp.Error)
+	assert.JSONEq(t, `1`, string(resp.ID))
+	out, _ := json.Marshal(resp.Result)
+	assert.JSONEq(t, `[{"id": "b.go:B", "file": "b.go"}]`, string(out))
+
+	resp = call(`{"jsonrpc": "2.0", "id": "h", "method": "textDocument/hover",
+		"params": {"project": "projA", "file": "a.go", "line": 20}}`)
+	assert.Nil(t, resp.Error)
+	assert.Nil(t, resp.Result, "a hover over nothing is null")
+
+	for body, code := range map[string]int{
+		`{"jsonrpc": "2.0", "id": 2, "method": "textDocument/rename", "params": {"project": "projA", "file": "a.go", "line": 5}}`:    rpcMethodNotFound,
+		`{"jsonrpc": "2.0", "id": 3, "method": "textDocument/definition", "params": {"project": "projA", "file": "a.go"}}`:           rpcInvalidParams,
+		`{"jsonrpc": "2.0", "id": 4, "method": "textDocument/definition", "params": {"project": "nope", "file": "a.go", "line": 1}}`: rpcNotFound,
+		`{"id": 5, "method": "textDocument/definition"}`:                                                                             rpcInvalidRequest,
+		`{"jsonrpc": `: rpcParseError,
+	} {
+		resp := call(body)
+		if assert.NotNil(t, resp.Error, body) {
+			assert.Equal(t, code, resp.Error.Code, body)
+		}
+	}
+
+	// Batches answer every request but the notifications
+	w := do(`[
+		{"jsonrpc": "2.0", "id": 1, "method": "textDocument/definition", "params": {"project": "projA", "file": "a.go", "line": 4}},
+		{"jsonrpc": "2.0", "method": "textDocument/definition", "params": {"project": "projA", "file": "a.go", "line": 4}}
+	]`)
+	var batch []rpcResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, batch, 1) {
+		def, _ := json.Marshal(batch[0].Result)
+		assert.JSONEq(t, `[{"id": "a.go:A", "file": "a.go", "start_line": 3, "end_line": 9}]`, string(def))
+	}
+
+	w = do(`{"jsonrpc": "2.0", "method": "textDocument/hover", "params": {}}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/jobs"
+	"github.com/gin-gonic/gin"
+)
+
+// async reports whether the request asks to run as a background job
+// (?async=true) rather than answer when done.
+func async(c *gin.Context) bool {
+	return c.Query("async") == "true"
+}
+
+// startJob runs fn as a background job and answers 202 with the job, which
+// GET /api/v1/jobs/:id then follows. The job outlives the request, and holds
+// the stores while it runs unless the request's route is one of unheldRoutes.
+// Response: 202 {"job": {...}}
+func (s *Server) startJob(c *gin.Context, kind, projectID string, fn jobs.Func) {
+	held := !unheldRoutes[c.FullPath()]
+	job := s.jobs.Start(context.Background(), kind, projectID, func(ctx context.Context, run *jobs.Run) (any, error) {
+		if held {
+			release := s.manager.Hold()
+			defer release()
+		}
+		return fn(ctx, run)
+	})
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// handleListJobs lists the running jobs and the last finished ones, newest
+// first, without their logs and results.
+// Query parameters:
+//   - kind: only jobs of this kind ("ingest", "cluster", "summary", "compact")
+//   - project: only jobs on this project
+//   - state: only jobs in this state ("running", "succeeded", "failed", "cancelled")
+//
+// Response: {"jobs": [...]}
+func (s *Server) handleListJobs(c *gin.Context) {
+	filter := jobs.Filter{Kind: c.Query("kind"), Project: c.Query("project"), State: jobs.State(c.Query("state"))}
+	if filter.Project != "" {
+		if err := ValidateProjectID(filter.Project); err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+	}
+	switch filter.State {
+	case "", jobs.StateRunning, jobs.StateSucceeded, jobs.StateFailed, jobs.StateCancelled:
+	default:
+		err := &ValidationError{Field: "state", Message: "must be running, succeeded, failed or cancelled"}
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": s.jobs.List(filter)})
+}
+
+// handleGetJob returns a job with its progress, logs and, once it succeeded,
+// its result.
+// Response: the job, or 404 when there is no such job or it was dropped from
+// the history.
+func (s *Server) handleGetJob(c *gin.Context) {
+	job, ok := s.jobs.Get(c.Param("id"))
+	if !ok {
+		handleError(c, errors.NewAppError(http.StatusNotFound, "Job not found", nil))
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// handleCancelJob cancels a running job. The job stops at its next check of
+// its context, then reports state "cancelled".
+// Response: 202 {"job": {...}}, 404 when there is no such job or 409 when it
+// has already finished.
+func (s *Server) handleCancelJob(c *gin.Context) {
+	job, err := s.jobs.Cancel(c.Param("id"))
+	switch {
+	case stderrors.Is(err, jobs.ErrNotFound):
+		handleError(c, errors.NewAppError(http.StatusNotFound, "Job not found", err))
+	case stderrors.Is(err, jobs.ErrFinished):
+		handleError(c, errors.NewAppError(http.StatusConflict, err.Error(), err))
+	case err != nil:
+		handleError(c, err)
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"job": job})
+	}
+}
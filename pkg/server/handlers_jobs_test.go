@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/jobs"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleJobs(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(dataDir, "projA")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetTopicID(gcamdb.TopicForProject("projA"))
+	if err := db.AddFact(meb.Fact{Subject: "a.go:F", Predicate: "calls", Object: "b.go:G"}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	mgr := manager.NewStoreManager(dataDir, manager.MemoryProfileDefault, false)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, "")
+	do := func(method, path string, out any) int {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		if out != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+				t.Fatalf("%s %s: %v: %s", method, path, err, w.Body.String())
+			}
+		}
+		return w.Code
+	}
+
+	var started struct{ Job jobs.Job }
+	if code := do("POST", "/api/v1/admin/compact-dictionary?project=projA&async=true", &started); code != http.StatusAccepted {
+		t.Fatalf("async compaction = %d", code)
+	}
+	assert.Equal(t, "compact", started.Job.Kind)
+	assert.Equal(t, "projA", started.Job.Project)
+	s.jobs.Wait()
+
+	var job jobs.Job
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/jobs/"+started.Job.ID, &job))
+	assert.Equal(t, jobs.StateSucceeded, job.State)
+	assert.NotNil(t, job.Result, "the job keeps the compaction report")
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/v1/jobs/missing", nil))
+
+	// A compaction that deletes waits for the stores' holders
+	release := mgr.Hold()
+	if code := do("POST", "/api/v1/admin/compact-dictionary?project=projA&async=true&dry_run=false", &started); code != http.StatusAccepted {
+		t.Fatalf("async compaction = %d", code)
+	}
+	time.Sleep(50 * time.Millisecond)
+	job, _ = s.jobs.Get(started.Job.ID)
+	assert.Equal(t, jobs.StateRunning, job.State, "compaction ran while the stores were held")
+	release()
+	s.jobs.Wait()
+	job, _ = s.jobs.Get(started.Job.ID)
+	assert.Equal(t, jobs.StateSucceeded, job.State)
+
+	running := s.jobs.Start(context.Background(), "cluster", "projA", func(ctx context.Context, run *jobs.Run) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	var listed struct{ Jobs []jobs.Job }
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/jobs?project=projA&state=running", &listed))
+	if assert.Len(t, listed.Jobs, 1) {
+		assert.Equal(t, running.ID, listed.Jobs[0].ID)
+	}
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/v1/jobs?state=paused", nil))
+
+	assert.Equal(t, http.StatusAccepted, do("POST", "/api/v1/jobs/"+running.ID+"/cancel", nil))
+	s.jobs.Wait()
+	do("GET", "/api/v1/jobs/"+running.ID, &job)
+	assert.Equal(t, jobs.StateCancelled, job.State)
+	assert.Equal(t, http.StatusConflict, do("POST", "/api/v1/jobs/"+running.ID+"/cancel", nil), "finished jobs cannot be cancelled")
+	assert.Equal(t, http.StatusNotFound, do("POST", "/api/v1/jobs/missing/cancel", nil))
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/service/ai"
+	"github.com/gin-gonic/gin"
+)
+
+// savedQueryRequest is the body saving a query. Without a query, the query is
+// generated from the question.
+type savedQueryRequest struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Query       string                   `json:"query"`
+	Question    string                   `json:"question"`
+	Parameters  []gcamdb.SavedQueryParam `json:"parameters"`
+}
+
+// bindSavedQuery reads a savedQueryRequest into the query to save, generating
+// its query from its question when it has none.
+func (s *Server) bindSavedQuery(c *gin.Context, projectID string, req savedQueryRequest) (gcamdb.SavedQuery, error) {
+	q := gcamdb.SavedQuery{
+		Name:        req.Name,
+		Description: req.Description,
+		Parameters:  req.Parameters,
+	}
+	if err := ValidateQueryName(req.Name); err != nil {
+		return q, errors.NewAppError(http.StatusBadRequest, err.Error(), err)
+	}
+	if req.Query == "" && req.Question != "" {
+		if err := ValidateQuery(req.Question); err != nil {
+			return q, errors.NewAppError(http.StatusBadRequest, err.Error(), err)
+		}
+		store, err := s.manager.GetStore(projectID)
+		if err != nil {
+			return q, errors.NewAppError(http.StatusNotFound, "project not found: "+projectID, err)
+		}
+		intent := ai.ClassifyIntent(req.Question)
+		gen, err := ai.GenerateDatalog(c.Request.Context(), req.Question, intent.Intent, intent.Target, store)
+		if err != nil {
+			return q, errors.NewAppError(http.StatusUnprocessableEntity, "could not generate a query from the question", err)
+		}
+		req.Query = gen.Query
+		q.Question = req.Question
+	}
+	query, err := ValidateAndSanitizeQuery(req.Query)
+	if err != nil {
+		return q, errors.NewAppError(http.StatusBadRequest, err.Error(), err)
+	}
+	q.Query = query
+	return q, nil
+}
+
+// handleListSavedQueries lists a project's saved queries.
+// Query parameters:
+//   - project: project ID
+//
+// Response: {"queries": [...], "total": N}, by name.
+func (s *Server) handleListSavedQueries(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	queries, err := s.graphService.ListSavedQueries(c.Request.Context(), projectID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if queries == nil {
+		queries = []gcamdb.SavedQuery{}
+	}
+	c.JSON(http.StatusOK, gin.H{"queries": queries, "total": len(queries)})
+}
+
+// handleCreateSavedQuery saves a named query in a project's library.
+// Query parameters:
+//   - project: project ID
+//
+// Request body:
+//
+//	{"name": "callers-of", "description": "...", "query": "triples(?c, \"calls\", \"{symbol}\")",
+//	 "parameters": [{"name": "symbol", "description": "...", "default": "", "required": true}]}
+//
+// {name} placeholders of the query without a declared parameter become
+// required parameters. A "question" in place of the query has the query
+// generated from it. Response: 201 with the saved query, or 409 when the
+// project already has a query of that name.
+func (s *Server) handleCreateSavedQuery(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	var req savedQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+
+	q, err := s.bindSavedQuery(c, projectID, req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if _, err := s.graphService.GetSavedQuery(c.Request.Context(), projectID, q.Name); err == nil {
+		handleError(c, errors.NewAppError(http.StatusConflict, "query '"+q.Name+"' already exists", nil))
+		return
+	} else if !stderrors.Is(err, errors.ErrNotFound) {
+		handleError(c, err)
+		return
+	}
+	saved, _, err := s.graphService.SaveQuery(c.Request.Context(), projectID, q)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, saved)
+}
+
+// handleGetSavedQuery returns a saved query.
+// Query parameters:
+//   - project: project ID
+func (s *Server) handleGetSavedQuery(c *gin.Context) {
+	projectID := c.Query("project")
+	name := c.Param("name")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	q, err := s.graphService.GetSavedQuery(c.Request.Context(), projectID, name)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+// handleUpdateSavedQuery saves the query named in the path, replacing it if
+// it exists. The body is the one of handleCreateSavedQuery, without the name.
+// Response: 200 with the saved query, or 201 when it is new.
+func (s *Server) handleUpdateSavedQuery(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	var req savedQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+	req.Name = c.Param("name")
+
+	q, err := s.bindSavedQuery(c, projectID, req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	saved, created, err := s.graphService.SaveQuery(c.Request.Context(), projectID, q)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, saved)
+}
+
+// handleDeleteSavedQuery deletes a saved query.
+// Query parameters:
+//   - project: project ID
+func (s *Server) handleDeleteSavedQuery(c *gin.Context) {
+	projectID := c.Query("project")
+	name := c.Param("name")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	if err := s.graphService.DeleteSavedQuery(c.Request.Context(), projectID, name); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handleRunSavedQuery runs a saved query with its parameters bound.
+// Query parameters:
+//   - project: project ID
+//   - limit, timeout, as_of, include_provenance: as for /api/v1/query
+//
+// Request body: {"params": {"symbol": "pkg/meb/store.go:Query"}}, optional
+// when every parameter has a default. Values may not hold quotes.
+// Response: the rows, as raw /api/v1/query responses give them, with the name
+// of the query and the query that was run.
+func (s *Server) handleRunSavedQuery(c *gin.Context) {
+	projectID := c.Query("project")
+	name := c.Param("name")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	var req struct {
+		Params map[string]string `json:"params"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+			return
+		}
+	}
+	opts, err := parseQueryOptions(c)
+	if err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	release, ok := s.admit(c, queryEstimate(opts.Limit, false))
+	if !ok {
+		return
+	}
+	defer release()
+
+	began := time.Now()
+	res, query, err := s.graphService.RunSavedQuery(c.Request.Context(), projectID, name, req.Params, opts)
+	if query != "" {
+		s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditDatalog, Project: projectID, Query: query, Raw: true, Limit: opts.Limit}, began, resultRows(res), err)
+	}
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+		*gcamdb.QueryResult
+	}{name, query, res})
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/gin-gonic/gin"
+)
+
+// handleSnapshot streams a snapshot bundle of a project's store for read
+// replicas (gca replica --pull).
+// Query parameters:
+//   - project: project ID
+//
+// The response carries the store's ETag; a request whose If-None-Match names
+// it gets 304 without a body, so replicas can poll cheaply.
+func (s *Server) handleSnapshot(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	// Polls of an unchanged store are answered without copying it
+	etag, err := s.manager.SnapshotETag(projectID)
+	if err == nil && c.GetHeader("If-None-Match") == etag {
+		c.Header("ETag", etag)
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if err == nil {
+		err = s.manager.SnapshotProject(projectID, func(dir, etag string, facts uint64) error {
+			c.Header("ETag", etag)
+			c.Header("Content-Type", "application/gzip")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.snapshot.tar.gz"`, projectID))
+			c.Status(http.StatusOK)
+			m := &gcamdb.SnapshotManifest{Project: projectID, Facts: facts, ETag: etag, CreatedAt: time.Now().UTC()}
+			return gcamdb.WriteSnapshot(c.Writer, dir, m)
+		})
+	}
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		// Too late for an error response; the replica fails to verify the bundle
+		logger.Error("Snapshot stream failed", "project", projectID, "error", err)
+		return
+	}
+	switch {
+	case stderrors.Is(err, manager.ErrSharedSnapshot):
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+	case strings.Contains(err.Error(), "not found"):
+		handleError(c, fmt.Errorf("%w: %v", errors.ErrNotFound, err))
+	default:
+		handleError(c, fmt.Errorf("%w: %v", errors.ErrInternal, err))
+	}
+}
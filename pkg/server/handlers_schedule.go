@@ -0,0 +1,61 @@
+package server
+
+import (
+	stderrors "errors"
+	"net/http"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	"github.com/gin-gonic/gin"
+)
+
+// Schedule registers the scheduler re-ingesting projects on cron schedules, so
+// that GET /api/v1/admin/schedules reports its jobs, their runs are tracked as
+// background jobs and they are published to the webhooks. Call it before
+// serving.
+func (s *Server) Schedule(sch *ingest.Scheduler) {
+	s.scheduler = sch
+	sch.OnSync(s.notifySync)
+	sch.TrackJobs(s.jobs)
+}
+
+// handleSchedules lists the scheduled ingest jobs: their schedule, next and
+// last run, and whether one is running.
+// Response: {"jobs": [...]}
+func (s *Server) handleSchedules(c *gin.Context) {
+	if s.scheduler == nil {
+		handleError(c, errors.NewAppError(http.StatusNotFound, "No ingests are scheduled", nil))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": s.scheduler.Status()})
+}
+
+// handleRunSchedule starts a project's scheduled ingest now, without waiting
+// for it to finish.
+// Response: 202 {"project", "started", "job_id"}, the ID of the background job
+// running the ingest; 404 when the project has no job or 409 when its ingest
+// is already running.
+func (s *Server) handleRunSchedule(c *gin.Context) {
+	if s.scheduler == nil {
+		handleError(c, errors.NewAppError(http.StatusNotFound, "No ingests are scheduled", nil))
+		return
+	}
+	project := c.Param("project")
+	err := s.scheduler.Start(project)
+	switch {
+	case stderrors.Is(err, ingest.ErrNoJob):
+		handleError(c, errors.NewAppError(http.StatusNotFound, err.Error(), err))
+	case stderrors.Is(err, ingest.ErrJobRunning):
+		handleError(c, errors.NewAppError(http.StatusConflict, err.Error(), err))
+	case err != nil:
+		handleError(c, err)
+	default:
+		var jobID string
+		for _, st := range s.scheduler.Status() {
+			if st.Project == project {
+				jobID = st.JobID
+			}
+		}
+		c.JSON(http.StatusAccepted, gin.H{"project": project, "started": true, "job_id": jobID})
+	}
+}
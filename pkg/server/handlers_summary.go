@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	"github.com/duynguyendang/gca/pkg/jobs"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// fileSummarizer returns the summarizer batch summaries run on, created on
+// first use since most servers never run one. A failed creation, such as with
+// no API key set yet, is tried again on the next call.
+func (s *Server) fileSummarizer() (ingest.Summarizer, error) {
+	s.summarizerMu.Lock()
+	defer s.summarizerMu.Unlock()
+	if s.summarizer == nil {
+		svc, err := ingest.NewFileSummaryService(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		s.summarizer = svc
+	}
+	return s.summarizer, nil
+}
+
+// handleBatchSummary summarizes every file of a package in one request, so that
+// a file browser need not ask /api/v1/ai/ask once per file. Files are sent to
+// the model in batches run concurrently under a rate limit, and the summaries
+// are stored as has_summary facts, which hydration and graphs then carry.
+// Query parameters:
+//   - project: project ID
+//
+// Request body:
+//
+//	{"path": "pkg/meb", "force": false}
+//
+// Files that already have a summary are skipped unless "force" is set.
+// Response: 200 with NDJSON, one {"progress": {"done", "total", "written",
+// "error"}} line per batch as it completes, then {"report": {"files",
+// "written", "cached", "skipped", "failed"}}, or {"error": "..."} when the run
+// stops early. Errors before the first batch get the usual JSON error status.
+// With ?async=true the run is a background job instead, answered 202 with the
+// job, whose progress counts the files and whose result is the report.
+func (s *Server) handleBatchSummary(c *gin.Context) {
+	if s.aiService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI service not initialized (missing API Key)"})
+		return
+	}
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	var req struct {
+		Path  string `json:"path"`
+		Force bool   `json:"force"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+	if err := ValidateSymbolID(req.Path); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	summarizer, err := s.fileSummarizer()
+	if err != nil {
+		logger.Error("File summarizer unavailable", "error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "file summarizer unavailable"})
+		return
+	}
+
+	opts := ingest.FileSummaryOptions{Force: req.Force}
+	if async(c) {
+		s.startJob(c, "summary", projectID, func(ctx context.Context, run *jobs.Run) (any, error) {
+			progress := func(p ingest.FileSummaryProgress) {
+				run.Progress("files", p.Done, p.Total)
+				if p.Error != "" {
+					run.Logf("Batch failed: %s", p.Error)
+				} else if len(p.Written) > 0 {
+					run.Logf("Summarized %d files", len(p.Written))
+				}
+			}
+			return s.graphService.SummarizePackage(ctx, projectID, req.Path, summarizer, opts, progress)
+		})
+		return
+	}
+
+	enc := json.NewEncoder(c.Writer)
+	streaming := false
+	progress := func(p ingest.FileSummaryProgress) {
+		if !streaming {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+			streaming = true
+		}
+		if err := enc.Encode(gin.H{"progress": p}); err == nil {
+			c.Writer.Flush()
+		}
+	}
+	report, err := s.graphService.SummarizePackage(c.Request.Context(), projectID, req.Path, summarizer, opts, progress)
+	if err != nil {
+		logger.Warn("Batch summary failed", "project", projectID, "path", req.Path, "error", err)
+		if !streaming {
+			handleError(c, err)
+			return
+		}
+		enc.Encode(gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	if !streaming {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+	}
+	enc.Encode(gin.H{"report": report})
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+// handleSaveView saves a named graph view so it can be bookmarked and shared.
+// Query parameters:
+//   - project: project ID
+//
+// Request body: {"name": "...", "query": "<datalog query>", "filters": {...}, "graph": {...}}
+// The graph is the one the client rendered; when it is omitted the query is run
+// to produce it. Filters record the parameters the graph was produced with.
+//
+// Response: 201 with the saved view, including its ID.
+func (s *Server) handleSaveView(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	var req struct {
+		Name    string            `json:"name"`
+		Query   string            `json:"query"`
+		Filters map[string]string `json:"filters"`
+		Graph   *export.D3Graph   `json:"graph"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+	if err := ValidateViewName(req.Name); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if req.Query != "" {
+		query, err := ValidateAndSanitizeQuery(req.Query)
+		if err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+		req.Query = query
+	}
+
+	graph := req.Graph
+	if graph == nil {
+		if req.Query == "" {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, "graph or query is required", nil))
+			return
+		}
+		exported, err := s.graphService.ExportGraph(c.Request.Context(), projectID, req.Query, true, false)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		graph = s.graphService.LimitGraph(exported, service.GraphLimits{})
+	}
+
+	view, err := s.graphService.SaveView(c.Request.Context(), projectID, &service.GraphView{
+		Name:    req.Name,
+		Query:   req.Query,
+		Filters: req.Filters,
+		Graph:   graph,
+	})
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, view)
+}
+
+// handleGetView returns a saved graph view.
+// Query parameters:
+//   - project: project ID the view was saved in
+//
+// Response: the view with its name, query, filters and graph.
+func (s *Server) handleGetView(c *gin.Context) {
+	projectID := c.Query("project")
+	viewID := c.Param("id")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	if err := ValidateViewID(viewID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+
+	view, err := s.graphService.GetView(c.Request.Context(), projectID, viewID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	"github.com/duynguyendang/gca/pkg/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// NotifyTo makes the server publish its graph changes to d's subscriptions,
+// check d's rules after each one, and manage the subscriptions at
+// /api/v1/webhooks. Call it before serving.
+func (s *Server) NotifyTo(d *webhook.Dispatcher) {
+	s.webhooks = d
+}
+
+// notifySync pushes the graph updates of a finished watch sync or scheduled
+// ingest to live graph clients, publishes its events and checks the project's
+// rules against its new graph.
+func (s *Server) notifySync(r ingest.SyncReport) {
+	if r.Err == nil {
+		s.publishGraphUpdates(r.Project)
+	}
+	if s.webhooks == nil {
+		return
+	}
+	data := webhook.IngestCompleted{
+		Trigger:    r.Trigger,
+		Files:      r.Files,
+		Facts:      r.Facts,
+		DurationMS: r.Duration.Milliseconds(),
+	}
+	if r.Err != nil {
+		data.Error = r.Err.Error()
+		s.webhooks.Publish(webhook.Event{Type: webhook.EventIngestFailed, Project: r.Project, Data: data})
+		return
+	}
+	s.webhooks.Publish(webhook.Event{Type: webhook.EventIngestCompleted, Project: r.Project, Data: data})
+	if r.VectorsAfter != r.VectorsBefore {
+		s.webhooks.Publish(webhook.Event{Type: webhook.EventVectorsRebuilt, Project: r.Project,
+			Data: webhook.VectorsRebuilt{Before: r.VectorsBefore, After: r.VectorsAfter}})
+	}
+	s.checkRules(r.Project)
+}
+
+// notifyImport pushes a fact import to live graph clients, publishes it and
+// checks the project's rules in the background, so that the import's response
+// does not wait for them.
+func (s *Server) notifyImport(report *ingest.ImportReport) {
+	go s.publishGraphUpdates(report.Project)
+	if s.webhooks == nil {
+		return
+	}
+	s.webhooks.Publish(webhook.Event{Type: webhook.EventFactsImported, Project: report.Project, Data: report})
+	go s.checkRules(report.Project)
+}
+
+// checkRules runs the project's rules, each under the query timeout.
+func (s *Server) checkRules(project string) {
+	s.webhooks.CheckRules(context.Background(), project, func(ctx context.Context, query string) ([]map[string]any, error) {
+		ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+		defer cancel()
+		return s.graphService.ExecuteQuery(ctx, project, query)
+	})
+}
+
+// handleListWebhooks returns the webhook subscriptions, without their
+// secrets, and the rules checked after each graph change.
+// Response: {"webhooks": [...], "rules": [...], "events": [<event types>]}
+func (s *Server) handleListWebhooks(c *gin.Context) {
+	if s.webhooks == nil {
+		handleError(c, errors.NewAppError(http.StatusNotFound, "Webhooks are not enabled", nil))
+		return
+	}
+	reg := s.webhooks.Registry()
+	subs := reg.Subscriptions()
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": subs, "rules": reg.AllRules(), "events": webhook.EventTypes})
+}
+
+// handleAddWebhook subscribes a URL to graph change events.
+//
+// Request body: {"url": "https://...", "events": ["ingest.completed", ...],
+// "projects": ["..."], "secret": "..."}
+// Events and projects default to all. With a secret, deliveries carry an
+// X-GCA-Signature header: "sha256=" and the HMAC-SHA256 of the body.
+//
+// Response: 201 with the subscription, including its ID but not its secret.
+func (s *Server) handleAddWebhook(c *gin.Context) {
+	if s.webhooks == nil {
+		handleError(c, errors.NewAppError(http.StatusNotFound, "Webhooks are not enabled", nil))
+		return
+	}
+	var req struct {
+		URL      string   `json:"url"`
+		Events   []string `json:"events"`
+		Projects []string `json:"projects"`
+		Secret   string   `json:"secret"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, "Invalid request body", err))
+		return
+	}
+	for _, p := range req.Projects {
+		if err := ValidateProjectID(p); err != nil {
+			handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+			return
+		}
+	}
+	sub, err := s.webhooks.Registry().Add(webhook.Subscription{
+		URL:      req.URL,
+		Events:   req.Events,
+		Projects: req.Projects,
+		Secret:   req.Secret,
+	})
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	sub.Secret = ""
+	c.JSON(http.StatusCreated, sub)
+}
+
+// handleDeleteWebhook removes a webhook subscription.
+// Response: 204, or 404 when there is no such subscription.
+func (s *Server) handleDeleteWebhook(c *gin.Context) {
+	if s.webhooks == nil {
+		handleError(c, errors.NewAppError(http.StatusNotFound, "Webhooks are not enabled", nil))
+		return
+	}
+	if err := s.webhooks.Registry().Remove(c.Param("id")); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/webhook"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleWebhooks(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(dataDir, "projA")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetTopicID(gcamdb.TopicForProject("projA"))
+	db.Close()
+
+	events := make(chan webhook.Event, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev webhook.Event
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Error(err)
+		}
+		events <- ev
+	}))
+	defer hook.Close()
+
+	mgr := manager.NewStoreManager(dataDir, manager.MemoryProfileLow, false)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, "")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/v1/webhooks", "").Code, "webhooks are off by default")
+
+	reg, err := webhook.OpenRegistry(filepath.Join(t.TempDir(), "webhooks.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := webhook.NewDispatcher(reg)
+	defer d.Close(context.Background())
+	s.NotifyTo(d)
+
+	w := do("POST", "/api/v1/webhooks", `{"url": "`+hook.URL+`", "events": ["facts.imported"], "secret": "s3cret"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add = %d: %s", w.Code, w.Body.String())
+	}
+	var sub webhook.Subscription
+	if err := json.Unmarshal(w.Body.Bytes(), &sub); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, sub.ID)
+	assert.Empty(t, sub.Secret)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/v1/webhooks", `{"url": "not a url"}`).Code)
+
+	w = do("GET", "/api/v1/webhooks", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+	assert.Contains(t, w.Body.String(), sub.ID)
+
+	w = do("POST", "/api/v1/facts/import?project=projA", `{"subject": "a.go", "predicate": "linted_by", "object": "vet"}`+"\n")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("import = %d: %s", w.Code, w.Body.String())
+	}
+	ev := <-events
+	assert.Equal(t, webhook.EventFactsImported, ev.Type)
+	assert.Equal(t, "projA", ev.Project)
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/api/v1/webhooks/"+sub.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/api/v1/webhooks/"+sub.ID, "").Code)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/common/deadline"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Classes of expensive graph operations, each limited on its own.
+const (
+	// OpBackbone rolls up every call of a project: the backbone, file backbone,
+	// package and map graphs.
+	OpBackbone = "backbone"
+	// OpCluster detects clusters or communities in a whole graph.
+	OpCluster = "cluster"
+)
+
+// ErrOperationQueueFull is returned when too many requests already wait for a slot.
+var ErrOperationQueueFull = stderrors.New("too many requests waiting for a slot")
+
+// OperationLimiter lets at most a fixed number of operations of a class run at
+// once. Further requests wait for a slot, up to config.OperationMaxQueued of
+// them; the rest are turned away.
+type OperationLimiter struct {
+	slots chan struct{}
+
+	mu       sync.Mutex
+	queued   int
+	admitted int64
+	waited   int64
+	rejected int64
+}
+
+// OperationStats reports an OperationLimiter's state and history.
+type OperationStats struct {
+	Limit    int   `json:"limit"`
+	Running  int   `json:"running"`
+	Queued   int   `json:"queued"`
+	Admitted int64 `json:"admitted"`
+	Waited   int64 `json:"waited"` // Admitted after waiting for a slot
+	Rejected int64 `json:"rejected"`
+}
+
+// NewOperationLimiter returns a limiter running at most limit operations at once.
+func NewOperationLimiter(limit int) *OperationLimiter {
+	return &OperationLimiter{slots: make(chan struct{}, max(limit, 1))}
+}
+
+// TryAcquire takes a slot if one is free. Call release when the operation is done.
+func (l *OperationLimiter) TryAcquire() (release func(), ok bool) {
+	select {
+	case l.slots <- struct{}{}:
+		l.mu.Lock()
+		l.admitted++
+		l.mu.Unlock()
+		return l.releaser(), true
+	default:
+		return nil, false
+	}
+}
+
+// Acquire takes a slot, waiting for one while ctx allows. Call release when the
+// operation is done.
+func (l *OperationLimiter) Acquire(ctx context.Context) (release func(), err error) {
+	if release, ok := l.TryAcquire(); ok {
+		return release, nil
+	}
+	l.mu.Lock()
+	if l.queued >= config.OperationMaxQueued {
+		l.rejected++
+		l.mu.Unlock()
+		return nil, ErrOperationQueueFull
+	}
+	l.queued++
+	l.mu.Unlock()
+
+	select {
+	case l.slots <- struct{}{}:
+		l.mu.Lock()
+		l.queued--
+		l.admitted++
+		l.waited++
+		l.mu.Unlock()
+		return l.releaser(), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.queued--
+		l.rejected++
+		l.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (l *OperationLimiter) releaser() func() {
+	var once sync.Once
+	return func() { once.Do(func() { <-l.slots }) }
+}
+
+// Stats returns the limiter's current state.
+func (l *OperationLimiter) Stats() OperationStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return OperationStats{
+		Limit:    cap(l.slots),
+		Running:  len(l.slots),
+		Queued:   l.queued,
+		Admitted: l.admitted,
+		Waited:   l.waited,
+		Rejected: l.rejected,
+	}
+}
+
+// DefaultOperationLimits returns the concurrency limit of each operation class.
+func DefaultOperationLimits() map[string]int {
+	return map[string]int{
+		OpBackbone: config.OperationLimitBackbone,
+		OpCluster:  config.OperationLimitCluster,
+	}
+}
+
+// ParseOperationLimits overrides base with spec, a comma-separated list of
+// class=limit pairs such as "backbone=2,cluster=1". A limit of 0 leaves the
+// class unlimited.
+func ParseOperationLimits(spec string, base map[string]int) (map[string]int, error) {
+	limits := make(map[string]int, len(base))
+	for class, n := range base {
+		limits[class] = n
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		class, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return base, fmt.Errorf("operation limit %q: want class=limit", pair)
+		}
+		class = strings.TrimSpace(class)
+		if _, known := base[class]; !known {
+			return base, fmt.Errorf("operation limit %q: unknown class %q", pair, class)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return base, fmt.Errorf("operation limit %q: invalid limit", pair)
+		}
+		limits[class] = n
+	}
+	return limits, nil
+}
+
+// newOperationLimiters returns a limiter for each class with a limit.
+func newOperationLimiters(limits map[string]int) map[string]*OperationLimiter {
+	limiters := make(map[string]*OperationLimiter, len(limits))
+	for class, n := range limits {
+		if n > 0 {
+			limiters[class] = NewOperationLimiter(n)
+		}
+	}
+	return limiters
+}
+
+// limit takes a slot of class for the request being served. When none frees up
+// the request is answered and ok is false; otherwise call release when the
+// operation is done.
+func (s *Server) limit(c *gin.Context, class string) (release func(), ok bool) {
+	limiter := s.operations[class]
+	if limiter == nil {
+		return func() {}, true
+	}
+	ctx := c.Request.Context()
+	deadline.Enter(ctx, "waiting for a "+class+" slot")
+	queueCtx, cancel := context.WithTimeout(ctx, config.OperationQueueTimeout)
+	defer cancel()
+	release, err := limiter.Acquire(queueCtx)
+	if err == nil {
+		return release, true
+	}
+	if deadlineExceeded(c, ctx.Err()) {
+		return nil, false
+	}
+	c.Header("Retry-After", strconv.Itoa(config.OperationRetryAfterSec))
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":       fmt.Sprintf("Too many %s operations are running. Please try again later.", class),
+		"retry_after": config.OperationRetryAfterSec,
+	})
+	return nil, false
+}
+
+// handleOperations reports the state of each limited operation class.
+func (s *Server) handleOperations(c *gin.Context) {
+	stats := make(map[string]OperationStats, len(s.operations))
+	for class, limiter := range s.operations {
+		stats[class] = limiter.Stats()
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// tryLimit takes a slot of class only if one is free, for optional work such
+// as auto-clustering that the request can do without.
+func (s *Server) tryLimit(class string) (release func(), ok bool) {
+	limiter := s.operations[class]
+	if limiter == nil {
+		return func() {}, true
+	}
+	return limiter.TryAcquire()
+}
+
+// waitLimit waits for a slot of class for as long as ctx allows, for work run
+// in a background job rather than a request.
+func (s *Server) waitLimit(ctx context.Context, class string) (release func(), err error) {
+	limiter := s.operations[class]
+	if limiter == nil {
+		return func() {}, nil
+	}
+	return limiter.Acquire(ctx)
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationLimiter(t *testing.T) {
+	l := NewOperationLimiter(1)
+	ctx := context.Background()
+
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := l.TryAcquire()
+	assert.False(t, ok, "second slot taken")
+
+	// A request waits for the slot, until its deadline
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	admitted := make(chan func())
+	go func() {
+		release, err := l.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		admitted <- release
+	}()
+	assert.Eventually(t, func() bool { return l.Stats().Queued == 1 }, time.Second, time.Millisecond)
+	release()
+	release() // Releasing twice is harmless
+	(<-admitted)()
+
+	stats := l.Stats()
+	assert.Equal(t, 1, stats.Limit)
+	assert.Equal(t, 0, stats.Running)
+	assert.Equal(t, int64(2), stats.Admitted)
+	assert.Equal(t, int64(1), stats.Waited)
+	assert.Equal(t, int64(1), stats.Rejected)
+
+	// The queue is bounded
+	release, _ = l.TryAcquire()
+	defer release()
+	waiting, stop := context.WithCancel(ctx)
+	for range config.OperationMaxQueued {
+		go l.Acquire(waiting)
+	}
+	assert.Eventually(t, func() bool { return l.Stats().Queued == config.OperationMaxQueued }, time.Second, time.Millisecond)
+	_, err = l.Acquire(ctx)
+	assert.True(t, errors.Is(err, ErrOperationQueueFull), "got %v", err)
+	stop()
+}
+
+func TestParseOperationLimits(t *testing.T) {
+	limits, err := ParseOperationLimits("backbone=1, cluster=0", DefaultOperationLimits())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]int{OpBackbone: 1, OpCluster: 0}, limits)
+	// Unlimited classes get no limiter
+	limiters := newOperationLimiters(limits)
+	assert.Contains(t, limiters, OpBackbone)
+	assert.NotContains(t, limiters, OpCluster)
+
+	for _, spec := range []string{"backbone", "backbone=-1", "backbone=many", "search=2"} {
+		_, err := ParseOperationLimits(spec, DefaultOperationLimits())
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestOperationLimitResponse(t *testing.T) {
+	mgr := manager.NewStoreManager(t.TempDir(), manager.MemoryProfileDefault, true)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, "")
+
+	// With the only slot taken and no room to queue, requests are turned away
+	s.operations[OpBackbone] = NewOperationLimiter(1)
+	release, _ := s.operations[OpBackbone].TryAcquire()
+	defer release()
+	s.operations[OpBackbone].queued = config.OperationMaxQueued
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/graph/backbone?project=p", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/operations", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"backbone":{"limit":1,"running":1`)
+}
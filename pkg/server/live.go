@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// liveGraph fans the graph updates of each project out to its WebSocket
+// clients. It remembers the last version it published per project, so that
+// every update goes out once however many syncs report it.
+type liveGraph struct {
+	mu        sync.Mutex
+	clients   map[string]map[chan service.GraphUpdate]bool // by project
+	published map[string]int                               // last version published, by project
+}
+
+func newLiveGraph() *liveGraph {
+	return &liveGraph{
+		clients:   make(map[string]map[chan service.GraphUpdate]bool),
+		published: make(map[string]int),
+	}
+}
+
+// subscribe registers a client of project's updates after version.
+func (l *liveGraph) subscribe(project string, version int) chan service.GraphUpdate {
+	ch := make(chan service.GraphUpdate, config.LiveGraphBuffer)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.clients[project]) == 0 {
+		l.clients[project] = make(map[chan service.GraphUpdate]bool)
+		l.published[project] = version
+	}
+	l.clients[project][ch] = true
+	return ch
+}
+
+// unsubscribe removes a client; its channel is closed unless publish already
+// dropped it.
+func (l *liveGraph) unsubscribe(project string, ch chan service.GraphUpdate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.clients[project][ch] {
+		return
+	}
+	delete(l.clients[project], ch)
+	close(ch)
+	if len(l.clients[project]) == 0 {
+		delete(l.clients, project)
+		delete(l.published, project)
+	}
+}
+
+// publish sends project's updates since the last published version to its
+// clients. A client whose queue is full is dropped, closing its channel, and
+// has to reconnect and fetch the graph again.
+func (l *liveGraph) publish(project string, updates func(since int) ([]service.GraphUpdate, error)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.clients[project]) == 0 {
+		return
+	}
+	list, err := updates(l.published[project])
+	if err != nil {
+		logger.Warn("Live graph updates failed", "project", project, "error", err)
+		return
+	}
+	for _, u := range list {
+		for ch := range l.clients[project] {
+			select {
+			case ch <- u:
+			default:
+				logger.Warn("Live graph client fell behind, disconnecting", "project", project)
+				delete(l.clients[project], ch)
+				close(ch)
+			}
+		}
+		l.published[project] = u.Version
+	}
+}
+
+// publishGraphUpdates pushes the project's new graph updates to its live graph
+// clients.
+func (s *Server) publishGraphUpdates(projectID string) {
+	s.live.publish(projectID, func(since int) ([]service.GraphUpdate, error) {
+		return s.graphService.GraphUpdates(context.Background(), projectID, since)
+	})
+}
+
+// handleLiveGraph upgrades to a WebSocket that pushes the project's graph
+// updates as watch syncs, scheduled ingests and fact imports change it, so a
+// view can update in place instead of fetching the graph again.
+// GET /api/v1/ws/graph?project=X
+//
+// Each message is a service.GraphUpdate in JSON:
+//
+//	{"project": "p", "version": 7, "added_nodes": [...], "removed_nodes": ["a.go:F"],
+//	 "added_links": [...], "removed_links": [...]}
+//
+// A message with "reset": true follows a full ingest, whose changes are not
+// tracked; the client should fetch the graph again. The server closes the
+// connection of a client too slow to keep up, which should do the same after
+// reconnecting. Messages from the client are ignored.
+func (s *Server) handleLiveGraph(c *gin.Context) {
+	projectID := c.Query("project")
+	if err := ValidateProjectID(projectID); err != nil {
+		handleError(c, errors.NewAppError(http.StatusBadRequest, err.Error(), err))
+		return
+	}
+	version, err := s.graphService.LatestVersion(c.Request.Context(), projectID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		// Cross-origin clients are allowed when CORSMiddleware allowed their origin
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || origin == "http://"+r.Host || origin == "https://"+r.Host ||
+				c.Writer.Header().Get("Access-Control-Allow-Origin") == origin
+		},
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // The upgrader has responded
+	}
+	defer conn.Close()
+
+	updates := s.live.subscribe(projectID, version)
+	defer s.live.unsubscribe(projectID, updates)
+
+	// Read until the client goes away, answering its pings and closes
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(config.LiveGraphPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case u, ok := <-updates:
+			conn.SetWriteDeadline(time.Now().Add(config.LiveGraphWriteTimeout))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow, fetch the graph again"))
+				return
+			}
+			if err := conn.WriteJSON(u); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(config.LiveGraphWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/service"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/gorilla/websocket"
+)
+
+func TestLiveGraph(t *testing.T) {
+	tmpDir := t.TempDir()
+	pDir := filepath.Join(tmpDir, "projA")
+	if err := os.Mkdir(pDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := meb.NewMEBStore(store.DefaultConfig(pDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	mgr := manager.NewStoreManager(tmpDir, manager.MemoryProfileDefault, false)
+	defer mgr.CloseAll()
+	srv := httptest.NewServer(NewServer(mgr, tmpDir).router)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/ws/graph?project=projA", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	body := `{"subject": "a.go:F", "predicate": "calls", "object": "b.go:G"}`
+	resp, err := http.Post(srv.URL+"/api/v1/facts/import?project=projA", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("import got %d", resp.StatusCode)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var u service.GraphUpdate
+	if err := conn.ReadJSON(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Project != "projA" || u.Version != 1 {
+		t.Errorf("got update of %s version %d, want projA version 1", u.Project, u.Version)
+	}
+	if len(u.AddedLinks) != 1 || u.AddedLinks[0].Source != "a.go:F" || u.AddedLinks[0].Target != "b.go:G" {
+		t.Errorf("added links = %+v, want a.go:F calls b.go:G", u.AddedLinks)
+	}
+	if len(u.AddedNodes) != 2 {
+		t.Errorf("added %d nodes, want 2", len(u.AddedNodes))
+	}
+
+	resp, err = http.Get(srv.URL + "/api/v1/ws/graph?project=missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown project got %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestLiveGraphDropsSlowClients(t *testing.T) {
+	l := newLiveGraph()
+	ch := l.subscribe("p", 3)
+	version := 3
+	l.publish("p", func(since int) ([]service.GraphUpdate, error) {
+		if since != 3 {
+			t.Errorf("since = %d, want 3", since)
+		}
+		var list []service.GraphUpdate
+		for range config.LiveGraphBuffer + 1 {
+			version++
+			list = append(list, service.GraphUpdate{Project: "p", Version: version})
+		}
+		return list, nil
+	})
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != config.LiveGraphBuffer {
+		t.Errorf("client got %d updates before being dropped, want %d", n, config.LiveGraphBuffer)
+	}
+	l.unsubscribe("p", ch) // Already dropped; must not close it again
+}
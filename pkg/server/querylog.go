@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/gca/pkg/meb/bench"
+)
+
+// RecordQueries makes the server append a sample of the Datalog, search and
+// path queries it serves to rec, for replay by the stress command. Call it
+// before serving.
+func (s *Server) RecordQueries(rec *bench.Recorder) {
+	s.queryLog = rec
+}
+
+// recordQuery completes a sampled query's record with how it was served and
+// writes it to the query log.
+func (s *Server) recordQuery(rec bench.QueryRecord, began time.Time, err error) {
+	rec.LatencyUS = time.Since(began).Microseconds()
+	rec.Error = err != nil
+	if err := s.queryLog.Record(rec); err != nil {
+		logger.Warn("Query log write failed", "error", err)
+	}
+}
+
+// embeddingRecorder keeps the query vector of a semantic search, the form in
+// which vector searches are recorded.
+type embeddingRecorder struct {
+	embedder interface {
+		GetEmbedding(ctx context.Context, text string) ([]float32, error)
+	}
+	vector []float32
+}
+
+func (e *embeddingRecorder) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vec, err := e.embedder.GetEmbedding(ctx, text)
+	e.vector = vec
+	return vec, err
+}
@@ -6,14 +6,21 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/duynguyendang/gca/internal/manager"
 	"github.com/duynguyendang/gca/pkg/agent"
 	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	"github.com/duynguyendang/gca/pkg/jobs"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/gca/pkg/meb/bench"
 	"github.com/duynguyendang/gca/pkg/registry"
 	"github.com/duynguyendang/gca/pkg/service"
 	"github.com/duynguyendang/gca/pkg/service/ai"
+	"github.com/duynguyendang/gca/pkg/webhook"
 	manglesdk "github.com/duynguyendang/manglekit/sdk"
 	"github.com/gin-gonic/gin"
 )
@@ -50,10 +57,14 @@ func DefaultCORSConfig() CORSConfig {
 			"Authorization",
 			"X-Requested-With",
 			"X-Request-ID",
+			"X-API-Key",
+			"X-Tenant-ID",
 		},
 		ExposeHeaders: []string{
 			"Content-Length",
 			"X-Request-ID",
+			"X-Next-Cursor",
+			"ETag",
 		},
 		AllowCredentials: true,
 		MaxAge:           86400, // 24 hours
@@ -69,14 +80,29 @@ type Server struct {
 	queryService *registry.QueryService
 	sourceDir    string
 	router       *gin.Engine
+	watchers     map[string]*ingest.Watcher // by project; set before serving
+	scheduler    *ingest.Scheduler          // nil unless ingests are scheduled
+	queryLog     *bench.Recorder            // nil unless recording queries
+	auditLog     *gcamdb.AuditLog           // nil unless auditing
+	webhooks     *webhook.Dispatcher        // nil unless notifying webhooks
+	admission    *Admission
+	operations   map[string]*OperationLimiter // by operation class; classes without a limit are absent
+	live         *liveGraph
+	jobs         *jobs.Manager
+	summarizer   ingest.Summarizer // nil until the first batch summary; see fileSummarizer
+	summarizerMu sync.Mutex
 }
 
 // NewServer creates a new Server instance.
 func NewServer(mgr *manager.StoreManager, sourceDir string) *Server {
 	r := gin.Default()
+	// Match routes on the escaped path, so a symbol ID in a path segment may
+	// hold slashes as %2F
+	r.UseRawPath = true
 	r.Use(RequestIDMiddleware())
 	r.Use(CORSMiddleware())
 	r.Use(RateLimitMiddleware())
+	r.Use(TimeoutMiddleware(routeTimeouts()))
 	r.Use(ValidationMiddleware())
 	r.Use(CompressionMiddleware())
 
@@ -120,11 +146,67 @@ func NewServer(mgr *manager.StoreManager, sourceDir string) *Server {
 		queryService: queryService,
 		sourceDir:    sourceDir,
 		router:       r,
+		admission:    NewAdmission(admissionBudget(mgr.Profile())),
+		operations:   newOperationLimiters(operationLimits()),
+		live:         newLiveGraph(),
+		jobs:         jobs.NewManager(),
 	}
 	s.setupRoutes()
 	return s
 }
 
+// routeTimeouts returns the default route timeouts, overridden by the
+// GCA_ROUTE_TIMEOUTS environment variable.
+func routeTimeouts() RouteTimeouts {
+	rt := DefaultRouteTimeouts()
+	spec := os.Getenv("GCA_ROUTE_TIMEOUTS")
+	if spec == "" {
+		return rt
+	}
+	parsed, err := ParseRouteTimeouts(spec, rt)
+	if err != nil {
+		logger.Warn("Ignoring GCA_ROUTE_TIMEOUTS", "error", err)
+		return rt
+	}
+	return parsed
+}
+
+// operationLimits returns the default operation limits, overridden by the
+// GCA_OPERATION_LIMITS environment variable.
+func operationLimits() map[string]int {
+	limits := DefaultOperationLimits()
+	spec := os.Getenv("GCA_OPERATION_LIMITS")
+	if spec == "" {
+		return limits
+	}
+	parsed, err := ParseOperationLimits(spec, limits)
+	if err != nil {
+		logger.Warn("Ignoring GCA_OPERATION_LIMITS", "error", err)
+		return limits
+	}
+	return parsed
+}
+
+// admissionBudget returns the memory heavy requests may take at once under
+// profile; 0, for no limit, except with the low memory profile.
+func admissionBudget(profile manager.MemoryProfile) int64 {
+	if profile == manager.MemoryProfileLow {
+		return config.AdmissionBudgetLow
+	}
+	return 0
+}
+
+// Watch registers a watcher keeping a project's graph in sync with its source
+// tree, so that the freshness endpoint reports it and its syncs are published
+// to the webhooks. Call it before serving.
+func (s *Server) Watch(w *ingest.Watcher) {
+	if s.watchers == nil {
+		s.watchers = make(map[string]*ingest.Watcher)
+	}
+	s.watchers[w.Project()] = w
+	w.OnSync(s.notifySync)
+}
+
 // Run starts the server on the specified address.
 func (s *Server) Run(addr string) error {
 	return s.router.Run(addr)
@@ -135,42 +217,156 @@ func (s *Server) Handler() http.Handler {
 	return s.router
 }
 
+// unheldRoutes are the routes whose requests, and the jobs they start, don't
+// hold the stores: the replica snapshot, which closes a store itself,
+// dictionary compaction, which holds them itself, and the live graph socket,
+// which stays open as long as its client and reads no store.
+var unheldRoutes = map[string]bool{
+	"/api/v1/replica/snapshot":         true,
+	"/api/v1/admin/compact-dictionary": true,
+	"/api/v1/ws/graph":                 true,
+}
+
+// holdStores holds the manager's stores for the rest of the request, so that
+// a snapshot waits for it rather than closing a store under it.
+func (s *Server) holdStores(c *gin.Context) {
+	if unheldRoutes[c.FullPath()] {
+		c.Next()
+		return
+	}
+	release := s.manager.Hold()
+	defer release()
+	c.Next()
+}
+
+// readOnlyError answers writes to a server whose stores were opened read-only.
+const readOnlyError = "The server opened its stores read-only; start it with --watch, --schedule or --tenants to write to them"
+
+// readOnly answers c with 503 and reports true when the manager opened its
+// stores read-only, as a server without --watch, --schedule or --tenants does,
+// so that writes to them would fail.
+func (s *Server) readOnly(c *gin.Context) bool {
+	if !s.manager.ReadOnly() {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": readOnlyError})
+	return true
+}
+
+// writable refuses a request to a route that writes to the stores when they
+// were opened read-only. Such routes are registered with it in setupRoutes.
+func (s *Server) writable(c *gin.Context) {
+	if !s.readOnly(c) {
+		c.Next()
+	}
+}
+
+// setupRoutes registers the API. Every request holds the stores, but for
+// unheldRoutes. Routes that write to the stores go through writable and
+// withinQuota.
 func (s *Server) setupRoutes() {
+	s.router.Use(s.holdStores)
 	s.router.GET("/api/health", s.healthCheck)
 	s.router.GET("/api/v1/projects", s.handleProjects)
-	s.router.GET("/api/v1/graph", s.handleGraph)
-	s.router.GET("/api/v1/graph/paginated", s.handleGraphPaginated) // Lazy loading support
-	s.router.GET("/api/v1/graph/manifest", s.handleGraphManifest)
-	s.router.GET("/api/v1/graph/map", s.handleGraphMap)
-	s.router.GET("/api/v1/graph/file-details", s.handleFileDetails)
-	s.router.GET("/api/v1/graph/file-calls", s.handleFileCalls)
-	s.router.GET("/api/v1/graph/backbone", s.handleGraphBackbone)
-	s.router.GET("/api/v1/graph/file-backbone", s.handleFileBackbone)
+	s.router.GET("/api/v1/projects/:id/freshness", s.handleFreshness)
+	s.router.GET("/api/v1/graph", s.conditional, s.handleGraph)
+	s.router.GET("/api/v1/graph/paginated", s.conditional, s.handleGraphPaginated) // Lazy loading support
+	s.router.GET("/api/v1/graph/manifest", s.conditional, s.handleGraphManifest)
+	s.router.GET("/api/v1/graph/map", s.conditional, s.handleGraphMap)
+	s.router.GET("/api/v1/graph/file-details", s.conditional, s.handleFileDetails)
+	s.router.GET("/api/v1/graph/file-calls", s.conditional, s.handleFileCalls)
+	s.router.GET("/api/v1/graph/backbone", s.conditional, s.handleGraphBackbone)
+	s.router.GET("/api/v1/graph/file-backbone", s.conditional, s.handleFileBackbone)
+	s.router.GET("/api/v1/graph/packages", s.conditional, s.handleGraphPackages)
+	s.router.GET("/api/v1/graph/entry-points", s.conditional, s.handleEntryPoints)
 	s.router.GET("/api/v1/hydrate", s.handleHydrate)
+	s.router.GET("/api/v1/owners", s.handleOwners)
 	s.router.POST("/api/v1/query", s.handleQuery)
+	s.router.POST("/api/v1/query/federated", s.handleFederatedQuery)
+	s.router.POST("/api/v1/query/explain-results", s.handleExplainResults)
 	s.router.GET("/api/v1/source", s.handleSource)
-	s.router.GET("/api/v1/summary", s.handleSummary)
+	s.router.GET("/api/v1/source/revisions", s.handleSourceRevisions)
+	s.router.GET("/api/v1/summary", s.conditional, s.handleSummary)
 	s.router.GET("/api/v1/predicates", s.handlePredicates)
 	s.router.GET("/api/v1/symbols", s.handleSymbols)
+	s.router.GET("/api/v1/symbols/top", s.conditional, s.handleTopSymbols)
+	s.router.GET("/api/v1/symbols/:id/overview", s.conditional, s.handleSymbolOverview)
 	s.router.GET("/api/v1/files", s.handleFiles)
 	s.router.GET("/api/v1/search/flow", s.handleFlowPath)
-	s.router.GET("/api/v1/graph/path", s.handleGraphPath)
-	s.router.GET("/api/v1/graph/cluster", s.handleGraphCluster)
+	s.router.GET("/api/v1/search/docs", s.handleDocSearch)
+	s.router.GET("/api/v1/graph/path", s.conditional, s.handleGraphPath)
+	s.router.GET("/api/v1/graph/cluster", s.conditional, s.handleGraphCluster)
 	s.router.GET("/api/v1/semantic-search", s.handleSemanticSearch)
-	s.router.GET("/api/v1/graph/communities", s.handleGraphCommunities)
+	s.router.GET("/api/v1/graph/communities", s.conditional, s.handleGraphCommunities)
 	s.router.POST("/api/v1/graph/hybrid-cluster", s.handleHybridCluster)
 	s.router.POST("/api/v1/graph/subgraph", s.handleGraphSubgraph)
+	s.router.GET("/api/v1/ws/graph", s.handleLiveGraph)
 
 	// Cross-Reference Analysis
-	s.router.GET("/api/v1/graph/who-calls", s.handleWhoCalls)
-	s.router.GET("/api/v1/graph/what-calls", s.handleWhatCalls)
-	s.router.GET("/api/v1/graph/reachable", s.handleCheckReachability)
-	s.router.GET("/api/v1/graph/cycles", s.handleDetectCycles)
-	s.router.GET("/api/v1/graph/lca", s.handleFindLCA)
-	s.router.POST("/api/v1/graph/enrich-called-by", s.handleEnrichCalledBy)
+	s.router.GET("/api/v1/graph/who-calls", s.conditional, s.handleWhoCalls)
+	s.router.GET("/api/v1/graph/what-calls", s.conditional, s.handleWhatCalls)
+	s.router.GET("/api/v1/graph/reachable", s.conditional, s.handleCheckReachability)
+	s.router.GET("/api/v1/graph/cycles", s.conditional, s.handleDetectCycles)
+	s.router.GET("/api/v1/graph/lca", s.conditional, s.handleFindLCA)
+	s.router.POST("/api/v1/graph/enrich-called-by", s.writable, s.withinQuota, s.handleEnrichCalledBy)
+
+	// Code intelligence for editor plugins (JSON-RPC 2.0)
+	s.router.POST("/api/v1/intel", s.handleIntel)
+
+	// Saved graph views
+	s.router.POST("/api/v1/views", s.writable, s.withinQuota, s.handleSaveView)
+	s.router.GET("/api/v1/views/:id", s.handleGetView)
+
+	// Saved Datalog queries
+	s.router.GET("/api/v1/queries", s.handleListSavedQueries)
+	s.router.POST("/api/v1/queries", s.writable, s.withinQuota, s.handleCreateSavedQuery)
+	s.router.GET("/api/v1/queries/:name", s.handleGetSavedQuery)
+	s.router.PUT("/api/v1/queries/:name", s.writable, s.withinQuota, s.handleUpdateSavedQuery)
+	s.router.DELETE("/api/v1/queries/:name", s.writable, s.handleDeleteSavedQuery)
+	s.router.POST("/api/v1/queries/:name/run", s.handleRunSavedQuery)
+
+	// Annotations
+	s.router.POST("/api/v1/annotations", s.writable, s.withinQuota, s.handleAddAnnotation)
+
+	// External facts
+	s.router.POST("/api/v1/facts/import", s.writable, s.withinQuota, s.handleImportFacts)
+	s.router.PUT("/api/v1/facts/weights", s.writable, s.withinQuota, s.handleUpdateFactWeights)
+
+	// Maintenance
+	s.router.POST("/api/v1/admin/compact-dictionary", s.handleCompactDictionary)
+	s.router.GET("/api/v1/admin/memory", s.handleMemory)
+	s.router.GET("/api/v1/admin/operations", s.handleOperations)
+	s.router.GET("/api/v1/admin/catalogs", s.handleCatalogs)
+	s.router.GET("/api/v1/admin/schedules", s.handleSchedules)
+	s.router.POST("/api/v1/admin/schedules/:project/run", s.withinQuota, s.handleRunSchedule)
+	s.router.GET("/api/v1/versions", s.handleVersions)
+
+	// Background jobs
+	s.router.GET("/api/v1/jobs", s.handleListJobs)
+	s.router.GET("/api/v1/jobs/:id", s.handleGetJob)
+	s.router.POST("/api/v1/jobs/:id/cancel", s.handleCancelJob)
+
+	// Webhook subscriptions
+	s.router.GET("/api/v1/webhooks", s.handleListWebhooks)
+	s.router.POST("/api/v1/webhooks", s.handleAddWebhook)
+	s.router.DELETE("/api/v1/webhooks/:id", s.handleDeleteWebhook)
+
+	// Query audit log
+	s.router.GET("/api/v1/audit", s.handleAudit)
+
+	// Read replicas
+	s.router.GET("/api/v1/replica/snapshot", s.handleSnapshot)
+
+	// Static Analysis
+	s.router.GET("/api/v1/analysis/vulnerabilities", s.handleVulnerabilities)
+	s.router.GET("/api/v1/analysis/duplicates", s.conditional, s.handleDuplicates)
+	s.router.GET("/api/v1/metrics/complexity", s.handleComplexity)
 
 	// AI Endpoints
 	s.router.POST("/api/v1/ai/ask", s.handleAIAsk)
+	s.router.GET("/api/v1/ai/module-summary", s.handleModuleSummary)
+	s.router.POST("/api/v1/ai/smart-search", s.handleSmartSearch)
+	s.router.POST("/api/v1/ai/batch-summary", s.writable, s.withinQuota, s.handleBatchSummary)
 
 	// Unified Ask Endpoint (NL -> Datalog -> Answer)
 	s.router.POST("/api/v1/ask", s.handleAsk)
@@ -219,15 +415,37 @@ func (s *Server) handleAIAsk(c *gin.Context) {
 		req.Query = SanitizeString(req.Query)
 	}
 
+	var err error
+	began := time.Now()
+	defer func() {
+		s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditAI, Project: req.ProjectID, Query: req.Query}, began, 0, err)
+	}()
+
+	if req.Task == "path_narrative" {
+		var narrative *ai.PathNarrative
+		narrative, err = s.aiService.NarratePath(c.Request.Context(), req)
+		if err != nil {
+			logger.Error("AI Path Narrative Error", "error", err)
+			if deadlineExceeded(c, err) {
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"answer": narrative.Summary, "narrative": narrative})
+		return
+	}
+
 	useOODA := os.Getenv("USE_OODA_LOOP") == "true"
 
 	var answer string
-	var err error
-
 	if useOODA {
 		answer, err = s.aiService.HandleRequestOODA(c.Request.Context(), req)
 		if err != nil {
 			logger.Error("AI OODA Error", "error", err)
+			if deadlineExceeded(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -235,6 +453,9 @@ func (s *Server) handleAIAsk(c *gin.Context) {
 		answer, err = s.aiService.HandleRequest(c.Request.Context(), req)
 		if err != nil {
 			logger.Error("AI Error", "error", err)
+			if deadlineExceeded(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -294,12 +515,26 @@ func (s *Server) handleAgentExecute(c *gin.Context) {
 		config.PredicateHasRole,
 		config.PredicateHasTag,
 		config.PredicateKind,
+		config.PredicateIsEntryPoint,
 	}
 
 	ctx := c.Request.Context()
-	session, err := orch.Run(ctx, req.ProjectID, req.Query, predicateNames)
+	var entryPoints []string
+	if entries, err := s.graphService.EntryPoints(ctx, req.ProjectID); err != nil {
+		logger.Warn("Agent entry points unavailable", "project", req.ProjectID, "error", err)
+	} else {
+		for _, e := range entries {
+			entryPoints = append(entryPoints, e.ID)
+		}
+	}
+	began := time.Now()
+	session, err := orch.Run(ctx, req.ProjectID, req.Query, predicateNames, entryPoints)
+	s.audit(c, gcamdb.AuditEntry{Kind: gcamdb.AuditAI, Project: req.ProjectID, Query: req.Query}, began, 0, err)
 	if err != nil {
 		logger.Error("Agent Execute failed", "error", err)
+		if deadlineExceeded(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
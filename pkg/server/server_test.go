@@ -109,3 +109,40 @@ func TestServer_MultiProject(t *testing.T) {
 		}
 	})
 }
+
+func TestServer_ReadOnlyWrites(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(tmpDir, "projA")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	mgr := manager.NewStoreManager(tmpDir, manager.MemoryProfileDefault, true)
+	defer mgr.CloseAll()
+	s := NewServer(mgr, tmpDir)
+
+	cases := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/v1/annotations?project=projA", `{"node": "a.go:F", "note": "hot"}`, http.StatusServiceUnavailable},
+		{"POST", "/api/v1/views?project=projA", `{"name": "v"}`, http.StatusServiceUnavailable},
+		{"DELETE", "/api/v1/queries/q?project=projA", "", http.StatusServiceUnavailable},
+		{"POST", "/api/v1/admin/compact-dictionary?project=projA&dry_run=false", "", http.StatusServiceUnavailable},
+		{"POST", "/api/v1/admin/compact-dictionary?project=projA", "", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s %s = %d, want %d: %s", tc.method, tc.path, w.Code, tc.want, w.Body.String())
+			continue
+		}
+		if tc.want == http.StatusServiceUnavailable && !strings.Contains(w.Body.String(), "read-only") {
+			t.Errorf("%s %s: %s; want the read-only error", tc.method, tc.path, w.Body.String())
+		}
+	}
+}
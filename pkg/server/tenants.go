@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/gin-gonic/gin"
+)
+
+// tenantServer is the Server of one tenant, on the tenant's own data root.
+type tenantServer struct {
+	keys    [][]byte
+	manager *manager.StoreManager
+	server  *Server
+	audit   *gcamdb.AuditLog
+}
+
+// TenantRouter serves several tenants from one listener. A request names its
+// tenant with a /t/<tenant> path prefix, which is stripped before the request
+// reaches the tenant's Server, or with the X-Tenant-ID header, and must carry
+// one of the tenant's API keys in X-API-Key or an "Authorization: Bearer"
+// header. Each tenant has its own StoreManager, so no request can reach
+// another tenant's projects.
+type TenantRouter struct {
+	tenants map[string]*tenantServer
+	router  *gin.Engine
+}
+
+// NewTenantRouter creates a Server for each tenant on its data root, held to
+// the tenant's quota.
+func NewTenantRouter(tenants []manager.Tenant, profile manager.MemoryProfile, readOnly bool) *TenantRouter {
+	// Logging and request IDs are left to the tenant's Server
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(CORSMiddleware())
+
+	tr := &TenantRouter{
+		tenants: make(map[string]*tenantServer, len(tenants)),
+		router:  r,
+	}
+	for _, t := range tenants {
+		mgr := manager.NewTenantStoreManager(t, profile, readOnly)
+		ts := &tenantServer{manager: mgr, server: NewServer(mgr, "")}
+		for _, key := range t.APIKeys {
+			ts.keys = append(ts.keys, []byte(key))
+		}
+		tr.tenants[t.ID] = ts
+		logger.Info("Tenant registered", "tenant", t.ID, "data_dir", t.DataDir)
+	}
+
+	r.GET("/api/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.NoRoute(tr.dispatch)
+	return tr
+}
+
+// Handler returns the HTTP handler serving every tenant.
+func (tr *TenantRouter) Handler() http.Handler {
+	return tr.router
+}
+
+// EnableAudit gives every tenant an audit log of its own, in its data root.
+// Call it before serving.
+func (tr *TenantRouter) EnableAudit() error {
+	for id, ts := range tr.tenants {
+		log, err := gcamdb.OpenAuditLog(filepath.Join(ts.manager.BaseDir(), config.AuditStoreDir), false)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", id, err)
+		}
+		ts.audit = log
+		ts.server.AuditTo(log)
+	}
+	return nil
+}
+
+// Close closes the stores and audit logs of every tenant.
+func (tr *TenantRouter) Close() {
+	for id, ts := range tr.tenants {
+		if err := ts.audit.Close(); err != nil {
+			logger.Warn("Closing tenant audit log failed", "tenant", id, "error", err)
+		}
+		ts.manager.CloseAll()
+	}
+}
+
+// Warmup warms up every tenant's stores, one tenant after the other, with
+// manager.StoreManager.Warmup. It returns when they are warm or ctx is done.
+func (tr *TenantRouter) Warmup(ctx context.Context) error {
+	for id, ts := range tr.tenants {
+		if err := ts.manager.Warmup(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warn("Tenant warmup failed", "tenant", id, "error", err)
+		}
+	}
+	return nil
+}
+
+// Maintain reconciles every tenant's stores in the background with
+// manager.StoreManager.Maintain, the tenants side by side. It returns ctx's
+// error once ctx is done.
+func (tr *TenantRouter) Maintain(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, ts := range tr.tenants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ts.manager.Maintain(ctx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Sweep deletes the expired facts of every tenant's stores in the background
+// with manager.StoreManager.Sweep, the tenants side by side. It returns ctx's
+// error once ctx is done.
+func (tr *TenantRouter) Sweep(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, ts := range tr.tenants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ts.manager.Sweep(ctx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// dispatch resolves and authenticates a request, then hands it to the tenant's
+// Server, whose routes that write to the stores check the tenant's quota.
+func (tr *TenantRouter) dispatch(c *gin.Context) {
+	id, path, err := tenantOf(c.Request)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ts, ok := tr.tenants[id]
+	// Unknown tenants and bad keys get the same answer, so tenant IDs can't be probed
+	if !ok || !ts.authorized(requestAPIKey(c.Request)) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	req := c.Request
+	if path != req.URL.Path {
+		// Keep escapes, such as a symbol ID's %2F, past the tenant prefix
+		prefix := req.URL.Path[:len(req.URL.Path)-len(path)]
+		raw, ok := strings.CutPrefix(req.URL.RawPath, prefix)
+		if !ok {
+			raw = ""
+		}
+		req = req.Clone(req.Context())
+		req.URL.Path = path
+		req.URL.RawPath = raw
+	}
+	ts.server.Handler().ServeHTTP(c.Writer, req)
+}
+
+// authorized reports whether key is one of the tenant's API keys.
+func (ts *tenantServer) authorized(key string) bool {
+	if key == "" {
+		return false
+	}
+	ok := 0
+	for _, k := range ts.keys {
+		ok |= subtle.ConstantTimeCompare(k, []byte(key))
+	}
+	return ok == 1
+}
+
+// tenantOf returns the tenant a request names and its path with any tenant
+// prefix stripped. A prefix and a header naming different tenants are an error.
+func tenantOf(r *http.Request) (id, path string, err error) {
+	path = r.URL.Path
+	header := r.Header.Get(config.TenantHeader)
+	if rest, ok := strings.CutPrefix(path, config.TenantPathPrefix); ok {
+		id, path, _ = strings.Cut(rest, "/")
+		path = "/" + path
+		if header != "" && header != id {
+			return "", "", stderrors.New("tenant header does not match the path")
+		}
+	} else {
+		id = header
+	}
+	if id == "" {
+		return "", "", stderrors.New("tenant is required: use the " + config.TenantPathPrefix + "<tenant> path prefix or the " + config.TenantHeader + " header")
+	}
+	return id, path, nil
+}
+
+// requestAPIKey returns the API key of a request, from X-API-Key or a bearer token.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// withinQuota refuses a request to a route that grows the stores once they
+// have used the manager's store size quota. Such routes are registered with it
+// in setupRoutes.
+func (s *Server) withinQuota(c *gin.Context) {
+	err := s.manager.CheckStoreQuota()
+	if err == nil {
+		c.Next()
+		return
+	}
+	if stderrors.Is(err, manager.ErrQuotaExceeded) {
+		logger.Warn("Store quota exceeded", "path", c.FullPath(), "error", err)
+		c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{"error": "Storage quota exceeded"})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+}
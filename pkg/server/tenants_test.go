@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestTenantRouter(t *testing.T) {
+	root := t.TempDir()
+	var tenants []manager.Tenant
+	for _, tc := range []struct{ id, project, key string }{
+		{"acme", "rockets", "acme-key"},
+		{"globex", "widgets", "globex-key"},
+	} {
+		dir := filepath.Join(root, tc.id)
+		db, err := meb.NewMEBStore(store.DefaultConfig(filepath.Join(dir, tc.project)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+		tenants = append(tenants, manager.Tenant{ID: tc.id, DataDir: dir, APIKeys: []string{tc.key}})
+	}
+	tenants[1].MaxStoreBytes = 1 // Already used up
+
+	tr := NewTenantRouter(tenants, manager.MemoryProfileLow, false)
+	defer tr.Close()
+
+	do := func(method, path string, header map[string]string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		tr.Handler().ServeHTTP(w, req)
+		return w
+	}
+	projects := func(w *httptest.ResponseRecorder) []string {
+		var list []manager.ProjectMetadata
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("projects body %q: %v", w.Body.String(), err)
+		}
+		var ids []string
+		for _, p := range list {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	if w := do("GET", "/api/health", nil, ""); w.Code != http.StatusOK {
+		t.Errorf("health = %d, want 200 without a tenant", w.Code)
+	}
+
+	w := do("GET", "/t/acme/api/v1/projects", map[string]string{"X-API-Key": "acme-key"}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("acme projects by prefix = %d: %s", w.Code, w.Body.String())
+	}
+	if ids := projects(w); len(ids) != 1 || ids[0] != "rockets" {
+		t.Errorf("acme projects = %v, want only rockets", ids)
+	}
+	w = do("GET", "/api/v1/projects", map[string]string{"X-Tenant-ID": "globex", "Authorization": "Bearer globex-key"}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("globex projects by header = %d: %s", w.Code, w.Body.String())
+	}
+	if ids := projects(w); len(ids) != 1 || ids[0] != "widgets" {
+		t.Errorf("globex projects = %v, want only widgets", ids)
+	}
+
+	for name, tc := range map[string]struct {
+		path   string
+		header map[string]string
+		code   int
+	}{
+		"no tenant":        {"/api/v1/projects", map[string]string{"X-API-Key": "acme-key"}, http.StatusBadRequest},
+		"no key":           {"/t/acme/api/v1/projects", nil, http.StatusUnauthorized},
+		"other tenant key": {"/t/acme/api/v1/projects", map[string]string{"X-API-Key": "globex-key"}, http.StatusUnauthorized},
+		"unknown tenant":   {"/t/initech/api/v1/projects", map[string]string{"X-API-Key": "acme-key"}, http.StatusUnauthorized},
+		"header mismatch":  {"/t/acme/api/v1/projects", map[string]string{"X-API-Key": "acme-key", "X-Tenant-ID": "globex"}, http.StatusBadRequest},
+		"other project":    {"/t/acme/api/v1/summary?project=widgets", map[string]string{"X-API-Key": "acme-key"}, http.StatusNotFound},
+	} {
+		if w := do("GET", tc.path, tc.header, ""); w.Code != tc.code {
+			t.Errorf("%s: %s = %d, want %d: %s", name, tc.path, w.Code, tc.code, w.Body.String())
+		}
+	}
+
+	w = do("POST", "/t/globex/api/v1/views?project=widgets", map[string]string{"X-API-Key": "globex-key", "Content-Type": "application/json"}, `{"name":"v","query":"triples(?s, \"calls\", ?o)"}`)
+	if w.Code != http.StatusInsufficientStorage {
+		t.Errorf("globex view over quota = %d, want 507: %s", w.Code, w.Body.String())
+	}
+	for _, route := range []struct{ method, path string }{
+		{"POST", "/t/globex/api/v1/queries?project=widgets"},
+		{"PUT", "/t/globex/api/v1/facts/weights?project=widgets"},
+		{"POST", "/t/globex/api/v1/ai/batch-summary?project=widgets"},
+		{"POST", "/t/globex/api/v1/admin/schedules/widgets/run"},
+	} {
+		w = do(route.method, route.path, map[string]string{"X-API-Key": "globex-key", "Content-Type": "application/json"}, `{}`)
+		if w.Code != http.StatusInsufficientStorage {
+			t.Errorf("globex %s %s over quota = %d, want 507: %s", route.method, route.path, w.Code, w.Body.String())
+		}
+	}
+	// Reads stay open over quota
+	w = do("POST", "/t/globex/api/v1/query?project=widgets", map[string]string{"X-API-Key": "globex-key", "Content-Type": "application/json"}, `{"query":"triples(?s, \"calls\", ?o)"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("globex query over quota = %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/deadline"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutKey holds the request's deadline in the gin context.
+const timeoutKey = "gca.timeout"
+
+// RouteTimeouts are the deadlines of requests by route. Routes are matched by
+// their path pattern, exactly or, for patterns ending in *, by prefix; the
+// longest match wins and requests matching none get Default. A timeout of 0
+// sets no deadline.
+type RouteTimeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// DefaultRouteTimeouts gives AI endpoints the longest deadlines, queries the
+// longest a caller may ask for with ?timeout=, and graph endpoints the default.
+// Snapshot downloads stream a whole store and have none.
+func DefaultRouteTimeouts() RouteTimeouts {
+	return RouteTimeouts{
+		Default: config.RouteTimeoutDefault,
+		Routes: map[string]time.Duration{
+			"/api/v1/query":                  config.RouteTimeoutQuery,
+			"/api/v1/query/federated":        config.RouteTimeoutQuery,
+			"/api/v1/query/explain-results":  config.RouteTimeoutAI,
+			"/api/v1/ai/*":                   config.RouteTimeoutAI,
+			"/api/v1/ai/batch-summary":       config.RouteTimeoutAdmin,
+			"/api/v1/ask":                    config.RouteTimeoutAI,
+			"/api/v1/agent/*":                config.RouteTimeoutAI,
+			"/api/v1/admin/*":                config.RouteTimeoutAdmin,
+			"/api/v1/facts/import":           config.RouteTimeoutAdmin,
+			"/api/v1/graph/enrich-called-by": config.RouteTimeoutAdmin,
+			"/api/v1/replica/snapshot":       0,
+			"/api/v1/ws/graph":               0,
+		},
+	}
+}
+
+// ParseRouteTimeouts overrides base with spec, a comma-separated list of
+// pattern=duration pairs such as "/api/v1/ask=5m,/api/v1/graph/*=10s". The
+// pattern "default" sets the default.
+func ParseRouteTimeouts(spec string, base RouteTimeouts) (RouteTimeouts, error) {
+	rt := RouteTimeouts{Default: base.Default, Routes: make(map[string]time.Duration, len(base.Routes))}
+	for pattern, d := range base.Routes {
+		rt.Routes[pattern] = d
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return base, fmt.Errorf("route timeout %q: want pattern=duration", pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 0 {
+			return base, fmt.Errorf("route timeout %q: invalid duration", pair)
+		}
+		if pattern = strings.TrimSpace(pattern); pattern == "default" {
+			rt.Default = d
+		} else {
+			rt.Routes[pattern] = d
+		}
+	}
+	return rt, nil
+}
+
+// For returns the deadline of requests to path.
+func (rt RouteTimeouts) For(path string) time.Duration {
+	if d, ok := rt.Routes[path]; ok {
+		return d
+	}
+	best, timeout := -1, rt.Default
+	for pattern, d := range rt.Routes {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(path, prefix) && len(prefix) > best {
+			best, timeout = len(prefix), d
+		}
+	}
+	return timeout
+}
+
+// TimeoutMiddleware gives each request its route's deadline and tracks the
+// stages it goes through, for deadlineExceeded to report.
+func TimeoutMiddleware(rt RouteTimeouts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		ctx := deadline.Track(c.Request.Context())
+		timeout := rt.For(path)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		c.Set(timeoutKey, timeout)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// deadlineExceeded answers a request that ran out of time, its own or that of
+// one of its stages, with 504 and the stage it was in, and reports whether it
+// did.
+func deadlineExceeded(c *gin.Context, err error) bool {
+	ctx := c.Request.Context()
+	if !stderrors.Is(err, context.DeadlineExceeded) && !stderrors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	stage := deadline.Stage(ctx)
+	if stage == "" {
+		stage = "request"
+	}
+	body := gin.H{
+		"error": fmt.Sprintf("deadline exceeded during %s", stage),
+		"stage": stage,
+	}
+	if timeout := c.GetDuration(timeoutKey); timeout > 0 {
+		body["timeout"] = timeout.String()
+	}
+	c.JSON(http.StatusGatewayTimeout, body)
+	return true
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/deadline"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteTimeouts(t *testing.T) {
+	rt := RouteTimeouts{
+		Default: 30 * time.Second,
+		Routes: map[string]time.Duration{
+			"/api/v1/ai/*":       3 * time.Minute,
+			"/api/v1/ai/explain": time.Minute,
+			"/api/v1/graph/*":    10 * time.Second,
+			"/api/v1/graph/path": 20 * time.Second,
+			"/api/v1/snapshot":   0,
+		},
+	}
+	assert.Equal(t, time.Minute, rt.For("/api/v1/ai/explain"))
+	assert.Equal(t, 3*time.Minute, rt.For("/api/v1/ai/narrate"))
+	assert.Equal(t, 20*time.Second, rt.For("/api/v1/graph/path"))
+	assert.Equal(t, 10*time.Second, rt.For("/api/v1/graph/map"))
+	assert.Equal(t, time.Duration(0), rt.For("/api/v1/snapshot"))
+	assert.Equal(t, 30*time.Second, rt.For("/api/v1/files"))
+
+	parsed, err := ParseRouteTimeouts(" default=1m, /api/v1/graph/*=5s,/api/v1/ask=10m", rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, time.Minute, parsed.For("/api/v1/files"))
+	assert.Equal(t, 5*time.Second, parsed.For("/api/v1/graph/map"))
+	assert.Equal(t, 10*time.Minute, parsed.For("/api/v1/ask"))
+	// The base is left as it was
+	assert.Equal(t, 10*time.Second, rt.For("/api/v1/graph/map"))
+
+	for _, spec := range []string{"/api/v1/ask", "/api/v1/ask=soon", "/api/v1/ask=-1s"} {
+		_, err := ParseRouteTimeouts(spec, rt)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TimeoutMiddleware(RouteTimeouts{
+		Default: time.Second,
+		Routes:  map[string]time.Duration{"/slow": 10 * time.Millisecond},
+	}))
+	r.GET("/slow", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		deadline.Enter(ctx, "hydration")
+		<-ctx.Done()
+		handleError(c, ctx.Err())
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"deadline": ok})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "hydration", body["stage"])
+	assert.Equal(t, "deadline exceeded during hydration", body["error"])
+	assert.Equal(t, "10ms", body["timeout"])
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"deadline":true}`, w.Body.String())
+}
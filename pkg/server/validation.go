@@ -1,11 +1,13 @@
 package server
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/gin-gonic/gin"
 )
 
@@ -281,6 +283,42 @@ func ValidateSymbolID(symbolID string) error {
 	return nil
 }
 
+// ValidateViewID validates a saved graph view ID
+func ValidateViewID(viewID string) error {
+	if viewID == "" {
+		return &ValidationError{Field: "view_id", Message: "is required"}
+	}
+	if _, err := hex.DecodeString(viewID); err != nil || len(viewID) != 16 {
+		return &ValidationError{Field: "view_id", Message: "must be 16 hex characters"}
+	}
+	return nil
+}
+
+// ValidateViewName validates the name a graph view is saved under
+func ValidateViewName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+	if len(name) > config.MaxViewNameLength {
+		return &ValidationError{Field: "name", Message: "exceeds maximum length"}
+	}
+	return nil
+}
+
+// ValidateQueryName validates the name a Datalog query is saved under
+func ValidateQueryName(name string) error {
+	if name == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+	if len(name) > config.MaxQueryNameLength {
+		return &ValidationError{Field: "name", Message: "exceeds maximum length"}
+	}
+	if err := gcamdb.ValidQueryName(name); err != nil {
+		return &ValidationError{Field: "name", Message: "must be letters, digits, '_', '.' or '-' and not one of " + strings.Join(gcamdb.ReservedQueryNames, ", ")}
+	}
+	return nil
+}
+
 // ValidateAndSanitizeQuery validates a Datalog query string.
 // It trims whitespace, checks length, and rejects dangerous content,
 // but does NOT HTML-escape the query — that would corrupt Datalog syntax.
@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/repl"
+)
+
+// ResultExplanation is the architectural reading of a query's results, with
+// follow-up queries to dig deeper.
+type ResultExplanation struct {
+	Explanation string              `json:"explanation"`
+	Suggestions []string            `json:"suggestions"`
+	Summary     *repl.ResultSummary `json:"summary"` // What the model was shown of the results
+}
+
+// ExplainResults asks the model what results of the Datalog query datalog
+// mean, as the REPL does after a natural language query. question is the
+// question the query answers, if any. Large results are summarized before
+// they reach the prompt.
+func (s *AIService) ExplainResults(ctx context.Context, question, datalog string, results []map[string]any) (*ResultExplanation, error) {
+	if s.ExplainPrompt == nil {
+		return nil, fmt.Errorf("explain_results.prompt not loaded")
+	}
+	summary := repl.SummarizeResults(results)
+	prompt, err := s.ExplainPrompt.Execute(repl.ExplainData(question, datalog, summary))
+	if err != nil {
+		return nil, err
+	}
+	answer, err := s.GenerateText(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	explanation := strings.TrimSpace(answer)
+	suggestions := []string{}
+	for _, line := range strings.Split(repl.ExtractSuggestedQueries(explanation), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			suggestions = append(suggestions, line)
+		}
+	}
+	return &ResultExplanation{Explanation: explanation, Suggestions: suggestions, Summary: summary}, nil
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/duynguyendang/gca/pkg/common/deadline"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/logger"
 	gcamdb "github.com/duynguyendang/gca/pkg/meb"
@@ -46,6 +47,8 @@ type AIService struct {
 	SmartSearchPrompt    *prompts.Prompt
 	MultiFilePrompt      *prompts.Prompt
 	DefaultContextPrompt *prompts.Prompt
+	ModuleSummaryPrompt  *prompts.Prompt
+	ExplainPrompt        *prompts.Prompt
 
 	// Response caching for AI synthesis
 	responseCache    map[string]*cachedResponse
@@ -161,13 +164,16 @@ func NewAIService(ctx context.Context, manager ProjectStoreManager) (*AIService,
 		SmartSearchPrompt:    loadPrompt("smart_search"),
 		MultiFilePrompt:      loadPrompt("multi_file"),
 		DefaultContextPrompt: loadPrompt("default_context"),
+		ModuleSummaryPrompt:  loadPrompt("module_summary"),
+		ExplainPrompt:        loadPrompt("explain"),
 		responseCache:        make(map[string]*cachedResponse),
 		responseCacheTTL:     cacheTTL,
 	}, nil
 }
 
 func (s *AIService) GenerateText(ctx context.Context, prompt string) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	deadline.Enter(ctx, "LLM generation")
+	ctx, cancel := context.WithTimeout(ctx, config.AIRequestTimeout)
 	defer cancel()
 
 	logger.Debug("Sending Prompt to LLM", "provider", s.provider, "prompt", prompt)
@@ -234,6 +240,7 @@ func (s *AIService) GetEmbedding(ctx context.Context, text string) ([]float32, e
 		return nil, fmt.Errorf("empty text for embedding")
 	}
 
+	deadline.Enter(ctx, "embedding")
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
@@ -273,6 +280,7 @@ func (s *AIService) HandleRequest(ctx context.Context, req AIRequest) (string, e
 		return "", fmt.Errorf("failed to get store: %w", err)
 	}
 
+	deadline.Enter(ctx, "prompt building")
 	prompt, err := s.buildTaskPrompt(ctx, store, req)
 	if err != nil {
 		return "", fmt.Errorf("failed to build prompt: %w", err)
@@ -390,6 +398,9 @@ func (s *AIService) buildDatalogPrompt(req AIRequest) (string, error) {
 }
 
 func (s *AIService) buildPathNarrativePrompt(ctx context.Context, store *meb.MEBStore, req AIRequest) (string, error) {
+	if prompt, _, err := s.pathNarrativePrompt(ctx, store, req); err == nil {
+		return prompt, nil
+	}
 	pathStr := extractPathString(req.Data)
 	if s.PathNarrativePrompt != nil {
 		promptStr, err := s.PathNarrativePrompt.Execute(map[string]interface{}{
@@ -815,7 +826,7 @@ func (s *AIService) appendSymbolContext(ctx context.Context, store *meb.MEBStore
 }
 
 func (s *AIService) getSymbolContent(store *meb.MEBStore, symbolID string) (string, error) {
-	contentBytes, err := store.GetContentByKey(string(symbolID))
+	contentBytes, err := gcamdb.GetDocument(store, string(symbolID))
 	if err != nil {
 		return "", err
 	}
@@ -935,6 +946,7 @@ func (s *AIService) HandleRequestOODA(ctx context.Context, req AIRequest) (strin
 	if task == "" {
 		task = ooda.TaskChat
 	}
+	deadline.Enter(ctx, "OODA loop")
 
 	return ooda.RunOODATask(ctx, loop, req.ProjectID, req.Query, task, req.SymbolID, req.Data)
 }
@@ -986,6 +998,7 @@ func (s *AIService) HandleAsk(ctx context.Context, req AskRequest) (*AskResponse
 		target = req.SymbolID
 	}
 
+	deadline.Enter(ctx, "datalog generation")
 	queryResult, err := GenerateDatalog(ctx, req.Query, intentResult.Intent, target, store)
 	if err != nil {
 		resp.Query = queryResult.Query
@@ -996,6 +1009,7 @@ func (s *AIService) HandleAsk(ctx context.Context, req AskRequest) (*AskResponse
 
 	resp.Query = queryResult.Query
 
+	deadline.Enter(ctx, "query execution")
 	pathTool := parsePathTool(resp.Query)
 	var results interface{}
 	if pathTool != nil {
@@ -1023,6 +1037,7 @@ func (s *AIService) HandleAsk(ctx context.Context, req AskRequest) (*AskResponse
 	}
 
 	// Call AI synthesis (the slow part)
+	deadline.Enter(ctx, "answer synthesis")
 	synthResult, err := SynthesizeAnswer(ctx, intentResult.Intent, req.Query, resp.Query, results, store)
 	if err == nil {
 		resp.Answer = synthResult.Answer
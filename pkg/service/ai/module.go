@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/service"
+)
+
+// ModuleSummary is a README-style summary of a module. Citations are file,
+// symbol or package IDs taken from the module's context.
+type ModuleSummary struct {
+	Path             string                 `json:"path"`
+	Purpose          string                 `json:"purpose"`
+	PurposeCitations []string               `json:"purpose_citations"`
+	KeyTypes         []ModuleSummaryItem    `json:"key_types"`
+	Dependencies     []ModuleSummaryItem    `json:"dependencies"`
+	Consumers        []ModuleSummaryItem    `json:"consumers"`
+	Context          *service.ModuleContext `json:"context"`
+}
+
+// ModuleSummaryItem is one entry of a module summary section.
+type ModuleSummaryItem struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Citations   []string `json:"citations"`
+}
+
+// SummarizeModule asks the model for a structured summary of the module
+// described by mc. Citations the context does not back are dropped.
+func (s *AIService) SummarizeModule(ctx context.Context, mc *service.ModuleContext) (*ModuleSummary, error) {
+	if s.ModuleSummaryPrompt == nil {
+		return nil, fmt.Errorf("module_summary.prompt not loaded")
+	}
+	prompt, err := s.ModuleSummaryPrompt.Execute(map[string]interface{}{
+		"Path":    mc.Path,
+		"Context": formatModuleContext(mc),
+	})
+	if err != nil {
+		return nil, err
+	}
+	answer, err := s.GenerateText(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := parseModuleSummary(answer)
+	if err != nil {
+		return nil, err
+	}
+	summary.Path = mc.Path
+	summary.Context = mc
+	filterModuleCitations(summary, mc)
+	return summary, nil
+}
+
+// formatModuleContext renders a module's context as the facts section of the prompt.
+func formatModuleContext(mc *service.ModuleContext) string {
+	var sb strings.Builder
+	sb.WriteString("## Files\n")
+	for _, f := range mc.Files {
+		fmt.Fprintf(&sb, "- %s\n", f)
+	}
+
+	sb.WriteString("\n## Exported Symbols\n")
+	if len(mc.Symbols) == 0 {
+		sb.WriteString("(none)\n")
+	}
+	for _, sym := range mc.Symbols {
+		fmt.Fprintf(&sb, "- %s", sym.ID)
+		if sym.Kind != "" {
+			fmt.Fprintf(&sb, " (%s)", sym.Kind)
+		}
+		if sym.Summary != "" {
+			fmt.Fprintf(&sb, ": %s", sym.Summary)
+		}
+		sb.WriteString("\n")
+	}
+
+	edges := func(title string, list []service.ModuleEdge) {
+		fmt.Fprintf(&sb, "\n## %s\n", title)
+		if len(list) == 0 {
+			sb.WriteString("(none)\n")
+		}
+		for _, e := range list {
+			scope := "external"
+			if e.Internal {
+				scope = "internal"
+			}
+			fmt.Fprintf(&sb, "- %s (%s, %d references)", e.Package, scope, e.Count)
+			if len(e.Symbols) > 0 {
+				fmt.Fprintf(&sb, " via %s", strings.Join(e.Symbols, ", "))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	edges("Dependencies (packages the module uses)", mc.Dependencies)
+	edges("Consumers (packages using the module)", mc.Consumers)
+
+	if mc.Truncated {
+		sb.WriteString("\nSome lists above were truncated.\n")
+	}
+	return sb.String()
+}
+
+// parseModuleSummary reads the JSON object of a model answer, ignoring
+// markdown fences and text around it.
+func parseModuleSummary(answer string) (*ModuleSummary, error) {
+	start := strings.Index(answer, "{")
+	end := strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("module summary is not JSON: %q", answer)
+	}
+	var summary ModuleSummary
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse module summary: %w", err)
+	}
+	for _, items := range []*[]ModuleSummaryItem{&summary.KeyTypes, &summary.Dependencies, &summary.Consumers} {
+		if *items == nil {
+			*items = []ModuleSummaryItem{}
+		}
+	}
+	return &summary, nil
+}
+
+// filterModuleCitations drops citations naming nothing in the module's context.
+func filterModuleCitations(summary *ModuleSummary, mc *service.ModuleContext) {
+	known := make(map[string]bool)
+	for _, f := range mc.Files {
+		known[f] = true
+	}
+	for _, sym := range mc.Symbols {
+		known[sym.ID] = true
+	}
+	for _, list := range [][]service.ModuleEdge{mc.Dependencies, mc.Consumers} {
+		for _, e := range list {
+			known[e.Package] = true
+			for _, id := range e.Symbols {
+				known[id] = true
+			}
+		}
+	}
+	filter := func(ids []string) []string {
+		kept := []string{}
+		for _, id := range ids {
+			if known[id] {
+				kept = append(kept, id)
+			}
+		}
+		return kept
+	}
+	summary.PurposeCitations = filter(summary.PurposeCitations)
+	for _, items := range [][]ModuleSummaryItem{summary.KeyTypes, summary.Dependencies, summary.Consumers} {
+		for i := range items {
+			items[i].Citations = filter(items[i].Citations)
+		}
+	}
+}
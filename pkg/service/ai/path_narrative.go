@@ -0,0 +1,240 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/duynguyendang/gca/pkg/common/deadline"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// PathNarrative explains a path through the graph hop by hop, each hop
+// carrying the code and provenance it was explained from.
+type PathNarrative struct {
+	Query   string             `json:"query"`
+	Summary string             `json:"summary"`
+	Hops    []PathNarrativeHop `json:"hops"`
+}
+
+// PathNarrativeHop is one edge of a narrated path. Code is the source of the
+// symbol the edge's fact starts from; Citation is From or To, whichever the
+// model grounded its explanation in.
+type PathNarrativeHop struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Relation    string `json:"relation,omitempty"`
+	Reversed    bool   `json:"reversed,omitempty"` // The fact points from To to From
+	Source      string `json:"source,omitempty"`   // Where the fact was recorded: "file:line", a file, or "virtual"
+	Code        string `json:"code,omitempty"`
+	Explanation string `json:"explanation"`
+	Citation    string `json:"citation,omitempty"`
+}
+
+// NarratePath explains the path in req.Data, a list of nodes or a graph of
+// nodes and links, with the code and edge provenance of each hop put in the
+// prompt and returned alongside the model's explanation of it.
+func (s *AIService) NarratePath(ctx context.Context, req AIRequest) (*PathNarrative, error) {
+	store, err := s.manager.GetStore(req.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	deadline.Enter(ctx, "path hydration")
+	prompt, hops, err := s.pathNarrativePrompt(ctx, store, req)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := s.GenerateText(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	narrative, err := parsePathNarrative(answer, hops)
+	if err != nil {
+		return nil, err
+	}
+	narrative.Query = req.Query
+	return narrative, nil
+}
+
+// pathNarrativePrompt hydrates the hops of the path in req.Data and renders
+// them as the evidence of the path_narrative prompt.
+func (s *AIService) pathNarrativePrompt(ctx context.Context, store *meb.MEBStore, req AIRequest) (string, []PathNarrativeHop, error) {
+	if s.PathNarrativePrompt == nil {
+		return "", nil, fmt.Errorf("path_narrative.prompt not loaded")
+	}
+	nodes, relations := parsePathData(req.Data)
+	if len(nodes) < 2 {
+		return "", nil, fmt.Errorf("path_narrative needs a path of at least two nodes")
+	}
+	hops := hydratePathHops(ctx, store, nodes, relations)
+	prompt, err := s.PathNarrativePrompt.Execute(map[string]interface{}{
+		"Query":    req.Query,
+		"Path":     strings.Join(nodes, " -> "),
+		"Evidence": formatPathEvidence(hops),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return prompt, hops, nil
+}
+
+// parsePathData reads a path sent by a client: a list of node IDs, of node
+// objects ({"id": ..., "name": ...}), or a graph with "nodes" and "links".
+// Relations are the links' predicates keyed by source and target.
+func parsePathData(data interface{}) ([]string, map[[2]string]string) {
+	relations := make(map[[2]string]string)
+	list, ok := data.([]interface{})
+	if graph, isGraph := data.(map[string]interface{}); isGraph {
+		list, ok = graph["nodes"].([]interface{})
+		links, _ := graph["links"].([]interface{})
+		for _, l := range links {
+			if m, ok := l.(map[string]interface{}); ok {
+				src, _ := m["source"].(string)
+				dst, _ := m["target"].(string)
+				rel, _ := m["relation"].(string)
+				if src != "" && dst != "" && rel != "" {
+					relations[[2]string{src, dst}] = rel
+				}
+			}
+		}
+	}
+	if !ok {
+		return nil, relations
+	}
+	nodes := make([]string, 0, len(list))
+	for _, item := range list {
+		switch v := item.(type) {
+		case string:
+			nodes = append(nodes, v)
+		case map[string]interface{}:
+			if id, ok := v["id"].(string); ok && id != "" {
+				nodes = append(nodes, id)
+			} else if name, ok := v["name"].(string); ok && name != "" {
+				nodes = append(nodes, name)
+			}
+		}
+	}
+	if len(nodes) > config.PathNarrativeMaxHops+1 {
+		nodes = nodes[:config.PathNarrativeMaxHops+1]
+	}
+	return nodes, relations
+}
+
+// hydratePathHops finds the fact behind each hop, from the client's links or
+// else the store, with its provenance and the code of its subject.
+func hydratePathHops(ctx context.Context, store *meb.MEBStore, nodes []string, relations map[[2]string]string) []PathNarrativeHop {
+	hops := make([]PathNarrativeHop, 0, len(nodes)-1)
+	for i := 0; i+1 < len(nodes); i++ {
+		hop := PathNarrativeHop{From: nodes[i], To: nodes[i+1]}
+		if rel, ok := relations[[2]string{hop.From, hop.To}]; ok {
+			hop.Relation = rel
+		} else if rel, ok := relations[[2]string{hop.To, hop.From}]; ok {
+			hop.Relation, hop.Reversed = rel, true
+		} else if rel := edgePredicate(ctx, store, hop.From, hop.To); rel != "" {
+			hop.Relation = rel
+		} else if rel := edgePredicate(ctx, store, hop.To, hop.From); rel != "" {
+			hop.Relation, hop.Reversed = rel, true
+		}
+
+		subj := hop.From
+		if hop.Reversed {
+			subj = hop.To
+		}
+		if hop.Relation != "" {
+			hop.Source = gcamdb.FactSource(ctx, store, subj, hop.Relation)
+		}
+		if code, err := gcamdb.GetDocument(store, subj); err == nil {
+			hop.Code = truncateCode(string(code), config.PathNarrativeMaxSnippet)
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// edgePredicate returns the predicate of a fact from subj to obj, or "".
+func edgePredicate(ctx context.Context, store *meb.MEBStore, subj, obj string) string {
+	for fact, err := range gcamdb.Scan(ctx, store, subj, "", obj) {
+		if err == nil && fact.Subject != "" {
+			return fact.Predicate
+		}
+	}
+	return ""
+}
+
+// formatPathEvidence renders hydrated hops as the evidence section of the prompt.
+func formatPathEvidence(hops []PathNarrativeHop) string {
+	var sb strings.Builder
+	for i, hop := range hops {
+		rel := hop.Relation
+		if rel == "" {
+			rel = "unknown relation"
+		}
+		if hop.Reversed {
+			fmt.Fprintf(&sb, "### Hop %d: %s <-[%s]- %s\n", i+1, hop.From, rel, hop.To)
+		} else {
+			fmt.Fprintf(&sb, "### Hop %d: %s -[%s]-> %s\n", i+1, hop.From, rel, hop.To)
+		}
+		if hop.Source != "" {
+			fmt.Fprintf(&sb, "Recorded at: %s\n", hop.Source)
+		}
+		if hop.Code != "" {
+			fmt.Fprintf(&sb, "```\n%s\n```\n", hop.Code)
+		} else {
+			sb.WriteString("(no code available)\n")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// parsePathNarrative reads the JSON object of a model answer onto the
+// hydrated hops, dropping citations other than a hop's own ends.
+func parsePathNarrative(answer string, hops []PathNarrativeHop) (*PathNarrative, error) {
+	start := strings.Index(answer, "{")
+	end := strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("path narrative is not JSON: %q", answer)
+	}
+	var parsed struct {
+		Summary string `json:"summary"`
+		Hops    []struct {
+			Hop         int    `json:"hop"`
+			Explanation string `json:"explanation"`
+			Citation    string `json:"citation"`
+		} `json:"hops"`
+	}
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse path narrative: %w", err)
+	}
+	narrative := &PathNarrative{Summary: parsed.Summary, Hops: hops}
+	for i, h := range parsed.Hops {
+		n := h.Hop - 1
+		if h.Hop == 0 {
+			n = i // Unnumbered: take the answer's order
+		}
+		if n < 0 || n >= len(hops) {
+			continue
+		}
+		hops[n].Explanation = h.Explanation
+		if h.Citation == hops[n].From || h.Citation == hops[n].To {
+			hops[n].Citation = h.Citation
+		}
+	}
+	return narrative, nil
+}
+
+// truncateCode cuts code to at most max bytes without splitting a rune.
+func truncateCode(code string, max int) string {
+	if len(code) <= max {
+		return code
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(code[cut]) {
+		cut--
+	}
+	return code[:cut] + "\n..."
+}
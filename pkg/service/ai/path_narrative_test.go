@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/prompts"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathNarrative(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	assert.NoError(t, s.AddDocument("api/user.go:GetUser", []byte("func GetUser(c *gin.Context) {\n\tsvc.Load(c.Param(\"id\"))\n}"), nil, nil))
+	assert.NoError(t, s.AddDocument("svc/user.go:Load", []byte("func Load(id string) (*User, error) {\n\treturn repo.Find(id)\n}"), nil, nil))
+	assert.NoError(t, s.AddFactBatch([]meb.Fact{
+		{Subject: "api/user.go:GetUser", Predicate: "calls", Object: "svc/user.go:Load"},
+		{Subject: "api/user.go:GetUser", Predicate: "start_line", Object: "12"},
+		{Subject: "repo/user.go:Find", Predicate: "called_by", Object: "svc/user.go:Load"},
+	}))
+
+	prompt, err := prompts.LoadPrompt("../../../prompts/path_narrative.prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &AIService{PathNarrativePrompt: prompt}
+
+	// A client-built path: node objects, no links.
+	req := AIRequest{
+		Query: "How is a user loaded?",
+		Data: []interface{}{
+			map[string]interface{}{"id": "api/user.go:GetUser", "name": "GetUser"},
+			map[string]interface{}{"id": "svc/user.go:Load", "name": "Load"},
+			map[string]interface{}{"id": "repo/user.go:Find", "name": "Find"},
+		},
+	}
+	text, hops, err := svc.pathNarrativePrompt(context.Background(), s, req)
+	if err != nil || len(hops) != 2 {
+		t.Fatalf("pathNarrativePrompt() hops = %+v, err = %v", hops, err)
+	}
+
+	assert.Equal(t, "calls", hops[0].Relation)
+	assert.Equal(t, "api/user.go:12", hops[0].Source)
+	assert.Contains(t, hops[0].Code, "svc.Load")
+	assert.Equal(t, "called_by", hops[1].Relation)
+	assert.True(t, hops[1].Reversed, "the called_by fact points from Find back to Load")
+	assert.Equal(t, "virtual", hops[1].Source)
+	assert.Contains(t, text, "### Hop 1: api/user.go:GetUser -[calls]-> svc/user.go:Load")
+	assert.Contains(t, text, "Recorded at: api/user.go:12")
+	assert.Contains(t, text, "svc.Load(c.Param")
+
+	answer := "```json\n" + `{"summary": "The handler loads the user.", "hops": [
+		{"hop": 1, "explanation": "GetUser passes the id to Load.", "citation": "api/user.go:GetUser"},
+		{"hop": 2, "explanation": "Load reads the repository.", "citation": "main.go:main"}]}` + "\n```"
+	narrative, err := parsePathNarrative(answer, hops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "The handler loads the user.", narrative.Summary)
+	assert.Equal(t, "GetUser passes the id to Load.", narrative.Hops[0].Explanation)
+	assert.Equal(t, "api/user.go:GetUser", narrative.Hops[0].Citation)
+	assert.Equal(t, "", narrative.Hops[1].Citation, "a citation outside the hop is dropped")
+
+	// A graph with links keeps the client's relations.
+	nodes, relations := parsePathData(map[string]interface{}{
+		"nodes": []interface{}{map[string]interface{}{"id": "a"}, map[string]interface{}{"id": "b"}},
+		"links": []interface{}{map[string]interface{}{"source": "a", "target": "b", "relation": "imports"}},
+	})
+	assert.Equal(t, []string{"a", "b"}, nodes)
+	assert.Equal(t, "imports", relations[[2]string{"a", "b"}])
+
+	_, _, err = svc.pathNarrativePrompt(context.Background(), s, AIRequest{Data: []interface{}{"a"}})
+	assert.Error(t, err)
+}
@@ -237,6 +237,16 @@ func ValidateDatalog(query string) (bool, error) {
 		"regex":        true,
 		"contains":     true,
 		"starts_with":  true,
+		"ends_with":    true,
+		"gt":           true,
+		"lt":           true,
+		"gte":          true,
+		"lte":          true,
+		"between":      true,
+		"weight_gt":    true,
+		"weight_gte":   true,
+		"weight_lt":    true,
+		"weight_lte":   true,
 		"calls":        true,
 		"defines":      true,
 		"imports":      true,
@@ -285,7 +295,7 @@ func BuildGraphContext(ctx context.Context, store *meb.MEBStore, symbolID string
 
 	symbolID = strings.Trim(symbolID, "\"' ")
 
-	content, err := store.GetContentByKey(symbolID)
+	content, err := gcamdb.GetDocument(store, symbolID)
 	if err == nil {
 		context["content"] = string(content)
 	}
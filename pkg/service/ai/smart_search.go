@@ -0,0 +1,188 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/deadline"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/gca/pkg/logger"
+	"github.com/duynguyendang/gca/pkg/service"
+)
+
+// Stages of a smart search, in the order they run.
+const (
+	SmartSearchStepDatalog  = "datalog"  // The question translated to Datalog
+	SmartSearchStepExecute  = "execute"  // The Datalog query run as a graph
+	SmartSearchStepSemantic = "semantic" // Similarity search, when the query found nothing
+	SmartSearchStepSummary  = "summary"  // The model's answer over the graph found
+)
+
+// SmartSearchStep reports how a stage of a smart search went.
+type SmartSearchStep struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"` // "ok", "empty", "failed" or "skipped"
+	Detail   string `json:"detail,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// SmartSearchResult holds the answer of a smart search and what each stage
+// produced, so that a UI can show how it was reached.
+type SmartSearchResult struct {
+	Question   string                         `json:"question"`
+	Intent     string                         `json:"intent"`
+	Confidence float64                        `json:"confidence"`
+	Datalog    string                         `json:"datalog"`
+	Source     string                         `json:"source"`            // Stage the graph came from: "datalog", "semantic" or "" for none
+	Matches    []service.SemanticSearchResult `json:"matches,omitempty"` // Symbols similarity search found
+	Graph      *export.D3Graph                `json:"graph"`
+	Answer     string                         `json:"answer"`
+	Citations  []string                       `json:"citations"` // IDs of the graph's nodes the answer names
+	Steps      []SmartSearchStep              `json:"steps"`
+}
+
+// SmartSearch answers question about projectID's code in one call: the
+// question is translated to Datalog and run as a graph; when that finds
+// nothing the symbols most similar to the question, and the edges among
+// them, are taken instead; the model then answers over the graph, citing the
+// nodes it names. Stages that fail are reported in Steps and the later ones
+// go on with what there is. SmartSearch only fails when the project has no
+// store.
+func (s *AIService) SmartSearch(ctx context.Context, graphs *service.GraphService, projectID, question string) (*SmartSearchResult, error) {
+	store, err := s.manager.GetStore(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	res := &SmartSearchResult{Question: question, Graph: &export.D3Graph{}, Citations: []string{}}
+	step := func(name string, began time.Time, status, detail string) {
+		res.Steps = append(res.Steps, SmartSearchStep{Name: name, Status: status, Detail: detail, Duration: time.Since(began).Milliseconds()})
+	}
+
+	began := time.Now()
+	deadline.Enter(ctx, "datalog generation")
+	intent := ClassifyIntent(question)
+	res.Intent, res.Confidence = string(intent.Intent), intent.Confidence
+	if gen, err := GenerateDatalog(ctx, question, intent.Intent, intent.Target, store); err != nil {
+		step(SmartSearchStepDatalog, began, "failed", err.Error())
+	} else {
+		res.Datalog = gen.Query
+		step(SmartSearchStepDatalog, began, "ok", "")
+	}
+
+	began = time.Now()
+	switch {
+	case res.Datalog == "":
+		step(SmartSearchStepExecute, began, "skipped", "no query")
+	default:
+		deadline.Enter(ctx, "query execution")
+		g, err := graphs.ExportGraph(ctx, projectID, res.Datalog, true, true)
+		switch {
+		case err != nil:
+			step(SmartSearchStepExecute, began, "failed", err.Error())
+		case len(g.Nodes) == 0:
+			step(SmartSearchStepExecute, began, "empty", "")
+		default:
+			res.Graph, res.Source = g, "datalog"
+			step(SmartSearchStepExecute, began, "ok", fmt.Sprintf("%d nodes", len(g.Nodes)))
+		}
+	}
+
+	began = time.Now()
+	if res.Source != "" {
+		step(SmartSearchStepSemantic, began, "skipped", "the query found nodes")
+	} else {
+		deadline.Enter(ctx, "semantic search")
+		if err := s.smartSearchFallback(ctx, graphs, projectID, res); err != nil {
+			step(SmartSearchStepSemantic, began, "failed", err.Error())
+		} else if len(res.Matches) == 0 {
+			step(SmartSearchStepSemantic, began, "empty", "")
+		} else {
+			step(SmartSearchStepSemantic, began, "ok", fmt.Sprintf("%d symbols", len(res.Matches)))
+		}
+	}
+
+	began = time.Now()
+	switch {
+	case len(res.Graph.Nodes) == 0:
+		res.Answer = "I couldn't find any code matching your question."
+		step(SmartSearchStepSummary, began, "skipped", "nothing found")
+	case s.SmartSearchPrompt == nil:
+		step(SmartSearchStepSummary, began, "failed", "smart_search.prompt not loaded")
+	default:
+		deadline.Enter(ctx, "answer synthesis")
+		nodes, links := formatSmartSearchGraph(res.Graph)
+		prompt, err := s.SmartSearchPrompt.Execute(map[string]interface{}{"Nodes": nodes, "Links": links, "Query": question})
+		if err == nil {
+			res.Answer, err = s.GenerateText(ctx, prompt)
+		}
+		if err != nil {
+			logger.Warn("Smart search summary failed", "project", projectID, "error", err)
+			step(SmartSearchStepSummary, began, "failed", err.Error())
+			break
+		}
+		res.Answer = strings.TrimSpace(res.Answer)
+		for _, n := range res.Graph.Nodes {
+			if strings.Contains(res.Answer, n.ID) {
+				res.Citations = append(res.Citations, n.ID)
+			}
+		}
+		step(SmartSearchStepSummary, began, "ok", "")
+	}
+	return res, nil
+}
+
+// smartSearchFallback fills res with the symbols most similar to its
+// question, by embedding or else by keyword, and the edges among them.
+func (s *AIService) smartSearchFallback(ctx context.Context, graphs *service.GraphService, projectID string, res *SmartSearchResult) error {
+	matches, err := graphs.SemanticSearch(ctx, projectID, res.Question, config.SmartSearchFallbackK, s)
+	if err != nil || len(matches) == 0 {
+		if err != nil {
+			logger.Warn("Semantic search failed, falling back to keyword search", "project", projectID, "error", err)
+		}
+		if matches, err = graphs.KeywordSearch(ctx, projectID, res.Question, config.SmartSearchFallbackK); err != nil {
+			return err
+		}
+	}
+	res.Matches = matches
+	if len(matches) == 0 {
+		return nil
+	}
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.SymbolID
+	}
+	g, err := graphs.GetSubgraphWithOptions(ctx, projectID, service.SubgraphOptions{IDs: ids})
+	if err != nil {
+		return err
+	}
+	res.Graph, res.Source = g, "semantic"
+	return nil
+}
+
+// formatSmartSearchGraph lists the nodes and links of g for the smart_search
+// prompt, up to config.SmartSearchMaxNodes of each.
+func formatSmartSearchGraph(g *export.D3Graph) (string, string) {
+	var nodes, links strings.Builder
+	for i, n := range g.Nodes {
+		if i == config.SmartSearchMaxNodes {
+			fmt.Fprintf(&nodes, "... and %d more\n", len(g.Nodes)-i)
+			break
+		}
+		fmt.Fprintf(&nodes, "%d. **%s** (Type: %s)\n   ID: `%s`\n", i+1, n.Name, n.Kind, n.ID)
+	}
+	for i, l := range g.Links {
+		if i == config.SmartSearchMaxNodes {
+			fmt.Fprintf(&links, "... and %d more\n", len(g.Links)-i)
+			break
+		}
+		relation := l.Relation
+		if relation == "" {
+			relation = config.PredicateCalls
+		}
+		fmt.Fprintf(&links, "%d. `%s` **%s** `%s`\n", i+1, l.Source, relation, l.Target)
+	}
+	return nodes.String(), links.String()
+}
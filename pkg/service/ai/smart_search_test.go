@@ -0,0 +1,31 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatSmartSearchGraph(t *testing.T) {
+	g := &export.D3Graph{
+		Nodes: []export.D3Node{
+			{ID: "auth/jwt.go:Verify", Name: "Verify", Kind: "func"},
+			{ID: "api/server.go:Auth", Name: "Auth", Kind: "func"},
+		},
+		Links: []export.D3Link{{Source: "api/server.go:Auth", Target: "auth/jwt.go:Verify"}},
+	}
+	nodes, links := formatSmartSearchGraph(g)
+	assert.Contains(t, nodes, "1. **Verify** (Type: func)\n   ID: `auth/jwt.go:Verify`")
+	assert.Contains(t, links, "`api/server.go:Auth` **calls** `auth/jwt.go:Verify`", "links without a relation are calls")
+
+	for i := range config.SmartSearchMaxNodes + 5 {
+		g.Nodes = append(g.Nodes, export.D3Node{ID: fmt.Sprintf("gen.go:F%d", i)})
+	}
+	nodes, _ = formatSmartSearchGraph(g)
+	assert.Equal(t, config.SmartSearchMaxNodes, strings.Count(nodes, "ID: `"))
+	assert.Contains(t, nodes, "... and 7 more")
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 )
 
@@ -46,20 +47,20 @@ func (s *CentralityService) ComputeDegreeCentrality(ctx context.Context, store *
 	outDegree := make(map[string]int)
 	kindMap := make(map[string]string)
 
-	for fact := range store.ScanContext(ctx, "", config.PredicateCalls, "") {
+	for fact := range gcamdb.Scan(ctx, store, "", config.PredicateCalls, "") {
 		if obj, ok := fact.Object.(string); ok {
 			inDegree[obj]++
 		}
 		outDegree[fact.Subject]++
 	}
 
-	for fact := range store.ScanContext(ctx, "", config.PredicateDefines, "") {
+	for fact := range gcamdb.Scan(ctx, store, "", config.PredicateDefines, "") {
 		if sym, ok := fact.Object.(string); ok {
 			kindMap[sym] = s.inferKind(sym)
 		}
 	}
 
-	for fact := range store.ScanContext(ctx, "", config.PredicateImports, "") {
+	for fact := range gcamdb.Scan(ctx, store, "", config.PredicateImports, "") {
 		if obj, ok := fact.Object.(string); ok {
 			inDegree[obj]++
 		}
@@ -279,7 +280,7 @@ func ComputePageRankCentrality(ctx context.Context, store *meb.MEBStore, iterati
 	nodes := make(map[string]struct{})
 	edges := make(map[string][]string)
 
-	for fact := range store.ScanContext(ctx, "", config.PredicateCalls, "") {
+	for fact := range gcamdb.Scan(ctx, store, "", config.PredicateCalls, "") {
 		if obj, ok := fact.Object.(string); ok {
 			nodes[fact.Subject] = struct{}{}
 			nodes[obj] = struct{}{}
@@ -378,3 +379,57 @@ func NormalizeCentrality(scores map[string]float64) map[string]float64 {
 
 	return normalized
 }
+
+// pageRank ranks the nodes of adj by PageRank over its pred edges, each rank
+// divided by the highest. The rank of nodes without pred facts is spread
+// evenly over the graph.
+func pageRank(adj *gcamdb.Adjacency, pred string, iterations int, damping float64) map[string]float64 {
+	nodes := adj.Nodes()
+	n := len(nodes)
+	if n == 0 {
+		return map[string]float64{}
+	}
+	index := make(map[string]int, n)
+	for i, id := range nodes {
+		index[id] = i
+	}
+	var src, dst []int
+	outDeg := make([]int, n)
+	for s, d := range adj.Edges(pred) {
+		src = append(src, index[s])
+		dst = append(dst, index[d])
+		outDeg[index[s]]++
+	}
+
+	ranks := make([]float64, n)
+	for i := range ranks {
+		ranks[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for range iterations {
+		dangling := 0.0
+		for i, r := range ranks {
+			if outDeg[i] == 0 {
+				dangling += r
+			}
+		}
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for k := range src {
+			next[dst[k]] += damping * ranks[src[k]] / float64(outDeg[src[k]])
+		}
+		ranks, next = next, ranks
+	}
+
+	maxRank := 0.0
+	for _, r := range ranks {
+		maxRank = math.Max(maxRank, r)
+	}
+	scores := make(map[string]float64, n)
+	for i, id := range nodes {
+		scores[id] = ranks[i] / maxRank
+	}
+	return scores
+}
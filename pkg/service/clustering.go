@@ -2,7 +2,9 @@ package service
 
 import (
 	"math/rand"
-	"time"
+	"sort"
+
+	"github.com/duynguyendang/gca/pkg/config"
 )
 
 // GraphNode represents a simple node for clustering.
@@ -20,14 +22,20 @@ type GraphLink struct {
 
 // ClusteringService handles community detection.
 type ClusteringService struct {
+	// Seed seeds the order Leiden visits nodes in, so the same graph and seed
+	// always give the same clusters
+	Seed int64
 }
 
-// NewClusteringService creates a new instance.
+// NewClusteringService creates a new instance seeded with
+// config.GraphDefaultSeed.
 func NewClusteringService() *ClusteringService {
-	return &ClusteringService{}
+	return &ClusteringService{Seed: config.GraphDefaultSeed}
 }
 
-// ClusterResult contains the mapping of node IDs to cluster IDs.
+// ClusterResult contains the mapping of node IDs to cluster IDs. Clusters are
+// numbered in the order of their smallest member ID, and list their members
+// sorted, so equal partitions get equal IDs.
 type ClusterResult struct {
 	Clusters    map[int][]string // ClusterID -> []NodeID
 	NodeCluster map[string]int   // NodeID -> ClusterID
@@ -58,6 +66,11 @@ func (s *ClusteringService) DetectCommunitiesLeiden(nodes []GraphNode, links []G
 		Neighbors map[int]float64 // Index of neighbor -> Weight
 	}
 
+	// Index nodes in ID order, so that the caller's order does not change the
+	// visit order the seed gives
+	nodes = append([]GraphNode(nil), nodes...)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
 	nodeMap := make(map[string]int) // ID -> Index
 	graphNodes := make([]*NodeData, len(nodes))
 
@@ -105,7 +118,7 @@ func (s *ClusteringService) DetectCommunitiesLeiden(nodes []GraphNode, links []G
 		commTotalWeight[i] = n.Weight
 	}
 
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rng := rand.New(rand.NewSource(s.Seed))
 	improved := true
 
 	// Leiden/Louvain main loop
@@ -156,11 +169,18 @@ func (s *ClusteringService) DetectCommunitiesLeiden(nodes []GraphNode, links []G
 			tot_old := commTotalWeight[oldComm] - k_i // Remove self
 			gain_old := w_in_old - (Resolution * tot_old * factor)
 
-			for c, w_in := range neighborComms {
+			// Ties go to the first community in ID order, not map order
+			comms := make([]int, 0, len(neighborComms))
+			for c := range neighborComms {
+				comms = append(comms, c)
+			}
+			sort.Ints(comms)
+			for _, c := range comms {
 				if c == oldComm {
 					continue
 				}
 
+				w_in := neighborComms[c]
 				tot := commTotalWeight[c]
 				gain := w_in - (Resolution * tot * factor)
 
@@ -195,7 +215,8 @@ func (s *ClusteringService) DetectCommunitiesLeiden(nodes []GraphNode, links []G
 	newCommMap := make(map[int]int)
 	nextID := 0
 
-	for i, commID := range partition {
+	for i := range graphNodes {
+		commID := partition[i]
 		realCommID, exists := newCommMap[commID]
 		if !exists {
 			realCommID = nextID
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDetectCommunitiesLeidenDeterministic(t *testing.T) {
+	// Two dense groups joined by one edge
+	var nodes []GraphNode
+	var links []GraphLink
+	for g := range 2 {
+		for i := range 6 {
+			nodes = append(nodes, GraphNode{ID: fmt.Sprintf("g%d/n%d", g, i)})
+			for j := range i {
+				links = append(links, GraphLink{Source: fmt.Sprintf("g%d/n%d", g, i), Target: fmt.Sprintf("g%d/n%d", g, j)})
+			}
+		}
+	}
+	links = append(links, GraphLink{Source: "g0/n0", Target: "g1/n0"})
+
+	want := (&ClusteringService{Seed: 7}).DetectCommunitiesLeiden(nodes, links)
+	reversed := make([]GraphNode, len(nodes))
+	for i, n := range nodes {
+		reversed[len(nodes)-1-i] = n
+	}
+	for range 5 {
+		got := (&ClusteringService{Seed: 7}).DetectCommunitiesLeiden(reversed, links)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("same seed gave different clusters:\n%v\n%v", got.Clusters, want.Clusters)
+		}
+	}
+	if want.NodeCluster["g0/n0"] != 0 {
+		t.Errorf("cluster of the smallest ID = %d, want 0", want.NodeCluster["g0/n0"])
+	}
+	for id, members := range want.Clusters {
+		for i := 1; i < len(members); i++ {
+			if members[i-1] > members[i] {
+				t.Errorf("cluster %d members not sorted: %v", id, members)
+			}
+		}
+	}
+}
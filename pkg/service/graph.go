@@ -6,8 +6,11 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/common/deadline"
 	"github.com/duynguyendang/gca/pkg/common/errors"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/export"
@@ -22,6 +25,11 @@ type HydratedSymbol struct {
 	Content  string                 `json:"code"`
 	Metadata map[string]interface{} `json:"metadata"`
 	Children []HydratedSymbol       `json:"children,omitempty"`
+	// ChildCount is the number of symbols this one defines, hydrated or not
+	ChildCount int `json:"child_count,omitempty"`
+	// NextChildren continues Children where the page stopped; pass it back as
+	// HydrateOptions.After with this symbol's ID
+	NextChildren string `json:"next_children,omitempty"`
 }
 
 // ProjectStoreManager interface abstraction
@@ -30,19 +38,52 @@ type ProjectStoreManager interface {
 	ListProjects() ([]manager.ProjectMetadata, error)
 }
 
+// SharedStoreManager is implemented by managers that keep several projects in
+// one store. ProjectTopic reports the topic a project was ingested under and
+// whether its queries must be scoped to it.
+type SharedStoreManager interface {
+	ProjectTopic(projectID string) (uint32, bool)
+}
+
 // GraphService handles graph query and enrichment operations.
 type GraphService struct {
-	manager         ProjectStoreManager
-	projectMapCache map[string]*export.D3Graph
-	cacheMu         sync.RWMutex
+	manager       ProjectStoreManager
+	graphCache    *graphCache    // nil when config.GraphCacheEnabled is false
+	manifestCache *manifestCache // nil when config.GraphCacheEnabled is false
+	symbolIndex   *symbolIndexCache
+	tokenIndexes  *tokenIndexCache
+	docIndexes    *tokenIndexCache
+	kindIndexes   *kindIndexCache
+	adjacencies   *adjacencyCache
+	topSymbols    *topSymbolsCache
+	layouts       *layoutCache
+	annotationsMu sync.Mutex // serializes read-modify-write of annotations documents
+	importMu      sync.Mutex // serializes fact imports, which switch the store's topic
+
+	// started and revision stand for the writes ContentVersion cannot see in
+	// the store: revision counts them since the service started
+	started  time.Time
+	revision atomic.Uint64
 }
 
 // NewGraphService creates a new GraphService.
 func NewGraphService(manager ProjectStoreManager) *GraphService {
-	return &GraphService{
-		manager:         manager,
-		projectMapCache: make(map[string]*export.D3Graph),
-	}
+	s := &GraphService{
+		manager:      manager,
+		symbolIndex:  newSymbolIndexCache(),
+		tokenIndexes: newTokenIndexCache(),
+		docIndexes:   newDocIndexCache(),
+		kindIndexes:  newKindIndexCache(),
+		adjacencies:  newAdjacencyCache(),
+		topSymbols:   newTopSymbolsCache(),
+		layouts:      newLayoutCache(config.LayoutCacheMaxSize),
+		started:      time.Now(),
+	}
+	if config.GraphCacheEnabled {
+		s.graphCache = newGraphCache(config.GraphCacheTTL, config.GraphCacheMaxSize)
+		s.manifestCache = newManifestCache()
+	}
+	return s
 }
 
 // ListProjects returns a list of available projects.
@@ -53,31 +94,61 @@ func (s *GraphService) ListProjects() ([]manager.ProjectMetadata, error) {
 // ExportGraph executes a query and transforms the results into a D3 graph JSON.
 // It also optionally hydrates the nodes with source code.
 func (s *GraphService) ExportGraph(ctx context.Context, projectID, query string, hydrate bool, lazy bool) (*export.D3Graph, error) {
+	return s.ExportGraphWithOptions(ctx, projectID, query, hydrate, lazy, gcamdb.QueryOptions{})
+}
+
+// ExportGraphWithOptions is ExportGraph with an explicit query row limit and deadline.
+// Partial-result warnings from the query are copied onto the graph.
+func (s *GraphService) ExportGraphWithOptions(ctx context.Context, projectID, query string, hydrate bool, lazy bool, opts gcamdb.QueryOptions) (*export.D3Graph, error) {
 	store, err := s.getStore(projectID)
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
+	if opts.AsOf > 0 {
+		// Hydration reads the same version as the query
+		if ctx, err = gcamdb.AsOf(ctx, store, opts.AsOf); err != nil {
+			return nil, queryError(err)
+		}
+	}
+
+	cacheKey := graphCacheKey{Project: projectID, Query: normalizeQuery(query), Hydrate: hydrate, Lazy: lazy, Limit: opts.Limit, AsOf: opts.AsOf}
+	factCount := store.Count()
+	if cached, ok := s.graphCache.get(cacheKey, factCount); ok {
+		return cached, nil
+	}
 
 	// 1. Execute Query
-	results, err := gcamdb.Query(ctx, store, query)
+	deadline.Enter(ctx, "query")
+	// Rows carry their fact's provenance so links report where they came from
+	opts.Provenance = true
+	res, err := gcamdb.QueryWithOptions(ctx, store, query, opts)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+		return nil, queryError(err)
 	}
 
 	// 2. Transform to D3
-	transformer := export.NewD3Transformer(store)
-	graph, err := transformer.Transform(ctx, query, results)
+	deadline.Enter(ctx, "graph transform")
+	transformer := s.newTransformer(ctx, projectID, store)
+	graph, err := transformer.Transform(ctx, query, res.Rows)
 	if err != nil {
 		return nil, fmt.Errorf("%w: transformer failed: %v", errors.ErrInternal, err)
 	}
+	graph.Warnings = res.Warnings
 
 	// 3. Hydrate if requested
 	if hydrate && len(graph.Nodes) > 0 {
+		deadline.Enter(ctx, "hydration")
 		if err := s.enrichNodes(ctx, store, graph, lazy); err != nil {
 			return nil, fmt.Errorf("%w: hydration failed: %v", errors.ErrInternal, err)
 		}
 	}
 
+	// Partial results depend on timing, so only complete graphs are cached
+	if !res.TimedOut {
+		s.graphCache.set(cacheKey, factCount, graph)
+	}
+
 	return graph, nil
 }
 
@@ -97,113 +168,24 @@ func (s *GraphService) getStore(projectID string) (*meb.MEBStore, error) {
 	return store, nil
 }
 
-// GetCentralityRanking returns symbols ranked by their graph centrality
-func (s *GraphService) GetCentralityRanking(ctx context.Context, projectID string, limit int) ([]CentralityResult, error) {
-	store, err := s.getStore(projectID)
-	if err != nil {
-		return nil, err
-	}
-
-	inDegree := make(map[string]int)
-	outDegree := make(map[string]int)
-
-	for fact := range store.Scan("", config.PredicateCalls, "") {
-		if obj, ok := fact.Object.(string); ok {
-			inDegree[obj]++
-		}
-		outDegree[fact.Subject]++
-	}
-
-	for fact := range store.Scan("", config.PredicateImports, "") {
-		if obj, ok := fact.Object.(string); ok {
-			inDegree[obj]++
-		}
-		outDegree[fact.Subject]++
+// scope restricts ctx to projectID's graph when the project shares its store.
+func (s *GraphService) scope(ctx context.Context, projectID string) context.Context {
+	shared, ok := s.manager.(SharedStoreManager)
+	if !ok {
+		return ctx
 	}
-
-	type scoredSymbol struct {
-		symbol    string
-		inDegree  int
-		outDegree int
-		score     float64
-	}
-
-	var symbols []scoredSymbol
-	seen := make(map[string]bool)
-
-	for sym := range inDegree {
-		if !seen[sym] {
-			seen[sym] = true
-			symbols = append(symbols, scoredSymbol{
-				symbol:    sym,
-				inDegree:  inDegree[sym],
-				outDegree: outDegree[sym],
-				score:     float64(inDegree[sym] + outDegree[sym]),
-			})
-		}
-	}
-	for sym := range outDegree {
-		if !seen[sym] {
-			seen[sym] = true
-			symbols = append(symbols, scoredSymbol{
-				symbol:    sym,
-				inDegree:  inDegree[sym],
-				outDegree: outDegree[sym],
-				score:     float64(inDegree[sym] + outDegree[sym]),
-			})
-		}
-	}
-
-	maxScore := 0.0
-	for i := range symbols {
-		boost := 1.0
-		lower := strings.ToLower(symbols[i].symbol)
-		if strings.Contains(lower, ":main") || strings.Contains(lower, ".main") ||
-			strings.Contains(lower, ":init") || strings.Contains(lower, ".init") {
-			boost = 2.5
-		}
-		if symbols[i].outDegree > 10 && symbols[i].inDegree > 5 {
-			boost *= 1.5
-		}
-		if IsInterfacePattern(symbols[i].symbol) {
-			boost *= 1.3
-		}
-		symbols[i].score *= boost
-		if symbols[i].score > maxScore {
-			maxScore = symbols[i].score
-		}
-	}
-
-	if maxScore > 0 {
-		for i := range symbols {
-			symbols[i].score /= maxScore
-		}
-	}
-
-	for i := 0; i < len(symbols)-1; i++ {
-		for j := i + 1; j < len(symbols); j++ {
-			if symbols[i].score < symbols[j].score {
-				symbols[i], symbols[j] = symbols[j], symbols[i]
-			}
-		}
+	topic, scoped := shared.ProjectTopic(projectID)
+	if !scoped {
+		return ctx
 	}
+	return gcamdb.WithScope(ctx, gcamdb.Scope{Project: projectID, Topic: topic})
+}
 
+// GetCentralityRanking returns the limit symbols (20 by default) ranked
+// highest by their degree centrality; see GetTopSymbols.
+func (s *GraphService) GetCentralityRanking(ctx context.Context, projectID string, limit int) ([]CentralityResult, error) {
 	if limit <= 0 {
 		limit = 20
 	}
-	if limit > len(symbols) {
-		limit = len(symbols)
-	}
-
-	results := make([]CentralityResult, limit)
-	for i := 0; i < limit; i++ {
-		results[i] = CentralityResult{
-			SymbolID:   symbols[i].symbol,
-			Centrality: symbols[i].score,
-			InDegree:   symbols[i].inDegree,
-			OutDegree:  symbols[i].outDegree,
-		}
-	}
-
-	return results, nil
+	return s.GetTopSymbols(ctx, projectID, MetricDegree, limit)
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"iter"
 
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 	"github.com/duynguyendang/meb/vector"
 )
@@ -108,18 +109,21 @@ func (kg *KnowledgeGraph) SemanticSearch(ctx context.Context, opts SemanticSearc
 			continue
 		}
 
-		subject, err := kg.store.ResolveID(vr.ID)
-		if err != nil {
+		key, err := kg.store.ResolveID(vr.ID)
+		if err != nil || gcamdb.IsDocNode(key) {
 			continue
 		}
 
-		results = append(results, SearchResult{
-			ID:      vr.ID,
-			Score:   vr.Score,
-			Subject: subject,
-		})
+		for _, subject := range gcamdb.VectorSymbols(ctx, kg.store, key) {
+			results = append(results, SearchResult{
+				ID:      vr.ID,
+				Score:   vr.Score,
+				Subject: subject,
+			})
+		}
 
 		if len(results) >= limit {
+			results = results[:limit]
 			break
 		}
 	}
@@ -133,7 +137,11 @@ func (kg *KnowledgeGraph) GetContent(id string) ([]byte, error) {
 	if !found {
 		return nil, fmt.Errorf("id not found: %s", id)
 	}
-	return kg.store.GetContent(dictID)
+	raw, err := kg.store.GetContent(dictID)
+	if err != nil {
+		return nil, err
+	}
+	return gcamdb.DecodeContent(raw, kg.store.GetContentByKey)
 }
 
 // LookupID resolves a string ID to its dictionary ID.
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// adjacencyCache keeps the adjacency snapshot ingest saved for each project,
+// reloaded when the project's fact count changes. Snapshots are decoded once
+// into flat arrays, so graph algorithms read neighbors from memory.
+type adjacencyCache struct {
+	mu      sync.Mutex
+	entries map[string]adjacencyEntry
+}
+
+type adjacencyEntry struct {
+	adj       *gcamdb.Adjacency // nil when the saved snapshot is stale
+	factCount uint64
+}
+
+func newAdjacencyCache() *adjacencyCache {
+	return &adjacencyCache{entries: make(map[string]adjacencyEntry)}
+}
+
+// get returns the project's adjacency snapshot, or nil when ingest saved none
+// or facts were written since, which leaves callers to scan the store. A
+// project without one is looked up again each time, as saving one writes
+// no facts.
+func (c *adjacencyCache) get(projectID string, store *meb.MEBStore) *gcamdb.Adjacency {
+	factCount := store.Count()
+	c.mu.Lock()
+	entry, ok := c.entries[projectID]
+	c.mu.Unlock()
+	if ok && entry.factCount == factCount {
+		return entry.adj
+	}
+
+	adj, err := gcamdb.LoadAdjacency(store, projectID)
+	if err != nil {
+		logger.Warn("Could not load adjacency snapshot", "project", projectID, "error", err)
+	}
+	if adj == nil {
+		return nil
+	}
+	if !adj.Fresh(store) {
+		adj = nil
+	}
+	c.mu.Lock()
+	c.entries[projectID] = adjacencyEntry{adj: adj, factCount: factCount}
+	c.mu.Unlock()
+	return adj
+}
+
+// adjacency returns the project's fresh adjacency snapshot, or nil when there
+// is none or ctx reads a past version, which the snapshot does not describe.
+func (s *GraphService) adjacency(ctx context.Context, projectID string, store *meb.MEBStore) *gcamdb.Adjacency {
+	if _, pinned := gcamdb.AsOfVersion(ctx); pinned {
+		return nil
+	}
+	return s.adjacencies.get(projectID, store)
+}
+
+// adjacentNeighbors offers add the neighbors of nodeID that opts allows, as
+// getWeightedNeighbors finds them by scanning, from the snapshot adj. Without
+// predicates in opts, every structural predicate is followed.
+func adjacentNeighbors(adj *gcamdb.Adjacency, nodeID string, portals map[string]string, opts PathOptions, add func(string, pathEdge)) {
+	preds := opts.Predicates
+	if len(preds) == 0 {
+		preds = config.StructuralPredicates
+	}
+
+	if opts.Direction != PathReverse {
+		if handler, ok := portals[nodeID]; ok {
+			add(handler, pathEdge{pred: config.PredicateHandledBy})
+		}
+		for _, p := range preds {
+			for _, n := range adj.Out(nodeID, p) {
+				add(n, pathEdge{pred: p})
+			}
+		}
+		if opts.allows(config.PredicateParentDefines) {
+			for _, n := range adj.In(nodeID, config.PredicateDefines) {
+				add(n, pathEdge{pred: config.PredicateParentDefines})
+			}
+		}
+	}
+
+	if opts.Direction != PathForward {
+		for _, p := range preds {
+			for _, n := range adj.In(nodeID, p) {
+				add(n, pathEdge{pred: p, reversed: true})
+			}
+		}
+	}
+}
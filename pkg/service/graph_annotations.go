@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// AnnotationsKey is the document holding a store's annotations graph: notes,
+// labels and manual links users add on top of the extracted facts. It lives
+// outside the fact indexes so re-ingesting a file keeps its annotations. Node
+// IDs carry their project prefix, so one document serves a shared store.
+const AnnotationsKey = "gca:annotations"
+
+// VirtualLinkProvenance marks links added by an annotation in exported graphs.
+const VirtualLinkProvenance = "annotation"
+
+// Verdicts users give on edges, inferred ones in particular: each confirmed or
+// rejected link also tunes the confidence in other links inferred the same way.
+const (
+	VerdictConfirmed = "confirmed"
+	VerdictRejected  = "rejected"
+)
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Annotation is a user note or status labels on a node or an edge. An edge
+// annotation with Virtual set also adds the edge to exported graphs, for
+// dependencies the extractor cannot see; one with a Verdict confirms or rejects
+// the edge. An annotation with ExpiresAt set, by the request or the store's
+// retention policy for its graph, disappears then.
+//
+// The UIDs of the annotated symbols are recorded with it, so that it follows
+// them when they move to another file and take another ID.
+type Annotation struct {
+	ID        string    `json:"id"`
+	Node      string    `json:"node,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	Relation  string    `json:"relation,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	Labels    []string  `json:"labels,omitempty"` // e.g. deprecated, hot-path
+	Virtual   bool      `json:"virtual,omitempty"`
+	Verdict   string    `json:"verdict,omitempty"` // VerdictConfirmed or VerdictRejected
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	NodeUID   string    `json:"node_uid,omitempty"`
+	SourceUID string    `json:"source_uid,omitempty"`
+	TargetUID string    `json:"target_uid,omitempty"`
+}
+
+// graph returns the retention graph of a: gcamdb.GraphVirtual for a virtual
+// link, else gcamdb.GraphAnnotations.
+func (a *Annotation) graph() string {
+	if a.Virtual {
+		return gcamdb.GraphVirtual
+	}
+	return gcamdb.GraphAnnotations
+}
+
+func (a *Annotation) validate() error {
+	switch {
+	case a.Node != "" && (a.Source != "" || a.Target != ""):
+		return fmt.Errorf("%w: annotation targets either a node or an edge", errors.ErrInvalidInput)
+	case a.Node == "" && (a.Source == "" || a.Target == ""):
+		return fmt.Errorf("%w: annotation needs a node or an edge source and target", errors.ErrInvalidInput)
+	case a.Virtual && a.Node != "":
+		return fmt.Errorf("%w: only edge annotations can add a virtual link", errors.ErrInvalidInput)
+	case a.Virtual && a.Relation == "":
+		return fmt.Errorf("%w: virtual link needs a relation", errors.ErrInvalidInput)
+	case a.Verdict != "" && a.Verdict != VerdictConfirmed && a.Verdict != VerdictRejected:
+		return fmt.Errorf("%w: verdict must be %q or %q", errors.ErrInvalidInput, VerdictConfirmed, VerdictRejected)
+	case a.Verdict != "" && a.Node != "":
+		return fmt.Errorf("%w: only edge annotations can have a verdict", errors.ErrInvalidInput)
+	case a.Note == "" && len(a.Labels) == 0 && !a.Virtual && a.Verdict == "":
+		return fmt.Errorf("%w: annotation has no note, labels, virtual link or verdict", errors.ErrInvalidInput)
+	}
+	for _, label := range a.Labels {
+		if !labelPattern.MatchString(label) {
+			return fmt.Errorf("%w: label %q must be lowercase letters, digits and dashes", errors.ErrInvalidInput, label)
+		}
+	}
+	return nil
+}
+
+// AddAnnotation validates a and appends it to the project store's annotations
+// graph, returning it with its ID, creation time and, under the store's
+// retention policy, expiry set. Expired annotations are dropped as it
+// rewrites the graph.
+func (s *GraphService) AddAnnotation(projectID string, a *Annotation) (*Annotation, error) {
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("%w: generate annotation ID: %v", errors.ErrInternal, err)
+	}
+	added := *a
+	added.ID = hex.EncodeToString(id)
+	added.CreatedAt = time.Now().UTC()
+	added.NodeUID = symbolUID(store, added.Node)
+	added.SourceUID = symbolUID(store, added.Source)
+	added.TargetUID = symbolUID(store, added.Target)
+	if !added.ExpiresAt.IsZero() && !added.ExpiresAt.After(added.CreatedAt) {
+		return nil, fmt.Errorf("%w: expires_at is in the past", errors.ErrInvalidInput)
+	}
+	if added.ExpiresAt.IsZero() {
+		policy, err := gcamdb.Retention(store)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInternal, err)
+		}
+		added.ExpiresAt = policy.ExpiresAt(added.graph(), added.CreatedAt)
+	}
+
+	s.annotationsMu.Lock()
+	defer s.annotationsMu.Unlock()
+	annotations, err := loadAnnotations(store)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(append(annotations, added))
+	if err != nil {
+		return nil, fmt.Errorf("%w: encode annotations: %v", errors.ErrInternal, err)
+	}
+	if err := store.AddDocument(AnnotationsKey, data, nil, nil); err != nil {
+		return nil, fmt.Errorf("%w: save annotations: %v", errors.ErrInternal, err)
+	}
+	s.revision.Add(1)
+	return &added, nil
+}
+
+// AnnotateGraph merges the annotations graph into an exported graph: node and
+// edge notes and labels go into Metadata, and virtual links between nodes of
+// the graph are added.
+func (s *GraphService) AnnotateGraph(projectID string, graph *export.D3Graph) error {
+	if graph == nil || len(graph.Nodes) == 0 {
+		return nil
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return err
+	}
+	annotations, err := loadAnnotations(store)
+	if err != nil || len(annotations) == 0 {
+		return err
+	}
+
+	nodes := make(map[string]int, len(graph.Nodes))
+	for i, n := range graph.Nodes {
+		nodes[n.ID] = i
+	}
+	type edgeKey struct{ source, target, relation string }
+	edges := make(map[edgeKey][]int)
+	for i, l := range graph.Links {
+		// An annotation without a relation covers every link between its endpoints
+		keys := []edgeKey{{l.Source, l.Target, ""}}
+		if l.Relation != "" {
+			keys = append(keys, edgeKey{l.Source, l.Target, l.Relation})
+		}
+		for _, k := range keys {
+			edges[k] = append(edges[k], i)
+		}
+	}
+
+	for _, a := range annotations {
+		if a.Node != "" {
+			if i, ok := nodes[a.Node]; ok {
+				n := &graph.Nodes[i]
+				if n.Metadata == nil {
+					n.Metadata = make(map[string]string)
+				}
+				annotateMetadata(n.Metadata, a)
+			}
+			continue
+		}
+		_, okSource := nodes[a.Source]
+		_, okTarget := nodes[a.Target]
+		if !okSource || !okTarget {
+			continue
+		}
+		matched := edges[edgeKey{a.Source, a.Target, a.Relation}]
+		if len(matched) == 0 && a.Virtual {
+			graph.Links = append(graph.Links, export.D3Link{
+				Source:           a.Source,
+				Target:           a.Target,
+				Relation:         a.Relation,
+				Weight:           1,
+				Type:             "virtual",
+				SourceProvenance: VirtualLinkProvenance,
+			})
+			matched = []int{len(graph.Links) - 1}
+			edges[edgeKey{a.Source, a.Target, a.Relation}] = matched
+		}
+		for _, i := range matched {
+			l := &graph.Links[i]
+			if l.Metadata == nil {
+				l.Metadata = make(map[string]string)
+			}
+			annotateMetadata(l.Metadata, a)
+		}
+	}
+	return nil
+}
+
+// annotateMetadata adds a's labels (comma-separated, like owners), note (one
+// per line) and verdict, the latest of which stands, to metadata.
+func annotateMetadata(metadata map[string]string, a Annotation) {
+	if len(a.Labels) > 0 {
+		labels := strings.Split(metadata["labels"], ",")
+		if metadata["labels"] == "" {
+			labels = nil
+		}
+		for _, label := range a.Labels {
+			if !slices.Contains(labels, label) {
+				labels = append(labels, label)
+			}
+		}
+		metadata["labels"] = strings.Join(labels, ",")
+	}
+	if a.Note != "" {
+		if metadata["notes"] != "" {
+			metadata["notes"] += "\n"
+		}
+		metadata["notes"] += a.Note
+	}
+	if a.Verdict != "" {
+		metadata["verdict"] = a.Verdict
+	}
+}
+
+// annotateHydrated adds node annotations to hydrated symbols and their children
+// as "labels" and "notes" metadata.
+func annotateHydrated(hydrated []HydratedSymbol, byNode map[string][]Annotation) {
+	for i := range hydrated {
+		hs := &hydrated[i]
+		var labels, notes []string
+		for _, a := range byNode[hs.ID] {
+			for _, label := range a.Labels {
+				if !slices.Contains(labels, label) {
+					labels = append(labels, label)
+				}
+			}
+			if a.Note != "" {
+				notes = append(notes, a.Note)
+			}
+		}
+		if len(labels) > 0 {
+			hs.Metadata["labels"] = labels
+		}
+		if len(notes) > 0 {
+			hs.Metadata["notes"] = notes
+		}
+		annotateHydrated(hs.Children, byNode)
+	}
+}
+
+// nodeAnnotations indexes the store's node annotations by node ID.
+func nodeAnnotations(store *meb.MEBStore) (map[string][]Annotation, error) {
+	annotations, err := loadAnnotations(store)
+	if err != nil {
+		return nil, err
+	}
+	byNode := make(map[string][]Annotation)
+	for _, a := range annotations {
+		if a.Node != "" {
+			byNode[a.Node] = append(byNode[a.Node], a)
+		}
+	}
+	return byNode, nil
+}
+
+// loadAnnotations returns the store's annotations that have not expired.
+func loadAnnotations(store *meb.MEBStore) ([]Annotation, error) {
+	ok, err := store.HasDocument(AnnotationsKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInternal, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	data, err := store.GetContentByKey(AnnotationsKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: load annotations: %v", errors.ErrInternal, err)
+	}
+	var annotations []Annotation
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, fmt.Errorf("%w: decode annotations: %v", errors.ErrInternal, err)
+	}
+	now := time.Now()
+	annotations = slices.DeleteFunc(annotations, func(a Annotation) bool {
+		return !a.ExpiresAt.IsZero() && !a.ExpiresAt.After(now)
+	})
+	for i := range annotations {
+		a := &annotations[i]
+		a.Node = rebindSymbol(store, a.Node, a.NodeUID)
+		a.Source = rebindSymbol(store, a.Source, a.SourceUID)
+		a.Target = rebindSymbol(store, a.Target, a.TargetUID)
+	}
+	return annotations, nil
+}
+
+// symbolUID returns the UID of the symbol id, "" for none or a node that is
+// not a symbol.
+func symbolUID(store *meb.MEBStore, id string) string {
+	if id == "" {
+		return ""
+	}
+	return gcamdb.UIDOf(context.Background(), store, id)
+}
+
+// rebindSymbol returns the ID of the symbol holding uid, or id while no one
+// symbol does.
+func rebindSymbol(store *meb.MEBStore, id, uid string) string {
+	if uid == "" {
+		return id
+	}
+	if ids := gcamdb.UIDSymbols(context.Background(), store, uid); len(ids) == 1 {
+		return ids[0]
+	}
+	return id
+}
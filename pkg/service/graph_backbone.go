@@ -3,11 +3,15 @@ package service
 import (
 	"context"
 	"fmt"
+	"iter"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/duynguyendang/gca/pkg/common"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/gca/pkg/logger"
 	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 )
@@ -19,6 +23,13 @@ func (s *GraphService) GetFileBackbone(ctx context.Context, projectID, fileID st
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
+
+	cacheKey := graphCacheKey{Project: projectID, Query: "file_backbone:" + fileID}
+	factCount := store.Count()
+	if cached, ok := s.graphCache.get(cacheKey, factCount); ok {
+		return cached, nil
+	}
 
 	cleanFileID := strings.Trim(fileID, "\"")
 
@@ -121,7 +132,10 @@ func (s *GraphService) GetFileBackbone(ctx context.Context, projectID, fileID st
 		links = append(links, l)
 	}
 
-	return &export.D3Graph{Nodes: nodes, Links: links}, nil
+	graph := &export.D3Graph{Nodes: nodes, Links: links}
+	graph.Sort()
+	s.graphCache.set(cacheKey, factCount, graph)
+	return graph, nil
 }
 
 func extractFileFromSymbolWithStore(ctx context.Context, store *meb.MEBStore, symbol string) string {
@@ -130,7 +144,7 @@ func extractFileFromSymbolWithStore(ctx context.Context, store *meb.MEBStore, sy
 		return parts[0]
 	}
 	// Use MEB O(1) lookup via defines predicate
-	for fact, err := range store.ScanContext(ctx, "", config.PredicateDefines, symbol) {
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateDefines, symbol) {
 		if err != nil {
 			continue
 		}
@@ -140,3 +154,155 @@ func extractFileFromSymbolWithStore(ctx context.Context, store *meb.MEBStore, sy
 	}
 	return ""
 }
+
+// GetBackboneGraph returns a graph containing only cross-file dependencies:
+// the calls between symbols of different files or, aggregated, between the
+// files themselves.
+//
+// The calls facts are streamed from the predicate-bound index scan and folded
+// into the graph as they arrive, without materializing query rows; the scan
+// stops once config.BackboneMaxNodes nodes are found, and the graph is marked
+// truncated. The nodes are then hydrated by parallel workers, each resolving
+// the facts of its share of them.
+func (s *GraphService) GetBackboneGraph(ctx context.Context, projectID string, aggregate bool) (*export.D3Graph, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	kinds := s.kindIndexes.get(ctx, projectID, store)
+	calls := gcamdb.Scan(ctx, store, "", config.PredicateCalls, "")
+	backbone := buildBackbone(calls, kinds, aggregate, config.BackboneMaxNodes)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	backbone.Sort()
+
+	if len(backbone.Nodes) > 0 {
+		if err := s.enrichNodesParallel(ctx, store, backbone); err != nil {
+			logger.Warn("Backbone enrichment warning", "error", err)
+		}
+	}
+
+	return backbone, nil
+}
+
+// buildBackbone folds calls facts into a backbone graph, keeping the calls
+// that cross files: between symbols, or with aggregate set between their
+// files, linked once per pair. It stops reading calls when the next one would
+// take the graph past maxNodes nodes.
+func buildBackbone(calls iter.Seq2[meb.Fact, error], kinds *gcamdb.KindIndex, aggregate bool, maxNodes int) *export.D3Graph {
+	backbone := &export.D3Graph{
+		Nodes: []export.D3Node{},
+		Links: []export.D3Link{},
+	}
+	nodeSet := make(map[string]bool)
+	fileLinks := make(map[[2]string]bool)
+	addNode := func(n export.D3Node) {
+		if !nodeSet[n.ID] {
+			nodeSet[n.ID] = true
+			backbone.Nodes = append(backbone.Nodes, n)
+		}
+	}
+
+	for fact, err := range calls {
+		if err != nil {
+			continue
+		}
+		srcID := fact.Subject
+		tgtID, ok := fact.Object.(string)
+		if !ok {
+			continue
+		}
+		srcFile, srcName, ok1 := strings.Cut(srcID, ":")
+		tgtFile, tgtName, ok2 := strings.Cut(tgtID, ":")
+		if !ok1 || !ok2 || srcFile == tgtFile {
+			continue
+		}
+
+		source, target := srcID, tgtID
+		if aggregate {
+			source, target = srcFile, tgtFile
+		}
+		added := 0
+		if !nodeSet[source] {
+			added++
+		}
+		if !nodeSet[target] {
+			added++
+		}
+		if len(nodeSet)+added > maxNodes {
+			backbone.Truncated = true
+			backbone.Warnings = append(backbone.Warnings, fmt.Sprintf("backbone stopped at %d nodes", len(nodeSet)))
+			break
+		}
+
+		if aggregate {
+			addNode(export.D3Node{
+				ID:   srcFile,
+				Name: common.ExtractBaseName(srcFile),
+				Kind: config.SymbolKindFile,
+			})
+			addNode(export.D3Node{
+				ID:   tgtFile,
+				Name: common.ExtractBaseName(tgtFile),
+				Kind: config.SymbolKindFile,
+			})
+			if pair := [2]string{srcFile, tgtFile}; !fileLinks[pair] {
+				fileLinks[pair] = true
+				backbone.Links = append(backbone.Links, export.D3Link{
+					Source:   srcFile,
+					Target:   tgtFile,
+					Relation: config.RelationCalls,
+					Weight:   1,
+				})
+			}
+			continue
+		}
+
+		addNode(export.D3Node{
+			ID:       srcID,
+			Name:     srcName,
+			Kind:     kindOr(kinds, srcID, config.SymbolKindGateway),
+			ParentID: srcFile,
+		})
+		addNode(export.D3Node{
+			ID:       tgtID,
+			Name:     tgtName,
+			Kind:     kindOr(kinds, tgtID, config.SymbolKindGateway),
+			ParentID: tgtFile,
+		})
+		backbone.Links = append(backbone.Links, export.D3Link{
+			Source:   srcID,
+			Target:   tgtID,
+			Relation: config.RelationCalls,
+		})
+	}
+	return backbone
+}
+
+// enrichNodesParallel enriches the graph's nodes as enrichNodes does lazily,
+// split across workers that hydrate their share of the nodes concurrently.
+// It returns the first error a worker met.
+func (s *GraphService) enrichNodesParallel(ctx context.Context, store *meb.MEBStore, graph *export.D3Graph) error {
+	workers := runtime.GOMAXPROCS(0)
+	size := max(config.BackboneEnrichSize, (len(graph.Nodes)+workers-1)/workers)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, (len(graph.Nodes)+size-1)/size)
+	for lo := 0; lo < len(graph.Nodes); lo += size {
+		// The parts share the graph's node array, so their enrichment lands in it
+		part := &export.D3Graph{Nodes: graph.Nodes[lo:min(lo+size, len(graph.Nodes))]}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.enrichNodes(ctx, store, part, true); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func backboneCalls() []meb.Fact {
+	return []meb.Fact{
+		{Subject: "api/server.go:Handle", Predicate: config.PredicateCalls, Object: "auth/jwt.go:Verify"},
+		{Subject: "api/server.go:Handle", Predicate: config.PredicateCalls, Object: "api/server.go:respond"},
+		{Subject: "api/server.go:Login", Predicate: config.PredicateCalls, Object: "auth/jwt.go:Sign"},
+		{Subject: "auth/jwt.go:Verify", Predicate: config.PredicateCalls, Object: "db/keys.go:Load"},
+	}
+}
+
+func TestBuildBackbone(t *testing.T) {
+	calls := func(yield func(meb.Fact, error) bool) {
+		for _, f := range backboneCalls() {
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+
+	g := buildBackbone(calls, nil, false, config.BackboneMaxNodes)
+	assert.Len(t, g.Links, 3, "calls within a file are left out")
+	assert.Len(t, g.Nodes, 5)
+	assert.Equal(t, "auth/jwt.go", g.Nodes[1].ParentID)
+	assert.False(t, g.Truncated)
+
+	g = buildBackbone(calls, nil, true, config.BackboneMaxNodes)
+	assert.Len(t, g.Nodes, 3)
+	if assert.Len(t, g.Links, 2, "files are linked once per pair") {
+		assert.Equal(t, "api/server.go", g.Links[0].Source)
+		assert.Equal(t, "auth/jwt.go", g.Links[0].Target)
+	}
+
+	g = buildBackbone(calls, nil, false, 4)
+	assert.True(t, g.Truncated)
+	assert.Len(t, g.Nodes, 4, "the scan stops before the call that would pass the cap")
+	assert.Len(t, g.Links, 2)
+	assert.NotEmpty(t, g.Warnings)
+}
+
+func TestGetBackboneGraph(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	facts := append(backboneCalls(), meb.Fact{Subject: "auth/jwt.go:Verify", Predicate: config.PredicateHasKind, Object: "func"})
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+
+	g, err := svc.GetBackboneGraph(context.Background(), "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, g.Links, 3)
+	i := slices.IndexFunc(g.Nodes, func(n export.D3Node) bool { return n.ID == "auth/jwt.go:Verify" })
+	if assert.GreaterOrEqual(t, i, 0) {
+		assert.Equal(t, "func", g.Nodes[i].Kind)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := svc.GetBackboneGraph(ctx, "test", true); err == nil {
+		t.Error("GetBackboneGraph with a canceled context succeeded")
+	}
+}
+
+func BenchmarkBuildBackbone(b *testing.B) {
+	const edges = 1_000_000
+	calls := func(yield func(meb.Fact, error) bool) {
+		for i := range edges {
+			f := meb.Fact{
+				Subject:   fmt.Sprintf("pkg%d/f%d.go:F%d", i%97, i%1009, i%4999),
+				Predicate: config.PredicateCalls,
+				Object:    fmt.Sprintf("pkg%d/f%d.go:G%d", i%89, i%1013, i%4993),
+			}
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+	for b.Loop() {
+		buildBackbone(calls, nil, true, config.BackboneMaxNodes)
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/datalog"
+	"github.com/duynguyendang/gca/pkg/export"
+)
+
+// graphCacheKey identifies a cached graph. Query holds either a normalized Datalog
+// query or a view name such as "project_map" for graphs built without one.
+type graphCacheKey struct {
+	Project string
+	Query   string
+	Hydrate bool
+	Lazy    bool
+	Limit   int
+	AsOf    int
+}
+
+type graphCacheEntry struct {
+	graph     *export.D3Graph
+	factCount uint64
+	expiresAt time.Time
+	createdAt time.Time
+}
+
+// graphCache memoizes exported graphs per project. Entries expire after a TTL and are
+// discarded as soon as the project's fact count changes, which happens on every ingest.
+// Graphs are cloned on the way in and out so callers can mutate what they receive.
+type graphCache struct {
+	mu      sync.Mutex
+	entries map[graphCacheKey]*graphCacheEntry
+	ttl     time.Duration
+	maxSize int
+}
+
+func newGraphCache(ttl time.Duration, maxSize int) *graphCache {
+	return &graphCache{
+		entries: make(map[graphCacheKey]*graphCacheEntry),
+		ttl:     ttl,
+		maxSize: maxSize,
+	}
+}
+
+// get returns a copy of the cached graph if it is fresh and was built from a store
+// holding factCount facts. A nil cache never hits.
+func (c *graphCache) get(key graphCacheKey, factCount uint64) (*export.D3Graph, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if entry.factCount != factCount || time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return cloneGraph(entry.graph), true
+}
+
+func (c *graphCache) set(key graphCacheKey, factCount uint64, graph *export.D3Graph) {
+	if c == nil || graph == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxSize {
+		c.evictOldest()
+	}
+	now := time.Now()
+	c.entries[key] = &graphCacheEntry{
+		graph:     cloneGraph(graph),
+		factCount: factCount,
+		expiresAt: now.Add(c.ttl),
+		createdAt: now,
+	}
+}
+
+// invalidate drops projectID's graphs, for changes that leave its fact count
+// as it was, such as new fact weights.
+func (c *graphCache) invalidate(projectID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.Project == projectID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *graphCache) evictOldest() {
+	var oldestKey graphCacheKey
+	var oldest *graphCacheEntry
+	for key, entry := range c.entries {
+		if oldest == nil || entry.createdAt.Before(oldest.createdAt) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	if oldest != nil {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// manifestCache holds one complete manifest per project, valid while the project's
+// fact count is unchanged.
+type manifestCache struct {
+	mu      sync.Mutex
+	entries map[string]manifestCacheEntry
+}
+
+type manifestCacheEntry struct {
+	manifest  map[string]interface{}
+	factCount uint64
+}
+
+func newManifestCache() *manifestCache {
+	return &manifestCache{entries: make(map[string]manifestCacheEntry)}
+}
+
+// get returns the cached manifest for projectID if it was built at factCount. A nil cache never hits.
+func (c *manifestCache) get(projectID string, factCount uint64) (map[string]interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[projectID]
+	if !ok || entry.factCount != factCount {
+		return nil, false
+	}
+	return entry.manifest, true
+}
+
+func (c *manifestCache) set(projectID string, factCount uint64, manifest map[string]interface{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[projectID] = manifestCacheEntry{manifest: manifest, factCount: factCount}
+}
+
+// normalizeQuery canonicalizes a Datalog query so that formatting differences
+// (whitespace, quote style, trailing dot) share a cache entry.
+func normalizeQuery(query string) string {
+	atoms, err := datalog.Parse(query)
+	if err != nil {
+		return strings.Join(strings.Fields(query), " ")
+	}
+	return joinAtoms(atoms)
+}
+
+// cloneGraph copies a graph deeply enough that mutating node fields, node metadata
+// or the node/link slices of the copy leaves the original untouched.
+func cloneGraph(g *export.D3Graph) *export.D3Graph {
+	out := *g
+	out.Nodes = make([]export.D3Node, len(g.Nodes))
+	for i, n := range g.Nodes {
+		if n.Metadata != nil {
+			md := make(map[string]string, len(n.Metadata))
+			for k, v := range n.Metadata {
+				md[k] = v
+			}
+			n.Metadata = md
+		}
+		out.Nodes[i] = n
+	}
+	out.Links = append([]export.D3Link(nil), g.Links...)
+	if out.Links == nil {
+		out.Links = []export.D3Link{}
+	}
+	out.Warnings = append([]string(nil), g.Warnings...)
+	return &out
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestExportGraphCache(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.AddFact(meb.Fact{Subject: "a.go", Predicate: "imports", Object: "b.go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+
+	g1, err := svc.ExportGraph(ctx, "test", `triples(?s, "imports", ?o)`, false, false)
+	if err != nil {
+		t.Fatalf("ExportGraph: %v", err)
+	}
+	if len(g1.Nodes) != 2 {
+		t.Fatalf("got %d nodes, want 2", len(g1.Nodes))
+	}
+	// Mutating a returned graph must not leak into the cache
+	g1.Nodes = g1.Nodes[:0]
+
+	// Formatting differences share the cache entry
+	g2, err := svc.ExportGraph(ctx, "test", `triples(?s,'imports',?o).`, false, false)
+	if err != nil {
+		t.Fatalf("ExportGraph: %v", err)
+	}
+	if len(g2.Nodes) != 2 {
+		t.Errorf("cached graph has %d nodes, want 2", len(g2.Nodes))
+	}
+	if len(svc.graphCache.entries) != 1 {
+		t.Errorf("cache has %d entries, want 1", len(svc.graphCache.entries))
+	}
+
+	// A new fact changes the fact count and invalidates the entry
+	if err := s.AddFact(meb.Fact{Subject: "b.go", Predicate: "imports", Object: "c.go"}); err != nil {
+		t.Fatal(err)
+	}
+	g3, err := svc.ExportGraph(ctx, "test", `triples(?s, "imports", ?o)`, false, false)
+	if err != nil {
+		t.Fatalf("ExportGraph: %v", err)
+	}
+	if len(g3.Nodes) != 3 {
+		t.Errorf("after ingest got %d nodes, want 3", len(g3.Nodes))
+	}
+}
+
+func TestGetManifestCollisionsAndCache(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	facts := []meb.Fact{
+		{Subject: "cmd/a/main.go", Predicate: "defines", Object: "cmd/a/main.go:main"},
+		{Subject: "cmd/b/main.go", Predicate: "defines", Object: "cmd/b/main.go:main"},
+		{Subject: "pkg/x.go", Predicate: "defines", Object: "pkg/x.go:Server.Start"},
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+
+	manifest, _, err := svc.GetManifest(ctx, "test", gcamdb.ScanOptions{})
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	symbols := manifest["S"].(map[string][]string)
+	if want := []string{"cmd/a/main.go:main", "cmd/b/main.go:main"}; !reflect.DeepEqual(symbols["main"], want) {
+		t.Errorf("S[main] = %v, want %v", symbols["main"], want)
+	}
+	if want := []string{"pkg/x.go:Server.Start"}; !reflect.DeepEqual(symbols["Start"], want) {
+		t.Errorf("S[Start] = %v, want %v", symbols["Start"], want)
+	}
+
+	// A new ingest invalidates the cached manifest
+	if err := s.AddFact(meb.Fact{Subject: "cmd/c/main.go", Predicate: "defines", Object: "cmd/c/main.go:main"}); err != nil {
+		t.Fatal(err)
+	}
+	manifest, _, err = svc.GetManifest(ctx, "test", gcamdb.ScanOptions{})
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	if got := manifest["S"].(map[string][]string)["main"]; len(got) != 3 {
+		t.Errorf("after ingest S[main] = %v, want 3 entries", got)
+	}
+}
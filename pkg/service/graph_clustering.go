@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/export"
 	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
 )
 
 // CommunityHierarchy represents a hierarchical community structure.
@@ -33,28 +36,39 @@ type HybridCluster struct {
 	Members []string `json:"members"`
 }
 
-// DetectCommunityHierarchy runs the Leiden algorithm on the graph and returns a hierarchical structure.
-func (s *GraphService) DetectCommunityHierarchy(ctx context.Context, projectID string) (*CommunityHierarchy, error) {
-	graph, err := s.ExportGraph(ctx, projectID, "", false, false)
+// DetectCommunityHierarchy runs the Leiden algorithm, seeded with seed, on the
+// project's structural graph and returns a hierarchical structure. The graph
+// is read from the project's adjacency snapshot, or scanned from the store
+// when it has no fresh one.
+func (s *GraphService) DetectCommunityHierarchy(ctx context.Context, projectID string, seed int64) (*CommunityHierarchy, error) {
+	store, err := s.getStore(projectID)
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
+	adj := s.adjacency(ctx, projectID, store)
+	if adj == nil {
+		adj = gcamdb.BuildAdjacency(ctx, store, store.Count())
+	}
 
-	if len(graph.Nodes) == 0 {
+	if len(adj.Nodes()) == 0 {
 		return &CommunityHierarchy{Levels: []CommunityLevel{}}, nil
 	}
 
-	nodes := make([]GraphNode, len(graph.Nodes))
-	for i, n := range graph.Nodes {
-		nodes[i] = GraphNode{ID: n.ID, Name: n.Name, Kind: n.Kind}
+	kinds := s.kindIndexes.get(ctx, projectID, store)
+	nodes := make([]GraphNode, len(adj.Nodes()))
+	for i, id := range adj.Nodes() {
+		nodes[i] = GraphNode{ID: id, Kind: kinds.Kind(id)}
 	}
 
-	links := make([]GraphLink, len(graph.Links))
-	for i, l := range graph.Links {
-		links[i] = GraphLink{Source: l.Source, Target: l.Target}
+	var links []GraphLink
+	for _, pred := range adj.Predicates() {
+		for src, dst := range adj.Edges(pred) {
+			links = append(links, GraphLink{Source: src, Target: dst})
+		}
 	}
 
-	clusteringSvc := NewClusteringService()
+	clusteringSvc := &ClusteringService{Seed: seed}
 	result := clusteringSvc.DetectCommunitiesLeiden(nodes, links)
 
 	hierarchy := &CommunityHierarchy{
@@ -70,6 +84,7 @@ func (s *GraphService) DetectCommunityHierarchy(ctx context.Context, projectID s
 	for id := range membersByCluster {
 		commIDs = append(commIDs, id)
 	}
+	sort.Ints(commIDs)
 
 	hierarchy.Levels[0] = CommunityLevel{
 		ID:          0,
@@ -85,6 +100,7 @@ func (s *GraphService) GetHybridClusters(ctx context.Context, projectID string,
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	results, err := store.Find().
 		SimilarTo(queryEmbedding).
@@ -94,6 +110,20 @@ func (s *GraphService) GetHybridClusters(ctx context.Context, projectID string,
 		return nil, fmt.Errorf("vector search failed: %w", err)
 	}
 
+	var scoped []meb.Result
+	for _, r := range results {
+		if gcamdb.IsDocNode(r.Key) {
+			continue
+		}
+		for _, id := range gcamdb.VectorSymbols(ctx, store, r.Key) {
+			if gcamdb.InScope(ctx, id) {
+				r.Key = id
+				scoped = append(scoped, r)
+			}
+		}
+	}
+	results = scoped
+
 	if len(results) == 0 {
 		return &HybridClusteringResult{}, nil
 	}
@@ -113,17 +143,19 @@ func (s *GraphService) GetHybridClusters(ctx context.Context, projectID string,
 }
 
 // GetClusterGraph applies Leiden clustering to reduce large graphs.
-func (s *GraphService) GetClusterGraph(ctx context.Context, projectID, query string) (*export.D3Graph, error) {
+func (s *GraphService) GetClusterGraph(ctx context.Context, projectID, query string, seed int64) (*export.D3Graph, error) {
 	fullGraph, err := s.ExportGraph(ctx, projectID, query, true, false)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.ClusterGraphData(fullGraph)
+	return s.ClusterGraphData(fullGraph, seed)
 }
 
-// ClusterGraphData takes an existing D3Graph and applies clustering to it.
-func (s *GraphService) ClusterGraphData(fullGraph *export.D3Graph) (*export.D3Graph, error) {
+// ClusterGraphData takes an existing D3Graph and applies clustering to it,
+// seeding Leiden with seed: the same graph and seed give the same clusters,
+// under the same IDs.
+func (s *GraphService) ClusterGraphData(fullGraph *export.D3Graph, seed int64) (*export.D3Graph, error) {
 	logger.Debug("ClusterGraphData starting", "nodes", len(fullGraph.Nodes), "links", len(fullGraph.Links))
 
 	if len(fullGraph.Nodes) == 0 {
@@ -151,7 +183,7 @@ func (s *GraphService) ClusterGraphData(fullGraph *export.D3Graph) (*export.D3Gr
 		}
 	}
 
-	clusteringSvc := NewClusteringService()
+	clusteringSvc := &ClusteringService{Seed: seed}
 	logger.Debug("Running Leiden algorithm")
 	result := clusteringSvc.DetectCommunitiesLeiden(nodes, links)
 	logger.Debug("Leiden returned clusters", "clusters", len(result.Clusters))
@@ -233,8 +265,10 @@ func (s *GraphService) ClusterGraphData(fullGraph *export.D3Graph) (*export.D3Gr
 		})
 	}
 
-	return &export.D3Graph{
+	clustered := &export.D3Graph{
 		Nodes: superNodes,
 		Links: superLinks,
-	}, nil
+	}
+	clustered.Sort()
+	return clustered, nil
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// ScoreLinks sets the confidence of the graph's inferred links: those ingest
+// recorded evidence for, scored by how often and by which method they were
+// found, and the virtual links of annotations. A link users confirmed scores 1
+// and one they rejected 0; every verdict also tunes the precision of its
+// method. When minConfidence is positive, scored links below it are dropped;
+// extracted links carry no score and are always kept.
+func (s *GraphService) ScoreLinks(projectID string, graph *export.D3Graph, minConfidence float64) error {
+	if graph == nil || len(graph.Links) == 0 {
+		return nil
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return err
+	}
+	evidence, err := gcamdb.LinkEvidenceFor(store)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errors.ErrInternal, err)
+	}
+	annotations, err := loadAnnotations(store)
+	if err != nil {
+		return err
+	}
+
+	// Verdicts by edge, the latest standing; one without a relation covers
+	// every link between its endpoints
+	type edgeKey struct{ source, target, relation string }
+	verdicts := make(map[edgeKey]string)
+	virtual := make(map[edgeKey]bool)
+	for _, a := range annotations {
+		if a.Virtual {
+			virtual[edgeKey{a.Source, a.Target, a.Relation}] = true
+		}
+		if a.Verdict != "" {
+			verdicts[edgeKey{a.Source, a.Target, a.Relation}] = a.Verdict
+		}
+	}
+	confirmed := make(map[string]int)
+	rejected := make(map[string]int)
+	for k, verdict := range verdicts {
+		methods := map[string]bool{}
+		for _, e := range evidence {
+			if e.Subject == k.source && e.Object == k.target && (k.relation == "" || e.Predicate == k.relation) {
+				methods[e.Method] = true
+			}
+		}
+		if virtual[k] {
+			methods[gcamdb.LinkMethodManual] = true
+		}
+		for m := range methods {
+			if verdict == VerdictConfirmed {
+				confirmed[m]++
+			} else {
+				rejected[m]++
+			}
+		}
+	}
+	precision := func(method string) float64 {
+		return gcamdb.MethodPrecision(method, confirmed[method], rejected[method])
+	}
+
+	kept := graph.Links[:0]
+	for _, l := range graph.Links {
+		scored := true
+		verdict, ok := verdicts[edgeKey{l.Source, l.Target, l.Relation}]
+		if !ok {
+			verdict, ok = verdicts[edgeKey{l.Source, l.Target, ""}]
+		}
+		e, inferred := evidence[gcamdb.LinkKey(l.Source, l.Relation, l.Target)]
+		switch {
+		case ok && verdict == VerdictConfirmed:
+			l.Confidence = 1
+		case ok:
+			l.Confidence = 0
+		case inferred:
+			l.Confidence = gcamdb.LinkConfidence(precision(e.Method), e.Evidence)
+		case l.SourceProvenance == VirtualLinkProvenance:
+			l.Confidence = precision(gcamdb.LinkMethodManual)
+		default:
+			scored = l.Confidence > 0
+		}
+		if minConfidence > 0 && scored && l.Confidence < minConfidence {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	graph.Links = kept
+	return nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// DocSearchResult is a doc comment matching a documentation search, with the
+// symbol it documents.
+type DocSearchResult struct {
+	DocID    string  `json:"doc_id"`
+	SymbolID string  `json:"symbol_id"`
+	Name     string  `json:"name,omitempty"`
+	Doc      string  `json:"doc"`
+	Score    float32 `json:"score"`
+}
+
+// DocSearch ranks the project's doc nodes against query, apart from code:
+// by keyword over the doc comments alone and, given an embedder, by vector
+// similarity restricted to doc nodes. The two rankings are merged by
+// reciprocal rank fusion. It reports whether vector search took part.
+func (s *GraphService) DocSearch(ctx context.Context, projectID, query string, k int, embedder interface {
+	GetEmbedding(ctx context.Context, text string) ([]float32, error)
+}) ([]DocSearchResult, bool, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	scores := make(map[string]float64)
+	fuse := func(rank int, docID string) {
+		scores[docID] += 1 / float64(config.DocSearchRankOffset+rank+1)
+	}
+
+	idx, err := s.docIndexes.get(ctx, projectID, store)
+	if err != nil {
+		return nil, false, err
+	}
+	for rank, h := range idx.Search(query, k, func(id string) bool { return gcamdb.InScope(ctx, id) }) {
+		fuse(rank, h.ID)
+	}
+
+	vectors := false
+	if embedder != nil {
+		if embedding, err := embedder.GetEmbedding(ctx, query); err != nil {
+			logger.Warn("Doc search could not embed the query, using keywords only", "project", projectID, "error", err)
+		} else {
+			rank := 0
+			for vr, err := range store.Vectors().Search(embedding, k*config.DocSearchVectorDepth) {
+				if err != nil || rank == k {
+					break
+				}
+				docID, err := store.ResolveID(vr.ID)
+				if err != nil || !gcamdb.IsDocNode(docID) || !gcamdb.InScope(ctx, docID) {
+					continue
+				}
+				fuse(rank, docID)
+				rank++
+				vectors = true
+			}
+		}
+	}
+
+	results := make([]DocSearchResult, 0, len(scores))
+	for docID, score := range scores {
+		symbolID := gcamdb.DocNodeSymbol(docID)
+		results = append(results, DocSearchResult{
+			DocID:    docID,
+			SymbolID: symbolID,
+			Name:     symbolShortName(symbolID),
+			Score:    float32(score),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].DocID < results[j].DocID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	for i := range results {
+		for fact, err := range gcamdb.Scan(ctx, store, results[i].SymbolID, config.PredicateHasDoc, "") {
+			if doc, ok := fact.Object.(string); err == nil && ok {
+				results[i].Doc = doc
+				break
+			}
+		}
+	}
+	return results, vectors, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// DuplicateGroup is a set of functions linked by near-duplicate pairs.
+type DuplicateGroup struct {
+	Symbols []string             `json:"symbols"`
+	Pairs   []gcamdb.SimilarPair `json:"pairs"`
+	Score   float64              `json:"score"` // Highest similarity of its pairs
+}
+
+// GetDuplicates groups the near-duplicate functions tagged at ingest: each
+// group holds the functions its pairs of at least minScore link, directly or
+// through each other. Larger groups come first, then more similar ones; at
+// most limit are returned.
+func (s *GraphService) GetDuplicates(ctx context.Context, projectID string, minScore float64, limit int) ([]DuplicateGroup, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	pairs, err := gcamdb.SimilarPairs(ctx, store)
+	if err != nil {
+		return nil, fmt.Errorf("%w: load duplicates: %v", errors.ErrInternal, err)
+	}
+
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(id string) string {
+		if parent[id] == id {
+			return id
+		}
+		root := find(parent[id])
+		parent[id] = root
+		return root
+	}
+	var kept []gcamdb.SimilarPair
+	for _, p := range pairs {
+		if p.Score < minScore {
+			continue
+		}
+		for _, id := range []string{p.A, p.B} {
+			if _, ok := parent[id]; !ok {
+				parent[id] = id
+			}
+		}
+		if a, b := find(p.A), find(p.B); a != b {
+			parent[max(a, b)] = min(a, b)
+		}
+		kept = append(kept, p)
+	}
+
+	byRoot := make(map[string]*DuplicateGroup)
+	for _, p := range kept {
+		root := find(p.A)
+		g, ok := byRoot[root]
+		if !ok {
+			g = &DuplicateGroup{}
+			byRoot[root] = g
+		}
+		g.Pairs = append(g.Pairs, p)
+		g.Score = max(g.Score, p.Score)
+	}
+	for id := range parent {
+		g := byRoot[find(id)]
+		g.Symbols = append(g.Symbols, id)
+	}
+
+	groups := make([]DuplicateGroup, 0, len(byRoot))
+	for _, g := range byRoot {
+		sort.Strings(g.Symbols)
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Symbols) != len(groups[j].Symbols) {
+			return len(groups[i].Symbols) > len(groups[j].Symbols)
+		}
+		if groups[i].Score != groups[j].Score {
+			return groups[i].Score > groups[j].Score
+		}
+		return groups[i].Symbols[0] < groups[j].Symbols[0]
+	})
+	return groups[:min(limit, len(groups))], nil
+}
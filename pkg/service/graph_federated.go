@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/datalog"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// ProjectVariable is the pseudo-variable that federated query rows bind to the
+// project each row came from. Constraints on it, such as
+// starts_with(?project, "frontend"), select which projects are queried.
+const ProjectVariable = "?project"
+
+// ExecuteFederatedQuery runs query against every project in projectIDs and merges
+// the rows, tagging each with ProjectVariable.
+func (s *GraphService) ExecuteFederatedQuery(ctx context.Context, projectIDs []string, query string) (*gcamdb.QueryResult, error) {
+	return s.ExecuteFederatedQueryWithOptions(ctx, projectIDs, query, gcamdb.QueryOptions{})
+}
+
+// ExecuteFederatedQueryWithOptions is ExecuteFederatedQuery with a row limit and
+// deadline. Each project runs under the same options, concurrently, and the merged
+// rows are capped at the limit. Rows are ordered by project, in projectIDs order.
+// A query with several triples atoms joins facts across projects, as described
+// at joinAcrossProjects.
+func (s *GraphService) ExecuteFederatedQueryWithOptions(ctx context.Context, projectIDs []string, query string, opts gcamdb.QueryOptions) (*gcamdb.QueryResult, error) {
+	if len(projectIDs) == 0 {
+		return nil, fmt.Errorf("%w: no projects to query", errors.ErrInvalidInput)
+	}
+
+	atoms, err := datalog.Parse(query)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	// ?project is never bound inside a store, so its constraints are applied here
+	var projectConstraints, storeAtoms []datalog.Atom
+	for _, atom := range atoms {
+		if atom.Predicate != "triples" && slices.Contains(atom.Args, ProjectVariable) {
+			projectConstraints = append(projectConstraints, atom)
+		} else {
+			storeAtoms = append(storeAtoms, atom)
+		}
+	}
+	storeQuery := joinAtoms(storeAtoms)
+
+	// Skip projects that constraints on ?project alone already rule out
+	var selected []string
+	for _, id := range dedupe(projectIDs) {
+		if gcamdb.MatchesConstraints(map[string]any{ProjectVariable: id}, projectOnly(projectConstraints)) {
+			selected = append(selected, id)
+		}
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = config.QueryResultLimit
+	}
+	limit = min(limit, config.QueryMaxResultLimit)
+
+	triples := 0
+	for _, atom := range storeAtoms {
+		if atom.Predicate == "triples" {
+			triples++
+		}
+	}
+	if len(selected) > 1 && triples > 1 {
+		return s.joinAcrossProjects(ctx, selected, storeAtoms, projectConstraints, opts, limit)
+	}
+
+	results, err := s.queryProjects(ctx, selected, storeQuery, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &gcamdb.QueryResult{Rows: []map[string]any{}}
+	for i, id := range selected {
+		res := results[i]
+		merged.TimedOut = merged.TimedOut || res.TimedOut
+		merged.Truncated = merged.Truncated || res.Truncated
+		for _, w := range res.Warnings {
+			merged.Warnings = append(merged.Warnings, fmt.Sprintf("%s: %s", id, w))
+		}
+		for _, row := range res.Rows {
+			// Rows may be shared with the query cache; tag a copy
+			tagged := make(map[string]any, len(row)+1)
+			for k, v := range row {
+				tagged[k] = v
+			}
+			tagged[ProjectVariable] = id
+			if !gcamdb.MatchesConstraints(tagged, projectConstraints) {
+				continue
+			}
+			if len(merged.Rows) >= limit {
+				merged.Truncated = true
+				break
+			}
+			merged.Rows = append(merged.Rows, tagged)
+		}
+	}
+	if merged.Truncated && len(merged.Rows) >= limit {
+		merged.Warnings = append(merged.Warnings, fmt.Sprintf("result limit of %d rows reached across projects; results may be incomplete", limit))
+	}
+	return merged, nil
+}
+
+// queryProjects runs query in each project, concurrently, and returns the
+// results in projects order.
+func (s *GraphService) queryProjects(ctx context.Context, projects []string, query string, opts gcamdb.QueryOptions) ([]*gcamdb.QueryResult, error) {
+	results := make([]*gcamdb.QueryResult, len(projects))
+	errs := make([]error, len(projects))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, config.MaxWorkers)
+	for i, id := range projects {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = s.ExecuteQueryWithOptions(ctx, id, query, opts)
+		}(i, id)
+	}
+	wg.Wait()
+	for i, id := range projects {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("project %s: %w", id, errs[i])
+		}
+	}
+	return results, nil
+}
+
+// joinAcrossProjects evaluates each triples atom of a federated query in every
+// project and joins the bindings on the variables the atoms share, so a fact in
+// one project can join a fact in another, such as a frontend calls_api fact and
+// the backend handled_by fact of the same route. Constraints over one atom's
+// variables are evaluated with that atom in each store; the others are applied
+// to the joined rows. A row's ?project is the project of the fact bound by the
+// first triples atom.
+func (s *GraphService) joinAcrossProjects(ctx context.Context, projects []string, atoms, projectConstraints []datalog.Atom, opts gcamdb.QueryOptions, limit int) (*gcamdb.QueryResult, error) {
+	var triples, constraints []datalog.Atom
+	for _, atom := range atoms {
+		if atom.Predicate == "triples" {
+			triples = append(triples, atom)
+		} else {
+			constraints = append(constraints, atom)
+		}
+	}
+
+	merged := &gcamdb.QueryResult{Rows: []map[string]any{}}
+	pushed := make([]bool, len(constraints))
+	var rows []map[string]any
+	bound := make(map[string]bool)
+	for i, atom := range triples {
+		vars := atomVariables(atom)
+		part := []datalog.Atom{atom}
+		for j, c := range constraints {
+			if !pushed[j] && coveredBy(c, vars) {
+				part = append(part, c)
+				pushed[j] = true
+			}
+		}
+		// The join needs every binding of the atom, not just the first page
+		partOpts := opts
+		partOpts.Limit = config.QueryMaxResultLimit
+		partOpts.Provenance = opts.Provenance && i == 0
+		results, err := s.queryProjects(ctx, projects, joinAtoms(part), partOpts)
+		if err != nil {
+			return nil, err
+		}
+		var bindings []map[string]any
+		for k, id := range projects {
+			res := results[k]
+			merged.TimedOut = merged.TimedOut || res.TimedOut
+			merged.Truncated = merged.Truncated || res.Truncated
+			for _, w := range res.Warnings {
+				merged.Warnings = append(merged.Warnings, fmt.Sprintf("%s: %s", id, w))
+			}
+			for _, row := range res.Rows {
+				if i == 0 {
+					// Rows may be shared with the query cache; tag a copy
+					tagged := make(map[string]any, len(row)+1)
+					for k, v := range row {
+						tagged[k] = v
+					}
+					tagged[ProjectVariable] = id
+					row = tagged
+				}
+				bindings = append(bindings, row)
+			}
+		}
+		if i == 0 {
+			rows = bindings
+		} else {
+			var shared []string
+			for _, v := range vars {
+				if bound[v] {
+					shared = append(shared, v)
+				}
+			}
+			var capped bool
+			rows, capped = hashJoin(rows, bindings, shared, config.QueryMaxResultLimit)
+			merged.Truncated = merged.Truncated || capped
+		}
+		for _, v := range vars {
+			bound[v] = true
+		}
+	}
+
+	var rest []datalog.Atom
+	for j, c := range constraints {
+		if pushed[j] {
+			continue
+		}
+		if strings.HasPrefix(c.Predicate, "weight_") {
+			// Weights live in the store holding the fact, which a cross-atom row lacks
+			merged.Warnings = append(merged.Warnings, fmt.Sprintf("%s spans several triples atoms and is not applied across projects", c.String()))
+			continue
+		}
+		rest = append(rest, c)
+	}
+	rest = append(rest, projectConstraints...)
+	for _, row := range rows {
+		if !gcamdb.MatchesConstraints(row, rest) {
+			continue
+		}
+		if len(merged.Rows) >= limit {
+			merged.Truncated = true
+			break
+		}
+		merged.Rows = append(merged.Rows, row)
+	}
+	if merged.Truncated && len(merged.Rows) >= limit {
+		merged.Warnings = append(merged.Warnings, fmt.Sprintf("result limit of %d rows reached across projects; results may be incomplete", limit))
+	}
+	return merged, nil
+}
+
+// hashJoin joins left and right rows that agree on every shared variable, with
+// no shared variables giving their cross product. It stops at maxRows rows and
+// reports whether it did.
+func hashJoin(left, right []map[string]any, shared []string, maxRows int) ([]map[string]any, bool) {
+	index := make(map[string][]map[string]any, len(right))
+	for _, row := range right {
+		key := joinKey(row, shared)
+		index[key] = append(index[key], row)
+	}
+	var out []map[string]any
+	for _, l := range left {
+		for _, r := range index[joinKey(l, shared)] {
+			if len(out) >= maxRows {
+				return out, true
+			}
+			joined := make(map[string]any, len(l)+len(r))
+			for k, v := range r {
+				joined[k] = v
+			}
+			for k, v := range l {
+				joined[k] = v
+			}
+			out = append(out, joined)
+		}
+	}
+	return out, false
+}
+
+// joinKey renders the values row binds to vars as one map key.
+func joinKey(row map[string]any, vars []string) string {
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "%v\x00", row[v])
+	}
+	return b.String()
+}
+
+// atomVariables returns the variables among atom's arguments.
+func atomVariables(atom datalog.Atom) []string {
+	var vars []string
+	for _, arg := range atom.Args {
+		if strings.HasPrefix(arg, "?") && !slices.Contains(vars, arg) {
+			vars = append(vars, arg)
+		}
+	}
+	return vars
+}
+
+// coveredBy reports whether every variable of constraint is in vars. A
+// constraint on ProjectVariable never is, since no store binds it.
+func coveredBy(constraint datalog.Atom, vars []string) bool {
+	for _, arg := range constraint.Args {
+		if strings.HasPrefix(arg, "?") && !slices.Contains(vars, arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// projectOnly returns the constraints whose only variable is ProjectVariable.
+func projectOnly(constraints []datalog.Atom) []datalog.Atom {
+	var out []datalog.Atom
+	for _, atom := range constraints {
+		only := true
+		for _, arg := range atom.Args {
+			if arg != ProjectVariable && strings.HasPrefix(arg, "?") {
+				only = false
+				break
+			}
+		}
+		if only {
+			out = append(out, atom)
+		}
+	}
+	return out
+}
+
+func joinAtoms(atoms []datalog.Atom) string {
+	parts := make([]string, len(atoms))
+	for i, atom := range atoms {
+		parts[i] = atom.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func dedupe(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
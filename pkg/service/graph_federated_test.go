@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/duynguyendang/gca/internal/manager"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+// multiStoreManager serves a separate store per project ID.
+type multiStoreManager struct {
+	stores map[string]*meb.MEBStore
+}
+
+func (m *multiStoreManager) GetStore(id string) (*meb.MEBStore, error) {
+	if s, ok := m.stores[id]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("project not found: %s", id)
+}
+
+func (m *multiStoreManager) ListProjects() ([]manager.ProjectMetadata, error) {
+	return nil, nil
+}
+
+func TestExecuteFederatedQuery(t *testing.T) {
+	facts := map[string][]meb.Fact{
+		"frontend": {
+			{Subject: "web/app.ts:load", Predicate: "calls", Object: "api/users.go:List"},
+			{Subject: "web/app.ts:load", Predicate: "calls_api", Object: "GET /users"},
+		},
+		"backend": {
+			{Subject: "api/users.go:List", Predicate: "calls", Object: "db/users.go:Query"},
+			{Subject: "api/users.go:Get", Predicate: "calls", Object: "db/users.go:Query"},
+			{Subject: "GET /users", Predicate: "handled_by", Object: "api/users.go:List"},
+			{Subject: "GET /users/:id", Predicate: "handled_by", Object: "api/users.go:Get"},
+		},
+	}
+	mgr := &multiStoreManager{stores: make(map[string]*meb.MEBStore)}
+	for id, fs := range facts {
+		s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		if err := s.AddFactBatch(fs); err != nil {
+			t.Fatal(err)
+		}
+		mgr.stores[id] = s
+	}
+	svc := NewGraphService(mgr)
+	ctx := context.Background()
+
+	res, err := svc.ExecuteFederatedQuery(ctx, []string{"frontend", "backend"}, `triples(?s, "calls", ?o)`)
+	if err != nil {
+		t.Fatalf("ExecuteFederatedQuery: %v", err)
+	}
+	perProject := make(map[string]int)
+	for _, row := range res.Rows {
+		perProject[row[ProjectVariable].(string)]++
+	}
+	if perProject["frontend"] != 1 || perProject["backend"] != 2 {
+		t.Errorf("rows per project = %v, want frontend:1 backend:2", perProject)
+	}
+
+	// Constraints on ?project pick the stores to query
+	res, err = svc.ExecuteFederatedQuery(ctx, []string{"frontend", "backend"}, `triples(?s, "calls", ?o), starts_with(?project, "back"), ?s != "api/users.go:Get"`)
+	if err != nil {
+		t.Fatalf("ExecuteFederatedQuery: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0][ProjectVariable] != "backend" || res.Rows[0]["?s"] != "api/users.go:List" {
+		t.Errorf("filtered rows = %v, want the backend List call only", res.Rows)
+	}
+
+	// The merged result honors the row limit
+	res, err = svc.ExecuteFederatedQueryWithOptions(ctx, []string{"frontend", "backend"}, `triples(?s, "calls", ?o)`, gcamdb.QueryOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("ExecuteFederatedQueryWithOptions: %v", err)
+	}
+	if len(res.Rows) != 2 || !res.Truncated {
+		t.Errorf("limited result = %d rows, truncated=%v; want 2 rows, truncated", len(res.Rows), res.Truncated)
+	}
+
+	// Triples atoms join on shared variables across projects
+	res, err = svc.ExecuteFederatedQuery(ctx, []string{"frontend", "backend"}, `triples(?page, "calls_api", ?route), triples(?route, "handled_by", ?h), ?h != "api/users.go:Get"`)
+	if err != nil {
+		t.Fatalf("ExecuteFederatedQuery: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0]["?page"] != "web/app.ts:load" || res.Rows[0]["?h"] != "api/users.go:List" || res.Rows[0][ProjectVariable] != "frontend" {
+		t.Errorf("joined rows = %v, want the frontend page joined to the backend List handler", res.Rows)
+	}
+
+	// A join that no project could answer alone still needs a fact from each
+	res, err = svc.ExecuteFederatedQuery(ctx, []string{"frontend", "backend"}, `triples(?page, "calls_api", ?route), triples(?route, "handled_by", ?h), triples(?h, "calls", ?q)`)
+	if err != nil {
+		t.Fatalf("ExecuteFederatedQuery: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0]["?q"] != "db/users.go:Query" {
+		t.Errorf("three-way join rows = %v, want one row reaching db/users.go:Query", res.Rows)
+	}
+
+	if _, err := svc.ExecuteFederatedQuery(ctx, []string{"frontend", "missing"}, `triples(?s, "calls", ?o)`); err == nil {
+		t.Error("expected an error for an unknown project")
+	}
+}
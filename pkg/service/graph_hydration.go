@@ -2,183 +2,373 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/duynguyendang/gca/pkg/common/deadline"
+	"github.com/duynguyendang/gca/pkg/common/errors"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 )
 
-func (s *GraphService) HydrateShallow(ctx context.Context, store *meb.MEBStore, ids []string) ([]HydratedSymbol, error) {
-	hydrated := make([]HydratedSymbol, 0, len(ids))
+// HydrateFields selects which parts of a symbol HydrateWithFields fills in.
+type HydrateFields uint8
 
-	for _, id := range ids {
-		hs := HydratedSymbol{ID: id, Metadata: make(map[string]interface{})}
+const (
+	HydrateMetadata HydrateFields = 1 << iota // kind, language, line range, owners, vulnerabilities, summary
+	HydrateContent                            // source code
+	HydrateChildren                           // shallow symbols reached through defines
 
-		for fact, _ := range store.ScanContext(ctx, id, config.PredicateHasKind, "") {
-			if str, ok := fact.Object.(string); ok {
-				hs.Kind = str
-				break
-			}
-		}
-		for fact, _ := range store.ScanContext(ctx, id, config.PredicateHasLanguage, "") {
-			if str, ok := fact.Object.(string); ok {
-				hs.Metadata["language"] = str
-				break
-			}
-		}
-		for fact, _ := range store.ScanContext(ctx, id, config.PredicateStartLine, "") {
-			if num, ok := fact.Object.(int); ok {
-				hs.Metadata["start_line"] = num
-			} else if floatNum, ok := fact.Object.(float64); ok {
-				hs.Metadata["start_line"] = int(floatNum)
-			} else if strNum, ok := fact.Object.(string); ok {
-				if parsed, err := strconv.Atoi(strNum); err == nil {
-					hs.Metadata["start_line"] = parsed
-				}
-			}
-		}
-		for fact, _ := range store.ScanContext(ctx, id, config.PredicateEndLine, "") {
-			if num, ok := fact.Object.(int); ok {
-				hs.Metadata["end_line"] = num
-			} else if floatNum, ok := fact.Object.(float64); ok {
-				hs.Metadata["end_line"] = int(floatNum)
-			} else if strNum, ok := fact.Object.(string); ok {
-				if parsed, err := strconv.Atoi(strNum); err == nil {
-					hs.Metadata["end_line"] = parsed
-				}
-			}
+	HydrateAll = HydrateMetadata | HydrateContent | HydrateChildren
+)
+
+// ParseHydrateFields parses a comma-separated fields mask such as "metadata,children".
+// Accepted names are metadata, content, children and all; an empty mask means metadata and content.
+func ParseHydrateFields(mask string) (HydrateFields, error) {
+	if strings.TrimSpace(mask) == "" {
+		return HydrateMetadata | HydrateContent, nil
+	}
+	var fields HydrateFields
+	for _, name := range strings.Split(mask, ",") {
+		switch strings.TrimSpace(name) {
+		case "metadata":
+			fields |= HydrateMetadata
+		case "content":
+			fields |= HydrateContent
+		case "children":
+			fields |= HydrateChildren
+		case "all":
+			fields |= HydrateAll
+		default:
+			return 0, fmt.Errorf("%w: unknown hydrate field %q", errors.ErrInvalidInput, name)
 		}
+	}
+	return fields, nil
+}
+
+// HydrateOptions selects what HydrateWithOptions fills in and how much of a
+// symbol's children tree it returns.
+type HydrateOptions struct {
+	Fields HydrateFields
+	// Depth is the levels of children hydrated with HydrateChildren (default
+	// config.HydrateDefaultDepth). Symbols on the last level only report their
+	// ChildCount.
+	Depth int
+	// ChildLimit caps the children returned per symbol on every level (default
+	// config.HydrateDefaultChildLimit); symbols with more get a NextChildren token.
+	ChildLimit int
+	// After is a NextChildren token: the children of the one symbol hydrated
+	// resume where that page stopped.
+	After string
+}
 
-		hydrated = append(hydrated, hs)
+// normalize applies the defaults and bounds of opts.
+func (opts HydrateOptions) normalize() (HydrateOptions, error) {
+	switch {
+	case opts.Depth < 0:
+		return opts, fmt.Errorf("%w: depth cannot be negative", errors.ErrInvalidInput)
+	case opts.ChildLimit < 0:
+		return opts, fmt.Errorf("%w: child limit cannot be negative", errors.ErrInvalidInput)
 	}
-	return hydrated, nil
+	if opts.Depth == 0 {
+		opts.Depth = config.HydrateDefaultDepth
+	}
+	if opts.ChildLimit == 0 {
+		opts.ChildLimit = config.HydrateDefaultChildLimit
+	}
+	opts.Depth = min(opts.Depth, config.HydrateMaxDepth)
+	opts.ChildLimit = min(opts.ChildLimit, config.HydrateMaxChildLimit)
+	return opts, nil
+}
+
+// childCursor encodes the position of the next page of parent's children.
+func childCursor(parent string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset) + ":" + parent))
+}
+
+// parseChildCursor decodes a childCursor token.
+func parseChildCursor(token string) (parent string, offset int, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		n, rest, ok := strings.Cut(string(raw), ":")
+		if offset, err = strconv.Atoi(n); ok && err == nil && offset >= 0 {
+			return rest, offset, nil
+		}
+	}
+	return "", 0, fmt.Errorf("%w: invalid children continuation token", errors.ErrInvalidInput)
+}
+
+// HydrateShallow hydrates metadata only.
+func (s *GraphService) HydrateShallow(ctx context.Context, store *meb.MEBStore, ids []string) ([]HydratedSymbol, error) {
+	return s.HydrateWithOptions(ctx, store, "", ids, HydrateOptions{Fields: HydrateMetadata})
 }
 
+// HydrateShallowBatch is HydrateShallow; kept for callers that hydrate whole graphs lazily.
 func (s *GraphService) HydrateShallowBatch(ctx context.Context, store *meb.MEBStore, ids []string) ([]HydratedSymbol, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
+	return s.HydrateWithOptions(ctx, store, "", ids, HydrateOptions{Fields: HydrateMetadata})
+}
 
-	hydrated := make([]HydratedSymbol, len(ids))
-	idToIdx := make(map[string]int, len(ids))
-	for i, id := range ids {
-		hydrated[i] = HydratedSymbol{ID: id, Metadata: make(map[string]interface{})}
-		idToIdx[id] = i
-	}
+// Hydrate hydrates metadata and source code.
+func (s *GraphService) Hydrate(ctx context.Context, store *meb.MEBStore, projectID string, ids []string) ([]HydratedSymbol, error) {
+	return s.HydrateWithOptions(ctx, store, projectID, ids, HydrateOptions{Fields: HydrateMetadata | HydrateContent})
+}
+
+// HydrateWithFields hydrates the given fields of ids, with one page of children
+// at the default depth.
+func (s *GraphService) HydrateWithFields(ctx context.Context, store *meb.MEBStore, projectID string, ids []string, fields HydrateFields) ([]HydratedSymbol, error) {
+	return s.HydrateWithOptions(ctx, store, projectID, ids, HydrateOptions{Fields: fields})
+}
 
-	metadataPredicates := []string{
-		config.PredicateHasKind,
-		config.PredicateHasLanguage,
-		config.PredicateStartLine,
-		config.PredicateEndLine,
+// HydrateWithOptions hydrates ids in bulk. All facts and documents are read in a single
+// read transaction, one subject scan per symbol, with each source file fetched once no
+// matter how many of its symbols are requested. Slicing symbol bodies out of their files
+// runs in parallel afterwards.
+func (s *GraphService) HydrateWithOptions(ctx context.Context, store *meb.MEBStore, projectID string, ids []string, opts HydrateOptions) ([]HydratedSymbol, error) {
+	deadline.Enter(ctx, "hydration")
+	opts, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	fields := opts.Fields
+	after := 0
+	if opts.After != "" {
+		parent, offset, err := parseChildCursor(opts.After)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) != 1 || ids[0] != parent {
+			return nil, fmt.Errorf("%w: continuation token belongs to %s", errors.ErrInvalidInput, parent)
+		}
+		after = offset
 	}
 
-	for _, pred := range metadataPredicates {
-		for _, id := range ids {
-			for fact, err := range store.ScanContext(ctx, id, pred, "") {
-				if err != nil {
-					continue
-				}
-				idx, ok := idToIdx[id]
-				if !ok {
-					continue
-				}
-				hs := &hydrated[idx]
+	hc := gcamdb.HotCacheOf(store)
+	hydrated := make([]HydratedSymbol, len(ids))
+	files := make(map[string][]byte) // file path -> content, fetched once
+	slices := make(map[string][]int) // file path -> indexes of symbols cut from it
 
-				switch pred {
-				case config.PredicateHasKind:
-					if str, ok := fact.Object.(string); ok {
-						hs.Kind = str
-					}
-				case config.PredicateHasLanguage:
-					if str, ok := fact.Object.(string); ok {
-						hs.Metadata["language"] = str
-					}
-				case config.PredicateStartLine:
-					if num, ok := fact.Object.(int); ok {
-						hs.Metadata["start_line"] = num
-					} else if floatNum, ok := fact.Object.(float64); ok {
-						hs.Metadata["start_line"] = int(floatNum)
-					} else if strNum, ok := fact.Object.(string); ok {
-						if parsed, err := strconv.Atoi(strNum); err == nil {
-							hs.Metadata["start_line"] = parsed
-						}
-					}
-				case config.PredicateEndLine:
-					if num, ok := fact.Object.(int); ok {
-						hs.Metadata["end_line"] = num
-					} else if floatNum, ok := fact.Object.(float64); ok {
-						hs.Metadata["end_line"] = int(floatNum)
-					} else if strNum, ok := fact.Object.(string); ok {
-						if parsed, err := strconv.Atoi(strNum); err == nil {
-							hs.Metadata["end_line"] = parsed
-						}
+	err = store.View(func(txn *meb.StoreTxn) error {
+		for i, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			hs := HydratedSymbol{ID: id, Metadata: make(map[string]interface{})}
+			childIDs := hydrateFacts(ctx, txn, hc, &hs)
+
+			if fields&HydrateChildren != 0 {
+				hydrateChildren(ctx, txn, hc, &hs, childIDs, after, opts)
+			}
+
+			if fields&HydrateContent != 0 {
+				if content := contentByKeys(txn, hc, contentKeys(projectID, id)...); len(content) > 0 {
+					hs.Content = string(content)
+				} else if idx := strings.Index(id, ":"); idx != -1 {
+					filePath := id[:idx]
+					if _, fetched := files[filePath]; !fetched {
+						files[filePath] = contentByKeys(txn, hc, fileKeys(projectID, filePath)...)
 					}
+					slices[filePath] = append(slices[filePath], i)
 				}
-				break
 			}
+
+			hydrated[i] = hs
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return hydrated, nil
-}
+	sliceSymbolBodies(hydrated, files, slices)
 
-func (s *GraphService) Hydrate(ctx context.Context, store *meb.MEBStore, projectID string, ids []string) ([]HydratedSymbol, error) {
-	hydrated, err := s.HydrateShallow(ctx, store, ids)
+	// Metadata is gathered regardless because it comes from the same scan as the line range
+	if fields&HydrateMetadata == 0 {
+		for i := range hydrated {
+			hydrated[i].Kind = ""
+			hydrated[i].Metadata = make(map[string]interface{})
+		}
+		return hydrated, nil
+	}
+
+	byNode, err := nodeAnnotations(store)
 	if err != nil {
 		return nil, err
 	}
-	for i := range hydrated {
-		hs := &hydrated[i]
+	annotateHydrated(hydrated, byNode)
+	return hydrated, nil
+}
 
-		content, _ := store.GetContentByKey(hs.ID)
-		if len(content) == 0 {
-			content, _ = store.GetContentByKey("/" + hs.ID)
+// hydrateFacts fills hs from a single scan of its subject's facts, or the hot
+// cache's copy of them, and returns the IDs it defines.
+func hydrateFacts(ctx context.Context, txn *meb.StoreTxn, hc *gcamdb.HotCache, hs *HydratedSymbol) []string {
+	var children []string
+	facts, _ := hc.SubjectFacts(ctx, txn, hs.ID) // Facts read before an error still count
+	for _, fact := range facts {
+		str, isStr := fact.Object.(string)
+		switch fact.Predicate {
+		case config.PredicateHasKind:
+			if isStr && hs.Kind == "" {
+				hs.Kind = str
+			}
+		case config.PredicateHasLanguage:
+			if _, set := hs.Metadata["language"]; isStr && !set {
+				hs.Metadata["language"] = str
+			}
+		case config.PredicateStartLine:
+			if n, ok := factInt(fact.Object); ok {
+				hs.Metadata["start_line"] = n
+			}
+		case config.PredicateEndLine:
+			if n, ok := factInt(fact.Object); ok {
+				hs.Metadata["end_line"] = n
+			}
+		case config.PredicateOwnedBy:
+			if isStr {
+				owners, _ := hs.Metadata["owners"].([]string)
+				hs.Metadata["owners"] = append(owners, str)
+			}
+		case config.PredicateHasSummary:
+			if isStr {
+				hs.Metadata["summary"] = str
+			}
+		case config.PredicateHasUID:
+			if isStr {
+				hs.Metadata["uid"] = str
+			}
+		case config.PredicateHasVulnerability:
+			if isStr {
+				vulns, _ := hs.Metadata["vulnerabilities"].([]string)
+				hs.Metadata["vulnerabilities"] = append(vulns, str)
+			}
+		case config.PredicateDefines:
+			if isStr {
+				children = append(children, str)
+			}
 		}
-		if len(content) == 0 && projectID != "" && !strings.HasPrefix(hs.ID, projectID+"/") {
-			prefixedID := projectID + "/" + hs.ID
-			content, _ = store.GetContentByKey(prefixedID)
+	}
+	return children
+}
+
+// hydrateChildren fills hs.Children with the page of childIDs starting at from,
+// and their own children down to opts.Depth levels.
+func hydrateChildren(ctx context.Context, txn *meb.StoreTxn, hc *gcamdb.HotCache, hs *HydratedSymbol, childIDs []string, from int, opts HydrateOptions) {
+	hs.ChildCount = len(childIDs)
+	from = min(from, len(childIDs))
+	end := min(from+opts.ChildLimit, len(childIDs))
+	for _, childID := range childIDs[from:end] {
+		child := HydratedSymbol{ID: childID, Metadata: make(map[string]interface{})}
+		grandchildren := hydrateFacts(ctx, txn, hc, &child)
+		if opts.Depth > 1 {
+			deeper := opts
+			deeper.Depth--
+			hydrateChildren(ctx, txn, hc, &child, grandchildren, 0, deeper)
+		} else {
+			child.ChildCount = len(grandchildren)
 		}
-		if len(content) > 0 {
-			hs.Content = string(content)
+		hs.Children = append(hs.Children, child)
+	}
+	if end < len(childIDs) {
+		hs.NextChildren = childCursor(hs.ID, end)
+	}
+}
+
+// contentKeys lists the document keys a symbol's own content may be stored under.
+func contentKeys(projectID, id string) []string {
+	keys := []string{id, "/" + id}
+	if projectID != "" && !strings.HasPrefix(id, projectID+"/") {
+		keys = append(keys, projectID+"/"+id)
+	}
+	return keys
+}
+
+// fileKeys lists the document keys a file's content may be stored under.
+func fileKeys(projectID, filePath string) []string {
+	keys := []string{filePath}
+	if projectID != "" && !strings.HasPrefix(filePath, projectID+"/") {
+		keys = append(keys, projectID+"/"+filePath)
+	}
+	return keys
+}
+
+// contentByKeys returns the first non-empty document stored under one of keys.
+func contentByKeys(txn *meb.StoreTxn, hc *gcamdb.HotCache, keys ...string) []byte {
+	for _, key := range keys {
+		id, err := txn.GetID(key)
+		if err != nil {
 			continue
 		}
+		raw, err := txn.GetContent(id)
+		if err != nil || len(raw) == 0 {
+			continue
+		}
+		if content, err := hc.DecodeContent(raw, gcamdb.TxnContentFetcher(txn)); err == nil && len(content) > 0 {
+			return content
+		}
+	}
+	return nil
+}
 
-		if strings.Contains(hs.ID, ":") {
-			parts := strings.Split(hs.ID, ":")
-			filePath := parts[0]
-			fileContentBytes, _ := store.GetContentByKey(filePath)
-			if len(fileContentBytes) == 0 && projectID != "" && !strings.HasPrefix(filePath, projectID+"/") {
-				prefixedPath := projectID + "/" + filePath
-				fileContentBytes, _ = store.GetContentByKey(prefixedPath)
-			}
-			if len(fileContentBytes) > 0 {
+// sliceSymbolBodies cuts each symbol's lines out of its file, splitting every file
+// once and processing files in parallel.
+func sliceSymbolBodies(hydrated []HydratedSymbol, files map[string][]byte, slices map[string][]int) {
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for filePath, idxs := range slices {
+		content := files[filePath]
+		if len(content) == 0 {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(content []byte, idxs []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var lines []string
+			for _, i := range idxs {
+				hs := &hydrated[i] // each index belongs to exactly one file
 				startLine, hasStart := hs.Metadata["start_line"].(int)
 				endLine, hasEnd := hs.Metadata["end_line"].(int)
-
-				if hasStart && hasEnd {
-					lines := strings.Split(string(fileContentBytes), "\n")
-					start := startLine - 1
-					end := endLine
-
-					if start < 0 {
-						start = 0
-					}
-					if end > len(lines) {
-						end = len(lines)
-					}
-					if start < end {
-						hs.Content = strings.Join(lines[start:end], "\n")
-					}
-				} else {
-					hs.Content = string(fileContentBytes)
+				if !hasStart || !hasEnd {
+					hs.Content = string(content)
+					continue
 				}
+				if lines == nil {
+					lines = strings.Split(string(content), "\n")
+				}
+				hs.Content = gcamdb.SnippetLines(lines, startLine, endLine)
 			}
+		}(content, idxs)
+	}
+	wg.Wait()
+}
+
+// scanOwners returns every owned_by value recorded for id.
+func scanOwners(ctx context.Context, store *meb.MEBStore, id string) []string {
+	return scanStrings(ctx, store, id, config.PredicateOwnedBy)
+}
+
+// scanStrings returns every string object recorded for (id, predicate).
+func scanStrings(ctx context.Context, store *meb.MEBStore, id, predicate string) []string {
+	var values []string
+	for fact, err := range gcamdb.Scan(ctx, store, id, predicate, "") {
+		if err != nil {
+			continue
+		}
+		if str, ok := fact.Object.(string); ok {
+			values = append(values, str)
 		}
 	}
-	return hydrated, nil
+	return values
 }
 
 func (s *GraphService) enrichNodes(ctx context.Context, store *meb.MEBStore, graph *export.D3Graph, lazy bool) error {
@@ -235,6 +425,12 @@ func (s *GraphService) enrichNodes(ctx context.Context, store *meb.MEBStore, gra
 			} else if tags, ok := h.Metadata["tags"].(string); ok {
 				n.Metadata["tags"] = tags
 			}
+			if owners, ok := h.Metadata["owners"].([]string); ok {
+				n.Metadata["owners"] = strings.Join(owners, ",")
+			}
+			if vulns, ok := h.Metadata["vulnerabilities"].([]string); ok {
+				n.Metadata["vulnerabilities"] = strings.Join(vulns, ",")
+			}
 		}
 	}
 	return nil
@@ -281,6 +477,12 @@ func (s *GraphService) mapChildren(hydrated []HydratedSymbol) []export.D3Node {
 		} else if tags, ok := h.Metadata["tags"].(string); ok {
 			nodes[i].Metadata["tags"] = tags
 		}
+		if owners, ok := h.Metadata["owners"].([]string); ok {
+			nodes[i].Metadata["owners"] = strings.Join(owners, ",")
+		}
+		if vulns, ok := h.Metadata["vulnerabilities"].([]string); ok {
+			nodes[i].Metadata["vulnerabilities"] = strings.Join(vulns, ",")
+		}
 	}
 	return nodes
 }
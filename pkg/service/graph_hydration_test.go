@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestHydrateWithFields(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	src := "package demo\n\nfunc A() {}\n\nfunc B() {\n\treturn\n}\n"
+	if err := s.AddDocument("demo/a.go", []byte(src), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	facts := []meb.Fact{
+		{Subject: "demo/a.go", Predicate: config.PredicateDefines, Object: "demo/a.go:A"},
+		{Subject: "demo/a.go", Predicate: config.PredicateDefines, Object: "demo/a.go:B"},
+		{Subject: "demo/a.go:A", Predicate: config.PredicateHasKind, Object: "func"},
+		{Subject: "demo/a.go:A", Predicate: config.PredicateStartLine, Object: int32(3)},
+		{Subject: "demo/a.go:A", Predicate: config.PredicateEndLine, Object: int32(3)},
+		{Subject: "demo/a.go:B", Predicate: config.PredicateHasKind, Object: "func"},
+		{Subject: "demo/a.go:B", Predicate: config.PredicateStartLine, Object: int32(5)},
+		{Subject: "demo/a.go:B", Predicate: config.PredicateEndLine, Object: int32(7)},
+		{Subject: "demo/a.go:B", Predicate: config.PredicateOwnedBy, Object: "@team-a"},
+		{Subject: "demo/a.go:B", Predicate: config.PredicateOwnedBy, Object: "@team-b"},
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+
+	hydrated, err := svc.Hydrate(ctx, s, "", []string{"demo/a.go:A", "demo/a.go:B"})
+	if err != nil {
+		t.Fatalf("Hydrate: %v", err)
+	}
+	if got := hydrated[0].Content; got != "func A() {}" {
+		t.Errorf("A content = %q", got)
+	}
+	if got := hydrated[1].Content; got != "func B() {\n\treturn\n}" {
+		t.Errorf("B content = %q", got)
+	}
+	if owners, _ := hydrated[1].Metadata["owners"].([]string); len(owners) != 2 {
+		t.Errorf("B owners = %v, want two owners", hydrated[1].Metadata["owners"])
+	}
+
+	// Content without metadata still slices by line range
+	hydrated, err = svc.HydrateWithFields(ctx, s, "", []string{"demo/a.go:A"}, HydrateContent)
+	if err != nil {
+		t.Fatalf("HydrateWithFields: %v", err)
+	}
+	if hydrated[0].Content != "func A() {}" || hydrated[0].Kind != "" || len(hydrated[0].Metadata) != 0 {
+		t.Errorf("content-only hydration = %+v", hydrated[0])
+	}
+
+	hydrated, err = svc.HydrateWithFields(ctx, s, "", []string{"demo/a.go"}, HydrateChildren)
+	if err != nil {
+		t.Fatalf("HydrateWithFields: %v", err)
+	}
+	if len(hydrated[0].Children) != 2 || hydrated[0].Children[0].Kind != "func" || hydrated[0].Content != "" {
+		t.Errorf("children-only hydration = %+v", hydrated[0])
+	}
+
+	if _, err := ParseHydrateFields("metadata,bogus"); err == nil {
+		t.Error("ParseHydrateFields accepted an unknown field")
+	}
+}
+
+func TestHydrateChildrenPaging(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// demo/big.go defines T0..T4; each type defines two methods
+	var facts []meb.Fact
+	for i := 0; i < 5; i++ {
+		typ := fmt.Sprintf("demo/big.go:T%d", i)
+		facts = append(facts,
+			meb.Fact{Subject: "demo/big.go", Predicate: config.PredicateDefines, Object: typ},
+			meb.Fact{Subject: typ, Predicate: config.PredicateHasKind, Object: "struct"},
+			meb.Fact{Subject: typ, Predicate: config.PredicateDefines, Object: typ + ".A"},
+			meb.Fact{Subject: typ, Predicate: config.PredicateDefines, Object: typ + ".B"},
+		)
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+	ids := []string{"demo/big.go"}
+
+	// One level by default: types report their methods without listing them
+	hydrated, err := svc.HydrateWithOptions(ctx, s, "", ids, HydrateOptions{Fields: HydrateChildren})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := hydrated[0]
+	if len(file.Children) != 5 || file.ChildCount != 5 || file.NextChildren != "" {
+		t.Fatalf("file = %d children of %d, next %q", len(file.Children), file.ChildCount, file.NextChildren)
+	}
+	if c := file.Children[0]; len(c.Children) != 0 || c.ChildCount != 2 {
+		t.Errorf("first type = %+v, want 2 unlisted children", c)
+	}
+
+	hydrated, err = svc.HydrateWithOptions(ctx, s, "", ids, HydrateOptions{Fields: HydrateChildren, Depth: 2, ChildLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file = hydrated[0]
+	if len(file.Children) != 2 || file.ChildCount != 5 || file.NextChildren == "" {
+		t.Fatalf("first page = %d children of %d, next %q", len(file.Children), file.ChildCount, file.NextChildren)
+	}
+	if c := file.Children[0]; len(c.Children) != 2 || c.NextChildren != "" {
+		t.Errorf("second level = %+v, want both methods", c)
+	}
+
+	// Follow the tokens to the end; every type comes back once
+	seen := map[string]bool{}
+	for _, c := range file.Children {
+		seen[c.ID] = true
+	}
+	for pages := 1; file.NextChildren != ""; pages++ {
+		if pages > 5 {
+			t.Fatal("paging does not end")
+		}
+		hydrated, err = svc.HydrateWithOptions(ctx, s, "", ids, HydrateOptions{Fields: HydrateChildren, ChildLimit: 2, After: file.NextChildren})
+		if err != nil {
+			t.Fatal(err)
+		}
+		file = hydrated[0]
+		for _, c := range file.Children {
+			if seen[c.ID] {
+				t.Errorf("%s returned twice", c.ID)
+			}
+			seen[c.ID] = true
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("paged through %d types, want 5", len(seen))
+	}
+
+	// Tokens are bound to the symbol they were issued for
+	hydrated, _ = svc.HydrateWithOptions(ctx, s, "", ids, HydrateOptions{Fields: HydrateChildren, ChildLimit: 1})
+	if _, err := svc.HydrateWithOptions(ctx, s, "", []string{"demo/other.go"}, HydrateOptions{Fields: HydrateChildren, After: hydrated[0].NextChildren}); err == nil {
+		t.Error("token accepted for another symbol")
+	}
+	if _, err := svc.HydrateWithOptions(ctx, s, "", ids, HydrateOptions{Fields: HydrateChildren, After: "!!"}); err == nil {
+		t.Error("malformed token accepted")
+	}
+
+	// GetSymbolWithOptions resolves the token's symbol with or without its project prefix
+	sym, err := svc.GetSymbolWithOptions(ctx, "demo", "big.go", HydrateOptions{Fields: HydrateChildren, ChildLimit: 1, After: hydrated[0].NextChildren})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sym.Children) != 1 || sym.Children[0].ID == hydrated[0].Children[0].ID {
+		t.Errorf("next page via GetSymbolWithOptions = %+v", sym.Children)
+	}
+}
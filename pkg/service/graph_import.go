@@ -0,0 +1,66 @@
+package service
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// ImportFacts adds facts from an external producer, in NDJSON or CSV, to the
+// project's graph as a new ingest version. An import with an invalid line writes
+// nothing and returns an error wrapping ErrInvalidInput and the *ingest.ImportError
+// listing the lines.
+func (s *GraphService) ImportFacts(projectID string, r io.Reader, format string) (*ingest.ImportReport, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+	// Import writes under the project's topic; leave the store's topic as it was
+	prev := store.TopicID()
+	defer store.SetTopicID(prev)
+
+	report, err := ingest.ImportFacts(store, projectID, r, format)
+	if err != nil {
+		if stderrors.Is(err, ingest.ErrInvalidImport) {
+			return nil, fmt.Errorf("%w: %w", errors.ErrInvalidInput, err)
+		}
+		return nil, fmt.Errorf("%w: %v", errors.ErrInternal, err)
+	}
+	return report, nil
+}
+
+// UpdateFactWeights sets the weights of facts in the project's graph, which
+// query provenance, graph links and weight constraints then report. Nothing is
+// written unless every fact exists and every weight is positive.
+func (s *GraphService) UpdateFactWeights(projectID string, weights []gcamdb.FactWeight) error {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return err
+	}
+
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+	prev := store.TopicID()
+	defer store.SetTopicID(prev)
+	store.SetTopicID(gcamdb.TopicForProject(projectID))
+
+	if err := gcamdb.UpdateFactWeights(store, weights); err != nil {
+		switch {
+		case stderrors.Is(err, gcamdb.ErrFactNotFound):
+			return fmt.Errorf("%w: %v", errors.ErrNotFound, err)
+		case stderrors.Is(err, gcamdb.ErrInvalidWeight):
+			return fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+		}
+		return fmt.Errorf("%w: %v", errors.ErrInternal, err)
+	}
+	s.graphCache.invalidate(projectID)
+	s.revision.Add(1)
+	return nil
+}
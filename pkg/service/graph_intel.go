@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// Editors ask about positions, not symbol IDs. The code intelligence methods
+// below translate a file and line to the innermost symbol whose start_line and
+// end_line cover it, and, when the editor sends the identifier under the
+// cursor, to the symbol that identifier names: one the enclosing symbol calls
+// or references, or else the best exact name match in the project.
+
+// Position is a place in a source file as an editor reports it.
+type Position struct {
+	File string `json:"file"`           // As ingested ("p/pkg/a.go") or relative to the project ("pkg/a.go")
+	Line int    `json:"line"`           // 1-based
+	Word string `json:"word,omitempty"` // Identifier under the cursor, if any
+}
+
+// Hover is what an editor shows for the symbol at a position.
+type Hover struct {
+	gcamdb.Location
+	Kind    string `json:"kind,omitempty"`
+	Doc     string `json:"doc,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Callers int    `json:"callers"`
+	Callees int    `json:"callees"`
+}
+
+// referencePredicates are the facts that count as references to a symbol.
+var referencePredicates = []string{config.PredicateCalls, config.PredicateReferences}
+
+// SymbolAtPosition returns the location of the symbol at pos, or nil when no
+// symbol covers the position.
+func (s *GraphService) SymbolAtPosition(ctx context.Context, projectID string, pos Position) (*gcamdb.Location, error) {
+	store, ctx, id, err := s.symbolAtPosition(ctx, projectID, pos)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	loc := gcamdb.SymbolLocation(ctx, store, id)
+	return &loc, nil
+}
+
+// Definition returns where the symbol at pos is defined; empty when no symbol
+// covers the position.
+func (s *GraphService) Definition(ctx context.Context, projectID string, pos Position) ([]gcamdb.Location, error) {
+	loc, err := s.SymbolAtPosition(ctx, projectID, pos)
+	if err != nil || loc == nil {
+		return []gcamdb.Location{}, err
+	}
+	return []gcamdb.Location{*loc}, nil
+}
+
+// References returns the symbols that call or reference the symbol at pos.
+func (s *GraphService) References(ctx context.Context, projectID string, pos Position) ([]gcamdb.Location, error) {
+	return s.linkedAt(ctx, projectID, pos, referencePredicates, true)
+}
+
+// IncomingCalls returns the callers of the symbol at pos.
+func (s *GraphService) IncomingCalls(ctx context.Context, projectID string, pos Position) ([]gcamdb.Location, error) {
+	return s.linkedAt(ctx, projectID, pos, []string{config.PredicateCalls}, true)
+}
+
+// OutgoingCalls returns the symbols the symbol at pos calls.
+func (s *GraphService) OutgoingCalls(ctx context.Context, projectID string, pos Position) ([]gcamdb.Location, error) {
+	return s.linkedAt(ctx, projectID, pos, []string{config.PredicateCalls}, false)
+}
+
+// HoverAt describes the symbol at pos: its location, kind, doc comment, AI
+// summary and direct caller and callee counts. It returns nil when no symbol
+// covers the position.
+func (s *GraphService) HoverAt(ctx context.Context, projectID string, pos Position) (*Hover, error) {
+	store, ctx, id, err := s.symbolAtPosition(ctx, projectID, pos)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	h := &Hover{Location: gcamdb.SymbolLocation(ctx, store, id)}
+	h.Kind = s.kindIndexes.get(ctx, projectID, store).Kind(id)
+	for fact, err := range gcamdb.Scan(ctx, store, id, "", "") {
+		if err != nil {
+			continue
+		}
+		text, _ := fact.Object.(string)
+		switch fact.Predicate {
+		case config.PredicateHasDoc:
+			h.Doc = text
+		case config.PredicateHasSummary:
+			h.Summary = text
+		case config.PredicateKind, config.PredicateHasKind:
+			if h.Kind == "" {
+				h.Kind = text
+			}
+		}
+	}
+	h.Callers = len(linked(ctx, store, id, []string{config.PredicateCalls}, true))
+	h.Callees = len(linked(ctx, store, id, []string{config.PredicateCalls}, false))
+	return h, nil
+}
+
+// linkedAt returns the locations of the symbols linked to the one at pos by
+// preds: its subjects when incoming, its objects otherwise.
+func (s *GraphService) linkedAt(ctx context.Context, projectID string, pos Position, preds []string, incoming bool) ([]gcamdb.Location, error) {
+	store, ctx, id, err := s.symbolAtPosition(ctx, projectID, pos)
+	if err != nil || id == "" {
+		return []gcamdb.Location{}, err
+	}
+	ids := linked(ctx, store, id, preds, incoming)
+	locs := make([]gcamdb.Location, len(ids))
+	for i, other := range ids {
+		locs[i] = gcamdb.SymbolLocation(ctx, store, other)
+	}
+	return locs, nil
+}
+
+// symbolAtPosition resolves pos to a symbol ID in the project's store, returning
+// the store and the project-scoped context with it. The ID is empty when no
+// symbol covers the position.
+func (s *GraphService) symbolAtPosition(ctx context.Context, projectID string, pos Position) (*meb.MEBStore, context.Context, string, error) {
+	if pos.File == "" || pos.Line <= 0 {
+		return nil, ctx, "", fmt.Errorf("%w: a position needs a file and a line from 1", errors.ErrInvalidInput)
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, ctx, "", err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	var enclosing gcamdb.Location
+	found := false
+	for _, file := range fileKeys(projectID, strings.TrimPrefix(pos.File, "/")) {
+		if enclosing, found = gcamdb.SymbolAt(ctx, store, file, pos.Line); found {
+			break
+		}
+	}
+	if pos.Word == "" {
+		return store, ctx, enclosing.ID, nil
+	}
+	if found && namedBy(enclosing.ID, pos.Word) {
+		return store, ctx, enclosing.ID, nil
+	}
+	if found {
+		for _, id := range linked(ctx, store, enclosing.ID, referencePredicates, false) {
+			if namedBy(id, pos.Word) {
+				return store, ctx, id, nil
+			}
+		}
+	}
+	for _, m := range s.symbolIndex.get(ctx, projectID, store).search(pos.Word, 1) {
+		if m.Score >= scoreExact {
+			return store, ctx, m.ID, nil
+		}
+	}
+	return store, ctx, "", nil
+}
+
+// linked returns the distinct symbols linked to id by preds, sorted: the
+// subjects of facts about id when incoming, their objects otherwise.
+func linked(ctx context.Context, store *meb.MEBStore, id string, preds []string, incoming bool) []string {
+	seen := make(map[string]bool)
+	for _, pred := range preds {
+		subj, obj := id, ""
+		if incoming {
+			subj, obj = "", id
+		}
+		for fact, err := range gcamdb.Scan(ctx, store, subj, pred, obj) {
+			if err != nil {
+				continue
+			}
+			other := fact.Subject
+			if !incoming {
+				other, _ = fact.Object.(string)
+			}
+			if other != "" && other != id {
+				seen[other] = true
+			}
+		}
+	}
+	ids := make([]string, 0, len(seen))
+	for other := range seen {
+		ids = append(ids, other)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// namedBy reports whether word names the symbol: its short name, without a
+// parameter list, or that name's last dotted part ("Save" names
+// "pkg/a.go:Repo.Save").
+func namedBy(id, word string) bool {
+	name := symbolShortName(id)
+	if i := strings.Index(name, "("); i > 0 {
+		name = name[:i]
+	}
+	return name == word || strings.HasSuffix(name, "."+word)
+}
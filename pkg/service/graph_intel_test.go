@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeIntelligence(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// api.go: Handle (lines 3-10) calls Repo.Save and logs; main.go: main (1-5) calls Handle
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "p/api.go", Predicate: "defines", Object: "p/api.go:Handle"},
+		{Subject: "p/api.go:Handle", Predicate: "start_line", Object: 3},
+		{Subject: "p/api.go:Handle", Predicate: "end_line", Object: 10},
+		{Subject: "p/api.go:Handle", Predicate: "has_doc", Object: "Handle serves the API."},
+		{Subject: "p/api.go:Handle", Predicate: "calls", Object: "p/db.go:Repo.Save"},
+		{Subject: "p/api.go:Handle", Predicate: "calls", Object: "p/log.go:Info"},
+		{Subject: "p/db.go", Predicate: "defines", Object: "p/db.go:Repo.Save"},
+		{Subject: "p/db.go:Repo.Save", Predicate: "start_line", Object: 12},
+		{Subject: "p/db.go:Repo.Save", Predicate: "end_line", Object: 20},
+		{Subject: "p/main.go", Predicate: "defines", Object: "p/main.go:main"},
+		{Subject: "p/main.go:main", Predicate: "start_line", Object: 1},
+		{Subject: "p/main.go:main", Predicate: "end_line", Object: 5},
+		{Subject: "p/main.go:main", Predicate: "calls", Object: "p/api.go:Handle"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+	ids := func(locs []gcamdb.Location) []string {
+		out := []string{}
+		for _, l := range locs {
+			out = append(out, l.ID)
+		}
+		return out
+	}
+
+	// The project prefix is optional; the word picks the callee under the cursor
+	defs, err := svc.Definition(ctx, "p", Position{File: "api.go", Line: 5, Word: "Save"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []gcamdb.Location{{ID: "p/db.go:Repo.Save", File: "p/db.go", StartLine: 12, EndLine: 20}}, defs)
+
+	// Without a word the enclosing symbol is meant
+	refs, err := svc.References(ctx, "p", Position{File: "p/api.go", Line: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"p/main.go:main"}, ids(refs))
+
+	out, err := svc.OutgoingCalls(ctx, "p", Position{File: "api.go", Line: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"p/db.go:Repo.Save", "p/log.go:Info"}, ids(out))
+
+	in, err := svc.IncomingCalls(ctx, "p", Position{File: "db.go", Line: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"p/api.go:Handle"}, ids(in))
+
+	hover, err := svc.HoverAt(ctx, "p", Position{File: "api.go", Line: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, hover) {
+		assert.Equal(t, "p/api.go:Handle", hover.ID)
+		assert.Equal(t, "Handle serves the API.", hover.Doc)
+		assert.Equal(t, 1, hover.Callers)
+		assert.Equal(t, 2, hover.Callees)
+	}
+
+	// Lines no symbol covers resolve to nothing rather than an error
+	hover, err = svc.HoverAt(ctx, "p", Position{File: "api.go", Line: 40})
+	assert.NoError(t, err)
+	assert.Nil(t, hover)
+	defs, err = svc.Definition(ctx, "p", Position{File: "api.go", Line: 40})
+	assert.NoError(t, err)
+	assert.Empty(t, defs)
+
+	_, err = svc.Definition(ctx, "p", Position{File: "api.go"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// KeywordSearch ranks symbols by TF-IDF over their names, IDs and doc comments.
+// It needs no embedding provider, so it serves semantic search in offline and
+// low-memory deployments. Stores ingested before the token index existed are
+// indexed on first use.
+func (s *GraphService) KeywordSearch(ctx context.Context, projectID, query string, k int) ([]SemanticSearchResult, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	idx, err := s.tokenIndexes.get(ctx, projectID, store)
+	if err != nil {
+		return nil, err
+	}
+	hits := idx.Search(query, k, func(id string) bool { return gcamdb.InScope(ctx, id) })
+	results := make([]SemanticSearchResult, 0, len(hits))
+	for _, h := range hits {
+		results = append(results, SemanticSearchResult{
+			SymbolID: h.ID,
+			Score:    float32(h.Score),
+			Name:     symbolShortName(h.ID),
+		})
+	}
+	return results, nil
+}
+
+// tokenIndexCache keeps one token index per project, reloaded when the
+// project's fact count changes.
+type tokenIndexCache struct {
+	mu      sync.Mutex
+	entries map[string]tokenIndexEntry
+	load    func(store *meb.MEBStore, project string) (*gcamdb.TokenIndex, error) // nil when ingest saves none
+	build   func(ctx context.Context, store *meb.MEBStore) *gcamdb.TokenIndex
+}
+
+type tokenIndexEntry struct {
+	index     *gcamdb.TokenIndex
+	factCount uint64
+}
+
+func newTokenIndexCache() *tokenIndexCache {
+	return &tokenIndexCache{
+		entries: make(map[string]tokenIndexEntry),
+		load:    gcamdb.LoadTokenIndex,
+		build:   gcamdb.BuildTokenIndex,
+	}
+}
+
+// newDocIndexCache keeps the doc node indexes of documentation search, which
+// are built from the store on first use.
+func newDocIndexCache() *tokenIndexCache {
+	return &tokenIndexCache{entries: make(map[string]tokenIndexEntry), build: gcamdb.BuildDocTokenIndex}
+}
+
+// get returns the project's index: the one saved by ingest, or one built from
+// the store when there is none.
+func (c *tokenIndexCache) get(ctx context.Context, projectID string, store *meb.MEBStore) (*gcamdb.TokenIndex, error) {
+	factCount := store.Count()
+	c.mu.Lock()
+	entry, ok := c.entries[projectID]
+	c.mu.Unlock()
+	if ok && entry.factCount == factCount {
+		return entry.index, nil
+	}
+
+	var idx *gcamdb.TokenIndex
+	if c.load != nil {
+		var err error
+		if idx, err = c.load(store, projectID); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInternal, err)
+		}
+	}
+	if idx == nil {
+		idx = c.build(ctx, store)
+	}
+	c.mu.Lock()
+	c.entries[projectID] = tokenIndexEntry{index: idx, factCount: factCount}
+	c.mu.Unlock()
+	return idx, nil
+}
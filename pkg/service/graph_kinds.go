@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/gca/pkg/logger"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// kindIndexCache keeps one kind index per project, reloaded when the
+// project's fact count changes.
+type kindIndexCache struct {
+	mu      sync.Mutex
+	entries map[string]kindIndexEntry
+}
+
+type kindIndexEntry struct {
+	index     *gcamdb.KindIndex
+	factCount uint64
+}
+
+func newKindIndexCache() *kindIndexCache {
+	return &kindIndexCache{entries: make(map[string]kindIndexEntry)}
+}
+
+// get returns the project's kind index: the one saved by ingest, or one built
+// from the store when there is none.
+func (c *kindIndexCache) get(ctx context.Context, projectID string, store *meb.MEBStore) *gcamdb.KindIndex {
+	factCount := store.Count()
+	c.mu.Lock()
+	entry, ok := c.entries[projectID]
+	c.mu.Unlock()
+	if ok && entry.factCount == factCount {
+		return entry.index
+	}
+
+	idx, err := gcamdb.LoadKindIndex(store, projectID)
+	if err != nil {
+		logger.Warn("Could not load kind index, rebuilding it", "project", projectID, "error", err)
+	}
+	if idx == nil {
+		idx = gcamdb.BuildKindIndex(ctx, store)
+	}
+	c.mu.Lock()
+	c.entries[projectID] = kindIndexEntry{index: idx, factCount: factCount}
+	c.mu.Unlock()
+	return idx
+}
+
+// newTransformer returns a D3 transformer labelling nodes from the project's
+// kind index, so nodes that only appear as objects get a kind too, and telling
+// internal nodes by the files the project ingested.
+func (s *GraphService) newTransformer(ctx context.Context, projectID string, store *meb.MEBStore) *export.D3Transformer {
+	t := export.NewD3Transformer(store)
+	kinds := s.kindIndexes.get(ctx, projectID, store)
+	t.Kinds = kinds.Kind
+	t.IsIngestedFile = kinds.IsIngestedFile
+	return t
+}
+
+// kindOr returns the indexed kind of id, or fallback when it has none.
+func kindOr(idx *gcamdb.KindIndex, id, fallback string) string {
+	if kind := idx.Kind(id); kind != "" {
+		return kind
+	}
+	return fallback
+}
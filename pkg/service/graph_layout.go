@@ -0,0 +1,67 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+)
+
+// LayoutGraph attaches precomputed x/y positions to the graph's nodes so big
+// graphs need no force simulation in the browser. Layouts are cached by graph
+// hash. Graphs spanning more than config.LayoutMaxFiles files are returned
+// without positions and with a warning.
+func (s *GraphService) LayoutGraph(graph *export.D3Graph) {
+	if graph == nil || len(graph.Nodes) == 0 {
+		return
+	}
+	hash := graph.Hash()
+	positions, ok := s.layouts.get(hash)
+	if !ok {
+		positions, ok = export.ComputeLayout(graph, export.LayoutOptions{
+			Size:       config.LayoutSize,
+			Iterations: config.LayoutIterations,
+			MaxFiles:   config.LayoutMaxFiles,
+		})
+		if !ok {
+			graph.Warnings = append(graph.Warnings, "graph spans too many files for server-side layout; positions omitted")
+			return
+		}
+		s.layouts.set(hash, positions)
+	}
+	graph.ApplyLayout(positions)
+}
+
+// layoutCache keeps the most recent layouts by graph hash. Positions depend only
+// on the graph's shape, so entries never go stale.
+type layoutCache struct {
+	mu      sync.Mutex
+	entries map[string]map[string]export.Position
+	order   []string // insertion order for eviction
+	maxSize int
+}
+
+func newLayoutCache(maxSize int) *layoutCache {
+	return &layoutCache{entries: make(map[string]map[string]export.Position), maxSize: maxSize}
+}
+
+func (c *layoutCache) get(hash string) (map[string]export.Position, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	positions, ok := c.entries[hash]
+	return positions, ok
+}
+
+func (c *layoutCache) set(hash string, positions map[string]export.Position) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[hash]; exists {
+		return
+	}
+	if len(c.order) >= c.maxSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[hash] = positions
+	c.order = append(c.order, hash)
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+)
+
+// GraphLimits caps the size of a graph returned to a client. A zero field takes
+// the configured default.
+type GraphLimits struct {
+	MaxNodes int
+	MaxLinks int
+}
+
+// LimitGraph truncates the graph to the limits, keeping the most central nodes
+// and heaviest links. Every graph endpoint goes through it so callers see one
+// consistent cap, flagged with truncated:true when it applied.
+func (s *GraphService) LimitGraph(graph *export.D3Graph, limits GraphLimits) *export.D3Graph {
+	if graph == nil {
+		return nil
+	}
+	if limits.MaxNodes <= 0 {
+		limits.MaxNodes = config.GraphDefaultMaxNodes
+	}
+	if limits.MaxLinks <= 0 {
+		limits.MaxLinks = config.GraphDefaultMaxLinks
+	}
+	return graph.Truncate(limits.MaxNodes, limits.MaxLinks)
+}
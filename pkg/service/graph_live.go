@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// GraphUpdate is how one ingest version changed a project's graph: the nodes
+// that came into or went out of it, and the links added and removed. A full
+// ingest does not track its facts, so it is reported with Reset set instead,
+// for clients to fetch the graph again.
+type GraphUpdate struct {
+	Project      string          `json:"project"`
+	Version      int             `json:"version"`
+	Reset        bool            `json:"reset,omitempty"`
+	AddedNodes   []export.D3Node `json:"added_nodes"`
+	RemovedNodes []string        `json:"removed_nodes"`
+	AddedLinks   []export.D3Link `json:"added_links"`
+	RemovedLinks []export.D3Link `json:"removed_links"`
+}
+
+// LatestVersion returns the ID of the project's latest ingest version, 0 when
+// none was recorded.
+func (s *GraphService) LatestVersion(ctx context.Context, projectID string) (int, error) {
+	versions, err := s.ListVersions(ctx, projectID)
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+	return versions[len(versions)-1].ID, nil
+}
+
+// GraphUpdates returns the updates of the project's ingest versions after
+// since, oldest first. Incremental ingests rewrite the facts of every changed
+// file, so facts a version both removed and wrote are left out; a node is
+// added when the version gave the first facts to it and removed when it took
+// the last ones away, as the store now reads.
+func (s *GraphService) GraphUpdates(ctx context.Context, projectID string, since int) ([]GraphUpdate, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.ListVersions(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	var updates []GraphUpdate
+	for _, v := range versions {
+		if v.ID <= since {
+			continue
+		}
+		if v.Full {
+			updates = append(updates, GraphUpdate{Project: projectID, Version: v.ID, Reset: true})
+			continue
+		}
+		added, removed, err := gcamdb.VersionChanges(store, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInternal, err)
+		}
+		updates = append(updates, s.graphUpdate(ctx, projectID, store, v.ID, added, removed))
+	}
+	return updates, nil
+}
+
+// graphUpdate folds the facts one version wrote and removed into its update.
+func (s *GraphService) graphUpdate(ctx context.Context, projectID string, store *meb.MEBStore, version int, added, removed []meb.Fact) GraphUpdate {
+	update := GraphUpdate{
+		Project:      projectID,
+		Version:      version,
+		AddedNodes:   []export.D3Node{},
+		RemovedNodes: []string{},
+		AddedLinks:   []export.D3Link{},
+		RemovedLinks: []export.D3Link{},
+	}
+
+	key := func(f meb.Fact) string { return gcamdb.LinkKey(f.Subject, f.Predicate, fmt.Sprint(f.Object)) }
+	rewritten := make(map[string]bool, len(removed))
+	for _, f := range removed {
+		rewritten[key(f)] = true
+	}
+	kept := make(map[string]bool, len(added))
+	for _, f := range added {
+		if rewritten[key(f)] {
+			kept[key(f)] = true
+		}
+	}
+
+	// Facts each touched node gained and lost
+	delta := make(map[string]int)
+	fold := func(facts []meb.Fact, sign int, links *[]export.D3Link) {
+		for _, f := range facts {
+			if kept[key(f)] || !gcamdb.InScope(ctx, f.Subject) {
+				continue
+			}
+			delta[f.Subject] += sign
+			obj, ok := f.Object.(string)
+			if !ok || attributePredicates[f.Predicate] || obj == f.Subject || !isNodeValue(obj) {
+				continue
+			}
+			delta[obj] += sign
+			*links = append(*links, export.D3Link{Source: f.Subject, Target: obj, Relation: f.Predicate})
+		}
+	}
+	fold(added, 1, &update.AddedLinks)
+	fold(removed, -1, &update.RemovedLinks)
+
+	ids := make([]string, 0, len(delta))
+	for id, d := range delta {
+		if d != 0 && isNodeValue(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	transformer := s.newTransformer(ctx, projectID, store)
+	for _, id := range ids {
+		after := nodeFactCount(ctx, store, id)
+		before := after - delta[id]
+		switch {
+		case before <= 0 && after > 0:
+			update.AddedNodes = append(update.AddedNodes, transformer.Node(id))
+		case before > 0 && after == 0:
+			update.RemovedNodes = append(update.RemovedNodes, id)
+		}
+	}
+	(&export.D3Graph{Links: update.AddedLinks}).Sort()
+	(&export.D3Graph{Links: update.RemovedLinks}).Sort()
+	return update
+}
+
+// nodeFactCount returns the number of facts id is the subject of, or the
+// object of as a node.
+func nodeFactCount(ctx context.Context, store *meb.MEBStore, id string) int {
+	n := 0
+	for _, err := range gcamdb.Scan(ctx, store, id, "", "") {
+		if err == nil {
+			n++
+		}
+	}
+	for fact, err := range gcamdb.Scan(ctx, store, "", "", id) {
+		if err == nil && !attributePredicates[fact.Predicate] && fact.Subject != id {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphUpdates(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	add := func(facts ...meb.Fact) {
+		t.Helper()
+		if err := s.AddFactBatch(facts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Version 1: full ingest
+	add(meb.Fact{Subject: "a.go", Predicate: config.PredicateDefines, Object: "a.go:Old"},
+		meb.Fact{Subject: "a.go:Old", Predicate: config.PredicateCalls, Object: "b.go:B"},
+		meb.Fact{Subject: "b.go", Predicate: config.PredicateDefines, Object: "b.go:B"})
+	if _, err := gcamdb.RecordVersion(s, "test", true).Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 2: a.go renames Old to New, which calls B as Old did
+	rec := gcamdb.RecordVersion(s, "test", false)
+	for _, subject := range []string{"a.go", "a.go:Old"} {
+		if err := rec.DeleteSubject(s, subject); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(meb.Fact{Subject: "a.go", Predicate: config.PredicateDefines, Object: "a.go:New"},
+		meb.Fact{Subject: "a.go:New", Predicate: config.PredicateCalls, Object: "b.go:B"},
+		meb.Fact{Subject: "a.go:New", Predicate: config.PredicateStartLine, Object: "3"})
+	rec.AddSubject("a.go")
+	rec.AddSubject("a.go:New")
+	if _, err := rec.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewGraphService(&MockStoreManager{store: s})
+	latest, err := svc.LatestVersion(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, latest)
+
+	updates, err := svc.GraphUpdates(context.Background(), "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Len(t, updates, 2) {
+		return
+	}
+	assert.True(t, updates[0].Reset, "full ingests reset the graph")
+
+	u := updates[1]
+	assert.Equal(t, 2, u.Version)
+	if assert.Len(t, u.AddedNodes, 1) {
+		assert.Equal(t, "a.go:New", u.AddedNodes[0].ID)
+	}
+	assert.Equal(t, []string{"a.go:Old"}, u.RemovedNodes)
+	assert.Len(t, u.AddedLinks, 2, "defines and calls of New, not its start line")
+	assert.Len(t, u.RemovedLinks, 2)
+
+	updates, err = svc.GraphUpdates(context.Background(), "test", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, updates)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// CompactDictionary removes dictionary strings the project no longer references.
+// With dryRun set it only reports what would be reclaimed.
+func (s *GraphService) CompactDictionary(ctx context.Context, projectID string, dryRun bool) (*gcamdb.CompactReport, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	return gcamdb.CompactDictionary(ctx, store, dryRun)
+}
+
+// ListVersions returns the ingest versions recorded in the project's store, oldest
+// first. Reads can be pinned to any of them with QueryOptions.AsOf. In a shared
+// store only the project's own runs are listed.
+func (s *GraphService) ListVersions(ctx context.Context, projectID string) ([]gcamdb.Version, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := gcamdb.Versions(store)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInternal, err)
+	}
+	sc, scoped := gcamdb.ScopeFrom(s.scope(ctx, projectID))
+	if !scoped {
+		return versions, nil
+	}
+	owned := versions[:0]
+	for _, v := range versions {
+		if v.Project == sc.Project {
+			owned = append(owned, v)
+		}
+	}
+	return owned, nil
+}
+
+// ContentVersion returns a token that changes whenever what the project's
+// graph endpoints return may have: with the store's fact count, the latest
+// ingest version, the writes made through this service, such as annotations
+// and fact weights, and the annotations that expired. Responses computed under
+// an equal token are equal, so it serves as an ETag.
+func (s *GraphService) ContentVersion(ctx context.Context, projectID string) (string, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return "", err
+	}
+	versions, err := s.ListVersions(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+	var latest gcamdb.Version
+	if len(versions) > 0 {
+		latest = versions[len(versions)-1]
+	}
+	annotations, err := loadAnnotations(store)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%d\x00%d\x00%d\x00%d\x00%d", store.Count(), latest.ID, latest.CreatedAt.UnixNano(),
+		s.started.UnixNano(), s.revision.Load(), len(annotations))
+	return hex.EncodeToString(h.Sum(nil)[:12]), nil
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// SymbolComplexity reports the size and complexity metrics recorded for a function or method.
+type SymbolComplexity struct {
+	ID         string `json:"id"`
+	LOC        int    `json:"loc"`
+	Complexity int    `json:"complexity"`
+	Params     int    `json:"params"`
+}
+
+// complexitySorters maps the accepted sort keys to their primary metric.
+var complexitySorters = map[string]func(SymbolComplexity) int{
+	"complexity": func(m SymbolComplexity) int { return m.Complexity },
+	"loc":        func(m SymbolComplexity) int { return m.LOC },
+	"params":     func(m SymbolComplexity) int { return m.Params },
+}
+
+// GetComplexityHotspots returns the top symbols ranked by the given metric
+// ("complexity" by default, or "loc" / "params"). A non-positive top returns all symbols.
+func (s *GraphService) GetComplexityHotspots(ctx context.Context, projectID string, top int, sortBy string) ([]SymbolComplexity, error) {
+	if sortBy == "" {
+		sortBy = "complexity"
+	}
+	key, ok := complexitySorters[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort metric %q", errors.ErrInvalidInput, sortBy)
+	}
+
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	var metrics []SymbolComplexity
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateComplexity, "") {
+		if err != nil {
+			continue
+		}
+		complexity, ok := factInt(fact.Object)
+		if !ok {
+			continue
+		}
+		m := SymbolComplexity{ID: fact.Subject, Complexity: complexity}
+		m.LOC = scanInt(ctx, store, fact.Subject, config.PredicateLOC)
+		m.Params = scanInt(ctx, store, fact.Subject, config.PredicateParamCount)
+		metrics = append(metrics, m)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		ki, kj := key(metrics[i]), key(metrics[j])
+		if ki != kj {
+			return ki > kj
+		}
+		if metrics[i].LOC != metrics[j].LOC {
+			return metrics[i].LOC > metrics[j].LOC
+		}
+		return metrics[i].ID < metrics[j].ID
+	})
+
+	if top > 0 && len(metrics) > top {
+		metrics = metrics[:top]
+	}
+	if metrics == nil {
+		metrics = []SymbolComplexity{}
+	}
+	return metrics, nil
+}
+
+func scanInt(ctx context.Context, store *meb.MEBStore, id, predicate string) int {
+	for fact, err := range gcamdb.Scan(ctx, store, id, predicate, "") {
+		if err != nil {
+			continue
+		}
+		if v, ok := factInt(fact.Object); ok {
+			return v
+		}
+	}
+	return 0
+}
+
+// factInt decodes a numeric fact object, which may come back as int, float64 or string.
+func factInt(obj any) (int, bool) {
+	switch v := obj.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Metrics GetTopSymbols ranks symbols by.
+const (
+	MetricDegree   = "degree"   // Calls and imports in and out, boosted for entry points, hubs and interface-like names
+	MetricPageRank = "pagerank" // PageRank over calls
+	MetricFanIn    = "fanin"    // Callers
+)
+
+// topSymbolsCache keeps each project's ranking per metric, recomputed when
+// the project's fact count changes.
+type topSymbolsCache struct {
+	mu      sync.Mutex
+	entries map[topSymbolsKey]topSymbolsEntry
+}
+
+type topSymbolsKey struct {
+	project, metric string
+}
+
+type topSymbolsEntry struct {
+	ranking   []CentralityResult
+	factCount uint64
+}
+
+func newTopSymbolsCache() *topSymbolsCache {
+	return &topSymbolsCache{entries: make(map[topSymbolsKey]topSymbolsEntry)}
+}
+
+// GetTopSymbols returns the limit symbols ranking highest by metric
+// (MetricDegree by default), their centrality the metric divided by the
+// highest. Rankings are computed from the project's adjacency snapshot and
+// kept until facts are written.
+func (s *GraphService) GetTopSymbols(ctx context.Context, projectID, metric string, limit int) ([]CentralityResult, error) {
+	if metric == "" {
+		metric = MetricDegree
+	}
+	switch metric {
+	case MetricDegree, MetricPageRank, MetricFanIn:
+	default:
+		return nil, fmt.Errorf("%w: unknown metric %q", errors.ErrInvalidInput, metric)
+	}
+	if limit <= 0 {
+		limit = config.DefaultSearchLimit
+	}
+
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	key := topSymbolsKey{project: projectID, metric: metric}
+	factCount := store.Count()
+	s.topSymbols.mu.Lock()
+	entry, ok := s.topSymbols.entries[key]
+	s.topSymbols.mu.Unlock()
+	if !ok || entry.factCount != factCount {
+		entry = topSymbolsEntry{ranking: s.rankSymbols(ctx, projectID, store, metric), factCount: factCount}
+		s.topSymbols.mu.Lock()
+		s.topSymbols.entries[key] = entry
+		s.topSymbols.mu.Unlock()
+	}
+
+	ranking := entry.ranking
+	if len(ranking) > limit {
+		ranking = ranking[:limit]
+	}
+	return slices.Clone(ranking), nil
+}
+
+// rankSymbols ranks every symbol with a score under metric, best first.
+func (s *GraphService) rankSymbols(ctx context.Context, projectID string, store *meb.MEBStore, metric string) []CentralityResult {
+	adj := s.adjacency(ctx, projectID, store)
+	if adj == nil {
+		adj = gcamdb.BuildAdjacency(ctx, store, store.Count())
+	}
+	var ranks map[string]float64
+	if metric == MetricPageRank {
+		ranks = pageRank(adj, config.PredicateCalls, config.PageRankIterations, config.PageRankDamping)
+	}
+	entries := make(map[string]bool)
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateIsEntryPoint, "") {
+		if err == nil {
+			entries[fact.Subject] = true
+		}
+	}
+	kinds := s.kindIndexes.get(ctx, projectID, store)
+
+	var results []CentralityResult
+	maxScore := 0.0
+	for _, id := range adj.Nodes() {
+		callsIn, callsOut := adj.Degree(id, config.PredicateCalls)
+		importsIn, importsOut := adj.Degree(id, config.PredicateImports)
+		in, out := callsIn+importsIn, callsOut+importsOut
+		var score float64
+		switch metric {
+		case MetricDegree:
+			score = float64(in+out) * degreeBoost(id, in, out)
+		case MetricPageRank:
+			if callsIn+callsOut > 0 {
+				score = ranks[id]
+			}
+		case MetricFanIn:
+			score = float64(callsIn)
+		}
+		if score <= 0 {
+			continue
+		}
+		maxScore = max(maxScore, score)
+		results = append(results, CentralityResult{
+			SymbolID:   id,
+			Centrality: score,
+			InDegree:   in,
+			OutDegree:  out,
+			Kind:       kinds.Kind(id),
+			IsEntry:    entries[id],
+		})
+	}
+
+	for i := range results {
+		results[i].Centrality /= maxScore
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Centrality != results[j].Centrality {
+			return results[i].Centrality > results[j].Centrality
+		}
+		return results[i].SymbolID < results[j].SymbolID
+	})
+	return results
+}
+
+// degreeBoost weighs up the degree of entry points, hubs and interface-like
+// symbols.
+func degreeBoost(id string, in, out int) float64 {
+	boost := 1.0
+	lower := strings.ToLower(id)
+	if strings.Contains(lower, ":main") || strings.Contains(lower, ".main") ||
+		strings.Contains(lower, ":init") || strings.Contains(lower, ".init") {
+		boost = config.CentralityBoostMain
+	}
+	if out > 10 && in > 5 {
+		boost *= config.CentralityBoostHub
+	}
+	if IsInterfacePattern(id) {
+		boost *= config.CentralityBoostInterface
+	}
+	return boost
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// ModuleContext is what the graph knows about one module (a package directory
+// and its subdirectories), gathered for summarizing it.
+type ModuleContext struct {
+	Path         string         `json:"path"`
+	Files        []string       `json:"files"`
+	Symbols      []ModuleSymbol `json:"symbols"`      // Exported symbols
+	Dependencies []ModuleEdge   `json:"dependencies"` // Packages the module uses
+	Consumers    []ModuleEdge   `json:"consumers"`    // Packages using the module
+	Truncated    bool           `json:"truncated,omitempty"`
+}
+
+// ModuleSymbol is an exported symbol of a module.
+type ModuleSymbol struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind,omitempty"`
+	Summary string `json:"summary,omitempty"` // has_summary, or the first line of has_doc
+}
+
+// ModuleEdge is a package on the other side of a module's calls or imports.
+type ModuleEdge struct {
+	Package string `json:"package"`
+	Count   int    `json:"count"` // Underlying call and import edges
+	// Symbols are the most used symbols across the edge: the ones used in a
+	// dependency, or the callers in a consumer.
+	Symbols  []string `json:"symbols,omitempty"`
+	Internal bool     `json:"internal"`
+}
+
+// GetModuleContext collects the files, exported symbols, dependencies and
+// consumers of the module at modulePath, a package directory relative to the
+// project root (such as "pkg/meb"); directory IDs prefixed with the project name
+// are matched too. Lists are capped by the config.ModuleSummaryMax* settings.
+func (s *GraphService) GetModuleContext(ctx context.Context, projectID, modulePath string) (*ModuleContext, error) {
+	modulePath = strings.Trim(path.Clean("/"+strings.TrimSpace(modulePath)), "/")
+	if modulePath == "" {
+		return nil, fmt.Errorf("%w: module path is required", errors.ErrInvalidInput)
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	refs, internal, err := scanPackageRefs(ctx, store, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: scan module edges: %v", errors.ErrInternal, err)
+	}
+
+	// Files define the module; fall back to the project-prefixed directory
+	var files []string
+	for _, dir := range []string{modulePath, projectID + "/" + modulePath} {
+		for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateType, config.SymbolKindFile) {
+			if err == nil && inModule(packageOf(fact.Subject), dir) {
+				files = append(files, fact.Subject)
+			}
+		}
+		if len(files) > 0 {
+			modulePath = dir
+			break
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: module %s has no files", errors.ErrNotFound, modulePath)
+	}
+	sort.Strings(files)
+
+	mc := &ModuleContext{Path: modulePath}
+	if len(files) > config.ModuleSummaryMaxFiles {
+		mc.Truncated = true
+	}
+	mc.Files = files[:min(len(files), config.ModuleSummaryMaxFiles)]
+
+	exports := make(map[string]bool)
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateExports, "") {
+		if id, ok := fact.Object.(string); err == nil && ok {
+			exports[id] = true
+		}
+	}
+	for _, file := range files {
+		for fact, err := range gcamdb.Scan(ctx, store, file, config.PredicateDefines, "") {
+			id, ok := fact.Object.(string)
+			if err != nil || !ok {
+				continue
+			}
+			_, name, _ := strings.Cut(id, ":")
+			if !ingest.IsExportedSymbol(id, name, exports) {
+				continue
+			}
+			if len(mc.Symbols) == config.ModuleSummaryMaxSymbols {
+				mc.Truncated = true
+				break
+			}
+			mc.Symbols = append(mc.Symbols, moduleSymbol(ctx, store, id))
+		}
+	}
+
+	// Roll the project's edges crossing the module boundary up to packages
+	type rollup struct {
+		count int
+		uses  map[string]int
+	}
+	deps := make(map[string]*rollup)
+	consumers := make(map[string]*rollup)
+	add := func(m map[string]*rollup, pkg, symbol string) {
+		r := m[pkg]
+		if r == nil {
+			r = &rollup{uses: make(map[string]int)}
+			m[pkg] = r
+		}
+		r.count++
+		if strings.Contains(symbol, ":") {
+			r.uses[symbol]++
+		}
+	}
+	for _, r := range refs {
+		if r.sourcePkg == "" || r.targetPkg == "" {
+			continue
+		}
+		from, to := inModule(r.sourcePkg, modulePath), inModule(r.targetPkg, modulePath)
+		switch {
+		case from && !to:
+			add(deps, r.targetPkg, r.target)
+		case to && !from:
+			add(consumers, r.sourcePkg, r.source)
+		}
+	}
+	edges := func(m map[string]*rollup) []ModuleEdge {
+		out := make([]ModuleEdge, 0, len(m))
+		for pkg, r := range m {
+			_, isInternal := internal[pkg]
+			out = append(out, ModuleEdge{Package: pkg, Count: r.count, Symbols: topUses(r.uses), Internal: isInternal})
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Count != out[j].Count {
+				return out[i].Count > out[j].Count
+			}
+			return out[i].Package < out[j].Package
+		})
+		if len(out) > config.ModuleSummaryMaxEdges {
+			mc.Truncated = true
+			out = out[:config.ModuleSummaryMaxEdges]
+		}
+		return out
+	}
+	mc.Dependencies = edges(deps)
+	mc.Consumers = edges(consumers)
+	return mc, nil
+}
+
+// moduleSymbol looks up the kind and one-line summary of a symbol.
+func moduleSymbol(ctx context.Context, store *meb.MEBStore, id string) ModuleSymbol {
+	sym := ModuleSymbol{ID: id}
+	var doc string
+	for fact, err := range gcamdb.Scan(ctx, store, id, "", "") {
+		str, ok := fact.Object.(string)
+		if err != nil || !ok {
+			continue
+		}
+		switch fact.Predicate {
+		case config.PredicateType:
+			sym.Kind = str
+		case config.PredicateHasSummary:
+			sym.Summary = str
+		case config.PredicateHasDoc:
+			doc, _, _ = strings.Cut(strings.TrimSpace(str), "\n")
+		}
+	}
+	if sym.Summary == "" {
+		sym.Summary = doc
+	}
+	return sym
+}
+
+// topUses returns the most used symbols of an edge, most used first.
+func topUses(uses map[string]int) []string {
+	ids := make([]string, 0, len(uses))
+	for id := range uses {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if uses[ids[i]] != uses[ids[j]] {
+			return uses[ids[i]] > uses[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids[:min(len(ids), config.ModuleSummaryMaxCitations)]
+}
+
+// inModule reports whether pkg is the module directory or one of its subdirectories.
+func inModule(pkg, module string) bool {
+	return pkg == module || strings.HasPrefix(pkg, module+"/")
+}
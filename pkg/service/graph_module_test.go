@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestGetModuleContext(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "app/db/store.go", Predicate: "type", Object: "file"},
+		{Subject: "app/db/sql/query.go", Predicate: "type", Object: "file"},
+		{Subject: "app/api/handler.go", Predicate: "type", Object: "file"},
+		{Subject: "app/db/store.go", Predicate: "defines", Object: "app/db/store.go:Store"},
+		{Subject: "app/db/store.go", Predicate: "defines", Object: "app/db/store.go:Open"},
+		{Subject: "app/db/store.go", Predicate: "defines", Object: "app/db/store.go:dial"},
+		{Subject: "app/db/store.go:Store", Predicate: "type", Object: "struct"},
+		{Subject: "app/db/store.go:Store", Predicate: "has_doc", Object: "Store wraps a connection.\nMore."},
+		{Subject: "app/db/store.go:Open", Predicate: "has_summary", Object: "Opens a store."},
+		{Subject: "app/db/store.go:Open", Predicate: "has_doc", Object: "Open opens a store."},
+		{Subject: "app/db/store.go", Predicate: "imports", Object: "database/sql"},
+		{Subject: "app/db/store.go:Open", Predicate: "calls", Object: "sql.Open"},
+		{Subject: "app/db/store.go:Open", Predicate: "calls", Object: "app/db/sql/query.go:Build"},
+		{Subject: "app/api/handler.go", Predicate: "imports", Object: "github.com/acme/app/db"},
+		{Subject: "app/api/handler.go:Get", Predicate: "calls", Object: "app/db/store.go:Open"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewGraphService(&MockStoreManager{store: s})
+	mc, err := svc.GetModuleContext(context.Background(), "app", "db/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mc.Path != "app/db" {
+		t.Errorf("path = %q, want app/db", mc.Path)
+	}
+	if got := fmt.Sprint(mc.Files); got != "[app/db/sql/query.go app/db/store.go]" {
+		t.Errorf("files = %s", got)
+	}
+	want := []ModuleSymbol{
+		{ID: "app/db/store.go:Store", Kind: "struct", Summary: "Store wraps a connection."},
+		{ID: "app/db/store.go:Open", Summary: "Opens a store."},
+	}
+	if fmt.Sprint(mc.Symbols) != fmt.Sprint(want) && fmt.Sprint(mc.Symbols) != fmt.Sprint([]ModuleSymbol{want[1], want[0]}) {
+		t.Errorf("symbols = %v, want %v", mc.Symbols, want)
+	}
+	// The call into app/db/sql stays inside the module
+	if got := fmt.Sprint(mc.Dependencies); got != "[{database/sql 2 [] false}]" {
+		t.Errorf("dependencies = %s", got)
+	}
+	if got := fmt.Sprint(mc.Consumers); got != "[{app/api 2 [app/api/handler.go:Get] true}]" {
+		t.Errorf("consumers = %s", got)
+	}
+
+	if _, err := svc.GetModuleContext(context.Background(), "app", "missing"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("missing module error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// signatureLines is how many lines codeSignature reads looking for the one
+// that opens a declaration's body.
+const signatureLines = 10
+
+// SymbolOverview is what a node-detail panel shows about one symbol: its code
+// and declaration, its neighbors in the call graph, its metrics, and the
+// metadata enrichment passes recorded for it.
+type SymbolOverview struct {
+	ID              string        `json:"id"`
+	UID             string        `json:"uid,omitempty"`
+	Kind            string        `json:"kind"`
+	Language        string        `json:"language,omitempty"`
+	File            string        `json:"file,omitempty"`
+	StartLine       int           `json:"start_line,omitempty"`
+	EndLine         int           `json:"end_line,omitempty"`
+	Signature       string        `json:"signature,omitempty"`
+	Doc             string        `json:"doc,omitempty"`
+	Summary         string        `json:"summary,omitempty"` // LLM summary written by the summarize pass
+	Code            string        `json:"code,omitempty"`
+	Callers         []string      `json:"callers"`
+	Callees         []string      `json:"callees"`
+	Metrics         SymbolMetrics `json:"metrics"`
+	Tags            []string      `json:"tags"`
+	Owners          []string      `json:"owners"`
+	Vulnerabilities []string      `json:"vulnerabilities,omitempty"`
+	EntryPoint      string        `json:"entry_point,omitempty"` // Kind of entry point, such as "main" or "handler"
+}
+
+// SymbolMetrics are the size and connectivity figures of a symbol. Callers and
+// Callees count every neighbor, however many SymbolOverview lists.
+type SymbolMetrics struct {
+	LOC        int     `json:"loc,omitempty"`
+	Complexity int     `json:"complexity,omitempty"`
+	Params     int     `json:"params,omitempty"`
+	Callers    int     `json:"callers"`
+	Callees    int     `json:"callees"`
+	Centrality float64 `json:"centrality,omitempty"` // Set on the symbols warmup found most central
+}
+
+// GetSymbolOverview gathers everything the detail panel of symbol id shows in
+// one call, listing up to limit callers and callees (config.OverviewNeighborLimit
+// when limit is not positive). The ID may be a UID or lack the project prefix,
+// as with GetSymbol.
+func (s *GraphService) GetSymbolOverview(ctx context.Context, projectID, id string, limit int) (*SymbolOverview, error) {
+	if limit <= 0 {
+		limit = config.OverviewNeighborLimit
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Symbols without a source range, such as packages, still have an overview
+	hs, err := s.GetSymbolFields(ctx, projectID, id, HydrateMetadata|HydrateContent)
+	if stderrors.Is(err, errors.ErrNotFound) {
+		hs, err = s.GetSymbolFields(ctx, projectID, id, HydrateMetadata)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+	id = hs.ID
+
+	ov := &SymbolOverview{
+		ID:        id,
+		Kind:      hs.Kind,
+		Code:      hs.Content,
+		Signature: codeSignature(hs.Content),
+		Tags:      []string{},
+		Owners:    resolveOwners(ctx, store, id),
+	}
+	if ov.Kind == "" {
+		ov.Kind = s.kindIndexes.get(ctx, projectID, store).Kind(id)
+	}
+	if file, _, ok := strings.Cut(id, ":"); ok {
+		ov.File = file
+	}
+	ov.UID, _ = hs.Metadata["uid"].(string)
+	ov.Language, _ = hs.Metadata["language"].(string)
+	ov.StartLine, _ = hs.Metadata["start_line"].(int)
+	ov.EndLine, _ = hs.Metadata["end_line"].(int)
+	ov.Summary, _ = hs.Metadata["summary"].(string)
+	ov.Vulnerabilities, _ = hs.Metadata["vulnerabilities"].([]string)
+	if ov.Owners == nil {
+		ov.Owners = []string{}
+	}
+
+	for fact, err := range gcamdb.Scan(ctx, store, id, "", "") {
+		if err != nil {
+			continue
+		}
+		str, _ := fact.Object.(string)
+		switch fact.Predicate {
+		case config.PredicateHasDoc:
+			if ov.Doc == "" {
+				ov.Doc = str
+			}
+		case config.PredicateHasTag, config.PredicateHasRole:
+			if str != "" && !slices.Contains(ov.Tags, str) {
+				ov.Tags = append(ov.Tags, str)
+			}
+		case config.PredicateIsEntryPoint:
+			ov.EntryPoint = str
+		case config.PredicateLOC:
+			ov.Metrics.LOC, _ = factInt(fact.Object)
+		case config.PredicateComplexity:
+			ov.Metrics.Complexity, _ = factInt(fact.Object)
+		case config.PredicateParamCount:
+			ov.Metrics.Params, _ = factInt(fact.Object)
+		case config.PredicateHasCentrality:
+			ov.Metrics.Centrality, _ = factFloat(fact.Object)
+		}
+	}
+	slices.Sort(ov.Tags)
+
+	callers, callees := s.callNeighbors(ctx, projectID, store, id)
+	ov.Metrics.Callers, ov.Metrics.Callees = len(callers), len(callees)
+	ov.Callers = callers[:min(limit, len(callers))]
+	ov.Callees = callees[:min(limit, len(callees))]
+	return ov, nil
+}
+
+// callNeighbors returns the symbols that call id and those it calls, each
+// sorted, from the adjacency snapshot when it is fresh.
+func (s *GraphService) callNeighbors(ctx context.Context, projectID string, store *meb.MEBStore, id string) (callers, callees []string) {
+	if adj := s.adjacency(ctx, projectID, store); adj != nil {
+		callers, callees = adj.In(id, config.PredicateCalls), adj.Out(id, config.PredicateCalls)
+	} else {
+		for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateCalls, id) {
+			if err == nil && !slices.Contains(callers, fact.Subject) {
+				callers = append(callers, fact.Subject)
+			}
+		}
+		for fact, err := range gcamdb.Scan(ctx, store, id, config.PredicateCalls, "") {
+			if obj, ok := fact.Object.(string); err == nil && ok && !slices.Contains(callees, obj) {
+				callees = append(callees, obj)
+			}
+		}
+		slices.Sort(callers)
+		slices.Sort(callees)
+	}
+	if callers == nil {
+		callers = []string{}
+	}
+	if callees == nil {
+		callees = []string{}
+	}
+	return callers, callees
+}
+
+// codeSignature returns the declaration code opens with: its lines up to the
+// one opening the body, joined without the brace or colon. Leading comments,
+// decorators and annotations are skipped. When no body opens within
+// signatureLines lines, the first line is returned.
+func codeSignature(code string) string {
+	var lines []string
+	for line := range strings.Lines(code) {
+		line = strings.TrimSpace(line)
+		if len(lines) == 0 && (line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "@")) {
+			continue
+		}
+		if len(lines) == signatureLines {
+			break
+		}
+		if head, ok := strings.CutSuffix(line, "{"); ok {
+			return strings.Join(append(lines, strings.TrimSpace(head)), " ")
+		}
+		if head, ok := strings.CutSuffix(line, ":"); ok {
+			return strings.Join(append(lines, head), " ")
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return lines[0]
+}
+
+// factFloat decodes a fractional fact object, which may come back as a float,
+// an integer or a string.
+func factFloat(obj any) (float64, bool) {
+	switch v := obj.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	n, ok := factInt(obj)
+	return float64(n), ok
+}
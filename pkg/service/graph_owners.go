@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/meb"
+)
+
+// GetOwners returns the owners recorded for a symbol or file.
+// Symbols without their own owned_by facts fall back to their containing file.
+func (s *GraphService) GetOwners(ctx context.Context, projectID, id string) ([]string, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+	return resolveOwners(ctx, store, id), nil
+}
+
+// FilterGraphByOwner keeps only the nodes owned by owner and the links between them.
+// Owner matching is case-insensitive and ignores a leading "@".
+func (s *GraphService) FilterGraphByOwner(ctx context.Context, projectID string, graph *export.D3Graph, owner string) (*export.D3Graph, error) {
+	if graph == nil || owner == "" {
+		return graph, nil
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	want := normalizeOwner(owner)
+	kept := make(map[string]bool)
+	filtered := &export.D3Graph{
+		Nodes: make([]export.D3Node, 0),
+		Links: make([]export.D3Link, 0),
+	}
+
+	for _, n := range graph.Nodes {
+		for _, o := range resolveOwners(ctx, store, n.ID) {
+			if normalizeOwner(o) == want {
+				kept[n.ID] = true
+				filtered.Nodes = append(filtered.Nodes, n)
+				break
+			}
+		}
+	}
+	for _, l := range graph.Links {
+		if kept[l.Source] && kept[l.Target] {
+			filtered.Links = append(filtered.Links, l)
+		}
+	}
+	return filtered, nil
+}
+
+// resolveOwners looks up owned_by facts for id, falling back to the file part of a symbol ID.
+func resolveOwners(ctx context.Context, store *meb.MEBStore, id string) []string {
+	if owners := scanOwners(ctx, store, id); len(owners) > 0 {
+		return owners
+	}
+	if idx := strings.LastIndex(id, ":"); idx > 0 {
+		return scanOwners(ctx, store, id[:idx])
+	}
+	return nil
+}
+
+func normalizeOwner(owner string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(owner), "@"))
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// packageEdge identifies a rolled-up edge between two packages.
+type packageEdge struct {
+	source, target, relation string
+}
+
+// GetPackageGraph rolls the project's calls and imports up to package level: one
+// node per package directory (plus the external packages it uses) and one link per
+// package pair and relation, weighted by the number of symbol or file edges it
+// stands for. Edges within a package are dropped. It reads each predicate with
+// a single predicate scan instead of exporting the symbol graph. With external
+// false, links to packages outside the project are left out.
+func (s *GraphService) GetPackageGraph(ctx context.Context, projectID string, external bool) (*export.D3Graph, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	cacheKey := graphCacheKey{Project: projectID, Query: "package_graph"}
+	if !external {
+		cacheKey.Query = "package_graph:internal"
+	}
+	factCount := store.Count()
+	if graph, ok := s.graphCache.get(cacheKey, factCount); ok {
+		return graph, nil
+	}
+
+	refs, internal, err := scanPackageRefs(ctx, store, projectID)
+	if err != nil {
+		return nil, err
+	}
+	weights := make(map[packageEdge]int)
+	for _, r := range refs {
+		if r.sourcePkg == "" || r.targetPkg == "" || r.sourcePkg == r.targetPkg {
+			continue
+		}
+		if _, ok := internal[r.targetPkg]; !ok && !external {
+			continue
+		}
+		weights[packageEdge{r.sourcePkg, r.targetPkg, r.relation}]++
+	}
+
+	graph := packageGraph(internal, weights)
+	s.graphCache.set(cacheKey, factCount, graph)
+	return graph, nil
+}
+
+// packageRef is a call or import edge with both ends resolved to packages. An
+// empty targetPkg means the callee could not be resolved.
+type packageRef struct {
+	source, target       string // File or symbol IDs
+	sourcePkg, targetPkg string
+	relation             string
+}
+
+// scanPackageRefs reads the calls and imports visible to ctx with one predicate
+// scan each and resolves their ends to packages. It also returns the project's
+// packages, the directories of the files it defines, with their file counts.
+func scanPackageRefs(ctx context.Context, store *meb.MEBStore, projectID string) ([]packageRef, map[string]int, error) {
+	type edge struct{ source, target string }
+	scan := func(predicate string) ([]edge, error) {
+		var edges []edge
+		for fact, err := range gcamdb.Scan(ctx, store, "", predicate, "") {
+			if err != nil {
+				return nil, err
+			}
+			if target, ok := fact.Object.(string); ok {
+				edges = append(edges, edge{fact.Subject, target})
+			}
+		}
+		return edges, nil
+	}
+	calls, err := scan(config.PredicateCalls)
+	if err != nil {
+		return nil, nil, err
+	}
+	imports, err := scan(config.PredicateImports)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	internal := make(map[string]int) // package -> files seen
+	seenFiles := make(map[string]bool)
+	addFile := func(file string) {
+		if !seenFiles[file] {
+			seenFiles[file] = true
+			internal[packageOf(file)]++
+		}
+	}
+	for _, e := range imports {
+		addFile(e.source)
+	}
+	for _, e := range calls {
+		if file, _, ok := strings.Cut(e.source, ":"); ok {
+			addFile(file)
+		}
+	}
+	resolve := newImportResolver(projectID, internal)
+
+	// Unresolved callees ("db.Open") name a package the calling file imports
+	fileImports := make(map[string]map[string]string) // file -> import name -> import path
+	for _, e := range imports {
+		imp := strings.Trim(e.target, `"'`)
+		if fileImports[e.source] == nil {
+			fileImports[e.source] = make(map[string]string)
+		}
+		fileImports[e.source][path.Base(imp)] = imp
+	}
+
+	refs := make([]packageRef, 0, len(imports)+len(calls))
+	for _, e := range imports {
+		refs = append(refs, packageRef{
+			source:    e.source,
+			target:    e.target,
+			sourcePkg: packageOf(e.source),
+			targetPkg: resolve(e.target),
+			relation:  config.PredicateImports,
+		})
+	}
+	for _, e := range calls {
+		file, _, ok := strings.Cut(e.source, ":")
+		if !ok {
+			continue
+		}
+		target := ""
+		if calleeFile, _, ok := strings.Cut(e.target, ":"); ok {
+			target = packageOf(calleeFile)
+		} else if qualifier, _, ok := strings.Cut(e.target, "."); ok {
+			if imp, ok := fileImports[file][qualifier]; ok {
+				target = resolve(imp)
+			}
+		}
+		refs = append(refs, packageRef{
+			source:    e.source,
+			target:    e.target,
+			sourcePkg: packageOf(file),
+			targetPkg: target,
+			relation:  config.PredicateCalls,
+		})
+	}
+	return refs, internal, nil
+}
+
+// packageGraph builds the rolled-up graph, with nodes and links in a stable order.
+func packageGraph(internal map[string]int, weights map[packageEdge]int) *export.D3Graph {
+	graph := &export.D3Graph{Nodes: []export.D3Node{}, Links: make([]export.D3Link, 0, len(weights))}
+	nodes := make(map[string]bool)
+	addNode := func(id string) {
+		if nodes[id] {
+			return
+		}
+		nodes[id] = true
+		files, isInternal := internal[id]
+		node := export.D3Node{
+			ID:         id,
+			Name:       path.Base(id),
+			Kind:       "package",
+			Group:      "package",
+			ChildCount: files,
+			IsInternal: &isInternal,
+		}
+		if !isInternal {
+			node.Kind = config.SymbolKindExternalPackage
+			node.Group = "external"
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for pkg := range internal {
+		addNode(pkg)
+	}
+	for e, n := range weights {
+		addNode(e.target)
+		graph.Links = append(graph.Links, export.D3Link{
+			Source:   e.source,
+			Target:   e.target,
+			Relation: e.relation,
+			Weight:   float64(n),
+			Count:    n,
+			Type:     "ast",
+		})
+	}
+
+	graph.Sort()
+	return graph
+}
+
+// packageOf returns the package directory of a file; top-level files belong to
+// the root package.
+func packageOf(file string) string {
+	dir := path.Dir(file)
+	if dir == "." || dir == "/" {
+		return config.DefaultPackageRoot
+	}
+	return dir
+}
+
+// newImportResolver maps import paths onto the project's package directories:
+// "github.com/acme/app/pkg/db" resolves to "app/pkg/db" or "pkg/db" when the
+// project has it. Imports of other packages are returned unchanged.
+func newImportResolver(projectID string, internal map[string]int) func(string) string {
+	// Longest directories first, so the most specific match wins
+	dirs := make([]string, 0, len(internal))
+	for dir := range internal {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if len(dirs[i]) != len(dirs[j]) {
+			return len(dirs[i]) > len(dirs[j])
+		}
+		return dirs[i] < dirs[j]
+	})
+	resolved := make(map[string]string)
+	return func(imp string) string {
+		imp = strings.Trim(imp, `"'`)
+		if target, ok := resolved[imp]; ok {
+			return target
+		}
+		target := imp
+		if _, ok := internal[imp]; !ok {
+			for _, dir := range dirs {
+				if dir == config.DefaultPackageRoot {
+					continue
+				}
+				rel := strings.TrimPrefix(dir, projectID+"/")
+				if imp == rel || strings.HasSuffix(imp, "/"+rel) || strings.HasSuffix(imp, "/"+dir) {
+					target = dir
+					break
+				}
+			}
+		}
+		resolved[imp] = target
+		return target
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestGetPackageGraph(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "app/api/handler.go", Predicate: "imports", Object: "github.com/acme/app/db"},
+		{Subject: "app/api/handler.go", Predicate: "imports", Object: "fmt"},
+		{Subject: "app/api/routes.go", Predicate: "imports", Object: "github.com/acme/app/db"},
+		{Subject: "app/api/handler.go:Get", Predicate: "calls", Object: "app/db/store.go:Open"},
+		{Subject: "app/api/handler.go:Get", Predicate: "calls", Object: "app/db/store.go:Query"},
+		{Subject: "app/api/handler.go:Get", Predicate: "calls", Object: "app/api/routes.go:route"},
+		{Subject: "app/api/handler.go:Get", Predicate: "calls", Object: "fmt.Println"},
+		{Subject: "app/api/handler.go:Get", Predicate: "calls", Object: "s.cache.Get"},
+		{Subject: "app/db/store.go:Open", Predicate: "calls", Object: "app/db/store.go:Query"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewGraphService(&MockStoreManager{store: s})
+	links := func(external bool) map[string]int {
+		t.Helper()
+		g, err := svc.GetPackageGraph(context.Background(), "app", external)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]int)
+		for _, l := range g.Links {
+			got[fmt.Sprintf("%s -%s-> %s", l.Source, l.Relation, l.Target)] = l.Count
+		}
+		return got
+	}
+
+	want := map[string]int{
+		"app/api -imports-> app/db": 2,
+		"app/api -imports-> fmt":    1,
+		"app/api -calls-> app/db":   2,
+		"app/api -calls-> fmt":      1,
+	}
+	if got := links(true); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("links = %v, want %v", got, want)
+	}
+
+	delete(want, "app/api -imports-> fmt")
+	delete(want, "app/api -calls-> fmt")
+	if got := links(false); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("internal links = %v, want %v", got, want)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/duynguyendang/gca/pkg/common"
+	"github.com/duynguyendang/gca/pkg/common/deadline"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/export"
 	gcamdb "github.com/duynguyendang/gca/pkg/meb"
@@ -19,6 +20,7 @@ func (s *GraphService) GetFileGraph(ctx context.Context, projectID, fileID strin
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	cleanFileID := strings.Trim(fileID, "\"")
 
@@ -37,6 +39,7 @@ func (s *GraphService) GetFileGraph(ctx context.Context, projectID, fileID strin
 	}
 
 	linkMap := make(map[string]bool)
+	transformer := s.newTransformer(ctx, projectID, store)
 
 	merge := func(query string) error {
 		results, err := gcamdb.Query(ctx, store, query)
@@ -47,7 +50,7 @@ func (s *GraphService) GetFileGraph(ctx context.Context, projectID, fileID strin
 			return nil
 		}
 
-		subGraph, err := export.ExportD3(ctx, store, query, results)
+		subGraph, err := transformer.Transform(ctx, query, results)
 		if err != nil {
 			return err
 		}
@@ -88,6 +91,10 @@ func (s *GraphService) GetFileGraph(ctx context.Context, projectID, fileID strin
 		if err := merge(q3); err != nil {
 			return nil, fmt.Errorf("failed to get calls: %w", err)
 		}
+		q4 := fmt.Sprintf("triples(?s, \"%s\", ?t), triples(%s, \"%s\", ?s)", config.PredicateRenders, quotedFileID, config.PredicateDefines)
+		if err := merge(q4); err != nil {
+			return nil, fmt.Errorf("failed to get rendered components: %w", err)
+		}
 	}
 
 	if len(mergedGraph.Nodes) > 0 {
@@ -96,9 +103,10 @@ func (s *GraphService) GetFileGraph(ctx context.Context, projectID, fileID strin
 		}
 	}
 
-	s.resolvePackageImportsToFiles(ctx, store, mergedGraph, cleanFileID)
+	files := s.kindIndexes.get(ctx, projectID, store)
+	s.resolvePackageImportsToFiles(ctx, store, files, s.adjacency(ctx, projectID, store), mergedGraph, cleanFileID)
 
-	s.filterToFilesOnly(mergedGraph)
+	s.filterToFilesOnly(mergedGraph, files)
 
 	return mergedGraph, nil
 }
@@ -111,8 +119,9 @@ func extractFileFromID(id string) string {
 	return id
 }
 
-// filterToFilesOnly removes function-level nodes and aggregates links to file level
-func (s *GraphService) filterToFilesOnly(graph *export.D3Graph) {
+// filterToFilesOnly removes function-level nodes and aggregates links to file level.
+// Nodes are internal when files holds them as ingested files.
+func (s *GraphService) filterToFilesOnly(graph *export.D3Graph, files *gcamdb.KindIndex) {
 	fileNodes := make(map[string]export.D3Node)
 
 	for _, n := range graph.Nodes {
@@ -122,7 +131,7 @@ func (s *GraphService) filterToFilesOnly(graph *export.D3Graph) {
 			if idx := strings.LastIndex(fileID, "/"); idx != -1 {
 				fileName = fileID[idx+1:]
 			}
-			isInternal := true
+			isInternal := files.IsIngestedFile(fileID)
 			fileNodes[fileID] = export.D3Node{
 				ID:         fileID,
 				Name:       fileName,
@@ -162,14 +171,18 @@ func (s *GraphService) filterToFilesOnly(graph *export.D3Graph) {
 
 	graph.Nodes = newNodes
 	graph.Links = newLinks
+	graph.Sort()
 }
 
-// resolvePackageImportsToFiles expands package import nodes to show actual files
-func (s *GraphService) resolvePackageImportsToFiles(ctx context.Context, store *meb.MEBStore, graph *export.D3Graph, sourceFileID string) {
+// resolvePackageImportsToFiles expands package import nodes, those files knows
+// as packages, to show actual files. A first-party import is resolved with the
+// imports_file facts of the importing file when ingest recorded them, and by
+// matching package paths otherwise, on the adjacency snapshot adj when there is one.
+func (s *GraphService) resolvePackageImportsToFiles(ctx context.Context, store *meb.MEBStore, files *gcamdb.KindIndex, adj *gcamdb.Adjacency, graph *export.D3Graph, sourceFileID string) {
 	packagesToResolve := make(map[string]bool)
 
 	for _, n := range graph.Nodes {
-		if !strings.Contains(n.ID, ":") && strings.Contains(n.ID, "/") && !strings.Contains(n.ID, ".go") {
+		if kind := files.Kind(n.ID); kind == config.SymbolKindPackage || kind == config.SymbolKindExternalPackage {
 			packagesToResolve[n.ID] = true
 		}
 	}
@@ -178,39 +191,92 @@ func (s *GraphService) resolvePackageImportsToFiles(ctx context.Context, store *
 		return
 	}
 
-	for pkgPath := range packagesToResolve {
-		files := s.findFilesWithPrefix(store, pkgPath)
+	importedFiles := make(map[string][]string)
+	filesOf := func(source, pkgPath string) []string {
+		imported, ok := importedFiles[source]
+		if !ok {
+			for fact, err := range gcamdb.Scan(ctx, store, source, config.PredicateImportsFile, "") {
+				if f, isStr := fact.Object.(string); err == nil && isStr && fact.Subject == source {
+					imported = append(imported, f)
+				}
+			}
+			importedFiles[source] = imported
+		}
+		// The files of the imported package share the longest path suffix with it
+		var files []string
+		best := 0
+		for _, f := range imported {
+			switch n := packageSuffixMatch(f, pkgPath); {
+			case n > best:
+				files, best = []string{f}, n
+			case n == best && n > 0:
+				files = append(files, f)
+			}
+		}
+		return files
+	}
 
-		if len(files) == 0 {
-			continue
+	for pkgPath := range packagesToResolve {
+		external := false
+		for fact, err := range gcamdb.Scan(ctx, store, pkgPath, config.PredicateIsInternal, "") {
+			if internal, ok := fact.Object.(bool); err == nil && ok && fact.Subject == pkgPath && !internal {
+				external = true
+			}
 		}
 
+		var prefixFiles []string
+		prefixResolved := false
+		nodeFiles := make(map[string]bool)
+		var fileOrder []string
+
 		var newLinks []export.D3Link
 		for _, l := range graph.Links {
-			if l.Target == pkgPath {
-				for _, f := range files {
-					newLinks = append(newLinks, export.D3Link{
-						Source:   l.Source,
-						Target:   f,
-						Relation: l.Relation,
-						Type:     l.Type,
-					})
+			if l.Target != pkgPath {
+				newLinks = append(newLinks, l)
+				continue
+			}
+			var files []string
+			if !external {
+				files = filesOf(l.Source, pkgPath)
+			}
+			if len(files) == 0 {
+				if !prefixResolved {
+					prefixFiles = findFilesWithPrefix(ctx, store, adj, pkgPath)
+					prefixResolved = true
 				}
-			} else {
+				files = prefixFiles
+			}
+			if len(files) == 0 {
 				newLinks = append(newLinks, l)
+				continue
+			}
+			for _, f := range files {
+				newLinks = append(newLinks, export.D3Link{
+					Source:   l.Source,
+					Target:   f,
+					Relation: l.Relation,
+					Type:     l.Type,
+				})
+				if !nodeFiles[f] {
+					nodeFiles[f] = true
+					fileOrder = append(fileOrder, f)
+				}
 			}
 		}
+		if len(fileOrder) == 0 {
+			continue
+		}
 		graph.Links = newLinks
 
 		var newNodes []export.D3Node
 		for _, n := range graph.Nodes {
 			if n.ID == pkgPath {
-				for _, f := range files {
+				for _, f := range fileOrder {
 					fileName := f
 					if idx := strings.LastIndex(f, "/"); idx != -1 {
 						fileName = f[idx+1:]
 					}
-					isInternal := true
+					isInternal := files.IsIngestedFile(f)
 					newNodes = append(newNodes, export.D3Node{
 						ID:         f,
 						Name:       fileName,
@@ -226,53 +292,73 @@ func (s *GraphService) resolvePackageImportsToFiles(ctx context.Context, store *
 	}
 }
 
-// findFilesWithPrefix finds all ingested files that match a package path.
-func (s *GraphService) findFilesWithPrefix(store *meb.MEBStore, prefix string) []string {
+// packageSuffixMatch counts the trailing segments of an import path that match
+// the trailing directories of a file.
+func packageSuffixMatch(file, pkgPath string) int {
+	dirs := strings.Split(file, "/")
+	dirs = dirs[:len(dirs)-1]
+	parts := strings.Split(pkgPath, "/")
+	n := 0
+	for n < len(dirs) && n < len(parts) && dirs[len(dirs)-1-n] == parts[len(parts)-1-n] {
+		n++
+	}
+	return n
+}
+
+// findFilesWithPrefix finds all ingested files that match a package path: those
+// under it, and those in a package whose name ends like it. With a snapshot adj,
+// the files under it are a range of its sorted subjects and package names are
+// matched once each, rather than once per file as scanning the store does.
+func findFilesWithPrefix(ctx context.Context, store *meb.MEBStore, adj *gcamdb.Adjacency, prefix string) []string {
 	var files []string
 	seen := make(map[string]bool)
-
-	toSlashed := func(p string) string {
-		return strings.ReplaceAll(p, ".", "/")
-	}
-
-	for fact, _ := range store.Scan("", config.PredicateInPackage, "") {
-		filePath := string(fact.Subject)
-		pkgName, ok := fact.Object.(string)
-		if !ok {
-			continue
+	add := func(filePath string) bool {
+		if !seen[filePath] {
+			seen[filePath] = true
+			files = append(files, filePath)
 		}
+		return len(files) < config.MaxPackageFilesToResolve
+	}
 
-		matched := false
-
-		if strings.HasPrefix(filePath, prefix) {
-			matched = true
+	parts := strings.Split(prefix, "/")
+	inPackage := func(pkgName string) bool {
+		internalPkg := strings.ReplaceAll(pkgName, ".", "/")
+		if len(parts) > 2 {
+			return strings.Contains(internalPkg, strings.Join(parts[len(parts)-2:], "/"))
 		}
+		suffix := parts[len(parts)-1]
+		return strings.HasSuffix(internalPkg, "/"+suffix) || internalPkg == suffix
+	}
 
-		internalPkg := toSlashed(pkgName)
-
-		parts := strings.Split(prefix, "/")
-		if len(parts) > 2 {
-			suffix := strings.Join(parts[len(parts)-2:], "/")
-			if strings.Contains(internalPkg, suffix) {
-				matched = true
+	if adj == nil {
+		for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateInPackage, "") {
+			pkgName, ok := fact.Object.(string)
+			if err != nil || !ok {
+				continue
 			}
-		} else if len(parts) > 0 {
-			suffix := parts[len(parts)-1]
-			if strings.HasSuffix(internalPkg, "/"+suffix) || internalPkg == suffix {
-				matched = true
+			if (strings.HasPrefix(fact.Subject, prefix) || inPackage(pkgName)) && !add(fact.Subject) {
+				break
 			}
 		}
+		return files
+	}
 
-		if matched && !seen[filePath] {
-			seen[filePath] = true
-			files = append(files, filePath)
+	under := gcamdb.ScanOptions{SubjectPrefixes: []string{prefix}, Index: adj}
+	for fact, err := range gcamdb.ScanMatching(ctx, store, "", config.PredicateInPackage, "", under) {
+		if err == nil && !add(fact.Subject) {
+			return files
 		}
 	}
-
-	if len(files) > config.MaxPackageFilesToResolve {
-		files = files[:config.MaxPackageFilesToResolve]
+	for _, pkgName := range adj.Targets(config.PredicateInPackage) {
+		if !inPackage(pkgName) {
+			continue
+		}
+		for _, filePath := range adj.In(pkgName, config.PredicateInPackage) {
+			if !add(filePath) {
+				return files
+			}
+		}
 	}
-
 	return files
 }
 
@@ -292,14 +378,6 @@ func (s *GraphService) GetFileCalls(ctx context.Context, projectID, fileID strin
 		depth = config.MaxFileDepthLimit
 	}
 
-	cacheKey := fmt.Sprintf("file_calls:%s:%d", fileID, depth)
-	s.cacheMu.RLock()
-	if cached, ok := s.projectMapCache[cacheKey]; ok {
-		s.cacheMu.RUnlock()
-		return cached, nil
-	}
-	s.cacheMu.RUnlock()
-
 	store, err := s.getStore(projectID)
 	if err != nil {
 		logger.Error("GetFileCalls getStore error", "error", err)
@@ -309,6 +387,13 @@ func (s *GraphService) GetFileCalls(ctx context.Context, projectID, fileID strin
 		logger.Error("GetFileCalls store is nil", "projectID", projectID)
 		return nil, fmt.Errorf("store is nil for project: %s", projectID)
 	}
+	ctx = s.scope(ctx, projectID)
+
+	cacheKey := graphCacheKey{Project: projectID, Query: fmt.Sprintf("file_calls:%s:%d", fileID, depth)}
+	factCount := store.Count()
+	if cached, ok := s.graphCache.get(cacheKey, factCount); ok {
+		return cached, nil
+	}
 
 	cleanFileID := strings.Trim(fileID, "\"")
 
@@ -446,10 +531,9 @@ func (s *GraphService) GetFileCalls(ctx context.Context, projectID, fileID strin
 	}
 
 	result := &export.D3Graph{Nodes: nodes, Links: links}
+	result.Sort()
 
-	s.cacheMu.Lock()
-	s.projectMapCache[cacheKey] = result
-	s.cacheMu.Unlock()
+	s.graphCache.set(cacheKey, factCount, result)
 
 	return result, nil
 }
@@ -460,6 +544,8 @@ func (s *GraphService) GetFlowPath(ctx context.Context, projectID, fromID, toID
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
+	deadline.Enter(ctx, "path search")
 
 	fromID = strings.Trim(fromID, "\"")
 	toID = strings.Trim(toID, "\"")
@@ -520,6 +606,7 @@ func (s *GraphService) GetFlowPath(ctx context.Context, projectID, fromID, toID
 	nodes := []export.D3Node{}
 	links := []export.D3Link{}
 	nodeSet := make(map[string]bool)
+	kinds := s.kindIndexes.get(ctx, projectID, store)
 
 	for i := 0; i < len(foundPath); i++ {
 		id := foundPath[i]
@@ -527,7 +614,7 @@ func (s *GraphService) GetFlowPath(ctx context.Context, projectID, fromID, toID
 			nodes = append(nodes, export.D3Node{
 				ID:   id,
 				Name: common.ExtractBaseName(id),
-				Kind: config.SymbolKindSymbol,
+				Kind: kindOr(kinds, id, config.SymbolKindSymbol),
 			})
 			nodeSet[id] = true
 		}
@@ -560,7 +647,7 @@ func findFileForSymbolByStore(ctx context.Context, store *meb.MEBStore, target s
 
 	// Try direct lookup via defines predicate (O(1) via OPS index)
 	// Query: find subjects where defines(subject, target)
-	for fact, err := range store.ScanContext(ctx, "", config.PredicateDefines, target) {
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateDefines, target) {
 		if err != nil {
 			continue
 		}
@@ -579,6 +666,9 @@ func findFileForSymbolByStore(ctx context.Context, store *meb.MEBStore, target s
 	// Find all symbols with this short name
 	var candidates []string
 	for subject := range store.FindSubjectsByObject(ctx, config.PredicateHasName, shortName) {
+		if !gcamdb.InScope(ctx, subject) {
+			continue
+		}
 		candidates = append(candidates, subject)
 	}
 
@@ -2,34 +2,59 @@ package service
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/duynguyendang/gca/pkg/common"
+	"github.com/duynguyendang/gca/pkg/common/deadline"
 	"github.com/duynguyendang/gca/pkg/common/errors"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/datalog"
 	"github.com/duynguyendang/gca/pkg/export"
 	gcamdb "github.com/duynguyendang/gca/pkg/meb"
-	"github.com/duynguyendang/gca/pkg/logger"
 	"github.com/duynguyendang/gca/pkg/repl"
+	"github.com/duynguyendang/meb"
 )
 
 var queryOptimizer = datalog.NewQueryOptimizer()
 
 // ExecuteQuery executes a Datalog query and returns results.
 func (s *GraphService) ExecuteQuery(ctx context.Context, projectID, query string) ([]map[string]any, error) {
+	res, err := s.ExecuteQueryWithOptions(ctx, projectID, query, gcamdb.QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Rows, nil
+}
+
+// ExecuteQueryWithOptions executes a Datalog query under the given row limit and deadline.
+// Partial results are reported through the result's Truncated, TimedOut and Warnings fields.
+func (s *GraphService) ExecuteQueryWithOptions(ctx context.Context, projectID, query string, opts gcamdb.QueryOptions) (*gcamdb.QueryResult, error) {
 	store, err := s.getStore(projectID)
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
-	results, err := gcamdb.Query(ctx, store, query)
+	deadline.Enter(ctx, "query")
+	res, err := gcamdb.QueryWithOptions(ctx, store, query, opts)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+		return nil, queryError(err)
 	}
 
-	return results, nil
+	return res, nil
+}
+
+// queryError classifies a query failure: cancellations pass through, anything else is bad input.
+func queryError(err error) error {
+	if stderrors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
 }
 
 // ExecuteQueryOptimized executes a Datalog query with optimization (join reordering and predicate pushdown).
@@ -38,6 +63,7 @@ func (s *GraphService) ExecuteQueryOptimized(ctx context.Context, projectID, que
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	// Parse the query
 	atoms, err := datalog.Parse(query)
@@ -76,8 +102,7 @@ func reconstructQuery(atoms []datalog.Atom) string {
 
 // formatAtom formats an atom back into Datalog syntax.
 func formatAtom(atom datalog.Atom) string {
-	args := strings.Join(atom.Args, ", ")
-	return fmt.Sprintf("%s(%s)", atom.Predicate, args)
+	return atom.String()
 }
 
 // applyPushdownPredicates applies pushed-down predicates as post-processing filters.
@@ -124,21 +149,37 @@ func matchesPushdownPredicates(result map[string]any, predicates map[string]stri
 	return true
 }
 
-// GetManifest returns a compressed project manifest for the AI.
-func (s *GraphService) GetManifest(ctx context.Context, projectID string) (map[string]interface{}, error) {
+// GetManifest returns a compressed project manifest for the AI: "F" lists files and "S"
+// maps each short symbol name to every fully qualified ID carrying it, so common names
+// like main or init resolve to all their definitions. "D" maps symbol IDs to the
+// summaries generated at ingest time, when the project has any.
+// opts pages over the underlying defines facts; the second result is the cursor
+// for the next page, empty when the manifest is complete. Complete manifests are
+// cached per project until the next ingest and must not be modified by callers.
+func (s *GraphService) GetManifest(ctx context.Context, projectID string, opts gcamdb.ScanOptions) (map[string]interface{}, string, error) {
 	store, err := s.manager.GetStore(projectID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	fileMap := make(map[string]string)
-	symbolMap := make(map[string]string)
-
-	for fact, err := range store.Scan("", config.PredicateDefines, "") {
-		if err != nil {
-			return nil, fmt.Errorf("scan failed: %w", err)
+	paged := opts.Limit > 0 || opts.Cursor != ""
+	factCount := store.Count()
+	if !paged {
+		if manifest, ok := s.manifestCache.get(projectID, factCount); ok {
+			return manifest, "", nil
 		}
+	}
+
+	opts.Index = s.adjacency(ctx, projectID, store)
+	page, err := gcamdb.ScanPaged(ctx, store, "", config.PredicateDefines, "", opts)
+	if err != nil {
+		return nil, "", scanError(err)
+	}
+
+	fileMap := make(map[string]string)
+	symbolMap := make(map[string][]string)
 
+	for _, fact := range page.Facts {
 		filePath := string(fact.Subject)
 		fullID, ok := fact.Object.(string)
 		if !ok {
@@ -156,54 +197,204 @@ func (s *GraphService) GetManifest(ctx context.Context, projectID string) (map[s
 			shortName = shortName[idx+1:]
 		}
 
-		symbolMap[shortName] = fullID
+		if !slices.Contains(symbolMap[shortName], fullID) {
+			symbolMap[shortName] = append(symbolMap[shortName], fullID)
+		}
+	}
+	for _, ids := range symbolMap {
+		sort.Strings(ids)
 	}
 
-	return map[string]interface{}{
+	manifest := map[string]interface{}{
 		"F": fileMap,
 		"S": symbolMap,
-	}, nil
+	}
+	if summaries := manifestSummaries(ctx, store, page.Facts); len(summaries) > 0 {
+		manifest["D"] = summaries
+	}
+	if !paged {
+		s.manifestCache.set(projectID, factCount, manifest)
+	}
+	return manifest, page.NextCursor, nil
+}
+
+// manifestSummaries maps the symbols defined by defines to their ingest-time
+// summaries, for the manifest's "D" entry.
+func manifestSummaries(ctx context.Context, store *meb.MEBStore, defines []meb.Fact) map[string]string {
+	ids := make(map[string]bool, len(defines))
+	for _, fact := range defines {
+		if id, ok := fact.Object.(string); ok {
+			ids[id] = true
+		}
+	}
+	summaries := make(map[string]string)
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateHasSummary, "") {
+		if summary, ok := fact.Object.(string); err == nil && ok && ids[fact.Subject] {
+			summaries[fact.Subject] = summary
+		}
+	}
+	return summaries
 }
 
-// GetSource returns the content of a specific file/symbol.
+// scanError maps a rejected continuation token to invalid input.
+func scanError(err error) error {
+	if stderrors.Is(err, gcamdb.ErrInvalidCursor) {
+		return fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+	}
+	return err
+}
+
+// GetSource returns the content of a specific file/symbol. Symbols are stored
+// without content, so their code is sliced out of the parent file on demand.
 func (s *GraphService) GetSource(projectID, docID string) (string, error) {
 	store, err := s.getStore(projectID)
 	if err != nil {
 		return "", err
 	}
+	ctx := s.scope(context.Background(), projectID)
+	sc, scoped := gcamdb.ScopeFrom(ctx)
 
-	doc, err := store.GetContentByKey(string(docID))
-	if err != nil {
-		if projectID != "" && !strings.HasPrefix(docID, projectID+"/") {
-			prefixedDocID := projectID + "/" + docID
-			doc, err = store.GetContentByKey(string(prefixedDocID))
+	keys := []string{docID}
+	if projectID != "" && !strings.HasPrefix(docID, projectID+"/") {
+		keys = append(keys, projectID+"/"+docID)
+	}
+	for _, key := range keys {
+		if scoped && !sc.Owns(key) {
+			continue
+		}
+		isSymbol := strings.Contains(key, ":")
+		if doc, err := gcamdb.GetDocument(store, key); err == nil && (len(doc) > 0 || !isSymbol) {
+			return string(doc), nil
 		}
+		if isSymbol {
+			if snippet, err := gcamdb.GetSymbolSnippet(ctx, store, key); err == nil {
+				return snippet, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: document not found", errors.ErrNotFound)
+}
+
+// GetSourceRevisions returns the revisions ingests recorded for a file,
+// newest first. The file ID may omit its project prefix, as with GetSource.
+func (s *GraphService) GetSourceRevisions(projectID, docID string) ([]gcamdb.DocumentRevision, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	sc, scoped := gcamdb.ScopeFrom(s.scope(context.Background(), projectID))
 
+	keys := []string{docID}
+	if projectID != "" && !strings.HasPrefix(docID, projectID+"/") {
+		keys = append(keys, projectID+"/"+docID)
+	}
+	for _, key := range keys {
+		if scoped && !sc.Owns(key) {
+			continue
+		}
+		revs, err := gcamdb.GetDocumentRevisions(store, key)
 		if err != nil {
-			return "", fmt.Errorf("%w: document not found", errors.ErrNotFound)
+			return nil, fmt.Errorf("%w: %v", errors.ErrInternal, err)
+		}
+		if len(revs) > 0 {
+			return revs, nil
 		}
 	}
+	return nil, fmt.Errorf("%w: no revisions recorded for %s", errors.ErrNotFound, docID)
+}
+
+// GetSymbolSource returns the exact source range of a symbol with its doc
+// comment and the imports it uses, so clients need not know its line numbers.
+func (s *GraphService) GetSymbolSource(ctx context.Context, projectID, symbolID string) (*gcamdb.SymbolSource, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+	sc, scoped := gcamdb.ScopeFrom(ctx)
 
-	return string(doc), nil
+	keys := []string{symbolID}
+	if projectID != "" && !strings.HasPrefix(symbolID, projectID+"/") {
+		keys = append(keys, projectID+"/"+symbolID)
+	}
+	for _, key := range keys {
+		if scoped && !sc.Owns(key) {
+			continue
+		}
+		src, err := gcamdb.GetSymbolSource(ctx, store, key)
+		if err == nil {
+			return src, nil
+		}
+		if !stderrors.Is(err, gcamdb.ErrNoSnippet) {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInternal, err)
+		}
+	}
+	return nil, fmt.Errorf("%w: no source range for symbol", errors.ErrNotFound)
 }
 
 // GetSymbol retrieves the full hydrated symbol (content + metadata) for a given ID.
 func (s *GraphService) GetSymbol(ctx context.Context, projectID, docID string) (*HydratedSymbol, error) {
+	return s.GetSymbolFields(ctx, projectID, docID, HydrateMetadata|HydrateContent)
+}
+
+// GetSymbolFields retrieves a symbol hydrated with only the requested fields.
+func (s *GraphService) GetSymbolFields(ctx context.Context, projectID, docID string, fields HydrateFields) (*HydratedSymbol, error) {
+	return s.GetSymbolWithOptions(ctx, projectID, docID, HydrateOptions{Fields: fields})
+}
+
+// GetSymbolWithOptions retrieves a symbol hydrated as opts asks, such as the
+// next page of its children.
+func (s *GraphService) GetSymbolWithOptions(ctx context.Context, projectID, docID string, opts HydrateOptions) (*HydratedSymbol, error) {
 	store, err := s.getStore(projectID)
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
+	fields := opts.Fields
+	if _, err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	// A UID names the symbol in whichever file it now lives
+	if gcamdb.IsUID(docID) {
+		ids := gcamdb.UIDSymbols(ctx, store, docID)
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("%w: no symbol has UID %s", errors.ErrNotFound, docID)
+		}
+		docID = ids[0]
+	}
+	if opts.After != "" {
+		// The token names the symbol under the ID it was hydrated as
+		parent, _, err := parseChildCursor(opts.After)
+		if err != nil {
+			return nil, err
+		}
+		if parent != docID && parent != projectID+"/"+docID {
+			return nil, fmt.Errorf("%w: continuation token belongs to %s", errors.ErrInvalidInput, parent)
+		}
+		docID = parent
+	}
+
+	found := func(hydrated []HydratedSymbol) bool {
+		if len(hydrated) == 0 {
+			return false
+		}
+		h := hydrated[0]
+		if fields&HydrateContent != 0 {
+			return h.Content != ""
+		}
+		return h.Kind != "" || len(h.Metadata) > 0 || len(h.Children) > 0
+	}
 
 	ids := []string{string(docID)}
-	hydrated, err := s.Hydrate(ctx, store, projectID, ids)
-	if err != nil || len(hydrated) == 0 || hydrated[0].Content == "" {
+	hydrated, err := s.HydrateWithOptions(ctx, store, projectID, ids, opts)
+	if err != nil || !found(hydrated) {
 		if projectID != "" && !strings.HasPrefix(docID, projectID+"/") {
 			prefixedDocID := projectID + "/" + docID
 			ids = []string{string(prefixedDocID)}
-			hydrated, err = s.Hydrate(ctx, store, projectID, ids)
+			hydrated, err = s.HydrateWithOptions(ctx, store, projectID, ids, opts)
 		}
 
-		if err != nil || len(hydrated) == 0 || hydrated[0].Content == "" {
+		if err != nil || !found(hydrated) {
 			return nil, fmt.Errorf("%w: symbol not found", errors.ErrNotFound)
 		}
 	}
@@ -218,6 +409,19 @@ func (s *GraphService) GetPredicates(projectID string) ([]map[string]string, err
 		return nil, err
 	}
 
+	// Stores maintenance has catalogued list their predicates with fact counts;
+	// a shared store's catalog covers every project, so those list the store's
+	if cat, ok := gcamdb.CatalogOf(store); ok {
+		if _, scoped := gcamdb.ScopeFrom(s.scope(context.Background(), projectID)); !scoped {
+			results := make([]map[string]string, 0, len(cat.Predicates))
+			for p, n := range cat.Predicates {
+				results = append(results, map[string]string{"name": p, "facts": strconv.FormatUint(n, 10)})
+			}
+			sort.Slice(results, func(i, j int) bool { return results[i]["name"] < results[j]["name"] })
+			return results, nil
+		}
+	}
+
 	var results []map[string]string
 	for _, p := range store.ListPredicates() {
 		results = append(results, map[string]string{
@@ -227,8 +431,12 @@ func (s *GraphService) GetPredicates(projectID string) ([]map[string]string, err
 	return results, nil
 }
 
-// SearchSymbols performs symbol search.
-func (s *GraphService) SearchSymbols(projectID, query, predicate string, limit int) ([]string, error) {
+// SearchSymbols returns up to limit defined symbols matching query, best match
+// first. Matching is case-insensitive and ranks exact names above prefixes,
+// substrings, camel-case abbreviations and fuzzy subsequences; symbols with more
+// incoming calls rank higher within each tier. Lookups go through an in-memory
+// index that is rebuilt only after the project changes.
+func (s *GraphService) SearchSymbols(projectID, query, predicate string, limit int) ([]SymbolMatch, error) {
 	store, err := s.getStore(projectID)
 	if err != nil {
 		return nil, err
@@ -238,58 +446,49 @@ func (s *GraphService) SearchSymbols(projectID, query, predicate string, limit i
 		limit = config.DefaultSearchLimit
 	}
 
-	var matches []string
-	count := 0
-	for fact, err := range store.Scan("", config.PredicateDefines, "") {
-		if err != nil {
-			continue
-		}
-		if obj, ok := fact.Object.(string); ok {
-			if strings.Contains(strings.ToLower(obj), strings.ToLower(query)) {
-				matches = append(matches, obj)
-				count++
-				if count >= limit {
-					break
-				}
-			}
-		}
-	}
-	return matches, nil
+	return s.symbolIndex.get(s.scope(context.Background(), projectID), projectID, store).search(query, limit), nil
 }
 
-// ListFiles returns all ingested file paths for a project.
-func (s *GraphService) ListFiles(projectID string) ([]string, error) {
+// ListFiles returns ingested file paths for a project, restricted to opts.SubjectPrefixes
+// when set and paged with opts.Limit and opts.Cursor. The second result is the cursor
+// for the next page, empty on the last one.
+func (s *GraphService) ListFiles(ctx context.Context, projectID string, opts gcamdb.ScanOptions) ([]string, string, error) {
 	store, err := s.getStore(projectID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	ctx = s.scope(ctx, projectID)
 
-	seen := make(map[string]bool)
-	var files []string
+	page, err := gcamdb.ScanPaged(ctx, store, "", config.PredicateType, config.FileTypeFile, opts)
+	if err != nil {
+		return nil, "", scanError(err)
+	}
 
-	for fact, err := range store.Scan("", config.PredicateType, "") {
-		if err != nil {
-			continue
-		}
-		if obj, ok := fact.Object.(string); ok && obj == config.FileTypeFile {
-			f := string(fact.Subject)
-			if !seen[f] {
-				seen[f] = true
-				files = append(files, f)
-			}
+	seen := make(map[string]bool)
+	files := make([]string, 0, len(page.Facts))
+	for _, fact := range page.Facts {
+		f := string(fact.Subject)
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
 		}
 	}
-	return files, nil
+	return files, page.NextCursor, nil
 }
 
 // GetProjectMap returns a high-level view of file dependencies (imports only).
 func (s *GraphService) GetProjectMap(ctx context.Context, projectID string) (*export.D3Graph, error) {
-	s.cacheMu.RLock()
-	if graph, ok := s.projectMapCache[projectID]; ok {
-		s.cacheMu.RUnlock()
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	cacheKey := graphCacheKey{Project: projectID, Query: "project_map"}
+	factCount := store.Count()
+	if graph, ok := s.graphCache.get(cacheKey, factCount); ok {
 		return graph, nil
 	}
-	s.cacheMu.RUnlock()
 
 	query := fmt.Sprintf(`triples(?s, "%s", ?o)`, config.PredicateImports)
 
@@ -298,14 +497,9 @@ func (s *GraphService) GetProjectMap(ctx context.Context, projectID string) (*ex
 		return nil, err
 	}
 
-	store, err := s.getStore(projectID)
-	if err == nil {
-		s.resolvePackageImportsToFiles(ctx, store, graph, "")
-	}
+	s.resolvePackageImportsToFiles(ctx, store, s.kindIndexes.get(ctx, projectID, store), s.adjacency(ctx, projectID, store), graph, "")
 
-	s.cacheMu.Lock()
-	s.projectMapCache[projectID] = graph
-	s.cacheMu.Unlock()
+	s.graphCache.set(cacheKey, factCount, graph)
 
 	return graph, nil
 }
@@ -350,6 +544,8 @@ func (s *GraphService) GetFileDetails(ctx context.Context, projectID, fileID str
 	q1 := fmt.Sprintf(`triples(%s, "%s", ?s)`, quotedFileID, config.PredicateDefines)
 	q2 := fmt.Sprintf(`triples(?s, "%s", ?o), triples(%s, "%s", ?s), triples(%s, "%s", ?o)`,
 		config.PredicateCalls, quotedFileID, config.PredicateDefines, quotedFileID, config.PredicateDefines)
+	q3 := fmt.Sprintf(`triples(?s, "%s", ?o), triples(%s, "%s", ?s), triples(%s, "%s", ?o)`,
+		config.PredicateRenders, quotedFileID, config.PredicateDefines, quotedFileID, config.PredicateDefines)
 
 	mergedGraph := &export.D3Graph{Nodes: []export.D3Node{}, Links: []export.D3Link{}}
 
@@ -359,156 +555,74 @@ func (s *GraphService) GetFileDetails(ctx context.Context, projectID, fileID str
 		mergedGraph.Links = append(mergedGraph.Links, g1.Links...)
 	}
 
-	g2, err := s.ExportGraph(ctx, projectID, q2, false, true)
-	if err == nil {
+	// Calls and component renders between the file's own symbols
+	for _, q := range []string{q2, q3} {
+		g, err := s.ExportGraph(ctx, projectID, q, false, true)
+		if err != nil {
+			continue
+		}
 		nodeMap := make(map[string]bool)
 		for _, n := range mergedGraph.Nodes {
 			nodeMap[n.ID] = true
 		}
-		for _, n := range g2.Nodes {
+		for _, n := range g.Nodes {
 			if !nodeMap[n.ID] {
 				mergedGraph.Nodes = append(mergedGraph.Nodes, n)
 				nodeMap[n.ID] = true
 			}
 		}
-		mergedGraph.Links = append(mergedGraph.Links, g2.Links...)
+		mergedGraph.Links = append(mergedGraph.Links, g.Links...)
 	}
 
 	for i := range mergedGraph.Nodes {
 		mergedGraph.Nodes[i].ParentID = cleanFileID
 	}
+	mergedGraph.Sort()
 
 	return mergedGraph, nil
 }
 
-// GetBackboneGraph returns a graph containing only cross-file dependencies.
-func (s *GraphService) GetBackboneGraph(ctx context.Context, projectID string, aggregate bool) (*export.D3Graph, error) {
-	query := fmt.Sprintf(`triples(?s, "%s", ?o)`, config.PredicateCalls)
+// GenerateSummary generates a project summary.
+func (s *GraphService) GenerateSummary(projectID string) (*repl.ProjectSummary, error) {
 	store, err := s.getStore(projectID)
 	if err != nil {
 		return nil, err
 	}
 
-	results, err := gcamdb.Query(ctx, store, query)
+	summary, err := repl.GenerateProjectSummary(store)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+		return nil, err
 	}
 
-	backbone := &export.D3Graph{
-		Nodes: []export.D3Node{},
-		Links: []export.D3Link{},
+	// The summary walks the whole store; keep only this project's share of a shared one
+	ctx := s.scope(context.Background(), projectID)
+	if _, scoped := gcamdb.ScopeFrom(ctx); scoped {
+		summary.Packages = filterInScope(ctx, summary.Packages)
+		summary.EntryPoints = filterInScope(ctx, summary.EntryPoints)
+		summary.Stats["unique_packages"] = len(summary.Packages)
 	}
-	nodeSet := make(map[string]bool)
 
-	for _, r := range results {
-		srcID, ok1 := r["?s"].(string)
-		tgtID, ok2 := r["?o"].(string)
-		if !ok1 || !ok2 {
-			continue
-		}
-
-		srcParts := strings.SplitN(srcID, ":", 2)
-		tgtParts := strings.SplitN(tgtID, ":", 2)
-
-		if len(srcParts) < 2 || len(tgtParts) < 2 {
-			continue
-		}
-
-		srcFile := srcParts[0]
-		tgtFile := tgtParts[0]
-
-		if srcFile != tgtFile {
-			if aggregate {
-				linkKey := srcFile + "->" + tgtFile
-				if !nodeSet[linkKey] {
-					backbone.Links = append(backbone.Links, export.D3Link{
-						Source:   srcFile,
-						Target:   tgtFile,
-						Relation: config.RelationCalls,
-						Weight:   1,
-					})
-				}
-
-				if !nodeSet[srcFile] {
-					backbone.Nodes = append(backbone.Nodes, export.D3Node{
-						ID:   srcFile,
-						Name: common.ExtractBaseName(srcFile),
-						Kind: config.SymbolKindFile,
-					})
-					nodeSet[srcFile] = true
-				}
-				if !nodeSet[tgtFile] {
-					backbone.Nodes = append(backbone.Nodes, export.D3Node{
-						ID:   tgtFile,
-						Name: common.ExtractBaseName(tgtFile),
-						Kind: config.SymbolKindFile,
-					})
-					nodeSet[tgtFile] = true
-				}
-			} else {
-				backbone.Links = append(backbone.Links, export.D3Link{
-					Source:   srcID,
-					Target:   tgtID,
-					Relation: config.RelationCalls,
-				})
-
-				if !nodeSet[srcID] {
-					backbone.Nodes = append(backbone.Nodes, export.D3Node{
-						ID:       srcID,
-						Name:     srcParts[1],
-						Kind:     config.SymbolKindGateway,
-						ParentID: srcFile,
-					})
-					nodeSet[srcID] = true
-				}
-				if !nodeSet[tgtID] {
-					backbone.Nodes = append(backbone.Nodes, export.D3Node{
-						ID:       tgtID,
-						Name:     tgtParts[1],
-						Kind:     config.SymbolKindGateway,
-						ParentID: tgtFile,
-					})
-					nodeSet[tgtID] = true
-				}
-			}
-		}
-	}
-
-	if aggregate {
-		uniqueLinks := make([]export.D3Link, 0)
-		linkSeen := make(map[string]bool)
-		for _, l := range backbone.Links {
-			key := fmt.Sprintf("%s->%s", l.Source, l.Target)
-			if !linkSeen[key] {
-				uniqueLinks = append(uniqueLinks, l)
-				linkSeen[key] = true
-			}
-		}
-		backbone.Links = uniqueLinks
-	}
+	return summary, nil
+}
 
-	if len(backbone.Nodes) > 0 {
-		if err := s.enrichNodes(ctx, store, backbone, true); err != nil {
-			logger.Warn("Backbone enrichment warning", "error", err)
+func filterInScope(ctx context.Context, ids []string) []string {
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if gcamdb.InScope(ctx, id) {
+			kept = append(kept, id)
 		}
 	}
-
-	return backbone, nil
+	return kept
 }
 
-// GenerateSummary generates a project summary.
-func (s *GraphService) GenerateSummary(projectID string) (*repl.ProjectSummary, error) {
+// EntryPoints returns the project's entry points: main functions, HTTP handlers,
+// CLI commands and React root components, as tagged at ingest time.
+func (s *GraphService) EntryPoints(ctx context.Context, projectID string) ([]gcamdb.EntryPoint, error) {
 	store, err := s.getStore(projectID)
 	if err != nil {
 		return nil, err
 	}
-
-	summary, err := repl.GenerateProjectSummary(store)
-	if err != nil {
-		return nil, err
-	}
-
-	return summary, nil
+	return gcamdb.EntryPoints(s.scope(ctx, projectID), store)
 }
 
 // ResolveVirtualTriples identifies potential implicit relationships.
@@ -517,6 +631,7 @@ func (s *GraphService) ResolveVirtualTriples(ctx context.Context, projectID stri
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	links := []export.D3Link{}
 	nodes := []export.D3Node{}
@@ -545,6 +660,8 @@ func (s *GraphService) ResolveVirtualTriples(ctx context.Context, projectID stri
 		}
 	}
 
+	// Links by naming convention alone score no better than the method's prior
+	naming := gcamdb.LinkConfidence(gcamdb.MethodPrecision(gcamdb.LinkMethodNaming, 0, 0), 1)
 	for iName := range uniqueInterfaces {
 		shortName := common.ExtractSymbolName(iName)
 
@@ -553,26 +670,30 @@ func (s *GraphService) ResolveVirtualTriples(ctx context.Context, projectID stri
 
 			if strings.HasSuffix(sShort, "Impl") && strings.TrimSuffix(sShort, "Impl") == shortName {
 				links = append(links, export.D3Link{
-					Source:   iName,
-					Target:   sName,
-					Relation: config.VirtualRelationWiresTo,
-					Type:     "virtual",
-					Weight:   0.8,
+					Source:     iName,
+					Target:     sName,
+					Relation:   config.VirtualRelationWiresTo,
+					Type:       "virtual",
+					Weight:     0.8,
+					Confidence: naming,
 				})
 			}
 			if strings.HasPrefix(sShort, "Default") && strings.TrimPrefix(sShort, "Default") == shortName {
 				links = append(links, export.D3Link{
-					Source:   iName,
-					Target:   sName,
-					Relation: config.VirtualRelationWiresTo,
-					Type:     "virtual",
-					Weight:   0.8,
+					Source:     iName,
+					Target:     sName,
+					Relation:   config.VirtualRelationWiresTo,
+					Type:       "virtual",
+					Weight:     0.8,
+					Confidence: naming,
 				})
 			}
 		}
 	}
 
-	return &export.D3Graph{Nodes: nodes, Links: links}, nil
+	graph := &export.D3Graph{Nodes: nodes, Links: links}
+	graph.Sort()
+	return graph, nil
 }
 
 // SemanticSearchResult represents a single semantic search result.
@@ -590,6 +711,7 @@ func (s *GraphService) SemanticSearch(ctx context.Context, projectID, query stri
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	embedding, err := gemini.GetEmbedding(ctx, query)
 	if err != nil {
@@ -598,24 +720,30 @@ func (s *GraphService) SemanticSearch(ctx context.Context, projectID, query stri
 
 	results := make([]SemanticSearchResult, 0, k)
 
-	vecIter := store.Vectors().Search(embedding, k)
+	// Doc nodes share their symbol's vector: search twice as deep and skip them
+	vecIter := store.Vectors().Search(embedding, 2*k)
 	for vr, err := range vecIter {
-		if err != nil {
+		if err != nil || len(results) == k {
 			break
 		}
-		symbolID, err := store.ResolveID(vr.ID)
-		if err != nil {
+		key, err := store.ResolveID(vr.ID)
+		if err != nil || gcamdb.IsDocNode(key) {
 			continue
 		}
-		name := symbolID
-		if parts := strings.Split(symbolID, ":"); len(parts) > 1 {
-			name = parts[len(parts)-1]
+		for _, symbolID := range gcamdb.VectorSymbols(ctx, store, key) {
+			if !gcamdb.InScope(ctx, symbolID) || len(results) == k {
+				continue
+			}
+			name := symbolID
+			if parts := strings.Split(symbolID, ":"); len(parts) > 1 {
+				name = parts[len(parts)-1]
+			}
+			results = append(results, SemanticSearchResult{
+				SymbolID: symbolID,
+				Score:    vr.Score,
+				Name:     name,
+			})
 		}
-		results = append(results, SemanticSearchResult{
-			SymbolID: symbolID,
-			Score:    vr.Score,
-			Name:     name,
-		})
 	}
 
 	return results, nil
@@ -629,6 +757,7 @@ func (s *GraphService) SemanticSearchFiltered(ctx context.Context, projectID, qu
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	embedding, err := gemini.GetEmbedding(ctx, query)
 	if err != nil {
@@ -650,15 +779,23 @@ func (s *GraphService) SemanticSearchFiltered(ctx context.Context, projectID, qu
 
 	results := make([]SemanticSearchResult, 0, len(queryResults))
 	for _, qr := range queryResults {
-		name := qr.Key
-		if parts := strings.Split(qr.Key, ":"); len(parts) > 1 {
-			name = parts[len(parts)-1]
-		}
-		results = append(results, SemanticSearchResult{
-			SymbolID: qr.Key,
-			Score:    qr.Score,
-			Name:     name,
-		})
+		if gcamdb.IsDocNode(qr.Key) {
+			continue
+		}
+		for _, symbolID := range gcamdb.VectorSymbols(ctx, store, qr.Key) {
+			if !gcamdb.InScope(ctx, symbolID) {
+				continue
+			}
+			name := symbolID
+			if parts := strings.Split(symbolID, ":"); len(parts) > 1 {
+				name = parts[len(parts)-1]
+			}
+			results = append(results, SemanticSearchResult{
+				SymbolID: symbolID,
+				Score:    qr.Score,
+				Name:     name,
+			})
+		}
 	}
 
 	return results, nil
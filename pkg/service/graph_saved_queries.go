@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// savedQueryError maps the saved query ledger's errors to the service's.
+func savedQueryError(err error) error {
+	switch {
+	case stderrors.Is(err, gcamdb.ErrSavedQueryNotFound):
+		return fmt.Errorf("%w: %v", errors.ErrNotFound, err)
+	case stderrors.Is(err, gcamdb.ErrInvalidSavedQuery):
+		return fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+	default:
+		return fmt.Errorf("%w: %v", errors.ErrInternal, err)
+	}
+}
+
+// ListSavedQueries returns the project's saved queries by name.
+func (s *GraphService) ListSavedQueries(ctx context.Context, projectID string) ([]gcamdb.SavedQuery, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	queries, err := gcamdb.SavedQueries(store, projectID)
+	if err != nil {
+		return nil, savedQueryError(err)
+	}
+	return queries, nil
+}
+
+// GetSavedQuery returns the project's saved query called name.
+func (s *GraphService) GetSavedQuery(ctx context.Context, projectID, name string) (*gcamdb.SavedQuery, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	q, err := gcamdb.GetSavedQuery(store, projectID, name)
+	if err != nil {
+		return nil, savedQueryError(err)
+	}
+	return &q, nil
+}
+
+// SaveQuery saves q in the project, replacing the query of the same name. It
+// returns the query as saved and whether it is new.
+func (s *GraphService) SaveQuery(ctx context.Context, projectID string, q gcamdb.SavedQuery) (*gcamdb.SavedQuery, bool, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, false, err
+	}
+	q.Project = projectID
+
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+	saved, created, err := gcamdb.SaveQuery(store, q, time.Now())
+	if err != nil {
+		return nil, false, savedQueryError(err)
+	}
+	return &saved, created, nil
+}
+
+// DeleteSavedQuery deletes the project's saved query called name.
+func (s *GraphService) DeleteSavedQuery(ctx context.Context, projectID, name string) error {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return err
+	}
+
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+	if err := gcamdb.DeleteSavedQuery(store, projectID, name); err != nil {
+		return savedQueryError(err)
+	}
+	return nil
+}
+
+// RunSavedQuery runs the project's saved query called name with params bound
+// to its parameters, returning the rows and the query that was run.
+func (s *GraphService) RunSavedQuery(ctx context.Context, projectID, name string, params map[string]string, opts gcamdb.QueryOptions) (*gcamdb.QueryResult, string, error) {
+	q, err := s.GetSavedQuery(ctx, projectID, name)
+	if err != nil {
+		return nil, "", err
+	}
+	query, err := q.Bind(params)
+	if err != nil {
+		return nil, "", savedQueryError(err)
+	}
+	res, err := s.ExecuteQueryWithOptions(ctx, projectID, query, opts)
+	if err != nil {
+		return nil, query, err
+	}
+	return res, query, nil
+}
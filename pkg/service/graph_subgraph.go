@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/datalog"
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// SubgraphOptions selects the nodes of GetSubgraphWithOptions.
+type SubgraphOptions struct {
+	IDs    []string // Nodes to include
+	Query  string   // Datalog query whose bound nodes are included
+	Expand int      // Hops of neighbors added around the nodes, at most config.SubgraphMaxExpand
+}
+
+// attributePredicates are the predicates whose objects are values of their
+// subject, such as its kind or start line, rather than other nodes.
+var attributePredicates = map[string]bool{
+	config.PredicateType:             true,
+	config.PredicateKind:             true,
+	config.PredicateHasKind:          true,
+	config.PredicateHasLanguage:      true,
+	config.PredicateStartLine:        true,
+	config.PredicateEndLine:          true,
+	config.PredicateHasDoc:           true,
+	config.PredicateDocuments:        true, // Doc nodes stand in for their symbol's doc comment
+	config.PredicateHasComment:       true,
+	config.PredicateHasSummary:       true,
+	config.PredicateHasRole:          true,
+	config.PredicateHasTag:           true,
+	config.PredicateName:             true,
+	config.PredicateHasName:          true,
+	config.PredicateHasSecurityRisk:  true,
+	config.PredicateHasOperation:     true,
+	config.PredicateIsInternal:       true,
+	config.PredicateIsEntryPoint:     true,
+	config.PredicateIsGenerated:      true,
+	config.PredicateVulnSeverity:     true,
+	config.PredicateVulnSummary:      true,
+	config.PredicateVulnFixedIn:      true,
+	config.PredicateLOC:              true,
+	config.PredicateComplexity:       true,
+	config.PredicateParamCount:       true,
+	config.PredicateHasUID:           true,
+	config.PredicateOwnedBy:          true,
+	config.PredicateHasVulnerability: true,
+}
+
+// GetSubgraphWithOptions returns the nodes named by opts.IDs and bound by
+// opts.Query, grown by opts.Expand hops of neighbors in either direction, with
+// every edge the store holds among them. Unlike GetSubgraph it reads the store
+// rather than the project's import map, so it returns edges of any relation.
+// Growth stops at config.SubgraphMaxNodes, marking the graph truncated.
+func (s *GraphService) GetSubgraphWithOptions(ctx context.Context, projectID string, opts SubgraphOptions) (*export.D3Graph, error) {
+	if opts.Expand < 0 || opts.Expand > config.SubgraphMaxExpand {
+		return nil, fmt.Errorf("%w: expand must be between 0 and %d", errors.ErrInvalidInput, config.SubgraphMaxExpand)
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	set := make(map[string]bool)
+	var order []string
+	truncated := false
+	add := func(id string) bool {
+		if set[id] || !isNodeValue(id) {
+			return true
+		}
+		if len(order) >= config.SubgraphMaxNodes {
+			truncated = true
+			return false
+		}
+		set[id] = true
+		order = append(order, id)
+		return true
+	}
+
+	for _, id := range opts.IDs {
+		add(id)
+	}
+	var warnings []string
+	if opts.Query != "" {
+		ids, queryWarnings, err := queryNodes(ctx, store, opts.Query)
+		if err != nil {
+			return nil, err
+		}
+		warnings = queryWarnings
+		for _, id := range ids {
+			if !add(id) {
+				break
+			}
+		}
+	}
+
+	frontier := append([]string(nil), order...)
+	for hop := 0; hop < opts.Expand && !truncated; hop++ {
+		var next []string
+	expand:
+		for _, id := range frontier {
+			for _, n := range nodeNeighbors(ctx, store, id) {
+				if set[n] {
+					continue
+				}
+				if !add(n) {
+					break expand
+				}
+				next = append(next, n)
+			}
+		}
+		frontier = next
+	}
+
+	source := gcamdb.FactSources(ctx, store)
+	weight := gcamdb.FactWeigher(store)
+	var rows []map[string]any
+	for _, id := range order {
+		for fact, err := range gcamdb.Scan(ctx, store, id, "", "") {
+			if err != nil || attributePredicates[fact.Predicate] {
+				continue
+			}
+			obj, ok := fact.Object.(string)
+			if !ok || !set[obj] || obj == id {
+				continue
+			}
+			row := map[string]any{"?s": id, "?p": fact.Predicate, "?o": obj, gcamdb.RowWeightKey: weight(id, fact.Predicate, obj)}
+			if src := source(id, fact.Predicate); src != "" {
+				row[gcamdb.RowSourceKey] = src
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	transformer := s.newTransformer(ctx, projectID, store)
+	graph, err := transformer.Transform(ctx, `triples(?s, ?p, ?o)`, rows)
+	if err != nil {
+		return nil, fmt.Errorf("%w: transformer failed: %v", errors.ErrInternal, err)
+	}
+	linked := make(map[string]bool, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		linked[n.ID] = true
+	}
+	for _, id := range order {
+		if !linked[id] {
+			graph.Nodes = append(graph.Nodes, transformer.Node(id))
+		}
+	}
+	if graph.Links == nil {
+		graph.Links = []export.D3Link{}
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	graph.Warnings = warnings
+	if truncated {
+		graph.Truncated = true
+		graph.Warnings = append(graph.Warnings, fmt.Sprintf("subgraph stopped at %d nodes", config.SubgraphMaxNodes))
+	}
+	return graph, nil
+}
+
+// queryNodes returns the node IDs a Datalog query binds, leaving out the
+// values of variables it uses as predicates.
+func queryNodes(ctx context.Context, store *meb.MEBStore, query string) ([]string, []string, error) {
+	atoms, err := datalog.Parse(query)
+	if err != nil {
+		return nil, nil, queryError(err)
+	}
+	predicateVars := make(map[string]bool)
+	for _, atom := range atoms {
+		if atom.Predicate == "triples" && len(atom.Args) == 3 {
+			predicateVars[atom.Args[1]] = true
+		}
+	}
+	res, err := gcamdb.QueryWithOptions(ctx, store, query, gcamdb.QueryOptions{Limit: config.SubgraphMaxNodes})
+	if err != nil {
+		return nil, nil, queryError(err)
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, row := range res.Rows {
+		vars := make([]string, 0, len(row))
+		for v := range row {
+			if !strings.HasPrefix(v, "_") && !predicateVars[v] {
+				vars = append(vars, v)
+			}
+		}
+		sort.Strings(vars)
+		for _, v := range vars {
+			if id, ok := row[v].(string); ok && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, res.Warnings, nil
+}
+
+// nodeNeighbors returns the nodes id has an edge to or from.
+func nodeNeighbors(ctx context.Context, store *meb.MEBStore, id string) []string {
+	var neighbors []string
+	for fact, err := range gcamdb.Scan(ctx, store, id, "", "") {
+		if err != nil || attributePredicates[fact.Predicate] {
+			continue
+		}
+		if obj, ok := fact.Object.(string); ok && obj != id {
+			neighbors = append(neighbors, obj)
+		}
+	}
+	for fact, err := range gcamdb.Scan(ctx, store, "", "", id) {
+		if err != nil || fact.Subject == "" || attributePredicates[fact.Predicate] {
+			continue
+		}
+		if fact.Subject != id {
+			neighbors = append(neighbors, fact.Subject)
+		}
+	}
+	return neighbors
+}
+
+// isNodeValue reports whether a fact value can stand for a node: not
+// multi-line text or an overlong literal.
+func isNodeValue(id string) bool {
+	return id != "" && len(id) <= 200 && !strings.Contains(id, "\n")
+}
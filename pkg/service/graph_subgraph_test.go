@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/export"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestGetSubgraphWithOptions(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// handler -calls-> service -calls-> repo <-calls- worker, handler -imports-> repo
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "handler", Predicate: "calls", Object: "service"},
+		{Subject: "service", Predicate: "calls", Object: "repo"},
+		{Subject: "worker", Predicate: "calls", Object: "repo"},
+		{Subject: "handler", Predicate: "imports", Object: "repo"},
+		{Subject: "handler", Predicate: "kind", Object: "func"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+
+	nodes := func(g *export.D3Graph) []string {
+		var out []string
+		for _, n := range g.Nodes {
+			out = append(out, n.ID)
+		}
+		return out
+	}
+	links := func(g *export.D3Graph) map[string]bool {
+		out := make(map[string]bool)
+		for _, l := range g.Links {
+			out[l.Source+" -"+l.Relation+"-> "+l.Target] = true
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		opts      SubgraphOptions
+		wantNodes []string
+		wantLinks []string
+	}{
+		{"ids", SubgraphOptions{IDs: []string{"handler", "repo"}}, []string{"handler", "repo"}, []string{"handler -imports-> repo"}},
+		{"query", SubgraphOptions{Query: `triples("handler", "calls", ?x)`}, []string{"service"}, nil},
+		{"query with expand", SubgraphOptions{Query: `triples("handler", "calls", ?x)`, Expand: 1}, []string{"handler", "repo", "service"},
+			[]string{"handler -calls-> service", "service -calls-> repo", "handler -imports-> repo"}},
+		{"predicate variables are not nodes", SubgraphOptions{Query: `triples("worker", ?p, ?x)`}, []string{"repo"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := svc.GetSubgraphWithOptions(ctx, "test", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := nodes(g)
+			if len(got) != len(tt.wantNodes) {
+				t.Fatalf("nodes = %v, want %v", got, tt.wantNodes)
+			}
+			for i := range got {
+				if got[i] != tt.wantNodes[i] {
+					t.Fatalf("nodes = %v, want %v", got, tt.wantNodes)
+				}
+			}
+			gotLinks := links(g)
+			if len(gotLinks) != len(tt.wantLinks) {
+				t.Fatalf("links = %v, want %v", gotLinks, tt.wantLinks)
+			}
+			for _, l := range tt.wantLinks {
+				if !gotLinks[l] {
+					t.Errorf("missing link %q in %v", l, gotLinks)
+				}
+			}
+		})
+	}
+
+	if _, err := svc.GetSubgraphWithOptions(ctx, "test", SubgraphOptions{IDs: []string{"handler"}, Expand: 5}); err == nil {
+		t.Error("an expand beyond the limit was accepted")
+	}
+}
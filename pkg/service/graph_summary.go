@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/ingest"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// SummarizePackage writes a has_summary fact on every file directly in the
+// package directory pkgPath, which may omit the project prefix, asking
+// summarizer for the files without one (all of them with opts.Force).
+// progress is called after each batch; see ingest.SummarizeFiles.
+func (s *GraphService) SummarizePackage(ctx context.Context, projectID, pkgPath string, summarizer ingest.Summarizer, opts ingest.FileSummaryOptions, progress func(ingest.FileSummaryProgress)) (*ingest.FileSummaryReport, error) {
+	pkgPath = strings.Trim(path.Clean("/"+strings.TrimSpace(pkgPath)), "/")
+	if pkgPath == "" {
+		return nil, fmt.Errorf("%w: package path is required", errors.ErrInvalidInput)
+	}
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	scoped := s.scope(ctx, projectID)
+
+	var files []string
+	for _, dir := range []string{pkgPath, projectID + "/" + pkgPath} {
+		for fact, err := range gcamdb.Scan(scoped, store, "", config.PredicateType, config.SymbolKindFile) {
+			if err == nil && packageOf(fact.Subject) == dir {
+				files = append(files, fact.Subject)
+			}
+		}
+		if len(files) > 0 {
+			break
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: package %s has no files", errors.ErrNotFound, pkgPath)
+	}
+	if len(files) > config.FileSummaryMaxFiles {
+		return nil, fmt.Errorf("%w: package %s has %d files, more than the %d summarized at once", errors.ErrInvalidInput, pkgPath, len(files), config.FileSummaryMaxFiles)
+	}
+	sort.Strings(files)
+
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+	// Summaries are written under the project's topic; leave the store's topic as it was
+	prev := store.TopicID()
+	defer store.SetTopicID(prev)
+
+	report, err := ingest.SummarizeFiles(ctx, store, projectID, files, summarizer, opts, progress)
+	if err != nil {
+		return report, fmt.Errorf("%w: summarize package %s: %v", errors.ErrInternal, pkgPath, err)
+	}
+	return report, nil
+}
@@ -2,12 +2,21 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	stderrors "errors"
 	"os"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/duynguyendang/gca/internal/manager"
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
 	"github.com/duynguyendang/meb"
 	"github.com/duynguyendang/meb/store"
+	"github.com/stretchr/testify/assert"
 )
 
 // MockStoreManager
@@ -171,3 +180,651 @@ func TestGetFlowPath(t *testing.T) {
 		t.Errorf("Path incomplete. hasAB=%v, hasBC=%v", hasAB, hasBC)
 	}
 }
+
+func TestSharedStoreIsolation(t *testing.T) {
+	dir := t.TempDir()
+	s, err := meb.NewMEBStore(store.DefaultConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects := map[string][]meb.Fact{
+		"be": {
+			{Subject: "be/main.go", Predicate: config.PredicateType, Object: config.FileTypeFile},
+			{Subject: "be/main.go:main", Predicate: config.PredicateHasName, Object: "main"},
+			{Subject: "be/main.go:main", Predicate: config.PredicateCalls, Object: "be/db.go:Open"},
+		},
+		"fe": {
+			{Subject: "fe/app.ts", Predicate: config.PredicateType, Object: config.FileTypeFile},
+			{Subject: "fe/app.ts:main", Predicate: config.PredicateHasName, Object: "main"},
+			{Subject: "fe/app.ts:main", Predicate: config.PredicateCalls, Object: "fe/api.ts:fetch"},
+		},
+	}
+	for id, facts := range projects {
+		s.SetTopicID(gcamdb.TopicForProject(id))
+		if err := s.AddFactBatch(facts); err != nil {
+			t.Fatal(err)
+		}
+		if err := gcamdb.RegisterProject(s, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := manager.NewSharedStoreManager(dir, manager.MemoryProfileDefault, false)
+	defer mgr.CloseAll()
+	svc := NewGraphService(mgr)
+	ctx := context.Background()
+
+	list, err := svc.ListProjects()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListProjects = %v, %v; want be and fe", list, err)
+	}
+	if _, err := svc.ExecuteQuery(ctx, "other", `triples(?s, "calls", ?o)`); err == nil {
+		t.Error("query against an unregistered project succeeded")
+	}
+
+	queries := []string{
+		`triples(?s, "has_name", "main")`,
+		`triples(?s, "calls", ?o)`,
+		`triples(?s, "has_name", ?n), triples(?s, "calls", ?o)`,
+	}
+	for id := range projects {
+		sc := gcamdb.ProjectScope(id)
+		for _, q := range queries {
+			rows, err := svc.ExecuteQuery(ctx, id, q)
+			if err != nil {
+				t.Fatalf("%s: %s: %v", id, q, err)
+			}
+			if len(rows) != 1 {
+				t.Errorf("%s: %s returned %d rows, want 1: %v", id, q, len(rows), rows)
+			}
+			for _, row := range rows {
+				if subj, _ := row["?s"].(string); !sc.Owns(subj) {
+					t.Errorf("%s: %s leaked %q", id, q, subj)
+				}
+			}
+		}
+
+		files, _, err := svc.ListFiles(ctx, id, gcamdb.ScanOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(files)
+		if len(files) != 1 || !sc.Owns(files[0]) {
+			t.Errorf("ListFiles(%s) = %v", id, files)
+		}
+	}
+}
+
+func TestSaveAndGetView(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+
+	graph := &export.D3Graph{
+		Nodes: []export.D3Node{{ID: "a.go:F"}, {ID: "b.go:G"}},
+		Links: []export.D3Link{{Source: "a.go:F", Target: "b.go:G", Relation: "calls"}},
+	}
+	saved, err := svc.SaveView(ctx, "test", &GraphView{
+		Name:    "core calls",
+		Query:   "triples(?s, \"calls\", ?o)",
+		Filters: map[string]string{"owner": "@core"},
+		Graph:   graph,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID == "" || saved.Project != "test" || saved.CreatedAt.IsZero() {
+		t.Fatalf("saved view = %+v, want an ID, project and creation time", saved)
+	}
+
+	got, err := svc.GetView(ctx, "test", saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "core calls" || got.Query != saved.Query || got.Filters["owner"] != "@core" {
+		t.Errorf("loaded view = %+v, want the saved name, query and filters", got)
+	}
+	if len(got.Graph.Nodes) != 2 || len(got.Graph.Links) != 1 {
+		t.Errorf("loaded graph = %+v, want the saved graph", got.Graph)
+	}
+	// A view is not code: it adds no facts to the graph
+	for fact, err := range s.Scan(ViewKeyPrefix+saved.ID, "", "") {
+		t.Errorf("view key has fact %v (%v)", fact, err)
+	}
+
+	if _, err := svc.GetView(ctx, "test", "0000000000000000"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("GetView of a missing view: err = %v, want ErrNotFound", err)
+	}
+	if _, err := svc.GetView(ctx, "other", saved.ID); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("GetView from another project: err = %v, want ErrNotFound", err)
+	}
+	if _, err := svc.SaveView(ctx, "test", &GraphView{Name: " ", Graph: graph}); !stderrors.Is(err, errors.ErrInvalidInput) {
+		t.Errorf("SaveView without a name: err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	svc := NewGraphService(&MockStoreManager{store: s})
+
+	annotations := []*Annotation{
+		{Node: "a.go:F", Labels: []string{"deprecated"}, Note: "use G instead"},
+		{Node: "a.go:F", Labels: []string{"hot-path", "deprecated"}},
+		{Source: "a.go:F", Target: "c.go:H", Relation: "publishes", Virtual: true, Note: "via the event bus"},
+		{Source: "a.go:F", Target: "b.go:G", Labels: []string{"hot-path"}},
+	}
+	for _, a := range annotations {
+		if _, err := svc.AddAnnotation("test", a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, bad := range []*Annotation{
+		{Note: "no target"},
+		{Node: "a.go:F"},
+		{Node: "a.go:F", Virtual: true, Relation: "calls"},
+		{Node: "a.go:F", Labels: []string{"Hot Path"}},
+	} {
+		if _, err := svc.AddAnnotation("test", bad); !stderrors.Is(err, errors.ErrInvalidInput) {
+			t.Errorf("AddAnnotation(%+v): err = %v, want ErrInvalidInput", bad, err)
+		}
+	}
+
+	graph := &export.D3Graph{
+		Nodes: []export.D3Node{{ID: "a.go:F"}, {ID: "b.go:G"}, {ID: "c.go:H"}},
+		Links: []export.D3Link{{Source: "a.go:F", Target: "b.go:G", Relation: "calls"}},
+	}
+	if err := svc.AnnotateGraph("test", graph); err != nil {
+		t.Fatal(err)
+	}
+	if md := graph.Nodes[0].Metadata; md["labels"] != "deprecated,hot-path" || md["notes"] != "use G instead" {
+		t.Errorf("a.go:F metadata = %v, want merged labels and note", md)
+	}
+	if md := graph.Links[0].Metadata; md["labels"] != "hot-path" {
+		t.Errorf("a.go:F -> b.go:G metadata = %v, want the hot-path label", md)
+	}
+	if len(graph.Links) != 2 {
+		t.Fatalf("links = %+v, want the virtual publishes link added", graph.Links)
+	}
+	if l := graph.Links[1]; l.Type != "virtual" || l.Relation != "publishes" || l.Metadata["notes"] != "via the event bus" {
+		t.Errorf("virtual link = %+v", l)
+	}
+
+	hydrated, err := svc.HydrateWithFields(context.Background(), s, "test", []string{"a.go:F"}, HydrateMetadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels, _ := hydrated[0].Metadata["labels"].([]string); len(labels) != 2 {
+		t.Errorf("hydrated labels = %v, want deprecated and hot-path", hydrated[0].Metadata["labels"])
+	}
+}
+
+func TestAnnotationExpiry(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	svc := NewGraphService(&MockStoreManager{store: s})
+	if err := gcamdb.SaveRetention(s, gcamdb.RetentionPolicy{gcamdb.GraphVirtual: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := svc.AddAnnotation("test", &Annotation{Source: "a.go:F", Target: "b.go:G", Relation: "calls", Virtual: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.WithinDuration(t, time.Now().Add(time.Hour), link.ExpiresAt, time.Minute)
+	note, err := svc.AddAnnotation("test", &Annotation{Node: "a.go:F", Note: "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, note.ExpiresAt.IsZero(), "the annotations graph has no TTL")
+	if _, err := svc.AddAnnotation("test", &Annotation{Node: "a.go:F", Note: "late", ExpiresAt: time.Now().Add(-time.Minute)}); !stderrors.Is(err, errors.ErrInvalidInput) {
+		t.Errorf("AddAnnotation expired: err = %v, want ErrInvalidInput", err)
+	}
+
+	annotations, err := loadAnnotations(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations[0].ExpiresAt = time.Now().Add(-time.Second)
+	data, _ := json.Marshal(annotations)
+	if err := s.AddDocument(AnnotationsKey, data, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	annotations, err = loadAnnotations(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, annotations, 1, "expired annotations are hidden") {
+		assert.Equal(t, note.ID, annotations[0].ID)
+	}
+}
+
+func TestAnnotationsFollowMovedSymbols(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	svc := NewGraphService(&MockStoreManager{store: s})
+	uid := gcamdb.SymbolUID("pkg:pkg", "function", "F", "func F()", 0)
+	if err := s.AddFact(meb.Fact{Subject: "pkg/a.go:F", Predicate: config.PredicateHasUID, Object: uid}); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := svc.AddAnnotation("test", &Annotation{Node: "pkg/a.go:F", Labels: []string{"hot-path"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uid, added.NodeUID)
+
+	// F moves to b.go, keeping its UID
+	if err := s.DeleteFactsBySubject("pkg/a.go:F"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFact(meb.Fact{Subject: "pkg/b.go:F", Predicate: config.PredicateHasUID, Object: uid}); err != nil {
+		t.Fatal(err)
+	}
+	annotations, err := loadAnnotations(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, annotations, 1) {
+		assert.Equal(t, "pkg/b.go:F", annotations[0].Node)
+	}
+
+	// A second symbol sharing the UID leaves the annotation where it was
+	if err := s.AddFact(meb.Fact{Subject: "pkg/c.go:F", Predicate: config.PredicateHasUID, Object: uid}); err != nil {
+		t.Fatal(err)
+	}
+	annotations, err = loadAnnotations(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, annotations, 1) {
+		assert.Equal(t, "pkg/a.go:F", annotations[0].Node)
+	}
+}
+
+func TestScoreLinks(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	svc := NewGraphService(&MockStoreManager{store: s})
+	err = gcamdb.RecordLinkEvidence(s, []gcamdb.LinkEvidence{
+		{Subject: "web/app.ts:load", Predicate: config.PredicateCallsAPI, Object: "/api/users", Method: gcamdb.LinkMethodRoute, Evidence: 2},
+		{Subject: "web/app.ts:save", Predicate: config.PredicateCallsAPI, Object: "/api/users", Method: gcamdb.LinkMethodRoute, Evidence: 1},
+		{Subject: "web/app.ts:list", Predicate: config.PredicateCallsAPI, Object: "/api/users", Method: gcamdb.LinkMethodRoute, Evidence: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newGraph := func() *export.D3Graph {
+		return &export.D3Graph{Links: []export.D3Link{
+			{Source: "web/app.ts:load", Target: "/api/users", Relation: config.PredicateCallsAPI},
+			{Source: "web/app.ts:save", Target: "/api/users", Relation: config.PredicateCallsAPI},
+			{Source: "web/app.ts:list", Target: "/api/users", Relation: config.PredicateCallsAPI},
+			{Source: "api/users.go:List", Target: "db/users.go:Find", Relation: config.PredicateCalls},
+		}}
+	}
+
+	graph := newGraph()
+	if err := svc.ScoreLinks("test", graph, 0); err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(t, 0.91, graph.Links[0].Confidence, 1e-9, "two references by route")
+	assert.InDelta(t, 0.7, graph.Links[1].Confidence, 1e-9, "one reference by route")
+	assert.Zero(t, graph.Links[3].Confidence, "extracted links are not scored")
+
+	if _, err := svc.AddAnnotation("test", &Annotation{Node: "a.go:F", Verdict: VerdictConfirmed}); !stderrors.Is(err, errors.ErrInvalidInput) {
+		t.Errorf("verdict on a node: err = %v, want ErrInvalidInput", err)
+	}
+	if _, err := svc.AddAnnotation("test", &Annotation{Source: "web/app.ts:save", Target: "/api/users", Verdict: "maybe"}); !stderrors.Is(err, errors.ErrInvalidInput) {
+		t.Errorf("unknown verdict: err = %v, want ErrInvalidInput", err)
+	}
+	if _, err := svc.AddAnnotation("test", &Annotation{Source: "web/app.ts:save", Target: "/api/users", Verdict: VerdictRejected}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.AddAnnotation("test", &Annotation{Source: "web/app.ts:list", Target: "/api/users", Relation: config.PredicateCallsAPI, Verdict: VerdictRejected}); err != nil {
+		t.Fatal(err)
+	}
+
+	graph = newGraph()
+	if err := svc.ScoreLinks("test", graph, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, graph.Links, 2, "rejected links fall below min_confidence") {
+		assert.Equal(t, "web/app.ts:load", graph.Links[0].Source)
+		assert.Less(t, graph.Links[0].Confidence, 0.91, "rejections lower the route method's precision")
+		assert.Equal(t, "api/users.go:List", graph.Links[1].Source, "unscored links are kept")
+	}
+}
+
+func TestResolvePackageImportsToFiles(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// app/main.go imports the first-party app/pkg/meb and the external meb module
+	facts := []meb.Fact{
+		{Subject: "app/main.go", Predicate: config.PredicateImports, Object: "example.com/app/pkg/meb"},
+		{Subject: "app/main.go", Predicate: config.PredicateImports, Object: "example.com/meb"},
+		{Subject: "app/main.go", Predicate: config.PredicateImportsFile, Object: "app/pkg/meb/store.go"},
+		{Subject: "app/main.go", Predicate: config.PredicateImportsFile, Object: "app/pkg/meb/scan.go"},
+		{Subject: "example.com/meb", Predicate: config.PredicateIsInternal, Object: false},
+		{Subject: "app/pkg/meb/store.go", Predicate: config.PredicateType, Object: config.SymbolKindFile},
+		{Subject: "app/pkg/meb/scan.go", Predicate: config.PredicateType, Object: config.SymbolKindFile},
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+
+	graph := &export.D3Graph{
+		Nodes: []export.D3Node{{ID: "app/main.go"}, {ID: "example.com/app/pkg/meb"}, {ID: "example.com/meb"}},
+		Links: []export.D3Link{
+			{Source: "app/main.go", Target: "example.com/app/pkg/meb", Relation: config.PredicateImports},
+			{Source: "app/main.go", Target: "example.com/meb", Relation: config.PredicateImports},
+		},
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+	files := gcamdb.BuildKindIndex(context.Background(), s)
+	svc.resolvePackageImportsToFiles(context.Background(), s, files, nil, graph, "app/main.go")
+
+	var targets []string
+	for _, l := range graph.Links {
+		targets = append(targets, l.Target)
+	}
+	sort.Strings(targets)
+	want := []string{"app/pkg/meb/scan.go", "app/pkg/meb/store.go", "example.com/meb"}
+	if len(targets) != len(want) {
+		t.Fatalf("import targets = %v, want %v", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Fatalf("import targets = %v, want %v", targets, want)
+		}
+	}
+	for _, n := range graph.Nodes {
+		if n.ID == "app/pkg/meb/store.go" && (n.IsInternal == nil || !*n.IsInternal) {
+			t.Errorf("resolved file %s is not internal", n.ID)
+		}
+	}
+}
+
+func TestGetDuplicates(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+	if err := gcamdb.SaveSimilarPairs(ctx, s, []gcamdb.SimilarPair{
+		gcamdb.NewSimilarPair("a.go:A", "b.go:B", 0.99),
+		gcamdb.NewSimilarPair("b.go:B", "c.go:C", 0.9),
+		gcamdb.NewSimilarPair("x.go:X", "y.go:Y", 1),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := svc.GetDuplicates(ctx, "test", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, []string{"a.go:A", "b.go:B", "c.go:C"}, groups[0].Symbols, "pairs sharing a symbol are grouped")
+		assert.Len(t, groups[0].Pairs, 2)
+		assert.Equal(t, 0.99, groups[0].Score)
+		assert.Equal(t, []string{"x.go:X", "y.go:Y"}, groups[1].Symbols)
+	}
+
+	groups, err = svc.GetDuplicates(ctx, "test", 0.95, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, groups, 1) {
+		assert.Equal(t, []string{"x.go:X", "y.go:Y"}, groups[0].Symbols, "weaker pairs are left out")
+	}
+}
+
+func TestDetectCommunityHierarchy(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// Two triangles of calls joined by one import
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "a.go:A1", Predicate: config.PredicateCalls, Object: "a.go:A2"},
+		{Subject: "a.go:A2", Predicate: config.PredicateCalls, Object: "a.go:A3"},
+		{Subject: "a.go:A3", Predicate: config.PredicateCalls, Object: "a.go:A1"},
+		{Subject: "b.go:B1", Predicate: config.PredicateCalls, Object: "b.go:B2"},
+		{Subject: "b.go:B2", Predicate: config.PredicateCalls, Object: "b.go:B3"},
+		{Subject: "b.go:B3", Predicate: config.PredicateCalls, Object: "b.go:B1"},
+		{Subject: "a.go:A1", Predicate: config.PredicateImports, Object: "b.go:B1"},
+		{Subject: "a.go:A1", Predicate: config.PredicateType, Object: config.SymbolKindFunc},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+
+	scanned, err := svc.DetectCommunityHierarchy(ctx, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, scanned.Levels, 1) {
+		assert.NotEmpty(t, scanned.Levels[0].Communities)
+	}
+
+	if err := gcamdb.SaveAdjacency(s, "test", gcamdb.BuildAdjacency(ctx, s, s.Count())); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := svc.DetectCommunityHierarchy(ctx, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, scanned, snapshot, "the adjacency snapshot gives the scanned graph")
+}
+
+func TestGetTopSymbols(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// Every handler calls the store, which calls the db; cmd/main.go imports all
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "api.go:List", Predicate: config.PredicateCalls, Object: "store.go:Get"},
+		{Subject: "api.go:Show", Predicate: config.PredicateCalls, Object: "store.go:Get"},
+		{Subject: "api.go:Edit", Predicate: config.PredicateCalls, Object: "store.go:Get"},
+		{Subject: "store.go:Get", Predicate: config.PredicateCalls, Object: "db.go:Query"},
+		{Subject: "cmd/main.go", Predicate: config.PredicateImports, Object: "api.go"},
+		{Subject: "cmd/main.go", Predicate: config.PredicateImports, Object: "store.go"},
+		{Subject: "cmd/main.go", Predicate: config.PredicateImports, Object: "db.go"},
+		{Subject: "store.go:Get", Predicate: config.PredicateIsEntryPoint, Object: "handler"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+	ids := func(results []CentralityResult) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.SymbolID)
+		}
+		return out
+	}
+
+	top, err := svc.GetTopSymbols(ctx, "test", "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, top, 2) {
+		assert.Equal(t, "store.go:Get", top[0].SymbolID)
+		assert.Equal(t, 1.0, top[0].Centrality)
+		assert.Equal(t, 3, top[0].InDegree)
+		assert.Equal(t, 1, top[0].OutDegree)
+		assert.True(t, top[0].IsEntry)
+		assert.Equal(t, "cmd/main.go", top[1].SymbolID)
+	}
+
+	fanIn, err := svc.GetTopSymbols(ctx, "test", MetricFanIn, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"store.go:Get", "db.go:Query"}, ids(fanIn), "only called symbols have a fan-in")
+
+	ranked, err := svc.GetTopSymbols(ctx, "test", MetricPageRank, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, ranked, 5) {
+		assert.Equal(t, []string{"db.go:Query", "store.go:Get"}, ids(ranked[:2]), "rank flows down the calls")
+		assert.Equal(t, 1.0, ranked[0].Centrality)
+	}
+
+	// Rankings are cached until facts are written
+	if err := s.AddFact(meb.Fact{Subject: "db.go:Query", Predicate: config.PredicateCalls, Object: "db.go:conn"}); err != nil {
+		t.Fatal(err)
+	}
+	fanIn, err = svc.GetTopSymbols(ctx, "test", MetricFanIn, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, ids(fanIn), "db.go:conn")
+
+	_, err = svc.GetTopSymbols(ctx, "test", "betweenness", 10)
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+}
+
+func TestGetSymbolOverview(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	src := "package api\n\n// Get returns a row.\nfunc Get(\n\tid string,\n) (Row, error) {\n\treturn db.Query(id)\n}\n"
+	if err := s.AddDocument("api/get.go", []byte(src), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "api/get.go", Predicate: config.PredicateDefines, Object: "api/get.go:Get"},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateHasKind, Object: config.SymbolKindFunc},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateStartLine, Object: int32(4)},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateEndLine, Object: int32(8)},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateHasDoc, Object: "Get returns a row."},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateHasSummary, Object: "Looks a row up by ID."},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateHasTag, Object: "storage"},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateHasRole, Object: "handler"},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateOwnedBy, Object: "@data"},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateLOC, Object: 5},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateComplexity, Object: 1},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateParamCount, Object: 1},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateHasCentrality, Object: 0.5},
+		{Subject: "api/get.go:Get", Predicate: config.PredicateCalls, Object: "db.go:Query"},
+		{Subject: "db.go:Query", Predicate: config.PredicateHasKind, Object: config.SymbolKindFunc},
+		{Subject: "api/list.go:List", Predicate: config.PredicateCalls, Object: "api/get.go:Get"},
+		{Subject: "api/show.go:Show", Predicate: config.PredicateCalls, Object: "api/get.go:Get"},
+		{Subject: "api/edit.go:Edit", Predicate: config.PredicateCalls, Object: "api/get.go:Get"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+
+	ov, err := svc.GetSymbolOverview(ctx, "test", "api/get.go:Get", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, config.SymbolKindFunc, ov.Kind)
+	assert.Equal(t, "api/get.go", ov.File)
+	assert.Equal(t, [2]int{4, 8}, [2]int{ov.StartLine, ov.EndLine})
+	assert.Equal(t, "func Get( id string, ) (Row, error)", ov.Signature)
+	assert.Equal(t, "Get returns a row.", ov.Doc)
+	assert.Equal(t, "Looks a row up by ID.", ov.Summary)
+	assert.Equal(t, []string{"handler", "storage"}, ov.Tags)
+	assert.Equal(t, []string{"@data"}, ov.Owners)
+	assert.Equal(t, SymbolMetrics{LOC: 5, Complexity: 1, Params: 1, Callers: 3, Callees: 1, Centrality: 0.5}, ov.Metrics)
+	assert.Equal(t, []string{"api/edit.go:Edit", "api/list.go:List"}, ov.Callers, "callers are cut to the limit")
+	assert.Equal(t, []string{"db.go:Query"}, ov.Callees)
+
+	if err := gcamdb.SaveAdjacency(s, "test", gcamdb.BuildAdjacency(ctx, s, s.Count())); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := svc.GetSymbolOverview(ctx, "test", "api/get.go:Get", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ov, snapshot, "the adjacency snapshot gives the scanned neighbors")
+
+	// Symbols without a source range still have an overview
+	callee, err := svc.GetSymbolOverview(ctx, "test", "db.go:Query", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"api/get.go:Get"}, callee.Callers)
+	assert.Empty(t, callee.Code)
+
+	_, err = svc.GetSymbolOverview(ctx, "test", "api/gone.go:Missing", 0)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestCodeSignature(t *testing.T) {
+	for code, want := range map[string]string{
+		"func A() {}":                     "func A() {}",
+		"func A() {\n\treturn\n}":         "func A()",
+		"// A does.\nfunc A() error {\n}": "func A() error",
+		"@cache\ndef a(x):\n    return x": "def a(x)",
+		"type T struct {\n\tA int\n}":     "type T struct",
+		"var x = 1":                       "var x = 1",
+		"":                                "",
+	} {
+		assert.Equal(t, want, codeSignature(code), "signature of %q", code)
+	}
+}
+
+func TestFindFilesWithPrefix(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "pkg/meb/scan.go", Predicate: config.PredicateInPackage, Object: "meb"},
+		{Subject: "pkg/meb/store.go", Predicate: config.PredicateInPackage, Object: "meb"},
+		{Subject: "pkg/mebx/x.go", Predicate: config.PredicateInPackage, Object: "mebx"},
+		{Subject: "src/Store.java", Predicate: config.PredicateInPackage, Object: "com.example.pkg.meb"},
+		{Subject: "cmd/main.go", Predicate: config.PredicateInPackage, Object: "main"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, prefix := range []string{"pkg/meb/", "example.com/app/pkg/meb", "main"} {
+		scanned := findFilesWithPrefix(ctx, s, nil, prefix)
+		indexed := findFilesWithPrefix(ctx, s, gcamdb.BuildAdjacency(ctx, s, s.Count()), prefix)
+		sort.Strings(scanned)
+		sort.Strings(indexed)
+		assert.Equal(t, scanned, indexed, "files matching %s", prefix)
+	}
+	indexed := findFilesWithPrefix(ctx, s, gcamdb.BuildAdjacency(ctx, s, s.Count()), "example.com/app/pkg/meb")
+	assert.ElementsMatch(t, []string{"src/Store.java"}, indexed)
+	assert.ElementsMatch(t, []string{"pkg/meb/scan.go", "pkg/meb/store.go"}, findFilesWithPrefix(ctx, s, gcamdb.BuildAdjacency(ctx, s, s.Count()), "pkg/meb/"))
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+)
+
+// VulnerabilityReport describes one advisory and everything in the graph it affects.
+type VulnerabilityReport struct {
+	ID       string   `json:"id"`
+	Severity string   `json:"severity"`
+	Summary  string   `json:"summary,omitempty"`
+	FixedIn  string   `json:"fixed_in,omitempty"`
+	Affected []string `json:"affected"`
+}
+
+// severityRank orders textual severities for sorting; unknown/CVSS vectors rank last.
+var severityRank = map[string]int{
+	"CRITICAL": 0,
+	"HIGH":     1,
+	"MODERATE": 2,
+	"MEDIUM":   2,
+	"LOW":      3,
+}
+
+// GetVulnerabilities lists advisories recorded by the vulnerability enrichment pass,
+// most severe first. An optional severity filter matches case-insensitively.
+func (s *GraphService) GetVulnerabilities(ctx context.Context, projectID, severity string) ([]VulnerabilityReport, error) {
+	store, err := s.getStore(projectID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.scope(ctx, projectID)
+
+	byID := make(map[string]*VulnerabilityReport)
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateHasVulnerability, "") {
+		if err != nil {
+			continue
+		}
+		vulnID, ok := fact.Object.(string)
+		if !ok {
+			continue
+		}
+		report, ok := byID[vulnID]
+		if !ok {
+			report = &VulnerabilityReport{ID: vulnID, Affected: []string{}}
+			byID[vulnID] = report
+		}
+		report.Affected = append(report.Affected, fact.Subject)
+	}
+
+	reports := make([]VulnerabilityReport, 0, len(byID))
+	for id, report := range byID {
+		report.Severity = firstString(scanStrings(ctx, store, id, config.PredicateVulnSeverity))
+		report.Summary = firstString(scanStrings(ctx, store, id, config.PredicateVulnSummary))
+		report.FixedIn = firstString(scanStrings(ctx, store, id, config.PredicateVulnFixedIn))
+		if severity != "" && !strings.EqualFold(report.Severity, severity) {
+			continue
+		}
+		sort.Strings(report.Affected)
+		reports = append(reports, *report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		ri, ok := severityRank[strings.ToUpper(reports[i].Severity)]
+		if !ok {
+			ri = len(severityRank)
+		}
+		rj, ok := severityRank[strings.ToUpper(reports[j].Severity)]
+		if !ok {
+			rj = len(severityRank)
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return reports[i].ID < reports[j].ID
+	})
+	return reports, nil
+}
+
+func firstString(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestGetVulnerabilities(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "vuln_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	s, err := meb.NewMEBStore(store.DefaultConfig(tmpDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	facts := []meb.Fact{
+		{Subject: "GO-2024-0001", Predicate: config.PredicateVulnSeverity, Object: "LOW"},
+		{Subject: "GO-2024-0002", Predicate: config.PredicateVulnSeverity, Object: "CRITICAL"},
+		{Subject: "GO-2024-0002", Predicate: config.PredicateVulnFixedIn, Object: "v1.2.0"},
+		{Subject: "github.com/acme/lib@v1.0.0", Predicate: config.PredicateHasVulnerability, Object: "GO-2024-0001"},
+		{Subject: "github.com/acme/lib@v1.0.0", Predicate: config.PredicateHasVulnerability, Object: "GO-2024-0002"},
+		{Subject: "app/main.go:main", Predicate: config.PredicateHasVulnerability, Object: "GO-2024-0002"},
+	}
+	if err := s.AddFactBatch(facts); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewGraphService(&MockStoreManager{store: s})
+	reports, err := svc.GetVulnerabilities(context.Background(), "test", "")
+	if err != nil {
+		t.Fatalf("GetVulnerabilities failed: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d: %+v", len(reports), reports)
+	}
+	if reports[0].ID != "GO-2024-0002" || reports[0].FixedIn != "v1.2.0" || len(reports[0].Affected) != 2 {
+		t.Errorf("expected critical advisory first with 2 affected, got %+v", reports[0])
+	}
+
+	low, err := svc.GetVulnerabilities(context.Background(), "test", "low")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(low) != 1 || low[0].ID != "GO-2024-0001" {
+		t.Errorf("severity filter failed: %+v", low)
+	}
+}
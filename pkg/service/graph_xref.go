@@ -15,6 +15,7 @@ func (s *GraphService) GetCallers(ctx context.Context, projectID, symbolID strin
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	symbolID = symbolID
 
@@ -39,6 +40,7 @@ func (s *GraphService) GetCallees(ctx context.Context, projectID, symbolID strin
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	symbolID = symbolID
 
@@ -181,12 +183,13 @@ func (s *GraphService) GetWhoCallsFocused(ctx context.Context, projectID, symbol
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	// For depth=1, do direct scan without building full graph
 	if depth <= 1 {
 		var callers []string
 		seen := make(map[string]bool)
-		for fact := range store.ScanContext(ctx, "", config.PredicateCalls, symbolID) {
+		for fact := range gcamdb.Scan(ctx, store, "", config.PredicateCalls, symbolID) {
 			if fact.Subject != "" && !seen[fact.Subject] {
 				callers = append(callers, fact.Subject)
 				seen[fact.Subject] = true
@@ -211,12 +214,13 @@ func (s *GraphService) GetWhatCallsFocused(ctx context.Context, projectID, symbo
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	// For depth=1, do direct scan without building full graph
 	if depth <= 1 {
 		var callees []string
 		seen := make(map[string]bool)
-		for fact := range store.ScanContext(ctx, symbolID, config.PredicateCalls, "") {
+		for fact := range gcamdb.Scan(ctx, store, symbolID, config.PredicateCalls, "") {
 			if obj, ok := fact.Object.(string); ok {
 				if obj != "" && !seen[obj] {
 					callees = append(callees, obj)
@@ -243,11 +247,12 @@ func (s *GraphService) GetWhoCallsFocusedGraph(ctx context.Context, projectID, s
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	// Direct scan for callers of symbolID
 	var callers []string
 	seen := make(map[string]bool)
-	for fact := range store.ScanContext(ctx, "", config.PredicateCalls, symbolID) {
+	for fact := range gcamdb.Scan(ctx, store, "", config.PredicateCalls, symbolID) {
 		if fact.Subject != "" && !seen[fact.Subject] {
 			callers = append(callers, fact.Subject)
 			seen[fact.Subject] = true
@@ -313,11 +318,12 @@ func (s *GraphService) GetWhatCallsFocusedGraph(ctx context.Context, projectID,
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	// Direct scan for calls from symbolID
 	var callees []string
 	seen := make(map[string]bool)
-	for fact := range store.ScanContext(ctx, symbolID, config.PredicateCalls, "") {
+	for fact := range gcamdb.Scan(ctx, store, symbolID, config.PredicateCalls, "") {
 		if obj, ok := fact.Object.(string); ok {
 			if obj != "" && !seen[obj] {
 				callees = append(callees, obj)
@@ -383,6 +389,7 @@ func (s *GraphService) CheckReachability(ctx context.Context, projectID, fromID,
 	if err != nil {
 		return false, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	resolver := ingest.NewSymbolResolver(store)
 	cg, err := resolver.BuildCallGraph(store)
@@ -405,6 +412,7 @@ func (s *GraphService) DetectCycles(ctx context.Context, projectID string) ([][]
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	resolver := ingest.NewSymbolResolver(store)
 	cg, err := resolver.BuildCallGraph(store)
@@ -420,6 +428,7 @@ func (s *GraphService) FindLCA(ctx context.Context, projectID, symbolA, symbolB
 	if err != nil {
 		return "", err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	resolver := ingest.NewSymbolResolver(store)
 	cg, err := resolver.BuildCallGraph(store)
@@ -442,6 +451,7 @@ func (s *GraphService) EnrichWithCalledBy(ctx context.Context, projectID string)
 	if err != nil {
 		return err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	resolver := ingest.NewSymbolResolver(store)
 	cg, err := resolver.BuildCallGraph(store)
@@ -512,6 +522,7 @@ func (s *GraphService) QueryCalledBy(ctx context.Context, projectID, symbolID st
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	query := fmt.Sprintf(`triples(?caller, "%s", "%s")`, config.PredicateCalledBy, symbolID)
 	return gcamdb.Query(ctx, store, query)
@@ -522,6 +533,7 @@ func (s *GraphService) QueryCalls(ctx context.Context, projectID, symbolID strin
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
 
 	query := fmt.Sprintf(`triples("%s", "%s", ?callee)`, symbolID, config.PredicateCalls)
 	return gcamdb.Query(ctx, store, query)
@@ -4,11 +4,13 @@ import (
 	"container/heap"
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/duynguyendang/gca/pkg/common"
+	"github.com/duynguyendang/gca/pkg/common/deadline"
 	"github.com/duynguyendang/gca/pkg/config"
 	"github.com/duynguyendang/gca/pkg/export"
 	gcamdb "github.com/duynguyendang/gca/pkg/meb"
@@ -16,14 +18,78 @@ import (
 	"github.com/duynguyendang/meb"
 )
 
+// Path directions for PathOptions.
+const (
+	PathForward    = "forward"    // Follow facts from subject to object
+	PathReverse    = "reverse"    // Follow facts from object to subject
+	PathUndirected = "undirected" // Follow facts either way
+)
+
+// PathOptions restricts the edges FindShortestPathWithOptions follows.
+type PathOptions struct {
+	Predicates []string // Predicates followed, all when empty (the structural ones with an adjacency snapshot); "parent_defines" is defines followed to the parent
+	Direction  string   // PathForward (the default), PathReverse or PathUndirected
+	MaxDepth   int      // Edges in the path, config.MaxPathDepth when 0
+}
+
+// pathEdge is how the pathfinder reaches a neighbor: the predicate of the fact
+// and whether the fact points from the neighbor back to the current node.
+type pathEdge struct {
+	pred     string
+	reversed bool
+}
+
+// allows reports whether the options follow pred.
+func (o PathOptions) allows(pred string) bool {
+	return len(o.Predicates) == 0 || slices.Contains(o.Predicates, pred)
+}
+
+// structural reports whether the options only follow structural predicates,
+// whose edges an adjacency snapshot holds.
+func (o PathOptions) structural() bool {
+	for _, p := range o.Predicates {
+		if p != config.PredicateParentDefines && !slices.Contains(config.StructuralPredicates, p) {
+			return false
+		}
+	}
+	return true
+}
+
 // FindShortestPath implements Dijkstra's algorithm to find the shortest weighted path between two symbols.
 // It considers edge weights based on predicate types (calls, imports, defines, etc.).
 // Returns a D3Graph containing the path as nodes and links, or an error if the path cannot be found.
 func (s *GraphService) FindShortestPath(ctx context.Context, projectID, startID, endID string) (*export.D3Graph, error) {
+	return s.FindShortestPathWithOptions(ctx, projectID, startID, endID, PathOptions{})
+}
+
+// FindShortestPathWithOptions is FindShortestPath over the edges opts allows,
+// such as calls only, or imports followed backwards. Links of facts followed
+// in reverse keep the fact's own direction.
+func (s *GraphService) FindShortestPathWithOptions(ctx context.Context, projectID, startID, endID string, opts PathOptions) (*export.D3Graph, error) {
+	switch opts.Direction {
+	case "":
+		opts.Direction = PathForward
+	case PathForward, PathReverse, PathUndirected:
+	default:
+		return nil, fmt.Errorf("unknown path direction %q", opts.Direction)
+	}
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = config.MaxPathDepth
+	}
+
 	store, err := s.getStore(projectID)
 	if err != nil {
 		return nil, err
 	}
+	ctx = s.scope(ctx, projectID)
+	deadline.Enter(ctx, "path search")
+
+	// Neighbors come from the adjacency snapshot when it has every edge followed
+	var adj *gcamdb.Adjacency
+	if opts.structural() {
+		adj = s.adjacency(ctx, projectID, store)
+	}
 
 	cleanStart := strings.Trim(startID, "\"")
 	cleanEnd := strings.Trim(endID, "\"")
@@ -62,9 +128,9 @@ func (s *GraphService) FindShortestPath(ctx context.Context, projectID, startID,
 	}
 
 	startT := time.Now()
-	neighborCache := make(map[string]map[string]string) // node -> neighbor -> predicate
+	neighborCache := make(map[string]map[string]pathEdge) // node -> neighbor -> edge
 	depth := make(map[string]int)
-	edgePred := make(map[string]string) // curr -> predicate from parent
+	edgeTo := make(map[string]pathEdge) // curr -> edge from parent
 	depth[cleanStart] = 0
 
 	logger.Debug("Pathfinder Dijkstra start", "start", cleanStart, "end", cleanEnd)
@@ -89,28 +155,28 @@ func (s *GraphService) FindShortestPath(ctx context.Context, projectID, startID,
 		}
 
 		d := depth[curr]
-		if d >= config.MaxPathDepth {
+		if d >= maxDepth {
 			continue
 		}
 
 		// Get Neighbors with Predicates (Cached)
-		var neighbors map[string]string
+		var neighbors map[string]pathEdge
 		if cached, ok := neighborCache[curr]; ok {
 			neighbors = cached
 		} else {
-			neighbors = s.getWeightedNeighbors(ctx, store, curr, portals)
+			neighbors = s.getWeightedNeighbors(ctx, store, adj, curr, portals, opts)
 			neighborCache[curr] = neighbors
 		}
 
 		// Sort neighbors by weight to ensure branching doesn't cut high-priority links
 		type neighborWeight struct {
 			n    string
-			pred string
+			edge pathEdge
 			w    int
 		}
 		sorted := make([]neighborWeight, 0, len(neighbors))
-		for n, pred := range neighbors {
-			sorted = append(sorted, neighborWeight{n, pred, s.getWeight(pred)})
+		for n, edge := range neighbors {
+			sorted = append(sorted, neighborWeight{n, edge, s.getWeight(edge.pred)})
 		}
 		sort.Slice(sorted, func(i, j int) bool {
 			return sorted[i].w < sorted[j].w
@@ -121,12 +187,12 @@ func (s *GraphService) FindShortestPath(ctx context.Context, projectID, startID,
 				break
 			}
 
-			n, pred, weight := nw.n, nw.pred, nw.w
+			n, weight := nw.n, nw.w
 			newCost := cost + weight
 			if oldD, ok := dist[n]; !ok || newCost < oldD {
 				dist[n] = newCost
 				parent[n] = curr
-				edgePred[n] = pred
+				edgeTo[n] = nw.edge
 				depth[n] = d + 1
 				heap.Push(pq, &Item{
 					Value:    n,
@@ -149,9 +215,13 @@ func (s *GraphService) FindShortestPath(ctx context.Context, projectID, startID,
 				break
 			}
 			p := parent[curr]
-			pred := edgePred[curr]
+			edge := edgeTo[curr]
 			if p != "" { // Only create link if parent exists (not at start)
-				links = append([]export.D3Link{{Source: p, Target: curr, Relation: pred}}, links...)
+				link := export.D3Link{Source: p, Target: curr, Relation: edge.pred}
+				if edge.reversed {
+					link.Source, link.Target = curr, p
+				}
+				links = append([]export.D3Link{link}, links...)
 			}
 			curr = p
 		}
@@ -166,7 +236,7 @@ func (s *GraphService) FindShortestPath(ctx context.Context, projectID, startID,
 	if (strings.Contains(cleanStart, ":") || strings.Contains(cleanEnd, ":")) &&
 		(startFile != cleanStart || endFile != cleanEnd) {
 		logger.Debug("Pathfinder fallback to file-level", "start", startFile, "end", endFile)
-		return s.FindShortestPath(ctx, projectID, startFile, endFile)
+		return s.FindShortestPathWithOptions(ctx, projectID, startFile, endFile, opts)
 	}
 
 	return &export.D3Graph{Nodes: []export.D3Node{}, Links: []export.D3Link{}}, nil
@@ -178,44 +248,76 @@ func (s *GraphService) getWeight(pred string) int {
 		return config.PathfinderEdgeWeightFile
 	case config.PredicateImports, config.PredicateDefines, config.PredicateInPackage:
 		return config.PathfinderEdgeWeightDir
+	case config.PredicateMayCall:
+		return config.PathfinderEdgeWeightDispatch
 	}
 	return config.PathfinderEdgeWeightFunction
 }
 
-func (s *GraphService) getWeightedNeighbors(ctx context.Context, store *meb.MEBStore, nodeID string, portals map[string]string) map[string]string {
-	neighbors := make(map[string]string)
+// getWeightedNeighbors returns the neighbors of nodeID that opts allows, each
+// with its lightest edge, read from adj or, when it is nil, scanned from store.
+func (s *GraphService) getWeightedNeighbors(ctx context.Context, store *meb.MEBStore, adj *gcamdb.Adjacency, nodeID string, portals map[string]string, opts PathOptions) map[string]pathEdge {
+	neighbors := make(map[string]pathEdge)
+	add := func(n string, edge pathEdge) {
+		if n == nodeID || !opts.allows(edge.pred) {
+			return
+		}
+		if old, exists := neighbors[n]; !exists || s.getWeight(edge.pred) < s.getWeight(old.pred) {
+			neighbors[n] = edge
+		}
+	}
+	if adj != nil {
+		adjacentNeighbors(adj, nodeID, portals, opts, add)
+		return neighbors
+	}
 
-	// Portals check (Logical jump)
-	if handler, ok := portals[nodeID]; ok {
-		neighbors[handler] = config.PredicateHandledBy
+	// Scan every predicate at once, or each allowed one.
+	preds := opts.Predicates
+	if len(preds) == 0 {
+		preds = []string{""}
 	}
 
-	// 1. Outbound edges
-	for fact, err := range store.Scan(nodeID, "", "") {
-		if err != nil {
-			continue
+	if opts.Direction != PathReverse {
+		// Portals check (Logical jump)
+		if handler, ok := portals[nodeID]; ok {
+			add(handler, pathEdge{pred: config.PredicateHandledBy})
 		}
-		pred := fact.Predicate
-		obj := fact.Object.(string)
 
-		if obj == nodeID {
-			continue
+		// 1. Outbound edges
+		for _, p := range preds {
+			for fact, err := range gcamdb.Scan(ctx, store, nodeID, p, "") {
+				if err != nil {
+					continue
+				}
+				if obj, ok := fact.Object.(string); ok {
+					add(obj, pathEdge{pred: fact.Predicate})
+				}
+			}
 		}
 
-		if oldPred, exists := neighbors[obj]; !exists || s.getWeight(pred) < s.getWeight(oldPred) {
-			neighbors[obj] = pred
+		// 2. Inbound 'defines' (Structure Nav)
+		if opts.allows(config.PredicateParentDefines) {
+			for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateDefines, nodeID) {
+				if err != nil {
+					continue
+				}
+				add(fact.Subject, pathEdge{pred: config.PredicateParentDefines})
+			}
 		}
 	}
 
-	// 2. Inbound 'defines' (Structure Nav)
-	for fact, err := range store.Scan("", config.PredicateDefines, nodeID) {
-		if err != nil {
-			continue
-		}
-		parent := fact.Subject
-		pred := config.PredicateParentDefines
-		if oldPred, exists := neighbors[parent]; !exists || s.getWeight(pred) < s.getWeight(oldPred) {
-			neighbors[parent] = pred
+	if opts.Direction != PathForward {
+		// Inbound edges, followed back to their subjects
+		for _, p := range preds {
+			if p == config.PredicateParentDefines {
+				continue
+			}
+			for fact, err := range gcamdb.Scan(ctx, store, "", p, nodeID) {
+				if err != nil || fact.Subject == "" {
+					continue
+				}
+				add(fact.Subject, pathEdge{pred: fact.Predicate, reversed: true})
+			}
 		}
 	}
 
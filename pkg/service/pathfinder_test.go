@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/export"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func TestFindShortestPathWithOptions(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// handler -calls-> service -calls-> repo <-calls- worker, and handler -imports-> repo
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "handler", Predicate: "calls", Object: "service"},
+		{Subject: "service", Predicate: "calls", Object: "repo"},
+		{Subject: "worker", Predicate: "calls", Object: "repo"},
+		{Subject: "handler", Predicate: "imports", Object: "repo"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+	ctx := context.Background()
+
+	links := func(g *export.D3Graph) []string {
+		var out []string
+		for _, l := range g.Links {
+			out = append(out, l.Source+" -"+l.Relation+"-> "+l.Target)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name       string
+		start, end string
+		opts       PathOptions
+		want       []string
+	}{
+		{"default follows cheapest edges", "handler", "repo", PathOptions{}, []string{"handler -calls-> service", "service -calls-> repo"}},
+		{"imports only", "handler", "repo", PathOptions{Predicates: []string{"imports"}}, []string{"handler -imports-> repo"}},
+		{"depth limit", "handler", "repo", PathOptions{Predicates: []string{"calls"}, MaxDepth: 1}, nil},
+		{"forward cannot go against calls", "repo", "handler", PathOptions{Predicates: []string{"calls"}}, nil},
+		{"reverse keeps fact direction", "repo", "handler", PathOptions{Predicates: []string{"calls"}, Direction: PathReverse}, []string{"service -calls-> repo", "handler -calls-> service"}},
+		{"undirected", "handler", "worker", PathOptions{Predicates: []string{"calls"}, Direction: PathUndirected}, []string{"handler -calls-> service", "service -calls-> repo", "worker -calls-> repo"}},
+	}
+	run := func(t *testing.T) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				g, err := svc.FindShortestPathWithOptions(ctx, "test", tt.start, tt.end, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := links(g)
+				if len(got) != len(tt.want) {
+					t.Fatalf("links = %v, want %v", got, tt.want)
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Errorf("links = %v, want %v", got, tt.want)
+						break
+					}
+				}
+			})
+		}
+	}
+	t.Run("scanned", run)
+
+	// The same paths are found from the adjacency snapshot
+	if err := gcamdb.SaveAdjacency(s, "test", gcamdb.BuildAdjacency(ctx, s, s.Count())); err != nil {
+		t.Fatal(err)
+	}
+	if svc.adjacency(ctx, "test", s) == nil {
+		t.Fatal("the saved adjacency snapshot is not used")
+	}
+	t.Run("snapshot", run)
+
+	if _, err := svc.FindShortestPathWithOptions(ctx, "test", "handler", "repo", PathOptions{Direction: "sideways"}); err == nil {
+		t.Error("an unknown direction was accepted")
+	}
+}
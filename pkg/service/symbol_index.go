@@ -0,0 +1,407 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	gcamdb "github.com/duynguyendang/gca/pkg/meb"
+	"github.com/duynguyendang/meb"
+)
+
+// SymbolMatch is one ranked symbol search result.
+type SymbolMatch struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	Kind  string  `json:"kind,omitempty"`
+	Score float64 `json:"score"`
+}
+
+// Match-quality tiers. Tiers are far enough apart that the incoming-edge boost
+// reorders symbols within a tier but never lifts one above a better match.
+const (
+	scoreExact     = 1000
+	scorePrefix    = 800
+	scoreSubstring = 600
+	scoreCamelCase = 400
+	scoreFuzzy     = 200
+
+	// inboundBoost scales log(1+incoming edges); 20 edges add about 30 points.
+	inboundBoost = 10
+
+	// maxSymbolCandidates caps how many candidates a single lookup scores.
+	maxSymbolCandidates = 2000
+)
+
+// symbolIndex answers symbol autocomplete without scanning the store. IDs are
+// kept sorted by their lowercase form for prefix lookups, a second ordering by
+// short name serves name-prefix lookups, and a trigram index narrows substring
+// lookups to a few candidates.
+type symbolIndex struct {
+	ids      []string           // Symbol IDs, ordered by lower
+	lower    []string           // Lowercase IDs, sorted
+	names    []string           // Short display name per position
+	kinds    []string           // Symbol kind per position, may be empty
+	inbound  []int              // Incoming call edges per position
+	byName   []int32            // Positions ordered by lowercase name
+	nameKeys []string           // Lowercase names, aligned with byName
+	trigrams map[string][]int32 // Trigram -> ascending positions in lower
+}
+
+// symbolInfo is the per-symbol data a symbolIndex is built from.
+type symbolInfo struct {
+	id, name, kind string
+	inbound        int
+}
+
+func newSymbolIndex(symbols []symbolInfo) *symbolIndex {
+	sort.Slice(symbols, func(i, j int) bool {
+		li, lj := strings.ToLower(symbols[i].id), strings.ToLower(symbols[j].id)
+		if li != lj {
+			return li < lj
+		}
+		return symbols[i].id < symbols[j].id
+	})
+	n := len(symbols)
+	idx := &symbolIndex{
+		ids:      make([]string, n),
+		lower:    make([]string, n),
+		names:    make([]string, n),
+		kinds:    make([]string, n),
+		inbound:  make([]int, n),
+		byName:   make([]int32, n),
+		nameKeys: make([]string, n),
+		trigrams: make(map[string][]int32),
+	}
+	for i, sym := range symbols {
+		name := sym.name
+		if name == "" {
+			name = symbolShortName(sym.id)
+		}
+		l := strings.ToLower(sym.id)
+		idx.ids[i], idx.lower[i], idx.names[i], idx.kinds[i], idx.inbound[i] = sym.id, l, name, sym.kind, sym.inbound
+		idx.byName[i] = int32(i)
+		for _, tri := range trigramsOf(l) {
+			idx.trigrams[tri] = append(idx.trigrams[tri], int32(i))
+		}
+	}
+	sort.SliceStable(idx.byName, func(a, b int) bool {
+		return strings.ToLower(idx.names[idx.byName[a]]) < strings.ToLower(idx.names[idx.byName[b]])
+	})
+	for i, pos := range idx.byName {
+		idx.nameKeys[i] = strings.ToLower(idx.names[pos])
+	}
+	return idx
+}
+
+// symbolShortName derives a display name from a symbol ID such as
+// "pkg/server.go:Server.Start" or "pkg/server.go".
+func symbolShortName(id string) string {
+	if i := strings.LastIndex(id, ":"); i >= 0 {
+		return id[i+1:]
+	}
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// trigramsOf returns the distinct byte trigrams of s, in order of first appearance.
+func trigramsOf(s string) []string {
+	if len(s) < 3 {
+		return nil
+	}
+	seen := make(map[string]bool, len(s)-2)
+	out := make([]string, 0, len(s)-2)
+	for i := 0; i+3 <= len(s); i++ {
+		tri := s[i : i+3]
+		if !seen[tri] {
+			seen[tri] = true
+			out = append(out, tri)
+		}
+	}
+	return out
+}
+
+// search returns up to limit symbols matching query, best first. Matching is
+// case-insensitive; see matchScore for the ranking.
+func (idx *symbolIndex) search(query string, limit int) []SymbolMatch {
+	q := strings.ToLower(query)
+	cands := make(map[int32]struct{})
+	add := func(pos int32) bool {
+		cands[pos] = struct{}{}
+		return len(cands) < maxSymbolCandidates
+	}
+
+	// Name prefixes, then ID prefixes, then substrings via trigrams
+	for i := sort.SearchStrings(idx.nameKeys, q); i < len(idx.nameKeys) && strings.HasPrefix(idx.nameKeys[i], q); i++ {
+		if !add(idx.byName[i]) {
+			break
+		}
+	}
+	for i := sort.SearchStrings(idx.lower, q); i < len(idx.lower) && strings.HasPrefix(idx.lower[i], q); i++ {
+		if !add(int32(i)) {
+			break
+		}
+	}
+	if q != "" {
+		for _, pos := range idx.candidates(q) {
+			if strings.Contains(idx.lower[pos], q) && !add(pos) {
+				break
+			}
+		}
+	}
+
+	// Camel-case and fuzzy matches need a pass over the names; only pay for
+	// it when the cheaper lookups came up short
+	if len(cands) < limit && q != "" {
+		for i := range idx.names {
+			if _, ok := cands[int32(i)]; ok {
+				continue
+			}
+			if isSubsequence(q, strings.ToLower(idx.names[i])) && !add(int32(i)) {
+				break
+			}
+		}
+	}
+
+	matches := make([]SymbolMatch, 0, len(cands))
+	for pos := range cands {
+		score := matchScore(idx.names[pos], idx.lower[pos], q)
+		if score == 0 {
+			continue
+		}
+		score += inboundBoost * math.Log1p(float64(idx.inbound[pos]))
+		matches = append(matches, SymbolMatch{ID: idx.ids[pos], Name: idx.names[pos], Kind: idx.kinds[pos], Score: math.Round(score*100) / 100})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// matchScore rates how well q (lowercase) matches a symbol: an exact name beats a
+// name or ID prefix, which beats a substring, a camel-case abbreviation
+// ("ns" for NewServer) and finally any in-order subsequence of the name.
+// It returns 0 when q does not match at all.
+func matchScore(name, lowerID, q string) float64 {
+	lowerName := strings.ToLower(name)
+	switch {
+	case q == "":
+		return scoreFuzzy
+	case lowerName == q || lowerID == q:
+		return scoreExact
+	case strings.HasPrefix(lowerName, q):
+		// Shorter completions rank first
+		return scorePrefix - math.Min(float64(len(lowerName)-len(q)), 100)
+	case strings.HasPrefix(lowerID, q):
+		return scorePrefix - math.Min(float64(len(lowerID)-len(q)), 100)
+	case strings.Contains(lowerID, q):
+		return scoreSubstring
+	case camelCaseMatch(name, q):
+		return scoreCamelCase
+	}
+	if gaps, ok := subsequenceGaps(q, lowerName); ok {
+		return scoreFuzzy - math.Min(float64(gaps), 100)
+	}
+	return 0
+}
+
+// camelCaseMatch reports whether q abbreviates name hump by hump: each character
+// either continues the current hump or starts a later one ("nser" and "ns" both
+// match NewServer).
+func camelCaseMatch(name, q string) bool {
+	runes := []rune(name)
+	starts := make([]bool, len(runes))
+	for i, r := range runes {
+		switch {
+		case i == 0:
+			starts[i] = true
+		case unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]):
+			starts[i] = true
+		case unicode.IsLetter(r) && !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]):
+			starts[i] = true
+		}
+	}
+
+	qr := []rune(q)
+	var match func(qi, ni int) bool
+	match = func(qi, ni int) bool {
+		if qi == len(qr) {
+			return true
+		}
+		// Continue the current hump
+		if ni < len(runes) && ni > 0 && !starts[ni] && unicode.ToLower(runes[ni]) == qr[qi] && match(qi+1, ni+1) {
+			return true
+		}
+		// Or jump to a later hump that starts with this character
+		for j := ni; j < len(runes); j++ {
+			if starts[j] && unicode.ToLower(runes[j]) == qr[qi] && match(qi+1, j+1) {
+				return true
+			}
+		}
+		return false
+	}
+	return match(0, 0)
+}
+
+// subsequenceGaps reports whether q appears in s in order and how many
+// characters of s were skipped between the first and last matched character.
+func subsequenceGaps(q, s string) (int, bool) {
+	first, qi := -1, 0
+	for i := 0; i < len(s) && qi < len(q); i++ {
+		if s[i] == q[qi] {
+			if first < 0 {
+				first = i
+			}
+			qi++
+			if qi == len(q) {
+				return i - first + 1 - len(q), true
+			}
+		}
+	}
+	return 0, qi == len(q)
+}
+
+func isSubsequence(q, s string) bool {
+	_, ok := subsequenceGaps(q, s)
+	return ok
+}
+
+// candidates returns the positions that may contain q: the intersection of its
+// trigram posting lists, or every position when q is too short to have trigrams.
+func (idx *symbolIndex) candidates(q string) []int32 {
+	tris := trigramsOf(q)
+	if len(tris) == 0 {
+		all := make([]int32, len(idx.lower))
+		for i := range all {
+			all[i] = int32(i)
+		}
+		return all
+	}
+
+	lists := make([][]int32, 0, len(tris))
+	for _, tri := range tris {
+		list, ok := idx.trigrams[tri]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, list)
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	result := lists[0]
+	for _, list := range lists[1:] {
+		result = intersectSorted(result, list)
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+func intersectSorted(a, b []int32) []int32 {
+	out := make([]int32, 0, min(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// buildSymbolIndex indexes the distinct objects of the project's defines facts,
+// with their names, kinds and incoming call counts.
+func buildSymbolIndex(ctx context.Context, store *meb.MEBStore) *symbolIndex {
+	bySymbol := make(map[string]*symbolInfo)
+	var symbols []*symbolInfo
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateDefines, "") {
+		if err != nil {
+			continue
+		}
+		if obj, ok := fact.Object.(string); ok && bySymbol[obj] == nil {
+			info := &symbolInfo{id: obj}
+			bySymbol[obj] = info
+			symbols = append(symbols, info)
+		}
+	}
+
+	annotate := func(predicate string, apply func(info *symbolInfo, object string)) {
+		for fact, err := range gcamdb.Scan(ctx, store, "", predicate, "") {
+			if err != nil {
+				continue
+			}
+			if info := bySymbol[fact.Subject]; info != nil {
+				if obj, ok := fact.Object.(string); ok {
+					apply(info, obj)
+				}
+			}
+		}
+	}
+	annotate(config.PredicateHasName, func(info *symbolInfo, name string) { info.name = name })
+	annotate(config.PredicateType, func(info *symbolInfo, kind string) { info.kind = kind })
+
+	for fact, err := range gcamdb.Scan(ctx, store, "", config.PredicateCalls, "") {
+		if err != nil {
+			continue
+		}
+		if obj, ok := fact.Object.(string); ok {
+			if info := bySymbol[obj]; info != nil {
+				info.inbound++
+			}
+		}
+	}
+
+	infos := make([]symbolInfo, len(symbols))
+	for i, info := range symbols {
+		infos[i] = *info
+	}
+	return newSymbolIndex(infos)
+}
+
+// symbolIndexCache keeps one symbol index per project, rebuilt when the
+// project's fact count changes.
+type symbolIndexCache struct {
+	mu      sync.Mutex
+	entries map[string]symbolIndexEntry
+}
+
+type symbolIndexEntry struct {
+	index     *symbolIndex
+	factCount uint64
+}
+
+func newSymbolIndexCache() *symbolIndexCache {
+	return &symbolIndexCache{entries: make(map[string]symbolIndexEntry)}
+}
+
+// get returns the project's index, building it if it is missing or stale.
+func (c *symbolIndexCache) get(ctx context.Context, projectID string, store *meb.MEBStore) *symbolIndex {
+	factCount := store.Count()
+	c.mu.Lock()
+	entry, ok := c.entries[projectID]
+	c.mu.Unlock()
+	if ok && entry.factCount == factCount {
+		return entry.index
+	}
+
+	idx := buildSymbolIndex(ctx, store)
+	c.mu.Lock()
+	c.entries[projectID] = symbolIndexEntry{index: idx, factCount: factCount}
+	c.mu.Unlock()
+	return idx
+}
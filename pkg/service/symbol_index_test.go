@@ -0,0 +1,97 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/meb"
+	"github.com/duynguyendang/meb/store"
+)
+
+func matchIDs(matches []SymbolMatch) []string {
+	var ids []string
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestSymbolIndexSearch(t *testing.T) {
+	idx := newSymbolIndex([]symbolInfo{
+		{id: "pkg/a.go:NewServer", kind: "function"},
+		{id: "pkg/a.go:Server", kind: "struct"},
+		{id: "pkg/a.go:serverConfig", kind: "struct"},
+		{id: "pkg/b.go:Handler"},
+		{id: "pkg/b.go:handleServe", inbound: 5},
+		{id: "pkg/c.go:Parse"},
+		{id: "pkg/c.go:NewStreamEncoderReader"},
+	})
+
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		// Exact name, then name prefixes (shorter first), then substrings
+		{"SERVER", 10, []string{"pkg/a.go:Server", "pkg/a.go:serverConfig", "pkg/a.go:NewServer"}},
+		{"serve", 2, []string{"pkg/a.go:Server", "pkg/a.go:serverConfig"}},
+		{"pkg/c.go:p", 10, []string{"pkg/c.go:Parse"}},
+		// Camel-case abbreviations beat plain subsequences
+		{"nser", 10, []string{"pkg/a.go:NewServer", "pkg/c.go:NewStreamEncoderReader", "pkg/b.go:handleServe"}},
+		{"nsr", 10, []string{"pkg/c.go:NewStreamEncoderReader", "pkg/b.go:handleServe", "pkg/a.go:NewServer"}},
+		{"missing", 10, nil},
+	}
+	for _, tt := range tests {
+		if got := matchIDs(idx.search(tt.query, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search(%q, %d) = %v, want %v", tt.query, tt.limit, got, tt.want)
+		}
+	}
+
+	// Incoming edges reorder matches within a tier
+	if got := matchIDs(idx.search("pkg/b.go:h", 10)); !reflect.DeepEqual(got, []string{"pkg/b.go:handleServe", "pkg/b.go:Handler"}) {
+		t.Errorf("search(pkg/b.go:h) = %v, want handleServe (5 callers) first", got)
+	}
+	got := idx.search("serve", 10)
+	if got[len(got)-1].ID != "pkg/a.go:NewServer" {
+		t.Errorf("search(serve) = %v, want handleServe (5 callers) ahead of NewServer", matchIDs(got))
+	}
+	if m := got[0]; m.Name != "Server" || m.Kind != "struct" || m.Score <= 0 {
+		t.Errorf("search(serve)[0] = %+v, want name Server, kind struct and a positive score", m)
+	}
+}
+
+func TestSearchSymbolsRebuildsAfterIngest(t *testing.T) {
+	s, err := meb.NewMEBStore(store.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.AddFactBatch([]meb.Fact{
+		{Subject: "a.go", Predicate: config.PredicateDefines, Object: "a.go:Alpha"},
+		{Subject: "a.go:Alpha", Predicate: config.PredicateType, Object: "function"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewGraphService(&MockStoreManager{store: s})
+
+	got, err := svc.SearchSymbols("test", "alp", "", 10)
+	if err != nil {
+		t.Fatalf("SearchSymbols: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "a.go:Alpha" || got[0].Name != "Alpha" || got[0].Kind != "function" {
+		t.Fatalf("SearchSymbols = %+v, want a.go:Alpha (function)", got)
+	}
+
+	if err := s.AddFact(meb.Fact{Subject: "b.go", Predicate: config.PredicateDefines, Object: "b.go:Alphabet"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = svc.SearchSymbols("test", "alp", "", 10)
+	if err != nil {
+		t.Fatalf("SearchSymbols: %v", err)
+	}
+	if want := []string{"a.go:Alpha", "b.go:Alphabet"}; !reflect.DeepEqual(matchIDs(got), want) {
+		t.Errorf("SearchSymbols after ingest = %v, want %v", matchIDs(got), want)
+	}
+}
@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/config"
+	"github.com/duynguyendang/gca/pkg/logger"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-GCA-Event"     // The event type
+	HeaderDelivery  = "X-GCA-Delivery"  // The event ID, the same on every attempt
+	HeaderSignature = "X-GCA-Signature" // "sha256=" and the HMAC-SHA256 of the body under the secret, for subscriptions with one
+)
+
+// Event is the JSON body posted to subscriptions.
+type Event struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Project string    `json:"project"`
+	Time    time.Time `json:"time"`
+	Data    any       `json:"data"`
+}
+
+// IngestCompleted is the data of ingest.completed and ingest.failed events.
+type IngestCompleted struct {
+	Trigger    string   `json:"trigger"`         // "ingest", "watch" or "schedule"
+	Files      []string `json:"files,omitempty"` // Files a watch sync re-ingested
+	Facts      uint64   `json:"facts"`           // In the store, after the ingest
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// VectorsRebuilt is the data of vectors.rebuilt events.
+type VectorsRebuilt struct {
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+// RuleViolated is the data of rule.violated events.
+type RuleViolated struct {
+	Rule     string           `json:"rule"`
+	Severity string           `json:"severity,omitempty"`
+	Query    string           `json:"query"`
+	Count    int              `json:"count"`
+	Previous int              `json:"previous"` // Rows at the last check, -1 before the first
+	Rows     []map[string]any `json:"rows"`     // Up to config.WebhookRuleRows
+}
+
+type delivery struct {
+	sub  Subscription
+	ev   Event
+	body []byte
+}
+
+// Dispatcher posts events to the subscriptions of a registry. Deliveries are
+// queued and sent in the background, retried with growing delays until one
+// is answered with a 2xx status.
+type Dispatcher struct {
+	registry *Registry
+	client   *http.Client
+	queue    chan delivery
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	checked map[string]int // Rows of each rule at its last check, by project and rule
+}
+
+// NewDispatcher starts posting the events published to the subscriptions of
+// registry.
+func NewDispatcher(registry *Registry) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		registry: registry,
+		client:   &http.Client{Timeout: config.WebhookTimeout},
+		queue:    make(chan delivery, config.WebhookQueueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		checked:  make(map[string]int),
+	}
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// Registry returns the registry the dispatcher posts to.
+func (d *Dispatcher) Registry() *Registry {
+	return d.registry
+}
+
+// Publish queues ev for every subscription wanting it. Its ID and time are
+// filled in when empty. When the queue is full the delivery is dropped and
+// logged rather than holding up the caller.
+func (d *Dispatcher) Publish(ev Event) {
+	if ev.ID == "" {
+		id := make([]byte, 8)
+		rand.Read(id)
+		ev.ID = hex.EncodeToString(id)
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		logger.Warn("Webhook event not encodable", "event", ev.Type, "error", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	for _, sub := range d.registry.Subscriptions() {
+		if !sub.Wants(ev.Type, ev.Project) {
+			continue
+		}
+		select {
+		case d.queue <- delivery{sub: sub, ev: ev, body: body}:
+		default:
+			logger.Warn("Webhook queue full, dropping delivery", "webhook", sub.ID, "event", ev.Type)
+		}
+	}
+}
+
+// CheckRules runs the rules of project through query and publishes
+// rule.violated for each returning rows, unless it returned as many at its
+// last check. A rule that fails to run is logged and skipped.
+func (d *Dispatcher) CheckRules(ctx context.Context, project string, query func(ctx context.Context, query string) ([]map[string]any, error)) {
+	for _, rule := range d.registry.Rules(project) {
+		rows, err := query(ctx, rule.Query)
+		if err != nil {
+			logger.Warn("Webhook rule failed", "rule", rule.Name, "project", project, "error", err)
+			continue
+		}
+		key := project + "\x00" + rule.Name
+		d.mu.Lock()
+		previous, ok := d.checked[key]
+		if !ok {
+			previous = -1
+		}
+		d.checked[key] = len(rows)
+		d.mu.Unlock()
+		if len(rows) == 0 || len(rows) == previous {
+			continue
+		}
+		d.Publish(Event{Type: EventRuleViolated, Project: project, Data: RuleViolated{
+			Rule:     rule.Name,
+			Severity: rule.Severity,
+			Query:    rule.Query,
+			Count:    len(rows),
+			Previous: previous,
+			Rows:     rows[:min(len(rows), config.WebhookRuleRows)],
+		}})
+	}
+}
+
+// Close stops taking events and waits for the queued deliveries until ctx is
+// done, then abandons the rest.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	for del := range d.queue {
+		d.deliver(del)
+	}
+}
+
+// deliver posts a delivery, retrying failures up to
+// config.WebhookMaxAttempts times.
+func (d *Dispatcher) deliver(del delivery) {
+	wait := config.WebhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := d.post(del)
+		if err == nil {
+			return
+		}
+		if attempt == config.WebhookMaxAttempts {
+			logger.Warn("Webhook delivery failed", "webhook", del.sub.ID, "event", del.ev.Type, "attempts", attempt, "error", err)
+			return
+		}
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (d *Dispatcher) post(del delivery) error {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, del.sub.URL, bytes.NewReader(del.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gca-webhook")
+	req.Header.Set(HeaderEvent, del.ev.Type)
+	req.Header.Set(HeaderDelivery, del.ev.ID)
+	if del.sub.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(del.sub.Secret, del.body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the X-GCA-Signature value of body under secret, for receivers
+// checking that a delivery came from this server.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
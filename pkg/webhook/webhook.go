@@ -0,0 +1,221 @@
+// Package webhook notifies external systems, such as chat bots and CI gates,
+// of changes to the graph: it keeps a registry of subscriptions and of rules,
+// Datalog queries that must return no rows, and posts each event as JSON to
+// the subscriptions that want it.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Event types.
+const (
+	EventIngestCompleted = "ingest.completed" // An ingest or watch sync finished
+	EventIngestFailed    = "ingest.failed"    // An ingest or watch sync failed
+	EventFactsImported   = "facts.imported"   // External facts were added to a project
+	EventVectorsRebuilt  = "vectors.rebuilt"  // An ingest added or removed embeddings
+	EventRuleViolated    = "rule.violated"    // A rule returned rows, or a different number of them
+)
+
+// EventTypes lists the events a subscription may ask for.
+var EventTypes = []string{EventIngestCompleted, EventIngestFailed, EventFactsImported, EventVectorsRebuilt, EventRuleViolated}
+
+// Subscription is a URL events are posted to.
+type Subscription struct {
+	ID        string    `yaml:"id" json:"id"`
+	URL       string    `yaml:"url" json:"url"`
+	Events    []string  `yaml:"events,omitempty" json:"events"`     // Event types sent; all when empty
+	Projects  []string  `yaml:"projects,omitempty" json:"projects"` // Projects whose events are sent; all when empty
+	Secret    string    `yaml:"secret,omitempty" json:"secret,omitempty"`
+	CreatedAt time.Time `yaml:"created_at,omitempty" json:"created_at,omitzero"`
+}
+
+// Wants reports whether the subscription is sent events of type typ for project.
+func (s *Subscription) Wants(typ, project string) bool {
+	return (len(s.Events) == 0 || slices.Contains(s.Events, typ)) &&
+		(len(s.Projects) == 0 || slices.Contains(s.Projects, project))
+}
+
+// Rule is a Datalog query that should return no rows, checked whenever a
+// project's graph changes.
+type Rule struct {
+	Name     string `yaml:"name" json:"name"`
+	Query    string `yaml:"query" json:"query"`
+	Project  string `yaml:"project,omitempty" json:"project,omitempty"` // Project checked; all when empty
+	Severity string `yaml:"severity,omitempty" json:"severity,omitempty"`
+}
+
+// File is the layout of the file given to --webhooks.
+type File struct {
+	Webhooks []Subscription `yaml:"webhooks"`
+	Rules    []Rule         `yaml:"rules,omitempty"`
+}
+
+// Registry holds the subscriptions and rules of a webhooks file, and writes
+// the subscriptions added or removed through it back to the file.
+type Registry struct {
+	path string
+
+	mu   sync.RWMutex
+	file File
+}
+
+// OpenRegistry reads the webhooks file at path. A missing file is an empty
+// registry, created by the first subscription added.
+func OpenRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &r.file); err != nil {
+		return nil, fmt.Errorf("failed to parse webhooks file %s: %w", path, err)
+	}
+	ids := make(map[string]bool)
+	for i := range r.file.Webhooks {
+		sub := &r.file.Webhooks[i]
+		if sub.ID == "" {
+			sub.ID = fmt.Sprintf("%d", i+1)
+		}
+		if ids[sub.ID] {
+			return nil, fmt.Errorf("webhook %s is listed twice", sub.ID)
+		}
+		ids[sub.ID] = true
+		if err := validate(sub); err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", sub.ID, err)
+		}
+	}
+	names := make(map[string]bool)
+	for i, rule := range r.file.Rules {
+		if rule.Name == "" || rule.Query == "" {
+			return nil, fmt.Errorf("rule %d: name and query are required", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %s is listed twice", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return r, nil
+}
+
+// validate checks a subscription's URL and event types.
+func validate(sub *Subscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL, got %q", sub.URL)
+	}
+	for _, typ := range sub.Events {
+		if !slices.Contains(EventTypes, typ) {
+			return fmt.Errorf("unknown event %q", typ)
+		}
+	}
+	return nil
+}
+
+// Subscriptions returns the subscriptions, ordered by ID.
+func (r *Registry) Subscriptions() []Subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subs := slices.Clone(r.file.Webhooks)
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs
+}
+
+// AllRules returns every rule, in file order.
+func (r *Registry) AllRules() []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Rule{}, r.file.Rules...)
+}
+
+// Rules returns the rules checked for project.
+func (r *Registry) Rules(project string) []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var rules []Rule
+	for _, rule := range r.file.Rules {
+		if rule.Project == "" || rule.Project == project {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Add validates a subscription, gives it an ID and saves it.
+func (r *Registry) Add(sub Subscription) (Subscription, error) {
+	if err := validate(&sub); err != nil {
+		return Subscription{}, fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Subscription{}, fmt.Errorf("%w: generate webhook ID: %v", errors.ErrInternal, err)
+	}
+	sub.ID = hex.EncodeToString(id)
+	sub.CreatedAt = time.Now().UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file.Webhooks = append(r.file.Webhooks, sub)
+	if err := r.saveLocked(); err != nil {
+		r.file.Webhooks = r.file.Webhooks[:len(r.file.Webhooks)-1]
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Remove deletes the subscription with the given ID.
+func (r *Registry) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.IndexFunc(r.file.Webhooks, func(s Subscription) bool { return s.ID == id })
+	if i < 0 {
+		return fmt.Errorf("%w: webhook %s", errors.ErrNotFound, id)
+	}
+	old := r.file.Webhooks
+	r.file.Webhooks = slices.Delete(slices.Clone(old), i, i+1)
+	if err := r.saveLocked(); err != nil {
+		r.file.Webhooks = old
+		return err
+	}
+	return nil
+}
+
+// saveLocked writes the registry to its file, through a temporary file so a
+// crash never leaves it half written. Callers hold r.mu.
+func (r *Registry) saveLocked() error {
+	data, err := yaml.Marshal(&r.file)
+	if err != nil {
+		return fmt.Errorf("%w: encode webhooks: %v", errors.ErrInternal, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".webhooks-*")
+	if err != nil {
+		return fmt.Errorf("%w: save webhooks: %v", errors.ErrInternal, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w: save webhooks: %v", errors.ErrInternal, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%w: save webhooks: %v", errors.ErrInternal, err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("%w: save webhooks: %v", errors.ErrInternal, err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duynguyendang/gca/pkg/common/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records the deliveries it is sent, failing the first fail of them.
+func receiver(t *testing.T, fail int) (*httptest.Server, func() []received) {
+	var mu sync.Mutex
+	var got []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if fail > 0 {
+			fail--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		got = append(got, received{r.Header, body})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received{}, got...)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.yaml")
+	if err := os.WriteFile(path, []byte(`
+webhooks:
+  - url: https://chat.example.com/hook
+    events: [ingest.completed]
+rules:
+  - name: no-db-from-api
+    project: backend
+    query: triples(?F, "imports", "db")
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, reg.Subscriptions(), 1)
+	assert.Len(t, reg.Rules("backend"), 1)
+	assert.Empty(t, reg.Rules("frontend"))
+
+	_, err = reg.Add(Subscription{URL: "ftp://example.com"})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	_, err = reg.Add(Subscription{URL: "https://ci.example.com", Events: []string{"push"}})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+
+	sub, err := reg.Add(Subscription{URL: "https://ci.example.com", Projects: []string{"backend"}, Secret: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, sub.ID)
+
+	// Saved subscriptions and the rules survive a reopen
+	reopened, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, reopened.Subscriptions(), 2)
+	assert.Len(t, reopened.AllRules(), 1)
+
+	assert.NoError(t, reopened.Remove(sub.ID))
+	assert.ErrorIs(t, reopened.Remove(sub.ID), errors.ErrNotFound)
+	reopened, err = OpenRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, reopened.Subscriptions(), 1)
+
+	_, err = OpenRegistry(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NoError(t, err, "a missing file is an empty registry")
+}
+
+func TestDispatcher(t *testing.T) {
+	signed, signedGot := receiver(t, 1) // Retried once
+	other, otherGot := receiver(t, 0)
+
+	reg, err := OpenRegistry(filepath.Join(t.TempDir(), "webhooks.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Add(Subscription{URL: signed.URL, Secret: "s3cret", Events: []string{EventIngestCompleted}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Add(Subscription{URL: other.URL, Projects: []string{"frontend"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(reg)
+	d.Publish(Event{Type: EventIngestCompleted, Project: "backend", Data: IngestCompleted{Trigger: "ingest", Facts: 42}})
+	d.Publish(Event{Type: EventVectorsRebuilt, Project: "backend", Data: VectorsRebuilt{Before: 1, After: 2}})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	got := signedGot()
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, EventIngestCompleted, got[0].header.Get(HeaderEvent))
+		assert.Equal(t, Sign("s3cret", got[0].body), got[0].header.Get(HeaderSignature))
+		var ev Event
+		if err := json.Unmarshal(got[0].body, &ev); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, got[0].header.Get(HeaderDelivery), ev.ID)
+		assert.Equal(t, "backend", ev.Project)
+		assert.Equal(t, map[string]any{"trigger": "ingest", "facts": float64(42), "duration_ms": float64(0)}, ev.Data)
+	}
+	assert.Empty(t, otherGot(), "the other subscription only wants frontend")
+}
+
+func TestCheckRules(t *testing.T) {
+	srv, got := receiver(t, 0)
+	path := filepath.Join(t.TempDir(), "webhooks.yaml")
+	if err := os.WriteFile(path, []byte(`
+webhooks:
+  - url: `+srv.URL+`
+rules:
+  - name: no-cycles
+    severity: high
+    query: cycles
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(reg)
+
+	rows := 3
+	query := func(ctx context.Context, q string) ([]map[string]any, error) {
+		assert.Equal(t, "cycles", q)
+		out := make([]map[string]any, rows)
+		for i := range out {
+			out[i] = map[string]any{"?A": i}
+		}
+		return out, nil
+	}
+	d.CheckRules(context.Background(), "p", query) // Violated
+	d.CheckRules(context.Background(), "p", query) // Unchanged
+	rows = 0
+	d.CheckRules(context.Background(), "p", query) // Fixed
+	rows = 1
+	d.CheckRules(context.Background(), "p", query) // Violated again
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var counts []RuleViolated
+	for _, r := range got() {
+		var ev struct {
+			Type string       `json:"type"`
+			Data RuleViolated `json:"data"`
+		}
+		if err := json.Unmarshal(r.body, &ev); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, EventRuleViolated, ev.Type)
+		counts = append(counts, ev.Data)
+	}
+	if assert.Len(t, counts, 2) {
+		assert.Equal(t, 3, counts[0].Count)
+		assert.Equal(t, -1, counts[0].Previous)
+		assert.Equal(t, "high", counts[0].Severity)
+		assert.Len(t, counts[0].Rows, 3)
+		assert.Equal(t, 1, counts[1].Count)
+		assert.Equal(t, 0, counts[1].Previous)
+	}
+}